@@ -4,6 +4,9 @@ go 1.13
 
 require (
 	github.com/blang/semver v3.5.1+incompatible
+	github.com/containerd/containerd v1.3.2
+	github.com/docker/cli v0.0.0-20200130152716-5d0cf8839492
+	github.com/docker/distribution v2.7.1+incompatible
 	github.com/fatih/structtag v1.1.0
 	github.com/go-logr/logr v0.1.0
 	github.com/iancoleman/strcase v0.0.0-20191112232945-16388991a334
@@ -11,9 +14,10 @@ require (
 	github.com/markbates/inflect v1.0.4
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
+	github.com/opencontainers/image-spec v1.0.2-0.20190823105129-775207bd45b6
 	github.com/operator-framework/api v0.3.13
 	github.com/operator-framework/operator-lib v0.1.0
-	github.com/operator-framework/operator-registry v1.13.4
+	github.com/operator-framework/operator-registry v1.19.5
 	github.com/prometheus/client_golang v1.5.1
 	github.com/sergi/go-diff v1.0.0
 	github.com/sirupsen/logrus v1.5.0
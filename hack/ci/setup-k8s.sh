@@ -16,7 +16,8 @@ install_kubectl $tmp_sdk_root
 fetch_tools $tmp_sdk_root
 setup_envs $tmp_sdk_root
 
-# Create a cluster of version $K8S_VERSION.
+# Create a cluster of version $K8S_VERSION. kind nodes run containerd only, with no
+# docker daemon or socket, which the run bundle integration tests require.
 kind create cluster --image="$KIND_IMAGE"
 
 # Run this command externally after installation:
@@ -23,9 +23,10 @@ test_version() {
     commandoutput=$(operator-sdk olm install $ver_flag 2>&1)
     echo $commandoutput | grep -F "Successfully installed OLM version \\\"${version}\\\""
 
-    # Install should fail with OLM Installed
-    commandoutput=$(operator-sdk olm install $ver_flag 2>&1 || true)
-    echo $commandoutput | grep -F "Failed to install OLM version \\\"${version}\\\": detected existing OLM resources: OLM must be completely uninstalled before installation"
+    # Install should succeed again with the same version installed
+    commandoutput=$(operator-sdk olm install $ver_flag 2>&1)
+    echo $commandoutput | grep -F "Detected existing OLM resources, resuming installation"
+    echo $commandoutput | grep -F "Successfully installed OLM version \\\"${version}\\\""
 
     # Status should succeed with OLM installed
     commandoutput=$(operator-sdk olm status 2>&1)
@@ -36,5 +37,105 @@ test_version() {
     echo $commandoutput | grep -F "Successfully uninstalled OLM"
 }
 
+# Uninstall with --force should release a CSV stuck on a finalizer so OLM's
+# namespaces eventually terminate.
+test_force_uninstall() {
+    operator-sdk olm install
+    kubectl patch csv packageserver -n olm --type merge \
+      -p '{"metadata":{"finalizers":["operators.coreos.com/stuck"]}}'
+
+    commandoutput=$(operator-sdk olm uninstall --force --force-grace-period 10s --timeout 5m 2>&1)
+    echo $commandoutput | grep -F "Forcibly released ClusterServiceVersion \\\"olm/packageserver\\\""
+    echo $commandoutput | grep -F "Successfully uninstalled OLM"
+    for ns in olm operators; do
+      kubectl get namespace $ns 2>&1 | grep -F "NotFound"
+    done
+}
+
+# Install should resume an installation missing some of its resources, and
+# require --upgrade to install over another version.
+test_resume_install() {
+    operator-sdk olm install --version 0.15.1
+    kubectl delete deployment catalog-operator -n olm
+
+    commandoutput=$(operator-sdk olm install --version 0.15.1 2>&1)
+    echo $commandoutput | grep -F "Detected existing OLM resources, resuming installation"
+    kubectl get deployment catalog-operator -n olm
+
+    commandoutput=$(operator-sdk olm install --version 0.16.1 2>&1 || true)
+    echo $commandoutput | grep -F "set --upgrade to upgrade it"
+
+    operator-sdk olm uninstall
+}
+
+# Subscriptions should survive an uninstall with --preserve-crds and a reinstall.
+test_preserve_crds() {
+    operator-sdk olm install
+    kubectl create namespace preserve-crds
+    cat <<EOF | kubectl apply -f -
+apiVersion: operators.coreos.com/v1alpha1
+kind: Subscription
+metadata:
+  name: etcd
+  namespace: preserve-crds
+spec:
+  channel: singlenamespace-alpha
+  name: etcd
+  source: operatorhubio-catalog
+  sourceNamespace: olm
+EOF
+
+    commandoutput=$(operator-sdk olm uninstall --preserve-crds 2>&1)
+    echo $commandoutput | grep -F "Preserving OLM's CRDs"
+    kubectl get namespace olm 2>&1 | grep -F "NotFound"
+    kubectl get crd subscriptions.operators.coreos.com
+    kubectl get subscription etcd -n preserve-crds
+
+    operator-sdk olm install
+    kubectl get subscription etcd -n preserve-crds
+
+    kubectl delete namespace preserve-crds
+    operator-sdk olm uninstall
+}
+
+# Status should report OLM unhealthy while the package server APIService is
+# unavailable. olm-operator is scaled down first so it does not restore packageserver.
+test_status_unhealthy() {
+    operator-sdk olm install
+    kubectl scale deployment olm-operator -n olm --replicas=0
+    kubectl scale deployment packageserver -n olm --replicas=0
+    kubectl wait --for=condition=Available=False apiservice/v1.packages.operators.coreos.com --timeout=2m
+
+    commandoutput=$(operator-sdk olm status -o json 2>/dev/null || true)
+    echo $commandoutput | grep -F '"healthy": false'
+    rc=0
+    operator-sdk olm status || rc=$?
+    if [[ $rc -ne 3 ]]; then
+      echo "olm status should exit 3 while OLM resources are degraded, got $rc"
+      exit 1
+    fi
+
+    operator-sdk olm uninstall
+}
+
+# OLM installed in a custom namespace should be found by status and uninstall
+# without --olm-namespace.
+test_custom_namespace() {
+    operator-sdk olm install --olm-namespace platform-olm
+    kubectl get deployment olm-operator -n platform-olm
+
+    commandoutput=$(operator-sdk olm install 2>&1 || true)
+    echo $commandoutput | grep -F "OLM is already installed in namespace \\\"platform-olm\\\""
+
+    operator-sdk olm status
+    operator-sdk olm uninstall
+    kubectl get namespace platform-olm 2>&1 | grep -F "NotFound"
+}
+
 test_version "latest"
 test_version "0.10.1"
+test_custom_namespace
+test_resume_install
+test_preserve_crds
+test_status_unhealthy
+test_force_uninstall
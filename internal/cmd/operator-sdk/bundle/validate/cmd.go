@@ -32,9 +32,29 @@ const (
 image or an operator bundle directory on-disk containing operator metadata and manifests. This command will exit
 with an exit code of 1 if any validation errors arise, and 0 if only warnings arise or all validators pass.
 
+Objects of Kubernetes APIs removed in the target version set with '--k8s-version', or the CSV's
+spec.minKubeVersion if unset, are reported with the removed API and its replacement, ex. v1beta1 CRDs
+on Kubernetes 1.22+. They are errors if spec.minKubeVersion does not serve the API, and warnings otherwise.
+
+Optional validators, ex. OperatorHub.io submission checks, only run if selected with '--select-optional'.
+Findings of optional validators are prefixed with the validator's name, ex. '[community]'.
+
+External validator binaries, ex. for organization-specific policies, run if passed to '--alpha-select-external'.
+Each binary is passed the bundle root as its argument and the bundle's annotations as JSON on stdin, and must
+print its findings as JSON to stdout, in the same format as '--output json' findings. Binaries that fail or
+print invalid JSON are reported as validation errors.
+
+Set '--output json' to print findings and a summary of them as JSON for use in CI. Each finding has the name
+of the validator that reported it ("required" for validators that always run), a severity of error, warning,
+or info, a message, and the file or object it refers to if known. The exit code does not depend on the format.
+
 More information about operator bundles and metadata:
 https://github.com/operator-framework/operator-registry/blob/master/docs/design/operator-bundle.md
 
+Bundle images are pulled and unpacked without a container tool or daemon by default, with credentials in your
+docker config file or the file passed to '--pull-secret'. The image's bundle labels, ex. its package and channels,
+must match the bundle's annotations.yaml. Set '--image-builder' to pull images with docker or podman instead.
+
 NOTE: if validating an image, the image must exist in a remote registry, not just locally.
 `
 
@@ -57,13 +77,46 @@ To build and validate a *pullable* bundle image:
 
   $ operator-sdk bundle validate <some-registry>/<operator-bundle-name>:<tag>
 
-To list and run optional validators, which are specified by a label selector:
+To validate a bundle image in a registry served over plain HTTP, with credentials in a pull secret:
+
+  $ operator-sdk bundle validate localhost:5000/<operator-bundle-name>:<tag> --use-http --pull-secret ./auth.json
+
+To list and run optional validators, which are specified by one or more label selectors:
 
   $ operator-sdk bundle validate --list-optional
-  NAME           LABELS                     DESCRIPTION
-  operatorhub    name=operatorhub           OperatorHub.io metadata validation
-                 suite=operatorframework
+  NAME              LABELS                     DESCRIPTION
+  operatorhub       name=operatorhub           OperatorHub.io metadata validation: provider, maintainers, links, icon, categories, and capabilities
+                    suite=operatorframework
+  community         name=community             Community catalog submission requirements: containerImage and repository annotations, spec.version, and spec.minKubeVersion
+                    suite=operatorframework
+  good-practices    name=good-practices        Recommended practices: container resource requests, least-privilege RBAC, and apiextensions.k8s.io/v1 CRDs
+                    suite=operatorframework
+  image-refs        name=image-refs            Image references in the CSV are valid, and with --verify-images exist in their registries, and with --require-digests are pinned to digests
+                    suite=operatorframework
+  multiarch         name=multiarch             CSV operatorframework.io/arch.* and os.* labels are valid, and with --verify-images Deployment images support each declared platform
+                    suite=operatorframework
+  $ operator-sdk bundle validate ./bundle --select-optional name=operatorhub --select-optional name=community
   $ operator-sdk bundle validate ./bundle --select-optional suite=operatorframework
+
+To verify that images referenced by the CSV exist and are pinned to digests:
+
+  $ operator-sdk bundle validate ./bundle --select-optional name=image-refs --verify-images --require-digests
+
+To verify that images run by the CSV support the architectures declared by its labels:
+
+  $ operator-sdk bundle validate ./bundle --select-optional name=multiarch --verify-images
+
+To check for APIs removed in Kubernetes 1.22:
+
+  $ operator-sdk bundle validate ./bundle --k8s-version 1.22
+
+To run an external validator:
+
+  $ operator-sdk bundle validate ./bundle --alpha-select-external ./bin/org-bundle-validator
+
+To print findings as JSON:
+
+  $ operator-sdk bundle validate ./bundle --select-optional suite=operatorframework --output json
 `
 )
 
@@ -82,8 +135,12 @@ func NewCmd() *cobra.Command {
 			// and the file will have only the JSON result.
 			logger := createLogger(viper.GetBool(flags.VerboseOpt))
 
-			if c.selector, err = labels.Parse(c.selectorRaw); err != nil {
-				logger.Fatal(err)
+			for _, selectorRaw := range c.selectorsRaw {
+				sel, err := labels.Parse(selectorRaw)
+				if err != nil {
+					logger.Fatal(err)
+				}
+				c.selectors = append(c.selectors, sel)
 			}
 
 			if err = c.validate(args); err != nil {
@@ -0,0 +1,110 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/bundle/validate/internal"
+)
+
+// externalValidatorInput is written as JSON to the stdin of external validators,
+// which are passed the bundle root as their only argument.
+type externalValidatorInput struct {
+	// BundleRoot is the absolute path to the unpacked bundle.
+	BundleRoot string `json:"bundleRoot"`
+	// Annotations are the bundle's metadata, ex. its package and channels.
+	Annotations map[string]string `json:"annotations"`
+}
+
+// externalValidatorOutput is the JSON external validators must write to stdout. Findings have the same
+// schema as those written with '--output json', except their validator is always the binary's name.
+type externalValidatorOutput struct {
+	Findings []internal.Finding `json:"findings"`
+}
+
+// checkExternalValidator returns an error if path is not an executable file.
+func checkExternalValidator(path string) error {
+	if _, err := exec.LookPath(path); err != nil {
+		return fmt.Errorf("external validator %q is not executable: %v", path, err)
+	}
+	return nil
+}
+
+// runExternalValidator runs the external validator binary at path on the bundle in bundleRoot with
+// metadata, and returns its findings. If the binary fails or writes invalid output, a single error
+// finding naming it is returned.
+func runExternalValidator(path, bundleRoot string, metadata map[string]string) []internal.Finding {
+	name := filepath.Base(path)
+	findings, err := execExternalValidator(path, bundleRoot, metadata)
+	if err != nil {
+		return []internal.Finding{{
+			Validator: name,
+			Severity:  log.ErrorLevel.String(),
+			Message:   fmt.Sprintf("external validator %s failed: %v", path, err),
+			Object:    path,
+		}}
+	}
+	for i := range findings {
+		findings[i].Validator = name
+	}
+	return findings
+}
+
+func execExternalValidator(path, bundleRoot string, metadata map[string]string) ([]internal.Finding, error) {
+	absRoot, err := filepath.Abs(bundleRoot)
+	if err != nil {
+		return nil, err
+	}
+	input, err := json.Marshal(externalValidatorInput{BundleRoot: absRoot, Annotations: metadata})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling input: %v", err)
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.Command(path, absRoot)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	output := externalValidatorOutput{}
+	dec := json.NewDecoder(stdout)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&output); err != nil {
+		return nil, fmt.Errorf("error decoding output: %v", err)
+	}
+	for _, f := range output.Findings {
+		switch f.Severity {
+		case log.ErrorLevel.String(), log.WarnLevel.String(), log.InfoLevel.String():
+		default:
+			return nil, fmt.Errorf("finding %q has invalid severity %q, must be one of [error, warning, info]",
+				f.Message, f.Severity)
+		}
+	}
+	return output.Findings, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/bundle/validate/internal"
+)
+
+var _ = Describe("Running external validators", func() {
+	var (
+		bundleRoot = filepath.Join("testdata", "bad-bundle")
+		metadata   map[string]string
+	)
+
+	BeforeEach(func() {
+		metadata = map[string]string{"operators.operatorframework.io.bundle.package.v1": "memcached-operator"}
+	})
+
+	validatorPath := func(name string) string {
+		return filepath.Join("testdata", "external-validators", name)
+	}
+
+	Describe("checkExternalValidator", func() {
+		It("succeeds for an executable file", func() {
+			Expect(checkExternalValidator(validatorPath("example-validator.sh"))).To(Succeed())
+		})
+		It("fails for a file that does not exist", func() {
+			Expect(checkExternalValidator(validatorPath("does-not-exist.sh"))).NotTo(Succeed())
+		})
+	})
+
+	Describe("runExternalValidator", func() {
+		It("returns the example validator's findings", func() {
+			Expect(runExternalValidator(validatorPath("example-validator.sh"), bundleRoot, metadata)).To(Equal([]internal.Finding{
+				{
+					Validator: "example-validator.sh",
+					Severity:  "warning",
+					Message:   "metadata.annotations.example.com/support should be set to a support contact",
+					Object:    "memcached-operator.clusterserviceversion.yaml",
+				},
+			}))
+		})
+		It("returns error findings reported from bundle metadata", func() {
+			metadata["operators.operatorframework.io.bundle.package.v1"] = "memcached"
+			findings := runExternalValidator(validatorPath("example-validator.sh"), bundleRoot, metadata)
+			Expect(findings).To(HaveLen(2))
+			Expect(findings[0]).To(Equal(internal.Finding{
+				Validator: "example-validator.sh",
+				Severity:  "error",
+				Message:   "package name must end with -operator",
+				Object:    "memcached",
+			}))
+		})
+		It("returns an error finding naming a binary that crashes", func() {
+			path := validatorPath("crash.sh")
+			findings := runExternalValidator(path, bundleRoot, metadata)
+			Expect(findings).To(HaveLen(1))
+			Expect(findings[0].Validator).To(Equal("crash.sh"))
+			Expect(findings[0].Severity).To(Equal("error"))
+			Expect(findings[0].Message).To(HavePrefix("external validator " + path + " failed: "))
+			Expect(findings[0].Message).To(ContainSubstring("unexpected failure"))
+		})
+		It("returns an error finding naming a binary that writes invalid JSON", func() {
+			path := validatorPath("invalid-json.sh")
+			findings := runExternalValidator(path, bundleRoot, metadata)
+			Expect(findings).To(HaveLen(1))
+			Expect(findings[0].Severity).To(Equal("error"))
+			Expect(findings[0].Message).To(HavePrefix("external validator " + path + " failed: error decoding output"))
+		})
+		It("returns an error finding naming a binary that writes an invalid severity", func() {
+			path := validatorPath("invalid-severity.sh")
+			findings := runExternalValidator(path, bundleRoot, metadata)
+			Expect(findings).To(HaveLen(1))
+			Expect(findings[0].Severity).To(Equal("error"))
+			Expect(findings[0].Message).To(ContainSubstring(`has invalid severity "fatal"`))
+		})
+	})
+
+	Describe("merging findings", func() {
+		It("fails the result only if an external validator reports an error", func() {
+			res := internal.NewResult()
+			res.AddFindings(runExternalValidator(validatorPath("example-validator.sh"), bundleRoot, metadata)...)
+			Expect(res.Passed).To(BeTrue())
+			Expect(res.Outputs).To(HaveLen(1))
+			Expect(res.Outputs[0].Message).To(HavePrefix("[example-validator.sh] "))
+
+			res.AddFindings(runExternalValidator(validatorPath("crash.sh"), bundleRoot, metadata)...)
+			Expect(res.Passed).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,130 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	apierrors "github.com/operator-framework/api/pkg/validation/errors"
+	interfaces "github.com/operator-framework/api/pkg/validation/interfaces"
+	corev1 "k8s.io/api/core/v1"
+
+	internalregistry "github.com/operator-framework/operator-sdk/internal/registry"
+)
+
+// relatedImageEnvPrefix prefixes the names of container env vars containing related images.
+const relatedImageEnvPrefix = "RELATED_IMAGE_"
+
+// imageRefsOptions configure imageRefsValidator, which reads them from the objects it validates.
+type imageRefsOptions struct {
+	// resolver, if set, is used to verify that each image exists in its registry.
+	resolver internalregistry.DigestResolver
+	// requireDigests, if true, makes tag-based image references warnings.
+	requireDigests bool
+}
+
+// imageRefsValidator checks that image references in a bundle's CSV are valid,
+// and optionally that they exist and are pinned to digests.
+var imageRefsValidator interfaces.Validator = interfaces.ValidatorFunc(validateImageRefsBundles)
+
+func validateImageRefsBundles(objs ...interface{}) (results []apierrors.ManifestResult) {
+	opts := imageRefsOptions{}
+	for _, obj := range objs {
+		if o, ok := obj.(imageRefsOptions); ok {
+			opts = o
+		}
+	}
+	for _, obj := range objs {
+		if bundle, ok := obj.(*apimanifests.Bundle); ok {
+			results = append(results, validateImageRefs(context.TODO(), bundle, opts))
+		}
+	}
+	return results
+}
+
+func validateImageRefs(ctx context.Context, bundle *apimanifests.Bundle, opts imageRefsOptions) apierrors.ManifestResult {
+	result := apierrors.ManifestResult{Name: bundle.Name}
+	if bundle.CSV == nil {
+		return result
+	}
+
+	// Resolve each image only once, since many may be the same.
+	resolveErrs := map[string]error{}
+	for _, ref := range getImageRefs(bundle.CSV) {
+		named, err := reference.ParseNormalizedNamed(ref.image)
+		if err != nil {
+			result.Add(newCSVError(apierrors.LevelError, ref.image,
+				fmt.Sprintf("%s %q is not a valid image reference: %v", ref.field, ref.image, err)))
+			continue
+		}
+		if _, isDigested := named.(reference.Digested); !isDigested && opts.requireDigests {
+			result.Add(newCSVError(apierrors.LevelWarn, ref.image,
+				fmt.Sprintf("%s %q is not pinned to a digest", ref.field, ref.image)))
+		}
+		if opts.resolver == nil {
+			continue
+		}
+		err, isResolved := resolveErrs[ref.image]
+		if !isResolved {
+			_, err = opts.resolver.ResolveDigest(ctx, ref.image)
+			resolveErrs[ref.image] = err
+		}
+		if err != nil {
+			result.Add(newCSVError(apierrors.LevelError, ref.image,
+				fmt.Sprintf("%s %q cannot be resolved: %v", ref.field, ref.image, err)))
+		}
+	}
+	return result
+}
+
+// imageRef is an image reference in a CSV field.
+type imageRef struct {
+	image string
+	// field describes where in the CSV image is referenced.
+	field string
+}
+
+// getImageRefs returns all image references in csv's install strategy Deployment containers,
+// RELATED_IMAGE_* env vars, relatedImages, and "containerImage" annotation.
+func getImageRefs(csv *v1alpha1.ClusterServiceVersion) (refs []imageRef) {
+	addContainers := func(depName string, containers []corev1.Container) {
+		for _, c := range containers {
+			field := fmt.Sprintf("deployment %q container %q", depName, c.Name)
+			refs = append(refs, imageRef{image: c.Image, field: field + " image"})
+			for _, env := range c.Env {
+				if strings.HasPrefix(env.Name, relatedImageEnvPrefix) && env.ValueFrom == nil {
+					refs = append(refs, imageRef{image: env.Value, field: fmt.Sprintf("%s env %s", field, env.Name)})
+				}
+			}
+		}
+	}
+	for _, dep := range csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs {
+		addContainers(dep.Name, dep.Spec.Template.Spec.InitContainers)
+		addContainers(dep.Name, dep.Spec.Template.Spec.Containers)
+	}
+	for _, ri := range csv.Spec.RelatedImages {
+		refs = append(refs, imageRef{image: ri.Image, field: fmt.Sprintf("spec.relatedImages %q", ri.Name)})
+	}
+	// A missing containerImage annotation is reported by the community validator.
+	if image := csv.GetAnnotations()[containerImageAnnotation]; image != "" {
+		refs = append(refs, imageRef{image: image, field: "metadata.annotations." + containerImageAnnotation})
+	}
+	return refs
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	apierrors "github.com/operator-framework/api/pkg/validation/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	internalregistry "github.com/operator-framework/operator-sdk/internal/registry"
+)
+
+var _ = Describe("Running the image-refs validator", func() {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	var (
+		ctx    = context.TODO()
+		bundle *apimanifests.Bundle
+		csv    *v1alpha1.ClusterServiceVersion
+	)
+
+	// newBundle returns a bundle whose CSV references operatorImage in its Deployment and containerImage
+	// annotation, relatedImage in a RELATED_IMAGE_* env var and relatedImages.
+	newBundle := func(operatorImage, relatedImage string) *apimanifests.Bundle {
+		csv = &v1alpha1.ClusterServiceVersion{}
+		csv.SetName("memcached-operator.v0.0.1")
+		csv.SetAnnotations(map[string]string{containerImageAnnotation: operatorImage})
+		csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs = []v1alpha1.StrategyDeploymentSpec{{Name: "memcached-operator"}}
+		csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs[0].Spec.Template.Spec.Containers = []corev1.Container{{
+			Name:  "manager",
+			Image: operatorImage,
+			Env: []corev1.EnvVar{
+				{Name: "RELATED_IMAGE_MEMCACHED", Value: relatedImage},
+				{Name: "WATCH_NAMESPACE", Value: "not an image"},
+			},
+		}}
+		csv.Spec.RelatedImages = []v1alpha1.RelatedImage{{Name: "memcached", Image: relatedImage}}
+		return &apimanifests.Bundle{Name: "memcached-operator.v0.0.1", CSV: csv}
+	}
+
+	// details returns the details of all errors and warnings in result.
+	details := func(result apierrors.ManifestResult) (ds []string) {
+		for _, e := range append(result.Errors, result.Warnings...) {
+			ds = append(ds, e.Detail)
+		}
+		return ds
+	}
+
+	It("reports nothing for valid image references by default", func() {
+		bundle = newBundle("quay.io/example/memcached-operator:v0.0.1", "docker.io/library/memcached@"+digest)
+		Expect(details(validateImageRefs(ctx, bundle, imageRefsOptions{}))).To(BeEmpty())
+	})
+
+	It("reports invalid image references as errors", func() {
+		bundle = newBundle("quay.io/example/memcached-operator:v0.0.1", "Memcached:Latest Version")
+		result := validateImageRefs(ctx, bundle, imageRefsOptions{})
+		Expect(result.Warnings).To(BeEmpty())
+		Expect(details(result)).To(ConsistOf(
+			HavePrefix(`deployment "memcached-operator" container "manager" env RELATED_IMAGE_MEMCACHED "Memcached:Latest Version" is not a valid image reference`),
+			HavePrefix(`spec.relatedImages "memcached" "Memcached:Latest Version" is not a valid image reference`),
+		))
+		Expect(result.Errors[0].BadValue).To(Equal("Memcached:Latest Version"))
+	})
+
+	It("warns about tag-based image references if digests are required", func() {
+		bundle = newBundle("quay.io/example/memcached-operator:v0.0.1", "docker.io/library/memcached@"+digest)
+		result := validateImageRefs(ctx, bundle, imageRefsOptions{requireDigests: true})
+		Expect(result.Errors).To(BeEmpty())
+		Expect(details(result)).To(ConsistOf(
+			`deployment "memcached-operator" container "manager" image "quay.io/example/memcached-operator:v0.0.1" is not pinned to a digest`,
+			`metadata.annotations.containerImage "quay.io/example/memcached-operator:v0.0.1" is not pinned to a digest`,
+		))
+	})
+
+	It("reads options from validated objects", func() {
+		bundle = newBundle("quay.io/example/memcached-operator:v0.0.1", "docker.io/library/memcached@"+digest)
+		results := validateImageRefsBundles(bundle, imageRefsOptions{requireDigests: true})
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Warnings).To(HaveLen(2))
+	})
+
+	Context("with a local registry", func() {
+		var (
+			server   *httptest.Server
+			host     string
+			resolver internalregistry.DigestResolver
+		)
+
+		BeforeEach(func() {
+			server = newLocalRegistry("memcached-operator", "v0.0.1")
+			host = strings.TrimPrefix(server.URL, "http://")
+			var err error
+			resolver, err = internalregistry.NewDigestResolver("", false, true)
+			Expect(err).NotTo(HaveOccurred())
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("reports nothing for an image present in the registry", func() {
+			bundle = newBundle(host+"/memcached-operator:v0.0.1", host+"/memcached-operator:v0.0.1")
+			Expect(details(validateImageRefs(ctx, bundle, imageRefsOptions{resolver: resolver}))).To(BeEmpty())
+		})
+
+		It("reports an image missing from the registry as an error", func() {
+			missing := host + "/memcached:1.4"
+			bundle = newBundle(host+"/memcached-operator:v0.0.1", missing)
+			result := validateImageRefs(ctx, bundle, imageRefsOptions{resolver: resolver})
+			Expect(result.Warnings).To(BeEmpty())
+			Expect(details(result)).To(ConsistOf(
+				HavePrefix(fmt.Sprintf(`deployment "memcached-operator" container "manager" env RELATED_IMAGE_MEMCACHED %q cannot be resolved`, missing)),
+				HavePrefix(fmt.Sprintf(`spec.relatedImages "memcached" %q cannot be resolved`, missing)),
+			))
+		})
+	})
+})
+
+// newLocalRegistry returns a server implementing the registry API for only the image manifest of name:tag.
+func newLocalRegistry(name, tag string) *httptest.Server {
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json",` +
+		`"config":{"mediaType":"application/vnd.docker.container.image.v1+json","size":0,` +
+		`"digest":"sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},"layers":[]}`)
+	manifestDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+	manifestPaths := map[string]bool{
+		fmt.Sprintf("/v2/%s/manifests/%s", name, tag):            true,
+		fmt.Sprintf("/v2/%s/manifests/%s", name, manifestDigest): true,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case manifestPaths[r.URL.Path]:
+			w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+			w.Header().Set("Docker-Content-Digest", manifestDigest)
+			w.Header().Set("Content-Length", strconv.Itoa(len(manifest)))
+			w.WriteHeader(http.StatusOK)
+			if r.Method == http.MethodGet {
+				_, _ = w.Write(manifest)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	apierrors "github.com/operator-framework/api/pkg/validation/errors"
@@ -26,10 +27,14 @@ import (
 )
 
 const (
+	JSON       = "json"
 	JSONAlpha1 = "json-alpha1"
 	Text       = "text"
 )
 
+// RequiredValidatorName is the validator name of findings of validators that always run.
+const RequiredValidatorName = "required"
+
 // Result represents the final result
 type Result struct {
 	Passed  bool     `json:"passed"`
@@ -40,6 +45,36 @@ type Result struct {
 type output struct {
 	Type    string `json:"type"`
 	Message string `json:"message"`
+
+	// Fields of findings only written in the json format.
+	validator string
+	object    string
+	detail    string
+}
+
+// Finding is a validation finding written in the json format.
+type Finding struct {
+	// Validator is the name of the validator that reported the finding.
+	Validator string `json:"validator"`
+	// Severity is one of error, warning, or info.
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// Object is the name of the file or object the finding refers to, if known.
+	Object string `json:"object,omitempty"`
+}
+
+// Summary counts findings by severity, and passes if there are no errors.
+type Summary struct {
+	Errors   int  `json:"errors"`
+	Warnings int  `json:"warnings"`
+	Infos    int  `json:"infos"`
+	Passed   bool `json:"passed"`
+}
+
+// Report is the result written in the json format.
+type Report struct {
+	Findings []Finding `json:"findings"`
+	Summary  Summary   `json:"summary"`
 }
 
 // NewResult return a new result object which starts with passed == true since has no errors
@@ -47,18 +82,66 @@ func NewResult() *Result {
 	return &Result{Passed: true}
 }
 
-// AddManifestResults adds warnings and errors in results to Results.
+// AddManifestResults adds warnings and errors in results of required validators to Results.
 func (o *Result) AddManifestResults(results ...apierrors.ManifestResult) {
+	o.AddValidatorResults("", results...)
+}
+
+// AddValidatorResults adds warnings and errors in results of the optional validator with
+// name validator to Results. Messages of optional validators are prefixed with their name.
+func (o *Result) AddValidatorResults(validator string, results ...apierrors.ManifestResult) {
+	prefix := ""
+	if validator != "" {
+		prefix = fmt.Sprintf("[%s] ", validator)
+	}
 	for _, r := range results {
 		for _, w := range r.Warnings {
-			o.AddWarn(w)
+			o.Outputs = append(o.Outputs, newFindingOutput(logrus.WarnLevel, validator, prefix, r.Name, w))
 		}
 		for _, e := range r.Errors {
-			o.AddError(e)
+			o.Outputs = append(o.Outputs, newFindingOutput(logrus.ErrorLevel, validator, prefix, r.Name, e))
+			o.Passed = false
 		}
 	}
 }
 
+// AddFindings adds findings, ex. of external validators, to Results. Messages are prefixed
+// with the name of the validator that reported them.
+func (o *Result) AddFindings(findings ...Finding) {
+	for _, f := range findings {
+		o.Outputs = append(o.Outputs, output{
+			Type:      f.Severity,
+			Message:   fmt.Sprintf("[%s] %s", f.Validator, f.Message),
+			validator: f.Validator,
+			object:    f.Object,
+			detail:    f.Message,
+		})
+		if f.Severity == logrus.ErrorLevel.String() {
+			o.Passed = false
+		}
+	}
+}
+
+// newFindingOutput returns an output for e, which refers to the object named by e's bad value,
+// or resultName if it has none.
+func newFindingOutput(lvl logrus.Level, validator, prefix, resultName string, e apierrors.Error) output {
+	object := resultName
+	if value, ok := e.BadValue.(string); ok && value != "" {
+		object = value
+	}
+	detail := e.Detail
+	if detail == "" {
+		detail = e.Error()
+	}
+	return output{
+		Type:      lvl.String(),
+		Message:   prefix + e.Error(),
+		validator: validator,
+		object:    object,
+		detail:    detail,
+	}
+}
+
 // AddInfo will add a log to the result with the Info Level
 func (o *Result) AddInfo(msg string) {
 	o.Outputs = append(o.Outputs, output{
@@ -126,6 +209,49 @@ func (o *Result) printJSON() error {
 	return nil
 }
 
+// WriteReport writes findings and their summary to w in the json format. The schema of
+// this format is stable, so changes to it must be backwards-compatible.
+func (o *Result) WriteReport(w io.Writer) error {
+	report := Report{Findings: []Finding{}, Summary: Summary{Passed: true}}
+	for _, obj := range o.Outputs {
+		lvl, err := logrus.ParseLevel(obj.Type)
+		if err != nil {
+			return err
+		}
+		switch lvl {
+		case logrus.InfoLevel:
+			report.Summary.Infos++
+		case logrus.WarnLevel:
+			report.Summary.Warnings++
+		case logrus.ErrorLevel:
+			report.Summary.Errors++
+			report.Summary.Passed = false
+		default:
+			return fmt.Errorf("unknown output level %q", obj.Type)
+		}
+		f := Finding{
+			Validator: obj.validator,
+			Severity:  lvl.String(),
+			Message:   obj.detail,
+			Object:    obj.object,
+		}
+		if f.Validator == "" {
+			f.Validator = RequiredValidatorName
+		}
+		if f.Message == "" {
+			f.Message = obj.Message
+		}
+		report.Findings = append(report.Findings, f)
+	}
+
+	b, err := json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		return fmt.Errorf("error marshaling JSON output: %v", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", string(b))
+	return err
+}
+
 // prepare should be used when writing an Result to a non-log writer.
 // it will ensure that the passed boolean will properly set in the case of the setters were not properly used
 func (o *Result) prepare() error {
@@ -164,6 +290,10 @@ func (o *Result) PrintWithFormat(format string) (err error) {
 func (o *Result) getPrintFuncFormat(format string) func(*Result) error {
 	// PrintWithFormat output in desired format.
 	switch format {
+	case JSON:
+		return func(o *Result) error {
+			return o.WriteReport(os.Stdout)
+		}
 	case JSONAlpha1:
 		return func(o *Result) error {
 			return o.printJSON()
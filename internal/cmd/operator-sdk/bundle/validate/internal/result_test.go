@@ -15,6 +15,7 @@
 package internal
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -23,6 +24,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	apierrors "github.com/operator-framework/api/pkg/validation/errors"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -227,6 +229,74 @@ var _ = Describe("Output Result", func() {
 		})
 	})
 
+	Describe("Test AddValidatorResults()", func() {
+		It("should prefix messages of optional validators with their name", func() {
+			result.AddValidatorResults("community", apierrors.ManifestResult{
+				Name:     "memcached-operator.v0.0.1",
+				Warnings: []apierrors.Error{apierrors.WarnInvalidCSV("spec.minKubeVersion should be set", "")},
+			})
+
+			Expect(result.Passed).To(BeTrue())
+			Expect(result.Outputs).To(HaveLen(1))
+			Expect(result.Outputs[0].Type).To(Equal(log.WarnLevel.String()))
+			Expect(result.Outputs[0].Message).To(HavePrefix("[community] "))
+			Expect(result.Outputs[0].validator).To(Equal("community"))
+			Expect(result.Outputs[0].object).To(Equal("memcached-operator.v0.0.1"))
+			Expect(result.Outputs[0].detail).To(Equal("spec.minKubeVersion should be set"))
+		})
+
+		It("should flag passed with false for errors", func() {
+			result.AddManifestResults(apierrors.ManifestResult{
+				Errors: []apierrors.Error{apierrors.ErrInvalidCSV("spec.version must be set", "memcached-operator.v0.0.1")},
+			})
+
+			Expect(result.Passed).To(BeFalse())
+			Expect(result.Outputs).To(HaveLen(1))
+			Expect(result.Outputs[0].Message).NotTo(HavePrefix("["))
+			Expect(result.Outputs[0].object).To(Equal("memcached-operator.v0.0.1"))
+		})
+	})
+
+	Describe("Test WriteReport()", func() {
+		It("should write an empty list of findings for a passing result", func() {
+			buf := &bytes.Buffer{}
+			Expect(result.WriteReport(buf)).To(Succeed())
+
+			report := Report{}
+			Expect(json.Unmarshal(buf.Bytes(), &report)).To(Succeed())
+			Expect(report.Findings).NotTo(BeNil())
+			Expect(report.Findings).To(BeEmpty())
+			Expect(report.Summary).To(Equal(Summary{Passed: true}))
+			Expect(buf.String()).To(ContainSubstring(`"findings": []`))
+		})
+
+		It("should write findings of each severity and count them", func() {
+			result.AddInfo("example of an info")
+			result.AddError(errors.New("example of an error"))
+			result.AddValidatorResults("community", apierrors.ManifestResult{
+				Name:     "memcached-operator.v0.0.1",
+				Warnings: []apierrors.Error{apierrors.WarnInvalidCSV("spec.minKubeVersion should be set", "")},
+			})
+
+			buf := &bytes.Buffer{}
+			Expect(result.WriteReport(buf)).To(Succeed())
+
+			report := Report{}
+			Expect(json.Unmarshal(buf.Bytes(), &report)).To(Succeed())
+			Expect(report.Findings).To(Equal([]Finding{
+				{Validator: RequiredValidatorName, Severity: "info", Message: "example of an info"},
+				{Validator: RequiredValidatorName, Severity: "error", Message: "example of an error"},
+				{
+					Validator: "community",
+					Severity:  "warning",
+					Message:   "spec.minKubeVersion should be set",
+					Object:    "memcached-operator.v0.0.1",
+				},
+			}))
+			Expect(report.Summary).To(Equal(Summary{Errors: 1, Warnings: 1, Infos: 1, Passed: false}))
+		})
+	})
+
 	Describe("Test PrintWithFormat()", func() {
 		It("should print a JSON", func() {
 			By("passing the format`json-alpha1`")
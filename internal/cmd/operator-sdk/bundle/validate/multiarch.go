@@ -0,0 +1,185 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	apierrors "github.com/operator-framework/api/pkg/validation/errors"
+	interfaces "github.com/operator-framework/api/pkg/validation/interfaces"
+	corev1 "k8s.io/api/core/v1"
+
+	internalregistry "github.com/operator-framework/operator-sdk/internal/registry"
+)
+
+// Prefixes of CSV label keys declaring supported architectures and operating systems,
+// ex. "operatorframework.io/arch.arm64: supported".
+const (
+	archLabelPrefix = "operatorframework.io/arch."
+	osLabelPrefix   = "operatorframework.io/os."
+	// supportedLabelValue is the only valid value of arch and os labels.
+	supportedLabelValue = "supported"
+)
+
+// OLM considers bundles without arch or os labels to support only these.
+const (
+	defaultArch = "amd64"
+	defaultOS   = "linux"
+)
+
+// knownArchs and knownOSes are the architecture and operating system names images can be built for.
+var (
+	knownArchs = map[string]bool{
+		"386": true, "amd64": true, "arm": true, "arm64": true, "mips": true, "mipsle": true, "mips64": true,
+		"mips64le": true, "ppc64": true, "ppc64le": true, "riscv64": true, "s390x": true,
+	}
+	knownOSes = map[string]bool{
+		"linux": true, "windows": true,
+	}
+)
+
+// multiArchOptions configure multiArchValidator, which reads them from the objects it validates.
+type multiArchOptions struct {
+	// resolver, if set, is used to verify that each Deployment container image
+	// supports all declared architectures.
+	resolver internalregistry.PlatformResolver
+}
+
+// multiArchValidator checks that a bundle's CSV arch and os labels are valid,
+// and optionally that its images support the declared platforms.
+var multiArchValidator interfaces.Validator = interfaces.ValidatorFunc(validateMultiArchBundles)
+
+func validateMultiArchBundles(objs ...interface{}) (results []apierrors.ManifestResult) {
+	opts := multiArchOptions{}
+	for _, obj := range objs {
+		if o, ok := obj.(multiArchOptions); ok {
+			opts = o
+		}
+	}
+	for _, obj := range objs {
+		if bundle, ok := obj.(*apimanifests.Bundle); ok {
+			results = append(results, validateMultiArch(context.TODO(), bundle, opts))
+		}
+	}
+	return results
+}
+
+func validateMultiArch(ctx context.Context, bundle *apimanifests.Bundle, opts multiArchOptions) apierrors.ManifestResult {
+	result := apierrors.ManifestResult{Name: bundle.Name}
+	if bundle.CSV == nil {
+		return result
+	}
+
+	archs, oses := []string{}, []string{}
+	for _, key := range sortedKeys(bundle.CSV.GetLabels()) {
+		value := bundle.CSV.GetLabels()[key]
+		var name string
+		var known map[string]bool
+		switch {
+		case strings.HasPrefix(key, archLabelPrefix):
+			name, known = strings.TrimPrefix(key, archLabelPrefix), knownArchs
+		case strings.HasPrefix(key, osLabelPrefix):
+			name, known = strings.TrimPrefix(key, osLabelPrefix), knownOSes
+		default:
+			continue
+		}
+		if value != supportedLabelValue {
+			result.Add(newCSVError(apierrors.LevelError, key,
+				fmt.Sprintf("label %s has value %q, must be %q", key, value, supportedLabelValue)))
+			continue
+		}
+		if !known[name] {
+			result.Add(newCSVError(apierrors.LevelError, key,
+				fmt.Sprintf("label %s refers to unknown platform %q", key, name)))
+			continue
+		}
+		if strings.HasPrefix(key, archLabelPrefix) {
+			archs = append(archs, name)
+		} else {
+			oses = append(oses, name)
+		}
+	}
+	if len(archs) == 0 {
+		result.Add(newCSVError(apierrors.LevelWarn, bundle.CSV.GetName(),
+			fmt.Sprintf("CSV has no %s* labels, so it is only supported on %s; "+
+				"add a label per architecture its images support", archLabelPrefix, defaultArch)))
+		archs = []string{defaultArch}
+	}
+	if len(oses) == 0 {
+		oses = []string{defaultOS}
+	}
+	if opts.resolver == nil {
+		return result
+	}
+
+	// Resolve each image only once, since many may be the same.
+	resolved := map[string]bool{}
+	for _, ref := range getDeploymentImageRefs(bundle.CSV) {
+		if resolved[ref.image] {
+			continue
+		}
+		resolved[ref.image] = true
+		platforms, err := opts.resolver.ResolvePlatforms(ctx, ref.image)
+		if err != nil {
+			result.Add(newCSVError(apierrors.LevelError, ref.image,
+				fmt.Sprintf("%s %q platforms cannot be resolved: %v", ref.field, ref.image, err)))
+			continue
+		}
+		has := map[internalregistry.Platform]bool{}
+		for _, p := range platforms {
+			has[p] = true
+		}
+		for _, goos := range oses {
+			for _, arch := range archs {
+				if p := (internalregistry.Platform{OS: goos, Architecture: arch}); !has[p] {
+					result.Add(newCSVError(apierrors.LevelError, ref.image,
+						fmt.Sprintf("%s %q does not contain an image for architecture %s (platform %s)",
+							ref.field, ref.image, arch, p)))
+				}
+			}
+		}
+	}
+	return result
+}
+
+// getDeploymentImageRefs returns image references in csv's install strategy Deployment containers,
+// which are the images run on a cluster's nodes.
+func getDeploymentImageRefs(csv *v1alpha1.ClusterServiceVersion) (refs []imageRef) {
+	addContainers := func(depName string, containers []corev1.Container) {
+		for _, c := range containers {
+			field := fmt.Sprintf("deployment %q container %q image", depName, c.Name)
+			refs = append(refs, imageRef{image: c.Image, field: field})
+		}
+	}
+	for _, dep := range csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs {
+		addContainers(dep.Name, dep.Spec.Template.Spec.InitContainers)
+		addContainers(dep.Name, dep.Spec.Template.Spec.Containers)
+	}
+	return refs
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]string) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,196 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	apierrors "github.com/operator-framework/api/pkg/validation/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	internalregistry "github.com/operator-framework/operator-sdk/internal/registry"
+)
+
+// fakePlatformResolver resolves images to platforms by name, and fails for unknown images.
+type fakePlatformResolver map[string][]internalregistry.Platform
+
+func (r fakePlatformResolver) ResolvePlatforms(_ context.Context, image string) ([]internalregistry.Platform, error) {
+	platforms, ok := r[image]
+	if !ok {
+		return nil, errors.New("manifest unknown")
+	}
+	return platforms, nil
+}
+
+var _ = Describe("Running the multiarch validator", func() {
+	const (
+		operatorImage = "quay.io/example/memcached-operator:v0.0.1"
+		proxyImage    = "gcr.io/kubebuilder/kube-rbac-proxy:v0.5.0"
+	)
+
+	var (
+		ctx    = context.TODO()
+		bundle *apimanifests.Bundle
+		csv    *v1alpha1.ClusterServiceVersion
+	)
+
+	// newBundle returns a bundle whose CSV has labels, and a Deployment running operatorImage and proxyImage.
+	newBundle := func(labels map[string]string) *apimanifests.Bundle {
+		csv = &v1alpha1.ClusterServiceVersion{}
+		csv.SetName("memcached-operator.v0.0.1")
+		csv.SetLabels(labels)
+		csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs = []v1alpha1.StrategyDeploymentSpec{{Name: "memcached-operator"}}
+		csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs[0].Spec.Template.Spec.Containers = []corev1.Container{
+			{Name: "kube-rbac-proxy", Image: proxyImage},
+			{Name: "manager", Image: operatorImage},
+		}
+		return &apimanifests.Bundle{Name: "memcached-operator.v0.0.1", CSV: csv}
+	}
+
+	// details returns the details of all errors and warnings in result.
+	details := func(result apierrors.ManifestResult) (ds []string) {
+		for _, e := range append(result.Errors, result.Warnings...) {
+			ds = append(ds, e.Detail)
+		}
+		return ds
+	}
+
+	linux := func(archs ...string) (platforms []internalregistry.Platform) {
+		for _, arch := range archs {
+			platforms = append(platforms, internalregistry.Platform{OS: "linux", Architecture: arch})
+		}
+		return platforms
+	}
+
+	It("reports nothing for valid arch and os labels", func() {
+		bundle = newBundle(map[string]string{
+			"operatorframework.io/arch.amd64": "supported",
+			"operatorframework.io/arch.arm64": "supported",
+			"operatorframework.io/os.linux":   "supported",
+		})
+		Expect(details(validateMultiArch(ctx, bundle, multiArchOptions{}))).To(BeEmpty())
+	})
+
+	It("warns if there are no arch labels", func() {
+		bundle = newBundle(map[string]string{"operatorframework.io/os.linux": "supported"})
+		result := validateMultiArch(ctx, bundle, multiArchOptions{})
+		Expect(result.Errors).To(BeEmpty())
+		Expect(details(result)).To(ConsistOf(
+			HavePrefix("CSV has no operatorframework.io/arch.* labels, so it is only supported on amd64"),
+		))
+	})
+
+	It("reports invalid label values and unknown platforms as errors", func() {
+		bundle = newBundle(map[string]string{
+			"operatorframework.io/arch.amd64":  "true",
+			"operatorframework.io/arch.x86_64": "supported",
+			"operatorframework.io/os.macos":    "supported",
+			"operatorframework.io/arch.arm64":  "supported",
+		})
+		result := validateMultiArch(ctx, bundle, multiArchOptions{})
+		Expect(result.Warnings).To(BeEmpty())
+		Expect(details(result)).To(ConsistOf(
+			`label operatorframework.io/arch.amd64 has value "true", must be "supported"`,
+			`label operatorframework.io/arch.x86_64 refers to unknown platform "x86_64"`,
+			`label operatorframework.io/os.macos refers to unknown platform "macos"`,
+		))
+	})
+
+	Context("with a platform resolver", func() {
+		BeforeEach(func() {
+			bundle = newBundle(map[string]string{
+				"operatorframework.io/arch.amd64":   "supported",
+				"operatorframework.io/arch.arm64":   "supported",
+				"operatorframework.io/arch.ppc64le": "supported",
+			})
+		})
+
+		It("reports nothing if all images support all declared architectures", func() {
+			resolver := fakePlatformResolver{
+				operatorImage: linux("amd64", "arm64", "ppc64le", "s390x"),
+				proxyImage:    linux("ppc64le", "arm64", "amd64"),
+			}
+			Expect(details(validateMultiArch(ctx, bundle, multiArchOptions{resolver: resolver}))).To(BeEmpty())
+		})
+
+		It("reports each architecture missing from each image as an error", func() {
+			resolver := fakePlatformResolver{
+				operatorImage: linux("amd64"),
+				proxyImage:    linux("amd64", "arm64"),
+			}
+			result := validateMultiArch(ctx, bundle, multiArchOptions{resolver: resolver})
+			Expect(result.Warnings).To(BeEmpty())
+			Expect(details(result)).To(ConsistOf(
+				`deployment "memcached-operator" container "manager" image "quay.io/example/memcached-operator:v0.0.1" `+
+					`does not contain an image for architecture arm64 (platform linux/arm64)`,
+				`deployment "memcached-operator" container "manager" image "quay.io/example/memcached-operator:v0.0.1" `+
+					`does not contain an image for architecture ppc64le (platform linux/ppc64le)`,
+				`deployment "memcached-operator" container "kube-rbac-proxy" image "gcr.io/kubebuilder/kube-rbac-proxy:v0.5.0" `+
+					`does not contain an image for architecture ppc64le (platform linux/ppc64le)`,
+			))
+		})
+
+		It("checks declared operating systems", func() {
+			bundle.CSV.Labels["operatorframework.io/os.windows"] = "supported"
+			delete(bundle.CSV.Labels, "operatorframework.io/arch.arm64")
+			delete(bundle.CSV.Labels, "operatorframework.io/arch.ppc64le")
+			resolver := fakePlatformResolver{
+				operatorImage: linux("amd64"),
+				proxyImage:    append(linux("amd64"), internalregistry.Platform{OS: "windows", Architecture: "amd64"}),
+			}
+			Expect(details(validateMultiArch(ctx, bundle, multiArchOptions{resolver: resolver}))).To(ConsistOf(
+				`deployment "memcached-operator" container "manager" image "quay.io/example/memcached-operator:v0.0.1" ` +
+					`does not contain an image for architecture amd64 (platform windows/amd64)`,
+			))
+		})
+
+		It("reports images whose platforms cannot be resolved as errors", func() {
+			resolver := fakePlatformResolver{operatorImage: linux("amd64", "arm64", "ppc64le")}
+			Expect(details(validateMultiArch(ctx, bundle, multiArchOptions{resolver: resolver}))).To(ConsistOf(
+				`deployment "memcached-operator" container "kube-rbac-proxy" image "gcr.io/kubebuilder/kube-rbac-proxy:v0.5.0" ` +
+					`platforms cannot be resolved: manifest unknown`,
+			))
+		})
+
+		It("resolves each image once", func() {
+			calls := map[string]int{}
+			resolver := countingPlatformResolver{fakePlatformResolver{
+				operatorImage: linux("amd64", "arm64", "ppc64le"),
+				proxyImage:    linux("amd64", "arm64", "ppc64le"),
+			}, calls}
+			csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs[0].Spec.Template.Spec.InitContainers = []corev1.Container{
+				{Name: "init", Image: operatorImage},
+			}
+			Expect(details(validateMultiArch(ctx, bundle, multiArchOptions{resolver: resolver}))).To(BeEmpty())
+			Expect(calls).To(Equal(map[string]int{operatorImage: 1, proxyImage: 1}))
+		})
+	})
+})
+
+// countingPlatformResolver counts the number of times each image is resolved.
+type countingPlatformResolver struct {
+	internalregistry.PlatformResolver
+	calls map[string]int
+}
+
+func (r countingPlatformResolver) ResolvePlatforms(ctx context.Context, image string) ([]internalregistry.Platform, error) {
+	r.calls[image]++
+	return r.PlatformResolver.ResolvePlatforms(ctx, image)
+}
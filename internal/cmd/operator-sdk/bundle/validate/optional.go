@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"sort"
 	"text/tabwriter"
 
 	apimanifests "github.com/operator-framework/api/pkg/manifests"
@@ -33,7 +34,7 @@ const (
 	suiteKey = "suite"
 )
 
-// optionalValidators is a list of validators with 0their name, labels for CLI usage, and a light description.
+// optionalValidators is a list of validators with their name, labels for CLI usage, and a light description.
 var optionalValidators = validators{
 	{
 		Validator: apivalidation.OperatorHubValidator,
@@ -42,13 +43,60 @@ var optionalValidators = validators{
 			nameKey:  "operatorhub",
 			suiteKey: "operatorframework",
 		},
-		desc: "OperatorHub.io metadata validation",
+		desc: "OperatorHub.io metadata validation: provider, maintainers, links, icon, categories, and capabilities",
+	},
+	{
+		Validator: communityValidator,
+		name:      "community",
+		labels: map[string]string{
+			nameKey:  "community",
+			suiteKey: "operatorframework",
+		},
+		desc: "Community catalog submission requirements: containerImage and repository annotations, " +
+			"spec.version, and spec.minKubeVersion",
+	},
+	{
+		Validator: goodPracticesValidator,
+		name:      "good-practices",
+		labels: map[string]string{
+			nameKey:  "good-practices",
+			suiteKey: "operatorframework",
+		},
+		desc: "Recommended practices: container resource requests, least-privilege RBAC, " +
+			"and apiextensions.k8s.io/v1 CRDs",
+	},
+	{
+		Validator: imageRefsValidator,
+		name:      imageRefsValidatorName,
+		labels: map[string]string{
+			nameKey:  imageRefsValidatorName,
+			suiteKey: "operatorframework",
+		},
+		desc: "Image references in the CSV are valid, and with --verify-images exist in their registries, " +
+			"and with --require-digests are pinned to digests",
+	},
+	{
+		Validator: multiArchValidator,
+		name:      multiArchValidatorName,
+		labels: map[string]string{
+			nameKey:  multiArchValidatorName,
+			suiteKey: "operatorframework",
+		},
+		desc: "CSV operatorframework.io/arch.* and os.* labels are valid, and with --verify-images " +
+			"Deployment images support each declared platform",
 	},
 }
 
-// runOptionalValidators runs optional validators selected by sel on bundle.
-func runOptionalValidators(bundle *apimanifests.Bundle, sel labels.Selector) []apierrors.ManifestResult {
-	return optionalValidators.run(bundle, sel)
+// Names of optional validators configured by image verification flags.
+const (
+	imageRefsValidatorName = "image-refs"
+	multiArchValidatorName = "multiarch"
+)
+
+// runOptionalValidators runs optional validators selected by any of sels on bundle. opts are passed
+// to validators along with bundle objects, ex. imageRefsOptions and multiArchOptions.
+func runOptionalValidators(bundle *apimanifests.Bundle, opts []interface{}, sels ...labels.Selector) []validatorResult {
+	return optionalValidators.runWithOptions(bundle, opts, sels...)
 }
 
 // listOptionalValidators lists all optional validators.
@@ -67,6 +115,12 @@ type validator struct {
 
 type validators []validator
 
+// validatorResult contains the results of the validator with name.
+type validatorResult struct {
+	name    string
+	results []apierrors.ManifestResult
+}
+
 func (vals validators) String() string {
 	out := &bytes.Buffer{}
 	tw := tabwriter.NewWriter(out, 8, 4, 4, ' ', 0)
@@ -76,6 +130,7 @@ func (vals validators) String() string {
 		for k, v := range val.labels {
 			labelStrs = append(labelStrs, fmt.Sprintf("%s=%s", k, v))
 		}
+		sort.Strings(labelStrs)
 		if len(labelStrs) != 0 {
 			fmt.Fprintf(tw, "%s\t%s\t%s\n", val.name, labelStrs[0], val.desc)
 		}
@@ -89,6 +144,16 @@ func (vals validators) String() string {
 	return out.String()
 }
 
+// withName returns validators with name.
+func (vals validators) withName(name string) (named validators) {
+	for _, v := range vals {
+		if v.name == name {
+			named = append(named, v)
+		}
+	}
+	return named
+}
+
 // checkMatches returns an error if sel does not match any validators. This method helps the CLI
 // to fail early in case of erroneous input.
 func (vals validators) checkMatches(sel labels.Selector) error {
@@ -100,10 +165,30 @@ func (vals validators) checkMatches(sel labels.Selector) error {
 	return fmt.Errorf("selector %q does not match any validator labels", sel.String())
 }
 
-// run runs optional validators selected by sel on bundle.
-func (vals validators) run(bundle *apimanifests.Bundle, sel labels.Selector) (results []apierrors.ManifestResult) {
-	// No selector set, do not run any optional validators.
-	if sel == nil || sel.String() == "" {
+// selected returns validators selected by any of sels.
+func (vals validators) selected(sels ...labels.Selector) (selected validators) {
+	for _, v := range vals {
+		for _, sel := range sels {
+			// Empty selectors do not select any optional validators.
+			if sel != nil && sel.String() != "" && sel.Matches(labels.Set(v.labels)) {
+				selected = append(selected, v)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// run runs optional validators selected by any of sels on bundle, and returns the results of each.
+func (vals validators) run(bundle *apimanifests.Bundle, sels ...labels.Selector) []validatorResult {
+	return vals.runWithOptions(bundle, nil, sels...)
+}
+
+// runWithOptions is like run, but also passes opts to validators.
+func (vals validators) runWithOptions(bundle *apimanifests.Bundle, opts []interface{},
+	sels ...labels.Selector) (results []validatorResult) {
+	selected := vals.selected(sels...)
+	if len(selected) == 0 {
 		return results
 	}
 
@@ -115,11 +200,10 @@ func (vals validators) run(bundle *apimanifests.Bundle, sel labels.Selector) (re
 	for _, obj := range bundle.Objects {
 		objs = append(objs, obj)
 	}
+	objs = append(objs, opts...)
 
-	for _, v := range vals {
-		if sel.Matches(labels.Set(v.labels)) {
-			results = append(results, v.Validate(objs...)...)
-		}
+	for _, v := range selected {
+		results = append(results, validatorResult{name: v.name, results: v.Validate(objs...)})
 	}
 
 	return results
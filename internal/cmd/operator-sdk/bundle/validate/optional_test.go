@@ -15,12 +15,18 @@
 package validate
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	apimanifests "github.com/operator-framework/api/pkg/manifests"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
-	apierrors "github.com/operator-framework/api/pkg/validation/errors"
 	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/bundle/validate/internal"
 )
 
 var _ = Describe("Running optional validators", func() {
@@ -35,7 +41,7 @@ var _ = Describe("Running optional validators", func() {
 	Describe("run", func() {
 		var (
 			bundle  *apimanifests.Bundle
-			results []apierrors.ManifestResult
+			results []validatorResult
 			sel     labels.Selector
 		)
 
@@ -55,7 +61,9 @@ var _ = Describe("Running optional validators", func() {
 			})
 			results = vals.run(bundle, sel)
 			Expect(results).To(HaveLen(1))
-			Expect(results[0].Errors).To(HaveLen(1))
+			Expect(results[0].name).To(Equal("operatorhub"))
+			Expect(results[0].results).To(HaveLen(1))
+			Expect(results[0].results[0].Errors).To(HaveLen(1))
 		})
 		It("runs a validator for one selector on a bundle", func() {
 			bundle = &apimanifests.Bundle{}
@@ -65,9 +73,10 @@ var _ = Describe("Running optional validators", func() {
 			})
 			results = vals.run(bundle, sel)
 			Expect(results).To(HaveLen(1))
+			Expect(results[0].results).To(HaveLen(1))
 			// Only test that more than one error was returned than the empty bundle case, which
 			// indicates validation happening.
-			Expect(len(results[0].Errors)).To(BeNumerically(">", 1))
+			Expect(len(results[0].results[0].Errors)).To(BeNumerically(">", 1))
 		})
 	})
 
@@ -99,4 +108,106 @@ var _ = Describe("Running optional validators", func() {
 		})
 	})
 
+	Describe("suites on a bundle with known issues", func() {
+		var bundle *apimanifests.Bundle
+
+		BeforeEach(func() {
+			var err error
+			bundle, err = apimanifests.GetBundleFromDir(filepath.Join("testdata", "bad-bundle", "manifests"))
+			Expect(err).NotTo(HaveOccurred())
+			vals = optionalValidators
+		})
+
+		selectName := func(name string) labels.Selector {
+			return labels.SelectorFromSet(map[string]string{nameKey: name})
+		}
+
+		It("reports no findings if no suite is selected", func() {
+			Expect(vals.run(bundle)).To(BeEmpty())
+		})
+		It("reports operatorhub findings only if selected", func() {
+			findings := getFindings(vals.run(bundle, selectName("operatorhub")))
+			Expect(findings).NotTo(BeEmpty())
+			for _, f := range findings {
+				Expect(f).To(HavePrefix("[operatorhub] "))
+			}
+		})
+		It("reports community findings only if selected", func() {
+			findings := getFindings(vals.run(bundle, selectName("community")))
+			Expect(findings).To(ConsistOf(
+				"[community] metadata.annotations.containerImage must be set to the operator's image",
+				"[community] metadata.annotations.repository should be set to the operator's source repository URL",
+				"[community] spec.minKubeVersion should be set so the operator is not installed on unsupported clusters",
+			))
+		})
+		It("reports good-practices findings only if selected", func() {
+			findings := getFindings(vals.run(bundle, selectName("good-practices")))
+			Expect(findings).To(ConsistOf(
+				ContainSubstring(`[good-practices] CustomResourceDefinition memcacheds.cache.example.com uses apiextensions.k8s.io/v1beta1`),
+				ContainSubstring(`[good-practices] container "manager" of deployment "memcached-operator-controller-manager" does not set resources.requests`),
+				ContainSubstring(`[good-practices] spec.install.spec.clusterPermissions of service account "default" grant all verbs on all resources`),
+			))
+		})
+		It("reports multiarch findings only if selected", func() {
+			findings := getFindings(vals.run(bundle, selectName(multiArchValidatorName)))
+			Expect(findings).To(ConsistOf(
+				HavePrefix("[multiarch] CSV has no operatorframework.io/arch.* labels, so it is only supported on amd64"),
+			))
+		})
+		It("reports findings of all suites selected by any selector", func() {
+			findings := getFindings(vals.run(bundle, selectName("community"), selectName("good-practices")))
+			Expect(findings).To(ContainElement(HavePrefix("[community] ")))
+			Expect(findings).To(ContainElement(HavePrefix("[good-practices] ")))
+			Expect(findings).NotTo(ContainElement(HavePrefix("[operatorhub] ")))
+
+			findings = getFindings(vals.run(bundle, labels.SelectorFromSet(map[string]string{suiteKey: "operatorframework"})))
+			Expect(findings).To(ContainElement(HavePrefix("[operatorhub] ")))
+			Expect(findings).To(ContainElement(HavePrefix("[community] ")))
+			Expect(findings).To(ContainElement(HavePrefix("[good-practices] ")))
+		})
+		It("does not report findings for a bundle following all practices", func() {
+			csv := bundle.CSV
+			csv.Annotations["containerImage"] = "quay.io/example/memcached-operator:v0.0.1"
+			csv.Annotations["repository"] = "https://github.com/example/memcached-operator"
+			csv.Spec.MinKubeVersion = "1.16.0"
+			Expect(getFindings(vals.run(bundle, selectName("community")))).To(BeEmpty())
+		})
+		It("writes findings in the json format matching the golden report", func() {
+			res := internal.NewResult()
+			for _, vr := range vals.run(bundle, selectName("community"), selectName("good-practices")) {
+				res.AddValidatorResults(vr.name, vr.results...)
+			}
+			Expect(res.Passed).To(BeFalse())
+
+			buf := &bytes.Buffer{}
+			Expect(res.WriteReport(buf)).To(Succeed())
+			golden, err := ioutil.ReadFile(filepath.Join("testdata", "bad-bundle-report.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(Equal(string(golden)))
+		})
+	})
+
+	Describe("String", func() {
+		It("lists each validator's name, labels, and description", func() {
+			out := optionalValidators.String()
+			for _, v := range optionalValidators {
+				Expect(out).To(ContainSubstring(v.name))
+				Expect(out).To(ContainSubstring(v.desc))
+			}
+			Expect(out).To(ContainSubstring("name=good-practices"))
+		})
+	})
+
 })
+
+// getFindings returns the details of all errors and warnings in results, prefixed with validator name.
+func getFindings(results []validatorResult) (findings []string) {
+	for _, vr := range results {
+		for _, result := range vr.results {
+			for _, e := range append(result.Errors, result.Warnings...) {
+				findings = append(findings, fmt.Sprintf("[%s] %s", vr.name, e.Detail))
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	apierrors "github.com/operator-framework/api/pkg/validation/errors"
+	interfaces "github.com/operator-framework/api/pkg/validation/interfaces"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// CSV annotations required or recommended for community catalog submissions.
+const (
+	containerImageAnnotation = "containerImage"
+	repositoryAnnotation     = "repository"
+)
+
+// communityValidator checks that a bundle contains metadata required to submit it to community catalogs.
+var communityValidator interfaces.Validator = interfaces.ValidatorFunc(validateCommunityBundles)
+
+func validateCommunityBundles(objs ...interface{}) (results []apierrors.ManifestResult) {
+	for _, obj := range objs {
+		if bundle, ok := obj.(*apimanifests.Bundle); ok {
+			results = append(results, validateCommunityBundle(bundle))
+		}
+	}
+	return results
+}
+
+func validateCommunityBundle(bundle *apimanifests.Bundle) apierrors.ManifestResult {
+	result := apierrors.ManifestResult{Name: bundle.Name}
+	csv := bundle.CSV
+	if csv == nil {
+		result.Add(newCSVError(apierrors.LevelError, bundle.Name, "bundle does not contain a ClusterServiceVersion"))
+		return result
+	}
+
+	annotations := csv.GetAnnotations()
+	if annotations[containerImageAnnotation] == "" {
+		result.Add(newCSVError(apierrors.LevelError, csv.GetName(),
+			fmt.Sprintf("metadata.annotations.%s must be set to the operator's image", containerImageAnnotation)))
+	}
+	if annotations[repositoryAnnotation] == "" {
+		result.Add(newCSVError(apierrors.LevelWarn, csv.GetName(),
+			fmt.Sprintf("metadata.annotations.%s should be set to the operator's source repository URL", repositoryAnnotation)))
+	}
+	if csv.Spec.MinKubeVersion == "" {
+		result.Add(newCSVError(apierrors.LevelWarn, csv.GetName(),
+			"spec.minKubeVersion should be set so the operator is not installed on unsupported clusters"))
+	}
+	if csv.Spec.Version.String() == "0.0.0" {
+		result.Add(newCSVError(apierrors.LevelError, csv.GetName(), "spec.version must be set"))
+	}
+	return result
+}
+
+// goodPracticesValidator checks that a bundle's operator follows recommended practices for running on a cluster.
+var goodPracticesValidator interfaces.Validator = interfaces.ValidatorFunc(validateGoodPracticesBundles)
+
+func validateGoodPracticesBundles(objs ...interface{}) (results []apierrors.ManifestResult) {
+	for _, obj := range objs {
+		if bundle, ok := obj.(*apimanifests.Bundle); ok {
+			results = append(results, validateGoodPracticesBundle(bundle))
+		}
+	}
+	return results
+}
+
+func validateGoodPracticesBundle(bundle *apimanifests.Bundle) apierrors.ManifestResult {
+	result := apierrors.ManifestResult{Name: bundle.Name}
+	for _, crd := range bundle.V1beta1CRDs {
+		result.Add(newCSVError(apierrors.LevelWarn, crd.GetName(), fmt.Sprintf("CustomResourceDefinition %s "+
+			"uses apiextensions.k8s.io/v1beta1, which is not served by Kubernetes 1.22+; migrate it to v1", crd.GetName())))
+	}
+	csv := bundle.CSV
+	if csv == nil {
+		return result
+	}
+
+	for _, dep := range csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs {
+		for _, c := range dep.Spec.Template.Spec.Containers {
+			if len(c.Resources.Requests) == 0 {
+				result.Add(newCSVError(apierrors.LevelWarn, csv.GetName(), fmt.Sprintf("container %q of deployment %q "+
+					"does not set resources.requests, so it cannot be scheduled reliably", c.Name, dep.Name)))
+			}
+		}
+	}
+	checkPermissions := func(field string, perms []v1alpha1.StrategyDeploymentPermissions) {
+		for _, perm := range perms {
+			for _, rule := range perm.Rules {
+				if hasWildcard(rule.Verbs) && hasWildcard(rule.Resources) {
+					result.Add(newCSVError(apierrors.LevelWarn, csv.GetName(), fmt.Sprintf("%s of service account %q "+
+						"grant all verbs on all resources; grant only the permissions the operator needs", field, perm.ServiceAccountName)))
+				}
+			}
+		}
+	}
+	checkPermissions("spec.install.spec.permissions", csv.Spec.InstallStrategy.StrategySpec.Permissions)
+	checkPermissions("spec.install.spec.clusterPermissions", csv.Spec.InstallStrategy.StrategySpec.ClusterPermissions)
+	return result
+}
+
+// hasWildcard returns true if values contains rbacv1.VerbAll, which is also the wildcard for resources.
+func hasWildcard(values []string) bool {
+	for _, value := range values {
+		if value == rbacv1.VerbAll {
+			return true
+		}
+	}
+	return false
+}
+
+func newCSVError(level apierrors.Level, value, detail string) apierrors.Error {
+	return apierrors.Error{Type: apierrors.ErrorInvalidCSV, Level: level, BadValue: value, Detail: detail}
+}
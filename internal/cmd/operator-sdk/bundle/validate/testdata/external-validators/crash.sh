@@ -0,0 +1,3 @@
+#!/bin/sh
+echo "unexpected failure" >&2
+exit 3
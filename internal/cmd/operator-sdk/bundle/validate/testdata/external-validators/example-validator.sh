@@ -0,0 +1,45 @@
+#!/bin/sh
+#
+# Copyright 2020 The Operator-SDK Authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# An example external validator for 'operator-sdk bundle validate --alpha-select-external'.
+# It requires package names to end with "-operator", and recommends CSVs set an
+# "example.com/support" annotation.
+#
+# The bundle root is the first argument, and bundle metadata is written to stdin as JSON.
+
+set -eu
+
+bundle_root="$1"
+package=$(sed -n 's/.*"operators\.operatorframework\.io\.bundle\.package\.v1":"\([^"]*\)".*/\1/p')
+
+findings=""
+add_finding() {
+  [ -z "$findings" ] || findings="${findings},"
+  findings="${findings}{\"severity\":\"$1\",\"message\":\"$2\",\"object\":\"$3\"}"
+}
+
+case "$package" in
+  *-operator) ;;
+  *) add_finding error "package name must end with -operator" "$package" ;;
+esac
+
+for csv in "$bundle_root"/manifests/*.clusterserviceversion.yaml; do
+  if ! grep -q "example.com/support:" "$csv"; then
+    add_finding warning "metadata.annotations.example.com/support should be set to a support contact" "$(basename "$csv")"
+  fi
+done
+
+echo "{\"findings\":[${findings}]}"
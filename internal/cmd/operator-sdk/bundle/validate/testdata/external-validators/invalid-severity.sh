@@ -0,0 +1,2 @@
+#!/bin/sh
+echo '{"findings":[{"severity":"fatal","message":"unknown severity"}]}'
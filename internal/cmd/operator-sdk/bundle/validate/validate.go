@@ -15,18 +15,17 @@
 package validate
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 
-	apimanifests "github.com/operator-framework/api/pkg/manifests"
 	"github.com/operator-framework/operator-registry/pkg/containertools"
 	registryimage "github.com/operator-framework/operator-registry/pkg/image"
 	"github.com/operator-framework/operator-registry/pkg/image/containerdregistry"
 	"github.com/operator-framework/operator-registry/pkg/image/execregistry"
-	registrybundle "github.com/operator-framework/operator-registry/pkg/lib/bundle"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"k8s.io/apimachinery/pkg/labels"
@@ -36,12 +35,19 @@ import (
 )
 
 type bundleValidateCmd struct {
-	directory    string
-	imageBuilder string
-	outputFormat string
-	selectorRaw  string
-	selector     labels.Selector
-	listOptional bool
+	directory      string
+	imageBuilder   string
+	pullSecret     string
+	skipTLSVerify  bool
+	useHTTP        bool
+	verifyImages   bool
+	requireDigests bool
+	outputFormat   string
+	k8sVersion     string
+	selectorsRaw   []string
+	selectors      []labels.Selector
+	listOptional   bool
+	externals      []string
 }
 
 // validate verifies the command args
@@ -53,13 +59,49 @@ func (c bundleValidateCmd) validate(args []string) error {
 	if len(args) != 1 {
 		return errors.New("an image tag or directory is a required argument")
 	}
-	if c.outputFormat != internal.JSONAlpha1 && c.outputFormat != internal.Text {
+	switch c.outputFormat {
+	case internal.Text, internal.JSON, internal.JSONAlpha1:
+	default:
 		return fmt.Errorf("invalid value for output flag: %v", c.outputFormat)
 	}
 
-	// Check optional selector.
-	if c.selectorRaw != "" {
-		if err := optionalValidators.checkMatches(c.selector); err != nil {
+	if c.k8sVersion != "" {
+		if _, err := internalregistry.ParseKubeVersion(c.k8sVersion); err != nil {
+			return err
+		}
+	}
+
+	// Container tools pull images with their own configuration, so pull flags only configure
+	// registries queried without one.
+	if c.imageBuilder != containertools.NoneTool.String() && !c.verifyImages {
+		if c.pullSecret != "" {
+			return errors.New("--pull-secret can only be set with --image-builder=none or --verify-images")
+		}
+		if c.skipTLSVerify || c.useHTTP {
+			return errors.New("--skip-tls-verify and --use-http can only be set with --image-builder=none or --verify-images")
+		}
+	}
+
+	// Image verification flags only configure the image-refs and multiarch validators.
+	selectsImageRefs := len(optionalValidators.withName(imageRefsValidatorName).selected(c.selectors...)) != 0
+	selectsMultiArch := len(optionalValidators.withName(multiArchValidatorName).selected(c.selectors...)) != 0
+	if c.requireDigests && !selectsImageRefs {
+		return fmt.Errorf("--require-digests requires selecting the %s optional validator, "+
+			"ex. --select-optional name=%s", imageRefsValidatorName, imageRefsValidatorName)
+	}
+	if c.verifyImages && !selectsImageRefs && !selectsMultiArch {
+		return fmt.Errorf("--verify-images requires selecting the %s or %s optional validator, "+
+			"ex. --select-optional name=%s", imageRefsValidatorName, multiArchValidatorName, imageRefsValidatorName)
+	}
+
+	// Check optional selectors.
+	for _, sel := range c.selectors {
+		if err := optionalValidators.checkMatches(sel); err != nil {
+			return err
+		}
+	}
+	for _, path := range c.externals {
+		if err := checkExternalValidator(path); err != nil {
 			return err
 		}
 	}
@@ -70,86 +112,130 @@ func (c bundleValidateCmd) validate(args []string) error {
 // TODO: add a "permissive" flag to toggle whether warnings also cause a non-zero
 // exit code to be returned (true by default).
 func (c *bundleValidateCmd) addToFlagSet(fs *pflag.FlagSet) {
-	fs.StringVarP(&c.imageBuilder, "image-builder", "b", "docker",
+	fs.StringVarP(&c.imageBuilder, "image-builder", "b", containertools.NoneTool.String(),
 		"Tool to pull and unpack bundle images. Only used when validating a bundle image. "+
-			"One of: [docker, podman, none]")
-	fs.StringVar(&c.selectorRaw, "select-optional", "",
-		"Label selector to select optional validators to run. "+
+			"One of: [docker, podman, none]. If none, images are pulled without a container tool or daemon")
+	fs.StringVar(&c.pullSecret, "pull-secret", "",
+		"Path to a docker config file containing credentials to pull bundle images with, ex. the contents "+
+			"of a kubernetes.io/dockerconfigjson secret. Defaults to $HOME/.docker/config.json. "+
+			"Only used with --image-builder=none")
+	fs.BoolVar(&c.skipTLSVerify, "skip-tls-verify", false,
+		"Skip TLS certificate verification when pulling bundle images. Only used with --image-builder=none")
+	fs.BoolVar(&c.useHTTP, "use-http", false,
+		"Use plain HTTP when pulling bundle images. Only used with --image-builder=none")
+	fs.BoolVar(&c.verifyImages, "verify-images", false,
+		"Verify that images referenced by the CSV exist in their registries, and support the CSV's declared "+
+			"architectures, which requires network access. "+
+			"Registries are queried with --pull-secret, --skip-tls-verify, and --use-http. "+
+			"Requires selecting the image-refs or multiarch optional validator")
+	fs.BoolVar(&c.requireDigests, "require-digests", false,
+		"Warn about images referenced by the CSV by tag instead of digest. "+
+			"Requires selecting the image-refs optional validator")
+	fs.StringArrayVar(&c.selectorsRaw, "select-optional", nil,
+		"Label selector to select optional validators to run, ex. name=operatorhub. May be passed more than once "+
+			"to run validators selected by any selector. "+
 			"Run this command with '--list-optional' to list available optional validators")
 	fs.BoolVar(&c.listOptional, "list-optional", false,
 		"List all optional validators available. When set, no validators will be run")
+	fs.StringArrayVar(&c.externals, "alpha-select-external", nil,
+		"Path to an external validator binary to run. May be passed more than once. "+
+			"This flag is alpha, and the external validator interface may change")
 
+	fs.StringVar(&c.k8sVersion, "k8s-version", "",
+		"Target Kubernetes version, ex. 1.22. Objects of APIs removed in this version are reported as warnings, "+
+			"or errors if they are also removed in the CSV's spec.minKubeVersion. Defaults to spec.minKubeVersion")
 	fs.StringVarP(&c.outputFormat, "output", "o", internal.Text,
-		"Result format for results. One of: [text, json-alpha1]")
-	// It is hidden because it is an alpha option
-	// The idea is the next versions of Operator Registry will return a List of errors
-	if err := fs.MarkHidden("output"); err != nil {
-		panic(err)
-	}
+		"Result format for results. One of: [text, json]")
 }
 
 func (c bundleValidateCmd) run(logger *log.Entry, bundleRaw string) (res *internal.Result, err error) {
-	// Create a registry to validate bundle files and optionally unpack the image with.
-	reg, err := newImageRegistryForTool(logger, c.imageBuilder)
-	if err != nil {
-		return res, fmt.Errorf("error creating image registry: %v", err)
-	}
-	defer func() {
-		if err := reg.Destroy(); err != nil {
-			logger.Errorf("Error destroying image registry: %v", err)
-		}
-	}()
-
 	// If bundle isn't a directory, assume it's an image.
+	var imageLabels map[string]string
 	if isExist(bundleRaw) {
 		if c.directory, err = relWd(bundleRaw); err != nil {
 			return res, err
 		}
 	} else {
+		// Temp dirs are removed even if pulling or unpacking the image fails.
 		c.directory, err = ioutil.TempDir("", "bundle-")
 		if err != nil {
 			return res, err
 		}
 		defer func() {
-			if err = os.RemoveAll(c.directory); err != nil {
+			if err := os.RemoveAll(c.directory); err != nil {
 				logger.Errorf("Error removing temp bundle dir: %v", err)
 			}
 		}()
 
+		// Create a registry to pull and unpack the image with.
+		reg, cleanup, err := c.newImageRegistry(logger)
+		if err != nil {
+			return res, fmt.Errorf("error creating image registry: %v", err)
+		}
+		defer cleanup()
+
 		logger.Info("Unpacking image layers")
 
-		if err := c.unpackImageIntoDir(reg, bundleRaw, c.directory); err != nil {
+		if imageLabels, err = c.unpackImageIntoDir(reg, bundleRaw, c.directory); err != nil {
 			return res, fmt.Errorf("error unpacking image %s: %v", bundleRaw, err)
 		}
 	}
 
-	// Read the bundle object and metadata from the created/passed in directory.
-	bundle, mediaType, err := getBundleDataFromDir(c.directory)
-	if err != nil {
-		return res, err
-	}
-
-	// Create Result to be output.
-	res = internal.NewResult()
-
 	logger = logger.WithFields(log.Fields{
 		"bundle-dir":     c.directory,
 		"container-tool": c.imageBuilder,
 	})
-	val := registrybundle.NewImageValidator(reg, logger)
 
-	// Validate bundle format.
-	if err := val.ValidateBundleFormat(c.directory); err != nil {
-		res.AddError(fmt.Errorf("error validating format in %s: %v", c.directory, err))
+	// Read and validate the bundle's format, metadata, and content from the created/passed in directory.
+	bundle, results, err := internalregistry.ValidateBundleDir(logger, c.directory, c.k8sVersion)
+	if err != nil {
+		return res, err
 	}
 
-	// Validate bundle content.
-	results := internalregistry.ValidateBundleContent(logger, bundle, mediaType)
+	// Create Result to be output.
+	res = internal.NewResult()
 	res.AddManifestResults(results...)
 
+	// Check that an image's labels are consistent with its bundle's annotations.
+	if imageLabels != nil {
+		result, err := internalregistry.ValidateBundleImageLabels(bundleRaw, imageLabels, c.directory)
+		if err != nil {
+			return res, err
+		}
+		res.AddManifestResults(result)
+	}
+
 	// Run optional validators.
-	results = runOptionalValidators(bundle, c.selector)
-	res.AddManifestResults(results...)
+	imageRefsOpts := imageRefsOptions{requireDigests: c.requireDigests}
+	multiArchOpts := multiArchOptions{}
+	if c.verifyImages {
+		configDir, cleanup, err := c.newResolverConfigDir(logger)
+		if err != nil {
+			return res, fmt.Errorf("error creating image resolver: %v", err)
+		}
+		defer cleanup()
+		if imageRefsOpts.resolver, err = internalregistry.NewDigestResolver(configDir, c.skipTLSVerify, c.useHTTP); err != nil {
+			return res, err
+		}
+		if multiArchOpts.resolver, err = internalregistry.NewPlatformResolver(configDir, c.skipTLSVerify, c.useHTTP); err != nil {
+			return res, err
+		}
+	}
+	opts := []interface{}{imageRefsOpts, multiArchOpts}
+	for _, vr := range runOptionalValidators(bundle, opts, c.selectors...) {
+		res.AddValidatorResults(vr.name, vr.results...)
+	}
+
+	// Run external validators.
+	if len(c.externals) != 0 {
+		metadata, _, err := internalregistry.FindBundleMetadata(c.directory)
+		if err != nil {
+			return res, err
+		}
+		for _, path := range c.externals {
+			res.AddFindings(runExternalValidator(path, c.directory, metadata)...)
+		}
+	}
 
 	return res, nil
 }
@@ -159,60 +245,120 @@ func (c bundleValidateCmd) list() error {
 	return listOptionalValidators(os.Stdout)
 }
 
-// getBundleDataFromDir returns the bundle object and associated metadata from dir, if any.
-func getBundleDataFromDir(dir string) (*apimanifests.Bundle, string, error) {
-	// Gather bundle metadata.
-	metadata, _, err := internalregistry.FindBundleMetadata(dir)
+// newImageRegistry returns an image registry for c.imageBuilder, and a function that destroys
+// the registry and removes any files created for it.
+func (c bundleValidateCmd) newImageRegistry(logger *log.Entry) (registryimage.Registry, func(), error) {
+	var opts []containerdregistry.RegistryOption
+	var configDir string
+	cleanup := func() {
+		if configDir == "" {
+			return
+		}
+		if err := os.RemoveAll(configDir); err != nil {
+			logger.Errorf("Error removing temp docker config dir: %v", err)
+		}
+	}
+	if c.pullSecret != "" {
+		var err error
+		if configDir, err = newDockerConfigDir(c.pullSecret); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		opts = append(opts, containerdregistry.WithResolverConfigDir(configDir))
+	}
+	// The registry skips TLS verification and falls back to plain HTTP together.
+	if c.skipTLSVerify || c.useHTTP {
+		opts = append(opts, containerdregistry.SkipTLS(true))
+	}
+
+	reg, err := newImageRegistryForTool(logger, c.imageBuilder, opts...)
 	if err != nil {
-		return nil, "", err
+		cleanup()
+		return nil, nil, err
 	}
-	manifestsDirName, hasLabel := metadata.GetManifestsDir()
-	if !hasLabel {
-		manifestsDirName = registrybundle.ManifestsDir
+	return reg, func() {
+		if err := reg.Destroy(); err != nil {
+			logger.Errorf("Error destroying image registry: %v", err)
+		}
+		cleanup()
+	}, nil
+}
+
+// newResolverConfigDir returns the docker config dir image resolvers query registries with
+// for c's pull flags, and a function that removes any files created for it.
+func (c bundleValidateCmd) newResolverConfigDir(logger *log.Entry) (string, func(), error) {
+	var configDir string
+	cleanup := func() {
+		if configDir == "" {
+			return
+		}
+		if err := os.RemoveAll(configDir); err != nil {
+			logger.Errorf("Error removing temp docker config dir: %v", err)
+		}
 	}
-	manifestsDir := filepath.Join(dir, manifestsDirName)
-	// Detect mediaType.
-	mediaType, err := registrybundle.GetMediaType(manifestsDir)
+	if c.pullSecret != "" {
+		var err error
+		if configDir, err = newDockerConfigDir(c.pullSecret); err != nil {
+			return "", nil, err
+		}
+	}
+	return configDir, cleanup, nil
+}
+
+// newDockerConfigDir copies the docker config file at path to a new temp directory
+// as config.json, the file registries read credentials from, and returns the directory.
+func newDockerConfigDir(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, "", err
+		return "", fmt.Errorf("error reading pull secret: %v", err)
 	}
-	// Read the bundle.
-	bundle, err := apimanifests.GetBundleFromDir(manifestsDir)
+	dir, err := ioutil.TempDir("", "bundle-validate-auth-")
 	if err != nil {
-		return nil, "", err
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), b, 0600); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("error writing pull secret: %v", err)
 	}
-	return bundle, mediaType, nil
+	return dir, nil
 }
 
 // newImageRegistryForTool returns an image registry based on what type of image tool is passed.
-// If toolStr is empty, a containerd registry is returned.
-func newImageRegistryForTool(logger *log.Entry, toolStr string) (reg registryimage.Registry, err error) {
+// opts configure the containerd registry returned if toolStr is "none".
+func newImageRegistryForTool(logger *log.Entry, toolStr string,
+	opts ...containerdregistry.RegistryOption) (reg registryimage.Registry, err error) {
 	switch toolStr {
 	case containertools.DockerTool.String():
 		reg, err = execregistry.NewRegistry(containertools.DockerTool, logger)
 	case containertools.PodmanTool.String():
 		reg, err = execregistry.NewRegistry(containertools.PodmanTool, logger)
 	case containertools.NoneTool.String():
-		reg, err = containerdregistry.NewRegistry(
+		reg, err = containerdregistry.NewRegistry(append([]containerdregistry.RegistryOption{
 			containerdregistry.WithLog(logger),
 			// In case reg.Destroy() fails in the caller, make it obvious where this cache came from.
 			containerdregistry.WithCacheDir(filepath.Join(os.TempDir(), "bundle-validate-cache")),
-		)
+		}, opts...)...)
 	default:
 		err = fmt.Errorf("unrecognized image-builder option: %s", toolStr)
 	}
 	return reg, err
 }
 
-// unpackImageIntoDir writes files in image layers found in image imageTag to dir.
-func (c bundleValidateCmd) unpackImageIntoDir(reg registryimage.Registry, imageTag, dir string) error {
-	logger := log.WithFields(log.Fields{
-		"bundle-dir":     dir,
-		"container-tool": c.imageBuilder,
-	})
-	val := registrybundle.NewImageValidator(reg, logger)
-
-	return val.PullBundleImage(imageTag, dir)
+// unpackImageIntoDir pulls image imageTag, writes files in its layers to dir, and returns its labels.
+func (c bundleValidateCmd) unpackImageIntoDir(reg registryimage.Registry, imageTag, dir string) (map[string]string, error) {
+	ctx := context.TODO()
+	ref := registryimage.SimpleReference(imageTag)
+	if err := reg.Pull(ctx, ref); err != nil {
+		return nil, fmt.Errorf("error pulling image: %v", err)
+	}
+	if err := reg.Unpack(ctx, ref, dir); err != nil {
+		return nil, err
+	}
+	imageLabels, err := reg.Labels(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("error reading image labels: %v", err)
+	}
+	return imageLabels, nil
 }
 
 // relWd returns the path of dir relative to the current working directory
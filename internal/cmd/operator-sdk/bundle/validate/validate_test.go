@@ -15,10 +15,15 @@
 package validate
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/bundle/validate/internal"
 )
@@ -36,7 +41,12 @@ var _ = Describe("Running a bundle validate command", func() {
 			flag = cmd.Flags().Lookup("image-builder")
 			Expect(flag).NotTo(BeNil())
 			Expect(flag.Shorthand).To(Equal("b"))
-			Expect(flag.DefValue).To(Equal("docker"))
+			Expect(flag.DefValue).To(Equal("none"))
+
+			for _, name := range []string{"pull-secret", "skip-tls-verify", "use-http", "alpha-select-external", "k8s-version",
+				"verify-images", "require-digests"} {
+				Expect(cmd.Flags().Lookup(name)).NotTo(BeNil())
+			}
 
 			flag = cmd.Flags().Lookup("select-optional")
 			Expect(flag).NotTo(BeNil())
@@ -81,7 +91,7 @@ var _ = Describe("Running a bundle validate command", func() {
 			Expect(err.Error()).To(Equal("an image tag or directory is a required argument"))
 		})
 
-		It("fails if the output format isnt text or json-alpha1", func() {
+		It("fails if the output format isnt text, json, or json-alpha1", func() {
 			wrongArg := "json-alpha2"
 			cmd.outputFormat = wrongArg
 			err := cmd.validate([]string{"quay.io/person/example"})
@@ -89,14 +99,107 @@ var _ = Describe("Running a bundle validate command", func() {
 			Expect(err.Error()).To(Equal("invalid value for output flag: " + wrongArg))
 		})
 
-		It("succeeds if the arg is text or json-alpha1", func() {
+		It("succeeds if the arg is text, json, or json-alpha1", func() {
 			cmd.outputFormat = "text"
 			err := cmd.validate([]string{"quay.io/person/example"})
 			Expect(err).NotTo(HaveOccurred())
 
+			cmd.outputFormat = "json"
+			err = cmd.validate([]string{"quay.io/person/example"})
+			Expect(err).NotTo(HaveOccurred())
+
 			cmd.outputFormat = "json-alpha1"
 			err = cmd.validate([]string{"quay.io/person/example"})
 			Expect(err).NotTo(HaveOccurred())
 		})
+
+		It("fails if the Kubernetes version is invalid", func() {
+			cmd.outputFormat = internal.Text
+			cmd.k8sVersion = "latest"
+			err := cmd.validate([]string{"quay.io/person/example"})
+			Expect(err).To(MatchError(HavePrefix(`invalid Kubernetes version "latest"`)))
+
+			cmd.k8sVersion = "v1.22"
+			Expect(cmd.validate([]string{"quay.io/person/example"})).To(Succeed())
+		})
+
+		It("fails if image pull flags are set with a container tool", func() {
+			cmd.outputFormat = internal.Text
+			cmd.imageBuilder = "docker"
+			cmd.pullSecret = "config.json"
+			err := cmd.validate([]string{"quay.io/person/example"})
+			Expect(err).To(MatchError("--pull-secret can only be set with --image-builder=none or --verify-images"))
+
+			cmd.pullSecret = ""
+			cmd.useHTTP = true
+			err = cmd.validate([]string{"quay.io/person/example"})
+			Expect(err).To(MatchError("--skip-tls-verify and --use-http can only be set with --image-builder=none or --verify-images"))
+		})
+
+		It("succeeds if image pull flags are set with a container tool to verify images", func() {
+			cmd.outputFormat = internal.Text
+			cmd.imageBuilder = "docker"
+			cmd.pullSecret = "config.json"
+			cmd.useHTTP = true
+			cmd.verifyImages = true
+			cmd.selectors = []labels.Selector{labels.SelectorFromSet(map[string]string{nameKey: imageRefsValidatorName})}
+			Expect(cmd.validate([]string{"quay.io/person/example"})).To(Succeed())
+		})
+
+		It("fails if image verification flags are set without selecting the image-refs validator", func() {
+			cmd.outputFormat = internal.Text
+			cmd.requireDigests = true
+			err := cmd.validate([]string{"quay.io/person/example"})
+			Expect(err).To(MatchError(HavePrefix("--require-digests requires selecting the image-refs")))
+
+			cmd.selectors = []labels.Selector{labels.SelectorFromSet(map[string]string{nameKey: multiArchValidatorName})}
+			err = cmd.validate([]string{"quay.io/person/example"})
+			Expect(err).To(HaveOccurred())
+
+			cmd.selectors = []labels.Selector{labels.SelectorFromSet(map[string]string{suiteKey: "operatorframework"})}
+			Expect(cmd.validate([]string{"quay.io/person/example"})).To(Succeed())
+		})
+
+		It("fails if --verify-images is set without selecting the image-refs or multiarch validator", func() {
+			cmd.outputFormat = internal.Text
+			cmd.verifyImages = true
+			cmd.selectors = []labels.Selector{labels.SelectorFromSet(map[string]string{nameKey: "community"})}
+			err := cmd.validate([]string{"quay.io/person/example"})
+			Expect(err).To(MatchError(HavePrefix("--verify-images requires selecting the image-refs or multiarch")))
+
+			cmd.selectors = []labels.Selector{labels.SelectorFromSet(map[string]string{nameKey: multiArchValidatorName})}
+			Expect(cmd.validate([]string{"quay.io/person/example"})).To(Succeed())
+		})
+
+		It("succeeds if image pull flags are set without a container tool", func() {
+			cmd.outputFormat = internal.Text
+			cmd.imageBuilder = "none"
+			cmd.pullSecret = "config.json"
+			cmd.skipTLSVerify = true
+			cmd.useHTTP = true
+			Expect(cmd.validate([]string{"localhost:5000/example"})).To(Succeed())
+		})
+	})
+
+	Describe("newDockerConfigDir", func() {
+		It("copies a docker config file to config.json in a new directory", func() {
+			f, err := ioutil.TempFile("", "pull-secret-")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.Remove(f.Name())
+			_, err = f.WriteString(`{"auths":{"localhost:5000":{"auth":"dXNlcjpwYXNz"}}}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f.Close()).To(Succeed())
+
+			dir, err := newDockerConfigDir(f.Name())
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+			b, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(b)).To(ContainSubstring("localhost:5000"))
+		})
+		It("fails if the docker config file does not exist", func() {
+			_, err := newDockerConfigDir(filepath.Join("testdata", "does-not-exist.json"))
+			Expect(err).To(HaveOccurred())
+		})
 	})
 })
@@ -27,31 +27,51 @@ import (
 func NewCmd() *cobra.Command {
 	var timeout time.Duration
 	cfg := &operator.Configuration{}
+	u := operator.NewUninstall(cfg)
 	cmd := &cobra.Command{
-		Use:   "cleanup <operatorPackageName>",
+		Use:   "cleanup <operatorPackageName> [<additionalPackageName>...]",
 		Short: "Clean up an Operator deployed with the 'run' subcommand",
-		Long:  "This command has subcommands that will destroy an Operator deployed with OLM.",
-		Args:  cobra.ExactArgs(1),
+		Long: `This command has subcommands that will destroy an Operator deployed with OLM.
+Additional packages installed from the same catalog, ex. the operator's dependencies,
+are uninstalled in reverse order before the operator package. CRDs skipped with
+'run packagemanifests --skip-crds' are not part of the install and are never deleted.`,
+		Args: cobra.MinimumNArgs(1),
 		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
 			return cfg.Load()
 		},
-		Run: func(cmd *cobra.Command, args []string) {
-			u := operator.NewUninstall(cfg)
+		PreRunE: func(_ *cobra.Command, args []string) error {
 			u.Package = args[0]
+			u.AdditionalPackages = args[1:]
 			u.DeleteAll = true
 			u.DeleteOperatorGroupNames = []string{operator.SDKOperatorGroupName}
 			u.Logf = log.Infof
-
+			return u.Validate()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
 			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
 			defer cancel()
 
 			if err := u.Run(ctx); err != nil {
 				log.Fatalf("Uninstall operator: %v\n", err)
 			}
+			for _, pkg := range u.AdditionalPackages {
+				log.Infof("Operator %q uninstalled\n", pkg)
+			}
 			log.Infof("Operator %q uninstalled\n", u.Package)
 		},
 	}
 	cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "Time to wait for the command to complete before failing")
+	cmd.Flags().BoolVar(&u.SkipCleanupOrphans, "skip-cleanup-orphans", false,
+		"Do not delete registry objects for the package that are not owned by its catalog source")
+	cmd.Flags().BoolVar(&u.DeleteNamespace, "delete-namespace", false,
+		"Delete the operator's namespace after all operator resources are removed. "+
+			"Only namespaces labeled owner=operator-sdk are deleted unless --force is set")
+	cmd.Flags().BoolVar(&u.Force, "force", false,
+		"Delete the operator's namespace with --delete-namespace even if it was not created by operator-sdk")
+	cmd.Flags().BoolVarP(&u.AllNamespaces, "all-namespaces", "A", false,
+		"Uninstall the operator from every namespace containing a Subscription for its package")
+	cmd.Flags().BoolVar(&u.Yes, "yes", false,
+		"Uninstall the operator with --all-namespaces even if it is installed in more than one namespace")
 	cfg.BindFlags(cmd.PersistentFlags())
 
 	return cmd
@@ -20,6 +20,7 @@ import (
 	"github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/completion"
 	"github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/generate"
 	"github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/olm"
+	"github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/pkgmantobundle"
 	"github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/run"
 	"github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/scorecard"
 	"github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/version"
@@ -41,6 +42,7 @@ var commands = []*cobra.Command{
 	completion.NewCmd(),
 	generate.NewCmd(),
 	olm.NewCmd(),
+	pkgmantobundle.NewCmd(),
 	run.NewCmd(),
 	scorecard.NewCmd(),
 	version.NewCmd(),
@@ -19,12 +19,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
 
 	"github.com/operator-framework/api/pkg/apis/scorecard/v1alpha3"
 	"github.com/operator-framework/operator-registry/pkg/lib/bundle"
+	log "github.com/sirupsen/logrus"
 	"sigs.k8s.io/kubebuilder/pkg/model/config"
 	"sigs.k8s.io/yaml"
 
@@ -47,8 +49,64 @@ Typically one would run 'generate kustomize manifests' first to (re)generate kus
 Set '--version' to supply a semantic version for your bundle if you are creating one
 for the first time or upgrading an existing one.
 
-If '--output-dir' is set and you wish to build bundle images from that directory,
-either manually update your bundle.Dockerfile or set '--overwrite'.
+Set '--output-dir' to write bundle manifests, metadata, and bundle.Dockerfile to a directory
+other than the project root. The bundle.Dockerfile is written to that directory, and its paths
+are relative to it, so the bundle image can be built with that directory as the build context.
+
+Set '--min-kube-version' to the minimum Kubernetes version your operator supports, which is
+set as the CSV's spec.minKubeVersion. The version is saved in the PROJECT file so later runs keep it.
+
+Set '--skip-range' and '--skips' to let operator versions other than the replaced one upgrade directly
+to this version. These set the CSV's olm.skipRange annotation and spec.skips; if not set, an existing
+CSV's values are kept.
+
+Projects without a PROJECT file, ex. those with a legacy 'deploy' directory, can generate a bundle
+by setting '--deploy-dir', '--crds-dir', and '--version'. A CSV is generated from defaults
+if '--kustomize-dir' does not contain a CSV base, and no project config is saved.
+
+Set '--package' to publish your bundle in a package not named after your project. The package name
+prefixes the CSV's name and is set as the bundle's package annotation, and is saved in the PROJECT file
+so later runs keep it. If an existing bundle belongs to another package, its CSV is renamed and a warning is
+logged, since OLM does not upgrade operators installed from the previous package to the renamed one.
+
+Set '--use-image-digests' to pin every image in the CSV's install strategy Deployments, RELATED_IMAGE_* env vars,
+spec.relatedImages, and containerImage annotation to the digest it currently resolves to, ex. for disconnected
+installs. Registries are queried with credentials in the docker config file; an image that cannot be resolved
+fails generation unless '--skip-unresolvable' is set. Once queries to one registry fail 3 times or take 2 minutes
+in total, its remaining images are left pinned to their tags with a warning, unless '--require-digests' is set.
+
+After the bundle is written, it is validated like 'bundle validate' validates a bundle directory.
+Validation warnings are logged, and generation fails if there are any validation errors.
+Set '--skip-validation' to skip validation.
+
+Files written to the bundle are listed, with checksums, in 'metadata/generated-files.yaml'.
+By default only files in that list that have not been modified since they were generated are changed;
+if any other existing file would change, ex. a manually edited CRD or a file generated by another tool,
+nothing is written and those files are listed. The CSV is the exception, since manually edited fields
+are merged into it. Bundles generated before files were listed have their existing files treated as generated.
+Set '--force-overwrite' to change any existing file, or '--no-overwrite' to fail
+with a summary of changes if any existing file or metadata would change.
+Set '--overwrite-csv-metadata' to replace manually edited CSV fields, such as description and maintainers,
+with those of the kustomize base.
+
+For Go operators, spec and status descriptors of owned CRDs in the CSV are generated from
+'+operator-sdk:csv:customresourcedefinitions' markers on API types under '--apis-dir'.
+Malformed markers fail generation with the file and line of each marker.
+
+Set '--channels' and '--default-channel' to the channels your bundle belongs to.
+These are written to both bundle metadata and bundle.Dockerfile LABEL's; if existing
+metadata has different channels, only the channel annotations are overwritten.
+Existing annotations not managed by operator-sdk are always preserved.
+
+Set '--bundle-label' once per custom LABEL, ex. '--bundle-label vendor=example.com', to add to bundle.Dockerfile.
+Labels with keys prefixed by 'operators.operatorframework.io.' are also added to bundle metadata.
+Labels set by operator-sdk cannot be overridden. Custom labels are saved in the PROJECT file so later runs keep them.
+
+Set '--build-arg-labels' to LABEL's whose values are passed at build time, ex.
+'--build-arg-labels org.opencontainers.image.version=VERSION,org.opencontainers.image.revision=VCS_REF'.
+Each ARG and LABEL is written to a marked section after bundle.Dockerfile's FROM instruction, so CI can set them
+with 'docker build --build-arg VERSION=<version>'. ARG's and LABEL's added below the section's marker comment
+are preserved when the Dockerfile is regenerated. Build arg labels are saved in the PROJECT file.
 
 More information on bundles:
 https://github.com/operator-framework/operator-registry/#manifest-format
@@ -104,11 +162,39 @@ https://github.com/operator-framework/operator-registry/#manifest-format
 // defaultRootDir is the default root directory in which to generate bundle files.
 const defaultRootDir = "bundle"
 
+// readConfig returns the project config. Projects not laid out by kubebuilder, ex. those with a legacy
+// deploy directory, have no project config file; if --deploy-dir is set for such a project, a config
+// for the standalone generation mode is returned instead. Like the project config of a version 2 project,
+// this config names the operator after the working directory and cannot save plugin config.
+func (c *bundleCmd) readConfig() (*config.Config, error) {
+	if c.deployDir != "" && !projutil.HasProjectFile() {
+		c.standalone = true
+		return &config.Config{Version: config.Version2}, nil
+	}
+	cfg, err := projutil.ReadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error reading configuration: %v", err)
+	}
+	return cfg, nil
+}
+
 // setDefaults sets defaults useful to all modes of this subcommand.
 func (c *bundleCmd) setDefaults(cfg *config.Config) (err error) {
 	if c.projectName, err = genutil.GetOperatorName(cfg); err != nil {
 		return err
 	}
+	if c.packageName == "" {
+		if c.packageName, err = genutil.GetPackageName(cfg); err != nil {
+			return err
+		}
+	}
+	if c.apisDir == "" {
+		if cfg.MultiGroup {
+			c.apisDir = "apis"
+		} else {
+			c.apisDir = "api"
+		}
+	}
 	return nil
 }
 
@@ -119,10 +205,47 @@ func (c bundleCmd) validateManifests(*config.Config) (err error) {
 			return err
 		}
 	}
+	if c.fromVersion != "" {
+		if err := genutil.ValidateFromVersion(c.fromVersion, c.version); err != nil {
+			return err
+		}
+	}
+	if c.minKubeVersion != "" {
+		if err := genutil.ValidateMinKubeVersion(c.minKubeVersion); err != nil {
+			return fmt.Errorf("invalid --min-kube-version: %v", err)
+		}
+	}
+	if c.skipRange != "" {
+		if err := genutil.ValidateSkipRange(c.skipRange, c.version); err != nil {
+			return fmt.Errorf("invalid --skip-range: %v", err)
+		}
+	}
+	if err := genutil.ValidateSkips(c.skips); err != nil {
+		return fmt.Errorf("invalid --skips: %v", err)
+	}
+
+	if !c.useImageDigests {
+		switch {
+		case c.skipUnresolvable:
+			return errors.New("--skip-unresolvable requires --use-image-digests")
+		case c.requireDigests:
+			return errors.New("--require-digests requires --use-image-digests")
+		case c.skipTLSVerify:
+			return errors.New("--skip-tls-verify requires --use-image-digests")
+		case c.useHTTP:
+			return errors.New("--use-http requires --use-image-digests")
+		}
+	}
+	if c.skipUnresolvable && c.requireDigests {
+		return errors.New("--skip-unresolvable and --require-digests cannot both be set")
+	}
 
 	if c.kustomizeDir == "" {
 		return errors.New("--kustomize-dir must be set")
 	}
+	if c.standalone && c.version == "" {
+		return errors.New("--version must be set if there is no PROJECT file")
+	}
 
 	if !genutil.IsPipeReader() {
 		if c.deployDir == "" {
@@ -139,6 +262,10 @@ func (c bundleCmd) validateManifests(*config.Config) (err error) {
 		}
 	}
 
+	if err := genutil.ValidatePackageName(c.packageName); err != nil {
+		return fmt.Errorf("invalid --package: %v", err)
+	}
+
 	return nil
 }
 
@@ -162,6 +289,10 @@ func (c bundleCmd) runManifests(cfg *config.Config) (err error) {
 		}
 	}
 
+	if c.standalone {
+		log.Infof("No PROJECT file found, generating bundle from manifests in %s and %s", c.deployDir, c.crdsDir)
+	}
+
 	col := &collector.Manifests{}
 	if genutil.IsPipeReader() {
 		if err := col.UpdateFromReader(os.Stdin); err != nil {
@@ -173,45 +304,79 @@ func (c bundleCmd) runManifests(cfg *config.Config) (err error) {
 			return err
 		}
 	}
+	// Samples populate the CSV's alm-examples even if not passed as input.
+	if err := col.UpdateFromSamples(filepath.Join("config", "samples")); err != nil {
+		return err
+	}
 
-	csvGen := gencsv.Generator{
-		OperatorName: c.projectName,
-		OperatorType: projutil.PluginKeyToOperatorType(cfg.Layout),
-		Version:      c.version,
-		Collector:    col,
+	// The replaced CSV is usually published from another source, so only warn if
+	// the existing bundle does not contain it.
+	if c.fromVersion != "" {
+		replaces := fmt.Sprintf("%s.v%s", c.packageName, c.fromVersion)
+		if err := genutil.CheckCSVExists(filepath.Join(c.inputDir, bundle.ManifestsDir), replaces); err != nil {
+			log.Warnf("Cannot verify --from-version: %v", err)
+		}
 	}
 
-	stdout := genutil.NewMultiManifestWriter(os.Stdout)
-	opts := []gencsv.Option{
-		// By not passing apisDir and turning interactive prompts on, we forcibly rely on the kustomize base
-		// for UI metadata and uninferrable data.
-		gencsv.WithBase(c.kustomizeDir, "", projutil.InteractiveHardOff),
+	minKubeVersion := c.minKubeVersion
+	if minKubeVersion == "" {
+		if minKubeVersion, err = genutil.GetMinKubeVersion(cfg); err != nil {
+			return err
+		}
 	}
-	if c.stdout {
-		opts = append(opts, gencsv.WithWriter(stdout))
-	} else {
-		opts = append(opts, gencsv.WithBundleWriter(c.outputDir))
+	// UI metadata saved in the project config replaces that of the base and existing CSV.
+	projectMetadata, err := genutil.GetUIMetadata(cfg)
+	if err != nil {
+		return err
 	}
 
-	if err := csvGen.Generate(cfg, opts...); err != nil {
-		return fmt.Errorf("error generating ClusterServiceVersion: %v", err)
+	csvGen := gencsv.Generator{
+		OperatorName:           c.projectName,
+		PackageName:            c.packageName,
+		OperatorType:           projutil.PluginKeyToOperatorType(cfg.Layout),
+		Version:                c.version,
+		FromVersion:            c.fromVersion,
+		Collector:              col,
+		IconPath:               c.iconFile,
+		ProjectMetadata:        projectMetadata,
+		MinKubeVersion:         minKubeVersion,
+		SkipRange:              c.skipRange,
+		Skips:                  c.skips,
+		SkipRelatedImages:      c.skipRelatedImages,
+		SkipNativeAPIDetection: c.skipNativeAPIDetection,
+		Overwrite:              c.overwriteCSV,
 	}
-
-	objs := genutil.GetManifestObjects(col)
-	if c.stdout {
-		if err := genutil.WriteObjects(stdout, objs...); err != nil {
+	if !c.stdout {
+		if csvGen.PreviousPackageName, err = c.checkPackageRename(c.outputDir); err != nil {
 			return err
 		}
-	} else {
-		dir := filepath.Join(c.outputDir, bundle.ManifestsDir)
-		if err := genutil.WriteObjectsToFiles(dir, objs...); err != nil {
+	}
+	if c.useImageDigests {
+		if csvGen.DigestResolver, err = registry.NewDigestResolver("", c.skipTLSVerify, c.useHTTP); err != nil {
 			return err
 		}
+		csvGen.SkipUnresolvableImages = c.skipUnresolvable
+		csvGen.RequireDigests = c.requireDigests
+	}
+
+	// Descriptors are (re)generated from API type markers in apisDir. By turning interactive prompts off,
+	// we forcibly rely on the kustomize base for UI metadata and uninferrable data.
+	opts := []gencsv.Option{gencsv.WithBase(c.kustomizeDir, c.apisDir, projutil.InteractiveHardOff)}
+
+	if c.stdout {
+		err = writeManifestsToStdout(cfg, csvGen, col, opts)
+	} else {
+		err = c.writeBundleManifests(cfg, csvGen, col, opts)
+	}
+	if err != nil {
+		return err
 	}
 
-	// Write the scorecard config if it was passed.
-	if err := writeScorecardConfig(c.outputDir, col.ScorecardConfig); err != nil {
-		return fmt.Errorf("error writing bundle scorecard config: %v", err)
+	// Save an explicitly set minimum Kubernetes version for later runs.
+	if c.minKubeVersion != "" && !c.standalone {
+		if err := genutil.SaveMinKubeVersion(cfg, c.minKubeVersion); err != nil {
+			return err
+		}
 	}
 
 	if !c.quiet && !c.stdout {
@@ -221,6 +386,92 @@ func (c bundleCmd) runManifests(cfg *config.Config) (err error) {
 	return nil
 }
 
+// writeManifestsToStdout writes the CSV generated by csvGen with opts and manifests collected by col to stdout.
+func writeManifestsToStdout(cfg *config.Config, csvGen gencsv.Generator, col *collector.Manifests,
+	opts []gencsv.Option) error {
+
+	stdout := genutil.NewMultiManifestWriter(os.Stdout)
+	if err := csvGen.Generate(cfg, append(opts, gencsv.WithWriter(stdout))...); err != nil {
+		return fmt.Errorf("error generating ClusterServiceVersion: %v", err)
+	}
+	objs, err := genutil.GetManifestObjects(col)
+	if err != nil {
+		return err
+	}
+	return genutil.WriteObjects(stdout, objs...)
+}
+
+// writeBundleManifests writes bundle manifests to c.outputDir, changing existing files as permitted by
+// c.overwriteMode. All files are staged first so they can be checked against the existing bundle
+// before it is changed.
+func (c bundleCmd) writeBundleManifests(cfg *config.Config, csvGen gencsv.Generator, col *collector.Manifests,
+	opts []gencsv.Option) error {
+
+	stagingDir, err := ioutil.TempDir("", "operator-sdk-bundle-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(stagingDir); err != nil {
+			log.Error(err)
+		}
+	}()
+	if err := c.stageManifests(cfg, csvGen, col, opts, stagingDir); err != nil {
+		return err
+	}
+
+	// Manually edited CSV fields are preserved by merging them into the generated CSV,
+	// so the CSV may be regenerated even if it was edited since it was generated.
+	// The CSV of a renamed package is merged into the CSV of the new package, replacing it.
+	csvPaths := []string{path.Join(bundle.ManifestsDir, c.packageName+".clusterserviceversion.yaml")}
+	if csvGen.PreviousPackageName != "" {
+		csvPaths = append(csvPaths, path.Join(bundle.ManifestsDir, csvGen.PreviousPackageName+".clusterserviceversion.yaml"))
+	}
+	changes, err := genutil.SyncGeneratedFiles(stagingDir, c.outputDir, c.overwriteMode, csvPaths...)
+	if err != nil {
+		return err
+	}
+	if !c.quiet && len(changes) != 0 {
+		fmt.Printf("Changed bundle files in %s:\n%s\n", c.outputDir, genutil.FormatFileChanges(changes))
+	}
+	return nil
+}
+
+// stageManifests writes the CSV generated by csvGen with opts, manifests collected by col,
+// and the scorecard config to a bundle in stagingDir.
+func (c bundleCmd) stageManifests(cfg *config.Config, csvGen gencsv.Generator, col *collector.Manifests,
+	opts []gencsv.Option, stagingDir string) error {
+
+	// Data from an existing CSV is read from the output directory.
+	opts = append(opts, gencsv.WithStagedBundleWriter(c.outputDir, stagingDir))
+	if err := csvGen.Generate(cfg, opts...); err != nil {
+		return fmt.Errorf("error generating ClusterServiceVersion: %v", err)
+	}
+
+	objs, err := genutil.GetManifestObjects(col)
+	if err != nil {
+		return err
+	}
+	if err := genutil.WriteObjectsToFiles(filepath.Join(stagingDir, bundle.ManifestsDir), objs...); err != nil {
+		return err
+	}
+
+	// Write the scorecard config if it was passed, typically by including config/scorecard
+	// in the kustomize build piped to this command.
+	switch {
+	case c.skipScorecardConfig:
+	case col.ScorecardConfig.Metadata.Name == "":
+		log.Warnf("No scorecard config found in input manifests, so none will be written to %s; "+
+			"include config/scorecard in your manifests kustomization to add one",
+			filepath.Join(c.outputDir, filepath.FromSlash(scorecard.DefaultConfigDir)))
+	default:
+		if err := writeScorecardConfig(stagingDir, col.ScorecardConfig); err != nil {
+			return fmt.Errorf("error writing bundle scorecard config: %v", err)
+		}
+	}
+	return nil
+}
+
 // writeScorecardConfig writes cfg to dir at the hard-coded config path 'config.yaml'.
 func writeScorecardConfig(dir string, cfg v1alpha3.Configuration) error {
 	if cfg.Metadata.Name == "" {
@@ -242,6 +493,25 @@ func writeScorecardConfig(dir string, cfg v1alpha3.Configuration) error {
 
 // validateMetadata validates c for bundle metadata generation.
 func (c bundleCmd) validateMetadata(*config.Config) (err error) {
+	if err := genutil.ValidatePackageName(c.packageName); err != nil {
+		return fmt.Errorf("invalid --package: %v", err)
+	}
+	if _, _, err = genutil.ParseChannels(c.channels, c.defaultChannel); err != nil {
+		return err
+	}
+	flagLabels, err := genutil.ParseBundleLabels(c.bundleLabels)
+	if err != nil {
+		return fmt.Errorf("invalid --bundle-label: %v", err)
+	}
+	buildArgLabels, err := genutil.ParseBuildArgLabels(c.buildArgLabels)
+	if err != nil {
+		return fmt.Errorf("invalid --build-arg-labels: %v", err)
+	}
+	for key := range buildArgLabels {
+		if _, hasKey := flagLabels[key]; hasKey {
+			return fmt.Errorf("label %q cannot be set by both --bundle-label and --build-arg-labels", key)
+		}
+	}
 	return nil
 }
 
@@ -272,31 +542,309 @@ func (c bundleCmd) runMetadata(cfg *config.Config) error {
 // generateMetadata wraps the operator-registry bundle Dockerfile/metadata generator.
 func (c bundleCmd) generateMetadata(cfg *config.Config, manifestsDir, outputDir string) error {
 
-	metadataExists := isMetatdataExist(outputDir, manifestsDir)
-	err := bundle.GenerateFunc(manifestsDir, outputDir, c.projectName, c.channels, c.defaultChannel, c.overwrite)
+	channels, defaultChannel, err := genutil.ParseChannels(c.channels, c.defaultChannel)
+	if err != nil {
+		return err
+	}
+	channelLabels := genutil.MakeChannelLabels(channels, defaultChannel)
+	flagLabels, err := genutil.ParseBundleLabels(c.bundleLabels)
+	if err != nil {
+		return fmt.Errorf("invalid --bundle-label: %v", err)
+	}
+	customLabels, err := getCustomLabels(cfg, flagLabels)
+	if err != nil {
+		return err
+	}
+	flagBuildArgLabels, err := genutil.ParseBuildArgLabels(c.buildArgLabels)
+	if err != nil {
+		return fmt.Errorf("invalid --build-arg-labels: %v", err)
+	}
+	buildArgLabels, err := getBuildArgLabels(cfg, flagBuildArgLabels)
+	if err != nil {
+		return err
+	}
+
+	bundleRoot := outputDir
+	if bundleRoot == "" {
+		bundleRoot = filepath.Dir(manifestsDir)
+	}
+	dockerfilePath := genutil.GetBundleDockerfilePath(outputDir)
+
+	// Lines added to the build arg section would be lost if the Dockerfile is regenerated, so keep them.
+	var buildArgUserLines string
+	if b, err := ioutil.ReadFile(dockerfilePath); err == nil {
+		_, buildArgUserLines = genutil.SplitDockerfileBuildArgs(string(b))
+	}
+
+	// Update channels in existing metadata first so they do not conflict with generated metadata.
+	metadataExists := isMetatdataExist(outputDir, manifestsDir, dockerfilePath)
+	existing, err := syncChannelLabels(bundleRoot, dockerfilePath, channelLabels, c.noOverwrite)
+	if err != nil {
+		return err
+	}
+
+	// Manifests generation has already checked for a renamed package.
+	if !c.manifests {
+		if _, err := c.checkPackageRename(bundleRoot); err != nil {
+			return err
+		}
+	}
+	err = genutil.GenerateBundleMetadata(manifestsDir, outputDir, c.packageName, channels, defaultChannel, c.overwrite)
 	if err != nil {
 		return fmt.Errorf("error generating bundle metadata: %v", err)
 	}
 
 	// Add SDK annotations/labels if metadata did not exist before or when overwrite is true.
 	if c.overwrite || !metadataExists {
-		bundleRoot := outputDir
-		if bundleRoot == "" {
-			bundleRoot = filepath.Dir(manifestsDir)
+		if err = updateMetadata(cfg, bundleRoot, dockerfilePath, existing, c.skipScorecardConfig); err != nil {
+			return err
 		}
+	}
 
-		if err = updateMetadata(cfg, bundleRoot); err != nil {
+	if err := syncCustomLabels(bundleRoot, dockerfilePath, customLabels, c.noOverwrite && metadataExists); err != nil {
+		return err
+	}
+	err = syncBuildArgLabels(dockerfilePath, buildArgLabels, buildArgUserLines, c.noOverwrite && metadataExists)
+	if err != nil {
+		return err
+	}
+	if len(flagLabels) != 0 && !c.standalone {
+		if err := genutil.SaveBundleLabels(cfg, flagLabels); err != nil {
 			return err
 		}
 	}
+	if len(flagBuildArgLabels) != 0 && !c.standalone {
+		return genutil.SaveBuildArgLabels(cfg, flagBuildArgLabels)
+	}
+	return nil
+}
+
+// checkPackageRename returns the package of the existing bundle in bundleRoot if it differs
+// from c.packageName, warning that the bundle is being moved to another package. An empty
+// string is returned if there is no existing bundle metadata or the package has not changed.
+func (c bundleCmd) checkPackageRename(bundleRoot string) (string, error) {
+	annotationsPath := filepath.Join(bundleRoot, bundle.MetadataDir, bundle.AnnotationsFile)
+	b, err := ioutil.ReadFile(annotationsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	annotationsFile := bundle.AnnotationMetadata{}
+	if err := yaml.Unmarshal(b, &annotationsFile); err != nil {
+		return "", fmt.Errorf("error unmarshalling bundle metadata %s: %v", annotationsPath, err)
+	}
+	previous := annotationsFile.Annotations[bundle.PackageLabel]
+	if previous == "" || previous == c.packageName {
+		return "", nil
+	}
+	log.Warnf("Bundle package is changing from %q to %q, so its CSV is renamed with the new package prefix. "+
+		"OLM does not upgrade operators installed from package %q to bundles of package %q, "+
+		"and this CSV cannot replace CSVs of the previous package", previous, c.packageName, previous, c.packageName)
+	return previous, nil
+}
+
+// getBundleRoot returns the directory that bundle manifests and metadata were written to.
+func (c bundleCmd) getBundleRoot() string {
+	switch {
+	case c.outputDir != "":
+		return c.outputDir
+	case !c.manifests && c.inputDir != "":
+		// Metadata is generated in place.
+		return c.inputDir
+	}
+	return defaultRootDir
+}
+
+// validateBundle validates the bundle in bundleRoot with the same validators as 'bundle validate',
+// logging warnings and returning an error containing all validation errors.
+func (c bundleCmd) validateBundle(bundleRoot string) error {
+	// A bundle without metadata, ex. from generating only manifests, cannot be validated.
+	if _, _, err := registry.FindBundleMetadata(bundleRoot); err != nil {
+		log.Debugf("Not validating bundle: %v", err)
+		return nil
+	}
+
+	_, results, err := registry.ValidateBundleDir(log.NewEntry(log.StandardLogger()), bundleRoot, "")
+	if err != nil {
+		return fmt.Errorf("error reading bundle %s: %v", bundleRoot, err)
+	}
+	var errs []string
+	for _, result := range results {
+		for _, w := range result.Warnings {
+			log.Warnf("%s: %v", bundleRoot, w)
+		}
+		for _, e := range result.Errors {
+			errs = append(errs, e.Error())
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("bundle %s has validation errors, fix them or set --skip-validation:\n%s",
+			bundleRoot, strings.Join(errs, "\n"))
+	}
 	return nil
 }
 
+// getCustomLabels returns custom bundle labels saved in cfg overridden by flagLabels.
+func getCustomLabels(cfg *config.Config, flagLabels map[string]string) (map[string]string, error) {
+	savedLabels, err := genutil.GetBundleLabels(cfg)
+	if err != nil {
+		return nil, err
+	}
+	customLabels := make(map[string]string, len(savedLabels)+len(flagLabels))
+	for key, value := range savedLabels {
+		customLabels[key] = value
+	}
+	for key, value := range flagLabels {
+		customLabels[key] = value
+	}
+	return customLabels, nil
+}
+
+// getBuildArgLabels returns build arg labels saved in cfg overridden by flagLabels.
+func getBuildArgLabels(cfg *config.Config, flagLabels map[string]string) (map[string]string, error) {
+	savedLabels, err := genutil.GetBuildArgLabels(cfg)
+	if err != nil {
+		return nil, err
+	}
+	buildArgLabels := make(map[string]string, len(savedLabels)+len(flagLabels))
+	for key, arg := range savedLabels {
+		buildArgLabels[key] = arg
+	}
+	for key, arg := range flagLabels {
+		buildArgLabels[key] = arg
+	}
+	return buildArgLabels, nil
+}
+
+// syncBuildArgLabels writes the build arg section for buildArgLabels to the Dockerfile at dockerfilePath,
+// followed by userLines previously added to that section. If noOverwrite is true, changing the Dockerfile is an error.
+func syncBuildArgLabels(dockerfilePath string, buildArgLabels map[string]string, userLines string, noOverwrite bool) error {
+	b, err := ioutil.ReadFile(dockerfilePath)
+	if err != nil {
+		return err
+	}
+	updated := genutil.SetDockerfileBuildArgs(string(b), buildArgLabels, userLines)
+	if updated == string(b) {
+		return nil
+	}
+	if noOverwrite {
+		return fmt.Errorf("refusing to change existing files, unset --no-overwrite to change them:\n"+
+			"%s: build arg LABEL's do not match --build-arg-labels", dockerfilePath)
+	}
+	return ioutil.WriteFile(dockerfilePath, []byte(updated), projutil.FileMode)
+}
+
+// syncCustomLabels sets customLabels as LABEL's in the Dockerfile at dockerfilePath,
+// and those in the operator framework annotation namespace as annotations in bundleRoot's
+// metadata. If noOverwrite is true, changes to either file are an error instead.
+func syncCustomLabels(bundleRoot, dockerfilePath string, customLabels map[string]string, noOverwrite bool) error {
+	if len(customLabels) == 0 {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(dockerfilePath)
+	if err != nil {
+		return err
+	}
+	if updated := genutil.SetDockerfileLabels(string(b), customLabels); updated != string(b) {
+		if noOverwrite {
+			return fmt.Errorf("refusing to change existing files, unset --no-overwrite to change them:\n"+
+				"%s: LABEL's do not match custom bundle labels", dockerfilePath)
+		}
+		if err := ioutil.WriteFile(dockerfilePath, []byte(updated), projutil.FileMode); err != nil {
+			return err
+		}
+	}
+
+	metadataLabels := genutil.GetMetadataLabels(customLabels)
+	if len(metadataLabels) == 0 {
+		return nil
+	}
+	annotations, annotationsPath, err := registry.FindBundleMetadata(bundleRoot)
+	if err != nil {
+		return err
+	}
+	numAnnotations := len(annotations)
+	if changed := genutil.UpdateLabels(annotations, metadataLabels); len(changed) == 0 && len(annotations) == numAnnotations {
+		return nil
+	}
+	if noOverwrite {
+		return fmt.Errorf("refusing to change existing files, unset --no-overwrite to change them:\n"+
+			"%s: annotations do not match custom bundle labels", annotationsPath)
+	}
+	return writeAnnotations(annotationsPath, annotations)
+}
+
+// syncChannelLabels overwrites channel annotations in bundleRoot's existing metadata
+// and channel LABEL's in the Dockerfile at dockerfilePath with channelLabels, warning on mismatches.
+// If noOverwrite is true, mismatches are an error instead. Existing annotations are returned,
+// or nil if metadata does not exist.
+func syncChannelLabels(bundleRoot, dockerfilePath string, channelLabels map[string]string,
+	noOverwrite bool) (registry.Labels, error) {
+	annotationsPath := filepath.Join(bundleRoot, bundle.MetadataDir, bundle.AnnotationsFile)
+	b, err := ioutil.ReadFile(annotationsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	annotationsFile := bundle.AnnotationMetadata{}
+	if err := yaml.Unmarshal(b, &annotationsFile); err != nil {
+		return nil, fmt.Errorf("error unmarshalling bundle metadata %s: %v", annotationsPath, err)
+	}
+	existing := registry.Labels(annotationsFile.Annotations)
+	if existing == nil {
+		existing = registry.Labels{}
+	}
+
+	if changed := genutil.UpdateLabels(existing, channelLabels); len(changed) != 0 {
+		if noOverwrite {
+			return nil, fmt.Errorf("refusing to change existing files in %s, unset --no-overwrite to change them:\n"+
+				"%s: channels do not match --channels and --default-channel", bundleRoot, annotationsPath)
+		}
+		log.Warnf("Channels in %s do not match --channels and --default-channel, overwriting %s",
+			annotationsPath, strings.Join(changed, ", "))
+		if err := writeAnnotations(annotationsPath, existing); err != nil {
+			return nil, err
+		}
+	}
+
+	if isExist(dockerfilePath) {
+		b, err := ioutil.ReadFile(dockerfilePath)
+		if err != nil {
+			return nil, err
+		}
+		if updated := genutil.SetDockerfileLabels(string(b), channelLabels); updated != string(b) {
+			if noOverwrite {
+				return nil, fmt.Errorf("refusing to change existing files, unset --no-overwrite to change them:\n"+
+					"%s: channel LABEL's do not match --channels and --default-channel", dockerfilePath)
+			}
+			log.Warnf("Channels in %s do not match --channels and --default-channel, overwriting channel LABEL's",
+				dockerfilePath)
+			if err := ioutil.WriteFile(dockerfilePath, []byte(updated), projutil.FileMode); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return existing, nil
+}
+
 // TODO(estroz): these updates need to be atomic because the bundle's Dockerfile and annotations.yaml
 // cannot be out-of-sync.
-func updateMetadata(cfg *config.Config, bundleRoot string) error {
+// Annotations in existing that are not generated are preserved, except for scorecard annotations
+// if skipScorecardConfig is true, since the bundle then has no scorecard config for them to reference.
+func updateMetadata(cfg *config.Config, bundleRoot, dockerfilePath string, existing registry.Labels,
+	skipScorecardConfig bool) error {
+
 	bundleLabels := metricsannotations.MakeBundleMetadataLabels(cfg)
 	for key, value := range scorecardannotations.MakeBundleMetadataLabels(scorecard.DefaultConfigDir) {
+		if skipScorecardConfig {
+			delete(existing, key)
+			continue
+		}
 		if _, hasKey := bundleLabels[key]; hasKey {
 			return fmt.Errorf("internal error: duplicate bundle annotation key %s", key)
 		}
@@ -304,19 +852,23 @@ func updateMetadata(cfg *config.Config, bundleRoot string) error {
 	}
 
 	// Write labels to bundle Dockerfile.
-	if err := rewriteDockerfileLabels(bundle.DockerFile, bundleLabels); err != nil {
-		return fmt.Errorf("error writing LABEL's in %s: %v", bundle.DockerFile, err)
+	if err := rewriteDockerfileLabels(dockerfilePath, bundleLabels); err != nil {
+		return fmt.Errorf("error writing LABEL's in %s: %v", dockerfilePath, err)
 	}
-	if err := rewriteAnnotations(bundleRoot, bundleLabels); err != nil {
+	if err := rewriteAnnotations(bundleRoot, bundleLabels, existing); err != nil {
 		return fmt.Errorf("error writing LABEL's in bundle metadata: %v", err)
 	}
 
+	if skipScorecardConfig {
+		return nil
+	}
+
 	// Add a COPY for the scorecard config to bundle Dockerfile.
 	// TODO: change input config path to be a flag-based value.
 	localScorecardConfigPath := filepath.Join(bundleRoot, filepath.FromSlash(scorecard.DefaultConfigDir))
-	err := writeDockerfileCOPYScorecardConfig(bundle.DockerFile, localScorecardConfigPath)
+	err := writeDockerfileCOPYScorecardConfig(dockerfilePath, localScorecardConfigPath)
 	if err != nil {
-		return fmt.Errorf("error writing scorecard config COPY in %s: %v", bundle.DockerFile, err)
+		return fmt.Errorf("error writing scorecard config COPY in %s: %v", dockerfilePath, err)
 	}
 
 	return nil
@@ -324,17 +876,22 @@ func updateMetadata(cfg *config.Config, bundleRoot string) error {
 
 // writeDockerfileCOPYScorecardConfig checks if bundle.Dockerfile and scorecard config exists in
 // the operator project. If it does, it injects the scorecard configuration into bundle image.
+// The COPY source is relative to the Dockerfile's directory, which is the build context.
 func writeDockerfileCOPYScorecardConfig(dockerfileName, localConfigDir string) error {
-	if isExist(bundle.DockerFile) && isExist(localConfigDir) {
-		scorecardFileContent := fmt.Sprintf("COPY %s %s\n", localConfigDir, "/"+scorecard.DefaultConfigDir)
+	if isExist(dockerfileName) && isExist(localConfigDir) {
+		copyDir, err := filepath.Rel(filepath.Dir(dockerfileName), localConfigDir)
+		if err != nil {
+			return err
+		}
+		scorecardFileContent := fmt.Sprintf("COPY %s %s\n", filepath.ToSlash(copyDir), "/"+scorecard.DefaultConfigDir)
 		return projutil.RewriteFileContents(dockerfileName, "COPY", scorecardFileContent)
 	}
 	return nil
 }
 
-// isMetatdataExist returns true if bundle.Dockerfile and metadataDir exist, if not
-// it returns false.
-func isMetatdataExist(outputDir, manifestsDir string) bool {
+// isMetatdataExist returns true if the Dockerfile at dockerfilePath and metadataDir exist,
+// if not it returns false.
+func isMetatdataExist(outputDir, manifestsDir, dockerfilePath string) bool {
 	var annotationsDir string
 	if outputDir == "" {
 		annotationsDir = filepath.Dir(manifestsDir) + bundle.MetadataDir
@@ -342,7 +899,7 @@ func isMetatdataExist(outputDir, manifestsDir string) bool {
 		annotationsDir = outputDir + bundle.MetadataDir
 	}
 
-	if genutil.IsNotExist(bundle.DockerFile) || genutil.IsNotExist(annotationsDir) {
+	if genutil.IsNotExist(dockerfilePath) || genutil.IsNotExist(annotationsDir) {
 		return false
 	}
 	return true
@@ -362,15 +919,25 @@ func rewriteDockerfileLabels(dockerfileName string, kvs map[string]string) error
 	return projutil.RewriteFileContents(dockerfileName, "LABEL", newBundleLabels.String())
 }
 
-func rewriteAnnotations(bundleRoot string, kvs map[string]string) error {
+func rewriteAnnotations(bundleRoot string, kvs map[string]string, existing registry.Labels) error {
 	annotations, annotationsPath, err := registry.FindBundleMetadata(bundleRoot)
 	if err != nil {
 		return err
 	}
 
+	for key, value := range existing {
+		if _, hasKey := annotations[key]; !hasKey {
+			annotations[key] = value
+		}
+	}
 	for key, value := range kvs {
 		annotations[key] = value
 	}
+	return writeAnnotations(annotationsPath, annotations)
+}
+
+// writeAnnotations writes annotations to the bundle metadata file at annotationsPath.
+func writeAnnotations(annotationsPath string, annotations registry.Labels) error {
 	annotationsFile := bundle.AnnotationMetadata{
 		Annotations: annotations,
 	}
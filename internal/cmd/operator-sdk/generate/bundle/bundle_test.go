@@ -0,0 +1,399 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/api/pkg/apis/scorecard/v1alpha3"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	registrybundle "github.com/operator-framework/operator-registry/pkg/lib/bundle"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/kubebuilder/pkg/model/config"
+	"sigs.k8s.io/yaml"
+
+	metricsannotations "github.com/operator-framework/operator-sdk/internal/annotations/metrics"
+	scorecardannotations "github.com/operator-framework/operator-sdk/internal/annotations/scorecard"
+	genutil "github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/generate/internal"
+	"github.com/operator-framework/operator-sdk/internal/plugins/manifests"
+	"github.com/operator-framework/operator-sdk/internal/registry"
+	"github.com/operator-framework/operator-sdk/internal/scorecard"
+)
+
+var _ = Describe("Setting overwrite options", func() {
+	var (
+		c  *bundleCmd
+		fs *pflag.FlagSet
+	)
+
+	BeforeEach(func() {
+		c = &bundleCmd{}
+		fs = pflag.NewFlagSet("bundle", pflag.ContinueOnError)
+		c.addFlagsTo(fs)
+	})
+
+	It("preserves manually edited CSV fields with the scaffolded Makefile's flags", func() {
+		args := strings.Fields(strings.NewReplacer("$(VERSION)", "0.0.1", "$(BUNDLE_METADATA_OPTS)", "").
+			Replace(manifests.GenerateBundleFlags))
+		Expect(args).To(ContainElement("--overwrite"))
+		Expect(fs.Parse(args)).To(Succeed())
+		Expect(c.setOverwriteOptions(fs)).To(Succeed())
+		Expect(c.overwrite).To(BeTrue())
+		Expect(c.overwriteCSV).To(BeFalse())
+		Expect(c.overwriteMode).To(Equal(genutil.OverwriteGenerated))
+	})
+
+	It("changes any existing file only with --force-overwrite", func() {
+		Expect(fs.Parse([]string{"--overwrite", "--force-overwrite"})).To(Succeed())
+		Expect(c.setOverwriteOptions(fs)).To(Succeed())
+		Expect(c.overwriteMode).To(Equal(genutil.OverwriteAll))
+	})
+
+	It("overwrites manually edited CSV fields only with --overwrite-csv-metadata", func() {
+		Expect(fs.Parse([]string{"--overwrite=true", "--overwrite-csv-metadata"})).To(Succeed())
+		Expect(c.setOverwriteOptions(fs)).To(Succeed())
+		Expect(c.overwriteCSV).To(BeTrue())
+	})
+
+	It("rejects --overwrite-csv-metadata with --no-overwrite", func() {
+		Expect(fs.Parse([]string{"--overwrite-csv-metadata", "--no-overwrite"})).To(Succeed())
+		Expect(c.setOverwriteOptions(fs)).To(MatchError(
+			"--overwrite, --force-overwrite, and --overwrite-csv-metadata cannot be set with --no-overwrite"))
+	})
+})
+
+var _ = Describe("Generating bundle metadata", func() {
+	const (
+		mediaTypeKey = "operators.operatorframework.io.test.mediatype.v1"
+		configKey    = "operators.operatorframework.io.test.config.v1"
+	)
+
+	var (
+		wd, tmp, manifestsDir, outputDir string
+		cfg                              *config.Config
+		scorecardConfig                  v1alpha3.Configuration
+		err                              error
+	)
+
+	BeforeEach(func() {
+		wd, err = os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		manifestsDir, err = filepath.Abs(filepath.Join("..", "internal", "testdata", "bundle", registrybundle.ManifestsDir))
+		Expect(err).NotTo(HaveOccurred())
+		tmp, err = ioutil.TempDir("", "generate-bundle-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(tmp)).To(Succeed())
+
+		outputDir = "bundle"
+		cfg = &config.Config{Version: config.Version3Alpha, Layout: "go.kubebuilder.io/v2"}
+		scorecardConfig = v1alpha3.Configuration{}
+		scorecardConfig.SetGroupVersionKind(v1alpha3.GroupVersion.WithKind(v1alpha3.ConfigurationKind))
+		scorecardConfig.Metadata.Name = "config"
+		scorecardConfig.Stages = []v1alpha3.StageConfiguration{{
+			Parallel: true,
+			Tests: []v1alpha3.TestConfiguration{{
+				Image:      "quay.io/operator-framework/scorecard-test:v1.0.0",
+				Entrypoint: []string{"scorecard-test", "basic-check-spec"},
+				Labels:     map[string]string{"suite": "basic", "test": "basic-check-spec-test"},
+			}},
+		}}
+	})
+	AfterEach(func() {
+		Expect(os.Chdir(wd)).To(Succeed())
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	It("adds scorecard metadata for a config that scorecard can read back from the bundle", func() {
+		c := bundleCmd{projectName: "memcached-operator", packageName: "memcached-operator", channels: "alpha",
+			overwrite: true}
+		Expect(writeScorecardConfig(outputDir, scorecardConfig)).To(Succeed())
+		Expect(c.generateMetadata(cfg, manifestsDir, outputDir)).To(Succeed())
+
+		By("adding scorecard annotations")
+		annotations, _, err := registry.FindBundleMetadata(outputDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(annotations).To(HaveKeyWithValue(mediaTypeKey, "scorecard+v1"))
+		Expect(annotations).To(HaveKeyWithValue(configKey, scorecard.DefaultConfigDir))
+
+		By("adding scorecard LABEL's and a COPY to the bundle.Dockerfile")
+		b, err := ioutil.ReadFile(filepath.Join(outputDir, registrybundle.DockerFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(ContainSubstring("LABEL " + mediaTypeKey + "=scorecard+v1\n"))
+		Expect(string(b)).To(ContainSubstring("LABEL " + configKey + "=" + scorecard.DefaultConfigDir + "\n"))
+		Expect(string(b)).To(ContainSubstring("COPY tests/scorecard /tests/scorecard/\n"))
+
+		By("reading the config back as scorecard does for a bundle directory")
+		configDir, hasDir := scorecardannotations.GetConfigDir(annotations)
+		Expect(hasDir).To(BeTrue())
+		loaded, err := scorecard.LoadConfig(filepath.Join(outputDir, configDir, scorecard.ConfigFileName))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded).To(Equal(scorecardConfig))
+	})
+
+	It("excludes the scorecard config and its metadata if skipped", func() {
+		c := bundleCmd{projectName: "memcached-operator", packageName: "memcached-operator", channels: "alpha",
+			overwrite: true}
+		// Stage and sync bundle files as writeBundleManifests does.
+		stagingDir := filepath.Join(tmp, "staging")
+		Expect(writeScorecardConfig(stagingDir, scorecardConfig)).To(Succeed())
+		_, err := genutil.SyncGeneratedFiles(stagingDir, outputDir, genutil.OverwriteGenerated)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.generateMetadata(cfg, manifestsDir, outputDir)).To(Succeed())
+
+		c.skipScorecardConfig = true
+		Expect(os.RemoveAll(stagingDir)).To(Succeed())
+		Expect(os.MkdirAll(stagingDir, 0755)).To(Succeed())
+		_, err = genutil.SyncGeneratedFiles(stagingDir, outputDir, genutil.OverwriteGenerated)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.generateMetadata(cfg, manifestsDir, outputDir)).To(Succeed())
+
+		Expect(filepath.Join(outputDir, "tests", "scorecard")).NotTo(BeADirectory())
+
+		annotations, _, err := registry.FindBundleMetadata(outputDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(annotations).NotTo(HaveKey(mediaTypeKey))
+		Expect(annotations).NotTo(HaveKey(configKey))
+		Expect(annotations).To(HaveKeyWithValue(registrybundle.PackageLabel, "memcached-operator"))
+		b, err := ioutil.ReadFile(filepath.Join(outputDir, registrybundle.DockerFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).NotTo(ContainSubstring(mediaTypeKey))
+		Expect(string(b)).NotTo(ContainSubstring("tests/scorecard"))
+	})
+
+	It("adds custom labels and keeps them on regeneration", func() {
+		const customKey = "operators.operatorframework.io.custom.v1"
+		c := bundleCmd{projectName: "memcached-operator", packageName: "memcached-operator", channels: "alpha",
+			overwrite:    true,
+			bundleLabels: []string{"vendor=example.com", customKey + "=foo"}}
+		Expect(c.generateMetadata(cfg, manifestsDir, outputDir)).To(Succeed())
+
+		c.bundleLabels = []string{"version=0.0.1"}
+		Expect(c.generateMetadata(cfg, manifestsDir, outputDir)).To(Succeed())
+
+		By("writing all custom labels to the bundle.Dockerfile")
+		b, err := ioutil.ReadFile(filepath.Join(outputDir, registrybundle.DockerFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(ContainSubstring("LABEL vendor=example.com\n"))
+		Expect(string(b)).To(ContainSubstring("LABEL version=0.0.1\n"))
+		Expect(string(b)).To(ContainSubstring("LABEL " + customKey + "=foo\n"))
+
+		By("writing only namespaced custom labels to bundle metadata")
+		annotations, _, err := registry.FindBundleMetadata(outputDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(annotations).To(HaveKeyWithValue(customKey, "foo"))
+		Expect(annotations).NotTo(HaveKey("vendor"))
+		Expect(annotations).To(HaveKeyWithValue(registrybundle.PackageLabel, "memcached-operator"))
+	})
+
+	It("adds build arg labels and keeps lines added to their section on regeneration", func() {
+		c := bundleCmd{projectName: "memcached-operator", packageName: "memcached-operator", channels: "alpha",
+			overwrite:      true,
+			buildArgLabels: []string{"org.opencontainers.image.version=VERSION", "org.opencontainers.image.revision=VCS_REF"}}
+		Expect(c.generateMetadata(cfg, manifestsDir, outputDir)).To(Succeed())
+
+		dockerfilePath := filepath.Join(outputDir, registrybundle.DockerFile)
+		b, err := ioutil.ReadFile(dockerfilePath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(HavePrefix("FROM scratch\n"))
+		Expect(string(b)).To(ContainSubstring("ARG VCS_REF\nARG VERSION\n" +
+			"LABEL org.opencontainers.image.revision=$VCS_REF\nLABEL org.opencontainers.image.version=$VERSION\n"))
+		Expect(string(b)).To(ContainSubstring("COPY manifests /manifests/\n"))
+		annotations, _, err := registry.FindBundleMetadata(outputDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(annotations).NotTo(HaveKey("org.opencontainers.image.version"))
+
+		By("regenerating the Dockerfile with lines added to the build arg section")
+		const userLines = "ARG BUILD_DATE\nLABEL org.opencontainers.image.created=$BUILD_DATE\n"
+		rest, _ := genutil.SplitDockerfileBuildArgs(string(b))
+		withUserLines := genutil.SetDockerfileBuildArgs(rest, map[string]string{
+			"org.opencontainers.image.version":  "VERSION",
+			"org.opencontainers.image.revision": "VCS_REF",
+		}, userLines)
+		Expect(ioutil.WriteFile(dockerfilePath, []byte(withUserLines), 0644)).To(Succeed())
+		c.buildArgLabels = nil
+		Expect(c.generateMetadata(cfg, manifestsDir, outputDir)).To(Succeed())
+
+		b, err = ioutil.ReadFile(dockerfilePath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal(withUserLines))
+	})
+
+	It("rejects custom labels set by operator-sdk", func() {
+		c := bundleCmd{packageName: "memcached-operator", channels: "alpha",
+			bundleLabels: []string{registrybundle.ChannelsLabel + "=beta"}}
+		Expect(c.validateMetadata(cfg)).To(MatchError(ContainSubstring("cannot be overridden")))
+		c.bundleLabels = []string{"vendor=example.com", "vendor=example.org"}
+		Expect(c.validateMetadata(cfg)).To(MatchError(ContainSubstring("set more than once")))
+		c.bundleLabels = []string{"vendor=example.com"}
+		c.buildArgLabels = []string{"vendor=VENDOR"}
+		Expect(c.validateMetadata(cfg)).To(MatchError(ContainSubstring("both --bundle-label and --build-arg-labels")))
+	})
+
+	It("moves the bundle to a renamed package", func() {
+		c := bundleCmd{projectName: "memcached-operator", packageName: "memcached-operator", channels: "alpha",
+			overwrite: true}
+		Expect(c.generateMetadata(cfg, manifestsDir, outputDir)).To(Succeed())
+		previous, err := c.checkPackageRename(outputDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(previous).To(BeEmpty())
+
+		c.packageName = "acme-memcached-operator"
+		previous, err = c.checkPackageRename(outputDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(previous).To(Equal("memcached-operator"))
+		Expect(c.generateMetadata(cfg, manifestsDir, outputDir)).To(Succeed())
+
+		annotations, _, err := registry.FindBundleMetadata(outputDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(annotations).To(HaveKeyWithValue(registrybundle.PackageLabel, "acme-memcached-operator"))
+	})
+
+	It("rejects a package name that is not a DNS-1123 subdomain", func() {
+		c := bundleCmd{packageName: "Acme_Memcached", channels: "alpha"}
+		Expect(c.validateMetadata(cfg)).To(MatchError(ContainSubstring("invalid --package")))
+	})
+
+	It("validates the generated bundle", func() {
+		c := bundleCmd{projectName: "memcached-operator", packageName: "memcached-operator", channels: "alpha",
+			overwrite: true}
+		Expect(c.generateMetadata(cfg, manifestsDir, outputDir)).To(Succeed())
+		Expect(c.validateBundle(outputDir)).To(Succeed())
+
+		By("failing for a default channel that is not one of the bundle's channels")
+		annotationsPath := filepath.Join(outputDir, registrybundle.MetadataDir, registrybundle.AnnotationsFile)
+		Expect(registry.RewriteAnnotationsYaml(annotationsPath, map[string]string{
+			registrybundle.ChannelDefaultLabel: "stable",
+		})).To(Succeed())
+		err := c.validateBundle(outputDir)
+		Expect(err).To(MatchError(ContainSubstring(`default channel "stable" is not one of channels "alpha"`)))
+		Expect(err).To(MatchError(ContainSubstring(annotationsPath)))
+	})
+
+	It("does not validate a bundle without metadata", func() {
+		c := bundleCmd{}
+		Expect(os.MkdirAll(filepath.Join(outputDir, registrybundle.ManifestsDir), 0755)).To(Succeed())
+		Expect(c.validateBundle(outputDir)).To(Succeed())
+	})
+})
+
+var _ = Describe("Generating a bundle without a PROJECT file", func() {
+	var (
+		wd, tmp, testdataDir string
+		err                  error
+	)
+
+	BeforeEach(func() {
+		wd, err = os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		testdataDir, err = filepath.Abs(filepath.Join("testdata", "standalone"))
+		Expect(err).NotTo(HaveOccurred())
+		tmp, err = ioutil.TempDir("", "generate-bundle-")
+		Expect(err).NotTo(HaveOccurred())
+		// The operator is named after the working directory.
+		projectDir := filepath.Join(tmp, "memcached-operator")
+		Expect(os.Mkdir(projectDir, 0755)).To(Succeed())
+		Expect(os.Chdir(projectDir)).To(Succeed())
+	})
+	AfterEach(func() {
+		Expect(os.Chdir(wd)).To(Succeed())
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	It("generates a bundle from a legacy deploy directory", func() {
+		c := bundleCmd{
+			version:       "0.0.1",
+			deployDir:     filepath.Join(testdataDir, "deploy"),
+			crdsDir:       filepath.Join(testdataDir, "deploy", "crds"),
+			kustomizeDir:  filepath.Join("config", "manifests"),
+			channels:      "alpha",
+			overwrite:     true,
+			overwriteMode: genutil.OverwriteGenerated,
+			quiet:         true,
+		}
+		cfg, err := c.readConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.standalone).To(BeTrue())
+		Expect(c.setDefaults(cfg)).To(Succeed())
+		Expect(c.validateManifests(cfg)).To(Succeed())
+		Expect(c.runManifests(cfg)).To(Succeed())
+		Expect(c.validateMetadata(cfg)).To(Succeed())
+		Expect(c.runMetadata(cfg)).To(Succeed())
+
+		By("writing the same manifests as the golden bundle")
+		goldenDir := filepath.Join(testdataDir, "bundle", registrybundle.ManifestsDir)
+		outputDir := filepath.Join(defaultRootDir, registrybundle.ManifestsDir)
+		csvFile := "memcached-operator.clusterserviceversion.yaml"
+		Expect(readCSV(filepath.Join(outputDir, csvFile))).To(Equal(readCSV(filepath.Join(goldenDir, csvFile))))
+		crdFile := "cache.example.com_memcacheds.yaml"
+		expCRD, actCRD := &apiextv1.CustomResourceDefinition{}, &apiextv1.CustomResourceDefinition{}
+		readObject(filepath.Join(goldenDir, crdFile), expCRD)
+		readObject(filepath.Join(outputDir, crdFile), actCRD)
+		Expect(actCRD).To(Equal(expCRD))
+		saFile := "memcached-operator_v1_serviceaccount.yaml"
+		expSA, actSA := &corev1.ServiceAccount{}, &corev1.ServiceAccount{}
+		readObject(filepath.Join(goldenDir, saFile), expSA)
+		readObject(filepath.Join(outputDir, saFile), actSA)
+		Expect(actSA).To(Equal(expSA))
+		infos, err := ioutil.ReadDir(outputDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(infos).To(HaveLen(3))
+
+		By("writing bundle metadata and a bundle.Dockerfile")
+		Expect(registrybundle.DockerFile).To(BeAnExistingFile())
+		annotations, _, err := registry.FindBundleMetadata(defaultRootDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(annotations).To(HaveKeyWithValue(registrybundle.PackageLabel, "memcached-operator"))
+		Expect(annotations).To(HaveKeyWithValue(registrybundle.ChannelsLabel, "alpha"))
+
+		By("not writing a PROJECT file")
+		Expect("PROJECT").NotTo(BeAnExistingFile())
+	})
+
+	It("requires a version", func() {
+		c := bundleCmd{deployDir: filepath.Join(testdataDir, "deploy"), kustomizeDir: filepath.Join("config", "manifests")}
+		cfg, err := c.readConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.validateManifests(cfg)).To(MatchError(ContainSubstring("--version must be set")))
+	})
+})
+
+// readObject decodes the manifest at path into obj.
+func readObject(path string, obj interface{}) {
+	b, err := ioutil.ReadFile(path)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	ExpectWithOffset(1, yaml.Unmarshal(b, obj)).To(Succeed())
+}
+
+// readCSV decodes the CSV at path, normalizing fields that vary by formatting or operator-sdk build.
+func readCSV(path string) *v1alpha1.ClusterServiceVersion {
+	csv := &v1alpha1.ClusterServiceVersion{}
+	readObject(path, csv)
+	annotations := csv.GetAnnotations()
+	delete(annotations, metricsannotations.BuilderObjectAnnotation)
+	var examples []interface{}
+	ExpectWithOffset(1, json.Unmarshal([]byte(annotations["alm-examples"]), &examples)).To(Succeed())
+	b, err := json.Marshal(examples)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	annotations["alm-examples"] = string(b)
+	return csv
+}
@@ -15,6 +15,7 @@
 package bundle
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 
@@ -22,7 +23,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
-	"github.com/operator-framework/operator-sdk/internal/util/projutil"
+	genutil "github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/generate/internal"
 )
 
 //nolint:maligned
@@ -32,20 +33,48 @@ type bundleCmd struct {
 	metadata  bool
 
 	// Common options.
-	projectName  string
-	version      string
-	inputDir     string
-	outputDir    string
-	kustomizeDir string
-	deployDir    string
-	crdsDir      string
-	stdout       bool
-	quiet        bool
+	projectName            string
+	packageName            string
+	version                string
+	fromVersion            string
+	inputDir               string
+	outputDir              string
+	kustomizeDir           string
+	deployDir              string
+	crdsDir                string
+	apisDir                string
+	iconFile               string
+	minKubeVersion         string
+	skipRange              string
+	skips                  []string
+	skipRelatedImages      bool
+	skipNativeAPIDetection bool
+	skipScorecardConfig    bool
+	useImageDigests        bool
+	skipUnresolvable       bool
+	requireDigests         bool
+	skipTLSVerify          bool
+	useHTTP                bool
+	skipValidation         bool
+	stdout                 bool
+	quiet                  bool
 
 	// Metadata options.
 	channels       string
 	defaultChannel string
+	bundleLabels   []string
+	buildArgLabels []string
 	overwrite      bool
+	forceOverwrite bool
+	noOverwrite    bool
+	// overwriteCSV is true if human-owned fields of an existing CSV are
+	// regenerated from its base instead of preserved.
+	overwriteCSV bool
+	// overwriteMode determines which existing bundle files may be changed.
+	overwriteMode genutil.OverwriteMode
+	// standalone is true if the project has no project config file, in which case
+	// manifests are read only from --deploy-dir and --crds-dir.
+	standalone bool
 }
 
 // NewCmd returns the 'bundle' command configured for the new project layout.
@@ -67,10 +96,13 @@ func NewCmd() *cobra.Command {
 				c.manifests = true
 				c.metadata = true
 			}
+			if err := c.setOverwriteOptions(fs); err != nil {
+				return err
+			}
 
-			cfg, err := projutil.ReadConfig()
+			cfg, err := c.readConfig()
 			if err != nil {
-				return fmt.Errorf("error reading configuration: %v", err)
+				return err
 			}
 
 			if err := c.setDefaults(cfg); err != nil {
@@ -101,6 +133,18 @@ func NewCmd() *cobra.Command {
 					log.Fatalf("Error generating bundle metadata: %v", err)
 				}
 			}
+			if !c.stdout && !c.skipValidation {
+				if err = c.validateBundle(c.getBundleRoot()); err != nil {
+					log.Fatalf("Error validating bundle: %v", err)
+				}
+			}
+
+			// Save an explicitly set package name for later runs.
+			if fs.Changed("package") && !c.standalone {
+				if err = genutil.SavePackageName(cfg, c.packageName); err != nil {
+					log.Fatalf("Error saving package name: %v", err)
+				}
+			}
 
 			return nil
 		},
@@ -115,20 +159,93 @@ func NewCmd() *cobra.Command {
 	return cmd
 }
 
+// setOverwriteOptions sets which existing bundle files and CSV fields may be changed from
+// the overwrite flags set in fs.
+func (c *bundleCmd) setOverwriteOptions(fs *pflag.FlagSet) error {
+	switch {
+	case c.noOverwrite && (fs.Changed("overwrite") && c.overwrite || c.forceOverwrite || c.overwriteCSV):
+		return errors.New("--overwrite, --force-overwrite, and --overwrite-csv-metadata cannot be set with --no-overwrite")
+	case c.forceOverwrite:
+		c.overwriteMode = genutil.OverwriteAll
+	case c.noOverwrite:
+		// Existing metadata must not change either.
+		c.overwrite = false
+		c.overwriteMode = genutil.OverwriteNone
+	default:
+		c.overwriteMode = genutil.OverwriteGenerated
+	}
+	return nil
+}
+
 func (c *bundleCmd) addFlagsTo(fs *pflag.FlagSet) {
 	fs.BoolVar(&c.manifests, "manifests", false, "Generate bundle manifests")
 	fs.BoolVar(&c.metadata, "metadata", false, "Generate bundle metadata and Dockerfile")
 
 	fs.StringVarP(&c.version, "version", "v", "", "Semantic version of the operator in the generated bundle. "+
 		"Only set if creating a new bundle or upgrading your operator")
+	fs.StringVar(&c.fromVersion, "from-version", "", "Semantic version of the operator being upgraded from. "+
+		"Sets the CSV's spec.replaces, and must be less than --version")
+	fs.StringVar(&c.packageName, "package", "", "Name of the package the bundle belongs to, which prefixes "+
+		"the CSV's name and is set as the bundle's package annotation. Must be a DNS-1123 subdomain. "+
+		"Saved in the PROJECT file so later runs keep it. Defaults to the saved package or the project name")
 	fs.StringVar(&c.inputDir, "input-dir", "", "Directory to read an existing bundle from. "+
 		"This directory is the parent of your bundle 'manifests' directory, and different from --deploy-dir")
-	fs.StringVar(&c.outputDir, "output-dir", "", "Directory to write the bundle to")
+	fs.StringVar(&c.outputDir, "output-dir", "", "Directory to write the bundle and its bundle.Dockerfile to")
 	fs.StringVar(&c.deployDir, "deploy-dir", "", "Root directory for operator manifests such as "+
 		"Deployments and RBAC, ex. 'deploy'. This directory is different from that passed to --input-dir")
 	fs.StringVar(&c.crdsDir, "crds-dir", "", "Root directory for CustomResoureDefinition manifests")
+	fs.StringVar(&c.apisDir, "apis-dir", "", "Root directory for Go API type definitions, whose "+
+		"+operator-sdk:csv markers are parsed into CSV spec and status descriptors. "+
+		"Defaults to 'apis' for multigroup projects and 'api' otherwise")
 	fs.StringVar(&c.channels, "channels", "alpha", "A comma-separated list of channels the bundle belongs to")
-	fs.StringVar(&c.defaultChannel, "default-channel", "", "The default channel for the bundle")
+	fs.StringVar(&c.defaultChannel, "default-channel", "", "The default channel for the bundle, "+
+		"which must be one of --channels. Defaults to the first channel")
+	fs.StringArrayVar(&c.bundleLabels, "bundle-label", nil, "A custom LABEL of the form key=value, "+
+		"ex. vendor=example.com, to add to the bundle.Dockerfile. Labels with keys prefixed by "+
+		"'operators.operatorframework.io.' are also added to bundle metadata. May be passed more than once. "+
+		"Labels are saved in the PROJECT file so later runs keep them")
+	fs.StringSliceVar(&c.buildArgLabels, "build-arg-labels", nil, "LABEL's of the form key=ARG, "+
+		"ex. org.opencontainers.image.version=VERSION, to set to the values of build args in the bundle.Dockerfile, "+
+		"which declares each ARG. Labels are saved in the PROJECT file so later runs keep them")
 	fs.BoolVar(&c.overwrite, "overwrite", true, "Overwrite the bundle's metadata and Dockerfile if they exist")
+	fs.BoolVar(&c.forceOverwrite, "force-overwrite", false, "Overwrite bundle files not generated by operator-sdk "+
+		"or modified since they were generated, instead of failing without changing any files")
+	fs.BoolVar(&c.overwriteCSV, "overwrite-csv-metadata", false, "Overwrite manually edited fields of an "+
+		"existing CSV, such as description and maintainers, with those of the kustomize base instead of preserving them")
+	fs.BoolVar(&c.noOverwrite, "no-overwrite", false, "Fail without changing any files if an existing "+
+		"bundle file or metadata would change, and print a summary of those changes")
+	fs.StringVar(&c.iconFile, "icon", "", "Image file, ex. icon.png or icon.svg, to base64-encode "+
+		"and set as the CSV's icon. If not set, the kustomize base's or an existing CSV's icon is kept")
+	fs.StringVar(&c.minKubeVersion, "min-kube-version", "", "Minimum Kubernetes version, ex. 1.16.0, "+
+		"set as the CSV's spec.minKubeVersion and saved in the PROJECT file. If not set, the saved, "+
+		"kustomize base's, or an existing CSV's minKubeVersion is kept")
+	fs.StringVar(&c.skipRange, "skip-range", "", "Semantic version range of operator versions, ex. '>=0.1.0 <0.2.0', "+
+		"that can upgrade directly to this version, set as the CSV's olm.skipRange annotation. "+
+		"If not set, an existing CSV's skip range is kept")
+	fs.StringSliceVar(&c.skips, "skips", nil, "Name of a CSV, ex. memcached-operator.v0.1.1, "+
+		"that this CSV skips, set in the CSV's spec.skips. May be passed more than once. "+
+		"If not set, an existing CSV's skips are kept")
+	fs.BoolVar(&c.skipRelatedImages, "skip-related-images", false, "Do not generate the CSV's spec.relatedImages "+
+		"from manager container images and RELATED_IMAGE_* env vars, ex. if you manage them in your base CSV")
+	fs.BoolVar(&c.skipNativeAPIDetection, "skip-native-api-detection", false, "Do not declare the APIs of "+
+		"manifests that are not built into Kubernetes, ex. ServiceMonitors, in the CSV's "+
+		"spec.customresourcedefinitions.required or spec.nativeAPIs")
+	fs.BoolVar(&c.skipScorecardConfig, "skip-scorecard-config", false, "Do not write the scorecard config "+
+		"to the bundle's tests/scorecard directory, nor its annotations and bundle.Dockerfile LABEL's and COPY, "+
+		"ex. for production bundles")
+	fs.BoolVar(&c.useImageDigests, "use-image-digests", false, "Pin all images in the CSV's install strategy "+
+		"Deployments, RELATED_IMAGE_* env vars, spec.relatedImages, and containerImage annotation to the digests "+
+		"they currently resolve to in their registries, using credentials in the docker config file")
+	fs.BoolVar(&c.skipUnresolvable, "skip-unresolvable", false, "Leave images whose digests cannot be resolved "+
+		"as-is instead of failing. Requires --use-image-digests")
+	fs.BoolVar(&c.requireDigests, "require-digests", false, "Fail instead of leaving images pinned to their tags "+
+		"when their registry exceeds its time budget or failure threshold and its remaining images are not resolved. "+
+		"Requires --use-image-digests")
+	fs.BoolVar(&c.skipTLSVerify, "skip-tls-verify", false, "Skip TLS certificate verification when resolving "+
+		"image digests. Requires --use-image-digests")
+	fs.BoolVar(&c.useHTTP, "use-http", false, "Use plain HTTP when resolving image digests. "+
+		"Requires --use-image-digests")
+	fs.BoolVar(&c.skipValidation, "skip-validation", false, "Do not validate the generated bundle "+
+		"as 'bundle validate' does, which fails generation if the bundle has validation errors")
 	fs.BoolVarP(&c.quiet, "quiet", "q", false, "Run in quiet mode")
 }
@@ -0,0 +1,262 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genutil
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/kubebuilder/pkg/model/config"
+
+	"github.com/operator-framework/operator-sdk/internal/generate/clusterserviceversion/bases"
+	"github.com/operator-framework/operator-sdk/internal/plugins/manifests"
+	"github.com/operator-framework/operator-sdk/internal/util/projutil"
+)
+
+// GetMinKubeVersion returns the minimum Kubernetes version saved in cfg,
+// or an empty string if none is saved.
+func GetMinKubeVersion(cfg *config.Config) (string, error) {
+	mcfg, err := manifests.GetConfig(cfg)
+	if err != nil {
+		return "", err
+	}
+	if mcfg.MinKubeVersion == "" {
+		return "", nil
+	}
+	if err := ValidateMinKubeVersion(mcfg.MinKubeVersion); err != nil {
+		return "", fmt.Errorf("invalid minKubeVersion in project config: %v", err)
+	}
+	return mcfg.MinKubeVersion, nil
+}
+
+// SaveMinKubeVersion saves version in cfg and writes cfg to the project config
+// file if version differs from the saved version. Projects prior to version 3
+// cannot save plugin config, so a warning is logged instead.
+func SaveMinKubeVersion(cfg *config.Config, version string) error {
+	if !cfg.IsV3() {
+		log.Warnf("Project version %s cannot save --min-kube-version, so it must be set on every run", cfg.Version)
+		return nil
+	}
+	mcfg, err := manifests.GetConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if mcfg.MinKubeVersion == version {
+		return nil
+	}
+	mcfg.MinKubeVersion = version
+	if err := manifests.SetConfig(cfg, mcfg); err != nil {
+		return err
+	}
+	if err := projutil.WriteConfig(cfg); err != nil {
+		return fmt.Errorf("error writing project config: %v", err)
+	}
+	return nil
+}
+
+// GetPackageName returns the package name saved in cfg, or the operator name
+// if none is saved. See GetOperatorName.
+func GetPackageName(cfg *config.Config) (string, error) {
+	mcfg, err := manifests.GetConfig(cfg)
+	if err != nil {
+		return "", err
+	}
+	if mcfg.PackageName == "" {
+		return GetOperatorName(cfg)
+	}
+	if err := ValidatePackageName(mcfg.PackageName); err != nil {
+		return "", fmt.Errorf("invalid packageName in project config: %v", err)
+	}
+	return mcfg.PackageName, nil
+}
+
+// SavePackageName saves name in cfg and writes cfg to the project config
+// file if name differs from the saved package name. Projects prior to version 3
+// cannot save plugin config, so a warning is logged instead.
+func SavePackageName(cfg *config.Config, name string) error {
+	if !cfg.IsV3() {
+		log.Warnf("Project version %s cannot save --package, so it must be set on every run", cfg.Version)
+		return nil
+	}
+	mcfg, err := manifests.GetConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if mcfg.PackageName == name {
+		return nil
+	}
+	mcfg.PackageName = name
+	if err := manifests.SetConfig(cfg, mcfg); err != nil {
+		return err
+	}
+	if err := projutil.WriteConfig(cfg); err != nil {
+		return fmt.Errorf("error writing project config: %v", err)
+	}
+	return nil
+}
+
+// GetBundleLabels returns the custom bundle labels saved in cfg.
+func GetBundleLabels(cfg *config.Config) (map[string]string, error) {
+	mcfg, err := manifests.GetConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range mcfg.BundleLabels {
+		if err := ValidateBundleLabel(key, value); err != nil {
+			return nil, fmt.Errorf("invalid bundleLabels in project config: %v", err)
+		}
+	}
+	return mcfg.BundleLabels, nil
+}
+
+// SaveBundleLabels adds labels to the custom bundle labels saved in cfg and writes
+// cfg to the project config file if any label was added or changed. Projects prior
+// to version 3 cannot save plugin config, so a warning is logged instead.
+func SaveBundleLabels(cfg *config.Config, labels map[string]string) error {
+	if !cfg.IsV3() {
+		log.Warnf("Project version %s cannot save --bundle-label, so it must be set on every run", cfg.Version)
+		return nil
+	}
+	mcfg, err := manifests.GetConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if mcfg.BundleLabels == nil {
+		mcfg.BundleLabels = make(map[string]string, len(labels))
+	}
+	changed := false
+	for key, value := range labels {
+		if old, hasKey := mcfg.BundleLabels[key]; !hasKey || old != value {
+			mcfg.BundleLabels[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := manifests.SetConfig(cfg, mcfg); err != nil {
+		return err
+	}
+	if err := projutil.WriteConfig(cfg); err != nil {
+		return fmt.Errorf("error writing project config: %v", err)
+	}
+	return nil
+}
+
+// GetBuildArgLabels returns the build arg labels saved in cfg.
+func GetBuildArgLabels(cfg *config.Config) (map[string]string, error) {
+	mcfg, err := manifests.GetConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for key, arg := range mcfg.BuildArgLabels {
+		if err := ValidateBuildArgLabel(key, arg); err != nil {
+			return nil, fmt.Errorf("invalid buildArgLabels in project config: %v", err)
+		}
+	}
+	return mcfg.BuildArgLabels, nil
+}
+
+// SaveBuildArgLabels adds labels to the build arg labels saved in cfg and writes
+// cfg to the project config file if any label was added or changed. Projects prior
+// to version 3 cannot save plugin config, so a warning is logged instead.
+func SaveBuildArgLabels(cfg *config.Config, labels map[string]string) error {
+	if !cfg.IsV3() {
+		log.Warnf("Project version %s cannot save --build-arg-labels, so it must be set on every run", cfg.Version)
+		return nil
+	}
+	mcfg, err := manifests.GetConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if mcfg.BuildArgLabels == nil {
+		mcfg.BuildArgLabels = make(map[string]string, len(labels))
+	}
+	changed := false
+	for key, arg := range labels {
+		if old, hasKey := mcfg.BuildArgLabels[key]; !hasKey || old != arg {
+			mcfg.BuildArgLabels[key] = arg
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := manifests.SetConfig(cfg, mcfg); err != nil {
+		return err
+	}
+	if err := projutil.WriteConfig(cfg); err != nil {
+		return fmt.Errorf("error writing project config: %v", err)
+	}
+	return nil
+}
+
+// GetUIMetadata returns the CSV UI metadata saved in cfg. Projects prior to version 3
+// cannot save plugin config, so nil is returned for them.
+func GetUIMetadata(cfg *config.Config) (*bases.UIMetadata, error) {
+	if !cfg.IsV3() {
+		return nil, nil
+	}
+	mcfg, err := manifests.GetConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	meta := &bases.UIMetadata{
+		DisplayName: mcfg.DisplayName,
+		Description: mcfg.Description,
+		Maintainers: mcfg.Maintainers,
+		Keywords:    mcfg.Keywords,
+		Maturity:    mcfg.Maturity,
+	}
+	if mcfg.Provider != nil {
+		meta.Provider = *mcfg.Provider
+	}
+	return meta, nil
+}
+
+// SaveUIMetadata saves meta in cfg and writes cfg to the project config file
+// if meta differs from the saved UI metadata. Nothing is saved if meta is nil.
+func SaveUIMetadata(cfg *config.Config, meta *bases.UIMetadata) error {
+	if meta == nil {
+		return nil
+	}
+	saved, err := GetUIMetadata(cfg)
+	if err != nil || saved == nil || reflect.DeepEqual(saved, meta) {
+		return err
+	}
+	mcfg, err := manifests.GetConfig(cfg)
+	if err != nil {
+		return err
+	}
+	mcfg.DisplayName = meta.DisplayName
+	mcfg.Description = meta.Description
+	mcfg.Provider = nil
+	if meta.Provider != (v1alpha1.AppLink{}) {
+		provider := meta.Provider
+		mcfg.Provider = &provider
+	}
+	mcfg.Maintainers = meta.Maintainers
+	mcfg.Keywords = meta.Keywords
+	mcfg.Maturity = meta.Maturity
+	if err := manifests.SetConfig(cfg, mcfg); err != nil {
+		return err
+	}
+	if err := projutil.WriteConfig(cfg); err != nil {
+		return fmt.Errorf("error writing project config: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genutil
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	registrybundle "github.com/operator-framework/operator-registry/pkg/lib/bundle"
+	"sigs.k8s.io/kubebuilder/pkg/model/config"
+
+	"github.com/operator-framework/operator-sdk/internal/generate/clusterserviceversion/bases"
+	"github.com/operator-framework/operator-sdk/internal/util/projutil"
+)
+
+var _ = Describe("UIMetadata project config", func() {
+	var (
+		wd, tmp string
+		cfg     *config.Config
+		err     error
+	)
+
+	BeforeEach(func() {
+		wd, err = os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		tmp, err = ioutil.TempDir("", "genutil-config-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(tmp)).To(Succeed())
+		cfg = &config.Config{Version: config.Version3Alpha, ProjectName: "memcached-operator"}
+	})
+	AfterEach(func() {
+		Expect(os.Chdir(wd)).To(Succeed())
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	It("returns empty UI metadata if none is saved", func() {
+		Expect(GetUIMetadata(cfg)).To(Equal(&bases.UIMetadata{}))
+	})
+	It("saves UI metadata to the project config file", func() {
+		meta := &bases.UIMetadata{
+			DisplayName: "Memcached Operator",
+			Provider:    v1alpha1.AppLink{Name: "Example Inc.", URL: "https://example.com"},
+			Maintainers: []v1alpha1.Maintainer{{Name: "Jane Doe", Email: "jane@example.com"}},
+			Keywords:    []string{"memcached"},
+			Maturity:    "beta",
+		}
+		Expect(SaveMinKubeVersion(cfg, "1.16.0")).To(Succeed())
+		Expect(SaveUIMetadata(cfg, meta)).To(Succeed())
+
+		saved, err := projutil.ReadConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(GetUIMetadata(saved)).To(Equal(meta))
+		Expect(GetMinKubeVersion(saved)).To(Equal("1.16.0"))
+	})
+	It("does not write the project config file if UI metadata is unchanged", func() {
+		Expect(SaveUIMetadata(cfg, &bases.UIMetadata{})).To(Succeed())
+		Expect(projutil.HasProjectFile()).To(BeFalse())
+	})
+	It("does not save UI metadata for a project version prior to 3", func() {
+		cfg.Version = config.Version2
+		Expect(GetUIMetadata(cfg)).To(BeNil())
+		Expect(SaveUIMetadata(cfg, &bases.UIMetadata{DisplayName: "Memcached Operator"})).To(Succeed())
+		Expect(projutil.HasProjectFile()).To(BeFalse())
+	})
+})
+
+var _ = Describe("BundleLabels project config", func() {
+	var (
+		wd, tmp string
+		cfg     *config.Config
+		err     error
+	)
+
+	BeforeEach(func() {
+		wd, err = os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		tmp, err = ioutil.TempDir("", "genutil-config-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(tmp)).To(Succeed())
+		cfg = &config.Config{Version: config.Version3Alpha, ProjectName: "memcached-operator"}
+	})
+	AfterEach(func() {
+		Expect(os.Chdir(wd)).To(Succeed())
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	It("returns no labels if none are saved", func() {
+		Expect(GetBundleLabels(cfg)).To(BeEmpty())
+	})
+	It("adds labels to those saved in the project config file", func() {
+		Expect(SaveBundleLabels(cfg, map[string]string{"vendor": "example.com", "version": "0.0.1"})).To(Succeed())
+		Expect(SaveBundleLabels(cfg, map[string]string{"version": "0.0.2"})).To(Succeed())
+
+		saved, err := projutil.ReadConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(GetBundleLabels(saved)).To(Equal(map[string]string{"vendor": "example.com", "version": "0.0.2"}))
+	})
+	It("returns an error if a saved label is invalid", func() {
+		Expect(SaveBundleLabels(cfg, map[string]string{registrybundle.PackageLabel: "foo"})).To(Succeed())
+		_, err = GetBundleLabels(cfg)
+		Expect(err).To(MatchError(ContainSubstring("invalid bundleLabels in project config")))
+	})
+	It("does not save labels for a project version prior to 3", func() {
+		cfg.Version = config.Version2
+		Expect(SaveBundleLabels(cfg, map[string]string{"vendor": "example.com"})).To(Succeed())
+		Expect(GetBundleLabels(cfg)).To(BeEmpty())
+		Expect(projutil.HasProjectFile()).To(BeFalse())
+	})
+})
+
+var _ = Describe("BuildArgLabels project config", func() {
+	var (
+		wd, tmp string
+		cfg     *config.Config
+		err     error
+	)
+
+	BeforeEach(func() {
+		wd, err = os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		tmp, err = ioutil.TempDir("", "genutil-config-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(tmp)).To(Succeed())
+		cfg = &config.Config{Version: config.Version3Alpha, ProjectName: "memcached-operator"}
+	})
+	AfterEach(func() {
+		Expect(os.Chdir(wd)).To(Succeed())
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	It("returns no build arg labels if none are saved", func() {
+		Expect(GetBuildArgLabels(cfg)).To(BeEmpty())
+	})
+	It("adds labels to those saved in the project config file", func() {
+		Expect(SaveBuildArgLabels(cfg, map[string]string{"vcs-ref": "VCS_REF", "version": "VERSION"})).To(Succeed())
+		Expect(SaveBuildArgLabels(cfg, map[string]string{"version": "OPERATOR_VERSION"})).To(Succeed())
+
+		saved, err := projutil.ReadConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(GetBuildArgLabels(saved)).To(Equal(map[string]string{"vcs-ref": "VCS_REF", "version": "OPERATOR_VERSION"}))
+	})
+	It("returns an error if a saved label is invalid", func() {
+		Expect(SaveBuildArgLabels(cfg, map[string]string{"vcs-ref": "$VCS_REF"})).To(Succeed())
+		_, err = GetBuildArgLabels(cfg)
+		Expect(err).To(MatchError(ContainSubstring("invalid buildArgLabels in project config")))
+	})
+	It("does not save labels for a project version prior to 3", func() {
+		cfg.Version = config.Version2
+		Expect(SaveBuildArgLabels(cfg, map[string]string{"vcs-ref": "VCS_REF"})).To(Succeed())
+		Expect(GetBuildArgLabels(cfg)).To(BeEmpty())
+		Expect(projutil.HasProjectFile()).To(BeFalse())
+	})
+})
+
+var _ = Describe("MinKubeVersion project config", func() {
+	var (
+		wd, tmp string
+		cfg     *config.Config
+		err     error
+	)
+
+	BeforeEach(func() {
+		wd, err = os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		tmp, err = ioutil.TempDir("", "genutil-config-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(tmp)).To(Succeed())
+		cfg = &config.Config{Version: config.Version3Alpha, ProjectName: "memcached-operator"}
+	})
+	AfterEach(func() {
+		Expect(os.Chdir(wd)).To(Succeed())
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	It("returns an empty version if none is saved", func() {
+		Expect(GetMinKubeVersion(cfg)).To(BeEmpty())
+	})
+	It("saves a version to the project config file", func() {
+		Expect(SaveMinKubeVersion(cfg, "1.16.0")).To(Succeed())
+		Expect(GetMinKubeVersion(cfg)).To(Equal("1.16.0"))
+
+		saved, err := projutil.ReadConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(GetMinKubeVersion(saved)).To(Equal("1.16.0"))
+	})
+	It("returns an error if the saved version is invalid", func() {
+		Expect(SaveMinKubeVersion(cfg, "v1.16.0")).To(Succeed())
+		_, err = GetMinKubeVersion(cfg)
+		Expect(err).To(MatchError(ContainSubstring("invalid minKubeVersion in project config")))
+	})
+	It("does not save a version for a project version prior to 3", func() {
+		cfg.Version = config.Version2
+		Expect(SaveMinKubeVersion(cfg, "1.16.0")).To(Succeed())
+		Expect(GetMinKubeVersion(cfg)).To(BeEmpty())
+		Expect(projutil.HasProjectFile()).To(BeFalse())
+	})
+})
+
+var _ = Describe("PackageName project config", func() {
+	var (
+		wd, tmp string
+		cfg     *config.Config
+		err     error
+	)
+
+	BeforeEach(func() {
+		wd, err = os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		tmp, err = ioutil.TempDir("", "genutil-config-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(tmp)).To(Succeed())
+		cfg = &config.Config{Version: config.Version3Alpha, ProjectName: "internal-memcached"}
+	})
+	AfterEach(func() {
+		Expect(os.Chdir(wd)).To(Succeed())
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	It("returns the project name if no package name is saved", func() {
+		Expect(GetPackageName(cfg)).To(Equal("internal-memcached"))
+	})
+	It("saves the package name to the project config file", func() {
+		Expect(SavePackageName(cfg, "acme-memcached-operator")).To(Succeed())
+
+		saved, err := projutil.ReadConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(GetPackageName(saved)).To(Equal("acme-memcached-operator"))
+	})
+	It("returns an error if the saved package name is invalid", func() {
+		Expect(SavePackageName(cfg, "Acme_Memcached")).To(Succeed())
+		_, err = GetPackageName(cfg)
+		Expect(err).To(MatchError(ContainSubstring("invalid packageName in project config")))
+	})
+	It("does not save the package name for a project version prior to 3", func() {
+		cfg.Version = config.Version2
+		Expect(SavePackageName(cfg, "acme-memcached-operator")).To(Succeed())
+		Expect(GetPackageName(cfg)).To(Equal("internal-memcached"))
+		Expect(projutil.HasProjectFile()).To(BeFalse())
+	})
+})
@@ -0,0 +1,314 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	registrybundle "github.com/operator-framework/operator-registry/pkg/lib/bundle"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// GeneratedFilesFile is the name of the file in a bundle's metadata directory that lists
+// files generated by operator-sdk, so the list travels with the bundle.
+const GeneratedFilesFile = "generated-files.yaml"
+
+// OverwriteMode determines which existing files SyncGeneratedFiles may change.
+type OverwriteMode int
+
+const (
+	// OverwriteGenerated changes files listed in GeneratedFilesFile that have not been
+	// modified since they were generated, and refuses to change any other existing file.
+	OverwriteGenerated OverwriteMode = iota
+	// OverwriteAll changes any existing file.
+	OverwriteAll
+	// OverwriteNone refuses to change any existing file.
+	OverwriteNone
+)
+
+// FileOp is the kind of change made to a file.
+type FileOp string
+
+// Kinds of file changes, abbreviated as in `git status --short`.
+const (
+	FileAdded    FileOp = "A"
+	FileModified FileOp = "M"
+	FileDeleted  FileOp = "D"
+)
+
+// FileChange is a change to the file at Path, relative to a bundle's root.
+type FileChange struct {
+	Op   FileOp
+	Path string
+}
+
+func (c FileChange) String() string {
+	return fmt.Sprintf("%s %s", c.Op, c.Path)
+}
+
+// generatedFiles is the contents of GeneratedFilesFile.
+type generatedFiles struct {
+	Files []generatedFile `json:"files"`
+}
+
+// generatedFile is a generated file's path relative to a bundle's root, and the sha256 sum
+// of its contents when it was generated.
+type generatedFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+const generatedFilesHeader = "# Files generated by operator-sdk. This file is managed by operator-sdk; do not edit.\n"
+
+// SyncGeneratedFiles copies all files in srcDir to dstDir as permitted by mode, removes files
+// generated by a previous run that are no longer in srcDir, and records the copied files in
+// dstDir's metadata. Existing files in mergedPaths, like a CSV whose manually edited fields are
+// merged into the generated CSV, may be changed or removed in OverwriteGenerated mode if they were
+// generated previously, even if they were modified since. If mode does not permit a change, nothing is changed
+// and an error listing all such changes is returned. Otherwise the changes made are returned.
+// If dstDir has no list of generated files, ex. a bundle generated before files were listed, existing files
+// in srcDir are treated as generated and unmodified.
+func SyncGeneratedFiles(srcDir, dstDir string, mode OverwriteMode, mergedPaths ...string) ([]FileChange, error) {
+	listPath := filepath.Join(dstDir, registrybundle.MetadataDir, GeneratedFilesFile)
+	generated, err := readGeneratedFiles(listPath)
+	if err != nil {
+		return nil, err
+	}
+	bootstrap := generated == nil
+	if bootstrap {
+		generated = map[string]string{}
+	}
+	merged := map[string]bool{}
+	for _, path := range mergedPaths {
+		merged[filepath.ToSlash(path)] = true
+	}
+
+	// Collect changes from srcDir to dstDir.
+	contents := map[string][]byte{}
+	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if contents[filepath.ToSlash(rel)], err = ioutil.ReadFile(path); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var changes []FileChange
+	var refused []string
+	for path, b := range contents {
+		existing, err := ioutil.ReadFile(filepath.Join(dstDir, filepath.FromSlash(path)))
+		switch {
+		case os.IsNotExist(err):
+			changes = append(changes, FileChange{Op: FileAdded, Path: path})
+			continue
+		case err != nil:
+			return nil, err
+		}
+		if bootstrap {
+			generated[path] = hashContents(existing)
+		}
+		if bytes.Equal(existing, b) {
+			continue
+		}
+		changes = append(changes, FileChange{Op: FileModified, Path: path})
+		if reason := checkOverwrite(mode, generated, path, existing, merged[path]); reason != "" {
+			refused = append(refused, fmt.Sprintf("%s: %s", path, reason))
+		}
+	}
+	for path, sum := range generated {
+		if _, hasPath := contents[path]; hasPath {
+			continue
+		}
+		existing, err := ioutil.ReadFile(filepath.Join(dstDir, filepath.FromSlash(path)))
+		switch {
+		case os.IsNotExist(err):
+			continue
+		case err != nil:
+			return nil, err
+		}
+		// A stale file that was modified since it was generated is now owned by the user,
+		// unless its contents were merged into a generated file.
+		if mode == OverwriteGenerated && sum != hashContents(existing) && !merged[path] {
+			log.Warnf("Not removing %s, which is no longer generated but was modified since it was", path)
+			continue
+		}
+		changes = append(changes, FileChange{Op: FileDeleted, Path: path})
+		if reason := checkOverwrite(mode, generated, path, existing, merged[path]); reason != "" {
+			refused = append(refused, fmt.Sprintf("%s: %s", path, reason))
+		}
+	}
+	sortFileChanges(changes)
+
+	if len(refused) != 0 {
+		sort.Strings(refused)
+		hint := "set --force-overwrite to change them anyway"
+		if mode == OverwriteNone {
+			hint = "unset --no-overwrite to change them"
+		}
+		return nil, fmt.Errorf("refusing to change existing files in %s, %s:\n%s\nchanges:\n%s",
+			dstDir, hint, strings.Join(refused, "\n"), FormatFileChanges(changes))
+	}
+
+	// Apply changes and record all files in srcDir as generated.
+	for _, c := range changes {
+		path := filepath.Join(dstDir, filepath.FromSlash(c.Path))
+		switch c.Op {
+		case FileAdded, FileModified:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return nil, err
+			}
+			if err := ioutil.WriteFile(path, contents[c.Path], 0666); err != nil {
+				return nil, err
+			}
+		case FileDeleted:
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+			if err := removeEmptyDirs(dstDir, filepath.Dir(path)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if bootstrap && len(generated) != 0 {
+		log.Infof("Treated existing files in %s as generated, since it had no list of generated files; "+
+			"listed them in %s", dstDir, listPath)
+	}
+	sums := make(map[string]string, len(contents))
+	for path, b := range contents {
+		sums[path] = hashContents(b)
+	}
+	if err := writeGeneratedFiles(listPath, sums); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// checkOverwrite returns the reason mode does not permit changing the existing file at path,
+// or an empty string if it does.
+func checkOverwrite(mode OverwriteMode, generated map[string]string, path string, existing []byte,
+	merged bool) string {
+
+	switch mode {
+	case OverwriteAll:
+		return ""
+	case OverwriteNone:
+		return "file would change"
+	}
+	sum, isGenerated := generated[path]
+	switch {
+	case !isGenerated:
+		return "not generated by operator-sdk"
+	case merged || sum == hashContents(existing):
+		return ""
+	}
+	return "modified since it was generated"
+}
+
+// FormatFileChanges returns a summary of changes, one indented change per line.
+func FormatFileChanges(changes []FileChange) string {
+	lines := make([]string, len(changes))
+	for i, c := range changes {
+		lines[i] = "  " + c.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func sortFileChanges(changes []FileChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+}
+
+func hashContents(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// removeEmptyDirs removes dir and its parents up to but excluding root while they are empty,
+// so removing a directory's last generated file does not leave the directory behind.
+func removeEmptyDirs(root, dir string) error {
+	for ; ; dir = filepath.Dir(dir) {
+		if rel, err := filepath.Rel(root, dir); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			return nil
+		}
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		if len(infos) != 0 {
+			return nil
+		}
+		if err := os.Remove(dir); err != nil {
+			return err
+		}
+	}
+}
+
+// readGeneratedFiles returns the sha256 sums of generated files keyed by path from the list at listPath.
+// A nil map is returned if listPath does not exist.
+func readGeneratedFiles(listPath string) (map[string]string, error) {
+	sums := map[string]string{}
+	b, err := ioutil.ReadFile(listPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	list := generatedFiles{}
+	if err := yaml.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("error unmarshalling generated files list %s: %v", listPath, err)
+	}
+	for _, f := range list.Files {
+		sums[f.Path] = f.SHA256
+	}
+	return sums, nil
+}
+
+// writeGeneratedFiles writes sums as a list of generated files to listPath.
+func writeGeneratedFiles(listPath string, sums map[string]string) error {
+	list := generatedFiles{Files: []generatedFile{}}
+	for path, sum := range sums {
+		list.Files = append(list.Files, generatedFile{Path: path, SHA256: sum})
+	}
+	sort.Slice(list.Files, func(i, j int) bool {
+		return list.Files[i].Path < list.Files[j].Path
+	})
+	b, err := yaml.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(listPath), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(listPath, append([]byte(generatedFilesHeader), b...), 0666)
+}
@@ -0,0 +1,212 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SyncGeneratedFiles", func() {
+	const csvPath = "manifests/memcached-operator.clusterserviceversion.yaml"
+
+	var (
+		tmp, srcDir, dstDir string
+		err                 error
+	)
+
+	writeFile := func(dir, path, contents string) {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		ExpectWithOffset(1, os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		ExpectWithOffset(1, ioutil.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+	}
+	readFile := func(path string) string {
+		b, err := ioutil.ReadFile(filepath.Join(dstDir, filepath.FromSlash(path)))
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return string(b)
+	}
+	// generate stages files as a generator would and syncs them to dstDir.
+	generate := func(mode OverwriteMode, files map[string]string) ([]FileChange, error) {
+		ExpectWithOffset(1, os.RemoveAll(srcDir)).To(Succeed())
+		for path, contents := range files {
+			writeFile(srcDir, path, contents)
+		}
+		return SyncGeneratedFiles(srcDir, dstDir, mode, csvPath)
+	}
+
+	BeforeEach(func() {
+		tmp, err = ioutil.TempDir("", "genutil-files-")
+		Expect(err).NotTo(HaveOccurred())
+		srcDir = filepath.Join(tmp, "staging")
+		dstDir = filepath.Join(tmp, "bundle")
+
+		By("generating an initial bundle")
+		changes, err := generate(OverwriteGenerated, map[string]string{
+			csvPath:                       "csv: v1\n",
+			"manifests/crd.yaml":          "crd: v1\n",
+			"tests/scorecard/config.yaml": "config: v1\n",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(Equal([]FileChange{
+			{Op: FileAdded, Path: "manifests/crd.yaml"},
+			{Op: FileAdded, Path: csvPath},
+			{Op: FileAdded, Path: "tests/scorecard/config.yaml"},
+		}))
+		Expect(readFile(filepath.Join("metadata", GeneratedFilesFile))).To(ContainSubstring("path: manifests/crd.yaml\n"))
+
+		By("adding a file not generated by operator-sdk")
+		writeFile(dstDir, "manifests/foreign.yaml", "foreign: v1\n")
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	Context("by default", func() {
+		It("regenerates unmodified generated files and removes stale ones", func() {
+			changes, err := generate(OverwriteGenerated, map[string]string{
+				csvPath:              "csv: v2\n",
+				"manifests/crd.yaml": "crd: v2\n",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(Equal([]FileChange{
+				{Op: FileModified, Path: "manifests/crd.yaml"},
+				{Op: FileModified, Path: csvPath},
+				{Op: FileDeleted, Path: "tests/scorecard/config.yaml"},
+			}))
+			Expect(readFile("manifests/crd.yaml")).To(Equal("crd: v2\n"))
+			Expect(readFile("manifests/foreign.yaml")).To(Equal("foreign: v1\n"))
+			Expect(filepath.Join(dstDir, "tests", "scorecard", "config.yaml")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(dstDir, "tests")).NotTo(BeADirectory())
+		})
+		It("regenerates a modified CSV, whose manual edits are merged", func() {
+			writeFile(dstDir, csvPath, "csv: edited\n")
+			_, err := generate(OverwriteGenerated, map[string]string{csvPath: "csv: v2\n", "manifests/crd.yaml": "crd: v1\n"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(readFile(csvPath)).To(Equal("csv: v2\n"))
+		})
+		It("removes a modified CSV merged into the CSV of a renamed package", func() {
+			const renamedCSVPath = "manifests/acme-memcached-operator.clusterserviceversion.yaml"
+			writeFile(dstDir, csvPath, "csv: edited\n")
+			Expect(os.RemoveAll(srcDir)).To(Succeed())
+			writeFile(srcDir, renamedCSVPath, "csv: v2\n")
+			writeFile(srcDir, "manifests/crd.yaml", "crd: v1\n")
+			changes, err := SyncGeneratedFiles(srcDir, dstDir, OverwriteGenerated, renamedCSVPath, csvPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(ContainElement(FileChange{Op: FileAdded, Path: renamedCSVPath}))
+			Expect(changes).To(ContainElement(FileChange{Op: FileDeleted, Path: csvPath}))
+			Expect(filepath.Join(dstDir, filepath.FromSlash(csvPath))).NotTo(BeAnExistingFile())
+		})
+		It("refuses to change a foreign file or a generated file modified since it was generated", func() {
+			writeFile(dstDir, "manifests/crd.yaml", "crd: edited\n")
+			_, err := generate(OverwriteGenerated, map[string]string{
+				csvPath:                  "csv: v2\n",
+				"manifests/crd.yaml":     "crd: v2\n",
+				"manifests/foreign.yaml": "foreign: v2\n",
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("manifests/crd.yaml: modified since it was generated"))
+			Expect(err.Error()).To(ContainSubstring("manifests/foreign.yaml: not generated by operator-sdk"))
+			Expect(err.Error()).To(ContainSubstring("--force-overwrite"))
+
+			By("not changing any file")
+			Expect(readFile(csvPath)).To(Equal("csv: v1\n"))
+			Expect(readFile("manifests/crd.yaml")).To(Equal("crd: edited\n"))
+			Expect(readFile("manifests/foreign.yaml")).To(Equal("foreign: v1\n"))
+		})
+		It("treats existing files as generated in a bundle without a list of generated files", func() {
+			Expect(os.Remove(filepath.Join(dstDir, "metadata", GeneratedFilesFile))).To(Succeed())
+			changes, err := generate(OverwriteGenerated, map[string]string{
+				csvPath:              "csv: v2\n",
+				"manifests/crd.yaml": "crd: v2\n",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(Equal([]FileChange{
+				{Op: FileModified, Path: "manifests/crd.yaml"},
+				{Op: FileModified, Path: csvPath},
+			}))
+			Expect(readFile("manifests/crd.yaml")).To(Equal("crd: v2\n"))
+			Expect(readFile("tests/scorecard/config.yaml")).To(Equal("config: v1\n"))
+			Expect(readFile(filepath.Join("metadata", GeneratedFilesFile))).To(ContainSubstring("path: manifests/crd.yaml\n"))
+
+			By("protecting files modified after the list is written")
+			writeFile(dstDir, "manifests/crd.yaml", "crd: edited\n")
+			_, err = generate(OverwriteGenerated, map[string]string{csvPath: "csv: v2\n", "manifests/crd.yaml": "crd: v3\n"})
+			Expect(err).To(MatchError(ContainSubstring("manifests/crd.yaml: modified since it was generated")))
+		})
+		It("keeps a stale file modified since it was generated", func() {
+			writeFile(dstDir, "tests/scorecard/config.yaml", "config: edited\n")
+			changes, err := generate(OverwriteGenerated, map[string]string{csvPath: "csv: v1\n", "manifests/crd.yaml": "crd: v1\n"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(BeEmpty())
+			Expect(readFile("tests/scorecard/config.yaml")).To(Equal("config: edited\n"))
+			Expect(readFile(filepath.Join("metadata", GeneratedFilesFile))).NotTo(ContainSubstring("tests/scorecard"))
+		})
+	})
+
+	Context("with --force-overwrite", func() {
+		It("changes any existing file", func() {
+			writeFile(dstDir, "manifests/crd.yaml", "crd: edited\n")
+			changes, err := generate(OverwriteAll, map[string]string{
+				csvPath:                  "csv: v1\n",
+				"manifests/crd.yaml":     "crd: v2\n",
+				"manifests/foreign.yaml": "foreign: v2\n",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(Equal([]FileChange{
+				{Op: FileModified, Path: "manifests/crd.yaml"},
+				{Op: FileModified, Path: "manifests/foreign.yaml"},
+				{Op: FileDeleted, Path: "tests/scorecard/config.yaml"},
+			}))
+			Expect(readFile("manifests/crd.yaml")).To(Equal("crd: v2\n"))
+			Expect(readFile("manifests/foreign.yaml")).To(Equal("foreign: v2\n"))
+			Expect(readFile(filepath.Join("metadata", GeneratedFilesFile))).To(ContainSubstring("path: manifests/foreign.yaml\n"))
+		})
+	})
+
+	Context("with --no-overwrite", func() {
+		It("succeeds if only new files are added", func() {
+			changes, err := generate(OverwriteNone, map[string]string{
+				csvPath:                       "csv: v1\n",
+				"manifests/crd.yaml":          "crd: v1\n",
+				"manifests/other-crd.yaml":    "crd: v1\n",
+				"tests/scorecard/config.yaml": "config: v1\n",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(Equal([]FileChange{{Op: FileAdded, Path: "manifests/other-crd.yaml"}}))
+		})
+		It("fails with a summary of changes if any existing file would change", func() {
+			_, err := generate(OverwriteNone, map[string]string{
+				csvPath:                  "csv: v2\n",
+				"manifests/crd.yaml":     "crd: v1\n",
+				"manifests/foreign.yaml": "foreign: v2\n",
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("unset --no-overwrite"))
+			Expect(err.Error()).To(ContainSubstring("  M " + csvPath + "\n"))
+			Expect(err.Error()).To(ContainSubstring("  M manifests/foreign.yaml\n"))
+			Expect(err.Error()).To(ContainSubstring("  D tests/scorecard/config.yaml"))
+			Expect(err.Error()).NotTo(ContainSubstring("manifests/crd.yaml"))
+
+			By("not changing any file")
+			Expect(readFile(csvPath)).To(Equal("csv: v1\n"))
+			Expect(readFile("manifests/foreign.yaml")).To(Equal("foreign: v1\n"))
+			Expect(readFile("tests/scorecard/config.yaml")).To(Equal("config: v1\n"))
+		})
+	})
+})
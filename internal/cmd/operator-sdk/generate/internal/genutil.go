@@ -24,12 +24,16 @@ import (
 	"strings"
 
 	"github.com/blang/semver"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/kubebuilder/pkg/model/config"
 	"sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/registry"
 )
 
 // ValidateVersion returns an error if version is not a strict semantic version.
@@ -46,6 +50,91 @@ func ValidateVersion(version string) error {
 	return nil
 }
 
+// ValidateFromVersion returns an error if fromVersion, the version of the operator
+// being upgraded from, is not a strict semantic version less than version.
+func ValidateFromVersion(fromVersion, version string) error {
+	if err := ValidateVersion(fromVersion); err != nil {
+		return err
+	}
+	if version == "" {
+		return errors.New("--version must be set if --from-version is set")
+	}
+	from, err := semver.Parse(fromVersion)
+	if err != nil {
+		return err
+	}
+	to, err := semver.Parse(version)
+	if err != nil {
+		return err
+	}
+	if from.GE(to) {
+		return fmt.Errorf("--from-version %s must be less than --version %s", fromVersion, version)
+	}
+	return nil
+}
+
+// ValidateMinKubeVersion returns an error if version, a minimum Kubernetes
+// version, is not a strict semantic version without a leading "v".
+func ValidateMinKubeVersion(version string) error {
+	if strings.HasPrefix(version, "v") {
+		return fmt.Errorf("minimum Kubernetes version %s must not have a leading \"v\", ex. %s", version, version[1:])
+	}
+	return ValidateVersion(version)
+}
+
+// ValidateSkipRange returns an error if skipRange is not a semantic version range,
+// or if it contains version, since a CSV cannot skip itself.
+func ValidateSkipRange(skipRange, version string) error {
+	r, err := semver.ParseRange(skipRange)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid semantic version range: %v", skipRange, err)
+	}
+	if version == "" {
+		return nil
+	}
+	v, err := semver.Parse(version)
+	if err != nil {
+		return err
+	}
+	if r(v) {
+		return fmt.Errorf("%q must not contain --version %s", skipRange, version)
+	}
+	return nil
+}
+
+// ValidateSkips returns an error if any of skips is not a CSV name of the form
+// <name>.v<version>.
+func ValidateSkips(skips []string) error {
+	for _, skip := range skips {
+		if _, _, err := registry.ParseCSVName(skip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidatePackageName returns an error if name is not a valid package name,
+// which must be a DNS-1123 subdomain.
+func ValidatePackageName(name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+		return fmt.Errorf("package name %q is invalid: %s", name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// CheckCSVExists returns an error if the manifests in dir do not contain
+// a ClusterServiceVersion named csvName.
+func CheckCSVExists(dir, csvName string) error {
+	b, err := apimanifests.GetBundleFromDir(dir)
+	if err != nil {
+		return fmt.Errorf("error reading manifests in %s: %v", dir, err)
+	}
+	if b.CSV == nil || b.CSV.GetName() != csvName {
+		return fmt.Errorf("ClusterServiceVersion %s not found in %s", csvName, dir)
+	}
+	return nil
+}
+
 // IsPipeReader returns true if stdin is an open pipe, i.e. the caller can
 // accept input from stdin.
 func IsPipeReader() bool {
@@ -80,6 +169,14 @@ func WriteObjectsToFiles(dir string, objs ...controllerutil.Object) error {
 			fileName = makeCRDFileName(t.Spec.Group, t.Spec.Names.Plural)
 		case *apiextv1beta1.CustomResourceDefinition:
 			fileName = makeCRDFileName(t.Spec.Group, t.Spec.Names.Plural)
+		case *unstructured.Unstructured:
+			if t.GetKind() == "CustomResourceDefinition" {
+				group, _, _ := unstructured.NestedString(t.Object, "spec", "group")
+				plural, _, _ := unstructured.NestedString(t.Object, "spec", "names", "plural")
+				fileName = makeCRDFileName(group, plural)
+			} else {
+				fileName = makeObjectFileName(t)
+			}
 		default:
 			fileName = makeObjectFileName(t)
 		}
@@ -0,0 +1,100 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genutil
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ValidateFromVersion", func() {
+	It("accepts a version less than --version", func() {
+		Expect(ValidateFromVersion("0.0.1", "0.0.2")).To(Succeed())
+		Expect(ValidateFromVersion("0.9.0", "1.0.0-alpha")).To(Succeed())
+	})
+	It("returns an error for a version equal to or greater than --version", func() {
+		Expect(ValidateFromVersion("0.0.2", "0.0.2")).To(MatchError("--from-version 0.0.2 must be less than --version 0.0.2"))
+		Expect(ValidateFromVersion("0.1.0", "0.0.2")).To(MatchError("--from-version 0.1.0 must be less than --version 0.0.2"))
+	})
+	It("returns an error if --version is not set", func() {
+		Expect(ValidateFromVersion("0.0.1", "")).To(MatchError("--version must be set if --from-version is set"))
+	})
+	It("returns an error for an invalid version", func() {
+		Expect(ValidateFromVersion("v0.0.1", "0.0.2")).To(MatchError(ContainSubstring("not a valid semantic version")))
+	})
+})
+
+var _ = Describe("ValidateMinKubeVersion", func() {
+	It("accepts a strict semantic version", func() {
+		Expect(ValidateMinKubeVersion("1.16.0")).To(Succeed())
+	})
+	It("returns an error for a version with a leading v", func() {
+		Expect(ValidateMinKubeVersion("v1.16.0")).To(MatchError(ContainSubstring(`must not have a leading "v", ex. 1.16.0`)))
+	})
+	It("returns an error for an invalid version", func() {
+		Expect(ValidateMinKubeVersion("1.16")).To(MatchError(ContainSubstring("not a valid semantic version")))
+	})
+})
+
+var _ = Describe("ValidateSkipRange", func() {
+	It("accepts a range not containing --version", func() {
+		Expect(ValidateSkipRange(">=0.1.0 <0.2.0", "0.2.0")).To(Succeed())
+		Expect(ValidateSkipRange("<0.2.0", "")).To(Succeed())
+	})
+	It("returns an error for an invalid range", func() {
+		Expect(ValidateSkipRange("0.1.x", "0.2.0")).To(MatchError(ContainSubstring("not a valid semantic version range")))
+	})
+	It("returns an error for a range containing --version", func() {
+		Expect(ValidateSkipRange(">=0.1.0 <=0.2.0", "0.2.0")).To(MatchError(ContainSubstring("must not contain --version 0.2.0")))
+	})
+})
+
+var _ = Describe("ValidateSkips", func() {
+	It("accepts CSV names", func() {
+		Expect(ValidateSkips([]string{"memcached-operator.v0.1.0", "memcached-operator.v0.1.1-alpha"})).To(Succeed())
+	})
+	It("returns an error for a name without a version", func() {
+		Expect(ValidateSkips([]string{"memcached-operator.v0.1.0", "memcached-operator"})).To(MatchError(ContainSubstring("not of the form")))
+	})
+})
+
+var _ = Describe("ValidatePackageName", func() {
+	It("accepts DNS-1123 subdomains", func() {
+		Expect(ValidatePackageName("acme-memcached-operator")).To(Succeed())
+		Expect(ValidatePackageName("memcached.acme.com")).To(Succeed())
+	})
+	It("returns an error for a name that is not a DNS-1123 subdomain", func() {
+		Expect(ValidatePackageName("Acme_Memcached")).To(MatchError(ContainSubstring(`package name "Acme_Memcached" is invalid`)))
+		Expect(ValidatePackageName("")).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("CheckCSVExists", func() {
+	manifestsDir := filepath.Join("testdata", "bundle", "manifests")
+
+	It("succeeds if the ClusterServiceVersion exists", func() {
+		Expect(CheckCSVExists(manifestsDir, "memcached-operator.v0.0.2")).To(Succeed())
+	})
+	It("returns an error if the ClusterServiceVersion has a different name", func() {
+		Expect(CheckCSVExists(manifestsDir, "memcached-operator.v0.0.1")).To(MatchError(
+			"ClusterServiceVersion memcached-operator.v0.0.1 not found in " + manifestsDir))
+	})
+	It("returns an error if the directory does not exist", func() {
+		err := CheckCSVExists(filepath.Join("testdata", "notexist"), "memcached-operator.v0.0.1")
+		Expect(err).To(MatchError(ContainSubstring("error reading manifests in")))
+	})
+})
@@ -15,19 +15,32 @@
 package genutil
 
 import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/operator-framework/operator-sdk/internal/generate/collector"
 )
 
 // GetManifestObjects returns all objects to be written to a manifests directory from collector.Manifests.
-func GetManifestObjects(c *collector.Manifests) (objs []controllerutil.Object) {
-	// All CRDs passed in should be written.
-	for i := range c.V1CustomResourceDefinitions {
-		objs = append(objs, &c.V1CustomResourceDefinitions[i])
+func GetManifestObjects(c *collector.Manifests) (objs []controllerutil.Object, err error) {
+	// All CRDs passed in should be written as collected, without conversion webhook
+	// configuration since OLM injects it from the CSV's conversion webhooks.
+	crds, err := c.CustomResourceDefinitionObjects()
+	if err != nil {
+		return nil, err
 	}
-	for i := range c.V1beta1CustomResourceDefinitions {
-		objs = append(objs, &c.V1beta1CustomResourceDefinitions[i])
+	for i := range crds {
+		crd := &crds[i]
+		strategy, _, _ := unstructured.NestedString(crd.Object, "spec", "conversion", "strategy")
+		if strategy == string(apiextv1.WebhookConverter) {
+			unstructured.RemoveNestedField(crd.Object, "spec", "conversion")
+		}
+		objs = append(objs, crd)
 	}
 
 	// All ServiceAccounts passed in should be written.
@@ -40,9 +53,17 @@ func GetManifestObjects(c *collector.Manifests) (objs []controllerutil.Object) {
 	objs = append(objs, roleObjs...)
 	_, clusterRoleObjs := c.SplitCSVClusterPermissionsObjects()
 	objs = append(objs, clusterRoleObjs...)
+	for _, obj := range c.UnusedRoles() {
+		kind := "Role"
+		if _, isClusterRole := obj.(*rbacv1.ClusterRole); isClusterRole {
+			kind = "ClusterRole"
+		}
+		log.Warnf("Skipping %s %q: it is not bound to an operator ServiceAccount or any other subject", kind, obj.GetName())
+	}
 
 	removeNamespace(objs)
-	return objs
+	removeCertManagerAnnotations(objs)
+	return objs, nil
 }
 
 // removeNamespace removes the namespace field of resources intended to be inserted into
@@ -57,3 +78,25 @@ func removeNamespace(objs []controllerutil.Object) {
 		obj.SetNamespace("")
 	}
 }
+
+// certManagerAnnotationPrefix prefixes annotations cert-manager uses to inject CA bundles,
+// ex. "cert-manager.io/inject-ca-from".
+const certManagerAnnotationPrefix = "cert-manager.io/"
+
+// removeCertManagerAnnotations removes cert-manager annotations from objs.
+// OLM generates and injects webhook certificates itself, and does not install
+// cert-manager, so these annotations would either be ignored or conflict with OLM.
+func removeCertManagerAnnotations(objs []controllerutil.Object) {
+	for _, obj := range objs {
+		annotations := obj.GetAnnotations()
+		for key := range annotations {
+			if strings.HasPrefix(key, certManagerAnnotationPrefix) {
+				delete(annotations, key)
+			}
+		}
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+		obj.SetAnnotations(annotations)
+	}
+}
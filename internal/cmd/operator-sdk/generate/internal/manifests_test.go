@@ -15,42 +15,169 @@
 package genutil
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
-	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"github.com/operator-framework/operator-sdk/internal/generate/collector"
 )
 
 var _ = Describe("GetManifestObjects", func() {
 	It("should unset the namespace", func() {
+		// Roles bound to a subject other than an operator ServiceAccount are written to the bundle.
+		user := rbacv1.Subject{Kind: "User", Name: "admin"}
 		m := collector.Manifests{
 			Roles: []rbacv1.Role{
 				{ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: "foo"}},
 				{ObjectMeta: metav1.ObjectMeta{Namespace: "bar"}},
 			},
+			RoleBindings: []rbacv1.RoleBinding{
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: "foo"},
+					RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "foo"},
+					Subjects:   []rbacv1.Subject{user},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "bar"},
+					RoleRef:    rbacv1.RoleRef{Kind: "Role"},
+					Subjects:   []rbacv1.Subject{user},
+				},
+			},
 			ClusterRoles: []rbacv1.ClusterRole{
 				{ObjectMeta: metav1.ObjectMeta{Namespace: "bar"}},
 			},
+			ClusterRoleBindings: []rbacv1.ClusterRoleBinding{
+				{
+					RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole"},
+					Subjects: []rbacv1.Subject{user},
+				},
+			},
 			ServiceAccounts: []corev1.ServiceAccount{
 				{ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: "foo"}},
 				{ObjectMeta: metav1.ObjectMeta{Namespace: "bar"}},
 			},
-			V1beta1CustomResourceDefinitions: []apiextensionsv1beta1.CustomResourceDefinition{
-				{ObjectMeta: metav1.ObjectMeta{Namespace: "bar"}},
-			},
 			V1CustomResourceDefinitions: []apiextensionsv1.CustomResourceDefinition{
 				{ObjectMeta: metav1.ObjectMeta{Namespace: "bar"}},
 			},
 		}
-		objs := GetManifestObjects(&m)
-		Expect(objs).To(HaveLen(len(m.Roles) + len(m.ClusterRoles) + len(m.ServiceAccounts) + len(m.V1CustomResourceDefinitions) + len(m.V1beta1CustomResourceDefinitions)))
+		objs, err := GetManifestObjects(&m)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(len(m.Roles) + len(m.RoleBindings) + len(m.ClusterRoles) + len(m.ClusterRoleBindings) +
+			len(m.ServiceAccounts) + len(m.V1CustomResourceDefinitions)))
 		for _, obj := range objs {
 			Expect(obj.GetNamespace()).To(BeEmpty())
 		}
 	})
+	It("should remove cert-manager annotations", func() {
+		crd := apiextensionsv1.CustomResourceDefinition{}
+		crd.SetName("memcacheds.cache.example.com")
+		crd.SetAnnotations(map[string]string{
+			"cert-manager.io/inject-ca-from":        "memcached-operator-system/memcached-operator-serving-cert",
+			"controller-gen.kubebuilder.io/version": "v0.3.0",
+		})
+		m := collector.Manifests{V1CustomResourceDefinitions: []apiextensionsv1.CustomResourceDefinition{crd}}
+		objs, err := GetManifestObjects(&m)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(1))
+		Expect(objs[0].GetAnnotations()).To(Equal(map[string]string{"controller-gen.kubebuilder.io/version": "v0.3.0"}))
+	})
+	It("should remove conversion webhook configuration from CRDs", func() {
+		port := int32(443)
+		v1CRD := apiextensionsv1.CustomResourceDefinition{}
+		v1CRD.Spec.Conversion = &apiextensionsv1.CustomResourceConversion{
+			Strategy: apiextensionsv1.WebhookConverter,
+			Webhook: &apiextensionsv1.WebhookConversion{
+				ClientConfig: &apiextensionsv1.WebhookClientConfig{
+					Service:  &apiextensionsv1.ServiceReference{Namespace: "system", Name: "webhook-service", Port: &port},
+					CABundle: []byte("Cg=="),
+				},
+				ConversionReviewVersions: []string{"v1beta1"},
+			},
+		}
+		noneCRD := apiextensionsv1.CustomResourceDefinition{}
+		noneCRD.Spec.Conversion = &apiextensionsv1.CustomResourceConversion{Strategy: apiextensionsv1.NoneConverter}
+		m := collector.Manifests{
+			V1CustomResourceDefinitions: []apiextensionsv1.CustomResourceDefinition{v1CRD, noneCRD},
+		}
+		objs, err := GetManifestObjects(&m)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objs).To(HaveLen(2))
+		_, hasConversion, err := unstructured.NestedFieldNoCopy(objs[0].(*unstructured.Unstructured).Object, "spec", "conversion")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hasConversion).To(BeFalse())
+		strategy, _, err := unstructured.NestedString(objs[1].(*unstructured.Unstructured).Object, "spec", "conversion", "strategy")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strategy).To(Equal(string(apiextensionsv1.NoneConverter)))
+	})
+
+	Context("with CRDs collected from manifests", func() {
+		var tmp string
+
+		BeforeEach(func() {
+			var err error
+			tmp, err = ioutil.TempDir("", "genutil-crds-")
+			Expect(err).NotTo(HaveOccurred())
+		})
+		AfterEach(func() {
+			Expect(os.RemoveAll(tmp)).To(Succeed())
+		})
+
+		It("should write a v1 CRD with schema defaults and CEL validation byte-for-byte", func() {
+			crdsDir := filepath.Join("testdata", "crds")
+			m := collector.Manifests{}
+			Expect(m.UpdateFromDirs(tmp, crdsDir)).To(Succeed())
+			Expect(m.V1CustomResourceDefinitions).To(HaveLen(1))
+			objs, err := GetManifestObjects(&m)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(WriteObjectsToFiles(tmp, objs...)).To(Succeed())
+
+			fileName := "cache.example.com_memcacheds.yaml"
+			expected, err := ioutil.ReadFile(filepath.Join(crdsDir, fileName))
+			Expect(err).NotTo(HaveOccurred())
+			written, err := ioutil.ReadFile(filepath.Join(tmp, fileName))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(written)).To(Equal(string(expected)))
+
+			By("writing the same bytes when regenerated from the written CRD")
+			m = collector.Manifests{}
+			Expect(m.UpdateFromReader(bytes.NewBuffer(written))).To(Succeed())
+			objs, err = GetManifestObjects(&m)
+			Expect(err).NotTo(HaveOccurred())
+			buf := &bytes.Buffer{}
+			Expect(WriteObjects(buf, objs...)).To(Succeed())
+			Expect(buf.String()).To(Equal(string(expected)))
+		})
+		It("should write a v1beta1 CRD as v1", func() {
+			v1beta1CRD := `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: memcacheds.cache.example.com
+spec:
+  group: cache.example.com
+  names:
+    kind: Memcached
+    plural: memcacheds
+  scope: Namespaced
+  version: v1alpha1
+`
+			m := collector.Manifests{}
+			Expect(m.UpdateFromReader(bytes.NewBufferString(v1beta1CRD))).To(Succeed())
+			objs, err := GetManifestObjects(&m)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objs).To(HaveLen(1))
+			Expect(objs[0].GetObjectKind().GroupVersionKind()).To(Equal(
+				apiextensionsv1.SchemeGroupVersion.WithKind("CustomResourceDefinition")))
+			Expect(WriteObjectsToFiles(tmp, objs...)).To(Succeed())
+			Expect(filepath.Join(tmp, "cache.example.com_memcacheds.yaml")).To(BeAnExistingFile())
+		})
+	})
 })
@@ -0,0 +1,375 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	registrybundle "github.com/operator-framework/operator-registry/pkg/lib/bundle"
+)
+
+// channelNameRegexp matches channel names OLM accepts.
+var channelNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([-a-zA-Z0-9_.]*[a-zA-Z0-9])?$`)
+
+// labelKeyRegexp matches custom bundle label keys, ex. org.opencontainers.image.version.
+var labelKeyRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9_.]*[a-z0-9])?$`)
+
+// operatorFrameworkLabelPrefix is the namespace of bundle labels that are also
+// written to bundle metadata.
+const operatorFrameworkLabelPrefix = "operators.operatorframework.io."
+
+// buildArgRegexp matches Dockerfile ARG names that can be referenced as $NAME in a LABEL.
+var buildArgRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Comments delimiting the bundle.Dockerfile section of build arg LABEL's. Lines users add
+// between buildArgsUserMarker and buildArgsEndMarker are preserved when the section is regenerated.
+const (
+	buildArgsBeginMarker = "# BEGIN build arg LABEL's generated by operator-sdk. Set their values with 'docker build --build-arg'."
+	buildArgsUserMarker  = "# Custom ARG's and LABEL's below this line are preserved when this file is regenerated."
+	buildArgsEndMarker   = "# END build arg LABEL's."
+)
+
+// sdkOwnedLabelPrefixes prefix bundle labels set by operator-sdk and operator-registry,
+// which custom labels cannot override.
+var sdkOwnedLabelPrefixes = []string{
+	operatorFrameworkLabelPrefix + "bundle.",
+	operatorFrameworkLabelPrefix + "metrics.",
+	operatorFrameworkLabelPrefix + "test.",
+}
+
+// ParseChannels parses a comma-separated list of channels and validates them
+// along with defaultChannel, which must be one of channels. If defaultChannel
+// is empty, the first channel is returned as the default.
+func ParseChannels(channels, defaultChannel string) ([]string, string, error) {
+	var parsed []string
+	seen := map[string]bool{}
+	for _, ch := range strings.Split(channels, ",") {
+		ch = strings.TrimSpace(ch)
+		if ch == "" {
+			return nil, "", fmt.Errorf("channels %q contain an empty channel name", channels)
+		}
+		if !channelNameRegexp.MatchString(ch) {
+			return nil, "", fmt.Errorf("channel name %q is invalid: must consist of alphanumeric characters, "+
+				"'-', '_' or '.', and must start and end with an alphanumeric character", ch)
+		}
+		if !seen[ch] {
+			seen[ch] = true
+			parsed = append(parsed, ch)
+		}
+	}
+	if defaultChannel == "" {
+		return parsed, parsed[0], nil
+	}
+	if !seen[defaultChannel] {
+		return nil, "", fmt.Errorf("default channel %q must be one of channels %q", defaultChannel, strings.Join(parsed, ","))
+	}
+	return parsed, defaultChannel, nil
+}
+
+// MakeChannelLabels returns bundle metadata labels for channels and defaultChannel.
+func MakeChannelLabels(channels []string, defaultChannel string) map[string]string {
+	return map[string]string{
+		registrybundle.ChannelsLabel:       strings.Join(channels, ","),
+		registrybundle.ChannelDefaultLabel: defaultChannel,
+	}
+}
+
+// UpdateLabels sets each label in kvs in labels, and returns the sorted keys
+// of labels whose existing values differed from those in kvs.
+func UpdateLabels(labels, kvs map[string]string) (changed []string) {
+	for key, value := range kvs {
+		if old, hasKey := labels[key]; hasKey && old != value {
+			changed = append(changed, key)
+		}
+		labels[key] = value
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// SetDockerfileLabels replaces the values of LABEL's in a Dockerfile's contents
+// with those in kvs. LABEL's in kvs not already in contents are added in key order
+// after the last LABEL, or at the end of contents if there are none.
+func SetDockerfileLabels(contents string, kvs map[string]string) string {
+	lines := strings.SplitAfter(contents, "\n")
+	set := map[string]bool{}
+	lastLabel := -1
+	inBuildArgs := false
+	for i, line := range lines {
+		// LABEL's in the build arg section are not managed here.
+		switch strings.TrimSpace(line) {
+		case buildArgsBeginMarker:
+			inBuildArgs = true
+		case buildArgsEndMarker:
+			inBuildArgs = false
+		}
+		fields := strings.Fields(line)
+		if inBuildArgs || len(fields) != 2 || fields[0] != "LABEL" {
+			continue
+		}
+		lastLabel = i
+		key := strings.SplitN(fields[1], "=", 2)[0]
+		if value, hasKey := kvs[key]; hasKey {
+			lines[i] = fmt.Sprintf("LABEL %s=%s\n", key, value)
+			set[key] = true
+		}
+	}
+
+	var keys []string
+	for key := range kvs {
+		if !set[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var added []string
+	for _, key := range keys {
+		added = append(added, fmt.Sprintf("LABEL %s=%s\n", key, kvs[key]))
+	}
+	if lastLabel == -1 {
+		if len(added) != 0 && contents != "" && !strings.HasSuffix(contents, "\n") {
+			lines[len(lines)-1] += "\n"
+		}
+		return strings.Join(append(lines, added...), "")
+	}
+	out := append(lines[:lastLabel+1:lastLabel+1], added...)
+	return strings.Join(append(out, lines[lastLabel+1:]...), "")
+}
+
+// ParseBundleLabels parses custom bundle labels of the form key=value. An error is
+// returned if a label is malformed, sets a label owned by operator-sdk, or is set twice.
+func ParseBundleLabels(labels []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(labels))
+	for _, label := range labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("bundle label %q must be of the form key=value", label)
+		}
+		key, value := kv[0], kv[1]
+		if err := ValidateBundleLabel(key, value); err != nil {
+			return nil, err
+		}
+		if _, hasKey := parsed[key]; hasKey {
+			return nil, fmt.Errorf("bundle label %q is set more than once", key)
+		}
+		parsed[key] = value
+	}
+	return parsed, nil
+}
+
+// ValidateBundleLabel returns an error if key is not a valid custom bundle label key,
+// or value contains whitespace, which a Dockerfile LABEL cannot contain unquoted.
+func ValidateBundleLabel(key, value string) error {
+	if !labelKeyRegexp.MatchString(key) {
+		return fmt.Errorf("bundle label key %q is invalid: must consist of lower case alphanumeric characters, "+
+			"'-', '_' or '.', and must start and end with an alphanumeric character", key)
+	}
+	for _, prefix := range sdkOwnedLabelPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return fmt.Errorf("bundle label %q is set by operator-sdk and cannot be overridden", key)
+		}
+	}
+	if value == "" || strings.ContainsAny(value, " \t\n\r") {
+		return fmt.Errorf("bundle label %q value %q must be non-empty and must not contain whitespace", key, value)
+	}
+	return nil
+}
+
+// GetMetadataLabels returns the labels in the operator framework annotation namespace,
+// which are also written to bundle metadata.
+func GetMetadataLabels(labels map[string]string) map[string]string {
+	metadataLabels := map[string]string{}
+	for key, value := range labels {
+		if strings.HasPrefix(key, operatorFrameworkLabelPrefix) {
+			metadataLabels[key] = value
+		}
+	}
+	return metadataLabels
+}
+
+// ParseBuildArgLabels parses build arg labels of the form key=ARG, where ARG is the name of
+// the build arg whose value the label key is set to. An error is returned if a label key is
+// not a valid custom bundle label key, ARG is not a valid build arg name, or a key is set twice.
+func ParseBuildArgLabels(labels []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(labels))
+	for _, label := range labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("build arg label %q must be of the form key=ARG", label)
+		}
+		key, arg := kv[0], kv[1]
+		if err := ValidateBuildArgLabel(key, arg); err != nil {
+			return nil, err
+		}
+		if _, hasKey := parsed[key]; hasKey {
+			return nil, fmt.Errorf("build arg label %q is set more than once", key)
+		}
+		parsed[key] = arg
+	}
+	return parsed, nil
+}
+
+// ValidateBuildArgLabel returns an error if key is not a valid custom bundle label key,
+// or arg is not a valid Dockerfile ARG name.
+func ValidateBuildArgLabel(key, arg string) error {
+	if err := ValidateBundleLabel(key, arg); err != nil {
+		return err
+	}
+	if !buildArgRegexp.MatchString(arg) {
+		return fmt.Errorf("build arg %q of label %q is invalid: must consist of alphanumeric characters or '_', "+
+			"and must not start with a digit", arg, key)
+	}
+	return nil
+}
+
+// SplitDockerfileBuildArgs removes the build arg LABEL section from a Dockerfile's contents,
+// and returns the remaining contents and the lines users added to that section.
+func SplitDockerfileBuildArgs(contents string) (rest, userLines string) {
+	lines := strings.SplitAfter(contents, "\n")
+	begin, user, end := -1, -1, len(lines)
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case buildArgsBeginMarker:
+			if begin == -1 {
+				begin = i
+			}
+		case buildArgsUserMarker:
+			if begin != -1 && user == -1 {
+				user = i
+			}
+		case buildArgsEndMarker:
+			if begin != -1 && end == len(lines) {
+				end = i
+			}
+		}
+	}
+	if begin == -1 {
+		return contents, ""
+	}
+	if user != -1 {
+		userLines = strings.Join(lines[user+1:end], "")
+	}
+	if end < len(lines) {
+		end++
+	}
+	return strings.Join(lines[:begin], "") + strings.Join(lines[end:], ""), userLines
+}
+
+// SetDockerfileBuildArgs replaces the build arg LABEL section of a Dockerfile's contents with one
+// declaring an ARG and setting a LABEL for each label key in argLabels to the value of its build arg,
+// followed by userLines. The section is added after the first FROM instruction, since ARG's declared
+// before it cannot be used in LABEL's. The section is removed if argLabels and userLines are empty.
+func SetDockerfileBuildArgs(contents string, argLabels map[string]string, userLines string) string {
+	rest, _ := SplitDockerfileBuildArgs(contents)
+	if len(argLabels) == 0 && userLines == "" {
+		return rest
+	}
+
+	keys := make([]string, 0, len(argLabels))
+	seenArgs := map[string]bool{}
+	var args []string
+	for key, arg := range argLabels {
+		keys = append(keys, key)
+		if !seenArgs[arg] {
+			seenArgs[arg] = true
+			args = append(args, arg)
+		}
+	}
+	sort.Strings(keys)
+	sort.Strings(args)
+
+	var section strings.Builder
+	section.WriteString(buildArgsBeginMarker + "\n")
+	for _, arg := range args {
+		section.WriteString(fmt.Sprintf("ARG %s\n", arg))
+	}
+	for _, key := range keys {
+		section.WriteString(fmt.Sprintf("LABEL %s=$%s\n", key, argLabels[key]))
+	}
+	section.WriteString(buildArgsUserMarker + "\n")
+	section.WriteString(userLines)
+	if userLines != "" && !strings.HasSuffix(userLines, "\n") {
+		section.WriteString("\n")
+	}
+	section.WriteString(buildArgsEndMarker + "\n")
+
+	lines := strings.SplitAfter(rest, "\n")
+	insert := 0
+	for i, line := range lines {
+		if fields := strings.Fields(line); len(fields) != 0 && strings.EqualFold(fields[0], "FROM") {
+			insert = i + 1
+			break
+		}
+	}
+	if insert > 0 && !strings.HasSuffix(lines[insert-1], "\n") {
+		lines[insert-1] += "\n"
+	}
+	out := append(lines[:insert:insert], section.String())
+	return strings.Join(append(out, lines[insert:]...), "")
+}
+
+// GenerateBundleMetadata generates bundle metadata and a bundle.Dockerfile for the
+// manifests in manifestsDir. If outputDir is empty, metadata is written next to
+// manifestsDir and the Dockerfile to the working directory. Otherwise manifests and
+// metadata are written to outputDir, and the Dockerfile is written to outputDir with
+// paths relative to that directory so it can be used as the image build context.
+func GenerateBundleMetadata(manifestsDir, outputDir, pkgName string, channels []string, defaultChannel string,
+	overwrite bool) (err error) {
+
+	chans := strings.Join(channels, ",")
+	if outputDir == "" {
+		return registrybundle.GenerateFunc(manifestsDir, "", pkgName, chans, defaultChannel, overwrite)
+	}
+
+	if manifestsDir, err = filepath.Abs(manifestsDir); err != nil {
+		return err
+	}
+	if outputDir, err = filepath.Abs(outputDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+
+	// The registry generator writes the Dockerfile to, and makes its paths relative to,
+	// the working directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(outputDir); err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := os.Chdir(wd); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	return registrybundle.GenerateFunc(manifestsDir, outputDir, pkgName, chans, defaultChannel, overwrite)
+}
+
+// GetBundleDockerfilePath returns the path of the bundle.Dockerfile generated by
+// GenerateBundleMetadata for outputDir.
+func GetBundleDockerfilePath(outputDir string) string {
+	if outputDir == "" {
+		return registrybundle.DockerFile
+	}
+	return filepath.Join(outputDir, registrybundle.DockerFile)
+}
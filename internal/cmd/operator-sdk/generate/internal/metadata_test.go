@@ -0,0 +1,285 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	registrybundle "github.com/operator-framework/operator-registry/pkg/lib/bundle"
+
+	"github.com/operator-framework/operator-sdk/internal/registry"
+)
+
+var _ = Describe("Bundle metadata", func() {
+	Describe("ParseChannels", func() {
+		It("parses and de-duplicates channels", func() {
+			channels, def, err := ParseChannels("alpha, beta,alpha", "beta")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(channels).To(Equal([]string{"alpha", "beta"}))
+			Expect(def).To(Equal("beta"))
+		})
+		It("defaults to the first channel", func() {
+			channels, def, err := ParseChannels("stable-v1.2,alpha", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(channels).To(Equal([]string{"stable-v1.2", "alpha"}))
+			Expect(def).To(Equal("stable-v1.2"))
+		})
+		It("returns an error for a default channel not in channels", func() {
+			_, _, err := ParseChannels("alpha,beta", "stable")
+			Expect(err).To(MatchError(`default channel "stable" must be one of channels "alpha,beta"`))
+		})
+		It("returns an error for invalid channel names", func() {
+			for _, channels := range []string{"", "alpha,", "-alpha", "alpha/beta", "alpha beta"} {
+				_, _, err := ParseChannels(channels, "")
+				Expect(err).To(HaveOccurred(), channels)
+			}
+		})
+	})
+
+	Describe("UpdateLabels", func() {
+		It("sets labels and returns keys of changed values", func() {
+			labels := map[string]string{
+				registrybundle.ChannelsLabel: "alpha",
+				"com.example/team":           "cache",
+			}
+			changed := UpdateLabels(labels, MakeChannelLabels([]string{"alpha", "beta"}, "beta"))
+			Expect(changed).To(Equal([]string{registrybundle.ChannelsLabel}))
+			Expect(labels).To(Equal(map[string]string{
+				registrybundle.ChannelsLabel:       "alpha,beta",
+				registrybundle.ChannelDefaultLabel: "beta",
+				"com.example/team":                 "cache",
+			}))
+		})
+	})
+
+	Describe("SetDockerfileLabels", func() {
+		It("replaces existing LABEL's and adds missing ones after the last LABEL", func() {
+			contents := `FROM scratch
+
+LABEL operators.operatorframework.io.bundle.channels.v1=alpha
+LABEL com.example.team=cache
+
+COPY manifests /manifests/
+`
+			Expect(SetDockerfileLabels(contents, MakeChannelLabels([]string{"alpha", "beta"}, "beta"))).To(Equal(`FROM scratch
+
+LABEL operators.operatorframework.io.bundle.channels.v1=alpha,beta
+LABEL com.example.team=cache
+LABEL operators.operatorframework.io.bundle.channel.default.v1=beta
+
+COPY manifests /manifests/
+`))
+		})
+		It("appends LABEL's if there are none", func() {
+			Expect(SetDockerfileLabels("FROM scratch", map[string]string{"foo": "bar"})).To(Equal("FROM scratch\nLABEL foo=bar\n"))
+		})
+		It("does not change matching LABEL's", func() {
+			contents := "FROM scratch\nLABEL foo=bar\n"
+			Expect(SetDockerfileLabels(contents, map[string]string{"foo": "bar"})).To(Equal(contents))
+		})
+		It("ignores LABEL's in the build arg section", func() {
+			contents := SetDockerfileBuildArgs("FROM scratch\n\nLABEL foo=bar\n",
+				map[string]string{"vcs-ref": "VCS_REF"}, "LABEL team=cache\n")
+			Expect(SetDockerfileLabels(contents, map[string]string{"team": "storage", "foo": "baz"})).To(Equal(
+				strings.Replace(contents, "LABEL foo=bar\n", "LABEL foo=baz\nLABEL team=storage\n", 1)))
+		})
+	})
+
+	Describe("ParseBuildArgLabels", func() {
+		It("parses labels", func() {
+			labels, err := ParseBuildArgLabels([]string{"org.opencontainers.image.version=VERSION", "vcs-ref=VCS_REF"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(labels).To(Equal(map[string]string{
+				"org.opencontainers.image.version": "VERSION",
+				"vcs-ref":                          "VCS_REF",
+			}))
+		})
+		It("returns an error for malformed labels", func() {
+			_, err := ParseBuildArgLabels([]string{"vcs-ref"})
+			Expect(err).To(MatchError(ContainSubstring("must be of the form key=ARG")))
+			_, err = ParseBuildArgLabels([]string{"vcs-ref=1REF"})
+			Expect(err).To(MatchError(ContainSubstring(`build arg "1REF" of label "vcs-ref" is invalid`)))
+			_, err = ParseBuildArgLabels([]string{"vcs-ref=$VCS_REF"})
+			Expect(err).To(MatchError(ContainSubstring("is invalid")))
+			_, err = ParseBuildArgLabels([]string{registrybundle.PackageLabel + "=PACKAGE"})
+			Expect(err).To(MatchError(ContainSubstring("cannot be overridden")))
+		})
+		It("returns an error for duplicate labels", func() {
+			_, err := ParseBuildArgLabels([]string{"vcs-ref=VCS_REF", "vcs-ref=GIT_SHA"})
+			Expect(err).To(MatchError(ContainSubstring("set more than once")))
+		})
+	})
+
+	Describe("SetDockerfileBuildArgs", func() {
+		const dockerfile = `FROM scratch
+
+LABEL operators.operatorframework.io.bundle.channels.v1=alpha
+
+COPY manifests /manifests/
+`
+		argLabels := map[string]string{
+			"org.opencontainers.image.version":  "VERSION",
+			"org.opencontainers.image.revision": "VCS_REF",
+		}
+		withBuildArgs := `FROM scratch
+` + buildArgsBeginMarker + `
+ARG VCS_REF
+ARG VERSION
+LABEL org.opencontainers.image.revision=$VCS_REF
+LABEL org.opencontainers.image.version=$VERSION
+` + buildArgsUserMarker + `
+` + buildArgsEndMarker + `
+
+LABEL operators.operatorframework.io.bundle.channels.v1=alpha
+
+COPY manifests /manifests/
+`
+
+		It("adds ARG's and LABEL's after the FROM instruction", func() {
+			Expect(SetDockerfileBuildArgs(dockerfile, argLabels, "")).To(Equal(withBuildArgs))
+		})
+		It("declares an ARG once if it sets several LABEL's", func() {
+			contents := SetDockerfileBuildArgs(dockerfile, map[string]string{"version": "VERSION", "release": "VERSION"}, "")
+			Expect(strings.Count(contents, "ARG VERSION\n")).To(Equal(1))
+			Expect(contents).To(ContainSubstring("LABEL release=$VERSION\nLABEL version=$VERSION\n"))
+		})
+		It("replaces an existing section and keeps user lines", func() {
+			userLines := "ARG BUILD_DATE\nLABEL org.opencontainers.image.created=$BUILD_DATE\n"
+			contents := strings.Replace(withBuildArgs, buildArgsEndMarker, userLines+buildArgsEndMarker, 1)
+
+			rest, lines := SplitDockerfileBuildArgs(contents)
+			Expect(rest).To(Equal(dockerfile))
+			Expect(lines).To(Equal(userLines))
+
+			updated := SetDockerfileBuildArgs(contents, map[string]string{"org.opencontainers.image.version": "VERSION"}, lines)
+			Expect(updated).NotTo(ContainSubstring("VCS_REF"))
+			Expect(updated).To(ContainSubstring(buildArgsUserMarker + "\n" + userLines + buildArgsEndMarker + "\n"))
+			Expect(SetDockerfileBuildArgs(updated, map[string]string{"org.opencontainers.image.version": "VERSION"},
+				lines)).To(Equal(updated))
+		})
+		It("removes the section if there are no build arg labels or user lines", func() {
+			Expect(SetDockerfileBuildArgs(withBuildArgs, nil, "")).To(Equal(dockerfile))
+			Expect(SetDockerfileBuildArgs(dockerfile, nil, "")).To(Equal(dockerfile))
+		})
+	})
+
+	Describe("ParseBundleLabels", func() {
+		It("parses labels", func() {
+			labels, err := ParseBundleLabels([]string{"vendor=example.com", "vcs-ref=abc=123",
+				"operators.operatorframework.io.custom.v1=foo"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(labels).To(Equal(map[string]string{
+				"vendor":  "example.com",
+				"vcs-ref": "abc=123",
+				"operators.operatorframework.io.custom.v1": "foo",
+			}))
+		})
+		It("returns an error for malformed labels", func() {
+			_, err := ParseBundleLabels([]string{"vendor"})
+			Expect(err).To(MatchError(ContainSubstring("must be of the form key=value")))
+			_, err = ParseBundleLabels([]string{"Vendor=example.com"})
+			Expect(err).To(MatchError(ContainSubstring(`key "Vendor" is invalid`)))
+			_, err = ParseBundleLabels([]string{"vendor=Example Inc."})
+			Expect(err).To(MatchError(ContainSubstring("must not contain whitespace")))
+		})
+		It("returns an error for labels set by operator-sdk", func() {
+			for _, key := range []string{registrybundle.ChannelsLabel, registrybundle.PackageLabel,
+				"operators.operatorframework.io.metrics.builder", "operators.operatorframework.io.test.config.v1"} {
+				_, err := ParseBundleLabels([]string{key + "=foo"})
+				Expect(err).To(MatchError(ContainSubstring("cannot be overridden")))
+			}
+		})
+		It("returns an error for duplicate labels", func() {
+			_, err := ParseBundleLabels([]string{"vendor=example.com", "vendor=example.org"})
+			Expect(err).To(MatchError(ContainSubstring("set more than once")))
+		})
+	})
+
+	Describe("GetMetadataLabels", func() {
+		It("returns labels in the operator framework namespace", func() {
+			Expect(GetMetadataLabels(map[string]string{
+				"vendor": "example.com",
+				"operators.operatorframework.io.custom.v1": "foo",
+			})).To(Equal(map[string]string{"operators.operatorframework.io.custom.v1": "foo"}))
+		})
+	})
+
+	Describe("GenerateBundleMetadata", func() {
+		var (
+			wd, tmp, manifestsDir string
+			err                   error
+		)
+
+		BeforeEach(func() {
+			wd, err = os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			manifestsDir, err = filepath.Abs(filepath.Join("testdata", "bundle", registrybundle.ManifestsDir))
+			Expect(err).NotTo(HaveOccurred())
+			tmp, err = ioutil.TempDir("", "genutil-bundle-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(tmp)).To(Succeed())
+		})
+		AfterEach(func() {
+			Expect(os.Chdir(wd)).To(Succeed())
+			Expect(os.RemoveAll(tmp)).To(Succeed())
+		})
+
+		It("writes a buildable bundle to a nested output directory", func() {
+			outputDir := filepath.Join("dist", "bundles", "0.0.1")
+			Expect(GenerateBundleMetadata(manifestsDir, outputDir, "memcached-operator",
+				[]string{"alpha"}, "alpha", false)).To(Succeed())
+
+			By("not writing a Dockerfile to the working directory")
+			cwd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mustEvalSymlinks(cwd)).To(Equal(mustEvalSymlinks(tmp)))
+			Expect(filepath.Join(tmp, registrybundle.DockerFile)).NotTo(BeAnExistingFile())
+
+			By("writing a Dockerfile with paths relative to the output directory")
+			dockerfilePath := GetBundleDockerfilePath(outputDir)
+			Expect(dockerfilePath).To(Equal(filepath.Join(outputDir, registrybundle.DockerFile)))
+			b, err := ioutil.ReadFile(dockerfilePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(b)).To(ContainSubstring("COPY manifests /manifests/\n"))
+			Expect(string(b)).To(ContainSubstring("COPY metadata /metadata/\n"))
+
+			By("writing a valid bundle to the output directory")
+			annotations, _, err := registry.FindBundleMetadata(outputDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(annotations).To(HaveKeyWithValue(registrybundle.PackageLabel, "memcached-operator"))
+			Expect(annotations).To(HaveKeyWithValue(registrybundle.ChannelsLabel, "alpha"))
+			bundle, err := apimanifests.GetBundleFromDir(filepath.Join(outputDir, registrybundle.ManifestsDir))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bundle.CSV).NotTo(BeNil())
+			Expect(bundle.V1CRDs).To(HaveLen(1))
+		})
+
+		It("writes the Dockerfile to the working directory if no output directory is set", func() {
+			Expect(GetBundleDockerfilePath("")).To(Equal(registrybundle.DockerFile))
+		})
+	})
+})
+
+func mustEvalSymlinks(path string) string {
+	p, err := filepath.EvalSymlinks(path)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	return p
+}
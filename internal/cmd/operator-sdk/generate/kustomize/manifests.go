@@ -34,6 +34,15 @@ Running 'generate kustomize manifests' will (re)generate kustomize bases and a k
 'config/manifests', which are used to build operator-framework manifests by other operator-sdk commands.
 This command will interactively ask for UI metadata, an important component of manifest bases,
 by default unless a base already exists or you set '--interactive=false'.
+
+Set '--metadata-file' to a YAML or JSON file containing UI metadata to skip prompts for the fields it sets.
+Combined with '--interactive=false', fields not in this file are given defaults derived from the project name.
+
+For project version 3, UI metadata from prompts or '--metadata-file' is saved in the PROJECT file, along with
+maturity. Saved values replace those of an existing base and are not prompted for; edit the PROJECT file to change them.
+
+Set '--icon' to a png, jpeg, gif, or svg image file to embed it as the base's icon. If not set,
+an existing base's icon is kept.
 `
 
 const examples = `
@@ -49,6 +58,20 @@ const examples = `
   │   └── memcached-operator.clusterserviceversion.yaml
   └── kustomization.yaml
 
+  # To generate a kustomize base from UI metadata in a file without prompting:
+  $ cat metadata.yaml
+  displayName: Memcached Operator
+  description: Manages memcached clusters.
+  provider:
+    name: Example Inc.
+    url: https://example.com
+  keywords:
+  - memcached
+  maintainers:
+  - name: Jane Doe
+    email: jane@example.com
+  $ operator-sdk generate kustomize manifests --interactive=false --metadata-file metadata.yaml
+
   # After generating kustomize bases and a kustomization.yaml, you can generate a bundle or package manifests.
 
   # To generate a bundle:
@@ -60,11 +83,13 @@ const examples = `
 
 //nolint:maligned
 type manifestsCmd struct {
-	projectName string
-	inputDir    string
-	outputDir   string
-	apisDir     string
-	quiet       bool
+	projectName  string
+	inputDir     string
+	outputDir    string
+	apisDir      string
+	metadataFile string
+	iconFile     string
+	quiet        bool
 
 	// Interactive options.
 	interactiveLevel projutil.InteractiveLevel
@@ -121,6 +146,10 @@ func (c *manifestsCmd) addFlagsTo(fs *pflag.FlagSet) {
 	fs.StringVar(&c.inputDir, "input-dir", "", "Directory containing existing kustomize files")
 	fs.StringVar(&c.outputDir, "output-dir", "", "Directory to write kustomize files")
 	fs.StringVar(&c.apisDir, "apis-dir", "", "Root directory for API type defintions")
+	fs.StringVar(&c.metadataFile, "metadata-file", "", "YAML or JSON file containing UI metadata, "+
+		"ex. displayName, description, provider, keywords and maintainers. Fields set in this file are not prompted for")
+	fs.StringVar(&c.iconFile, "icon", "", "Image file, ex. icon.png or icon.svg, to base64-encode "+
+		"and set as the CSV's icon. If not set, an existing icon is kept")
 	fs.BoolVarP(&c.quiet, "quiet", "q", false, "Run in quiet mode")
 	fs.BoolVar(&c.interactive, "interactive", false, "When set or no kustomize base exists, an interactive "+
 		"command prompt will be presented to accept non-inferrable metadata")
@@ -166,9 +195,24 @@ func (c manifestsCmd) run(cfg *config.Config) error {
 		fmt.Println("Generating kustomize files in", c.outputDir)
 	}
 
+	// UI metadata and the minimum Kubernetes version saved in the project config are the source of truth
+	// for those fields of the base.
+	projectMetadata, err := genutil.GetUIMetadata(cfg)
+	if err != nil {
+		return err
+	}
+	minKubeVersion, err := genutil.GetMinKubeVersion(cfg)
+	if err != nil {
+		return err
+	}
+
 	csvGen := gencsv.Generator{
-		OperatorName: c.projectName,
-		OperatorType: projutil.PluginKeyToOperatorType(cfg.Layout),
+		OperatorName:    c.projectName,
+		OperatorType:    projutil.PluginKeyToOperatorType(cfg.Layout),
+		UIMetadataPath:  c.metadataFile,
+		ProjectMetadata: projectMetadata,
+		IconPath:        c.iconFile,
+		MinKubeVersion:  minKubeVersion,
 	}
 	opts := []gencsv.Option{
 		gencsv.WithBase(c.inputDir, c.apisDir, c.interactiveLevel),
@@ -178,6 +222,11 @@ func (c manifestsCmd) run(cfg *config.Config) error {
 		return fmt.Errorf("error generating kustomize bases: %v", err)
 	}
 
+	// Save UI metadata read from the metadata file or prompts so later runs keep it.
+	if err := genutil.SaveUIMetadata(cfg, projectMetadata); err != nil {
+		return err
+	}
+
 	// Write a kustomization.yaml to outputDir if one does not exist.
 	if err := kustomize.WriteIfNotExist(c.outputDir, manifestsKustomization); err != nil {
 		return fmt.Errorf("error writing kustomization.yaml: %v", err)
@@ -28,21 +28,29 @@ import (
 //nolint:maligned
 type packagemanifestsCmd struct {
 	// Common options.
-	projectName   string
-	version       string
-	fromVersion   string
-	inputDir      string
-	outputDir     string
-	kustomizeDir  string
-	deployDir     string
-	crdsDir       string
-	updateObjects bool
-	stdout        bool
-	quiet         bool
+	projectName            string
+	packageName            string
+	version                string
+	fromVersion            string
+	inputDir               string
+	outputDir              string
+	kustomizeDir           string
+	deployDir              string
+	crdsDir                string
+	minKubeVersion         string
+	skipRange              string
+	skips                  []string
+	skipRelatedImages      bool
+	skipNativeAPIDetection bool
+	updateObjects          bool
+	overwriteCSV           bool
+	stdout                 bool
+	quiet                  bool
 
 	// Package manifest options.
 	channelName      string
 	isDefaultChannel bool
+	removeChannels   []string
 }
 
 // NewCmd returns the 'packagemanifests' command configured for the new project layout.
@@ -74,6 +82,13 @@ func NewCmd() *cobra.Command {
 				log.Fatalf("Error generating package manifests: %v", err)
 			}
 
+			// Save an explicitly set package name for later runs.
+			if cmd.Flags().Changed("package") {
+				if err = genutil.SavePackageName(cfg, c.packageName); err != nil {
+					log.Fatalf("Error saving package name: %v", err)
+				}
+			}
+
 			return nil
 		},
 	}
@@ -85,7 +100,11 @@ func NewCmd() *cobra.Command {
 
 func (c *packagemanifestsCmd) addFlagsTo(fs *pflag.FlagSet) {
 	fs.StringVarP(&c.version, "version", "v", "", "Semantic version of the packaged operator")
-	fs.StringVar(&c.fromVersion, "from-version", "", "Semantic version of the operator being upgraded from")
+	fs.StringVar(&c.packageName, "package", "", "Name of the package, which prefixes the CSV's name and is set as "+
+		"the package manifest's packageName. Must be a DNS-1123 subdomain. Saved in the PROJECT file so later "+
+		"runs keep it. Defaults to the saved package or the project name")
+	fs.StringVar(&c.fromVersion, "from-version", "", "Semantic version of the operator being upgraded from. "+
+		"Sets the CSV's spec.replaces, and must be less than --version and exist in --input-dir")
 	fs.StringVar(&c.inputDir, "input-dir", "", "Directory to read existing package manifests from. "+
 		"This directory is the parent of individual versioned package directories, and different from --deploy-dir")
 	fs.StringVar(&c.outputDir, "output-dir", "", "Directory in which to write package manifests")
@@ -97,8 +116,26 @@ func (c *packagemanifestsCmd) addFlagsTo(fs *pflag.FlagSet) {
 	fs.StringVar(&c.channelName, "channel", "", "Channel name for the generated package")
 	fs.BoolVar(&c.isDefaultChannel, "default-channel", false, "Use the channel passed to --channel "+
 		"as the package manifest file's default channel")
+	fs.StringSliceVar(&c.removeChannels, "remove-channel", nil, "Channel name(s) to remove from an existing "+
+		"package manifest file. The default channel cannot be removed")
 	fs.BoolVar(&c.updateObjects, "update-objects", true, "Update non-CSV objects in this package, "+
 		"ex. CustomResoureDefinitions, Roles")
+	fs.BoolVar(&c.overwriteCSV, "overwrite-csv-metadata", false, "Overwrite manually edited fields of an "+
+		"existing CSV, such as description and maintainers, with those of the kustomize base instead of preserving them")
+	fs.StringVar(&c.minKubeVersion, "min-kube-version", "", "Minimum Kubernetes version, ex. 1.16.0, "+
+		"set as the CSV's spec.minKubeVersion and saved in the PROJECT file. If not set, the saved, "+
+		"kustomize base's, or an existing CSV's minKubeVersion is kept")
+	fs.StringVar(&c.skipRange, "skip-range", "", "Semantic version range of operator versions, ex. '>=0.1.0 <0.2.0', "+
+		"that can upgrade directly to this version, set as the CSV's olm.skipRange annotation. "+
+		"If not set, an existing CSV's skip range is kept")
+	fs.StringSliceVar(&c.skips, "skips", nil, "Name of a CSV, ex. memcached-operator.v0.1.1, "+
+		"that this CSV skips, set in the CSV's spec.skips. May be passed more than once. "+
+		"If not set, an existing CSV's skips are kept")
+	fs.BoolVar(&c.skipRelatedImages, "skip-related-images", false, "Do not generate the CSV's spec.relatedImages "+
+		"from manager container images and RELATED_IMAGE_* env vars, ex. if you manage them in your base CSV")
+	fs.BoolVar(&c.skipNativeAPIDetection, "skip-native-api-detection", false, "Do not declare the APIs of "+
+		"manifests that are not built into Kubernetes, ex. ServiceMonitors, in the CSV's "+
+		"spec.customresourcedefinitions.required or spec.nativeAPIs")
 	fs.BoolVarP(&c.quiet, "quiet", "q", false, "Run in quiet mode")
 	fs.BoolVar(&c.stdout, "stdout", false, "Write package to stdout")
 }
@@ -19,7 +19,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	log "github.com/sirupsen/logrus"
 	"sigs.k8s.io/kubebuilder/pkg/model/config"
 
 	genutil "github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/generate/internal"
@@ -41,6 +43,21 @@ consumed by this command.
 
 Set '--version' to supply a semantic version for your new package.
 
+Set '--package' to name the package something other than your project. The package name prefixes
+the CSV's name and is set as the package manifest's packageName, and is saved in the PROJECT file so
+later runs keep it.
+
+Set '--min-kube-version' to the minimum Kubernetes version your operator supports, which is
+set as the CSV's spec.minKubeVersion. The version is saved in the PROJECT file so later runs keep it.
+
+Set '--skip-range' and '--skips' to let operator versions other than the replaced one upgrade directly
+to this version. These set the CSV's olm.skipRange annotation and spec.skips; if not set, an existing
+CSV's values are kept.
+
+If a package manifest file already exists in '--input-dir', only the channel set by '--channel' is added or
+updated to point to the new CSV; other channels and the default channel are kept. Set '--default-channel'
+to make '--channel' the default channel, and '--remove-channel' to remove channels from the package.
+
 More information on the package manifests format:
 https://github.com/operator-framework/operator-registry/#manifest-format
 `
@@ -82,6 +99,11 @@ func (c *packagemanifestsCmd) setDefaults(cfg *config.Config) (err error) {
 	if c.projectName, err = genutil.GetOperatorName(cfg); err != nil {
 		return err
 	}
+	if c.packageName == "" {
+		if c.packageName, err = genutil.GetPackageName(cfg); err != nil {
+			return err
+		}
+	}
 
 	if c.inputDir == "" {
 		c.inputDir = defaultRootDir
@@ -97,6 +119,10 @@ func (c *packagemanifestsCmd) setDefaults(cfg *config.Config) (err error) {
 // validate validates c for package manifests generation.
 func (c packagemanifestsCmd) validate() error {
 
+	if err := genutil.ValidatePackageName(c.packageName); err != nil {
+		return fmt.Errorf("invalid --package: %v", err)
+	}
+
 	if c.version != "" {
 		if err := genutil.ValidateVersion(c.version); err != nil {
 			return err
@@ -106,9 +132,28 @@ func (c packagemanifestsCmd) validate() error {
 	}
 
 	if c.fromVersion != "" {
-		if err := genutil.ValidateVersion(c.fromVersion); err != nil {
+		if err := genutil.ValidateFromVersion(c.fromVersion, c.version); err != nil {
 			return err
 		}
+		// The replaced CSV must be in the package for the upgrade graph to be valid.
+		replaces := fmt.Sprintf("%s.v%s", c.packageName, c.fromVersion)
+		if err := genutil.CheckCSVExists(filepath.Join(c.inputDir, c.fromVersion), replaces); err != nil {
+			return fmt.Errorf("invalid --from-version: %v", err)
+		}
+	}
+
+	if c.minKubeVersion != "" {
+		if err := genutil.ValidateMinKubeVersion(c.minKubeVersion); err != nil {
+			return fmt.Errorf("invalid --min-kube-version: %v", err)
+		}
+	}
+	if c.skipRange != "" {
+		if err := genutil.ValidateSkipRange(c.skipRange, c.version); err != nil {
+			return fmt.Errorf("invalid --skip-range: %v", err)
+		}
+	}
+	if err := genutil.ValidateSkips(c.skips); err != nil {
+		return fmt.Errorf("invalid --skips: %v", err)
 	}
 
 	if c.inputDir == "" {
@@ -136,17 +181,25 @@ func (c packagemanifestsCmd) validate() error {
 	if c.isDefaultChannel && c.channelName == "" {
 		return fmt.Errorf("--default-channel can only be set if --channel is set")
 	}
+	for _, name := range c.removeChannels {
+		if name == c.channelName {
+			return fmt.Errorf("channel %q cannot be set by both --channel and --remove-channel", name)
+		}
+	}
 
 	return nil
 }
 
 // run generates package manifests.
-func (c packagemanifestsCmd) run(cfg *config.Config) error {
+func (c packagemanifestsCmd) run(cfg *config.Config) (err error) {
 
 	if !c.quiet && !c.stdout {
 		fmt.Println("Generating package manifests version", c.version)
 	}
 
+	if err := c.checkPackageRename(); err != nil {
+		return err
+	}
 	if err := c.generatePackageManifest(); err != nil {
 		return err
 	}
@@ -162,13 +215,37 @@ func (c packagemanifestsCmd) run(cfg *config.Config) error {
 			return err
 		}
 	}
+	// Samples populate the CSV's alm-examples even if not passed as input.
+	if err := col.UpdateFromSamples(filepath.Join("config", "samples")); err != nil {
+		return err
+	}
+
+	minKubeVersion := c.minKubeVersion
+	if minKubeVersion == "" {
+		if minKubeVersion, err = genutil.GetMinKubeVersion(cfg); err != nil {
+			return err
+		}
+	}
+	// UI metadata saved in the project config replaces that of the base and existing CSV.
+	projectMetadata, err := genutil.GetUIMetadata(cfg)
+	if err != nil {
+		return err
+	}
 
 	csvGen := gencsv.Generator{
-		OperatorName: c.projectName,
-		OperatorType: projutil.PluginKeyToOperatorType(cfg.Layout),
-		Version:      c.version,
-		FromVersion:  c.fromVersion,
-		Collector:    col,
+		OperatorName:           c.projectName,
+		PackageName:            c.packageName,
+		OperatorType:           projutil.PluginKeyToOperatorType(cfg.Layout),
+		Version:                c.version,
+		FromVersion:            c.fromVersion,
+		Collector:              col,
+		ProjectMetadata:        projectMetadata,
+		MinKubeVersion:         minKubeVersion,
+		SkipRange:              c.skipRange,
+		Skips:                  c.skips,
+		SkipRelatedImages:      c.skipRelatedImages,
+		SkipNativeAPIDetection: c.skipNativeAPIDetection,
+		Overwrite:              c.overwriteCSV,
 	}
 
 	stdout := genutil.NewMultiManifestWriter(os.Stdout)
@@ -188,7 +265,10 @@ func (c packagemanifestsCmd) run(cfg *config.Config) error {
 	}
 
 	if c.updateObjects {
-		objs := genutil.GetManifestObjects(col)
+		objs, err := genutil.GetManifestObjects(col)
+		if err != nil {
+			return err
+		}
 		if c.stdout {
 			if err := genutil.WriteObjects(stdout, objs...); err != nil {
 				return err
@@ -201,6 +281,13 @@ func (c packagemanifestsCmd) run(cfg *config.Config) error {
 		}
 	}
 
+	// Save an explicitly set minimum Kubernetes version for later runs.
+	if c.minKubeVersion != "" {
+		if err := genutil.SaveMinKubeVersion(cfg, c.minKubeVersion); err != nil {
+			return err
+		}
+	}
+
 	if !c.quiet && !c.stdout {
 		fmt.Println("Package manifests generated successfully in", c.outputDir)
 	}
@@ -210,10 +297,11 @@ func (c packagemanifestsCmd) run(cfg *config.Config) error {
 
 func (c packagemanifestsCmd) generatePackageManifest() error {
 	pkgGen := genpkg.Generator{
-		OperatorName:     c.projectName,
+		OperatorName:     c.packageName,
 		Version:          c.version,
 		ChannelName:      c.channelName,
 		IsDefaultChannel: c.isDefaultChannel,
+		RemoveChannels:   c.removeChannels,
 	}
 	opts := []genpkg.Option{
 		genpkg.WithBase(c.inputDir),
@@ -224,3 +312,20 @@ func (c packagemanifestsCmd) generatePackageManifest() error {
 	}
 	return nil
 }
+
+// checkPackageRename warns if c.inputDir contains the package manifest of a package other than
+// c.packageName, since a new package is generated instead of updating that one.
+func (c packagemanifestsCmd) checkPackageRename() error {
+	paths, err := filepath.Glob(filepath.Join(c.inputDir, "*.package.yaml"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if previous := strings.TrimSuffix(filepath.Base(path), ".package.yaml"); previous != c.packageName {
+			log.Warnf("Found package manifest %s of package %q, but generating package %q. "+
+				"OLM does not upgrade operators installed from package %q to CSVs of package %q",
+				path, previous, c.packageName, previous, c.packageName)
+		}
+	}
+	return nil
+}
@@ -25,6 +25,7 @@ func NewCmd() *cobra.Command {
 	}
 	cmd.AddCommand(
 		newInstallCmd(),
+		newSnapshotCmd(),
 		newStatusCmd(),
 		newUninstallCmd(),
 	)
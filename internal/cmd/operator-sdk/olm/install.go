@@ -34,7 +34,29 @@ func newInstallCmd() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVar(&mgr.Version, "version", installer.DefaultVersion, "version of OLM resources to install")
+	cmd.Flags().StringVar(&mgr.Version, "version", installer.DefaultVersion,
+		"version of OLM resources to install, either \"latest\" or an exact release version such as 0.15.1")
+	cmd.Flags().StringVar(&mgr.SHA256SumsFile, "sha256sums-file", "",
+		"sha256sum-formatted file of checksums to verify the downloaded crds.yaml and olm.yaml against. "+
+			"If unset, the release's checksums.txt is used if it has one")
+	cmd.Flags().StringVar(&mgr.ManifestsDir, "olm-manifests-dir", "",
+		"local directory containing the crds.yaml and olm.yaml of an OLM release to install instead of downloading them, for clusters without internet access. "+
+			"The directory is recorded, so olm status and uninstall read manifests from it by default")
+	cmd.Flags().StringVar(&mgr.ImageMirror, "image-mirror", "",
+		"registry prefix to rewrite images in the OLM manifests against, ex. registry.example.com/olm "+
+			"pulls quay.io/operator-framework/olm as registry.example.com/olm/operator-framework/olm")
+	cmd.Flags().StringVar(&mgr.OLMNamespace, "olm-namespace", installer.DefaultOLMNamespace,
+		"namespace to install OLM's components in. The namespace is recorded so olm status and uninstall "+
+			"find it without this flag")
+	cmd.Flags().StringArrayVar(&mgr.ComponentEnv, "component-env", nil,
+		"environment variable to set on the olm-operator, catalog-operator, and packageserver Deployments, as KEY=VALUE. "+
+			"May be set more than once")
+	cmd.Flags().BoolVar(&mgr.ProxyFromEnv, "proxy-from-env", false,
+		"set HTTP_PROXY, HTTPS_PROXY, and NO_PROXY on OLM's components to their values in the current environment. "+
+			"Values set by --component-env take precedence")
+	cmd.Flags().BoolVar(&mgr.Upgrade, "upgrade", false,
+		"allow installing --version over a different installed version of OLM. "+
+			"Re-running install for the installed version resumes a partial installation without this flag")
 	mgr.AddToFlagSet(cmd.Flags())
 	return cmd
 }
@@ -33,6 +33,32 @@ var _ = Describe("Running an olm install command", func() {
 			Expect(flag).NotTo(BeNil())
 			Expect(flag.DefValue).To(Equal(installer.DefaultVersion))
 			Expect(flag.Usage).NotTo(BeNil())
+
+			flag = cmd.Flags().Lookup("sha256sums-file")
+			Expect(flag).NotTo(BeNil())
+			Expect(flag.DefValue).To(Equal(""))
+
+			flag = cmd.Flags().Lookup("olm-namespace")
+			Expect(flag).NotTo(BeNil())
+			Expect(flag.DefValue).To(Equal(installer.DefaultOLMNamespace))
+
+			for _, name := range []string{"olm-manifests-dir", "image-mirror"} {
+				flag = cmd.Flags().Lookup(name)
+				Expect(flag).NotTo(BeNil())
+				Expect(flag.DefValue).To(Equal(""))
+			}
+
+			flag = cmd.Flags().Lookup("component-env")
+			Expect(flag).NotTo(BeNil())
+			Expect(flag.Value.Type()).To(Equal("stringArray"))
+
+			flag = cmd.Flags().Lookup("proxy-from-env")
+			Expect(flag).NotTo(BeNil())
+			Expect(flag.DefValue).To(Equal("false"))
+
+			flag = cmd.Flags().Lookup("upgrade")
+			Expect(flag).NotTo(BeNil())
+			Expect(flag.DefValue).To(Equal("false"))
 		})
 	})
 })
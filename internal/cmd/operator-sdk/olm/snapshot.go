@@ -0,0 +1,45 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olm
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/installer"
+)
+
+func newSnapshotCmd() *cobra.Command {
+	mgr := &installer.Manager{}
+	var output string
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Export the cluster state checked before installing operators to a file",
+		Long: `Export the cluster state checked before installing operators to a file, so checks can be reviewed
+without access to the cluster. The snapshot contains discovery data and all Namespaces, ResourceQuotas,
+CustomResourceDefinitions, APIServices, NetworkPolicies, OperatorGroups, Subscriptions, and
+ClusterServiceVersions. Secrets are never exported.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := mgr.Snapshot(output); err != nil {
+				log.Fatalf("Failed to snapshot cluster: %s", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "cluster-snapshot.json", "file to write the snapshot to, or \"-\" for stdout")
+	mgr.AddToFlagSet(cmd.Flags())
+	return cmd
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olm
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Running an olm snapshot command", func() {
+	Describe("newSnapshotCmd", func() {
+		It("builds a cobra command", func() {
+			cmd := newSnapshotCmd()
+			Expect(cmd).NotTo(BeNil())
+			Expect(cmd.Use).NotTo(BeNil())
+			Expect(cmd.Short).NotTo(BeNil())
+
+			flag := cmd.Flags().Lookup("output")
+			Expect(flag).NotTo(BeNil())
+			Expect(flag.DefValue).To(Equal("cluster-snapshot.json"))
+			Expect(flag.Usage).NotTo(BeNil())
+		})
+	})
+})
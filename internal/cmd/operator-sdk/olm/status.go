@@ -15,9 +15,13 @@
 package olm
 
 import (
+	"errors"
+	"os"
+
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
+	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
 	"github.com/operator-framework/operator-sdk/internal/olm/installer"
 )
 
@@ -26,17 +30,44 @@ func newStatusCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Get the status of the Operator Lifecycle Manager installation in your cluster",
+		Long: `Get the status of the Operator Lifecycle Manager installation in your cluster.
+
+The health of each expected OLM resource is printed. The command exits with
+status 2 if OLM is not installed or any resource is missing, 3 if all resources
+exist but any is unhealthy, and 1 on any other error.
+`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := mgr.Status(); err != nil {
-				log.Fatalf("Failed to get OLM status: %s", err)
+				log.Errorf("Failed to get OLM status: %s", err)
+				os.Exit(statusExitCode(err))
 			}
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&mgr.OLMNamespace, "olm-namespace", installer.DefaultOLMNamespace, "namespace where OLM is installed")
+	cmd.Flags().StringVar(&mgr.OLMNamespace, "olm-namespace", "", "namespace where OLM is installed. "+
+		"If unset, the namespace recorded by olm install is used, or \"olm\" if none was recorded")
 	cmd.Flags().StringVar(&mgr.Version, "version", "", "version of OLM installed on cluster; if unset"+
 		"operator-sdk attempts to auto-discover the version")
+	cmd.Flags().StringVar(&mgr.ManifestsDir, "olm-manifests-dir", "",
+		"local directory containing the crds.yaml and olm.yaml OLM was installed from. If unset, the directory recorded by olm install --olm-manifests-dir is used, if any")
+	cmd.Flags().StringVarP(&mgr.Output, "output", "o", "",
+		"print the status of each expected OLM resource to stdout in this format instead of a table, one of: json, yaml. "+
+			"The command fails if any resource is missing or unhealthy regardless of format")
 	mgr.AddToFlagSet(cmd.Flags())
 	return cmd
 }
+
+// statusExitCode returns the exit code for a status error: ExitCodeMissing if
+// OLM is not installed or resources are missing, ExitCodeDegraded if resources
+// exist but are unhealthy, and 1 for all other errors.
+func statusExitCode(err error) int {
+	unhealthy := &olmclient.UnhealthyError{}
+	if errors.As(err, &unhealthy) {
+		return unhealthy.ExitCode()
+	}
+	if errors.Is(err, olmclient.ErrOLMNotInstalled) {
+		return olmclient.ExitCodeMissing
+	}
+	return 1
+}
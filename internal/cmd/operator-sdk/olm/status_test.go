@@ -15,10 +15,13 @@
 package olm
 
 import (
+	"errors"
+	"fmt"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
-	"github.com/operator-framework/operator-sdk/internal/olm/installer"
+	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
 )
 
 var _ = Describe("Running an olm status command", func() {
@@ -31,13 +34,28 @@ var _ = Describe("Running an olm status command", func() {
 
 			flag := cmd.Flags().Lookup("olm-namespace")
 			Expect(flag).NotTo(BeNil())
-			Expect(flag.DefValue).To(Equal(installer.DefaultOLMNamespace))
+			Expect(flag.DefValue).To(Equal(""))
 			Expect(flag.Usage).NotTo(BeNil())
 
 			flag = cmd.Flags().Lookup("version")
 			Expect(flag).NotTo(BeNil())
 			Expect(flag.DefValue).To(Equal(""))
 			Expect(flag.Usage).NotTo(BeNil())
+
+			flag = cmd.Flags().ShorthandLookup("o")
+			Expect(flag).NotTo(BeNil())
+			Expect(flag.Name).To(Equal("output"))
+			Expect(flag.DefValue).To(Equal(""))
+		})
+	})
+	Describe("statusExitCode", func() {
+		It("should distinguish missing, degraded, and failed statuses", func() {
+			missing := olmclient.StatusReport{Resources: []olmclient.ResourceReport{{Name: "olm-operator", Kind: "Deployment"}}}
+			degraded := olmclient.StatusReport{Resources: []olmclient.ResourceReport{{Name: "olm-operator", Kind: "Deployment", Exists: true}}}
+			Expect(statusExitCode(&olmclient.UnhealthyError{Report: missing})).To(Equal(2))
+			Expect(statusExitCode(&olmclient.UnhealthyError{Report: degraded})).To(Equal(3))
+			Expect(statusExitCode(fmt.Errorf("error getting installed OLM version: %w", olmclient.ErrOLMNotInstalled))).To(Equal(2))
+			Expect(statusExitCode(errors.New("forbidden"))).To(Equal(1))
 		})
 	})
 })
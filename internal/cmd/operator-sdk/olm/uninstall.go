@@ -35,8 +35,20 @@ func newUninstallCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&mgr.Version, "version", "", "version of OLM resources to uninstall.")
-	cmd.Flags().StringVar(&mgr.OLMNamespace, "olm-namespace", installer.DefaultOLMNamespace,
-		"namespace from where OLM is to be uninstalled.")
+	cmd.Flags().StringVar(&mgr.OLMNamespace, "olm-namespace", "",
+		"namespace from where OLM is to be uninstalled. If unset, the namespace recorded by olm install is used, "+
+			"or \"olm\" if none was recorded")
+	cmd.Flags().StringVar(&mgr.ManifestsDir, "olm-manifests-dir", "",
+		"local directory containing the crds.yaml and olm.yaml OLM was installed from. If unset, the directory recorded by olm install --olm-manifests-dir is used, if any")
+	cmd.Flags().BoolVar(&mgr.Force, "force", false,
+		"remove finalizers of CSVs, Subscriptions, InstallPlans, and OperatorGroups in OLM's namespaces "+
+			"that are not deleted within --force-grace-period, so stuck objects and namespaces can terminate. "+
+			"Objects outside OLM's namespaces are never modified")
+	cmd.Flags().DurationVar(&mgr.ForceGracePeriod, "force-grace-period", installer.DefaultForceGracePeriod,
+		"time to wait for resources to be deleted before --force releases finalizers")
+	cmd.Flags().BoolVar(&mgr.PreserveCRDs, "preserve-crds", false,
+		"keep OLM's CRDs, and with them all Subscriptions, CSVs, and other OLM resources outside OLM's namespaces. "+
+			"Operators they installed keep running unmanaged until olm install is run again")
 	mgr.AddToFlagSet(cmd.Flags())
 	return cmd
 }
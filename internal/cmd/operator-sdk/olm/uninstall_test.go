@@ -35,8 +35,24 @@ var _ = Describe("Running an olm uninstall command", func() {
 
 			flag = cmd.Flags().Lookup("olm-namespace")
 			Expect(flag).NotTo(BeNil())
-			Expect(flag.DefValue).To(Equal(installer.DefaultOLMNamespace))
+			Expect(flag.DefValue).To(Equal(""))
 			Expect(flag.Usage).NotTo(BeNil())
+
+			flag = cmd.Flags().Lookup("olm-manifests-dir")
+			Expect(flag).NotTo(BeNil())
+			Expect(flag.DefValue).To(Equal(""))
+
+			flag = cmd.Flags().Lookup("force")
+			Expect(flag).NotTo(BeNil())
+			Expect(flag.DefValue).To(Equal("false"))
+
+			flag = cmd.Flags().Lookup("force-grace-period")
+			Expect(flag).NotTo(BeNil())
+			Expect(flag.DefValue).To(Equal(installer.DefaultForceGracePeriod.String()))
+
+			flag = cmd.Flags().Lookup("preserve-crds")
+			Expect(flag).NotTo(BeNil())
+			Expect(flag.DefValue).To(Equal("false"))
 		})
 	})
 })
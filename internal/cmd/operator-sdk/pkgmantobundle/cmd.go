@@ -0,0 +1,110 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgmantobundle
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry"
+)
+
+const longHelp = `
+'pkgman-to-bundle' converts a package manifests directory into one bundle directory per
+operator version. Each bundle contains the version's manifests, a metadata/annotations.yaml,
+and a bundle.Dockerfile. The channels each version is in, found by following 'replaces'
+from each channel's current CSV in the package manifest, are written to the bundle's
+channels annotation. The package's default channel is written to the bundle's default channel
+annotation if the bundle is in that channel, otherwise the bundle's first channel is.
+
+Bundles are written to '<output-dir>/bundle-<version>'. If '--image-tag-base' is set,
+each bundle image is built and tagged as '<image-tag-base>:v<version>'. Images are not pushed.
+
+More information on bundles:
+https://github.com/operator-framework/operator-registry/#manifest-format
+`
+
+const examples = `
+  # Convert a package manifests directory into bundles:
+  $ tree packagemanifests
+  packagemanifests
+  ├── 0.0.1
+  │   ├── cache.example.com_memcacheds.yaml
+  │   └── memcached-operator.clusterserviceversion.yaml
+  ├── 0.0.2
+  │   ├── cache.example.com_memcacheds.yaml
+  │   └── memcached-operator.clusterserviceversion.yaml
+  └── memcached-operator.package.yaml
+  $ operator-sdk pkgman-to-bundle packagemanifests --output-dir bundles
+  $ tree bundles
+  bundles
+  ├── bundle-0.0.1
+  │   ├── bundle.Dockerfile
+  │   ├── manifests
+  │   │   ├── cache.example.com_memcacheds.yaml
+  │   │   └── memcached-operator.clusterserviceversion.yaml
+  │   └── metadata
+  │       └── annotations.yaml
+  └── bundle-0.0.2
+      ├── bundle.Dockerfile
+      ├── manifests
+      │   ├── cache.example.com_memcacheds.yaml
+      │   └── memcached-operator.clusterserviceversion.yaml
+      └── metadata
+          └── annotations.yaml
+
+  # Also build bundle images quay.io/example/memcached-operator-bundle:v0.0.1 and :v0.0.2:
+  $ operator-sdk pkgman-to-bundle packagemanifests --output-dir bundles \
+      --image-tag-base quay.io/example/memcached-operator-bundle
+`
+
+// NewCmd returns the 'pkgman-to-bundle' command.
+func NewCmd() *cobra.Command {
+	c := newPkgManToBundleCmd()
+	cmd := &cobra.Command{
+		Use:     "pkgman-to-bundle <packagemanifests-dir>",
+		Short:   "Convert a package manifests directory into bundles",
+		Long:    longHelp,
+		Example: examples,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: func(*cobra.Command, []string) error {
+			return c.validate()
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			bundleDirs, err := c.convert(args[0])
+			if err != nil {
+				return err
+			}
+			for _, dir := range bundleDirs {
+				log.Infof("Bundle written to %s", dir)
+			}
+			return nil
+		},
+	}
+	c.addFlagsTo(cmd.Flags())
+	return cmd
+}
+
+func (c *pkgManToBundleCmd) addFlagsTo(fs *pflag.FlagSet) {
+	fs.StringVar(&c.outputDir, "output-dir", defaultOutputDir, "Directory to write bundle directories to")
+	fs.StringVar(&c.imageTagBase, "image-tag-base", "", "Image repository to build and tag each bundle image in, "+
+		"ex. quay.io/example/memcached-operator-bundle. Each image is tagged with 'v' followed by its bundle's version. "+
+		"Images are not built if unset")
+	fs.StringVar(&c.containerTool, "container-tool", registry.ContainerToolDocker,
+		fmt.Sprintf("Tool used to build bundle images, one of: %s, %s", registry.ContainerToolDocker, registry.ContainerToolPodman))
+}
@@ -0,0 +1,228 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgmantobundle
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/operator-registry/pkg/lib/bundle"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry"
+	"github.com/operator-framework/operator-sdk/internal/util/projutil"
+)
+
+// defaultOutputDir is the default directory bundle directories are written to.
+const defaultOutputDir = "bundles"
+
+type pkgManToBundleCmd struct {
+	outputDir     string
+	imageTagBase  string
+	containerTool string
+
+	// run runs a container tool command. Defaults to running cmd with output
+	// sent to the debug log.
+	run func(cmd *exec.Cmd) error
+}
+
+func newPkgManToBundleCmd() *pkgManToBundleCmd {
+	return &pkgManToBundleCmd{
+		outputDir:     defaultOutputDir,
+		containerTool: registry.ContainerToolDocker,
+		run:           projutil.RunCmd,
+	}
+}
+
+func (c pkgManToBundleCmd) validate() error {
+	if c.outputDir == "" {
+		return fmt.Errorf("--output-dir must be set")
+	}
+	switch c.containerTool {
+	case registry.ContainerToolDocker, registry.ContainerToolPodman:
+	default:
+		return fmt.Errorf("unknown container tool %q, must be one of: %s, %s",
+			c.containerTool, registry.ContainerToolDocker, registry.ContainerToolPodman)
+	}
+	return nil
+}
+
+// convert writes a bundle directory for each version in the package manifests
+// in pkgManDir, optionally building each bundle's image, and returns the
+// bundle directories in version order.
+func (c pkgManToBundleCmd) convert(pkgManDir string) ([]string, error) {
+	pkg, bundles, err := apimanifests.GetManifestsDir(pkgManDir)
+	if err != nil {
+		return nil, fmt.Errorf("error loading package manifests from %s: %v", pkgManDir, err)
+	}
+	if pkg == nil || pkg.PackageName == "" {
+		return nil, fmt.Errorf("no package manifest found in %s", pkgManDir)
+	}
+	if len(bundles) == 0 {
+		return nil, fmt.Errorf("no bundles found in %s", pkgManDir)
+	}
+	sort.Slice(bundles, func(i, j int) bool {
+		return bundles[i].CSV.Spec.Version.LT(bundles[j].CSV.Spec.Version.Version)
+	})
+
+	defaultChannel := pkg.DefaultChannelName
+	if defaultChannel == "" && len(pkg.Channels) == 1 {
+		defaultChannel = pkg.Channels[0].Name
+	}
+	channels := getBundleChannels(pkg, bundles)
+
+	var bundleDirs []string
+	for _, b := range bundles {
+		version := b.CSV.Spec.Version.String()
+		bundleChannels := channels[b.CSV.GetName()]
+		if len(bundleChannels) == 0 {
+			log.Warnf("Bundle %s is not in any channel, adding it to default channel %q", b.CSV.GetName(), defaultChannel)
+			bundleChannels = []string{defaultChannel}
+		}
+		bundleDir := filepath.Join(c.outputDir, "bundle-"+version)
+		if err := writeBundle(bundleDir, pkg.PackageName, b, bundleChannels, getBundleDefaultChannel(defaultChannel, bundleChannels)); err != nil {
+			return nil, fmt.Errorf("error writing bundle %s: %v", b.CSV.GetName(), err)
+		}
+		if c.imageTagBase != "" {
+			if err := c.buildImage(bundleDir, c.imageTagBase+":v"+version); err != nil {
+				return nil, err
+			}
+		}
+		bundleDirs = append(bundleDirs, bundleDir)
+	}
+	return bundleDirs, nil
+}
+
+// getBundleChannels returns the sorted names of channels each bundle in
+// bundles is in, keyed by CSV name. A bundle is in a channel if it is on the
+// replaces chain of the channel's current CSV.
+func getBundleChannels(pkg *apimanifests.PackageManifest, bundles []*apimanifests.Bundle) map[string][]string {
+	bundlesByName := make(map[string]*apimanifests.Bundle, len(bundles))
+	for _, b := range bundles {
+		bundlesByName[b.CSV.GetName()] = b
+	}
+	channels := map[string][]string{}
+	for _, ch := range pkg.Channels {
+		seen := map[string]bool{}
+		for name := ch.CurrentCSVName; name != "" && !seen[name]; {
+			seen[name] = true
+			b, ok := bundlesByName[name]
+			if !ok {
+				break
+			}
+			channels[name] = append(channels[name], ch.Name)
+			name = b.CSV.Spec.Replaces
+		}
+	}
+	for _, names := range channels {
+		sort.Strings(names)
+	}
+	return channels
+}
+
+// getBundleDefaultChannel returns the package's default channel if the bundle
+// is in it, since a bundle's default channel must be one of its channels.
+// Otherwise the bundle's first channel is returned.
+func getBundleDefaultChannel(defaultChannel string, bundleChannels []string) string {
+	for _, ch := range bundleChannels {
+		if ch == defaultChannel {
+			return defaultChannel
+		}
+	}
+	return bundleChannels[0]
+}
+
+// writeBundle writes b's manifests, metadata, and Dockerfile to dir, which
+// must not exist.
+func writeBundle(dir, pkgName string, b *apimanifests.Bundle, channels []string, defaultChannel string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("bundle directory %s already exists", dir)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	manifestsDir := filepath.Join(dir, bundle.ManifestsDir)
+	if err := os.MkdirAll(manifestsDir, 0755); err != nil {
+		return err
+	}
+	for _, obj := range b.Objects {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return fmt.Errorf("error marshaling %s %q: %v", obj.GetKind(), obj.GetName(), err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(manifestsDir, makeObjectFileName(pkgName, obj)), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	metadataDir := filepath.Join(dir, bundle.MetadataDir)
+	if err := os.MkdirAll(metadataDir, 0755); err != nil {
+		return err
+	}
+	chans := strings.Join(channels, ",")
+	annotations, err := bundle.GenerateAnnotations(bundle.RegistryV1Type, bundle.ManifestsDir, bundle.MetadataDir,
+		pkgName, chans, defaultChannel)
+	if err != nil {
+		return fmt.Errorf("error generating annotations: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(metadataDir, bundle.AnnotationsFile), annotations, 0644); err != nil {
+		return err
+	}
+
+	dockerfile, err := bundle.GenerateDockerfile(bundle.RegistryV1Type, bundle.ManifestsDir, bundle.MetadataDir,
+		manifestsDir, metadataDir, dir, pkgName, chans, defaultChannel)
+	if err != nil {
+		return fmt.Errorf("error generating Dockerfile: %v", err)
+	}
+	return ioutil.WriteFile(filepath.Join(dir, bundle.DockerFile), dockerfile, 0644)
+}
+
+// makeObjectFileName returns a file name for obj following the names used by
+// 'generate bundle'.
+func makeObjectFileName(pkgName string, obj *unstructured.Unstructured) string {
+	gvk := obj.GroupVersionKind()
+	switch gvk.Kind {
+	case "ClusterServiceVersion":
+		return pkgName + ".clusterserviceversion.yaml"
+	case "CustomResourceDefinition":
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		plural, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "plural")
+		if group != "" && plural != "" {
+			return fmt.Sprintf("%s_%s.yaml", group, plural)
+		}
+	}
+	if gvk.Group == "" {
+		return fmt.Sprintf("%s_%s_%s.yaml", obj.GetName(), gvk.Version, strings.ToLower(gvk.Kind))
+	}
+	return fmt.Sprintf("%s_%s_%s_%s.yaml", obj.GetName(), gvk.Group, gvk.Version, strings.ToLower(gvk.Kind))
+}
+
+// buildImage builds the bundle image in dir and tags it as image.
+func (c pkgManToBundleCmd) buildImage(dir, image string) error {
+	log.Infof("Building bundle image %s", image)
+	build := exec.Command(c.containerTool, "build", "-f", filepath.Join(dir, bundle.DockerFile), "-t", image, dir)
+	if err := c.run(build); err != nil {
+		return fmt.Errorf("error building bundle image %s: %v", image, err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgmantobundle
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/operator-registry/pkg/lib/bundle"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("pkgman-to-bundle", func() {
+	var (
+		c       *pkgManToBundleCmd
+		tmp     string
+		pkgDir  = filepath.Join("testdata", "kvstore-operator")
+		cmdArgs [][]string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmp, err = ioutil.TempDir("", "pkgman-to-bundle-")
+		Expect(err).NotTo(HaveOccurred())
+		cmdArgs = nil
+		c = newPkgManToBundleCmd()
+		c.outputDir = filepath.Join(tmp, "bundles")
+		c.run = func(cmd *exec.Cmd) error {
+			cmdArgs = append(cmdArgs, cmd.Args)
+			return nil
+		}
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	readAnnotations := func(bundleDir string) map[string]string {
+		b, err := ioutil.ReadFile(filepath.Join(bundleDir, bundle.MetadataDir, bundle.AnnotationsFile))
+		Expect(err).NotTo(HaveOccurred())
+		metadata := struct {
+			Annotations map[string]string `json:"annotations"`
+		}{}
+		Expect(yaml.Unmarshal(b, &metadata)).To(Succeed())
+		return metadata.Annotations
+	}
+
+	It("should write a bundle directory for each version", func() {
+		dirs, err := c.convert(pkgDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dirs).To(Equal([]string{
+			filepath.Join(c.outputDir, "bundle-0.0.1"),
+			filepath.Join(c.outputDir, "bundle-0.0.2"),
+		}))
+		for _, dir := range dirs {
+			Expect(filepath.Join(dir, bundle.ManifestsDir, "kvstore-operator.clusterserviceversion.yaml")).To(BeARegularFile())
+			dockerfile, err := ioutil.ReadFile(filepath.Join(dir, bundle.DockerFile))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(dockerfile)).To(ContainSubstring("COPY manifests /manifests/"))
+			Expect(string(dockerfile)).To(ContainSubstring("COPY metadata /metadata/"))
+		}
+		Expect(cmdArgs).To(BeEmpty())
+	})
+
+	It("should map package channels to bundle annotations", func() {
+		dirs, err := c.convert(pkgDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		// v0.0.1 is replaced in alpha and is the head of the default channel stable.
+		annotations := readAnnotations(dirs[0])
+		Expect(annotations).To(HaveKeyWithValue("operators.operatorframework.io.bundle.package.v1", "kvstore-operator"))
+		Expect(annotations).To(HaveKeyWithValue("operators.operatorframework.io.bundle.channels.v1", "alpha,stable"))
+		Expect(annotations).To(HaveKeyWithValue("operators.operatorframework.io.bundle.channel.default.v1", "stable"))
+
+		// v0.0.2 is not in the default channel, so its default is its only channel.
+		annotations = readAnnotations(dirs[1])
+		Expect(annotations).To(HaveKeyWithValue("operators.operatorframework.io.bundle.channels.v1", "alpha"))
+		Expect(annotations).To(HaveKeyWithValue("operators.operatorframework.io.bundle.channel.default.v1", "alpha"))
+	})
+
+	It("should build and tag bundle images if an image tag base is set", func() {
+		c.imageTagBase = "quay.io/example/kvstore-operator-bundle"
+		c.containerTool = "podman"
+		dirs, err := c.convert(pkgDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cmdArgs).To(Equal([][]string{
+			{"podman", "build", "-f", filepath.Join(dirs[0], "bundle.Dockerfile"), "-t", "quay.io/example/kvstore-operator-bundle:v0.0.1", dirs[0]},
+			{"podman", "build", "-f", filepath.Join(dirs[1], "bundle.Dockerfile"), "-t", "quay.io/example/kvstore-operator-bundle:v0.0.2", dirs[1]},
+		}))
+	})
+
+	It("should fail if an image build fails", func() {
+		c.imageTagBase = "quay.io/example/kvstore-operator-bundle"
+		c.run = func(*exec.Cmd) error { return errors.New("no space left on device") }
+		_, err := c.convert(pkgDir)
+		Expect(err).To(MatchError("error building bundle image quay.io/example/kvstore-operator-bundle:v0.0.1: no space left on device"))
+	})
+
+	It("should not overwrite existing bundle directories", func() {
+		Expect(os.MkdirAll(filepath.Join(c.outputDir, "bundle-0.0.1"), 0755)).To(Succeed())
+		_, err := c.convert(pkgDir)
+		Expect(err).To(MatchError(ContainSubstring("bundle directory " + filepath.Join(c.outputDir, "bundle-0.0.1") + " already exists")))
+	})
+
+	It("should fail if the directory does not contain package manifests", func() {
+		_, err := c.convert(tmp)
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("validate", func() {
+		It("should reject unknown container tools", func() {
+			c.containerTool = "buildah"
+			Expect(c.validate()).To(MatchError(`unknown container tool "buildah", must be one of: docker, podman`))
+		})
+		It("should require an output directory", func() {
+			c.outputDir = ""
+			Expect(c.validate()).To(MatchError("--output-dir must be set"))
+		})
+	})
+})
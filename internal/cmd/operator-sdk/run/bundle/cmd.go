@@ -16,17 +16,27 @@ package bundle
 
 import (
 	"context"
+	"os"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
+	"github.com/operator-framework/operator-sdk/internal/flags"
+	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
 	"github.com/operator-framework/operator-sdk/internal/olm/operator"
 	"github.com/operator-framework/operator-sdk/internal/olm/operator/bundle"
 )
 
+// defaultTimeout is longer than run packagemanifests', since registry pods and
+// OLM's bundle unpack jobs pull images.
+const defaultTimeout = 5 * time.Minute
+
 func NewCmd(cfg *operator.Configuration) *cobra.Command {
 	var timeout time.Duration
+	var canary bool
+	var output string
 
 	i := bundle.NewInstall(cfg)
 	cmd := &cobra.Command{
@@ -34,16 +44,33 @@ func NewCmd(cfg *operator.Configuration) *cobra.Command {
 		Short: "Deploy an Operator in the bundle format with OLM",
 		Args:  cobra.ExactArgs(1),
 		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg.Verbose = viper.GetBool(flags.VerboseOpt)
 			return cfg.Load()
 		},
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			i.BundleImage = args[0]
+			outputOpt := operator.StringOption("Output", "--output", &output)
+			return i.OptionRules().Append(operator.OptionRules{Rules: []operator.OptionRule{
+				operator.Constraint(func() error { return olmclient.ValidateOutputFormat(output) }, outputOpt),
+				operator.MutuallyExclusive(operator.BoolOption("Canary", "--canary", &canary), outputOpt),
+			}}).Validate()
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
 			defer cancel()
 
-			i.BundleImage = args[0]
+			if canary {
+				operator.RunCanaryOrDie(ctx, cfg, &i)
+				return
+			}
 
 			// TODO(joelanford): Add cleanup logic if this fails?
 			_, err := i.Run(ctx)
+			if output != "" {
+				if werr := i.Result().WriteWithError(os.Stdout, output, err); werr != nil {
+					logrus.Fatalf("Failed to write install result: %v\n", werr)
+				}
+			}
 			if err != nil {
 				logrus.Fatalf("Failed to run bundle: %v\n", err)
 			}
@@ -51,8 +78,18 @@ func NewCmd(cfg *operator.Configuration) *cobra.Command {
 	}
 	cmd.Flags().SortFlags = false
 	cfg.BindFlags(cmd.PersistentFlags())
+	cfg.BindSchemaDriftFlags(cmd.PersistentFlags())
 	i.BindFlags(cmd.Flags())
 
-	cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "install timeout")
+	cmd.Flags().DurationVar(&timeout, "timeout", defaultTimeout,
+		"Time to wait for the whole install, including pulling bundle and index images. "+
+			"On expiry, the error names the furthest install stage reached and the state of its object")
+	cmd.Flags().BoolVar(&canary, "canary", false,
+		"Install the operator, verify it, then uninstall everything that was created. "+
+			"The exit status reflects only the verification outcome")
+	cmd.Flags().StringVarP(&output, "output", "o", "",
+		"Print the install result to stdout in this format, one of: json, yaml. "+
+			"The result names the installed CSV, its CatalogSource, Subscription, and Deployments, "+
+			"and is printed with the failed stage and error if the install fails. Logs are written to stderr")
 	return cmd
 }
@@ -0,0 +1,72 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundleupgrade
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/operator-framework/operator-sdk/internal/flags"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/bundle"
+)
+
+// defaultTimeout matches run bundle's, since the registry pod and OLM's bundle
+// unpack job pull images.
+const defaultTimeout = 5 * time.Minute
+
+func NewCmd(cfg *operator.Configuration) *cobra.Command {
+	var timeout time.Duration
+
+	u := bundle.NewUpgrade(cfg)
+	cmd := &cobra.Command{
+		Use:   "bundle-upgrade <bundle-image>",
+		Short: "Upgrade an Operator previously installed in the bundle format with OLM",
+		Long: `Upgrade an Operator installed by 'run bundle' to the Operator in <bundle-image>.
+The bundle is added to the catalog the Operator was installed from, and the upgrade
+InstallPlan OLM creates is approved. Operators not installed from a catalog created
+by 'run bundle' are not upgraded.`,
+		Args: cobra.ExactArgs(1),
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg.Verbose = viper.GetBool(flags.VerboseOpt)
+			return cfg.Load()
+		},
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			u.BundleImage = args[0]
+			return u.Validate()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+
+			if _, err := u.Run(ctx); err != nil {
+				logrus.Fatalf("Failed to run bundle upgrade: %v\n", err)
+			}
+		},
+	}
+	cmd.Flags().SortFlags = false
+	cfg.BindFlags(cmd.PersistentFlags())
+	cfg.BindSchemaDriftFlags(cmd.PersistentFlags())
+	u.BindFlags(cmd.Flags())
+
+	cmd.Flags().DurationVar(&timeout, "timeout", defaultTimeout,
+		"Time to wait for the whole upgrade, including pulling bundle and index images. "+
+			"On expiry, the error names the furthest upgrade stage reached and the state of its object")
+	return cmd
+}
@@ -35,6 +35,7 @@ Currently only the package manifests format is supported via the 'packagemanifes
 	cmd.AddCommand(
 		// TODO(joelanford): enable bundle command when implementation is complete
 		// bundle.NewCmd(cfg),
+		// bundleupgrade.NewCmd(cfg),
 		packagemanifests.NewCmd(cfg),
 	)
 
@@ -16,17 +16,23 @@ package packagemanifests
 
 import (
 	"context"
+	"os"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
+	"github.com/operator-framework/operator-sdk/internal/flags"
+	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
 	"github.com/operator-framework/operator-sdk/internal/olm/operator"
 	"github.com/operator-framework/operator-sdk/internal/olm/operator/packagemanifests"
 )
 
 func NewCmd(cfg *operator.Configuration) *cobra.Command {
 	var timeout time.Duration
+	var canary bool
+	var output string
 
 	i := packagemanifests.NewInstall(cfg)
 	cmd := &cobra.Command{
@@ -34,22 +40,66 @@ func NewCmd(cfg *operator.Configuration) *cobra.Command {
 		Short: "Deploy an Operator in the package manifests format with OLM",
 		Long: `'run packagemanifests' deploys an Operator's package manifests with OLM. The command's argument
 will default to './packagemanifests' if unset; if set, the argument must be a package manifests root directory,
-ex. '<project-root>/packagemanifests'.`,
-		Aliases:           []string{"pm"},
-		Args:              cobra.MaximumNArgs(1),
-		PersistentPreRunE: func(_ *cobra.Command, _ []string) error { return cfg.Load() },
-		Run: func(cmd *cobra.Command, args []string) {
-			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
-			defer cancel()
+ex. '<project-root>/packagemanifests'.
 
+Set '--snapshot-file' to a cluster snapshot written by 'olm snapshot' to check, without accessing the cluster,
+that the operator could be installed instead of installing it. A line is printed for each check: whether OLM is
+installed, whether the install namespace's OperatorGroup is compatible with the install mode, and whether the
+cluster serves the operator's CRD versions or, if '--skip-crds' is set, its skipped CRDs. Checks of state not
+captured in the snapshot are reported as skipped, and the command fails if any check fails.`,
+		Aliases: []string{"pm"},
+		Args:    cobra.MaximumNArgs(1),
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg.Verbose = viper.GetBool(flags.VerboseOpt)
+			if i.SnapshotFile != "" {
+				// Preflight checks against a snapshot do not access the cluster.
+				return cfg.LoadOffline()
+			}
+			return cfg.Load()
+		},
+		PreRunE: func(_ *cobra.Command, args []string) error {
 			if len(args) == 0 {
 				i.PackageManifestsDirectory = "packagemanifests"
 			} else {
 				i.PackageManifestsDirectory = args[0]
 			}
+			dryRun := operator.Option{Field: "DryRun", Flag: "--dry-run", IsSet: func() bool {
+				return i.DryRun == packagemanifests.DryRunClient
+			}}
+			canaryOpt := operator.BoolOption("Canary", "--canary", &canary)
+			outputOpt := operator.StringOption("Output", "--output", &output)
+			snapshotFile := operator.StringOption("SnapshotFile", "--snapshot-file", &i.SnapshotFile)
+			return i.OptionRules().Append(operator.OptionRules{Rules: []operator.OptionRule{
+				operator.MutuallyExclusive(canaryOpt, dryRun),
+				operator.Constraint(func() error { return olmclient.ValidateOutputFormat(output) }, outputOpt),
+				operator.MutuallyExclusive(canaryOpt, outputOpt),
+				operator.MutuallyExclusive(dryRun, outputOpt),
+				operator.MutuallyExclusive(canaryOpt, snapshotFile),
+				operator.MutuallyExclusive(snapshotFile, outputOpt),
+			}}).Validate()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+
+			if canary {
+				operator.RunCanaryOrDie(ctx, cfg, &i)
+				return
+			}
 
 			// TODO(joelanford): Add cleanup logic if this fails?
 			_, err := i.Run(ctx)
+			if i.SnapshotFile != "" {
+				if err != nil {
+					log.Fatalf("Failed to run packagemanifests preflight checks: %v\n", err)
+				}
+				return
+			}
+			if output != "" {
+				if werr := i.Result().WriteWithError(os.Stdout, output, err); werr != nil {
+					log.Fatalf("Failed to write install result: %v\n", werr)
+				}
+			}
 			if err != nil {
 				log.Fatalf("Failed to run packagemanifests: %v\n", err)
 			}
@@ -57,8 +107,16 @@ ex. '<project-root>/packagemanifests'.`,
 	}
 	cmd.Flags().SortFlags = false
 	cfg.BindFlags(cmd.PersistentFlags())
+	cfg.BindSchemaDriftFlags(cmd.PersistentFlags())
 	i.BindFlags(cmd.Flags())
 
 	cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "install timeout")
+	cmd.Flags().BoolVar(&canary, "canary", false,
+		"Install the operator, verify it, then uninstall everything that was created. "+
+			"The exit status reflects only the verification outcome")
+	cmd.Flags().StringVarP(&output, "output", "o", "",
+		"Print the install result to stdout in this format, one of: json, yaml. "+
+			"The result names the installed CSV, its CatalogSource, Subscriptions, and Deployments, "+
+			"and is printed with the failed stage and error if the install fails. Logs are written to stderr")
 	return cmd
 }
@@ -33,6 +33,14 @@ var _ = Describe("Running a run packagemanifests command", func() {
 			aliases := cmd.Aliases
 			Expect(len(aliases)).To(Equal(1))
 			Expect(aliases[0]).To(Equal("pm"))
+			flag := cmd.Flags().Lookup("output")
+			Expect(flag).NotTo(BeNil())
+			Expect(flag.Shorthand).To(Equal("o"))
+			Expect(flag.DefValue).To(BeEmpty())
+			Expect(cmd.PersistentFlags().Lookup("skip-schema-drift-check")).NotTo(BeNil())
+			for _, name := range []string{"catalog-ready-timeout", "subscription-resolve-timeout", "csv-succeeded-timeout"} {
+				Expect(cmd.Flags().Lookup(name)).NotTo(BeNil(), name)
+			}
 		})
 	})
 })
@@ -46,6 +46,16 @@ type ClusterServiceVersion struct {
 	GVKs []schema.GroupVersionKind
 	// Interactive turns on an interactive prompt.
 	Interactive bool
+	// MetadataPath is the path to a YAML or JSON file containing UI metadata.
+	// Fields set in this file are not prompted for if Interactive is true.
+	MetadataPath string
+	// ProjectMetadata is UI metadata saved in the project config. Its fields are used if not set
+	// in the MetadataPath file, are not prompted for, and replace those in the base at BasePath.
+	// GetBase sets ProjectMetadata to all UI metadata applied to the base so it can be saved.
+	ProjectMetadata *UIMetadata
+	// IconPath is the path to a png, jpeg, gif, or svg image file set as the
+	// CSV's icon, replacing any existing icon.
+	IconPath string
 
 	// Fields for input to the base.
 	DisplayName  string
@@ -56,7 +66,7 @@ type ClusterServiceVersion struct {
 	Provider     v1alpha1.AppLink
 	Links        []v1alpha1.AppLink
 	Maintainers  []v1alpha1.Maintainer
-	Icon         []v1alpha1.Icon // TODO(estroz): read icon bytes from files.
+	Icon         []v1alpha1.Icon
 }
 
 // GetBase returns a base v1alpha1.ClusterServiceVersion, populated
@@ -71,11 +81,39 @@ func (b ClusterServiceVersion) GetBase() (base *v1alpha1.ClusterServiceVersion,
 		base = b.makeNewBase()
 	}
 
-	// Interactively fill in UI metadata.
+	// Fill in UI metadata from a file, then the project config, then interactively.
+	meta := &uiMetadata{}
+	if b.MetadataPath != "" {
+		if meta, err = readUIMetadata(b.MetadataPath); err != nil {
+			return nil, err
+		}
+	}
+	if b.ProjectMetadata != nil {
+		projectMeta, err := newUIMetadata(*b.ProjectMetadata)
+		if err != nil {
+			return nil, fmt.Errorf("invalid UI metadata in project config: %v", err)
+		}
+		// Only an existing base can conflict with the project config.
+		var existing *v1alpha1.ClusterServiceVersion
+		if b.BasePath != "" {
+			existing = base
+		}
+		meta.setProjectMetadata(projectMeta, existing)
+	}
 	if b.Interactive {
-		meta := &uiMetadata{}
 		meta.runInteractivePrompt()
-		meta.apply(base)
+	}
+	meta.apply(base)
+	if b.ProjectMetadata != nil {
+		*b.ProjectMetadata = meta.toUIMetadata()
+	}
+
+	if b.IconPath != "" {
+		icon, err := readIcon(b.IconPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading icon: %v", err)
+		}
+		base.Spec.Icon = []v1alpha1.Icon{icon}
 	}
 
 	if b.APIsDir != "" {
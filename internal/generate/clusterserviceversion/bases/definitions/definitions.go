@@ -15,9 +15,10 @@
 package definitions
 
 import (
-	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
 	log "github.com/sirupsen/logrus"
@@ -42,40 +43,45 @@ func ApplyDefinitionsForKeysGo(csv *v1alpha1.ClusterServiceVersion, apisRootDir
 	// These are usually '(pkg/)?apis/(<group>/)?<version>'.
 	// NB(estroz): using "leaf" packages prevents type builders from searching other packages.
 	// It would be nice to implement extra-package traversal in the future.
-	paths, err := makeAPIPaths(apisRootDir, gvks)
+	// Some APIs may not exist under apisRootDir, so no packages are loaded for them.
+	gvksByPath, err := makeAPIPaths(apisRootDir, gvks)
 	if err != nil {
 		return err
 	}
-	// Some APIs may not exist under apisRootDir, so skip loading packages if no paths are found
-	if len(paths) == 0 {
-		return nil
+	paths := make([]string, 0, len(gvksByPath))
+	for path := range gvksByPath {
+		paths = append(paths, path)
 	}
+	sort.Strings(paths)
 
-	// Collect Go types from roots.
-	g := &generator{}
-	ctx, err := g.contextForRoots(paths...)
-	if err != nil {
-		return err
-	}
-	g.needTypes(ctx)
-	if loader.PrintErrors(ctx.Roots, packages.TypeError) {
-		return errors.New("one or more API packages had type errors")
-	}
-
-	// Create definitions for kind types found under the collected roots.
+	// Types are collected from one root at a time, since types in different groups of
+	// a multigroup project can have the same name.
 	definitionsByGVK := make(map[schema.GroupVersionKind]*descriptionValues)
-	for _, gvk := range gvks {
-		kindType, hasKind := g.types[gvk.Kind]
-		if !hasKind {
-			log.Warnf("Skipping CSV annotation parsing for API %s: type %s not found", gvk, gvk.Kind)
-			continue
-		}
-		crd, err := g.buildCRDDescriptionFromType(gvk, kindType)
+	for _, path := range paths {
+		g := &generator{}
+		ctx, err := g.contextForRoots(path)
 		if err != nil {
 			return err
 		}
-		definitionsByGVK[gvk] = &descriptionValues{
-			crd: crd,
+		g.needTypes(ctx)
+		if err := getRootErrors(ctx.Roots); err != nil {
+			return fmt.Errorf("error parsing API markers: %v", err)
+		}
+
+		// Create definitions for kind types found under the collected root.
+		for _, gvk := range gvksByPath[path] {
+			kindType, hasKind := g.types[gvk.Kind]
+			if !hasKind {
+				log.Warnf("Skipping CSV annotation parsing for API %s: type %s not found", gvk, gvk.Kind)
+				continue
+			}
+			crd, err := g.buildCRDDescriptionFromType(gvk, kindType)
+			if err != nil {
+				return err
+			}
+			definitionsByGVK[gvk] = &descriptionValues{
+				crd: crd,
+			}
 		}
 	}
 
@@ -85,12 +91,15 @@ func ApplyDefinitionsForKeysGo(csv *v1alpha1.ClusterServiceVersion, apisRootDir
 	return nil
 }
 
-// makeAPIPaths creates a set of API directory paths with apisRootDir as their parent.
-func makeAPIPaths(apisRootDir string, gvks []schema.GroupVersionKind) (paths []string, err error) {
-	if apisRootDir, err = filepath.Abs(apisRootDir); err != nil {
+// makeAPIPaths creates a set of API directory paths with apisRootDir as their parent,
+// each mapped to the gvks with types expected in that directory.
+func makeAPIPaths(apisRootDir string, gvks []schema.GroupVersionKind) (map[string][]schema.GroupVersionKind, error) {
+	apisRootDir, err := filepath.Abs(apisRootDir)
+	if err != nil {
 		return nil, err
 	}
 
+	gvksByPath := make(map[string][]schema.GroupVersionKind)
 	for _, gvk := range gvks {
 		// Check if the kind pkg is at the expected layout.
 		group := MakeGroupFromFullGroup(gvk.Group)
@@ -102,9 +111,9 @@ func makeAPIPaths(apisRootDir string, gvks []schema.GroupVersionKind) (paths []s
 			log.Warnf("Skipping CSV annotation parsing for API %s: directory does not exist", gvk)
 			continue
 		}
-		paths = append(paths, expectedPkgPath)
+		gvksByPath[expectedPkgPath] = append(gvksByPath[expectedPkgPath], gvk)
 	}
-	return paths, nil
+	return gvksByPath, nil
 }
 
 // updateDefinitionsByKey updates owned definitions that already exist in csv or adds new definitions that do not.
@@ -124,9 +133,16 @@ func updateDefinitionsByKey(csv *v1alpha1.ClusterServiceVersion, defsByGVK map[s
 		}
 	}
 
-	// Add any new crdDescriptions to the CSV.
-	for _, values := range defsByGVK {
-		csv.Spec.CustomResourceDefinitions.Owned = append(csv.Spec.CustomResourceDefinitions.Owned, values.crd)
+	// Add any new crdDescriptions to the CSV in a stable order.
+	gvks := make([]schema.GroupVersionKind, 0, len(defsByGVK))
+	for gvk := range defsByGVK {
+		gvks = append(gvks, gvk)
+	}
+	sort.Slice(gvks, func(i, j int) bool {
+		return gvks[i].String() < gvks[j].String()
+	})
+	for _, gvk := range gvks {
+		csv.Spec.CustomResourceDefinitions.Owned = append(csv.Spec.CustomResourceDefinitions.Owned, defsByGVK[gvk].crd)
 	}
 }
 
@@ -194,15 +210,53 @@ func (g *generator) contextForRoots(dirs ...string) (ctx *genall.GenerationConte
 	}, nil
 }
 
-// needTypes sets types in the generator for a given context.
+// needTypes sets types in the generator for a given context. Descriptor markers with an unknown type
+// are added to their root's errors, since they would otherwise be silently dropped.
 func (g *generator) needTypes(ctx *genall.GenerationContext) {
 	g.types = make(map[string]*markers.TypeInfo)
-	cb := func(info *markers.TypeInfo) {
-		g.types[info.Name] = info
-	}
 	for _, root := range ctx.Roots {
+		cb := func(info *markers.TypeInfo) {
+			g.types[info.Name] = info
+			for _, field := range info.Fields {
+				if err := checkDescriptorTypes(info, field); err != nil {
+					root.AddError(loader.ErrFromNode(err, field.RawField))
+				}
+			}
+		}
 		if err := markers.EachType(ctx.Collector, root, cb); err != nil {
 			root.AddError(err)
 		}
 	}
 }
+
+// checkDescriptorTypes returns an error if any of field's descriptor markers has a type other than spec or status.
+// An empty type is allowed, since such markers only set non-descriptor values like displayName.
+func checkDescriptorTypes(info *markers.TypeInfo, field markers.FieldInfo) error {
+	for _, marker := range field.Markers[crdMarkerName] {
+		d, isDescriptor := marker.(Descriptor)
+		if !isDescriptor {
+			continue
+		}
+		switch descType(d.Type) {
+		case "", specDescType, statusDescType:
+		default:
+			return fmt.Errorf("invalid descriptor type %q for field %s.%s, must be one of: %s, %s",
+				d.Type, info.Name, field.Name, specDescType, statusDescType)
+		}
+	}
+	return nil
+}
+
+// getRootErrors returns an error containing all non-type errors, such as syntax and marker errors, found in roots.
+// Each error is prefixed by its position, typically as "file:line:column".
+func getRootErrors(roots []*loader.Package) error {
+	errs := []error{}
+	for _, root := range roots {
+		for _, pkgErr := range root.Errors {
+			if pkgErr.Kind != packages.TypeError {
+				errs = append(errs, pkgErr)
+			}
+		}
+	}
+	return fmtParseErrors(errs)
+}
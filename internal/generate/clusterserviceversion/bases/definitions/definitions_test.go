@@ -15,6 +15,7 @@
 package definitions
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -22,6 +23,7 @@ import (
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/stretchr/testify/assert"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
 )
 
 // TODO(estroz): migrate to ginkgo/gomega
@@ -251,3 +253,52 @@ func TestApplyDefinitionsForKeysGo(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyDefinitionsForKeysGoGolden(t *testing.T) {
+	goldenPath := filepath.Join(testDataDir, "static", "dummy.clusterserviceversion.yaml")
+	b, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := &v1alpha1.ClusterServiceVersion{}
+	if err := yaml.Unmarshal(b, golden); err != nil {
+		t.Fatal(err)
+	}
+
+	csv := &v1alpha1.ClusterServiceVersion{}
+	gvks := []schema.GroupVersionKind{
+		{Group: "cache.example.com", Version: "v1alpha2", Kind: "OtherDummy"},
+		{Group: "cache.example.com", Version: "v1alpha2", Kind: "Dummy"},
+	}
+	if err := ApplyDefinitionsForKeysGo(csv, filepath.Join(testDataDir, "api"), gvks); err != nil {
+		t.Fatalf("Expected nil error, got %q", err)
+	}
+
+	// Compare marshaled output so empty and nil slices are treated the same.
+	expected, err := yaml.Marshal(golden.Spec.CustomResourceDefinitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := yaml.Marshal(csv.Spec.CustomResourceDefinitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(expected), string(actual))
+}
+
+func TestApplyDefinitionsForKeysGoInvalidMarkers(t *testing.T) {
+	csv := &v1alpha1.ClusterServiceVersion{}
+	gvks := []schema.GroupVersionKind{
+		{Group: "cache.example.com", Version: "v1beta1", Kind: "Invalid"},
+	}
+	err := ApplyDefinitionsForKeysGo(csv, filepath.Join(testDataDir, "api"), gvks)
+	if err == nil {
+		t.Fatal("Expected non-nil error, got nil error")
+	}
+	// Both errors should point at their source file and line.
+	assert.Contains(t, err.Error(), `invalid_types.go:25:`)
+	assert.Contains(t, err.Error(), `invalid descriptor type "sepc" for field InvalidSpec.Size`)
+	assert.Contains(t, err.Error(), `invalid_types.go:27:`)
+	assert.Contains(t, err.Error(), `displayNam`)
+	assert.Empty(t, csv.Spec.CustomResourceDefinitions.Owned)
+}
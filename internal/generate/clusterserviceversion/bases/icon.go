@@ -0,0 +1,77 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bases
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxIconSize is the icon file size above which a warning is logged, since
+// large icons bloat CSVs and slow down catalog UIs.
+const maxIconSize = 1 << 20
+
+const svgMediaType = "image/svg+xml"
+
+// supportedIconMediaTypes are image types OLM and OperatorHub can display,
+// other than SVG which cannot be detected by content alone.
+var supportedIconMediaTypes = map[string]struct{}{
+	"image/png":  {},
+	"image/jpeg": {},
+	"image/gif":  {},
+}
+
+// readIcon reads the image file at path into a base64-encoded CSV icon.
+func readIcon(path string) (v1alpha1.Icon, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return v1alpha1.Icon{}, err
+	}
+	mediaType, err := getIconMediaType(path, b)
+	if err != nil {
+		return v1alpha1.Icon{}, err
+	}
+	if len(b) > maxIconSize {
+		log.Warnf("Icon %s is %d bytes, larger than the recommended maximum of %d bytes", path, len(b), maxIconSize)
+	}
+	return v1alpha1.Icon{
+		Data:      base64.StdEncoding.EncodeToString(b),
+		MediaType: mediaType,
+	}, nil
+}
+
+// getIconMediaType returns the media type of icon data b read from path,
+// or an error if that type is not supported.
+func getIconMediaType(path string, b []byte) (string, error) {
+	if strings.EqualFold(filepath.Ext(path), ".svg") {
+		if !bytes.Contains(b, []byte("<svg")) {
+			return "", fmt.Errorf("icon %s has extension .svg but does not contain an <svg> element", path)
+		}
+		return svgMediaType, nil
+	}
+	mediaType := http.DetectContentType(b)
+	if _, supported := supportedIconMediaTypes[mediaType]; !supported {
+		return "", fmt.Errorf("icon %s has unsupported media type %q, must be one of png, jpeg, gif, or svg", path, mediaType)
+	}
+	return mediaType, nil
+}
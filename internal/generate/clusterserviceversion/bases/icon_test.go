@@ -0,0 +1,99 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bases
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Icon", func() {
+	var tmp string
+
+	pngData := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	svgData := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="1" height="1"></svg>
+`)
+
+	BeforeEach(func() {
+		var err error
+		tmp, err = ioutil.TempDir("", "icon-")
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	writeFile := func(name string, data []byte) string {
+		path := filepath.Join(tmp, name)
+		Expect(ioutil.WriteFile(path, data, 0644)).To(Succeed())
+		return path
+	}
+
+	It("reads a png icon", func() {
+		icon, err := readIcon(writeFile("icon.png", pngData))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(icon).To(Equal(v1alpha1.Icon{
+			Data:      base64.StdEncoding.EncodeToString(pngData),
+			MediaType: "image/png",
+		}))
+	})
+	It("reads an svg icon", func() {
+		icon, err := readIcon(writeFile("icon.svg", svgData))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(icon).To(Equal(v1alpha1.Icon{
+			Data:      base64.StdEncoding.EncodeToString(svgData),
+			MediaType: "image/svg+xml",
+		}))
+	})
+	It("warns about but reads an oversized icon", func() {
+		buf := &bytes.Buffer{}
+		log.SetOutput(buf)
+		defer log.SetOutput(os.Stderr)
+
+		data := append(append([]byte{}, pngData...), make([]byte, maxIconSize)...)
+		icon, err := readIcon(writeFile("icon.png", data))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(icon.MediaType).To(Equal("image/png"))
+		Expect(buf.String()).To(ContainSubstring("larger than the recommended maximum"))
+	})
+	It("returns an error for an unsupported format", func() {
+		_, err := readIcon(writeFile("icon.bmp", []byte("BM\x00\x00\x00\x00")))
+		Expect(err).To(MatchError(ContainSubstring(`unsupported media type "image/bmp"`)))
+		_, err = readIcon(writeFile("icon.txt", []byte("not an icon")))
+		Expect(err).To(MatchError(ContainSubstring("unsupported media type")))
+	})
+	It("returns an error for an svg file without an svg element", func() {
+		_, err := readIcon(writeFile("icon.svg", pngData))
+		Expect(err).To(MatchError(ContainSubstring("does not contain an <svg> element")))
+	})
+	It("sets a base's icon", func() {
+		b := ClusterServiceVersion{OperatorName: "test-operator", IconPath: writeFile("icon.svg", svgData)}
+		csv, err := b.GetBase()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(csv.Spec.Icon).To(Equal([]v1alpha1.Icon{{
+			Data:      base64.StdEncoding.EncodeToString(svgData),
+			MediaType: "image/svg+xml",
+		}}))
+	})
+})
@@ -15,9 +15,15 @@
 package bases
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
 	"strings"
 
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
 
 	"github.com/operator-framework/operator-sdk/internal/util/projutil"
 )
@@ -38,18 +44,140 @@ type uiMetadata struct {
 	Keywords []string
 	// Maintainers is the list of organizational entities maintaining the operator.
 	Maintainers []string
-	// FEAT: read icon bytes from files.
+	// Maturity is the operator's maturity level, ex. alpha.
+	Maturity string
 }
 
-// runInteractivePrompt prompts the user to provide input to uiMetadata fields.
+// UIMetadata is the format of uiMetadata in a UI metadata file or the project config.
+// All fields are optional.
+type UIMetadata struct {
+	DisplayName string                `json:"displayName,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Provider    v1alpha1.AppLink      `json:"provider,omitempty"`
+	Keywords    []string              `json:"keywords,omitempty"`
+	Maintainers []v1alpha1.Maintainer `json:"maintainers,omitempty"`
+	Maturity    string                `json:"maturity,omitempty"`
+}
+
+// Apply sets fields of csv that are set in m.
+func (m UIMetadata) Apply(csv *v1alpha1.ClusterServiceVersion) error {
+	s, err := newUIMetadata(m)
+	if err != nil {
+		return err
+	}
+	s.apply(csv)
+	return nil
+}
+
+// readUIMetadata reads uiMetadata from a YAML or JSON file at path.
+func readUIMetadata(path string) (*uiMetadata, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := UIMetadata{}
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return nil, fmt.Errorf("error unmarshalling UI metadata file %s: %v", path, err)
+	}
+	s, err := newUIMetadata(f)
+	if err != nil {
+		return nil, fmt.Errorf("UI metadata file %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// newUIMetadata returns the uiMetadata in m.
+func newUIMetadata(m UIMetadata) (*uiMetadata, error) {
+	s := &uiMetadata{
+		DisplayName:  m.DisplayName,
+		Description:  m.Description,
+		ProviderName: m.Provider.Name,
+		ProviderURL:  m.Provider.URL,
+		Keywords:     m.Keywords,
+		Maturity:     m.Maturity,
+	}
+	for _, maintainer := range m.Maintainers {
+		if maintainer.Name == "" || maintainer.Email == "" {
+			return nil, errors.New("maintainers must have a name and email")
+		}
+		s.Maintainers = append(s.Maintainers, maintainer.Name+":"+maintainer.Email)
+	}
+	return s, nil
+}
+
+// toUIMetadata returns s in the format of a UI metadata file.
+func (s uiMetadata) toUIMetadata() UIMetadata {
+	return UIMetadata{
+		DisplayName: s.DisplayName,
+		Description: s.Description,
+		Provider:    v1alpha1.AppLink{Name: s.ProviderName, URL: s.ProviderURL},
+		Keywords:    s.Keywords,
+		Maintainers: parseMaintainers(s.Maintainers),
+		Maturity:    s.Maturity,
+	}
+}
+
+// setProjectMetadata sets fields of s that are not already set to those in project, the UI metadata
+// saved in the project config. Project config values are preferred over those in base,
+// so a warning is logged for each base field that is set to a different value.
+func (s *uiMetadata) setProjectMetadata(project *uiMetadata, base *v1alpha1.ClusterServiceVersion) {
+	warnConflict := func(field string, baseValue, projectValue interface{}) {
+		if base != nil && !reflect.ValueOf(baseValue).IsZero() && !reflect.DeepEqual(baseValue, projectValue) {
+			log.Warnf("Using %s %v from the project config instead of %v from the ClusterServiceVersion base",
+				field, projectValue, baseValue)
+		}
+	}
+	var spec v1alpha1.ClusterServiceVersionSpec
+	if base != nil {
+		spec = base.Spec
+	}
+
+	if s.DisplayName == "" && project.DisplayName != "" {
+		s.DisplayName = project.DisplayName
+		warnConflict("displayName", spec.DisplayName, s.DisplayName)
+	}
+	if s.Description == "" && project.Description != "" {
+		s.Description = project.Description
+		warnConflict("description", spec.Description, s.Description)
+	}
+	if s.ProviderName == "" && project.ProviderName != "" {
+		s.ProviderName, s.ProviderURL = project.ProviderName, project.ProviderURL
+		warnConflict("provider", spec.Provider, v1alpha1.AppLink{Name: s.ProviderName, URL: s.ProviderURL})
+	}
+	if len(s.Keywords) == 0 && len(project.Keywords) != 0 {
+		s.Keywords = project.Keywords
+		warnConflict("keywords", spec.Keywords, s.Keywords)
+	}
+	if len(s.Maintainers) == 0 && len(project.Maintainers) != 0 {
+		s.Maintainers = project.Maintainers
+		warnConflict("maintainers", spec.Maintainers, parseMaintainers(s.Maintainers))
+	}
+	if s.Maturity == "" && project.Maturity != "" {
+		s.Maturity = project.Maturity
+		warnConflict("maturity", spec.Maturity, s.Maturity)
+	}
+}
+
+// runInteractivePrompt prompts the user to provide input to uiMetadata fields
+// that are not already set.
 func (s *uiMetadata) runInteractivePrompt() {
-	s.DisplayName = projutil.GetRequiredInput("Display name for the operator")
-	s.Description = projutil.GetRequiredInput("Description for the operator")
-	s.ProviderName = projutil.GetRequiredInput("Provider's name for the operator")
-	s.ProviderURL = projutil.GetOptionalInput("Any relevant URL for the provider name")
-	s.Keywords = projutil.GetStringArray("Comma-separated list of keywords for your operator")
-	s.Maintainers = projutil.GetStringArray("Comma-separated list of maintainers and their emails" +
-		" (e.g. 'name1:email1, name2:email2')")
+	if s.DisplayName == "" {
+		s.DisplayName = projutil.GetRequiredInput("Display name for the operator")
+	}
+	if s.Description == "" {
+		s.Description = projutil.GetRequiredInput("Description for the operator")
+	}
+	if s.ProviderName == "" {
+		s.ProviderName = projutil.GetRequiredInput("Provider's name for the operator")
+		s.ProviderURL = projutil.GetOptionalInput("Any relevant URL for the provider name")
+	}
+	if len(s.Keywords) == 0 {
+		s.Keywords = projutil.GetStringArray("Comma-separated list of keywords for your operator")
+	}
+	if len(s.Maintainers) == 0 {
+		s.Maintainers = projutil.GetStringArray("Comma-separated list of maintainers and their emails" +
+			" (e.g. 'name1:email1, name2:email2')")
+	}
 }
 
 // apply populates the CSV with the data in s.
@@ -67,16 +195,11 @@ func (s uiMetadata) apply(csv *v1alpha1.ClusterServiceVersion) {
 	}
 
 	if len(s.Maintainers) != 0 {
-		maintainers := make([]v1alpha1.Maintainer, 0)
-		for _, entity := range s.Maintainers {
-			entityDetails := strings.Split(entity, ":")
-			if len(entityDetails) == 2 {
-				m := v1alpha1.Maintainer{}
-				m.Name, m.Email = entityDetails[0], entityDetails[1]
-				maintainers = append(maintainers, m)
-			}
-		}
-		csv.Spec.Maintainers = maintainers
+		csv.Spec.Maintainers = parseMaintainers(s.Maintainers)
+	}
+
+	if s.Maturity != "" {
+		csv.Spec.Maturity = s.Maturity
 	}
 
 	if s.ProviderName != "" {
@@ -88,3 +211,16 @@ func (s uiMetadata) apply(csv *v1alpha1.ClusterServiceVersion) {
 		csv.Spec.Provider = provider
 	}
 }
+
+// parseMaintainers parses maintainers in the format "name:email", skipping malformed entries.
+func parseMaintainers(entities []string) (maintainers []v1alpha1.Maintainer) {
+	for _, entity := range entities {
+		entityDetails := strings.Split(entity, ":")
+		if len(entityDetails) == 2 {
+			m := v1alpha1.Maintainer{}
+			m.Name, m.Email = entityDetails[0], entityDetails[1]
+			maintainers = append(maintainers, m)
+		}
+	}
+	return maintainers
+}
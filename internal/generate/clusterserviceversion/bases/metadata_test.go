@@ -15,6 +15,9 @@
 package bases
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
@@ -66,3 +69,103 @@ var _ = Describe("Metadata", func() {
 		Expect(csv.Spec.Provider).To(Equal(v1alpha1.AppLink{Name: meta.ProviderName, URL: meta.ProviderURL}))
 	})
 })
+
+var _ = Describe("UI metadata file", func() {
+	var tmp string
+
+	BeforeEach(func() {
+		var err error
+		tmp, err = ioutil.TempDir("", "ui-metadata-")
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	writeFile := func(contents string) string {
+		path := filepath.Join(tmp, "metadata.yaml")
+		Expect(ioutil.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+		return path
+	}
+
+	It("fills in a new base, defaulting fields it does not set", func() {
+		b := ClusterServiceVersion{
+			OperatorName: "test-operator",
+			MetadataPath: writeFile(`{"displayName": "Test", "maintainers": [{"name": "Jane Doe", "email": "jane@example.com"}]}`),
+		}
+		csv, err := b.GetBase()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(csv.Spec.DisplayName).To(Equal("Test"))
+		Expect(csv.Spec.Maintainers).To(Equal([]v1alpha1.Maintainer{{Name: "Jane Doe", Email: "jane@example.com"}}))
+		Expect(csv.Spec.Description).To(Equal("Test Operator description. TODO."))
+		Expect(csv.Spec.Keywords).To(Equal([]string{"test-operator"}))
+	})
+	It("reads YAML files", func() {
+		meta, err := readUIMetadata(writeFile(`displayName: Test
+description: A test operator.
+provider:
+  name: Example
+  url: https://example.com
+keywords:
+- test
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*meta).To(Equal(uiMetadata{
+			DisplayName:  "Test",
+			Description:  "A test operator.",
+			ProviderName: "Example",
+			ProviderURL:  "https://example.com",
+			Keywords:     []string{"test"},
+		}))
+	})
+	It("returns an error for unknown fields", func() {
+		_, err := readUIMetadata(writeFile("displayname: Test\n"))
+		Expect(err).To(MatchError(ContainSubstring("error unmarshalling UI metadata file")))
+	})
+	It("returns an error for incomplete maintainers", func() {
+		_, err := readUIMetadata(writeFile("maintainers:\n- name: Jane Doe\n"))
+		Expect(err).To(MatchError(ContainSubstring("maintainers must have a name and email")))
+	})
+})
+
+var _ = Describe("Project config UI metadata", func() {
+	project := UIMetadata{
+		DisplayName: "Project",
+		Description: "A project operator.",
+		Provider:    v1alpha1.AppLink{Name: "Example"},
+		Maintainers: []v1alpha1.Maintainer{{Name: "Jane Doe", Email: "jane@example.com"}},
+		Maturity:    "stable",
+	}
+
+	It("replaces base fields and is set to all applied UI metadata", func() {
+		meta := project
+		b := ClusterServiceVersion{OperatorName: "test-operator", ProjectMetadata: &meta}
+		csv, err := b.GetBase()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(csv.Spec.DisplayName).To(Equal("Project"))
+		Expect(csv.Spec.Provider).To(Equal(v1alpha1.AppLink{Name: "Example"}))
+		Expect(csv.Spec.Maturity).To(Equal("stable"))
+		Expect(csv.Spec.Keywords).To(Equal([]string{"test-operator"}))
+		Expect(meta).To(Equal(project))
+	})
+	It("is superseded by fields set in a UI metadata file", func() {
+		s := &uiMetadata{DisplayName: "File", Keywords: []string{"file"}}
+		projectMeta, err := newUIMetadata(project)
+		Expect(err).NotTo(HaveOccurred())
+		s.setProjectMetadata(projectMeta, nil)
+		Expect(s.toUIMetadata()).To(Equal(UIMetadata{
+			DisplayName: "File",
+			Description: project.Description,
+			Provider:    project.Provider,
+			Keywords:    []string{"file"},
+			Maintainers: project.Maintainers,
+			Maturity:    project.Maturity,
+		}))
+	})
+	It("returns an error for incomplete maintainers", func() {
+		meta := UIMetadata{Maintainers: []v1alpha1.Maintainer{{Name: "Jane Doe"}}}
+		b := ClusterServiceVersion{OperatorName: "test-operator", ProjectMetadata: &meta}
+		_, err := b.GetBase()
+		Expect(err).To(MatchError(ContainSubstring("invalid UI metadata in project config")))
+	})
+})
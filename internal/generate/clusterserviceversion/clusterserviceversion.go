@@ -15,10 +15,12 @@
 package clusterserviceversion
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/blang/semver"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
@@ -31,12 +33,18 @@ import (
 	"github.com/operator-framework/operator-sdk/internal/generate/clusterserviceversion/bases"
 	"github.com/operator-framework/operator-sdk/internal/generate/collector"
 	genutil "github.com/operator-framework/operator-sdk/internal/generate/internal"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/breaker"
+	"github.com/operator-framework/operator-sdk/internal/registry"
 	"github.com/operator-framework/operator-sdk/internal/util/projutil"
 )
 
 const (
 	// File extension for all ClusterServiceVersion manifests written by Generator.
 	csvYamlFileExt = ".clusterserviceversion.yaml"
+
+	// Default time to resolve all image digests within, longer than the
+	// default registry budget so one slow registry does not fail generation.
+	defaultDigestTimeout = 5 * time.Minute
 )
 
 var (
@@ -51,14 +59,64 @@ var (
 type Generator struct {
 	// OperatorName is the operator's name, ex. app-operator.
 	OperatorName string
+	// PackageName is the name of the package the CSV belongs to, which prefixes the CSV's name
+	// and names bundle and package CSV files. Defaults to OperatorName.
+	PackageName string
+	// PreviousPackageName, if set, is the package an existing bundled CSV was generated for
+	// before the package was renamed. That CSV is read instead of the CSV of PackageName.
+	PreviousPackageName string
 	// OperatorType determines what code API types are written in for getBase.
 	OperatorType projutil.OperatorType
 	// Version is the CSV current version.
 	Version string
-	// FromVersion is the version of a previous CSV to upgrade from.
+	// FromVersion is the version of a previous CSV to upgrade from. If set with
+	// Version, the CSV replaces the CSV of FromVersion.
 	FromVersion string
 	// Collector holds all manifests relevant to the Generator.
 	Collector *collector.Manifests
+	// Overwrite, if set, regenerates human-owned fields of an existing bundled
+	// CSV from the base instead of preserving them. See preserveHumanOwnedFields.
+	Overwrite bool
+	// SkipRelatedImages, if set, leaves the base's relatedImages as-is instead of
+	// generating them from Deployment images. See applyRelatedImages.
+	SkipRelatedImages bool
+	// SkipNativeAPIDetection, if set, does not declare APIs of collected objects that
+	// are not built into Kubernetes as required CRDs or native APIs. See applyRequiredAPIs.
+	SkipNativeAPIDetection bool
+	// UIMetadataPath is the path to a YAML or JSON file containing UI metadata,
+	// ex. displayName, applied to the base CSV. See bases.ClusterServiceVersion.
+	UIMetadataPath string
+	// ProjectMetadata is UI metadata saved in the project config, which replaces that of the base
+	// and an existing CSV. After generation it contains all UI metadata read from UIMetadataPath,
+	// the project config, or prompts, so it can be saved. See bases.ClusterServiceVersion.
+	ProjectMetadata *bases.UIMetadata
+	// IconPath is the path to an image file set as the CSV's icon. If empty,
+	// the base's or existing CSV's icon is kept. See bases.ClusterServiceVersion.
+	IconPath string
+	// MinKubeVersion is set as the CSV's spec.minKubeVersion. If empty,
+	// the base's or existing CSV's minKubeVersion is kept.
+	MinKubeVersion string
+	// SkipRange is set as the CSV's olm.skipRange annotation. If empty,
+	// the existing CSV's skip range is kept.
+	SkipRange string
+	// Skips are set as the CSV's spec.skips. If empty, the existing CSV's
+	// skips are kept.
+	Skips []string
+	// DigestResolver, if set, resolves the digests all images in the CSV are pinned to.
+	// See applyImageDigests.
+	DigestResolver registry.DigestResolver
+	// SkipUnresolvableImages, if set, leaves images whose digests DigestResolver
+	// cannot resolve as-is instead of failing.
+	SkipUnresolvableImages bool
+	// RegistryBreakers bound DigestResolver's calls to each registry host.
+	// Defaults to breakers with default options.
+	RegistryBreakers *breaker.Breakers
+	// RequireDigests, if set, fails instead of leaving images pinned to their tags
+	// when their registry's breaker opens.
+	RequireDigests bool
+	// DigestTimeout bounds the time taken to resolve all image digests.
+	// Defaults to defaultDigestTimeout.
+	DigestTimeout time.Duration
 
 	// Project configuration.
 	config *config.Config
@@ -119,8 +177,8 @@ func WithBaseWriter(dir string) Option {
 // <dir>/manifests.
 func WithBundleWriter(dir string) Option {
 	return func(g *Generator) error {
-		fileName := makeCSVFileName(g.OperatorName)
-		g.bundledPath = filepath.Join(dir, bundle.ManifestsDir, fileName)
+		fileName := makeCSVFileName(g.getPackageName())
+		g.bundledPath = filepath.Join(dir, bundle.ManifestsDir, g.getBundledFileName())
 		g.getWriter = func() (io.Writer, error) {
 			return genutil.Open(filepath.Join(dir, bundle.ManifestsDir), fileName)
 		}
@@ -128,11 +186,25 @@ func WithBundleWriter(dir string) Option {
 	}
 }
 
+// WithStagedBundleWriter sets a Generator's writer to a bundle CSV file under
+// <stagingDir>/manifests, while an existing CSV is read from under <dir>/manifests.
+// This lets callers compare a generated bundle to the one in dir before updating it.
+func WithStagedBundleWriter(dir, stagingDir string) Option {
+	return func(g *Generator) error {
+		fileName := makeCSVFileName(g.getPackageName())
+		g.bundledPath = filepath.Join(dir, bundle.ManifestsDir, g.getBundledFileName())
+		g.getWriter = func() (io.Writer, error) {
+			return genutil.Open(filepath.Join(stagingDir, bundle.ManifestsDir), fileName)
+		}
+		return nil
+	}
+}
+
 // WithPackageWriter sets a Generator's writer to a package CSV file under
 // <dir>/<version>.
 func WithPackageWriter(dir string) Option {
 	return func(g *Generator) error {
-		fileName := makeCSVFileName(g.OperatorName)
+		fileName := makeCSVFileName(g.getPackageName())
 		if g.FromVersion != "" {
 			g.bundledPath = filepath.Join(dir, g.FromVersion, fileName)
 		}
@@ -143,6 +215,22 @@ func WithPackageWriter(dir string) Option {
 	}
 }
 
+// getPackageName returns the name of the package the CSV belongs to.
+func (g Generator) getPackageName() string {
+	if g.PackageName != "" {
+		return g.PackageName
+	}
+	return g.OperatorName
+}
+
+// getBundledFileName returns the file name of an existing bundled CSV.
+func (g Generator) getBundledFileName() string {
+	if g.PreviousPackageName != "" {
+		return makeCSVFileName(g.PreviousPackageName)
+	}
+	return makeCSVFileName(g.getPackageName())
+}
+
 // Generate configures the generator with cfg and opts then runs it.
 func (g *Generator) Generate(cfg *config.Config, opts ...Option) (err error) {
 	g.config = cfg
@@ -195,7 +283,42 @@ func (g *Generator) generate() (*operatorsv1alpha1.ClusterServiceVersion, error)
 		return nil, fmt.Errorf("error getting ClusterServiceVersion base: %v", err)
 	}
 
-	if err = g.updateVersions(base); err != nil {
+	existing, err := g.getExisting()
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && !g.Overwrite {
+		icon := base.Spec.Icon
+		preserveHumanOwnedFields(existing, base)
+		// An icon passed explicitly replaces the existing one.
+		if g.IconPath != "" {
+			base.Spec.Icon = icon
+		}
+	}
+	if g.ProjectMetadata != nil {
+		if err := g.ProjectMetadata.Apply(base); err != nil {
+			return nil, fmt.Errorf("invalid UI metadata in project config: %v", err)
+		}
+	}
+	if g.MinKubeVersion != "" {
+		base.Spec.MinKubeVersion = g.MinKubeVersion
+	}
+	if existing != nil {
+		preserveUpgradeFields(existing, base)
+	}
+	if g.SkipRange != "" {
+		annotations := base.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[registry.SkipRangeAnnotation] = g.SkipRange
+		base.SetAnnotations(annotations)
+	}
+	if len(g.Skips) != 0 {
+		base.Spec.Skips = g.Skips
+	}
+
+	if err = g.updateVersions(base, existing); err != nil {
 		return nil, err
 	}
 
@@ -203,11 +326,38 @@ func (g *Generator) generate() (*operatorsv1alpha1.ClusterServiceVersion, error)
 		if err := ApplyTo(g.Collector, base); err != nil {
 			return nil, err
 		}
+		if !g.SkipRelatedImages {
+			applyRelatedImages(base)
+		}
+		if !g.SkipNativeAPIDetection {
+			applyRequiredAPIs(g.Collector, base)
+		}
+	}
+	if g.DigestResolver != nil {
+		if err := g.pinImageDigests(base); err != nil {
+			return nil, err
+		}
 	}
 
 	return base, nil
 }
 
+// pinImageDigests pins base's images to the digests resolved by g's
+// DigestResolver, within g's DigestTimeout.
+func (g Generator) pinImageDigests(base *operatorsv1alpha1.ClusterServiceVersion) error {
+	breakers := g.RegistryBreakers
+	if breakers == nil {
+		breakers = breaker.New(breaker.Options{})
+	}
+	timeout := g.DigestTimeout
+	if timeout <= 0 {
+		timeout = defaultDigestTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return applyImageDigests(ctx, base, g.DigestResolver, breakers, g.SkipUnresolvableImages, g.RequireDigests)
+}
+
 // makeCSVFileName returns a CSV file name containing name.
 func makeCSVFileName(name string) string {
 	return strings.ToLower(name) + csvYamlFileExt
@@ -235,12 +385,15 @@ func (g Generator) makeBaseGetter(basePath, apisDir string, interactive bool) ge
 
 	return func() (*operatorsv1alpha1.ClusterServiceVersion, error) {
 		b := bases.ClusterServiceVersion{
-			OperatorName: g.OperatorName,
-			OperatorType: g.OperatorType,
-			BasePath:     basePath,
-			APIsDir:      apisDir,
-			GVKs:         gvks,
-			Interactive:  interactive,
+			OperatorName:    g.OperatorName,
+			OperatorType:    g.OperatorType,
+			BasePath:        basePath,
+			APIsDir:         apisDir,
+			GVKs:            gvks,
+			Interactive:     interactive,
+			MetadataPath:    g.UIMetadataPath,
+			ProjectMetadata: g.ProjectMetadata,
+			IconPath:        g.IconPath,
 		}
 		return b.GetBase()
 	}
@@ -252,22 +405,30 @@ func requiresInteraction(basePath string, ilvl projutil.InteractiveLevel) bool {
 	return (ilvl == projutil.InteractiveSoftOff && genutil.IsNotExist(basePath)) || ilvl == projutil.InteractiveOnAll
 }
 
+// getExisting returns the CSV at g.bundledPath, or nil if there is none.
+func (g Generator) getExisting() (*operatorsv1alpha1.ClusterServiceVersion, error) {
+	if !genutil.IsExist(g.bundledPath) {
+		return nil, nil
+	}
+	existing, err := (bases.ClusterServiceVersion{BasePath: g.bundledPath}).GetBase()
+	if err != nil {
+		return nil, fmt.Errorf("error reading existing ClusterServiceVersion: %v", err)
+	}
+	return existing, nil
+}
+
 // updateVersions updates csv's version and data involving the version,
 // ex. ObjectMeta.Name, and place the old version in the `replaces` object,
 // if there is an old version to replace.
-func (g Generator) updateVersions(csv *operatorsv1alpha1.ClusterServiceVersion) (err error) {
+func (g Generator) updateVersions(csv, existing *operatorsv1alpha1.ClusterServiceVersion) (err error) {
 
 	oldVer, newVer := csv.Spec.Version.String(), g.Version
-	newName := genutil.MakeCSVName(g.OperatorName, newVer)
+	newName := genutil.MakeCSVName(g.getPackageName(), newVer)
 	oldName := csv.GetName()
 
 	// A bundled CSV may not have a base containing the previous version to use,
 	// so use the current bundled CSV for version information.
-	if genutil.IsExist(g.bundledPath) {
-		existing, err := (bases.ClusterServiceVersion{BasePath: g.bundledPath}).GetBase()
-		if err != nil {
-			return fmt.Errorf("error reading existing ClusterServiceVersion: %v", err)
-		}
+	if existing != nil {
 		oldVer = existing.Spec.Version.String()
 		oldName = existing.GetName()
 	}
@@ -278,11 +439,16 @@ func (g Generator) updateVersions(csv *operatorsv1alpha1.ClusterServiceVersion)
 		return nil
 	}
 
-	// Set replaces by default.
+	// Set replaces by default. A CSV of a renamed package cannot replace one of the
+	// previous package, since they are in different upgrade graphs.
 	// TODO: consider all possible CSV versioning schemes supported  by OLM.
-	if oldVer != "0.0.0" && newVer != oldVer {
+	if oldVer != "0.0.0" && newVer != oldVer && g.PreviousPackageName == "" {
 		csv.Spec.Replaces = oldName
 	}
+	// An explicit previous version always determines replaces.
+	if g.FromVersion != "" {
+		csv.Spec.Replaces = genutil.MakeCSVName(g.getPackageName(), g.FromVersion)
+	}
 
 	csv.SetName(newName)
 	csv.Spec.Version.Version, err = semver.Parse(newVer)
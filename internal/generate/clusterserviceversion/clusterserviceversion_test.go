@@ -16,11 +16,15 @@ package clusterserviceversion
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/blang/semver"
 	. "github.com/onsi/ginkgo"
@@ -34,8 +38,10 @@ import (
 	"sigs.k8s.io/yaml"
 
 	metricsannotations "github.com/operator-framework/operator-sdk/internal/annotations/metrics"
+	"github.com/operator-framework/operator-sdk/internal/generate/clusterserviceversion/bases"
 	"github.com/operator-framework/operator-sdk/internal/generate/collector"
 	genutil "github.com/operator-framework/operator-sdk/internal/generate/internal"
+	"github.com/operator-framework/operator-sdk/internal/registry"
 	"github.com/operator-framework/operator-sdk/internal/util/projutil"
 )
 
@@ -140,6 +146,68 @@ var _ = Describe("Generating a ClusterServiceVersion", func() {
 				Expect(outputFile).To(BeAnExistingFile())
 				Expect(readFileHelper(outputFile)).To(MatchYAML(baseCSVUIMetaStr))
 			})
+			It("should write identical kustomize bases headlessly from a UI metadata file", func() {
+				headlessDir := filepath.Join(csvDir, "headless")
+				generate := func() string {
+					g = Generator{
+						OperatorName:   operatorName,
+						OperatorType:   operatorType,
+						UIMetadataPath: filepath.Join(headlessDir, "metadata.yaml"),
+					}
+					opts := []Option{
+						WithBase(filepath.Join(tmp, "input"), "", projutil.InteractiveHardOff),
+						WithBaseWriter(tmp),
+					}
+					Expect(g.Generate(cfg, opts...)).ToNot(HaveOccurred())
+					outputFile := filepath.Join(tmp, "bases", makeCSVFileName(operatorName))
+					Expect(outputFile).To(BeAnExistingFile())
+					return readFileHelper(outputFile)
+				}
+				first := generate()
+				Expect(generate()).To(Equal(first))
+				golden := readFileHelper(filepath.Join(headlessDir, makeCSVFileName(operatorName)))
+				Expect(first).To(MatchYAML(golden))
+			})
+			It("should write identical bundle files when regenerated from the same inputs", func() {
+				input := filepath.Join(tmp, "basic.operator.yaml")
+				b, err := ioutil.ReadFile(goBasicOperatorPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ioutil.WriteFile(input, b, 0644)).To(Succeed())
+
+				outputFile := filepath.Join(tmp, bundle.ManifestsDir, makeCSVFileName(operatorName))
+				generate := func() []byte {
+					c := &collector.Manifests{}
+					collectManifestsFromFileHelper(c, input)
+					g = Generator{
+						OperatorName: operatorName,
+						OperatorType: operatorType,
+						Version:      version,
+						Collector:    c,
+					}
+					opts := []Option{
+						WithBase(csvBasesDir, goAPIsDir, projutil.InteractiveHardOff),
+						WithBundleWriter(tmp),
+					}
+					Expect(g.Generate(cfg, opts...)).ToNot(HaveOccurred())
+					out, err := ioutil.ReadFile(outputFile)
+					Expect(err).ToNot(HaveOccurred())
+					return out
+				}
+				first := generate()
+				Expect(generate()).To(Equal(first))
+
+				// A no-op touch of inputs must not change output.
+				later := time.Now().Add(time.Hour)
+				Expect(os.Chtimes(input, later, later)).To(Succeed())
+				Expect(generate()).To(Equal(first))
+
+				// Keys are written in lexical order at every level, not struct field order.
+				for _, parent := range []string{"", "metadata", "annotations", "spec"} {
+					keys := getYAMLKeysHelper(first, parent)
+					Expect(len(keys)).To(BeNumerically(">", 1), "keys of %q", parent)
+					Expect(sort.StringsAreSorted(keys)).To(BeTrue(), "keys of %q: %v", parent, keys)
+				}
+			})
 			It("should have sdk labels in annotations", func() {
 				g = Generator{
 					OperatorName: operatorName,
@@ -176,6 +244,25 @@ var _ = Describe("Generating a ClusterServiceVersion", func() {
 				Expect(outputFile).To(BeAnExistingFile())
 				Expect(readFileHelper(outputFile)).To(MatchYAML(newCSVStr))
 			})
+			It("should write a ClusterServiceVersion manifest to a staged bundle file", func() {
+				g = Generator{
+					OperatorName: operatorName,
+					OperatorType: operatorType,
+					Version:      version,
+					Collector:    col,
+				}
+				stagingDir := filepath.Join(tmp, "staging")
+				opts := []Option{
+					WithBase(csvBasesDir, goAPIsDir, projutil.InteractiveHardOff),
+					WithStagedBundleWriter(filepath.Join(tmp, "bundle"), stagingDir),
+				}
+				Expect(g.Generate(cfg, opts...)).ToNot(HaveOccurred())
+				Expect(g.bundledPath).To(Equal(filepath.Join(tmp, "bundle", bundle.ManifestsDir, makeCSVFileName(operatorName))))
+				Expect(g.bundledPath).NotTo(BeAnExistingFile())
+				outputFile := filepath.Join(stagingDir, bundle.ManifestsDir, makeCSVFileName(operatorName))
+				Expect(outputFile).To(BeAnExistingFile())
+				Expect(readFileHelper(outputFile)).To(MatchYAML(newCSVStr))
+			})
 			It("should write a ClusterServiceVersion manifest to a package file", func() {
 				g = Generator{
 					OperatorName: operatorName,
@@ -304,6 +391,373 @@ var _ = Describe("Generating a ClusterServiceVersion", func() {
 				Expect(err).ToNot(HaveOccurred())
 				Expect(csv).To(Equal(upgradeCSV(newCSV, g.OperatorName, g.Version)))
 			})
+			It("should replace the ClusterServiceVersion of FromVersion", func() {
+				g = Generator{
+					OperatorName: operatorName,
+					OperatorType: operatorType,
+					Version:      "0.0.3",
+					FromVersion:  "0.0.1",
+					Collector:    col,
+					config:       cfg,
+					getBase:      makeBaseGetter(newCSV),
+					bundledPath:  filepath.Join(csvNewLayoutBundleDir, "memcached-operator.clusterserviceversion.yaml"),
+				}
+				csv, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(csv.GetName()).To(Equal(operatorName + ".v0.0.3"))
+				Expect(csv.Spec.Replaces).To(Equal(operatorName + ".v0.0.1"))
+			})
+		})
+
+		Context("to upgrade a manually edited ClusterServiceVersion", func() {
+			var tmp string
+
+			BeforeEach(func() {
+				var err error
+				tmp, err = ioutil.TempDir(".", "")
+				Expect(err).ToNot(HaveOccurred())
+
+				edited := newCSV.DeepCopy()
+				edited.Spec.Description = "A hand-written description."
+				edited.Spec.Keywords = []string{"cache", "memcached"}
+				edited.Spec.Provider = v1alpha1.AppLink{Name: "Example Inc."}
+				edited.Spec.Icon = []v1alpha1.Icon{{Data: "ZWRpdGVk", MediaType: "image/png"}}
+				edited.Spec.MinKubeVersion = "1.16.0"
+				edited.GetAnnotations()["categories"] = "Database"
+				edited.GetAnnotations()[testSDKbuilderAnnotationKey] = "operator-sdk-v0.0.1"
+				b, err := yaml.Marshal(edited)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ioutil.WriteFile(filepath.Join(tmp, makeCSVFileName(operatorName)), b, 0644)).To(Succeed())
+
+				g = Generator{
+					OperatorName: operatorName,
+					OperatorType: operatorType,
+					Version:      "0.0.2",
+					Collector:    col,
+					config:       cfg,
+					getBase:      makeBaseGetter(newCSV),
+					bundledPath:  filepath.Join(tmp, makeCSVFileName(operatorName)),
+				}
+			})
+			AfterEach(func() {
+				if tmp != "" {
+					os.RemoveAll(tmp)
+				}
+			})
+
+			It("should preserve human-owned fields and regenerate the rest", func() {
+				csv, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				expected := upgradeCSV(newCSV, g.OperatorName, g.Version)
+				expected.Spec.Description = "A hand-written description."
+				expected.Spec.Keywords = []string{"cache", "memcached"}
+				expected.Spec.Provider = v1alpha1.AppLink{Name: "Example Inc."}
+				expected.Spec.Icon = []v1alpha1.Icon{{Data: "ZWRpdGVk", MediaType: "image/png"}}
+				expected.Spec.MinKubeVersion = "1.16.0"
+				expected.GetAnnotations()["categories"] = "Database"
+				Expect(csv).To(Equal(expected))
+			})
+			It("should replace an existing icon with the base's icon if IconPath is set", func() {
+				icon := []v1alpha1.Icon{{Data: "bmV3", MediaType: "image/svg+xml"}}
+				withIcon := newCSV.DeepCopy()
+				withIcon.Spec.Icon = icon
+				g.getBase = makeBaseGetter(withIcon)
+				g.IconPath = "icon.svg"
+				csv, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(csv.Spec.Icon).To(Equal(icon))
+				Expect(csv.Spec.Description).To(Equal("A hand-written description."))
+			})
+			It("should replace an existing minKubeVersion if MinKubeVersion is set", func() {
+				g.MinKubeVersion = "1.18.0"
+				csv, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(csv.Spec.MinKubeVersion).To(Equal("1.18.0"))
+				Expect(csv.Spec.Description).To(Equal("A hand-written description."))
+			})
+			It("should replace human-owned fields with UI metadata saved in the project config", func() {
+				g.ProjectMetadata = &bases.UIMetadata{Description: "A description from the project config."}
+				csv, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(csv.Spec.Description).To(Equal("A description from the project config."))
+				Expect(csv.Spec.Keywords).To(Equal([]string{"cache", "memcached"}))
+			})
+			It("should regenerate human-owned fields from the base with Overwrite", func() {
+				g.Overwrite = true
+				csv, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(csv).To(Equal(upgradeCSV(newCSV, g.OperatorName, g.Version)))
+			})
+		})
+
+		Context("to upgrade a ClusterServiceVersion with skips", func() {
+			var tmp string
+
+			BeforeEach(func() {
+				var err error
+				tmp, err = ioutil.TempDir(".", "")
+				Expect(err).ToNot(HaveOccurred())
+
+				existing := newCSV.DeepCopy()
+				existing.Spec.Skips = []string{operatorName + ".v0.0.0"}
+				existing.GetAnnotations()[registry.SkipRangeAnnotation] = "<0.0.1"
+				b, err := yaml.Marshal(existing)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ioutil.WriteFile(filepath.Join(tmp, makeCSVFileName(operatorName)), b, 0644)).To(Succeed())
+
+				g = Generator{
+					OperatorName: operatorName,
+					OperatorType: operatorType,
+					Version:      "0.0.2",
+					Collector:    col,
+					config:       cfg,
+					getBase:      makeBaseGetter(newCSV),
+					bundledPath:  filepath.Join(tmp, makeCSVFileName(operatorName)),
+				}
+			})
+			AfterEach(func() {
+				if tmp != "" {
+					os.RemoveAll(tmp)
+				}
+			})
+
+			It("should preserve the existing skips and skip range", func() {
+				csv, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(csv.Spec.Skips).To(Equal([]string{operatorName + ".v0.0.0"}))
+				Expect(csv.GetAnnotations()).To(HaveKeyWithValue(registry.SkipRangeAnnotation, "<0.0.1"))
+			})
+			It("should preserve the existing skips and skip range with Overwrite", func() {
+				g.Overwrite = true
+				csv, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(csv.Spec.Skips).To(Equal([]string{operatorName + ".v0.0.0"}))
+				Expect(csv.GetAnnotations()).To(HaveKeyWithValue(registry.SkipRangeAnnotation, "<0.0.1"))
+			})
+			It("should replace the existing skips and skip range if SkipRange and Skips are set", func() {
+				g.SkipRange = ">=0.0.1 <0.0.2"
+				g.Skips = []string{operatorName + ".v0.0.1-alpha"}
+				csv, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(csv.Spec.Skips).To(Equal(g.Skips))
+				Expect(csv.GetAnnotations()).To(HaveKeyWithValue(registry.SkipRangeAnnotation, ">=0.0.1 <0.0.2"))
+				Expect(csv.Spec.Replaces).To(Equal(operatorName + ".v0.0.1"))
+			})
+		})
+
+		Context("for a package not named after the operator", func() {
+			const packageName = "acme-memcached-operator"
+
+			var tmp string
+
+			BeforeEach(func() {
+				var err error
+				tmp, err = ioutil.TempDir(".", "")
+				Expect(err).ToNot(HaveOccurred())
+
+				existing := newCSV.DeepCopy()
+				existing.Spec.Description = "Edited description."
+				b, err := yaml.Marshal(existing)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(os.MkdirAll(filepath.Join(tmp, bundle.ManifestsDir), 0755)).To(Succeed())
+				existingPath := filepath.Join(tmp, bundle.ManifestsDir, makeCSVFileName(operatorName))
+				Expect(ioutil.WriteFile(existingPath, b, 0644)).To(Succeed())
+
+				g = Generator{
+					OperatorName: operatorName,
+					OperatorType: operatorType,
+					PackageName:  packageName,
+					Version:      "0.0.2",
+					Collector:    col,
+					getBase:      makeBaseGetter(newCSV),
+				}
+			})
+			AfterEach(func() {
+				if tmp != "" {
+					os.RemoveAll(tmp)
+				}
+			})
+
+			readBundledCSV := func(name string) *v1alpha1.ClusterServiceVersion {
+				b, err := ioutil.ReadFile(filepath.Join(tmp, bundle.ManifestsDir, makeCSVFileName(name)))
+				Expect(err).ToNot(HaveOccurred())
+				csv := &v1alpha1.ClusterServiceVersion{}
+				Expect(yaml.Unmarshal(b, csv)).To(Succeed())
+				return csv
+			}
+
+			It("should prefix the CSV's name and file name with the package name", func() {
+				g.FromVersion = "0.0.1"
+				Expect(g.Generate(cfg, WithBundleWriter(tmp))).To(Succeed())
+				csv := readBundledCSV(packageName)
+				Expect(csv.GetName()).To(Equal(packageName + ".v0.0.2"))
+				Expect(csv.Spec.Replaces).To(Equal(packageName + ".v0.0.1"))
+			})
+			It("should rename the existing CSV of the previous package", func() {
+				g.PreviousPackageName = operatorName
+				Expect(g.Generate(cfg, WithBundleWriter(tmp))).To(Succeed())
+				csv := readBundledCSV(packageName)
+				Expect(csv.GetName()).To(Equal(packageName + ".v0.0.2"))
+				Expect(csv.Spec.Description).To(Equal("Edited description."))
+				// The previous package's CSV is in another upgrade graph.
+				Expect(csv.Spec.Replaces).To(BeEmpty())
+			})
+		})
+
+		Context("with UI metadata saved in the project config", func() {
+			var (
+				tmp      string
+				basePath string
+				expected bases.UIMetadata
+			)
+
+			BeforeEach(func() {
+				var err error
+				tmp, err = ioutil.TempDir(".", "")
+				Expect(err).ToNot(HaveOccurred())
+				basePath = filepath.Join(tmp, "bases", makeCSVFileName(operatorName))
+				expected = bases.UIMetadata{
+					DisplayName: "Memcached Operator",
+					Description: "Manages memcached clusters.",
+					Provider:    v1alpha1.AppLink{Name: "Example Inc.", URL: "https://example.com"},
+					Keywords:    []string{"memcached", "cache"},
+					Maintainers: []v1alpha1.Maintainer{{Name: "Jane Doe", Email: "jane@example.com"}},
+					Maturity:    "beta",
+				}
+			})
+			AfterEach(func() {
+				if tmp != "" {
+					os.RemoveAll(tmp)
+				}
+			})
+
+			// regenerate generates a base in tmp from the base in tmp, like 'generate kustomize manifests',
+			// and returns it with the UI metadata to save. saved is copied as if read from the project config.
+			regenerate := func(saved bases.UIMetadata, metadataPath string) (*v1alpha1.ClusterServiceVersion, bases.UIMetadata) {
+				b, err := json.Marshal(saved)
+				Expect(err).ToNot(HaveOccurred())
+				projectMetadata := &bases.UIMetadata{}
+				Expect(json.Unmarshal(b, projectMetadata)).To(Succeed())
+				g = Generator{
+					OperatorName:    operatorName,
+					OperatorType:    operatorType,
+					UIMetadataPath:  metadataPath,
+					ProjectMetadata: projectMetadata,
+				}
+				opts := []Option{
+					WithBase(tmp, "", projutil.InteractiveHardOff),
+					WithBaseWriter(tmp),
+				}
+				Expect(g.Generate(cfg, opts...)).ToNot(HaveOccurred())
+				csv := &v1alpha1.ClusterServiceVersion{}
+				Expect(yaml.Unmarshal([]byte(readFileHelper(basePath)), csv)).To(Succeed())
+				return csv, *projectMetadata
+			}
+
+			It("should keep UI metadata from a metadata file across regenerations", func() {
+				b, err := yaml.Marshal(expected)
+				Expect(err).ToNot(HaveOccurred())
+				metadataPath := filepath.Join(tmp, "metadata.yaml")
+				Expect(ioutil.WriteFile(metadataPath, b, 0644)).To(Succeed())
+
+				csv, saved := regenerate(bases.UIMetadata{}, metadataPath)
+				Expect(saved).To(Equal(expected))
+				for i := 0; i < 3; i++ {
+					By(fmt.Sprintf("regenerating the base without the metadata file, run %d", i+1))
+					csv, saved = regenerate(saved, "")
+					Expect(saved).To(Equal(expected))
+					Expect(csv.Spec.DisplayName).To(Equal(expected.DisplayName))
+					Expect(csv.Spec.Description).To(Equal(expected.Description))
+					Expect(csv.Spec.Provider).To(Equal(expected.Provider))
+					Expect(csv.Spec.Keywords).To(Equal(expected.Keywords))
+					Expect(csv.Spec.Maintainers).To(Equal(expected.Maintainers))
+					Expect(csv.Spec.Maturity).To(Equal(expected.Maturity))
+				}
+			})
+			It("should prefer the project config to an edited base", func() {
+				regenerate(expected, "")
+				csv := &v1alpha1.ClusterServiceVersion{}
+				Expect(yaml.Unmarshal([]byte(readFileHelper(basePath)), csv)).To(Succeed())
+				csv.Spec.DisplayName = "Edited Operator"
+				csv.Spec.Links = []v1alpha1.AppLink{{Name: "Docs", URL: "https://example.com/docs"}}
+				b, err := yaml.Marshal(csv)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ioutil.WriteFile(basePath, b, 0644)).To(Succeed())
+
+				csv, saved := regenerate(expected, "")
+				Expect(saved).To(Equal(expected))
+				Expect(csv.Spec.DisplayName).To(Equal(expected.DisplayName))
+				Expect(csv.Spec.Links).To(Equal([]v1alpha1.AppLink{{Name: "Docs", URL: "https://example.com/docs"}}))
+			})
+		})
+	})
+
+	Describe("for a multigroup Go project", func() {
+		var (
+			multigroupDir = filepath.Join(testDataDir, "multigroup")
+			mgCfg         *config.Config
+			opts          []Option
+		)
+
+		BeforeEach(func() {
+			mgCfg = readConfigHelper(multigroupDir)
+			opts = []Option{
+				WithBase(filepath.Join(multigroupDir, "config", "manifests"), filepath.Join(multigroupDir, "apis"),
+					projutil.InteractiveHardOff),
+				WithWriter(buf),
+			}
+		})
+
+		// getSpecDescriptorPaths returns the spec descriptor paths of each owned CRD in csv by name.
+		getSpecDescriptorPaths := func(csv *v1alpha1.ClusterServiceVersion) map[string][]string {
+			paths := map[string][]string{}
+			for _, desc := range csv.Spec.CustomResourceDefinitions.Owned {
+				paths[desc.Name] = []string{}
+				for _, d := range desc.SpecDescriptors {
+					paths[desc.Name] = append(paths[desc.Name], d.Path)
+				}
+			}
+			return paths
+		}
+		expectedPaths := map[string][]string{
+			"memcacheds.cache.example.com": {"config.ttl", "size"},
+			"frigates.ship.example.com":    {"config.captain", "crew"},
+		}
+
+		It("should generate a base with owned CRDs of every group", func() {
+			g = Generator{
+				OperatorName: "fleet-operator",
+				OperatorType: operatorType,
+			}
+			Expect(g.Generate(mgCfg, opts...)).ToNot(HaveOccurred())
+			csv := &v1alpha1.ClusterServiceVersion{}
+			Expect(yaml.Unmarshal(buf.Bytes(), csv)).To(Succeed())
+			Expect(getSpecDescriptorPaths(csv)).To(Equal(expectedPaths))
+		})
+		It("should generate a ClusterServiceVersion with the CRDs and samples of every group", func() {
+			mgCol := &collector.Manifests{}
+			crdsDir := filepath.Join(multigroupDir, "config", "crd", "bases")
+			collectManifestsFromFileHelper(mgCol, filepath.Join(crdsDir, "cache.example.com_memcacheds.yaml"))
+			collectManifestsFromFileHelper(mgCol, filepath.Join(crdsDir, "ship.example.com_frigates.yaml"))
+			Expect(mgCol.UpdateFromSamples(filepath.Join(multigroupDir, "config", "samples"))).To(Succeed())
+			g = Generator{
+				OperatorName: "fleet-operator",
+				OperatorType: operatorType,
+				Version:      version,
+				Collector:    mgCol,
+			}
+			Expect(g.Generate(mgCfg, opts...)).ToNot(HaveOccurred())
+			csv := &v1alpha1.ClusterServiceVersion{}
+			Expect(yaml.Unmarshal(buf.Bytes(), csv)).To(Succeed())
+			Expect(getSpecDescriptorPaths(csv)).To(Equal(expectedPaths))
+
+			var examples []map[string]interface{}
+			Expect(json.Unmarshal([]byte(csv.GetAnnotations()["alm-examples"]), &examples)).To(Succeed())
+			kinds := []interface{}{}
+			for _, example := range examples {
+				kinds = append(kinds, example["kind"])
+			}
+			Expect(kinds).To(ConsistOf("Memcached", "Frigate"))
 		})
 	})
 
@@ -460,3 +914,38 @@ func removeSDKAnnotationsFromCSVString(csv string) string {
 	csv = layoutRe.ReplaceAllString(csv, "")
 	return csv
 }
+
+// getYAMLKeysHelper returns the keys of the mapping under the first key named
+// parent in b, a YAML document written by MarshalObject, or of the document's
+// top-level mapping if parent is empty.
+func getYAMLKeysHelper(b []byte, parent string) (keys []string) {
+	indent := -1
+	if parent == "" {
+		indent = 0
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		lineIndent := len(line) - len(trimmed)
+		if trimmed == "" || strings.HasPrefix(trimmed, "- ") {
+			continue
+		}
+		if indent == -1 {
+			if trimmed == parent+":" {
+				indent = lineIndent + 2
+			}
+			continue
+		}
+		if lineIndent < indent {
+			if len(keys) != 0 {
+				break
+			}
+			continue
+		}
+		if lineIndent == indent {
+			if i := strings.Index(trimmed, ":"); i > 0 {
+				keys = append(keys, trimmed[:i])
+			}
+		}
+	}
+	return keys
+}
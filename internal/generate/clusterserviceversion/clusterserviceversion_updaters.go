@@ -29,12 +29,19 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/version"
 
 	"github.com/operator-framework/operator-sdk/internal/generate/collector"
 	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
 )
 
+// almExamplesAnnotation contains a JSON array of example Custom Resources.
+const almExamplesAnnotation = "alm-examples"
+
 // ApplyTo applies relevant manifests in c to csv, sorts the applied updates,
 // and validates the result.
 func ApplyTo(c *collector.Manifests, csv *operatorsv1alpha1.ClusterServiceVersion) error {
@@ -68,6 +75,9 @@ func apply(c *collector.Manifests, csv *operatorsv1alpha1.ClusterServiceVersion)
 		return fmt.Errorf("error applying Custom Resource examples to CSV %s: %v", csv.GetName(), err)
 	}
 	applyWebhooks(c, csv)
+	if err := applyConversionWebhooks(c, csv); err != nil {
+		return fmt.Errorf("error applying conversion webhooks to CSV %s: %v", csv.GetName(), err)
+	}
 	return nil
 }
 
@@ -83,62 +93,57 @@ func getCSVInstallStrategy(csv *operatorsv1alpha1.ClusterServiceVersion) operato
 // This service account exists in every namespace as the default.
 const defaultServiceAccountName = "default"
 
-// applyRoles applies Roles to strategy's permissions field by combining Roles bound to ServiceAccounts
-// into one set of permissions.
-func applyRoles(c *collector.Manifests, strategy *operatorsv1alpha1.StrategyDetailsDeployment) { //nolint:dupl
+// applyRoles applies Roles, and ClusterRoles bound by RoleBindings, to strategy's permissions field
+// by combining the rules of all roles bound to each Deployment's ServiceAccount into one set of permissions.
+func applyRoles(c *collector.Manifests, strategy *operatorsv1alpha1.StrategyDetailsDeployment) {
 	objs, _ := c.SplitCSVPermissionsObjects()
-	roleSet := make(map[string]*rbacv1.Role)
+	roleRules := make(map[rbacv1.RoleRef][]rbacv1.PolicyRule)
 	for i := range objs {
 		switch t := objs[i].(type) {
 		case *rbacv1.Role:
-			roleSet[t.GetName()] = t
-		}
-	}
-
-	saToPermissions := make(map[string]operatorsv1alpha1.StrategyDeploymentPermissions)
-	for _, dep := range c.Deployments {
-		saName := dep.Spec.Template.Spec.ServiceAccountName
-		if saName == "" {
-			saName = defaultServiceAccountName
+			roleRules[newRoleRef("Role", t.GetName())] = t.Rules
+		case *rbacv1.ClusterRole:
+			roleRules[newRoleRef("ClusterRole", t.GetName())] = c.GetClusterRoleRules(t)
 		}
-		saToPermissions[saName] = operatorsv1alpha1.StrategyDeploymentPermissions{ServiceAccountName: saName}
 	}
 
-	// Collect all role names by their corresponding service accounts via bindings. This lets us
-	// look up all service accounts a role is bound to and create one set of permissions per service account.
+	saToPermissions := newServiceAccountPermissions(c)
 	for _, binding := range c.RoleBindings {
-		if role, hasRole := roleSet[binding.RoleRef.Name]; hasRole {
-			for _, subject := range binding.Subjects {
-				if perm, hasSA := saToPermissions[subject.Name]; hasSA && subject.Kind == "ServiceAccount" {
-					perm.Rules = append(perm.Rules, role.Rules...)
-					saToPermissions[subject.Name] = perm
-				}
-			}
-		}
-	}
-
-	// Apply relevant roles to each service account.
-	perms := []operatorsv1alpha1.StrategyDeploymentPermissions{}
-	for _, perm := range saToPermissions {
-		if len(perm.Rules) != 0 {
-			perms = append(perms, perm)
-		}
+		rules := roleRules[newRoleRef(binding.RoleRef.Kind, binding.RoleRef.Name)]
+		addBoundRules(saToPermissions, rules, binding.Subjects)
 	}
-	strategy.Permissions = perms
+	strategy.Permissions = makePermissions(saToPermissions)
 }
 
-// applyClusterRoles applies ClusterRoles to strategy's clusterPermissions field by combining ClusterRoles
-// bound to ServiceAccounts into one set of clusterPermissions.
-func applyClusterRoles(c *collector.Manifests, strategy *operatorsv1alpha1.StrategyDetailsDeployment) { //nolint:dupl
+// applyClusterRoles applies ClusterRoles, including those aggregated into them, to strategy's
+// clusterPermissions field by combining the rules of all ClusterRoles bound to each Deployment's
+// ServiceAccount into one set of clusterPermissions.
+func applyClusterRoles(c *collector.Manifests, strategy *operatorsv1alpha1.StrategyDetailsDeployment) {
 	objs, _ := c.SplitCSVClusterPermissionsObjects()
-	roleSet := make(map[string]*rbacv1.ClusterRole)
+	roleRules := make(map[string][]rbacv1.PolicyRule)
 	for i := range objs {
 		switch t := objs[i].(type) {
 		case *rbacv1.ClusterRole:
-			roleSet[t.GetName()] = t
+			roleRules[t.GetName()] = c.GetClusterRoleRules(t)
 		}
 	}
 
+	saToPermissions := newServiceAccountPermissions(c)
+	for _, binding := range c.ClusterRoleBindings {
+		if binding.RoleRef.Kind == "ClusterRole" {
+			addBoundRules(saToPermissions, roleRules[binding.RoleRef.Name], binding.Subjects)
+		}
+	}
+	strategy.ClusterPermissions = makePermissions(saToPermissions)
+}
+
+// newRoleRef returns a RoleRef used to look up rules by role kind and name.
+func newRoleRef(kind, name string) rbacv1.RoleRef {
+	return rbacv1.RoleRef{Kind: kind, Name: name}
+}
+
+// newServiceAccountPermissions returns empty permissions keyed by each Deployment's ServiceAccount name.
+func newServiceAccountPermissions(c *collector.Manifests) map[string]operatorsv1alpha1.StrategyDeploymentPermissions {
 	saToPermissions := make(map[string]operatorsv1alpha1.StrategyDeploymentPermissions)
 	for _, dep := range c.Deployments {
 		saName := dep.Spec.Template.Spec.ServiceAccountName
@@ -147,32 +152,63 @@ func applyClusterRoles(c *collector.Manifests, strategy *operatorsv1alpha1.Strat
 		}
 		saToPermissions[saName] = operatorsv1alpha1.StrategyDeploymentPermissions{ServiceAccountName: saName}
 	}
+	return saToPermissions
+}
 
-	// Collect all role names by their corresponding service accounts via bindings. This lets us
-	// look up all service accounts a role is bound to and create one set of permissions per service account.
-	for _, binding := range c.ClusterRoleBindings {
-		if role, hasRole := roleSet[binding.RoleRef.Name]; hasRole {
-			for _, subject := range binding.Subjects {
-				if perm, hasSA := saToPermissions[subject.Name]; hasSA && subject.Kind == "ServiceAccount" {
-					perm.Rules = append(perm.Rules, role.Rules...)
-					saToPermissions[subject.Name] = perm
-				}
-			}
+// addBoundRules adds rules to the permissions of each ServiceAccount in subjects that has permissions
+// in saToPermissions.
+func addBoundRules(saToPermissions map[string]operatorsv1alpha1.StrategyDeploymentPermissions,
+	rules []rbacv1.PolicyRule, subjects []rbacv1.Subject) {
+
+	for _, subject := range subjects {
+		if perm, hasSA := saToPermissions[subject.Name]; hasSA && subject.Kind == "ServiceAccount" {
+			perm.Rules = append(perm.Rules, rules...)
+			saToPermissions[subject.Name] = perm
 		}
 	}
+}
 
-	// Apply relevant roles to each service account.
+// makePermissions returns permissions in saToPermissions that have rules, with those rules merged.
+func makePermissions(saToPermissions map[string]operatorsv1alpha1.StrategyDeploymentPermissions) []operatorsv1alpha1.StrategyDeploymentPermissions {
 	perms := []operatorsv1alpha1.StrategyDeploymentPermissions{}
 	for _, perm := range saToPermissions {
 		if len(perm.Rules) != 0 {
+			perm.Rules = mergeRules(perm.Rules)
 			perms = append(perms, perm)
 		}
 	}
-	strategy.ClusterPermissions = perms
+	return perms
 }
 
-// applyDeployments updates strategy's deployments with the Deployments
-// in the collector.
+// mergeRules de-duplicates rules, combining the verbs of rules for the same
+// apiGroups, resources, resourceNames, and nonResourceURLs.
+func mergeRules(rules []rbacv1.PolicyRule) (merged []rbacv1.PolicyRule) {
+	ruleIndices := make(map[string]int)
+	for _, rule := range rules {
+		key := strings.Join([]string{
+			strings.Join(rule.APIGroups, ","),
+			strings.Join(rule.Resources, ","),
+			strings.Join(rule.ResourceNames, ","),
+			strings.Join(rule.NonResourceURLs, ","),
+		}, ";")
+		i, hasRule := ruleIndices[key]
+		if !hasRule {
+			ruleIndices[key] = len(merged)
+			merged = append(merged, *rule.DeepCopy())
+			continue
+		}
+		for _, verb := range rule.Verbs {
+			if !containsString(merged[i].Verbs, verb) {
+				merged[i].Verbs = append(merged[i].Verbs, verb)
+			}
+		}
+	}
+	return merged
+}
+
+// applyDeployments updates strategy's deployments with all Deployments in the collector,
+// each named after its Deployment. Permissions for each Deployment's ServiceAccount
+// are applied separately by applyRoles and applyClusterRoles.
 func applyDeployments(c *collector.Manifests, strategy *operatorsv1alpha1.StrategyDetailsDeployment) {
 	depSpecs := []operatorsv1alpha1.StrategyDeploymentSpec{}
 	for _, dep := range c.Deployments {
@@ -241,11 +277,7 @@ func applyCustomResourceDefinitions(c *collector.Manifests, csv *operatorsv1alph
 		descMap[defKey] = owned
 	}
 
-	var defKeys []registry.DefinitionKey
-	v1crdKeys := k8sutil.DefinitionsForV1CustomResourceDefinitions(c.V1CustomResourceDefinitions...)
-	defKeys = append(defKeys, v1crdKeys...)
-	v1beta1crdKeys := k8sutil.DefinitionsForV1beta1CustomResourceDefinitions(c.V1beta1CustomResourceDefinitions...)
-	defKeys = append(defKeys, v1beta1crdKeys...)
+	defKeys := k8sutil.DefinitionsForV1CustomResourceDefinitions(c.V1CustomResourceDefinitions...)
 	// crdDescriptions don't have a 'group' field.
 	for i := 0; i < len(defKeys); i++ {
 		defKeys[i].Group = ""
@@ -276,7 +308,11 @@ func applyWebhooks(c *collector.Manifests, csv *operatorsv1alpha1.ClusterService
 		} else if depName == "" {
 			log.Infof("No deployment is selected by service %q for validating webhook %q", serviceName, webhook.Name)
 		}
-		webhookDescriptions = append(webhookDescriptions, validatingToWebhookDescription(webhook, depName))
+		description := validatingToWebhookDescription(webhook, depName)
+		if port := getWebhookContainerPort(c, webhook.ClientConfig, depName, serviceName); port != 0 {
+			description.ContainerPort = port
+		}
+		webhookDescriptions = append(webhookDescriptions, description)
 	}
 	for _, webhook := range c.MutatingWebhooks {
 		depName, serviceName := findMatchingDeploymentAndServiceForWebhook(c, webhook.ClientConfig)
@@ -285,11 +321,140 @@ func applyWebhooks(c *collector.Manifests, csv *operatorsv1alpha1.ClusterService
 		} else if depName == "" {
 			log.Infof("No deployment is selected by service %q for mutating webhook %q", serviceName, webhook.Name)
 		}
-		webhookDescriptions = append(webhookDescriptions, mutatingToWebhookDescription(webhook, depName))
+		description := mutatingToWebhookDescription(webhook, depName)
+		if port := getWebhookContainerPort(c, webhook.ClientConfig, depName, serviceName); port != 0 {
+			description.ContainerPort = port
+		}
+		webhookDescriptions = append(webhookDescriptions, description)
 	}
 	csv.Spec.WebhookDefinitions = webhookDescriptions
 }
 
+// crdConversion is a version-agnostic CRD conversion configuration.
+type crdConversion struct {
+	crdName        string
+	strategy       string
+	clientConfig   *admissionregv1.WebhookClientConfig
+	reviewVersions []string
+}
+
+// getCRDConversions returns conversion configurations of all CRDs in c that have one.
+func getCRDConversions(c *collector.Manifests) (conversions []crdConversion) {
+	for _, crd := range c.V1CustomResourceDefinitions {
+		conv := crd.Spec.Conversion
+		if conv == nil {
+			continue
+		}
+		cc := crdConversion{crdName: crd.GetName(), strategy: string(conv.Strategy)}
+		if conv.Webhook != nil {
+			cc.reviewVersions = conv.Webhook.ConversionReviewVersions
+			if wcc := conv.Webhook.ClientConfig; wcc != nil {
+				cc.clientConfig = &admissionregv1.WebhookClientConfig{URL: wcc.URL, CABundle: wcc.CABundle}
+				if ref := wcc.Service; ref != nil {
+					cc.clientConfig.Service = &admissionregv1.ServiceReference{
+						Namespace: ref.Namespace, Name: ref.Name, Path: ref.Path, Port: ref.Port,
+					}
+				}
+			}
+		}
+		conversions = append(conversions, cc)
+	}
+	return conversions
+}
+
+// applyConversionWebhooks adds a ConversionWebhook to csv's webhookDefinitions for each
+// Service serving conversion webhooks of CRDs in the collector. CRDs with conversion webhooks
+// must be owned by csv, and their webhook Services must select a Deployment in csv.
+func applyConversionWebhooks(c *collector.Manifests, csv *operatorsv1alpha1.ClusterServiceVersion) error {
+	owned := map[string]bool{}
+	for _, desc := range csv.Spec.CustomResourceDefinitions.Owned {
+		owned[desc.Name] = true
+	}
+
+	// Group CRDs by the service path serving their conversion webhook.
+	descriptions := map[string]*operatorsv1alpha1.WebhookDescription{}
+	var keys []string
+	for _, conv := range getCRDConversions(c) {
+		if conv.clientConfig == nil {
+			if conv.strategy == string(apiextv1.WebhookConverter) {
+				return fmt.Errorf("CRD %q has conversion strategy %s but no webhook client config", conv.crdName, conv.strategy)
+			}
+			continue
+		}
+		if conv.strategy != string(apiextv1.WebhookConverter) {
+			return fmt.Errorf("CRD %q has a conversion webhook client config but conversion strategy %q, expected %s",
+				conv.crdName, conv.strategy, apiextv1.WebhookConverter)
+		}
+		if !owned[conv.crdName] {
+			return fmt.Errorf("CRD %q has a conversion webhook but is not owned by the CSV", conv.crdName)
+		}
+		wcc := *conv.clientConfig
+		if wcc.Service == nil {
+			return fmt.Errorf("CRD %q conversion webhook must reference a Service, not a URL", conv.crdName)
+		}
+		depName, serviceName := findMatchingDeploymentAndServiceForWebhook(c, wcc)
+		if serviceName == "" {
+			return fmt.Errorf("CRD %q conversion webhook Service %q was not found", conv.crdName, wcc.Service.Name)
+		}
+		if depName == "" {
+			return fmt.Errorf("CRD %q conversion webhook Service %q does not select the manager Deployment",
+				conv.crdName, serviceName)
+		}
+
+		path := ""
+		if wcc.Service.Path != nil {
+			path = *wcc.Service.Path
+		}
+		key := serviceName + path
+		desc, hasKey := descriptions[key]
+		if !hasKey {
+			seNone := admissionregv1.SideEffectClassNone
+			desc = &operatorsv1alpha1.WebhookDescription{
+				Type:           operatorsv1alpha1.ConversionWebhook,
+				DeploymentName: depName,
+				SideEffects:    &seNone,
+				WebhookPath:    wcc.Service.Path,
+			}
+			if wcc.Service.Port != nil {
+				desc.ContainerPort = *wcc.Service.Port
+			}
+			if port := getWebhookContainerPort(c, wcc, depName, serviceName); port != 0 {
+				desc.ContainerPort = port
+			}
+			descriptions[key] = desc
+			keys = append(keys, key)
+		}
+		desc.ConversionCRDs = append(desc.ConversionCRDs, conv.crdName)
+		for _, v := range conv.reviewVersions {
+			if !containsString(desc.AdmissionReviewVersions, v) {
+				desc.AdmissionReviewVersions = append(desc.AdmissionReviewVersions, v)
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		desc := descriptions[key]
+		sort.Strings(desc.ConversionCRDs)
+		// Conversion webhooks are named after the first CRD they convert.
+		desc.GenerateName = "c" + desc.ConversionCRDs[0]
+		if len(desc.AdmissionReviewVersions) == 0 {
+			desc.AdmissionReviewVersions = defaultAdmissionReviewVersions
+		}
+		csv.Spec.WebhookDefinitions = append(csv.Spec.WebhookDefinitions, *desc)
+	}
+	return nil
+}
+
+func containsString(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
 // The default AdmissionReviewVersions set in a CSV if not set in the source webhook.
 var defaultAdmissionReviewVersions = []string{"v1beta1"}
 
@@ -419,18 +584,173 @@ func findMatchingDeploymentAndServiceForWebhook(c *collector.Manifests, wcc admi
 	return depName, serviceName
 }
 
+// The port a webhook Service is served on if not set in a webhook's client config.
+const defaultWebhookServicePort int32 = 443
+
+// getWebhookContainerPort returns the port of depName's webhook server that serviceName
+// forwards wcc's requests to, which OLM requires as a webhook's containerPort since it
+// creates its own Service. 0 is returned if the port cannot be determined.
+func getWebhookContainerPort(c *collector.Manifests, wcc admissionregv1.WebhookClientConfig, depName, serviceName string) int32 {
+	if wcc.Service == nil || serviceName == "" {
+		return 0
+	}
+	servicePort := defaultWebhookServicePort
+	if wcc.Service.Port != nil {
+		servicePort = *wcc.Service.Port
+	}
+
+	var targetPort *intstr.IntOrString
+	for _, service := range c.Services {
+		if service.GetName() != serviceName {
+			continue
+		}
+		for i, port := range service.Spec.Ports {
+			if port.Port == servicePort {
+				targetPort = &service.Spec.Ports[i].TargetPort
+				break
+			}
+		}
+	}
+	switch {
+	case targetPort == nil:
+		return 0
+	case targetPort.Type == intstr.Int && targetPort.IntValue() == 0:
+		// The target port defaults to the service port.
+		return servicePort
+	case targetPort.Type == intstr.Int:
+		return targetPort.IntVal
+	}
+
+	// Resolve a named target port from the deployment's container ports.
+	for _, dep := range c.Deployments {
+		if dep.GetName() != depName {
+			continue
+		}
+		for _, container := range dep.Spec.Template.Spec.Containers {
+			for _, port := range container.Ports {
+				if port.Name == targetPort.StrVal {
+					return port.ContainerPort
+				}
+			}
+		}
+	}
+	return 0
+}
+
+const (
+	// relatedImageEnvPrefix prefixes names of container env vars containing images an operator uses.
+	relatedImageEnvPrefix = "RELATED_IMAGE_"
+	// managerContainerName is the name of operator containers in SDK project Deployments.
+	managerContainerName = "manager"
+)
+
+// applyRelatedImages sets csv's relatedImages to the images of manager containers
+// in csv's install strategy Deployments and the values of RELATED_IMAGE_* env vars
+// in those Deployments. Images are named "manager" and after the env var suffix,
+// respectively. Existing relatedImages with other names and images are kept.
+func applyRelatedImages(csv *operatorsv1alpha1.ClusterServiceVersion) {
+	var relatedImages []operatorsv1alpha1.RelatedImage
+	names, images := map[string]bool{}, map[string]bool{}
+	add := func(name, image string) {
+		if image == "" || images[image] {
+			return
+		}
+		// Names must be unique, so suffix duplicates with their index.
+		uniqueName := name
+		for i := 2; names[uniqueName]; i++ {
+			uniqueName = fmt.Sprintf("%s-%d", name, i)
+		}
+		names[uniqueName], images[image] = true, true
+		relatedImages = append(relatedImages, operatorsv1alpha1.RelatedImage{Name: uniqueName, Image: image})
+	}
+
+	depSpecs := csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs
+	for _, dep := range depSpecs {
+		for _, c := range dep.Spec.Template.Spec.Containers {
+			if c.Name == managerContainerName {
+				add(managerContainerName, c.Image)
+			}
+		}
+	}
+	for _, dep := range depSpecs {
+		containers := append([]corev1.Container{}, dep.Spec.Template.Spec.InitContainers...)
+		for _, c := range append(containers, dep.Spec.Template.Spec.Containers...) {
+			for _, env := range c.Env {
+				if strings.HasPrefix(env.Name, relatedImageEnvPrefix) && env.ValueFrom == nil {
+					name := strings.TrimPrefix(env.Name, relatedImageEnvPrefix)
+					add(strings.ReplaceAll(strings.ToLower(name), "_", "-"), env.Value)
+				}
+			}
+		}
+	}
+
+	for _, ri := range csv.Spec.RelatedImages {
+		if !names[ri.Name] && !images[ri.Image] {
+			names[ri.Name], images[ri.Image] = true, true
+			relatedImages = append(relatedImages, ri)
+		}
+	}
+	sort.SliceStable(relatedImages, func(i, j int) bool {
+		return relatedImages[i].Name < relatedImages[j].Name
+	})
+	csv.Spec.RelatedImages = relatedImages
+}
+
 // applyCustomResources updates csv's "alm-examples" annotation with the
-// Custom Resources in the collector.
+// Custom Resources in the collector. Existing examples of kinds that have no
+// collected Custom Resource, ex. hand-written in a base, are kept after them.
 func applyCustomResources(c *collector.Manifests, csv *operatorsv1alpha1.ClusterServiceVersion) error {
 	examples := []json.RawMessage{}
+	exampleObjs := []unstructured.Unstructured{}
+	collectedKinds := map[schema.GroupKind]struct{}{}
 	for _, cr := range c.CustomResources {
 		crBytes, err := cr.MarshalJSON()
 		if err != nil {
 			return err
 		}
 		examples = append(examples, json.RawMessage(crBytes))
+		exampleObjs = append(exampleObjs, cr)
+		collectedKinds[cr.GroupVersionKind().GroupKind()] = struct{}{}
 	}
 
+	if existingJSON := csv.GetAnnotations()[almExamplesAnnotation]; existingJSON != "" {
+		existing := []json.RawMessage{}
+		if err := json.Unmarshal([]byte(existingJSON), &existing); err != nil {
+			return fmt.Errorf("error parsing existing %s annotation: %v", almExamplesAnnotation, err)
+		}
+		for _, example := range existing {
+			u := unstructured.Unstructured{}
+			if err := u.UnmarshalJSON(example); err != nil {
+				return fmt.Errorf("error parsing existing %s annotation: %v", almExamplesAnnotation, err)
+			}
+			if _, hasCollected := collectedKinds[u.GroupVersionKind().GroupKind()]; !hasCollected {
+				examples = append(examples, example)
+				exampleObjs = append(exampleObjs, u)
+			}
+		}
+	}
+
+	// Sort examples by kind, then apiVersion and name, so they do not depend on input order.
+	indices := make([]int, len(examples))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		a, b := exampleObjs[indices[i]], exampleObjs[indices[j]]
+		if a.GetKind() != b.GetKind() {
+			return a.GetKind() < b.GetKind()
+		}
+		if a.GetAPIVersion() != b.GetAPIVersion() {
+			return a.GetAPIVersion() < b.GetAPIVersion()
+		}
+		return a.GetName() < b.GetName()
+	})
+	sorted := make([]json.RawMessage, len(examples))
+	for i, idx := range indices {
+		sorted[i] = examples[idx]
+	}
+	examples = sorted
+
 	examplesJSON, err := json.MarshalIndent(examples, "", "  ")
 	if err != nil {
 		return err
@@ -438,7 +758,7 @@ func applyCustomResources(c *collector.Manifests, csv *operatorsv1alpha1.Cluster
 	if csv.GetAnnotations() == nil {
 		csv.SetAnnotations(make(map[string]string))
 	}
-	csv.GetAnnotations()["alm-examples"] = string(examplesJSON)
+	csv.GetAnnotations()[almExamplesAnnotation] = string(examplesJSON)
 
 	return nil
 }
@@ -448,6 +768,42 @@ func applyCustomResources(c *collector.Manifests, csv *operatorsv1alpha1.Cluster
 func sortUpdates(csv *operatorsv1alpha1.ClusterServiceVersion) {
 	sort.Sort(descSorter(csv.Spec.CustomResourceDefinitions.Owned))
 	sort.Sort(descSorter(csv.Spec.CustomResourceDefinitions.Required))
+
+	strategy := &csv.Spec.InstallStrategy.StrategySpec
+	sort.SliceStable(strategy.DeploymentSpecs, func(i, j int) bool {
+		return strategy.DeploymentSpecs[i].Name < strategy.DeploymentSpecs[j].Name
+	})
+	sortPermissions(strategy.Permissions)
+	sortPermissions(strategy.ClusterPermissions)
+}
+
+// sortPermissions sorts perms by service account name, and each set of rules.
+func sortPermissions(perms []operatorsv1alpha1.StrategyDeploymentPermissions) {
+	sort.SliceStable(perms, func(i, j int) bool {
+		return perms[i].ServiceAccountName < perms[j].ServiceAccountName
+	})
+	for _, perm := range perms {
+		rules := perm.Rules
+		sort.SliceStable(rules, func(i, j int) bool {
+			return lessPolicyRule(rules[i], rules[j])
+		})
+	}
+}
+
+// lessPolicyRule compares rules by apiGroups, resources, resourceNames, nonResourceURLs, then verbs.
+func lessPolicyRule(a, b rbacv1.PolicyRule) bool {
+	for _, fields := range [][2][]string{
+		{a.APIGroups, b.APIGroups},
+		{a.Resources, b.Resources},
+		{a.ResourceNames, b.ResourceNames},
+		{a.NonResourceURLs, b.NonResourceURLs},
+		{a.Verbs, b.Verbs},
+	} {
+		if x, y := strings.Join(fields[0], ","), strings.Join(fields[1], ","); x != y {
+			return x < y
+		}
+	}
+	return false
 }
 
 // descSorter sorts a set of crdDescriptions.
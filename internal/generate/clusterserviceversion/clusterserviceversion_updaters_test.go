@@ -15,11 +15,20 @@
 package clusterserviceversion
 
 import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
 
 	"github.com/operator-framework/operator-sdk/internal/generate/collector"
 )
@@ -128,6 +137,59 @@ var _ = Describe("findMatchingDeploymentAndServiceForWebhook", func() {
 	})
 })
 
+var _ = Describe("getWebhookContainerPort", func() {
+	var (
+		c   *collector.Manifests
+		wcc admissionregv1.WebhookClientConfig
+	)
+
+	BeforeEach(func() {
+		labels := map[string]string{"control-plane": "controller-manager"}
+		dep := newDeployment("controller-manager", labels)
+		dep.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name:  "manager",
+			Ports: []corev1.ContainerPort{{Name: "webhook-server", ContainerPort: 9443}},
+		}}
+		c = &collector.Manifests{
+			Deployments: []appsv1.Deployment{dep},
+			Services:    []corev1.Service{newService("webhook-service", labels)},
+		}
+		wcc = admissionregv1.WebhookClientConfig{
+			Service: &admissionregv1.ServiceReference{Name: "webhook-service"},
+		}
+	})
+
+	It("returns the target port of the default service port", func() {
+		c.Services[0].Spec.Ports = []corev1.ServicePort{
+			{Port: 8443, TargetPort: intstr.FromInt(8080)},
+			{Port: 443, TargetPort: intstr.FromInt(9443)},
+		}
+		Expect(getWebhookContainerPort(c, wcc, "controller-manager", "webhook-service")).To(Equal(int32(9443)))
+	})
+	It("returns the target port of the client config's service port", func() {
+		port := int32(8443)
+		wcc.Service.Port = &port
+		c.Services[0].Spec.Ports = []corev1.ServicePort{
+			{Port: 8443, TargetPort: intstr.FromInt(8080)},
+			{Port: 443, TargetPort: intstr.FromInt(9443)},
+		}
+		Expect(getWebhookContainerPort(c, wcc, "controller-manager", "webhook-service")).To(Equal(int32(8080)))
+	})
+	It("resolves named target ports from the deployment's containers", func() {
+		c.Services[0].Spec.Ports = []corev1.ServicePort{{Port: 443, TargetPort: intstr.FromString("webhook-server")}}
+		Expect(getWebhookContainerPort(c, wcc, "controller-manager", "webhook-service")).To(Equal(int32(9443)))
+	})
+	It("defaults to the service port if the target port is not set", func() {
+		c.Services[0].Spec.Ports = []corev1.ServicePort{{Port: 443}}
+		Expect(getWebhookContainerPort(c, wcc, "controller-manager", "webhook-service")).To(Equal(int32(443)))
+	})
+	It("returns 0 if the port cannot be determined", func() {
+		c.Services[0].Spec.Ports = []corev1.ServicePort{{Port: 443, TargetPort: intstr.FromString("unknown")}}
+		Expect(getWebhookContainerPort(c, wcc, "controller-manager", "webhook-service")).To(BeZero())
+		Expect(getWebhookContainerPort(c, wcc, "controller-manager", "")).To(BeZero())
+	})
+})
+
 func newDeployment(name string, labels map[string]string) appsv1.Deployment {
 	dep := appsv1.Deployment{}
 	dep.SetName(name)
@@ -141,3 +203,218 @@ func newService(name string, labels map[string]string) corev1.Service {
 	s.Spec.Selector = labels
 	return s
 }
+
+var _ = Describe("applyCustomResources", func() {
+	samplesDir := filepath.Join(testDataDir, "samples")
+
+	newCRD := func(plural, kind string) apiextv1.CustomResourceDefinition {
+		crd := apiextv1.CustomResourceDefinition{}
+		crd.SetName(plural + ".cache.example.com")
+		crd.Spec.Group = "cache.example.com"
+		crd.Spec.Names.Kind = kind
+		crd.Spec.Versions = []apiextv1.CustomResourceDefinitionVersion{{Name: "v1alpha1"}}
+		return crd
+	}
+
+	It("should set alm-examples from samples, keeping hand-written examples of other kinds", func() {
+		c := &collector.Manifests{V1CustomResourceDefinitions: []apiextv1.CustomResourceDefinition{
+			newCRD("memcacheds", "Memcached"),
+			newCRD("memcachedrs", "MemcachedRS"),
+		}}
+		Expect(c.UpdateFromSamples(samplesDir)).To(Succeed())
+
+		csv := &operatorsv1alpha1.ClusterServiceVersion{}
+		csv.SetAnnotations(map[string]string{almExamplesAnnotation: `[
+			{"apiVersion": "cache.example.com/v1alpha1", "kind": "Memcached", "metadata": {"name": "outdated"}},
+			{"apiVersion": "cache.example.com/v1alpha2", "kind": "Dummy", "metadata": {"name": "dummy-sample"}, "spec": {}}
+		]`})
+		Expect(applyCustomResources(c, csv)).To(Succeed())
+
+		golden, err := ioutil.ReadFile(filepath.Join(samplesDir, "alm-examples.golden.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(csv.GetAnnotations()[almExamplesAnnotation]).To(Equal(strings.TrimSpace(string(golden))))
+	})
+	It("should fail if the existing alm-examples are invalid", func() {
+		csv := &operatorsv1alpha1.ClusterServiceVersion{}
+		csv.SetAnnotations(map[string]string{almExamplesAnnotation: `[{"kind": `})
+		Expect(applyCustomResources(&collector.Manifests{}, csv)).
+			To(MatchError(ContainSubstring("error parsing existing alm-examples annotation")))
+	})
+})
+
+var _ = Describe("applyRelatedImages", func() {
+	relatedImagesDir := filepath.Join(testDataDir, "relatedimages")
+
+	var csv *operatorsv1alpha1.ClusterServiceVersion
+
+	BeforeEach(func() {
+		c := &collector.Manifests{}
+		collectManifestsFromFileHelper(c, filepath.Join(relatedImagesDir, "deployment.yaml"))
+		csv = &operatorsv1alpha1.ClusterServiceVersion{}
+		Expect(apply(c, csv)).To(Succeed())
+	})
+
+	It("should set relatedImages from manager images and RELATED_IMAGE_ env vars", func() {
+		applyRelatedImages(csv)
+
+		b, err := yaml.Marshal(csv.Spec.RelatedImages)
+		Expect(err).NotTo(HaveOccurred())
+		golden, err := ioutil.ReadFile(filepath.Join(relatedImagesDir, "relatedimages.golden.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal(string(golden)))
+	})
+	It("should keep existing relatedImages not generated from the deployment", func() {
+		csv.Spec.RelatedImages = []operatorsv1alpha1.RelatedImage{
+			{Name: "manager", Image: "quay.io/example/memcached-operator:v0.0.0"},
+			{Name: "kube-rbac-proxy", Image: "gcr.io/kubebuilder/kube-rbac-proxy:v0.5.0"},
+		}
+		applyRelatedImages(csv)
+
+		Expect(csv.Spec.RelatedImages).To(Equal([]operatorsv1alpha1.RelatedImage{
+			{Name: "kube-rbac-proxy", Image: "gcr.io/kubebuilder/kube-rbac-proxy:v0.5.0"},
+			{Name: "manager", Image: "quay.io/example/memcached-operator:v0.0.1"},
+			{Name: "memcached", Image: "docker.io/library/memcached:1.4.36-alpine"},
+			{Name: "metrics-exporter", Image: "quay.io/prometheus/memcached-exporter:v0.7.0"},
+		}))
+	})
+})
+
+var _ = Describe("applyConversionWebhooks", func() {
+	conversionDir := filepath.Join(testDataDir, "conversion")
+
+	var (
+		c   *collector.Manifests
+		csv *operatorsv1alpha1.ClusterServiceVersion
+	)
+
+	BeforeEach(func() {
+		c = &collector.Manifests{}
+		collectManifestsFromFileHelper(c, filepath.Join(conversionDir, "manifests.yaml"))
+		csv = &operatorsv1alpha1.ClusterServiceVersion{}
+	})
+
+	It("should add a conversion webhook for a two-version CRD", func() {
+		Expect(apply(c, csv)).To(Succeed())
+
+		b, err := yaml.Marshal(csv.Spec.WebhookDefinitions)
+		Expect(err).NotTo(HaveOccurred())
+		golden, err := ioutil.ReadFile(filepath.Join(conversionDir, "webhookdefinitions.golden.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal(string(golden)))
+	})
+	It("should fail if the conversion service does not select the manager deployment", func() {
+		c.Services[0].Spec.Selector = map[string]string{"control-plane": "other"}
+		Expect(apply(c, csv)).To(MatchError(ContainSubstring(`CRD "memcacheds.cache.example.com" conversion webhook ` +
+			`Service "memcached-operator-webhook-service" does not select the manager Deployment`)))
+	})
+	It("should fail if the conversion strategy is not Webhook", func() {
+		c.V1CustomResourceDefinitions[0].Spec.Conversion.Strategy = apiextv1.NoneConverter
+		Expect(apply(c, csv)).To(MatchError(ContainSubstring(`conversion strategy "None", expected Webhook`)))
+	})
+	It("should fail if the CRD is not owned by the CSV", func() {
+		applyCustomResourceDefinitions(c, csv)
+		csv.Spec.CustomResourceDefinitions.Owned = nil
+		Expect(applyConversionWebhooks(c, csv)).To(MatchError(ContainSubstring("is not owned by the CSV")))
+	})
+})
+
+var _ = Describe("applyRoles and applyClusterRoles", func() {
+	rbacDir := filepath.Join(testDataDir, "rbac")
+
+	var c *collector.Manifests
+
+	BeforeEach(func() {
+		c = &collector.Manifests{}
+		collectManifestsFromFileHelper(c, filepath.Join(rbacDir, "manifests.yaml"))
+	})
+
+	It("should merge bound and aggregated roles into one set of rules per ServiceAccount", func() {
+		csv := &operatorsv1alpha1.ClusterServiceVersion{}
+		Expect(apply(c, csv)).To(Succeed())
+		sortUpdates(csv)
+
+		strategy := csv.Spec.InstallStrategy.StrategySpec
+		b, err := yaml.Marshal(map[string]interface{}{
+			"permissions":        strategy.Permissions,
+			"clusterPermissions": strategy.ClusterPermissions,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		golden, err := ioutil.ReadFile(filepath.Join(rbacDir, "permissions.golden.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal(string(golden)))
+	})
+	It("should drop roles that are not bound to any subject", func() {
+		Expect(c.UnusedRoles()).To(HaveLen(1))
+		Expect(c.UnusedRoles()[0].GetName()).To(Equal("memcached-operator-metrics-reader"))
+		_, out := c.SplitCSVClusterPermissionsObjects()
+		Expect(out).To(HaveLen(0))
+	})
+})
+
+var _ = Describe("applyDeployments", func() {
+	deploymentsDir := filepath.Join(testDataDir, "deployments")
+
+	It("should add every Deployment with the permissions of its ServiceAccount", func() {
+		c := &collector.Manifests{}
+		collectManifestsFromFileHelper(c, filepath.Join(deploymentsDir, "manifests.yaml"))
+		csv := &operatorsv1alpha1.ClusterServiceVersion{}
+		Expect(apply(c, csv)).To(Succeed())
+		sortUpdates(csv)
+
+		strategy := csv.Spec.InstallStrategy.StrategySpec
+		b, err := yaml.Marshal(map[string]interface{}{
+			"deployments":        strategy.DeploymentSpecs,
+			"permissions":        strategy.Permissions,
+			"clusterPermissions": strategy.ClusterPermissions,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		golden, err := ioutil.ReadFile(filepath.Join(deploymentsDir, "install.golden.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(MatchYAML(string(golden)))
+	})
+})
+
+var _ = Describe("mergeRules", func() {
+	It("de-duplicates rules and merges verbs of rules for the same resources", func() {
+		rules := []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+			{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+			{APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"foo"}, Verbs: []string{"delete"}},
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list", "get"}},
+		}
+		Expect(mergeRules(rules)).To(Equal([]rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+			{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+			{APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"foo"}, Verbs: []string{"delete"}},
+		}))
+	})
+})
+
+var _ = Describe("sortPermissions", func() {
+	It("sorts permissions by service account name then rule", func() {
+		perms := []operatorsv1alpha1.StrategyDeploymentPermissions{
+			{ServiceAccountName: "sa-b", Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"cache.example.com"}, Resources: []string{"memcacheds"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+			}},
+			{ServiceAccountName: "sa-a", Rules: []rbacv1.PolicyRule{
+				{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"watch"}},
+			}},
+		}
+		sortPermissions(perms)
+		Expect(perms).To(Equal([]operatorsv1alpha1.StrategyDeploymentPermissions{
+			{ServiceAccountName: "sa-a", Rules: []rbacv1.PolicyRule{
+				{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"watch"}},
+			}},
+			{ServiceAccountName: "sa-b", Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+				{APIGroups: []string{"cache.example.com"}, Resources: []string{"memcacheds"}, Verbs: []string{"get"}},
+			}},
+		}))
+	})
+})
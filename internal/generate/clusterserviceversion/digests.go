@@ -0,0 +1,128 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterserviceversion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/breaker"
+	"github.com/operator-framework/operator-sdk/internal/registry"
+)
+
+// containerImageAnnotation contains the operator's image.
+const containerImageAnnotation = "containerImage"
+
+// applyImageDigests replaces all image references in csv's install strategy Deployment
+// containers, RELATED_IMAGE_* env vars, relatedImages, and "containerImage" annotation
+// with references pinned to the digests resolved by resolver, ex. "memcached:1.4" becomes
+// "memcached@sha256:abc...". Images already pinned to a digest are left as-is.
+// An image that cannot be resolved is an error unless skipUnresolvable is true,
+// in which case it is logged and left as-is.
+//
+// Calls to each registry host are bounded by breakers. Once a host's breaker
+// opens, its remaining images are left pinned to their tags with a warning,
+// or are an error if requireDigests is true.
+func applyImageDigests(ctx context.Context, csv *operatorsv1alpha1.ClusterServiceVersion,
+	resolver registry.DigestResolver, breakers *breaker.Breakers, skipUnresolvable, requireDigests bool) error {
+
+	defer func() {
+		for _, line := range breakers.Summary() {
+			log.Debug(line)
+		}
+	}()
+
+	// Resolve each image only once, since many may be the same.
+	pinned := map[string]string{}
+	pin := func(image string) (string, error) {
+		if image == "" || strings.Contains(image, "@") {
+			return image, nil
+		}
+		if p, ok := pinned[image]; ok {
+			return p, nil
+		}
+		var digest string
+		err := breakers.Do(ctx, image, func(ctx context.Context) (err error) {
+			digest, err = resolver.ResolveDigest(ctx, image)
+			return err
+		})
+		if errors.Is(err, breaker.ErrOpen) {
+			if requireDigests {
+				return "", fmt.Errorf("error resolving digest of image %q: %v", image, err)
+			}
+			log.Warnf("Leaving image %q pinned to its tag: %v", image, err)
+			pinned[image] = image
+			return image, nil
+		}
+		if err != nil {
+			if !skipUnresolvable {
+				return "", fmt.Errorf("error resolving digest of image %q: %v", image, err)
+			}
+			log.Warnf("Skipping unresolvable image %q: %v", image, err)
+			pinned[image] = image
+			return image, nil
+		}
+		name, _ := registry.SplitImageTag(image)
+		pinned[image] = name + "@" + digest
+		return pinned[image], nil
+	}
+	pinContainers := func(containers []corev1.Container) (err error) {
+		for i, c := range containers {
+			if containers[i].Image, err = pin(c.Image); err != nil {
+				return err
+			}
+			for j, env := range c.Env {
+				if strings.HasPrefix(env.Name, relatedImageEnvPrefix) && env.ValueFrom == nil {
+					if containers[i].Env[j].Value, err = pin(env.Value); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	depSpecs := csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs
+	for i := range depSpecs {
+		podSpec := &depSpecs[i].Spec.Template.Spec
+		if err := pinContainers(podSpec.InitContainers); err != nil {
+			return err
+		}
+		if err := pinContainers(podSpec.Containers); err != nil {
+			return err
+		}
+	}
+	for i, ri := range csv.Spec.RelatedImages {
+		image, err := pin(ri.Image)
+		if err != nil {
+			return err
+		}
+		csv.Spec.RelatedImages[i].Image = image
+	}
+	if annotations := csv.GetAnnotations(); annotations[containerImageAnnotation] != "" {
+		image, err := pin(annotations[containerImageAnnotation])
+		if err != nil {
+			return err
+		}
+		annotations[containerImageAnnotation] = image
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterserviceversion
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/breaker"
+)
+
+// fakeDigestResolver resolves images to digests in a map, counting resolutions.
+type fakeDigestResolver struct {
+	digests  map[string]string
+	resolved map[string]int
+}
+
+func (r *fakeDigestResolver) ResolveDigest(_ context.Context, image string) (string, error) {
+	r.resolved[image]++
+	if digest, ok := r.digests[image]; ok {
+		return digest, nil
+	}
+	return "", errors.New("manifest unknown")
+}
+
+var _ = Describe("applyImageDigests", func() {
+	const (
+		managerDigest   = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		proxyDigest     = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+		memcachedDigest = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+		pinnedImage     = "quay.io/example/pinned@sha256:4444444444444444444444444444444444444444444444444444444444444444"
+	)
+
+	var (
+		resolver *fakeDigestResolver
+		breakers *breaker.Breakers
+		csv      *operatorsv1alpha1.ClusterServiceVersion
+	)
+
+	BeforeEach(func() {
+		breakers = breaker.New(breaker.Options{})
+		resolver = &fakeDigestResolver{
+			digests: map[string]string{
+				"quay.io/example/memcached-operator:v0.0.1": managerDigest,
+				"gcr.io/kubebuilder/kube-rbac-proxy:v0.5.0": proxyDigest,
+				"memcached:1.4.36-alpine":                   memcachedDigest,
+			},
+			resolved: map[string]int{},
+		}
+		csv = &operatorsv1alpha1.ClusterServiceVersion{}
+		csv.SetAnnotations(map[string]string{containerImageAnnotation: "quay.io/example/memcached-operator:v0.0.1"})
+		dep := appsv1.DeploymentSpec{}
+		dep.Template.Spec.InitContainers = []corev1.Container{{Name: "init", Image: pinnedImage}}
+		dep.Template.Spec.Containers = []corev1.Container{
+			{Name: "kube-rbac-proxy", Image: "gcr.io/kubebuilder/kube-rbac-proxy:v0.5.0"},
+			{
+				Name:  "manager",
+				Image: "quay.io/example/memcached-operator:v0.0.1",
+				Env: []corev1.EnvVar{
+					{Name: "RELATED_IMAGE_MEMCACHED", Value: "memcached:1.4.36-alpine"},
+					{Name: "WATCH_NAMESPACE", Value: "memcached:not-an-image"},
+				},
+			},
+		}
+		csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs = []operatorsv1alpha1.StrategyDeploymentSpec{
+			{Name: "memcached-operator-controller-manager", Spec: dep},
+		}
+		csv.Spec.RelatedImages = []operatorsv1alpha1.RelatedImage{
+			{Name: "manager", Image: "quay.io/example/memcached-operator:v0.0.1"},
+			{Name: "memcached", Image: "memcached:1.4.36-alpine"},
+		}
+	})
+
+	It("should pin all images to their digests", func() {
+		Expect(applyImageDigests(context.TODO(), csv, resolver, breakers, false, false)).To(Succeed())
+
+		managerImage := "quay.io/example/memcached-operator@" + managerDigest
+		memcachedImage := "memcached@" + memcachedDigest
+		podSpec := csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs[0].Spec.Template.Spec
+		Expect(podSpec.InitContainers[0].Image).To(Equal(pinnedImage))
+		Expect(podSpec.Containers[0].Image).To(Equal("gcr.io/kubebuilder/kube-rbac-proxy@" + proxyDigest))
+		Expect(podSpec.Containers[1].Image).To(Equal(managerImage))
+		Expect(podSpec.Containers[1].Env).To(Equal([]corev1.EnvVar{
+			{Name: "RELATED_IMAGE_MEMCACHED", Value: memcachedImage},
+			{Name: "WATCH_NAMESPACE", Value: "memcached:not-an-image"},
+		}))
+		Expect(csv.Spec.RelatedImages).To(Equal([]operatorsv1alpha1.RelatedImage{
+			{Name: "manager", Image: managerImage},
+			{Name: "memcached", Image: memcachedImage},
+		}))
+		Expect(csv.GetAnnotations()).To(HaveKeyWithValue(containerImageAnnotation, managerImage))
+	})
+	It("should resolve each image once and not resolve pinned images", func() {
+		Expect(applyImageDigests(context.TODO(), csv, resolver, breakers, false, false)).To(Succeed())
+		Expect(resolver.resolved).To(Equal(map[string]int{
+			"quay.io/example/memcached-operator:v0.0.1": 1,
+			"gcr.io/kubebuilder/kube-rbac-proxy:v0.5.0": 1,
+			"memcached:1.4.36-alpine":                   1,
+		}))
+	})
+	It("should fail naming an unresolvable image", func() {
+		delete(resolver.digests, "memcached:1.4.36-alpine")
+		err := applyImageDigests(context.TODO(), csv, resolver, breakers, false, false)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(`image "memcached:1.4.36-alpine"`))
+	})
+	It("should leave unresolvable images as-is if skipping them", func() {
+		delete(resolver.digests, "memcached:1.4.36-alpine")
+		Expect(applyImageDigests(context.TODO(), csv, resolver, breakers, true, false)).To(Succeed())
+
+		podSpec := csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs[0].Spec.Template.Spec
+		Expect(podSpec.Containers[1].Image).To(Equal("quay.io/example/memcached-operator@" + managerDigest))
+		Expect(podSpec.Containers[1].Env[0].Value).To(Equal("memcached:1.4.36-alpine"))
+		Expect(csv.Spec.RelatedImages[1].Image).To(Equal("memcached:1.4.36-alpine"))
+	})
+	Context("with a registry whose breaker opens", func() {
+		flakyImages := []string{
+			"flaky.example.com/org/a:v0.0.1",
+			"flaky.example.com/org/b:v0.0.1",
+			"flaky.example.com/org/c:v0.0.1",
+			"flaky.example.com/org/d:v0.0.1",
+		}
+		BeforeEach(func() {
+			for _, image := range flakyImages {
+				csv.Spec.RelatedImages = append(csv.Spec.RelatedImages, operatorsv1alpha1.RelatedImage{Name: image, Image: image})
+			}
+		})
+
+		It("should stop resolving the registry's images at the failure threshold", func() {
+			breakers = breaker.New(breaker.Options{FailureThreshold: 2})
+			Expect(applyImageDigests(context.TODO(), csv, resolver, breakers, true, false)).To(Succeed())
+
+			Expect(resolver.resolved).To(HaveKeyWithValue(flakyImages[0], 1))
+			Expect(resolver.resolved).To(HaveKeyWithValue(flakyImages[1], 1))
+			Expect(resolver.resolved).NotTo(HaveKey(flakyImages[2]))
+			Expect(resolver.resolved).NotTo(HaveKey(flakyImages[3]))
+			for i, image := range flakyImages {
+				Expect(csv.Spec.RelatedImages[2+i].Image).To(Equal(image))
+			}
+			Expect(csv.Spec.RelatedImages[0].Image).To(Equal("quay.io/example/memcached-operator@" + managerDigest))
+			stats := breakers.Stats()["flaky.example.com"]
+			Expect(stats.Failed).To(Equal(2))
+			Expect(stats.Skipped).To(Equal(2))
+			Expect(stats.Open).To(BeTrue())
+		})
+		It("should leave skipped images pinned to their tags", func() {
+			breakers = openBreaker("flaky.example.com")
+			for _, image := range flakyImages {
+				resolver.digests[image] = managerDigest
+			}
+			Expect(applyImageDigests(context.TODO(), csv, resolver, breakers, false, false)).To(Succeed())
+
+			Expect(resolver.resolved).NotTo(HaveKey(flakyImages[0]))
+			Expect(csv.Spec.RelatedImages[2].Image).To(Equal(flakyImages[0]))
+			Expect(csv.Spec.RelatedImages[0].Image).To(Equal("quay.io/example/memcached-operator@" + managerDigest))
+		})
+		It("should fail for skipped images if digests are required", func() {
+			breakers = openBreaker("flaky.example.com")
+			err := applyImageDigests(context.TODO(), csv, resolver, breakers, false, true)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(`image "flaky.example.com/org/a:v0.0.1"`))
+			Expect(err.Error()).To(ContainSubstring(breaker.ErrOpen.Error()))
+		})
+	})
+})
+
+// blockingDigestResolver blocks until the context it resolves with is done.
+type blockingDigestResolver struct{}
+
+func (blockingDigestResolver) ResolveDigest(ctx context.Context, _ string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+var _ = Describe("Generator.pinImageDigests", func() {
+	It("should stop resolving digests after the digest timeout", func() {
+		csv := &operatorsv1alpha1.ClusterServiceVersion{}
+		csv.SetAnnotations(map[string]string{containerImageAnnotation: "quay.io/example/memcached-operator:v0.0.1"})
+		g := Generator{DigestResolver: blockingDigestResolver{}, DigestTimeout: 10 * time.Millisecond}
+
+		done := make(chan error, 1)
+		go func() { done <- g.pinImageDigests(csv) }()
+		var err error
+		Eventually(done, time.Second).Should(Receive(&err))
+		Expect(err).To(MatchError(ContainSubstring(context.DeadlineExceeded.Error())))
+	})
+})
+
+// openBreaker returns breakers whose breaker for host is open.
+func openBreaker(host string) *breaker.Breakers {
+	b := breaker.New(breaker.Options{FailureThreshold: 1})
+	_ = b.Do(context.TODO(), host+"/org/operator:v0.0.1", func(context.Context) error {
+		return errors.New("500 Internal Server Error")
+	})
+	return b
+}
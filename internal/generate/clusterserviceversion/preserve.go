@@ -0,0 +1,89 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterserviceversion
+
+import (
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+
+	metricsannotations "github.com/operator-framework/operator-sdk/internal/annotations/metrics"
+	"github.com/operator-framework/operator-sdk/internal/registry"
+)
+
+// sdkOwnedAnnotations are CSV annotations the generator always sets itself.
+var sdkOwnedAnnotations = map[string]struct{}{
+	metricsannotations.BuilderObjectAnnotation: {},
+	metricsannotations.LayoutObjectAnnotation:  {},
+}
+
+// preserveHumanOwnedFields copies fields of existing that are edited by hand,
+// rather than derived from project manifests, to csv if set in existing:
+// description, displayName, icon, keywords, links, maintainers, provider,
+// minKubeVersion, and annotations not owned by the SDK. Existing alm-examples are merged with
+// collected samples later by applyCustomResources.
+func preserveHumanOwnedFields(existing, csv *operatorsv1alpha1.ClusterServiceVersion) {
+	if existing.Spec.Description != "" {
+		csv.Spec.Description = existing.Spec.Description
+	}
+	if existing.Spec.DisplayName != "" {
+		csv.Spec.DisplayName = existing.Spec.DisplayName
+	}
+	if len(existing.Spec.Icon) != 0 {
+		csv.Spec.Icon = existing.Spec.Icon
+	}
+	if len(existing.Spec.Keywords) != 0 {
+		csv.Spec.Keywords = existing.Spec.Keywords
+	}
+	if len(existing.Spec.Links) != 0 {
+		csv.Spec.Links = existing.Spec.Links
+	}
+	if len(existing.Spec.Maintainers) != 0 {
+		csv.Spec.Maintainers = existing.Spec.Maintainers
+	}
+	if existing.Spec.Provider.Name != "" || existing.Spec.Provider.URL != "" {
+		csv.Spec.Provider = existing.Spec.Provider
+	}
+	if existing.Spec.MinKubeVersion != "" {
+		csv.Spec.MinKubeVersion = existing.Spec.MinKubeVersion
+	}
+
+	annotations := csv.GetAnnotations()
+	for key, value := range existing.GetAnnotations() {
+		if _, sdkOwned := sdkOwnedAnnotations[key]; sdkOwned {
+			continue
+		}
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[key] = value
+	}
+	csv.SetAnnotations(annotations)
+}
+
+// preserveUpgradeFields copies existing's upgrade graph fields, spec.skips and
+// the olm.skipRange annotation, to csv if set in existing. These are kept even
+// when human-owned fields are overwritten, since a base never contains them.
+func preserveUpgradeFields(existing, csv *operatorsv1alpha1.ClusterServiceVersion) {
+	if len(existing.Spec.Skips) != 0 {
+		csv.Spec.Skips = existing.Spec.Skips
+	}
+	if skipRange, hasSkipRange := existing.GetAnnotations()[registry.SkipRangeAnnotation]; hasSkipRange {
+		annotations := csv.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[registry.SkipRangeAnnotation] = skipRange
+		csv.SetAnnotations(annotations)
+	}
+}
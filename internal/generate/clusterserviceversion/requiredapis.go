@@ -0,0 +1,166 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterserviceversion
+
+import (
+	"sort"
+	"strings"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/operator-framework/operator-sdk/internal/generate/collector"
+)
+
+// builtinAPIGroups are API groups every Kubernetes cluster serves, so their kinds need not be declared.
+var builtinAPIGroups = map[string]struct{}{
+	"":                             {},
+	"admissionregistration.k8s.io": {},
+	"apiextensions.k8s.io":         {},
+	"apiregistration.k8s.io":       {},
+	"apps":                         {},
+	"authentication.k8s.io":        {},
+	"authorization.k8s.io":         {},
+	"autoscaling":                  {},
+	"batch":                        {},
+	"certificates.k8s.io":          {},
+	"coordination.k8s.io":          {},
+	"discovery.k8s.io":             {},
+	"events.k8s.io":                {},
+	"extensions":                   {},
+	"flowcontrol.apiserver.k8s.io": {},
+	"networking.k8s.io":            {},
+	"node.k8s.io":                  {},
+	"policy":                       {},
+	"rbac.authorization.k8s.io":    {},
+	"scheduling.k8s.io":            {},
+	"storage.k8s.io":               {},
+}
+
+// nativeAPIGroups are API groups commonly served by aggregated API servers rather than
+// CustomResourceDefinitions, so their kinds are declared in spec.nativeAPIs.
+var nativeAPIGroups = map[string]struct{}{
+	"metrics.k8s.io":             {},
+	"custom.metrics.k8s.io":      {},
+	"external.metrics.k8s.io":    {},
+	"apps.openshift.io":          {},
+	"authorization.openshift.io": {},
+	"build.openshift.io":         {},
+	"image.openshift.io":         {},
+	"oauth.openshift.io":         {},
+	"project.openshift.io":       {},
+	"quota.openshift.io":         {},
+	"route.openshift.io":         {},
+	"security.openshift.io":      {},
+	"template.openshift.io":      {},
+	"user.openshift.io":          {},
+}
+
+// ignoredAPIGroups are API groups of objects that are never written to a bundle,
+// ex. cert-manager objects, since OLM manages webhook certificates itself, and
+// OLM objects such as ClusterServiceVersions in a legacy deploy/olm-catalog.
+var ignoredAPIGroups = map[string]struct{}{
+	"cert-manager.io":      {},
+	"operators.coreos.com": {},
+}
+
+// applyRequiredAPIs declares the APIs of objects in c.Others that are not built into
+// Kubernetes or owned by csv: kinds served by aggregated API servers in spec.nativeAPIs,
+// and all other kinds as required CustomResourceDefinitions. Existing declarations are kept.
+// Kinds OLM cannot declare, ex. those in a group that cannot belong to a CRD, are warned about.
+func applyRequiredAPIs(c *collector.Manifests, csv *operatorsv1alpha1.ClusterServiceVersion) {
+	ownedGKs := make(map[schema.GroupKind]struct{})
+	for _, desc := range csv.Spec.CustomResourceDefinitions.Owned {
+		ownedGKs[schema.GroupKind{Group: getCRDGroup(desc.Name), Kind: desc.Kind}] = struct{}{}
+	}
+	crdNames := make(map[string]struct{})
+	for _, desc := range csv.Spec.CustomResourceDefinitions.Required {
+		crdNames[desc.Name] = struct{}{}
+	}
+	nativeGVKs := make(map[metav1.GroupVersionKind]struct{})
+	for _, gvk := range csv.Spec.NativeAPIs {
+		nativeGVKs[gvk] = struct{}{}
+	}
+	warned := make(map[schema.GroupVersionKind]struct{})
+
+	for _, obj := range c.Others {
+		gvk := obj.GroupVersionKind()
+		if _, owned := ownedGKs[gvk.GroupKind()]; owned {
+			continue
+		}
+		if _, builtin := builtinAPIGroups[gvk.Group]; builtin {
+			continue
+		}
+		if _, ignored := ignoredAPIGroups[gvk.Group]; ignored {
+			continue
+		}
+
+		if _, native := nativeAPIGroups[gvk.Group]; native {
+			nativeGVK := metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind}
+			if _, hasGVK := nativeGVKs[nativeGVK]; !hasGVK {
+				nativeGVKs[nativeGVK] = struct{}{}
+				csv.Spec.NativeAPIs = append(csv.Spec.NativeAPIs, nativeGVK)
+			}
+			continue
+		}
+
+		// CRD groups must contain a ".", so these kinds cannot be required as CRDs.
+		if gvk.Version == "" || gvk.Kind == "" || !strings.Contains(gvk.Group, ".") {
+			if _, hasWarned := warned[gvk]; !hasWarned {
+				warned[gvk] = struct{}{}
+				log.Warnf("Cannot declare API %s of object %q as a required CustomResourceDefinition or native API, "+
+					"declare it in your base CSV if your operator requires it", gvk, obj.GetName())
+			}
+			continue
+		}
+
+		plural, _ := meta.UnsafeGuessKindToResource(gvk)
+		crdName := plural.Resource + "." + gvk.Group
+		if _, hasCRD := crdNames[crdName]; !hasCRD {
+			crdNames[crdName] = struct{}{}
+			csv.Spec.CustomResourceDefinitions.Required = append(csv.Spec.CustomResourceDefinitions.Required,
+				operatorsv1alpha1.CRDDescription{
+					Name:        crdName,
+					Version:     gvk.Version,
+					Kind:        gvk.Kind,
+					DisplayName: gvk.Kind,
+				})
+		}
+	}
+
+	sort.Sort(descSorter(csv.Spec.CustomResourceDefinitions.Required))
+	sort.Slice(csv.Spec.NativeAPIs, func(i, j int) bool {
+		a, b := csv.Spec.NativeAPIs[i], csv.Spec.NativeAPIs[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Version < b.Version
+	})
+}
+
+// getCRDGroup returns the group of a CRD from its name, ex. "cache.example.com" for
+// "memcacheds.cache.example.com".
+func getCRDGroup(crdName string) string {
+	if i := strings.Index(crdName, "."); i >= 0 {
+		return crdName[i+1:]
+	}
+	return ""
+}
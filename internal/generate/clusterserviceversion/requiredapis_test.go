@@ -0,0 +1,80 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterserviceversion
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/operator-sdk/internal/generate/collector"
+)
+
+var _ = Describe("applyRequiredAPIs", func() {
+	requiredAPIsDir := filepath.Join(testDataDir, "requiredapis", "config")
+
+	var (
+		c   *collector.Manifests
+		csv *operatorsv1alpha1.ClusterServiceVersion
+	)
+
+	BeforeEach(func() {
+		c = &collector.Manifests{}
+		csv = &operatorsv1alpha1.ClusterServiceVersion{}
+	})
+
+	It("should require the CRD of a ServiceMonitor in config/prometheus", func() {
+		collectManifestsFromFileHelper(c, filepath.Join(requiredAPIsDir, "prometheus", "monitor.yaml"))
+		applyRequiredAPIs(c, csv)
+
+		Expect(csv.Spec.CustomResourceDefinitions.Required).To(Equal([]operatorsv1alpha1.CRDDescription{
+			{Name: "servicemonitors.monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor", DisplayName: "ServiceMonitor"},
+		}))
+		Expect(csv.Spec.NativeAPIs).To(BeEmpty())
+	})
+	It("should declare required CRDs and native APIs, skipping built-in and ignored APIs", func() {
+		collectManifestsFromFileHelper(c, filepath.Join(requiredAPIsDir, "prometheus", "monitor.yaml"))
+		collectManifestsFromFileHelper(c, filepath.Join(requiredAPIsDir, "other", "manifests.yaml"))
+		applyRequiredAPIs(c, csv)
+
+		Expect(csv.Spec.CustomResourceDefinitions.Required).To(Equal([]operatorsv1alpha1.CRDDescription{
+			{Name: "prometheusrules.monitoring.coreos.com", Version: "v1", Kind: "PrometheusRule", DisplayName: "PrometheusRule"},
+			{Name: "servicemonitors.monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor", DisplayName: "ServiceMonitor"},
+		}))
+		Expect(csv.Spec.NativeAPIs).To(Equal([]metav1.GroupVersionKind{
+			{Group: "route.openshift.io", Version: "v1", Kind: "Route"},
+		}))
+	})
+	It("should keep existing declarations and not require owned CRDs", func() {
+		collectManifestsFromFileHelper(c, filepath.Join(requiredAPIsDir, "prometheus", "monitor.yaml"))
+		collectManifestsFromFileHelper(c, filepath.Join(requiredAPIsDir, "other", "manifests.yaml"))
+		csv.Spec.CustomResourceDefinitions.Owned = []operatorsv1alpha1.CRDDescription{
+			{Name: "prometheusrules.monitoring.coreos.com", Version: "v1", Kind: "PrometheusRule"},
+		}
+		existing := operatorsv1alpha1.CRDDescription{
+			Name: "servicemonitors.monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor",
+			DisplayName: "Service Monitor", Description: "Monitors the operator's metrics.",
+		}
+		csv.Spec.CustomResourceDefinitions.Required = []operatorsv1alpha1.CRDDescription{existing}
+		csv.Spec.NativeAPIs = []metav1.GroupVersionKind{{Group: "route.openshift.io", Version: "v1", Kind: "Route"}}
+		applyRequiredAPIs(c, csv)
+
+		Expect(csv.Spec.CustomResourceDefinitions.Required).To(Equal([]operatorsv1alpha1.CRDDescription{existing}))
+		Expect(csv.Spec.NativeAPIs).To(HaveLen(1))
+	})
+})
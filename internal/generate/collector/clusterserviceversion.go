@@ -15,154 +15,223 @@
 package collector
 
 import (
+	log "github.com/sirupsen/logrus"
 	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-// TODO(estroz): there's a significant amount of code dupliation here, a byproduct of Go's type system.
-// However at least a few bits can be refactored so each method is smaller.
-
 const (
 	// This service account exists in every namespace as the default.
 	defaultServiceAccountName = "default"
 
 	serviceAccountKind = "ServiceAccount"
+	roleKind           = "Role"
+	clusterRoleKind    = "ClusterRole"
 )
 
 // SplitCSVPermissionsObjects splits roles that should be written to a CSV as permissions (in)
 // from roles and role bindings that should be written directly to the bundle (out).
-func (c *Manifests) SplitCSVPermissionsObjects() (in, out []controllerutil.Object) { //nolint:dupl
-	roleMap := make(map[string]*rbacv1.Role)
-	for i := range c.Roles {
-		roleMap[c.Roles[i].GetName()] = &c.Roles[i]
-	}
-	roleBindingMap := make(map[string]*rbacv1.RoleBinding)
+//
+// A Role or ClusterRole is in if a RoleBinding binds it to a Deployment's service account.
+// A Role and its RoleBinding are out if that binding has any other subject; ClusterRoles bound by
+// such a RoleBinding are written by SplitCSVClusterPermissionsObjects. Unbound Roles are neither,
+// see UnusedRoles.
+func (c *Manifests) SplitCSVPermissionsObjects() (in, out []controllerutil.Object) {
+	in, out, _ = c.splitCSVPermissionsObjects()
+	return in, out
+}
+
+func (c *Manifests) splitCSVPermissionsObjects() (in, out, unused []controllerutil.Object) {
+	deploymentSANames := c.getDeploymentServiceAccountNames()
+
+	boundRoleNames := make(map[string]struct{})
+	inRoleNames := make(map[string]struct{})
+	inClusterRoleNames := make(map[string]struct{})
+	outRoleNames := make(map[string]struct{})
+	var outBindings []controllerutil.Object
 	for i := range c.RoleBindings {
-		roleBindingMap[c.RoleBindings[i].GetName()] = &c.RoleBindings[i]
+		binding := &c.RoleBindings[i]
+		roleRef := binding.RoleRef
+		if !isRBACRoleRef(roleRef) {
+			continue
+		}
+		hasDeploymentSA, hasOtherSubject := splitSubjects(binding.Subjects, deploymentSANames)
+		switch roleRef.Kind {
+		case roleKind:
+			boundRoleNames[roleRef.Name] = struct{}{}
+			if hasDeploymentSA {
+				inRoleNames[roleRef.Name] = struct{}{}
+			}
+			if hasOtherSubject {
+				outRoleNames[roleRef.Name] = struct{}{}
+			}
+		case clusterRoleKind:
+			// RoleBindings can grant a ClusterRole's rules within a namespace.
+			if hasDeploymentSA {
+				inClusterRoleNames[roleRef.Name] = struct{}{}
+			}
+		default:
+			continue
+		}
+		if hasOtherSubject {
+			outBindings = append(outBindings, binding)
+		}
 	}
 
-	// Check for unbound roles.
-	for roleName, role := range roleMap {
-		hasRef := false
-		for _, roleBinding := range roleBindingMap {
-			roleRef := roleBinding.RoleRef
-			if roleRef.Kind == "Role" && (roleRef.APIGroup == "" || roleRef.APIGroup == rbacv1.SchemeGroupVersion.Group) {
-				if roleRef.Name == roleName {
-					hasRef = true
-					break
-				}
-			}
+	for i := range c.Roles {
+		role := &c.Roles[i]
+		if _, isBound := boundRoleNames[role.GetName()]; !isBound {
+			unused = append(unused, role)
+			continue
 		}
-		if !hasRef {
+		if _, isIn := inRoleNames[role.GetName()]; isIn {
+			in = append(in, role)
+		}
+		if _, isOut := outRoleNames[role.GetName()]; isOut {
 			out = append(out, role)
-			delete(roleMap, roleName)
 		}
 	}
-
-	// If a role is bound and:
-	// 1. the binding only has one subject and it is a service account that maps to a deployment service account,
-	//    add the role to in.
-	// 2. the binding only has one subject and it does not map to a deployment service account or is not a service account,
-	//    add both role and binding to out.
-	// 3. the binding has more than one subject and:
-	//    a. one of those subjects is a deployment's service account, add both role and binding to out and role to in.
-	//    b. none of those subjects is a service account or maps to a deployment's service account, add both role and binding to out.
-	deploymentSANames := make(map[string]struct{})
-	for _, dep := range c.Deployments {
-		saName := dep.Spec.Template.Spec.ServiceAccountName
-		if saName == "" {
-			saName = defaultServiceAccountName
+	for i := range c.ClusterRoles {
+		if _, isIn := inClusterRoleNames[c.ClusterRoles[i].GetName()]; isIn {
+			in = append(in, &c.ClusterRoles[i])
 		}
-		deploymentSANames[saName] = struct{}{}
 	}
+	out = append(out, outBindings...)
+
+	return in, out, unused
+}
 
+// SplitCSVClusterPermissionsObjects splits cluster roles that should be written to a CSV as clusterPermissions (in)
+// from cluster roles and cluster role bindings that should be written directly to the bundle (out).
+//
+// A ClusterRole is in if a ClusterRoleBinding binds it to a Deployment's service account. A ClusterRole and
+// its ClusterRoleBinding are out if that binding has any other subject; a ClusterRole is also out if it is bound
+// by an out RoleBinding, or aggregated into an out ClusterRole. ClusterRoles that are not bound or aggregated
+// into a bound ClusterRole are neither, see UnusedRoles.
+func (c *Manifests) SplitCSVClusterPermissionsObjects() (in, out []controllerutil.Object) {
+	in, out, _ = c.splitCSVClusterPermissionsObjects()
+	return in, out
+}
+
+func (c *Manifests) splitCSVClusterPermissionsObjects() (in, out, unused []controllerutil.Object) {
+	deploymentSANames := c.getDeploymentServiceAccountNames()
+
+	usedRoleNames := make(map[string]struct{})
 	inRoleNames := make(map[string]struct{})
 	outRoleNames := make(map[string]struct{})
-	outRoleBindingNames := make(map[string]struct{})
+	var outBindings []controllerutil.Object
+	for i := range c.ClusterRoleBindings {
+		binding := &c.ClusterRoleBindings[i]
+		roleRef := binding.RoleRef
+		if !isRBACRoleRef(roleRef) || roleRef.Kind != clusterRoleKind {
+			continue
+		}
+		usedRoleNames[roleRef.Name] = struct{}{}
+		hasDeploymentSA, hasOtherSubject := splitSubjects(binding.Subjects, deploymentSANames)
+		if hasDeploymentSA {
+			inRoleNames[roleRef.Name] = struct{}{}
+		}
+		if hasOtherSubject {
+			outRoleNames[roleRef.Name] = struct{}{}
+			outBindings = append(outBindings, binding)
+		}
+	}
 	for _, binding := range c.RoleBindings {
 		roleRef := binding.RoleRef
-		if roleRef.Kind == "Role" && (roleRef.APIGroup == "" || roleRef.APIGroup == rbacv1.SchemeGroupVersion.Group) {
-			numSubjects := len(binding.Subjects)
-			if numSubjects == 1 {
-				// cases (1) and (2).
-				if _, hasSA := deploymentSANames[binding.Subjects[0].Name]; hasSA && binding.Subjects[0].Kind == serviceAccountKind {
-					inRoleNames[roleRef.Name] = struct{}{}
-				} else {
-					outRoleNames[roleRef.Name] = struct{}{}
-					outRoleBindingNames[binding.GetName()] = struct{}{}
-				}
-			} else {
-				// case (3).
-				for _, subject := range binding.Subjects {
-					if _, hasSA := deploymentSANames[subject.Name]; hasSA && subject.Kind == serviceAccountKind {
-						// case (3a).
-						inRoleNames[roleRef.Name] = struct{}{}
-					}
-				}
-				// case (3b).
-				outRoleNames[roleRef.Name] = struct{}{}
-				outRoleBindingNames[binding.GetName()] = struct{}{}
+		if !isRBACRoleRef(roleRef) || roleRef.Kind != clusterRoleKind {
+			continue
+		}
+		usedRoleNames[roleRef.Name] = struct{}{}
+		// The binding itself is written by SplitCSVPermissionsObjects.
+		if _, hasOtherSubject := splitSubjects(binding.Subjects, deploymentSANames); hasOtherSubject {
+			outRoleNames[roleRef.Name] = struct{}{}
+		}
+	}
+
+	// Rules of aggregated ClusterRoles are resolved into those of the ClusterRoles they are aggregated into,
+	// so they are only needed in the bundle if an aggregating ClusterRole is written to the bundle.
+	for i := range c.ClusterRoles {
+		role := &c.ClusterRoles[i]
+		if _, isUsed := usedRoleNames[role.GetName()]; !isUsed {
+			continue
+		}
+		_, isOut := outRoleNames[role.GetName()]
+		for _, aggregated := range c.getAggregatedClusterRoles(role) {
+			usedRoleNames[aggregated.GetName()] = struct{}{}
+			if isOut {
+				outRoleNames[aggregated.GetName()] = struct{}{}
 			}
 		}
 	}
 
-	for roleName := range inRoleNames {
-		if role, hasRoleName := roleMap[roleName]; hasRoleName {
+	for i := range c.ClusterRoles {
+		role := &c.ClusterRoles[i]
+		if _, isUsed := usedRoleNames[role.GetName()]; !isUsed {
+			unused = append(unused, role)
+			continue
+		}
+		if _, isIn := inRoleNames[role.GetName()]; isIn {
 			in = append(in, role)
 		}
-	}
-	for roleName := range outRoleNames {
-		if role, hasRoleName := roleMap[roleName]; hasRoleName {
+		if _, isOut := outRoleNames[role.GetName()]; isOut {
 			out = append(out, role)
 		}
 	}
-	for roleBindingName := range outRoleBindingNames {
-		if roleBinding, hasRoleBindingName := roleBindingMap[roleBindingName]; hasRoleBindingName {
-			out = append(out, roleBinding)
-		}
-	}
+	out = append(out, outBindings...)
 
-	return in, out
+	return in, out, unused
 }
 
-// SplitCSVClusterPermissionsObjects splits cluster roles that should be written to a CSV as clusterPermissions (in)
-// from cluster roles and cluster role bindings that should be written directly to the bundle (out).
-func (c *Manifests) SplitCSVClusterPermissionsObjects() (in, out []controllerutil.Object) { //nolint:dupl
-	roleMap := make(map[string]*rbacv1.ClusterRole)
-	for i := range c.ClusterRoles {
-		roleMap[c.ClusterRoles[i].GetName()] = &c.ClusterRoles[i]
-	}
-	roleBindingMap := make(map[string]*rbacv1.ClusterRoleBinding)
-	for i := range c.ClusterRoleBindings {
-		roleBindingMap[c.ClusterRoleBindings[i].GetName()] = &c.ClusterRoleBindings[i]
+// UnusedRoles returns Roles and ClusterRoles that are not bound to any subject, directly or by aggregation
+// into a bound ClusterRole. These are written neither to a CSV nor directly to the bundle.
+func (c *Manifests) UnusedRoles() (unused []controllerutil.Object) {
+	_, _, unusedRoles := c.splitCSVPermissionsObjects()
+	_, _, unusedClusterRoles := c.splitCSVClusterPermissionsObjects()
+	return append(unusedRoles, unusedClusterRoles...)
+}
+
+// GetClusterRoleRules returns role's rules and the rules of all ClusterRoles aggregated into role.
+func (c *Manifests) GetClusterRoleRules(role *rbacv1.ClusterRole) []rbacv1.PolicyRule {
+	rules := append([]rbacv1.PolicyRule{}, role.Rules...)
+	for _, aggregated := range c.getAggregatedClusterRoles(role) {
+		rules = append(rules, aggregated.Rules...)
 	}
+	return rules
+}
 
-	// Check for unbound roles.
-	for roleName, role := range roleMap {
-		hasRef := false
-		for _, roleBinding := range roleBindingMap {
-			roleRef := roleBinding.RoleRef
-			if roleRef.Kind == "ClusterRole" && (roleRef.APIGroup == "" || roleRef.APIGroup == rbacv1.SchemeGroupVersion.Group) {
-				if roleRef.Name == roleName {
-					hasRef = true
-					break
+// getAggregatedClusterRoles returns the ClusterRoles selected by role's aggregation rule,
+// and by the aggregation rules of those ClusterRoles.
+func (c *Manifests) getAggregatedClusterRoles(role *rbacv1.ClusterRole) (aggregated []*rbacv1.ClusterRole) {
+	seen := map[string]struct{}{role.GetName(): {}}
+	for queue := []*rbacv1.ClusterRole{role}; len(queue) != 0; queue = queue[1:] {
+		rule := queue[0].AggregationRule
+		if rule == nil {
+			continue
+		}
+		for j := range rule.ClusterRoleSelectors {
+			selector, err := metav1.LabelSelectorAsSelector(&rule.ClusterRoleSelectors[j])
+			if err != nil {
+				log.Warnf("Skipping invalid aggregation rule selector in ClusterRole %q: %v", queue[0].GetName(), err)
+				continue
+			}
+			for i := range c.ClusterRoles {
+				candidate := &c.ClusterRoles[i]
+				if _, hasSeen := seen[candidate.GetName()]; hasSeen || !selector.Matches(labels.Set(candidate.GetLabels())) {
+					continue
 				}
+				seen[candidate.GetName()] = struct{}{}
+				aggregated = append(aggregated, candidate)
+				queue = append(queue, candidate)
 			}
 		}
-		if !hasRef {
-			out = append(out, role)
-			delete(roleMap, roleName)
-		}
 	}
+	return aggregated
+}
 
-	// If a role is bound and:
-	// 1. the binding only has one subject and it is a service account that maps to a deployment service account,
-	//    add the role to in.
-	// 2. the binding only has one subject and it does not map to a deployment service account or is not a service account,
-	//    add both role and binding to out.
-	// 3. the binding has more than one subject and:
-	//    a. one of those subjects is a deployment's service account, add both role and binding to out and role to in.
-	//    b. none of those subjects is a service account or maps to a deployment's service account, add both role and binding to out.
+// getDeploymentServiceAccountNames returns the set of service account names used by Deployments in c.
+func (c *Manifests) getDeploymentServiceAccountNames() map[string]struct{} {
 	deploymentSANames := make(map[string]struct{})
 	for _, dep := range c.Deployments {
 		saName := dep.Spec.Template.Spec.ServiceAccountName
@@ -171,52 +240,23 @@ func (c *Manifests) SplitCSVClusterPermissionsObjects() (in, out []controlleruti
 		}
 		deploymentSANames[saName] = struct{}{}
 	}
+	return deploymentSANames
+}
 
-	inRoleNames := make(map[string]struct{})
-	outRoleNames := make(map[string]struct{})
-	outRoleBindingNames := make(map[string]struct{})
-	for _, binding := range c.ClusterRoleBindings {
-		roleRef := binding.RoleRef
-		if roleRef.Kind == "ClusterRole" && (roleRef.APIGroup == "" || roleRef.APIGroup == rbacv1.SchemeGroupVersion.Group) {
-			numSubjects := len(binding.Subjects)
-			if numSubjects == 1 {
-				// cases (1) and (2).
-				if _, hasSA := deploymentSANames[binding.Subjects[0].Name]; hasSA && binding.Subjects[0].Kind == serviceAccountKind {
-					inRoleNames[roleRef.Name] = struct{}{}
-				} else {
-					outRoleNames[roleRef.Name] = struct{}{}
-					outRoleBindingNames[binding.GetName()] = struct{}{}
-				}
-			} else {
-				// case (3).
-				for _, subject := range binding.Subjects {
-					if _, hasSA := deploymentSANames[subject.Name]; hasSA && subject.Kind == serviceAccountKind {
-						// case (3a).
-						inRoleNames[roleRef.Name] = struct{}{}
-					}
-				}
-				// case (3b).
-				outRoleNames[roleRef.Name] = struct{}{}
-				outRoleBindingNames[binding.GetName()] = struct{}{}
-			}
-		}
-	}
+// isRBACRoleRef returns true if roleRef refers to an rbac.authorization.k8s.io role.
+func isRBACRoleRef(roleRef rbacv1.RoleRef) bool {
+	return roleRef.APIGroup == "" || roleRef.APIGroup == rbacv1.SchemeGroupVersion.Group
+}
 
-	for roleName := range inRoleNames {
-		if role, hasRoleName := roleMap[roleName]; hasRoleName {
-			in = append(in, role)
+// splitSubjects returns whether subjects contain a service account in deploymentSANames,
+// and whether they contain any other subject.
+func splitSubjects(subjects []rbacv1.Subject, deploymentSANames map[string]struct{}) (hasDeploymentSA, hasOther bool) {
+	for _, subject := range subjects {
+		if _, hasSA := deploymentSANames[subject.Name]; hasSA && subject.Kind == serviceAccountKind {
+			hasDeploymentSA = true
+		} else {
+			hasOther = true
 		}
 	}
-	for roleName := range outRoleNames {
-		if role, hasRoleName := roleMap[roleName]; hasRoleName {
-			out = append(out, role)
-		}
-	}
-	for roleBindingName := range outRoleBindingNames {
-		if roleBinding, hasRoleBindingName := roleBindingMap[roleBindingName]; hasRoleBindingName {
-			out = append(out, roleBinding)
-		}
-	}
-
-	return in, out
+	return hasDeploymentSA, hasOther
 }
@@ -20,6 +20,7 @@ import (
 	. "github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
@@ -42,12 +43,12 @@ var _ = Describe("ClusterServiceVersion", func() {
 			Expect(out).To(HaveLen(0))
 		})
 		It("should return non-empty lists", func() {
-			By("splitting 1 Role no RoleBinding")
+			By("dropping 1 Role no RoleBinding")
 			c.Roles = []rbacv1.Role{newRole("my-role")}
 			in, out = c.SplitCSVPermissionsObjects()
 			Expect(in).To(HaveLen(0))
-			Expect(out).To(HaveLen(1))
-			Expect(getRoleNames(out)).To(ContainElement("my-role"))
+			Expect(out).To(HaveLen(0))
+			Expect(getRoleNames(c.UnusedRoles())).To(ConsistOf("my-role"))
 
 			By("splitting 1 Role 1 RoleBinding with 1 Subject not containing Deployment serviceAccountName")
 			c.Deployments = []appsv1.Deployment{newDeploymentWithServiceAccount("my-dep-account")}
@@ -144,12 +145,12 @@ var _ = Describe("ClusterServiceVersion", func() {
 			Expect(out).To(HaveLen(0))
 		})
 		It("should return non-empty lists", func() {
-			By("splitting 1 ClusterRole no ClusterRoleBinding")
+			By("dropping 1 ClusterRole no ClusterRoleBinding")
 			c.ClusterRoles = []rbacv1.ClusterRole{newClusterRole("my-role")}
 			in, out = c.SplitCSVClusterPermissionsObjects()
 			Expect(in).To(HaveLen(0))
-			Expect(out).To(HaveLen(1))
-			Expect(getClusterRoleNames(out)).To(ContainElement("my-role"))
+			Expect(out).To(HaveLen(0))
+			Expect(getClusterRoleNames(c.UnusedRoles())).To(ConsistOf("my-role"))
 
 			By("splitting 1 ClusterRole 1 ClusterRoleBinding with 1 Subject not containing Deployment serviceAccountName")
 			c.Deployments = []appsv1.Deployment{newDeploymentWithServiceAccount("my-dep-account")}
@@ -240,6 +241,64 @@ var _ = Describe("ClusterServiceVersion", func() {
 			Expect(getClusterRoleBindingNames(out)).To(ContainElement("my-role-binding-1"))
 			Expect(getClusterRoleBindingNames(out)).To(ContainElement("my-role-binding-2"))
 		})
+		It("should split ClusterRoles bound by RoleBindings", func() {
+			c.Deployments = []appsv1.Deployment{newDeploymentWithServiceAccount("my-dep-account")}
+			c.ClusterRoles = []rbacv1.ClusterRole{newClusterRole("my-role-1"), newClusterRole("my-role-2")}
+			c.RoleBindings = []rbacv1.RoleBinding{
+				newRoleBinding("my-role-binding-1", newClusterRoleRef("my-role-1"), newServiceAccountSubject("my-dep-account")),
+				newRoleBinding("my-role-binding-2", newClusterRoleRef("my-role-2"), newServiceAccountSubject("my-other-account")),
+			}
+
+			By("adding the deployment's ClusterRole to permissions")
+			in, out = c.SplitCSVPermissionsObjects()
+			Expect(getClusterRoleNames(in)).To(ConsistOf("my-role-1"))
+			Expect(getRoleBindingNames(out)).To(ConsistOf("my-role-binding-2"))
+
+			By("writing ClusterRoles of bindings written to the bundle")
+			in, out = c.SplitCSVClusterPermissionsObjects()
+			Expect(in).To(HaveLen(0))
+			Expect(getClusterRoleNames(out)).To(ConsistOf("my-role-2"))
+			Expect(c.UnusedRoles()).To(HaveLen(0))
+		})
+		It("should split aggregated ClusterRoles", func() {
+			c.Deployments = []appsv1.Deployment{newDeploymentWithServiceAccount("my-dep-account")}
+			c.ClusterRoles = []rbacv1.ClusterRole{
+				newAggregatingClusterRole("my-role", "aggregate-to-my-role"),
+				newAggregatingClusterRole("my-other-role", "aggregate-to-my-other-role"),
+				newLabeledClusterRole("my-aggregated-role", "aggregate-to-my-role"),
+				newLabeledClusterRole("my-other-aggregated-role", "aggregate-to-my-other-role"),
+				newClusterRole("my-unused-role"),
+			}
+			c.ClusterRoleBindings = []rbacv1.ClusterRoleBinding{
+				newClusterRoleBinding("my-role-binding", newClusterRoleRef("my-role"), newServiceAccountSubject("my-dep-account")),
+				newClusterRoleBinding("my-other-role-binding",
+					newClusterRoleRef("my-other-role"), newServiceAccountSubject("my-other-account")),
+			}
+			in, out = c.SplitCSVClusterPermissionsObjects()
+			Expect(getClusterRoleNames(in)).To(ConsistOf("my-role"))
+			Expect(getClusterRoleNames(out)).To(ConsistOf("my-other-role", "my-other-aggregated-role"))
+			Expect(getClusterRoleBindingNames(out)).To(ConsistOf("my-other-role-binding"))
+			Expect(getClusterRoleNames(c.UnusedRoles())).To(ConsistOf("my-unused-role"))
+		})
+	})
+
+	Describe("GetClusterRoleRules", func() {
+		It("should return rules of transitively aggregated ClusterRoles", func() {
+			role := newAggregatingClusterRole("my-role", "aggregate-to-my-role")
+			role.Rules = []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}}
+			aggregated := newLabeledClusterRole("my-aggregated-role", "aggregate-to-my-role")
+			aggregated.AggregationRule = newAggregatingClusterRole("", "aggregate-to-my-aggregated-role").AggregationRule
+			aggregated.Rules = []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}}
+			nested := newLabeledClusterRole("my-nested-role", "aggregate-to-my-aggregated-role")
+			nested.Rules = []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}}
+			c.ClusterRoles = []rbacv1.ClusterRole{role, aggregated, nested, newClusterRole("my-other-role")}
+
+			Expect(c.GetClusterRoleRules(&c.ClusterRoles[0])).To(Equal([]rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+			}))
+		})
 	})
 
 })
@@ -286,6 +345,20 @@ func newClusterRole(name string) (r rbacv1.ClusterRole) {
 	return r
 }
 
+func newLabeledClusterRole(name, label string) (r rbacv1.ClusterRole) {
+	r = newClusterRole(name)
+	r.SetLabels(map[string]string{label: "true"})
+	return r
+}
+
+func newAggregatingClusterRole(name, label string) (r rbacv1.ClusterRole) {
+	r = newClusterRole(name)
+	r.AggregationRule = &rbacv1.AggregationRule{
+		ClusterRoleSelectors: []metav1.LabelSelector{{MatchLabels: map[string]string{label: "true"}}},
+	}
+	return r
+}
+
 func newRoleBinding(name string, ref rbacv1.RoleRef, subjects ...rbacv1.Subject) (r rbacv1.RoleBinding) {
 	r.SetGroupVersionKind(rbacv1.SchemeGroupVersion.WithKind("RoleBinding"))
 	r.SetName(name)
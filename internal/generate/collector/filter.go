@@ -22,7 +22,6 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
-	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
@@ -35,12 +34,7 @@ func (c *Manifests) filter() {
 // filterCustomResources filters "other" objects, which contain likely
 // Custom Resources corresponding to a CustomResourceDefinition, by GVK.
 func (c *Manifests) filterCustomResources() {
-	crdGVKSet := make(map[schema.GroupVersionKind]struct{})
-	v1crdGVKs := k8sutil.GVKsForV1CustomResourceDefinitions(c.V1CustomResourceDefinitions...)
-	v1beta1crdGVKs := k8sutil.GVKsForV1beta1CustomResourceDefinitions(c.V1beta1CustomResourceDefinitions...)
-	for _, gvk := range append(v1crdGVKs, v1beta1crdGVKs...) {
-		crdGVKSet[gvk] = struct{}{}
-	}
+	crdGVKSet := c.getCRDGVKSet()
 
 	customResources := []unstructured.Unstructured{}
 	for _, other := range c.Others {
@@ -51,6 +45,15 @@ func (c *Manifests) filterCustomResources() {
 	c.CustomResources = customResources
 }
 
+// getCRDGVKSet returns the set of GVKs defined by collected CRDs.
+func (c *Manifests) getCRDGVKSet() map[schema.GroupVersionKind]struct{} {
+	crdGVKSet := make(map[schema.GroupVersionKind]struct{})
+	for _, gvk := range k8sutil.GVKsForV1CustomResourceDefinitions(c.V1CustomResourceDefinitions...) {
+		crdGVKSet[gvk] = struct{}{}
+	}
+	return crdGVKSet
+}
+
 // deduplicate removes duplicate objects from the collection, since we are
 // collecting an arbitrary list of manifests.
 func (c *Manifests) deduplicate() error {
@@ -104,18 +107,6 @@ func (c *Manifests) deduplicate() error {
 	}
 	c.V1CustomResourceDefinitions = v1crds
 
-	v1beta1crds := []apiextv1beta1.CustomResourceDefinition{}
-	for _, crd := range c.V1beta1CustomResourceDefinitions {
-		hasHash, err := addToHashes(&crd, hashes)
-		if err != nil {
-			return err
-		}
-		if !hasHash {
-			v1beta1crds = append(v1beta1crds, crd)
-		}
-	}
-	c.V1beta1CustomResourceDefinitions = v1beta1crds
-
 	validatingWebhooks := []admissionregv1.ValidatingWebhook{}
 	for _, webhook := range c.ValidatingWebhooks {
 		hasHash, err := addToHashes(&webhook, hashes)
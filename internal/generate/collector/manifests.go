@@ -32,28 +32,35 @@ import (
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/yaml"
 
 	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
 )
 
 // Manifests holds a collector of all manifests relevant to CSV updates.
+// CustomResourceDefinitions collected as apiextensions.k8s.io/v1beta1 are
+// converted to apiextensions.k8s.io/v1.
 type Manifests struct {
-	Roles                            []rbacv1.Role
-	ClusterRoles                     []rbacv1.ClusterRole
-	RoleBindings                     []rbacv1.RoleBinding
-	ClusterRoleBindings              []rbacv1.ClusterRoleBinding
-	Deployments                      []appsv1.Deployment
-	ServiceAccounts                  []corev1.ServiceAccount
-	Services                         []corev1.Service
-	V1CustomResourceDefinitions      []apiextv1.CustomResourceDefinition
-	V1beta1CustomResourceDefinitions []apiextv1beta1.CustomResourceDefinition
-	ValidatingWebhooks               []admissionregv1.ValidatingWebhook
-	MutatingWebhooks                 []admissionregv1.MutatingWebhook
-	CustomResources                  []unstructured.Unstructured
-	ScorecardConfig                  scorecardv1alpha3.Configuration
+	Roles                       []rbacv1.Role
+	ClusterRoles                []rbacv1.ClusterRole
+	RoleBindings                []rbacv1.RoleBinding
+	ClusterRoleBindings         []rbacv1.ClusterRoleBinding
+	Deployments                 []appsv1.Deployment
+	ServiceAccounts             []corev1.ServiceAccount
+	Services                    []corev1.Service
+	V1CustomResourceDefinitions []apiextv1.CustomResourceDefinition
+	ValidatingWebhooks          []admissionregv1.ValidatingWebhook
+	MutatingWebhooks            []admissionregv1.MutatingWebhook
+	CustomResources             []unstructured.Unstructured
+	ScorecardConfig             scorecardv1alpha3.Configuration
 
 	Others []unstructured.Unstructured
+
+	// crdObjects maps CRD names to their collected v1 manifests, which may contain
+	// fields the apiextv1 types in V1CustomResourceDefinitions do not.
+	crdObjects map[string]unstructured.Unstructured
 }
 
 var (
@@ -133,8 +140,7 @@ func (c *Manifests) UpdateFromDirs(deployDir, crdsDir string) error {
 
 	// Add CRDs from input.
 	if isDirExist(crdsDir) {
-		c.V1CustomResourceDefinitions, c.V1beta1CustomResourceDefinitions, err = k8sutil.GetCustomResourceDefinitions(crdsDir)
-		if err != nil {
+		if err := c.addCustomResourceDefinitionsFromDir(crdsDir); err != nil {
 			return fmt.Errorf("error adding CustomResourceDefinitions to manifest collector: %v", err)
 		}
 	}
@@ -297,9 +303,60 @@ func (c *Manifests) addDeployments(rawManifests ...[]byte) error {
 	return nil
 }
 
+// addCustomResourceDefinitionsFromDir adds CustomResourceDefinitions in all
+// files in crdsDir, excluding subdirectories, to the collector.
+func (c *Manifests) addCustomResourceDefinitionsFromDir(crdsDir string) error {
+	infos, err := ioutil.ReadDir(crdsDir)
+	if err != nil {
+		return err
+	}
+
+	numExisting := len(c.V1CustomResourceDefinitions)
+	for _, info := range infos {
+		path := filepath.Join(crdsDir, info.Name())
+		if info.IsDir() {
+			log.Debugf("Skipping dir: %s", path)
+			continue
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading manifest %s: %v", path, err)
+		}
+		scanner := k8sutil.NewYAMLScanner(bytes.NewBuffer(b))
+		for scanner.Scan() {
+			manifest := scanner.Bytes()
+			typeMeta, err := k8sutil.GetTypeMetaFromBytes(manifest)
+			if err != nil {
+				log.Debugf("Skipping manifest in %s: %v", path, err)
+				continue
+			}
+			if typeMeta.Kind != "CustomResourceDefinition" {
+				continue
+			}
+			if err := c.addCustomResourceDefinitions(typeMeta.GroupVersionKind().Version, manifest); err != nil {
+				return fmt.Errorf("error adding manifest in %s: %v", path, err)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("error scanning %s: %v", path, err)
+		}
+	}
+
+	// CRDs in crdsDir cannot define the same custom resource GVK.
+	crGVKSet := map[schema.GroupVersionKind]struct{}{}
+	for _, gvk := range k8sutil.GVKsForV1CustomResourceDefinitions(c.V1CustomResourceDefinitions[numExisting:]...) {
+		if _, hasGVK := crGVKSet[gvk]; hasGVK {
+			return fmt.Errorf("duplicate custom resource GVK %s in %s", gvk, crdsDir)
+		}
+		crGVKSet[gvk] = struct{}{}
+	}
+	return nil
+}
+
 // addCustomResourceDefinitions assumes all manifest data in rawManifests are
-// CustomResourceDefinitions and adds them to the collector. version determines
-// which CustomResourceDefinition type is used for all manifests in rawManifests.
+// CustomResourceDefinitions of API version version and adds them to the collector.
+// apiextensions.k8s.io/v1beta1 CRDs are converted to apiextensions.k8s.io/v1.
 func (c *Manifests) addCustomResourceDefinitions(version string, rawManifests ...[]byte) (err error) {
 	for _, rawManifest := range rawManifests {
 		switch version {
@@ -308,13 +365,29 @@ func (c *Manifests) addCustomResourceDefinitions(version string, rawManifests ..
 			if err := yaml.Unmarshal(rawManifest, &crd); err != nil {
 				return err
 			}
+			obj := unstructured.Unstructured{}
+			if err := yaml.Unmarshal(rawManifest, &obj); err != nil {
+				return err
+			}
+			if c.crdObjects == nil {
+				c.crdObjects = make(map[string]unstructured.Unstructured)
+			}
+			c.crdObjects[crd.GetName()] = obj
 			c.V1CustomResourceDefinitions = append(c.V1CustomResourceDefinitions, crd)
 		case apiextv1beta1.SchemeGroupVersion.Version:
-			crd := apiextv1beta1.CustomResourceDefinition{}
-			if err := yaml.Unmarshal(rawManifest, &crd); err != nil {
+			v1beta1CRD := apiextv1beta1.CustomResourceDefinition{}
+			if err := yaml.Unmarshal(rawManifest, &v1beta1CRD); err != nil {
 				return err
 			}
-			c.V1beta1CustomResourceDefinitions = append(c.V1beta1CustomResourceDefinitions, crd)
+			log.Warnf("CustomResourceDefinition %q has deprecated API version %s, converting it to %s",
+				v1beta1CRD.GetName(), apiextv1beta1.SchemeGroupVersion, apiextv1.SchemeGroupVersion)
+			crd, err := k8sutil.Convertv1beta1Tov1CustomResourceDefinition(&v1beta1CRD)
+			if err != nil {
+				return fmt.Errorf("error converting CustomResourceDefinition %q: %v", v1beta1CRD.GetName(), err)
+			}
+			// A converted CRD may have been collected before as a v1 manifest.
+			delete(c.crdObjects, crd.GetName())
+			c.V1CustomResourceDefinitions = append(c.V1CustomResourceDefinitions, *crd)
 		default:
 			return fmt.Errorf("unrecognized CustomResourceDefinition version %q", version)
 		}
@@ -322,6 +395,29 @@ func (c *Manifests) addCustomResourceDefinitions(version string, rawManifests ..
 	return nil
 }
 
+// CustomResourceDefinitionObjects returns each CRD in V1CustomResourceDefinitions
+// as it was collected, including fields that apiextv1 types do not contain,
+// ex. newer schema extensions. CRDs converted from apiextensions.k8s.io/v1beta1
+// or not collected from a manifest are returned as their typed equivalent.
+func (c Manifests) CustomResourceDefinitionObjects() ([]unstructured.Unstructured, error) {
+	objs := make([]unstructured.Unstructured, 0, len(c.V1CustomResourceDefinitions))
+	for i := range c.V1CustomResourceDefinitions {
+		crd := &c.V1CustomResourceDefinitions[i]
+		if obj, hasObj := c.crdObjects[crd.GetName()]; hasObj {
+			objs = append(objs, *obj.DeepCopy())
+			continue
+		}
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(crd)
+		if err != nil {
+			return nil, fmt.Errorf("error converting CustomResourceDefinition %q: %v", crd.GetName(), err)
+		}
+		obj := unstructured.Unstructured{Object: u}
+		obj.SetGroupVersionKind(apiextv1.SchemeGroupVersion.WithKind("CustomResourceDefinition"))
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
 // addValidatingWebhookConfigurations assumes all manifest data in rawManifests
 // are ValidatingWebhookConfigurations and adds their webhooks to the collector.
 func (c *Manifests) addValidatingWebhookConfigurations(rawManifests ...[]byte) error {
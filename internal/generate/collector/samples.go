@@ -0,0 +1,132 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/plugins/util/kustomize"
+	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
+)
+
+// UpdateFromSamples adds Custom Resources in files referenced by the
+// kustomization.yaml in samplesDir, ex. config/samples, to CustomResources.
+// Directories referenced by the kustomization are read the same way. Samples
+// are only added if they are an instance of a collected CRD, and if no Custom
+// Resource of the same kind and name was already collected. If samplesDir has
+// no kustomization.yaml, UpdateFromSamples is a no-op.
+func (c *Manifests) UpdateFromSamples(samplesDir string) error {
+	samples, err := readSamples(samplesDir)
+	if err != nil {
+		return err
+	}
+
+	crdGVKs := c.getCRDGVKSet()
+	type sampleKey struct {
+		gvk             schema.GroupVersionKind
+		namespace, name string
+	}
+	existing := map[sampleKey]struct{}{}
+	for _, cr := range c.CustomResources {
+		existing[sampleKey{cr.GroupVersionKind(), cr.GetNamespace(), cr.GetName()}] = struct{}{}
+	}
+	for _, sample := range samples {
+		gvk := sample.GroupVersionKind()
+		if _, isCR := crdGVKs[gvk]; !isCR {
+			log.Warnf("Sample %s %q is not an instance of any collected CustomResourceDefinition, skipping", gvk, sample.GetName())
+			continue
+		}
+		key := sampleKey{gvk, sample.GetNamespace(), sample.GetName()}
+		if _, ok := existing[key]; ok {
+			continue
+		}
+		existing[key] = struct{}{}
+		c.CustomResources = append(c.CustomResources, sample)
+	}
+	return nil
+}
+
+// readSamples returns the objects in files referenced by the kustomization in dir.
+func readSamples(dir string) (samples []unstructured.Unstructured, err error) {
+	kustomizationPath := filepath.Join(dir, kustomize.File)
+	b, err := ioutil.ReadFile(kustomizationPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Debugf("No %s found, skipping samples", kustomizationPath)
+			return nil, nil
+		}
+		return nil, err
+	}
+	kustomization := struct {
+		Resources []string `json:"resources"`
+	}{}
+	if err := yaml.Unmarshal(b, &kustomization); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", kustomizationPath, err)
+	}
+
+	for _, resource := range kustomization.Resources {
+		path := filepath.Join(dir, resource)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading sample %s: %v", path, err)
+		}
+		var objs []unstructured.Unstructured
+		if info.IsDir() {
+			objs, err = readSamples(path)
+		} else {
+			objs, err = readSampleFile(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, objs...)
+	}
+	return samples, nil
+}
+
+// readSampleFile returns the objects in the YAML file at path.
+func readSampleFile(path string) (objs []unstructured.Unstructured, err error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading sample %s: %v", path, err)
+	}
+	scanner := k8sutil.NewYAMLScanner(bytes.NewBuffer(b))
+	for scanner.Scan() {
+		u := unstructured.Unstructured{}
+		if err := yaml.Unmarshal(scanner.Bytes(), &u.Object); err != nil {
+			return nil, fmt.Errorf("error parsing sample %s: %v", path, err)
+		}
+		if len(u.Object) == 0 {
+			continue
+		}
+		if u.GetKind() == "" || u.GetAPIVersion() == "" {
+			return nil, fmt.Errorf("error parsing sample %s: object has no apiVersion or kind", path)
+		}
+		objs = append(objs, u)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error parsing sample %s: %v", path, err)
+	}
+	return objs, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("UpdateFromSamples", func() {
+	var (
+		c   *Manifests
+		tmp string
+	)
+
+	writeFile := func(name, content string) {
+		Expect(ioutil.WriteFile(filepath.Join(tmp, name), []byte(content), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmp, err = ioutil.TempDir("", "samples-")
+		Expect(err).NotTo(HaveOccurred())
+
+		crd := apiextv1.CustomResourceDefinition{}
+		crd.SetName("memcacheds.cache.example.com")
+		crd.Spec.Group = "cache.example.com"
+		crd.Spec.Names.Kind = "Memcached"
+		crd.Spec.Versions = []apiextv1.CustomResourceDefinitionVersion{{Name: "v1alpha1"}}
+		c = &Manifests{V1CustomResourceDefinitions: []apiextv1.CustomResourceDefinition{crd}}
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	It("should add samples referenced by the kustomization that are instances of collected CRDs", func() {
+		writeFile("kustomization.yaml", "resources:\n- memcached.yaml\n- other.yaml\n")
+		writeFile("memcached.yaml", "apiVersion: cache.example.com/v1alpha1\nkind: Memcached\nmetadata:\n  name: a\n"+
+			"---\napiVersion: cache.example.com/v1alpha1\nkind: Memcached\nmetadata:\n  name: b\n")
+		writeFile("other.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n")
+		writeFile("unreferenced.yaml", "apiVersion: cache.example.com/v1alpha1\nkind: Memcached\nmetadata:\n  name: d\n")
+
+		existing := unstructured.Unstructured{}
+		existing.SetAPIVersion("cache.example.com/v1alpha1")
+		existing.SetKind("Memcached")
+		existing.SetName("a")
+		existing.SetLabels(map[string]string{"from": "input"})
+		c.CustomResources = []unstructured.Unstructured{existing}
+
+		Expect(c.UpdateFromSamples(tmp)).To(Succeed())
+		Expect(c.CustomResources).To(HaveLen(2))
+		Expect(c.CustomResources[0].GetLabels()).To(HaveKeyWithValue("from", "input"))
+		Expect(c.CustomResources[1].GetName()).To(Equal("b"))
+	})
+	It("should do nothing without a kustomization", func() {
+		Expect(c.UpdateFromSamples(tmp)).To(Succeed())
+		Expect(c.CustomResources).To(BeEmpty())
+	})
+	It("should fail with the file name of invalid samples", func() {
+		writeFile("kustomization.yaml", "resources:\n- memcached.yaml\n")
+		writeFile("memcached.yaml", "apiVersion: cache.example.com/v1alpha1\nkind: Memcached\nmetadata: [\n")
+		err := c.UpdateFromSamples(tmp)
+		Expect(err).To(MatchError(ContainSubstring("error parsing sample " + filepath.Join(tmp, "memcached.yaml"))))
+	})
+	It("should fail if a referenced sample does not exist", func() {
+		writeFile("kustomization.yaml", "resources:\n- missing.yaml\n")
+		Expect(c.UpdateFromSamples(tmp)).To(MatchError(ContainSubstring(filepath.Join(tmp, "missing.yaml"))))
+	})
+})
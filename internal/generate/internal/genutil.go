@@ -55,15 +55,24 @@ func Open(dir, fileName string) (*File, error) {
 	return &File{f}, err
 }
 
-// WriteObject writes a k8s object to w.
+// WriteObject writes a k8s object to w, marshaled by MarshalObject.
 func WriteObject(w io.Writer, obj interface{}) error {
-	b, err := k8sutil.GetObjectBytes(obj, yaml.Marshal)
+	b, err := MarshalObject(obj)
 	if err != nil {
 		return err
 	}
 	return write(w, b)
 }
 
+// MarshalObject is the canonical marshaller of generated k8s objects. obj is
+// converted to its unstructured form without runtime-managed fields, so every
+// mapping, from struct fields to annotation keys, is written with its keys
+// sorted. Output therefore depends only on obj's content, not on
+// struct field order or map iteration order.
+func MarshalObject(obj interface{}) ([]byte, error) {
+	return k8sutil.GetObjectBytes(obj, yaml.Marshal)
+}
+
 // WriteObject writes any object to w.
 func WriteYAML(w io.Writer, obj interface{}) error {
 	b, err := yaml.Marshal(obj)
@@ -19,7 +19,6 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
-	"sort"
 
 	apimanifests "github.com/operator-framework/api/pkg/manifests"
 	"github.com/operator-framework/api/pkg/validation"
@@ -48,14 +47,17 @@ type Generator struct {
 	OperatorName string
 	// Version is the version of the operator being updated.
 	Version string
-	// ChannelName is operator's PackageManifest channel. If a new PackageManifest is generated
-	// or ChannelName is the only channel in the generated PackageManifest,
-	// this channel will be set to the PackageManifest's default.
+	// ChannelName is operator's PackageManifest channel, which is added to an existing PackageManifest
+	// or updated to point at Version. All other existing channels are kept as-is. If the PackageManifest
+	// has no default channel, ex. because it is new, this channel will be set as its default.
 	ChannelName string
 	// IsDefaultChannel determines whether ChannelName should be the default channel in the
 	// generated PackageManifest. If true, ChannelName will be the PackageManifest's default channel.
-	// Setting this field is only necessary when more than one channel exists.
+	// Otherwise an existing default channel is kept.
 	IsDefaultChannel bool
+	// RemoveChannels are names of existing channels to remove from the PackageManifest.
+	// The default channel cannot be removed unless ChannelName becomes the default.
+	RemoveChannels []string
 
 	// Func that returns a base PackageManifest.
 	getBase getBaseFunc
@@ -138,14 +140,16 @@ func (g *Generator) generate() (*apimanifests.PackageManifest, error) {
 	csvName := genutil.MakeCSVName(g.OperatorName, g.Version)
 	if g.ChannelName != "" {
 		setChannels(base, g.ChannelName, csvName)
-		sortChannelsByName(base)
-		if g.IsDefaultChannel || len(base.Channels) == 1 {
+		if g.IsDefaultChannel || base.DefaultChannelName == "" {
 			base.DefaultChannelName = g.ChannelName
 		}
 	} else if len(base.Channels) == 0 {
 		setChannels(base, "alpha", csvName)
 		base.DefaultChannelName = "alpha"
 	}
+	if err = removeChannels(base, g.RemoveChannels); err != nil {
+		return nil, err
+	}
 
 	if err = validatePackageManifest(base); err != nil {
 		return nil, err
@@ -175,11 +179,26 @@ func makePkgManFileName(operatorName string) string {
 	return operatorName + packageManifestFileExt
 }
 
-// sortChannelsByName sorts pkg.Channels by each element's name.
-func sortChannelsByName(pkg *apimanifests.PackageManifest) {
-	sort.Slice(pkg.Channels, func(i int, j int) bool {
-		return pkg.Channels[i].Name < pkg.Channels[j].Name
-	})
+// removeChannels removes the channels named names from pkg. Channels that do not exist
+// and the default channel cannot be removed.
+func removeChannels(pkg *apimanifests.PackageManifest, names []string) error {
+	for _, name := range names {
+		if name == pkg.DefaultChannelName {
+			return fmt.Errorf("cannot remove default channel %q, make another channel the default first", name)
+		}
+		idx := -1
+		for i, channel := range pkg.Channels {
+			if channel.Name == name {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			return fmt.Errorf("cannot remove channel %q, which does not exist", name)
+		}
+		pkg.Channels = append(pkg.Channels[:idx], pkg.Channels[idx+1:]...)
+	}
+	return nil
 }
 
 // validatePackageManifest will validate pkg and log warnings and errors.
@@ -210,7 +229,8 @@ func validatePackageManifest(pkg *apimanifests.PackageManifest) error {
 	return nil
 }
 
-// setChannels checks for duplicate channels in pkg and sets the default channel if possible.
+// setChannels sets the current CSV of pkg's channel named channelName to csvName,
+// appending the channel if it does not exist.
 func setChannels(pkg *apimanifests.PackageManifest, channelName, csvName string) {
 	channelIdx := -1
 	for i, channel := range pkg.Channels {
@@ -240,6 +240,87 @@ var _ = Describe("Generating a PackageManifest", func() {
 			})
 		})
 
+		Context("to update an existing PackageManifest file with several channels", func() {
+			multiChannelDir := filepath.Join(testDataDir, "packagemanifests")
+
+			var existing *apimanifests.PackageManifest
+
+			BeforeEach(func() {
+				b := readFileHelper(filepath.Join(multiChannelDir, makePkgManFileName(operatorName)))
+				existing = &apimanifests.PackageManifest{}
+				Expect(yaml.Unmarshal(b, existing)).To(Succeed())
+				Expect(existing.Channels).To(HaveLen(3))
+
+				g = Generator{
+					OperatorName: operatorName,
+					Version:      "0.3.0",
+				}
+				Expect(WithBase(multiChannelDir)(&g)).To(Succeed())
+			})
+
+			It("should only update the targeted channel", func() {
+				g.ChannelName = "candidate"
+				pkg, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				expected := existing.DeepCopy()
+				expected.Channels[2].CurrentCSVName = genutil.MakeCSVName(operatorName, "0.3.0")
+				Expect(pkg).To(Equal(expected))
+			})
+			It("should add a new channel without changing the default channel", func() {
+				g.ChannelName = "preview"
+				pkg, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				expected := existing.DeepCopy()
+				expected.Channels = append(expected.Channels, apimanifests.PackageChannel{
+					Name: "preview", CurrentCSVName: genutil.MakeCSVName(operatorName, "0.3.0"),
+				})
+				Expect(pkg).To(Equal(expected))
+			})
+			It("should change the default channel only if set explicitly", func() {
+				g.ChannelName = "fast"
+				g.IsDefaultChannel = true
+				pkg, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				expected := existing.DeepCopy()
+				expected.Channels[1].CurrentCSVName = genutil.MakeCSVName(operatorName, "0.3.0")
+				expected.DefaultChannelName = "fast"
+				Expect(pkg).To(Equal(expected))
+			})
+			It("should keep all channels if no channel is set", func() {
+				pkg, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(pkg).To(Equal(existing))
+			})
+			It("should remove channels set to be removed", func() {
+				g.RemoveChannels = []string{"candidate"}
+				pkg, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				expected := existing.DeepCopy()
+				expected.Channels = expected.Channels[:2]
+				Expect(pkg).To(Equal(expected))
+			})
+			It("should remove the default channel only if another channel becomes the default", func() {
+				g.RemoveChannels = []string{"stable"}
+				_, err := g.generate()
+				Expect(err).To(MatchError(ContainSubstring(`cannot remove default channel "stable"`)))
+
+				g.ChannelName = "fast"
+				g.IsDefaultChannel = true
+				pkg, err := g.generate()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(pkg.DefaultChannelName).To(Equal("fast"))
+				Expect(pkg.Channels).To(Equal([]apimanifests.PackageChannel{
+					{Name: "fast", CurrentCSVName: genutil.MakeCSVName(operatorName, "0.3.0")},
+					existing.Channels[2],
+				}))
+			})
+			It("should return an error removing a channel that does not exist", func() {
+				g.RemoveChannels = []string{"beta"}
+				_, err := g.generate()
+				Expect(err).To(MatchError(ContainSubstring(`cannot remove channel "beta"`)))
+			})
+		})
+
 	})
 
 })
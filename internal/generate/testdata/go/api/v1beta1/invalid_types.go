@@ -0,0 +1,46 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InvalidSpec defines the desired state of Invalid
+type InvalidSpec struct {
+	// Descriptor type is misspelled
+	// +operator-sdk:csv:customresourcedefinitions:type=sepc
+	Size int32 `json:"size"`
+	// Descriptor argument is misspelled
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,displayNam="Image"
+	Image string `json:"image"`
+}
+
+// InvalidStatus defines the observed state of Invalid
+type InvalidStatus struct {
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Nodes []string `json:"nodes"`
+}
+
+// Invalid is the Schema for the invalid API
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+type Invalid struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   InvalidSpec   `json:"spec,omitempty"`
+	Status InvalidStatus `json:"status,omitempty"`
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Config configures a frigate. This type has the same name as a type in the cache group.
+type Config struct {
+	// Captain is the name of the frigate's captain
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Captain"
+	Captain string `json:"captain"`
+}
+
+// FrigateSpec defines the desired state of Frigate
+type FrigateSpec struct {
+	// Crew is the number of sailors on the frigate
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Crew int32 `json:"crew"`
+	// Config configures the frigate
+	Config Config `json:"config,omitempty"`
+}
+
+// FrigateStatus defines the observed state of Frigate
+type FrigateStatus struct {
+	// Docked is true if the frigate is in port
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Docked bool `json:"docked"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Frigate is the Schema for the frigates API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=frigates,scope=Namespaced
+// +operator-sdk:csv:customresourcedefinitions:displayName="Frigate"
+type Frigate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FrigateSpec   `json:"spec,omitempty"`
+	Status FrigateStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FrigateList contains a list of Frigate
+type FrigateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Frigate `json:"items"`
+}
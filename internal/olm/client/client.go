@@ -21,6 +21,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -34,9 +35,12 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	deploymentutil "k8s.io/kubectl/pkg/util/deployment"
@@ -56,6 +60,11 @@ func init() {
 
 type Client struct {
 	KubeClient client.Client
+	// Logf, if set, logs each object as YAML before it is created.
+	Logf func(string, ...interface{})
+	// GetPodLogs, if set, returns logs of the Pod with key. It is used to
+	// describe failing pods in rollout errors.
+	GetPodLogs func(ctx context.Context, key types.NamespacedName, opts *corev1.PodLogOptions) ([]byte, error)
 }
 
 func NewClientForConfig(cfg *rest.Config) (*Client, error) {
@@ -72,8 +81,16 @@ func NewClientForConfig(cfg *rest.Config) (*Client, error) {
 		return nil, fmt.Errorf("failed to create client: %v", err)
 	}
 
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %v", err)
+	}
+
 	c := &Client{
 		KubeClient: cl,
+		GetPodLogs: func(ctx context.Context, key types.NamespacedName, opts *corev1.PodLogOptions) ([]byte, error) {
+			return cs.CoreV1().Pods(key.Namespace).GetLogs(key.Name, opts).DoRaw(ctx)
+		},
 	}
 	return c, nil
 }
@@ -86,6 +103,9 @@ func (c Client) DoCreate(ctx context.Context, objs ...runtime.Object) error {
 		}
 		kind := obj.GetObjectKind().GroupVersionKind().Kind
 		log.Infof("  Creating %s %q", kind, getName(a.GetNamespace(), a.GetName()))
+		if c.Logf != nil {
+			LogObject(c.Logf, "Creating", obj)
+		}
 		err = c.KubeClient.Create(ctx, obj)
 		if err != nil {
 			if !apierrors.IsAlreadyExists(err) {
@@ -97,6 +117,98 @@ func (c Client) DoCreate(ctx context.Context, objs ...runtime.Object) error {
 	return nil
 }
 
+// DoApply creates each object in objs, or updates it if it already exists and
+// differs from its desired state. Existing objects whose fields are a superset
+// of the desired object's, ex. because the server defaulted some, are left as-is.
+func (c Client) DoApply(ctx context.Context, objs ...runtime.Object) error {
+	for _, obj := range objs {
+		a, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		name := getName(a.GetNamespace(), a.GetName())
+		log.Infof("  Applying %s %q", gvk.Kind, name)
+		if c.Logf != nil {
+			LogObject(c.Logf, "Applying", obj)
+		}
+		desired, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		err = c.KubeClient.Create(ctx, obj)
+		if err == nil {
+			continue
+		}
+		if !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(gvk)
+		if err := c.KubeClient.Get(ctx, types.NamespacedName{Namespace: a.GetNamespace(), Name: a.GetName()}, existing); err != nil {
+			return err
+		}
+		if isSubset(desired, existing.Object) {
+			log.Infof("    %s %q is up to date", gvk.Kind, name)
+			continue
+		}
+		log.Infof("    Updating outdated %s %q", gvk.Kind, name)
+		a.SetResourceVersion(existing.GetResourceVersion())
+		if err := c.KubeClient.Update(ctx, obj); err != nil {
+			return fmt.Errorf("failed to update %s %q: %v", gvk.Kind, name, err)
+		}
+	}
+	return nil
+}
+
+// isSubset returns true if every field set in desired has the same value in
+// existing. Lists must have the same length, and their elements are compared
+// pairwise.
+func isSubset(desired, existing interface{}) bool {
+	switch d := desired.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		e, ok := existing.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range d {
+			if !isSubset(v, e[k]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		e, ok := existing.([]interface{})
+		if !ok || len(d) != len(e) {
+			return false
+		}
+		for i := range d {
+			if !isSubset(d[i], e[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(normalizeNumber(desired), normalizeNumber(existing))
+	}
+}
+
+// normalizeNumber converts integers to float64 so values decoded from YAML
+// and JSON compare equal.
+func normalizeNumber(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	}
+	return v
+}
+
 func (c Client) DoDelete(ctx context.Context, objs ...runtime.Object) error {
 	for _, obj := range objs {
 		a, err := meta.Accessor(obj)
@@ -139,6 +251,17 @@ func getName(namespace, name string) string {
 }
 
 func (c Client) DoRolloutWait(ctx context.Context, key types.NamespacedName) error {
+	return c.doRolloutWait(ctx, key, nil)
+}
+
+// DoRolloutWaitCheckPods waits for the Deployment with key to roll out like
+// DoRolloutWait, but fails early with a *PodFailedError if one of the
+// Deployment's pods is crash looping or repeatedly failing its readiness probe.
+func (c Client) DoRolloutWaitCheckPods(ctx context.Context, key types.NamespacedName) error {
+	return c.doRolloutWait(ctx, key, newPodChecker(c, key))
+}
+
+func (c Client) doRolloutWait(ctx context.Context, key types.NamespacedName, pc *podChecker) error {
 	onceReplicasUpdated := sync.Once{}
 	oncePendingTermination := sync.Once{}
 	onceNotAvailable := sync.Once{}
@@ -150,6 +273,11 @@ func (c Client) DoRolloutWait(ctx context.Context, key types.NamespacedName) err
 		if err != nil {
 			return false, err
 		}
+		if pc != nil {
+			if err := pc.check(ctx, &deployment); err != nil {
+				return false, err
+			}
+		}
 		if deployment.Generation <= deployment.Status.ObservedGeneration {
 			cond := deploymentutil.GetDeploymentCondition(deployment.Status, appsv1.DeploymentProgressing)
 			if cond != nil && cond.Reason == deploymentutil.TimedOutReason {
@@ -189,10 +317,14 @@ func (c Client) DoRolloutWait(ctx context.Context, key types.NamespacedName) err
 	return wait.PollImmediateUntil(time.Second, rolloutComplete, ctx.Done())
 }
 
+// DoCSVWait waits for the CSV with key to reach phase Succeeded. If the CSV
+// fails, or does not succeed before ctx is done, a *CSVFailedError describing
+// why is returned.
 func (c Client) DoCSVWait(ctx context.Context, key types.NamespacedName) error {
 	var (
-		curPhase olmapiv1alpha1.ClusterServiceVersionPhase
-		newPhase olmapiv1alpha1.ClusterServiceVersionPhase
+		curPhase  olmapiv1alpha1.ClusterServiceVersionPhase
+		newPhase  olmapiv1alpha1.ClusterServiceVersionPhase
+		curReason olmapiv1alpha1.ConditionReason
 	)
 	once := sync.Once{}
 
@@ -213,10 +345,17 @@ func (c Client) DoCSVWait(ctx context.Context, key types.NamespacedName) error {
 			curPhase = newPhase
 			log.Printf("  Found ClusterServiceVersion %q phase: %s", key, curPhase)
 		}
+		if csv.Status.Reason != curReason {
+			curReason = csv.Status.Reason
+			if curReason == olmapiv1alpha1.CSVReasonRequirementsNotMet {
+				log.Printf("  ClusterServiceVersion %q requirements not met: %s",
+					key, strings.Join(getUnmetRequirements(csv), ", "))
+			}
+		}
 
 		switch curPhase {
 		case olmapiv1alpha1.CSVPhaseFailed:
-			return false, fmt.Errorf("csv failed: reason: %q, message: %q", csv.Status.Reason, csv.Status.Message)
+			return false, c.newCSVFailedError(ctx, key, csv, nil)
 		case olmapiv1alpha1.CSVPhaseSucceeded:
 			return true, nil
 		default:
@@ -225,10 +364,14 @@ func (c Client) DoCSVWait(ctx context.Context, key types.NamespacedName) error {
 	}
 
 	err := wait.PollImmediateUntil(time.Second, csvPhaseSucceeded, ctx.Done())
-	if err != nil && errors.Is(err, context.DeadlineExceeded) {
-		if depCheckErr := c.printDeploymentErrors(ctx, key, csv); depCheckErr != nil {
-			return fmt.Errorf("error printing operator resource errors: %v %v", err, depCheckErr)
+	if err != nil && (errors.Is(err, wait.ErrWaitTimeout) || errors.Is(err, context.DeadlineExceeded)) {
+		// ctx is done, so diagnostics need their own deadline.
+		diagCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if depCheckErr := c.printDeploymentErrors(diagCtx, key, csv); depCheckErr != nil {
+			log.Debugf("Error printing operator resource errors: %v", depCheckErr)
 		}
+		return c.newCSVFailedError(diagCtx, key, csv, err)
 	}
 	return err
 }
@@ -282,7 +425,7 @@ func (c Client) printPodErrors(ctx context.Context, depSelectors *metav1.LabelSe
 	for _, p := range podList.Items {
 		if p.Status.Phase != corev1.PodSucceeded {
 			for _, cs := range p.Status.ContainerStatuses {
-				if !cs.Ready {
+				if !cs.Ready && cs.State.Waiting != nil {
 					podErrors[p.Name] = cs.State.Waiting.Message
 				}
 			}
@@ -294,8 +437,155 @@ func (c Client) printPodErrors(ctx context.Context, depSelectors *metav1.LabelSe
 	return nil
 }
 
+const (
+	// OLMOperatorGroupName is the name of the OperatorGroup OLM's release
+	// manifests create in the OLM namespace.
+	OLMOperatorGroupName = "olm-operators"
+	// OLMVersionAnnotation is set on the OLMOperatorGroupName OperatorGroup
+	// to the exact OLM version `olm install` installed.
+	OLMVersionAnnotation = "operators.operatorframework.io/olm-version"
+	// OLMNamespaceAnnotation is set on the ClusterServiceVersion CRD to the
+	// namespace `olm install` installed OLM in.
+	OLMNamespaceAnnotation = "operators.operatorframework.io/olm-namespace"
+	// OLMComponentEnvAnnotation is set on OLM component Deployments and the
+	// package server CSV to the comma-separated names of environment variables
+	// `olm install --component-env` injected into their containers.
+	OLMComponentEnvAnnotation = "operators.operatorframework.io/component-env"
+	// OLMManifestsDirAnnotation is set on the ClusterServiceVersion CRD to the
+	// absolute path of the local directory `olm install --olm-manifests-dir`
+	// installed OLM from.
+	OLMManifestsDirAnnotation = "operators.operatorframework.io/olm-manifests-dir"
+
+	csvCRDName = "clusterserviceversions.operators.coreos.com"
+)
+
 // GetInstalledVersion returns the OLM version installed in the namespace informed.
+// The version recorded by `olm install` is preferred, falling back to the
+// package server CSV's version for installations it did not record.
 func (c Client) GetInstalledVersion(ctx context.Context, namespace string) (string, error) {
+	version, err := c.GetRecordedVersion(ctx, namespace)
+	if err != nil {
+		return "", err
+	}
+	if version != "" {
+		return version, nil
+	}
+	return c.getPackageServerVersion(ctx, namespace)
+}
+
+// GetRecordedVersion returns the OLM version recorded at install time in
+// namespace, or an empty string if none was recorded.
+func (c Client) GetRecordedVersion(ctx context.Context, namespace string) (string, error) {
+	og := newOLMOperatorGroup(namespace)
+	if err := c.KubeClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: og.GetName()}, og); err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get operatorgroup %q: %v", og.GetName(), err)
+	}
+	return og.GetAnnotations()[OLMVersionAnnotation], nil
+}
+
+// RecordVersion sets OLMVersionAnnotation to version on the OLM OperatorGroup
+// in namespace.
+func (c Client) RecordVersion(ctx context.Context, namespace, version string) error {
+	og := newOLMOperatorGroup(namespace)
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, OLMVersionAnnotation, version)
+	if err := c.KubeClient.Patch(ctx, og, client.RawPatch(types.MergePatchType, []byte(patch))); err != nil {
+		return fmt.Errorf("failed to record OLM version on operatorgroup %q: %v", og.GetName(), err)
+	}
+	return nil
+}
+
+// GetRecordedNamespace returns the namespace OLM was installed in as recorded
+// at install time, or an empty string if none was recorded.
+func (c Client) GetRecordedNamespace(ctx context.Context) (string, error) {
+	crd, err := c.getCSVCRD(ctx)
+	if err != nil || crd == nil {
+		return "", err
+	}
+	return crd.GetAnnotations()[OLMNamespaceAnnotation], nil
+}
+
+// RecordNamespace sets OLMNamespaceAnnotation to namespace on OLM's
+// ClusterServiceVersion CRD, which unlike OLM's namespace can be found
+// without knowing where OLM is installed.
+func (c Client) RecordNamespace(ctx context.Context, namespace string) error {
+	crd, err := c.getCSVCRD(ctx)
+	if err != nil {
+		return err
+	}
+	if crd == nil {
+		return fmt.Errorf("failed to record OLM namespace: CRD %q not found", csvCRDName)
+	}
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, OLMNamespaceAnnotation, namespace)
+	if err := c.KubeClient.Patch(ctx, crd, client.RawPatch(types.MergePatchType, []byte(patch))); err != nil {
+		return fmt.Errorf("failed to record OLM namespace on CRD %q: %v", csvCRDName, err)
+	}
+	return nil
+}
+
+// GetRecordedManifestsDir returns the local directory OLM was installed from as
+// recorded at install time, or an empty string if it was downloaded.
+func (c Client) GetRecordedManifestsDir(ctx context.Context) (string, error) {
+	crd, err := c.getCSVCRD(ctx)
+	if err != nil || crd == nil {
+		return "", err
+	}
+	return crd.GetAnnotations()[OLMManifestsDirAnnotation], nil
+}
+
+// RecordManifestsDir sets OLMManifestsDirAnnotation to dir on OLM's
+// ClusterServiceVersion CRD, or removes it if dir is empty so an installation
+// of downloaded manifests does not inherit the directory of a previous one.
+func (c Client) RecordManifestsDir(ctx context.Context, dir string) error {
+	crd, err := c.getCSVCRD(ctx)
+	if err != nil {
+		return err
+	}
+	if crd == nil {
+		return fmt.Errorf("failed to record OLM manifests directory: CRD %q not found", csvCRDName)
+	}
+	value := "null"
+	if dir != "" {
+		value = fmt.Sprintf("%q", dir)
+	}
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%s}}}`, OLMManifestsDirAnnotation, value)
+	if err := c.KubeClient.Patch(ctx, crd, client.RawPatch(types.MergePatchType, []byte(patch))); err != nil {
+		return fmt.Errorf("failed to record OLM manifests directory on CRD %q: %v", csvCRDName, err)
+	}
+	return nil
+}
+
+// getCSVCRD returns the ClusterServiceVersion CRD, or nil if it does not exist.
+func (c Client) getCSVCRD(ctx context.Context) (*unstructured.Unstructured, error) {
+	// Clusters older than Kubernetes 1.16 only serve v1beta1 CRDs.
+	for _, version := range []string{"v1", "v1beta1"} {
+		crd := &unstructured.Unstructured{}
+		crd.SetGroupVersionKind(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: version, Kind: "CustomResourceDefinition"})
+		err := c.KubeClient.Get(ctx, types.NamespacedName{Name: csvCRDName}, crd)
+		if err == nil {
+			return crd, nil
+		}
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		if !meta.IsNoMatchError(err) {
+			return nil, fmt.Errorf("failed to get CRD %q: %v", csvCRDName, err)
+		}
+	}
+	return nil, nil
+}
+
+func newOLMOperatorGroup(namespace string) *unstructured.Unstructured {
+	og := &unstructured.Unstructured{}
+	og.SetGroupVersionKind(schema.GroupVersionKind{Group: olmapiv1alpha1.GroupName, Version: "v1", Kind: "OperatorGroup"})
+	og.SetNamespace(namespace)
+	og.SetName(OLMOperatorGroupName)
+	return og
+}
+
+func (c Client) getPackageServerVersion(ctx context.Context, namespace string) (string, error) {
 	opts := client.InNamespace(namespace)
 	csvs := &olmapiv1alpha1.ClusterServiceVersionList{}
 	if err := c.KubeClient.List(ctx, csvs, opts); err != nil {
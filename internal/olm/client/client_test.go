@@ -16,6 +16,8 @@ package client
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -23,6 +25,8 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 
 	"k8s.io/apimachinery/pkg/types"
 
@@ -193,4 +197,333 @@ var _ = Describe("Client", func() {
 			})
 		})
 	})
+
+	Describe("DoCSVWait", func() {
+		const (
+			namespace = "testns"
+			depName   = "memcached-operator-controller-manager"
+			image     = "quay.io/example/does-not-exist:v0.0.1"
+		)
+		var (
+			key    = types.NamespacedName{Namespace: namespace, Name: "memcached-operator.v0.0.1"}
+			labels = map[string]string{"control-plane": "controller-manager"}
+			csv    *olmapiv1alpha1.ClusterServiceVersion
+			objs   []runtime.Object
+		)
+
+		newEvent := func(name, kind, objName, reason, message string, age time.Duration) *corev1.Event {
+			return &corev1.Event{
+				ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: namespace},
+				InvolvedObject: corev1.ObjectReference{Kind: kind, Name: objName, Namespace: namespace},
+				Type:           corev1.EventTypeWarning,
+				Reason:         reason,
+				Message:        message,
+				LastTimestamp:  metav1.NewTime(time.Now().Add(-age)),
+			}
+		}
+
+		BeforeEach(func() {
+			csv = &olmapiv1alpha1.ClusterServiceVersion{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: namespace},
+				Spec: olmapiv1alpha1.ClusterServiceVersionSpec{
+					InstallStrategy: olmapiv1alpha1.NamedInstallStrategy{
+						StrategySpec: olmapiv1alpha1.StrategyDetailsDeployment{
+							DeploymentSpecs: []olmapiv1alpha1.StrategyDeploymentSpec{{
+								Name: depName,
+								Spec: appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: labels}},
+							}},
+						},
+					},
+				},
+			}
+			rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+				Name:            depName + "-8687c65f7d",
+				Namespace:       namespace,
+				Labels:          labels,
+				OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: depName}},
+			}}
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name:            rs.GetName() + "-kc44t",
+				Namespace:       namespace,
+				Labels:          labels,
+				OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: rs.GetName()}},
+			}}
+			objs = []runtime.Object{
+				rs, pod,
+				newEvent("ev-csv", olmapiv1alpha1.ClusterServiceVersionKind, key.Name, "InstallWaiting",
+					"installing: waiting for deployment "+depName+" to become ready", 3*time.Minute),
+				newEvent("ev-rs", "ReplicaSet", rs.GetName(), "SuccessfulCreate",
+					"Created pod: "+pod.GetName(), 2*time.Minute),
+				newEvent("ev-pod", "Pod", pod.GetName(), "Failed",
+					`Failed to pull image "`+image+`": rpc error: code = NotFound`, time.Minute),
+				newEvent("ev-other", "Pod", "unrelated", "Failed", "unrelated failure", 0),
+			}
+		})
+
+		doCSVWait := func(timeout time.Duration) error {
+			cl := Client{KubeClient: fake.NewFakeClient(append(objs, csv)...)}
+			ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+			defer cancel()
+			return cl.DoCSVWait(ctx, key)
+		}
+
+		It("should return immediately with the failure reason and recent events if the CSV failed", func() {
+			csv.Status.Phase = olmapiv1alpha1.CSVPhaseFailed
+			csv.Status.Reason = olmapiv1alpha1.CSVReasonInstallCheckFailed
+			csv.Status.Message = "install timeout"
+			start := time.Now()
+			err := doCSVWait(time.Minute)
+			Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+			csvErr := &CSVFailedError{}
+			Expect(errors.As(err, &csvErr)).To(BeTrue())
+			Expect(csvErr.TimedOut).To(BeFalse())
+			Expect(csvErr.Events).To(HaveLen(3))
+			Expect(csvErr.Events[2]).To(HavePrefix("Pod " + depName))
+			Expect(err.Error()).To(ContainSubstring(`reason: "InstallCheckFailed"`))
+			Expect(err.Error()).To(ContainSubstring(`message: "install timeout"`))
+			Expect(err.Error()).To(ContainSubstring(`Failed to pull image "` + image + `"`))
+			Expect(err.Error()).NotTo(ContainSubstring("unrelated failure"))
+		})
+		It("should describe image pull failures if the CSV does not succeed in time", func() {
+			csv.Status.Phase = olmapiv1alpha1.CSVPhaseInstalling
+			csv.Status.Reason = olmapiv1alpha1.CSVReasonWaiting
+			err := doCSVWait(100 * time.Millisecond)
+			csvErr := &CSVFailedError{}
+			Expect(errors.As(err, &csvErr)).To(BeTrue())
+			Expect(csvErr.TimedOut).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("timed out waiting for ClusterServiceVersion"))
+			Expect(err.Error()).To(ContainSubstring(`Failed to pull image "` + image + `"`))
+		})
+		It("should list unmet requirements by name", func() {
+			csv.Status.Phase = olmapiv1alpha1.CSVPhasePending
+			csv.Status.Reason = olmapiv1alpha1.CSVReasonRequirementsNotMet
+			csv.Status.RequirementStatus = []olmapiv1alpha1.RequirementStatus{
+				{Kind: "CustomResourceDefinition", Name: "memcacheds.cache.example.com", Status: olmapiv1alpha1.RequirementStatusReasonNotPresent},
+				{Kind: "ServiceAccount", Name: "default", Status: olmapiv1alpha1.RequirementStatusReasonPresent},
+			}
+			err := doCSVWait(100 * time.Millisecond)
+			csvErr := &CSVFailedError{}
+			Expect(errors.As(err, &csvErr)).To(BeTrue())
+			Expect(csvErr.UnmetRequirements).To(Equal([]string{`CustomResourceDefinition "memcacheds.cache.example.com": NotPresent`}))
+			Expect(err.Error()).To(ContainSubstring("unmet requirements"))
+		})
+	})
+
+	Describe("DoRolloutWaitCheckPods", func() {
+		const (
+			namespace = "testns"
+			depName   = "memcached-operator-registry-server"
+			// parseErr is logged by the registry initializer for a CSV with a malformed spec.
+			parseErr = `level=fatal msg="permissive mode disabled" error="error loading manifests from directory: ` +
+				`error adding operator bundle : json: cannot unmarshal string into Go struct field ` +
+				`.customresourcedefinitions of type struct { Owned []registry.DefinitionKey }"`
+		)
+		var (
+			depKey   = types.NamespacedName{Namespace: namespace, Name: depName}
+			labels   = map[string]string{"owner": "operator-sdk"}
+			dep      *appsv1.Deployment
+			newRS    *appsv1.ReplicaSet
+			pod      *corev1.Pod
+			objs     []runtime.Object
+			logsOpts *corev1.PodLogOptions
+		)
+
+		newReplicaSet := func(name string, template corev1.PodTemplateSpec) *appsv1.ReplicaSet {
+			return &appsv1.ReplicaSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            name,
+					Namespace:       namespace,
+					Labels:          labels,
+					OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: depName}},
+				},
+				Spec: appsv1.ReplicaSetSpec{Template: template},
+			}
+		}
+		newPod := func(name, rsName string) *corev1.Pod {
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            name,
+					Namespace:       namespace,
+					Labels:          labels,
+					OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: rsName}},
+				},
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{{Name: "registry-grpc"}},
+				},
+			}
+		}
+		crashLoop := func(p *corev1.Pod) {
+			p.Status.ContainerStatuses[0].State.Waiting = &corev1.ContainerStateWaiting{
+				Reason:  "CrashLoopBackOff",
+				Message: "back-off 10s restarting failed container=registry-grpc",
+			}
+		}
+
+		BeforeEach(func() {
+			replicas := int32(1)
+			template := corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "registry-grpc", Image: "quay.io/operator-framework/upstream-registry-builder:latest"}}},
+			}
+			dep = &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: depName, Namespace: namespace},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: template,
+				},
+				Status: appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1},
+			}
+			newRS = newReplicaSet(depName+"-6d4f8b9c7d", template)
+			pod = newPod(newRS.GetName()+"-x7k2p", newRS.GetName())
+			objs = []runtime.Object{dep, newRS, pod}
+			logsOpts = nil
+		})
+
+		doRolloutWait := func(timeout time.Duration) error {
+			cl := Client{
+				KubeClient: fake.NewFakeClient(objs...),
+				GetPodLogs: func(_ context.Context, key types.NamespacedName, opts *corev1.PodLogOptions) ([]byte, error) {
+					Expect(key).To(Equal(types.NamespacedName{Namespace: namespace, Name: pod.GetName()}))
+					logsOpts = opts
+					return []byte(`level=info msg="loading Bundles" dir=/registry/manifests` + "\n" + parseErr + "\n"), nil
+				},
+			}
+			ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+			defer cancel()
+			return cl.DoRolloutWaitCheckPods(ctx, depKey)
+		}
+
+		It("should return the registry's logs if a pod is crash looping on a corrupt CSV", func() {
+			crashLoop(pod)
+			start := time.Now()
+			err := doRolloutWait(time.Minute)
+			Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+			podErr := &PodFailedError{}
+			Expect(errors.As(err, &podErr)).To(BeTrue())
+			Expect(podErr.Container).To(Equal("registry-grpc"))
+			Expect(podErr.Reason).To(Equal("CrashLoopBackOff"))
+			Expect(podErr.Logs).To(HaveLen(2))
+			Expect(err.Error()).To(ContainSubstring(pod.GetName()))
+			Expect(err.Error()).To(ContainSubstring(parseErr))
+			Expect(logsOpts.Previous).To(BeTrue())
+			Expect(*logsOpts.TailLines).To(BeEquivalentTo(maxPodLogLines))
+		})
+		It("should return the registry's logs if a pod repeatedly fails its readiness probe", func() {
+			objs = append(objs, &corev1.Event{
+				ObjectMeta:     metav1.ObjectMeta{Name: "ev-unhealthy", Namespace: namespace},
+				InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: pod.GetName(), Namespace: namespace},
+				Type:           corev1.EventTypeWarning,
+				Reason:         "Unhealthy",
+				Message:        `Readiness probe failed: timeout: failed to connect service "localhost:50051" within 1s`,
+				Count:          maxReadinessProbeFailures,
+			})
+			err := doRolloutWait(time.Minute)
+			podErr := &PodFailedError{}
+			Expect(errors.As(err, &podErr)).To(BeTrue())
+			Expect(podErr.Reason).To(Equal("Unhealthy"))
+			Expect(err.Error()).To(ContainSubstring(parseErr))
+			Expect(logsOpts.Previous).To(BeFalse())
+		})
+		It("should ignore crash looping pods of old ReplicaSets", func() {
+			oldTemplate := *dep.Spec.Template.DeepCopy()
+			oldTemplate.Spec.Containers[0].Image = "quay.io/example/old:v0.0.1"
+			oldRS := newReplicaSet(depName+"-5c9b7f6d8b", oldTemplate)
+			oldPod := newPod(oldRS.GetName()+"-q8w4z", oldRS.GetName())
+			crashLoop(oldPod)
+			objs = append(objs, oldRS, oldPod)
+			err := doRolloutWait(100 * time.Millisecond)
+			Expect(err).To(HaveOccurred())
+			Expect(errors.As(err, new(*PodFailedError))).To(BeFalse())
+		})
+	})
+
+	Describe("GetInstalledVersion", func() {
+		const ns = "olm"
+
+		var (
+			c      Client
+			pkgCSV *olmapiv1alpha1.ClusterServiceVersion
+		)
+
+		BeforeEach(func() {
+			pkgCSV = &olmapiv1alpha1.ClusterServiceVersion{}
+			pkgCSV.SetName(pkgServerCSVNewName)
+			pkgCSV.SetNamespace(ns)
+			pkgCSV.SetLabels(map[string]string{pkgServerOLMVersionLabel: "0.15.1"})
+			c = Client{KubeClient: fake.NewFakeClient(pkgCSV)}
+		})
+
+		It("should fall back to the package server CSV if no version was recorded", func() {
+			Expect(c.KubeClient.Create(context.TODO(), newOLMOperatorGroup(ns))).To(Succeed())
+			version, err := c.GetInstalledVersion(context.TODO(), ns)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal("0.15.1"))
+		})
+		It("should return the recorded version", func() {
+			Expect(c.KubeClient.Create(context.TODO(), newOLMOperatorGroup(ns))).To(Succeed())
+			Expect(c.RecordVersion(context.TODO(), ns, "0.16.0")).To(Succeed())
+			version, err := c.GetInstalledVersion(context.TODO(), ns)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal("0.16.0"))
+		})
+		It("should fail to record a version if the OperatorGroup does not exist", func() {
+			Expect(c.RecordVersion(context.TODO(), ns, "0.16.0")).NotTo(Succeed())
+		})
+	})
+
+	Describe("RecordNamespace", func() {
+		It("should record OLM's namespace on the ClusterServiceVersion CRD", func() {
+			crd := &unstructured.Unstructured{}
+			crd.SetAPIVersion("apiextensions.k8s.io/v1")
+			crd.SetKind("CustomResourceDefinition")
+			crd.SetName(csvCRDName)
+			c := Client{KubeClient: fake.NewFakeClient(crd)}
+
+			ns, err := c.GetRecordedNamespace(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ns).To(BeEmpty())
+			Expect(c.RecordNamespace(context.TODO(), "platform-olm")).To(Succeed())
+			ns, err = c.GetRecordedNamespace(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ns).To(Equal("platform-olm"))
+		})
+		It("should fail if OLM's CRDs are not installed", func() {
+			c := Client{KubeClient: fake.NewFakeClient()}
+			Expect(c.RecordNamespace(context.TODO(), "platform-olm")).To(MatchError(ContainSubstring("not found")))
+		})
+	})
+
+	Describe("RecordManifestsDir", func() {
+		It("should record and remove OLM's manifests directory on the ClusterServiceVersion CRD", func() {
+			crd := &unstructured.Unstructured{}
+			crd.SetAPIVersion("apiextensions.k8s.io/v1")
+			crd.SetKind("CustomResourceDefinition")
+			crd.SetName(csvCRDName)
+			c := Client{KubeClient: fake.NewFakeClient(crd)}
+
+			Expect(c.RecordManifestsDir(context.TODO(), "/opt/olm/0.16.1")).To(Succeed())
+			dir, err := c.GetRecordedManifestsDir(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dir).To(Equal("/opt/olm/0.16.1"))
+			Expect(c.RecordManifestsDir(context.TODO(), "")).To(Succeed())
+			dir, err = c.GetRecordedManifestsDir(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dir).To(BeEmpty())
+		})
+	})
+	Describe("isSubset", func() {
+		It("should ignore fields only set in the existing object", func() {
+			desired := map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}}
+			existing := map[string]interface{}{"spec": map[string]interface{}{"replicas": float64(1), "paused": false}}
+			Expect(isSubset(desired, existing)).To(BeTrue())
+		})
+		It("should detect changed values and lists", func() {
+			existing := map[string]interface{}{"args": []interface{}{"-namespace", "olm"}}
+			Expect(isSubset(map[string]interface{}{"args": []interface{}{"-namespace", "olm"}}, existing)).To(BeTrue())
+			Expect(isSubset(map[string]interface{}{"args": []interface{}{"-namespace"}}, existing)).To(BeFalse())
+			Expect(isSubset(map[string]interface{}{"args": []interface{}{"-namespace", "operators"}}, existing)).To(BeFalse())
+			Expect(isSubset(map[string]interface{}{"image": "olm"}, existing)).To(BeFalse())
+		})
+	})
 })
@@ -0,0 +1,194 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	olmapiv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxCSVEvents is the number of most recent events included in CSV errors.
+const maxCSVEvents = 10
+
+// CSVFailedError is returned when a CSV reaches phase Failed, or does not
+// succeed before its install deadline.
+type CSVFailedError struct {
+	Key     types.NamespacedName
+	Phase   olmapiv1alpha1.ClusterServiceVersionPhase
+	Reason  olmapiv1alpha1.ConditionReason
+	Message string
+	// TimedOut is true if the CSV had not failed when the deadline was exceeded.
+	TimedOut bool
+	// UnmetRequirements describes requirements not met if Reason is RequirementsNotMet.
+	UnmetRequirements []string
+	// Events are the most recent events on the CSV and its operator Deployments'
+	// ReplicaSets and Pods, oldest first.
+	Events []string
+	Err    error
+}
+
+func (e *CSVFailedError) Error() string {
+	sb := &strings.Builder{}
+	if e.TimedOut {
+		fmt.Fprintf(sb, "timed out waiting for ClusterServiceVersion %q to succeed (phase: %q, reason: %q, message: %q)",
+			e.Key, e.Phase, e.Reason, e.Message)
+	} else {
+		fmt.Fprintf(sb, "ClusterServiceVersion %q failed: reason: %q, message: %q", e.Key, e.Reason, e.Message)
+	}
+	if len(e.UnmetRequirements) != 0 {
+		fmt.Fprintf(sb, "\nunmet requirements:\n  %s", strings.Join(e.UnmetRequirements, "\n  "))
+	}
+	if len(e.Events) != 0 {
+		fmt.Fprintf(sb, "\nrecent events:\n  %s", strings.Join(e.Events, "\n  "))
+	}
+	return sb.String()
+}
+
+func (e *CSVFailedError) Unwrap() error {
+	return e.Err
+}
+
+// newCSVFailedError returns a CSVFailedError for csv, collecting unmet
+// requirements and recent events related to it.
+func (c Client) newCSVFailedError(ctx context.Context, key types.NamespacedName, csv olmapiv1alpha1.ClusterServiceVersion, err error) *CSVFailedError {
+	e := &CSVFailedError{
+		Key:      key,
+		Phase:    csv.Status.Phase,
+		Reason:   csv.Status.Reason,
+		Message:  csv.Status.Message,
+		TimedOut: err != nil,
+		Err:      err,
+	}
+	if csv.Status.Reason == olmapiv1alpha1.CSVReasonRequirementsNotMet {
+		e.UnmetRequirements = getUnmetRequirements(csv)
+	}
+	events, evErr := c.getCSVEvents(ctx, key.Namespace, csv)
+	if evErr != nil {
+		log.Debugf("Error getting events for ClusterServiceVersion %q: %v", key, evErr)
+	}
+	e.Events = events
+	return e
+}
+
+// getUnmetRequirements returns descriptions of csv's requirements that are
+// not present, or whose dependents are not satisfied.
+func getUnmetRequirements(csv olmapiv1alpha1.ClusterServiceVersion) (unmet []string) {
+	for _, req := range csv.Status.RequirementStatus {
+		if req.Status == olmapiv1alpha1.RequirementStatusReasonPresent {
+			continue
+		}
+		desc := fmt.Sprintf("%s %q: %s", req.Kind, req.Name, req.Status)
+		if req.Message != "" {
+			desc += ": " + req.Message
+		}
+		unmet = append(unmet, desc)
+	}
+	return unmet
+}
+
+// getCSVEvents returns the most recent events in namespace involving csv, or
+// the ReplicaSets of csv's operator Deployments and their Pods, which is
+// where image pull and scheduling failures are reported.
+func (c Client) getCSVEvents(ctx context.Context, namespace string, csv olmapiv1alpha1.ClusterServiceVersion) ([]string, error) {
+	involved := map[string]bool{
+		olmapiv1alpha1.ClusterServiceVersionKind + "/" + csv.GetName(): true,
+	}
+	for _, ds := range csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs {
+		if ds.Spec.Selector == nil {
+			continue
+		}
+		sel, err := metav1.LabelSelectorAsSelector(ds.Spec.Selector)
+		if err != nil {
+			return nil, err
+		}
+		opts := []client.ListOption{client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: sel}}
+		rsList := &appsv1.ReplicaSetList{}
+		if err := c.KubeClient.List(ctx, rsList, opts...); err != nil {
+			return nil, fmt.Errorf("error listing ReplicaSets: %v", err)
+		}
+		replicaSets := map[string]bool{}
+		for _, rs := range rsList.Items {
+			if isOwnedBy(rs.GetOwnerReferences(), "Deployment", ds.Name) {
+				replicaSets[rs.GetName()] = true
+				involved["ReplicaSet/"+rs.GetName()] = true
+			}
+		}
+		podList := &corev1.PodList{}
+		if err := c.KubeClient.List(ctx, podList, opts...); err != nil {
+			return nil, fmt.Errorf("error listing Pods: %v", err)
+		}
+		for _, pod := range podList.Items {
+			for _, ref := range pod.GetOwnerReferences() {
+				if ref.Kind == "ReplicaSet" && replicaSets[ref.Name] {
+					involved["Pod/"+pod.GetName()] = true
+				}
+			}
+		}
+	}
+
+	eventList := &corev1.EventList{}
+	if err := c.KubeClient.List(ctx, eventList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("error listing Events: %v", err)
+	}
+	var events []corev1.Event
+	for _, ev := range eventList.Items {
+		if involved[ev.InvolvedObject.Kind+"/"+ev.InvolvedObject.Name] {
+			events = append(events, ev)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+	if len(events) > maxCSVEvents {
+		events = events[len(events)-maxCSVEvents:]
+	}
+	descs := make([]string, len(events))
+	for i, ev := range events {
+		descs[i] = fmt.Sprintf("%s %s: %s %s: %s",
+			ev.InvolvedObject.Kind, ev.InvolvedObject.Name, ev.Type, ev.Reason, strings.TrimSpace(ev.Message))
+	}
+	return descs, nil
+}
+
+func isOwnedBy(refs []metav1.OwnerReference, kind, name string) bool {
+	for _, ref := range refs {
+		if ref.Kind == kind && ref.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// eventTime returns the time ev last occurred.
+func eventTime(ev corev1.Event) time.Time {
+	switch {
+	case !ev.LastTimestamp.IsZero():
+		return ev.LastTimestamp.Time
+	case !ev.EventTime.IsZero():
+		return ev.EventTime.Time
+	}
+	return ev.GetCreationTimestamp().Time
+}
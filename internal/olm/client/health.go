@@ -0,0 +1,179 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	olmapiv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+)
+
+// packageServerAPIServiceName is the name of the APIService registered by
+// OLM's package server, which serves catalog content to OLM clients.
+const packageServerAPIServiceName = "v1.packages.operators.coreos.com"
+
+var apiServiceGVK = schema.GroupVersionKind{
+	Group:   "apiregistration.k8s.io",
+	Version: "v1",
+	Kind:    "APIService",
+}
+
+// ServerDiscovery discovers a cluster's version and API groups. It is
+// implemented by discovery clients and cluster snapshots.
+type ServerDiscovery interface {
+	discovery.ServerVersionInterface
+	discovery.ServerGroupsInterface
+}
+
+// OLMNotInstalledError is returned by CheckOLMInstalled when a cluster does not
+// serve OLM's APIs, or serves them but OLM is unhealthy.
+// errors.Is(err, ErrOLMNotInstalled) is true for all OLMNotInstalledErrors.
+type OLMNotInstalledError struct {
+	// KubernetesVersion is the cluster's version, if it could be found.
+	KubernetesVersion string
+	// Unhealthy describes why OLM is unhealthy, if installed.
+	Unhealthy string
+}
+
+func (e *OLMNotInstalledError) Error() string {
+	ver := e.KubernetesVersion
+	if ver == "" {
+		ver = "unknown version"
+	}
+	if e.Unhealthy != "" {
+		return fmt.Sprintf("OLM is installed but unhealthy on this cluster (Kubernetes %s): %s; "+
+			"check its status with \"operator-sdk olm status\" or reinstall it with \"operator-sdk olm install\"",
+			ver, e.Unhealthy)
+	}
+	return fmt.Sprintf("OLM is not installed on this cluster (Kubernetes %s): API group %q is not served; "+
+		"install it with \"operator-sdk olm install\"", ver, olmapiv1alpha1.SchemeGroupVersion)
+}
+
+func (e *OLMNotInstalledError) Is(target error) bool {
+	return target == ErrOLMNotInstalled
+}
+
+// CheckOLMInstalled returns an *OLMNotInstalledError if dc does not discover
+// OLM's operators.coreos.com/v1alpha1 API, or if OLM's package server
+// APIService is not available.
+func (c Client) CheckOLMInstalled(ctx context.Context, dc ServerDiscovery) error {
+	var kubeVersion string
+	if info, err := dc.ServerVersion(); err != nil {
+		log.Debugf("Failed to get Kubernetes version: %v", err)
+	} else {
+		kubeVersion = info.GitVersion
+	}
+
+	groups, err := dc.ServerGroups()
+	if err != nil {
+		return fmt.Errorf("error discovering API groups: %v", err)
+	}
+	served := false
+	for _, g := range groups.Groups {
+		if g.Name != olmapiv1alpha1.GroupName {
+			continue
+		}
+		for _, v := range g.Versions {
+			if v.Version == olmapiv1alpha1.GroupVersion {
+				served = true
+			}
+		}
+	}
+	if !served {
+		return &OLMNotInstalledError{KubernetesVersion: kubeVersion}
+	}
+
+	status := c.GetObjectsStatus(ctx, NewPackageServerAPIService())
+	if reason := getAPIServiceUnavailableReason(status.Resources[0]); reason != "" {
+		return &OLMNotInstalledError{KubernetesVersion: kubeVersion, Unhealthy: reason}
+	}
+	return nil
+}
+
+// NewPackageServerAPIService returns an unpopulated APIService with the name
+// of the one OLM's package server registers.
+func NewPackageServerAPIService() *unstructured.Unstructured {
+	apiService := &unstructured.Unstructured{}
+	apiService.SetGroupVersionKind(apiServiceGVK)
+	apiService.SetName(packageServerAPIServiceName)
+	return apiService
+}
+
+// DoPackageServerAPIServiceWait waits for OLM's package server APIService to
+// be available, returning why it is unavailable if ctx is done first.
+func (c Client) DoPackageServerAPIServiceWait(ctx context.Context) error {
+	apiService := NewPackageServerAPIService()
+	reason := "not found"
+	err := wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		rs := c.GetObjectsStatus(ctx, apiService).Resources[0]
+		if rs.Error != nil {
+			if apierrors.IsNotFound(rs.Error) {
+				return false, nil
+			}
+			return false, rs.Error
+		}
+		var available bool
+		available, reason = getConditionHealth(rs.Resource, "Available")
+		return available, nil
+	}, ctx.Done())
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return fmt.Errorf("APIService %q is not available: %s", packageServerAPIServiceName, reason)
+	}
+	return err
+}
+
+// getAPIServiceUnavailableReason returns why the APIService in rs is not
+// available, or an empty string if it is available or its status can't be read.
+func getAPIServiceUnavailableReason(rs ResourceStatus) string {
+	if rs.Error != nil {
+		if apierrors.IsNotFound(rs.Error) {
+			return fmt.Sprintf("APIService %q not found", rs.NamespacedName.Name)
+		}
+		// Users may not be able to read APIServices, in which case the install should proceed.
+		log.Debugf("Skipping OLM health check: get APIService %q: %v", rs.NamespacedName.Name, rs.Error)
+		return ""
+	}
+	conditions, _, err := unstructured.NestedSlice(rs.Resource.Object, "status", "conditions")
+	if err != nil {
+		log.Debugf("Skipping OLM health check: APIService %q conditions: %v", rs.NamespacedName.Name, err)
+		return ""
+	}
+	for _, c := range conditions {
+		cond := struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		}{}
+		m, ok := c.(map[string]interface{})
+		if !ok || runtime.DefaultUnstructuredConverter.FromUnstructured(m, &cond) != nil {
+			continue
+		}
+		if cond.Type == "Available" && cond.Status != "True" {
+			return fmt.Sprintf("APIService %q is not available: %s: %s", rs.NamespacedName.Name, cond.Reason, cond.Message)
+		}
+	}
+	return ""
+}
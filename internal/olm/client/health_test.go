@@ -0,0 +1,109 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newAPIService(available, reason, message string) *unstructured.Unstructured {
+	u := NewPackageServerAPIService()
+	Expect(unstructured.SetNestedSlice(u.Object, []interface{}{
+		map[string]interface{}{"type": "Available", "status": available, "reason": reason, "message": message},
+	}, "status", "conditions")).To(Succeed())
+	return u
+}
+
+var _ = Describe("CheckOLMInstalled", func() {
+	var dc *fakediscovery.FakeDiscovery
+
+	check := func(objs ...runtime.Object) error {
+		c := Client{KubeClient: fake.NewFakeClient(objs...)}
+		return c.CheckOLMInstalled(context.TODO(), dc)
+	}
+
+	BeforeEach(func() {
+		dc = &fakediscovery.FakeDiscovery{
+			Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+				{GroupVersion: "v1"},
+				{GroupVersion: "apps/v1"},
+			}},
+			FakedServerVersion: &version.Info{GitVersion: "v1.19.1"},
+		}
+	})
+
+	It("should succeed if OLM is installed and healthy", func() {
+		dc.Resources = append(dc.Resources, &metav1.APIResourceList{GroupVersion: "operators.coreos.com/v1alpha1"})
+		Expect(check(newAPIService("True", "Passed", "all checks passed"))).To(Succeed())
+	})
+	It("should return ErrOLMNotInstalled with guidance if OLM's API group is not served", func() {
+		err := check()
+		Expect(errors.Is(err, ErrOLMNotInstalled)).To(BeTrue())
+		notInstalled := &OLMNotInstalledError{}
+		Expect(errors.As(err, &notInstalled)).To(BeTrue())
+		Expect(notInstalled.KubernetesVersion).To(Equal("v1.19.1"))
+		Expect(notInstalled.Unhealthy).To(BeEmpty())
+		Expect(err.Error()).To(ContainSubstring("operator-sdk olm install"))
+		Expect(err.Error()).To(ContainSubstring("Kubernetes v1.19.1"))
+	})
+	It("should return ErrOLMNotInstalled if the package server APIService is unavailable", func() {
+		dc.Resources = append(dc.Resources, &metav1.APIResourceList{GroupVersion: "operators.coreos.com/v1alpha1"})
+		err := check(newAPIService("False", "MissingEndpoints", "endpoints for service/packageserver-service have no addresses"))
+		Expect(errors.Is(err, ErrOLMNotInstalled)).To(BeTrue())
+		notInstalled := &OLMNotInstalledError{}
+		Expect(errors.As(err, &notInstalled)).To(BeTrue())
+		Expect(notInstalled.Unhealthy).To(ContainSubstring("MissingEndpoints"))
+		Expect(err.Error()).To(ContainSubstring("operator-sdk olm install"))
+	})
+	It("should return ErrOLMNotInstalled if the package server APIService does not exist", func() {
+		dc.Resources = append(dc.Resources, &metav1.APIResourceList{GroupVersion: "operators.coreos.com/v1alpha1"})
+		err := check()
+		Expect(errors.Is(err, ErrOLMNotInstalled)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("not found"))
+	})
+})
+
+var _ = Describe("DoPackageServerAPIServiceWait", func() {
+	wait := func(objs ...runtime.Object) error {
+		c := Client{KubeClient: fake.NewFakeClient(objs...)}
+		ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+		defer cancel()
+		return c.DoPackageServerAPIServiceWait(ctx)
+	}
+
+	It("should succeed once the APIService is available", func() {
+		Expect(wait(newAPIService("True", "Passed", "all checks passed"))).To(Succeed())
+	})
+	It("should return the APIService's failure message if it does not become available", func() {
+		err := wait(newAPIService("False", "FailedDiscoveryCheck", "failing or missing response from https://10.0.0.1:5443"))
+		Expect(err).To(MatchError(`APIService "v1.packages.operators.coreos.com" is not available: ` +
+			"Available=False: FailedDiscoveryCheck: failing or missing response from https://10.0.0.1:5443"))
+	})
+	It("should fail if the APIService is never created", func() {
+		Expect(wait()).To(MatchError(`APIService "v1.packages.operators.coreos.com" is not available: not found`))
+	})
+})
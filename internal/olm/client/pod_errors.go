@@ -0,0 +1,219 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	deploymentutil "k8s.io/kubectl/pkg/util/deployment"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// maxPodLogLines is the number of most recent log lines included in pod errors.
+	maxPodLogLines = 50
+	// maxReadinessProbeFailures is the number of readiness probe failures
+	// after which a pod is considered failed.
+	maxReadinessProbeFailures = 12
+
+	reasonCrashLoopBackOff = "CrashLoopBackOff"
+	reasonUnhealthy        = "Unhealthy"
+)
+
+// PodFailedError is returned when a Deployment's pod is crash looping or
+// repeatedly failing its readiness probe while waiting for rollout.
+type PodFailedError struct {
+	Key       types.NamespacedName
+	Container string
+	Reason    string
+	Message   string
+	// Logs are the last lines logged by Container, from its previous
+	// instance if it is crash looping.
+	Logs []string
+	// LogsErr is set if logs could not be retrieved.
+	LogsErr error
+}
+
+func (e *PodFailedError) Error() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "Pod %q container %q failed: reason: %q, message: %q", e.Key, e.Container, e.Reason, e.Message)
+	if len(e.Logs) != 0 {
+		fmt.Fprintf(sb, "\ncontainer logs:\n  %s", strings.Join(e.Logs, "\n  "))
+	} else if e.LogsErr != nil {
+		fmt.Fprintf(sb, "\nerror getting container logs: %v", e.LogsErr)
+	}
+	return sb.String()
+}
+
+// podChecker checks pods of a Deployment's newest ReplicaSet for failures.
+type podChecker struct {
+	c       Client
+	depKey  types.NamespacedName
+	seenPod map[string]bool
+}
+
+func newPodChecker(c Client, depKey types.NamespacedName) *podChecker {
+	return &podChecker{c: c, depKey: depKey, seenPod: map[string]bool{}}
+}
+
+// check returns a *PodFailedError if a pod of dep's newest ReplicaSet has
+// failed. Pods of older ReplicaSets are ignored, since they are replaced by
+// the rollout being waited on.
+func (pc *podChecker) check(ctx context.Context, dep *appsv1.Deployment) error {
+	if dep.Spec.Selector == nil {
+		return nil
+	}
+	sel, err := metav1.LabelSelectorAsSelector(dep.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	opts := []client.ListOption{client.InNamespace(dep.GetNamespace()), client.MatchingLabelsSelector{Selector: sel}}
+	rsList := &appsv1.ReplicaSetList{}
+	if err := pc.c.KubeClient.List(ctx, rsList, opts...); err != nil {
+		return fmt.Errorf("error listing ReplicaSets: %v", err)
+	}
+	var replicaSets []*appsv1.ReplicaSet
+	for i := range rsList.Items {
+		if isOwnedBy(rsList.Items[i].GetOwnerReferences(), "Deployment", dep.GetName()) {
+			replicaSets = append(replicaSets, &rsList.Items[i])
+		}
+	}
+	newRS := deploymentutil.FindNewReplicaSet(dep, replicaSets)
+	if newRS == nil {
+		return nil
+	}
+	podList := &corev1.PodList{}
+	if err := pc.c.KubeClient.List(ctx, podList, opts...); err != nil {
+		return fmt.Errorf("error listing Pods: %v", err)
+	}
+
+	var unhealthy map[string]int32
+	for _, pod := range podList.Items {
+		if pod.GetDeletionTimestamp() != nil || !isOwnedBy(pod.GetOwnerReferences(), "ReplicaSet", newRS.GetName()) {
+			continue
+		}
+		podKey := types.NamespacedName{Namespace: pod.GetNamespace(), Name: pod.GetName()}
+		if !pc.seenPod[pod.GetName()] {
+			pc.seenPod[pod.GetName()] = true
+			log.Debugf("  Found Pod %q for Deployment %q", podKey, pc.depKey)
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if w := cs.State.Waiting; w != nil && w.Reason == reasonCrashLoopBackOff {
+				return pc.newPodFailedError(ctx, podKey, cs.Name, w.Reason, w.Message, true)
+			}
+		}
+		if isReady(pod) {
+			continue
+		}
+		if unhealthy == nil {
+			if unhealthy, err = pc.getReadinessProbeFailures(ctx, dep.GetNamespace()); err != nil {
+				return err
+			}
+		}
+		if n := unhealthy[pod.GetName()]; n >= maxReadinessProbeFailures {
+			msg := fmt.Sprintf("readiness probe failed %d times", n)
+			return pc.newPodFailedError(ctx, podKey, getUnreadyContainer(pod), reasonUnhealthy, msg, false)
+		}
+	}
+	return nil
+}
+
+// getReadinessProbeFailures returns the number of readiness probe failures
+// reported by events in namespace, keyed by Pod name.
+func (pc *podChecker) getReadinessProbeFailures(ctx context.Context, namespace string) (map[string]int32, error) {
+	eventList := &corev1.EventList{}
+	if err := pc.c.KubeClient.List(ctx, eventList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("error listing Events: %v", err)
+	}
+	failures := map[string]int32{}
+	for _, ev := range eventList.Items {
+		if ev.InvolvedObject.Kind != "Pod" || ev.Reason != reasonUnhealthy ||
+			!strings.HasPrefix(ev.Message, "Readiness probe failed") {
+			continue
+		}
+		count := ev.Count
+		if count == 0 {
+			count = 1
+		}
+		failures[ev.InvolvedObject.Name] += count
+	}
+	return failures, nil
+}
+
+// newPodFailedError returns a PodFailedError for container in the Pod with
+// key, including the container's last log lines, or its previous instance's
+// if previous is true.
+func (pc *podChecker) newPodFailedError(ctx context.Context, key types.NamespacedName, container, reason, msg string, previous bool) *PodFailedError {
+	e := &PodFailedError{Key: key, Container: container, Reason: reason, Message: msg}
+	if pc.c.GetPodLogs == nil {
+		e.LogsErr = errors.New("no pod log getter configured")
+		return e
+	}
+	tailLines := int64(maxPodLogLines)
+	logs, err := pc.c.GetPodLogs(ctx, key, &corev1.PodLogOptions{
+		Container: container,
+		Previous:  previous,
+		TailLines: &tailLines,
+	})
+	if err != nil {
+		e.LogsErr = err
+		return e
+	}
+	e.Logs = splitLogLines(string(logs), maxPodLogLines)
+	return e
+}
+
+// splitLogLines returns at most the last max non-empty trailing lines of logs.
+func splitLogLines(logs string, max int) []string {
+	lines := strings.Split(strings.TrimRight(logs, "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	if len(lines) > max {
+		lines = lines[len(lines)-max:]
+	}
+	return lines
+}
+
+func isReady(pod corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// getUnreadyContainer returns the name of pod's first container that is not
+// ready, or its first container if all are.
+func getUnreadyContainer(pod corev1.Pod) string {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if !cs.Ready {
+			return cs.Name
+		}
+	}
+	if len(pod.Spec.Containers) != 0 {
+		return pod.Spec.Containers[0].Name
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	redactedValue = "<redacted>"
+	omittedValue  = "<omitted>"
+)
+
+// LogObject logs obj as YAML with logf, prefixed by verb, ex. "Creating".
+func LogObject(logf func(string, ...interface{}), verb string, obj runtime.Object) {
+	kind, name := "object", ""
+	if gvk, err := apiutil.GVKForObject(obj, Scheme); err == nil {
+		kind = gvk.Kind
+	}
+	if a, err := meta.Accessor(obj); err == nil {
+		name = getName(a.GetNamespace(), a.GetName())
+	}
+	b, err := FormatObjectYAML(obj)
+	if err != nil {
+		logf("%s %s %q: failed to format object: %v", verb, kind, name, err)
+		return
+	}
+	logf("%s %s %q:\n%s", verb, kind, name, b)
+}
+
+// FormatObjectYAML returns obj as YAML suitable for logging. Managed fields are
+// stripped, Secret values are redacted, and ConfigMap values are omitted so
+// only their keys are shown.
+func FormatObjectYAML(obj runtime.Object) ([]byte, error) {
+	var u map[string]interface{}
+	if uobj, ok := obj.(runtime.Unstructured); ok {
+		u = runtime.DeepCopyJSON(uobj.UnstructuredContent())
+	} else {
+		var err error
+		if u, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj); err != nil {
+			return nil, err
+		}
+	}
+
+	// Typed objects do not always have their TypeMeta set.
+	if gvk, err := apiutil.GVKForObject(obj, Scheme); err == nil {
+		apiVersion, kind := gvk.ToAPIVersionAndKind()
+		u["apiVersion"], u["kind"] = apiVersion, kind
+	}
+	unstructured.RemoveNestedField(u, "metadata", "managedFields")
+
+	switch u["kind"] {
+	case "Secret":
+		replaceValues(u, redactedValue, "data", "stringData")
+	case "ConfigMap":
+		replaceValues(u, omittedValue, "data", "binaryData")
+	}
+
+	b, err := yaml.Marshal(u)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling object: %v", err)
+	}
+	return b, nil
+}
+
+// replaceValues replaces all values in each map field of u with value.
+func replaceValues(u map[string]interface{}, value string, fields ...string) {
+	for _, field := range fields {
+		m, ok := u[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k := range m {
+			m[k] = value
+		}
+	}
+}
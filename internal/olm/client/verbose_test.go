@@ -0,0 +1,73 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	olmapiv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("FormatObjectYAML", func() {
+	It("should set type meta and strip managed fields", func() {
+		cs := &olmapiv1alpha1.CatalogSource{}
+		cs.SetName("memcached-operator-catalog")
+		cs.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "operator-sdk"}})
+		cs.Spec.SourceType = olmapiv1alpha1.SourceTypeGrpc
+		b, err := FormatObjectYAML(cs)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(ContainSubstring("kind: CatalogSource\n"))
+		Expect(string(b)).To(ContainSubstring("sourceType: grpc\n"))
+		Expect(string(b)).NotTo(ContainSubstring("managedFields"))
+	})
+	It("should redact Secret data", func() {
+		s := &corev1.Secret{
+			Data:       map[string][]byte{"password": []byte("hunter2")},
+			StringData: map[string]string{"token": "abcdef"},
+		}
+		b, err := FormatObjectYAML(s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(ContainSubstring("password: <redacted>\n"))
+		Expect(string(b)).To(ContainSubstring("token: <redacted>\n"))
+		Expect(string(b)).NotTo(ContainSubstring("hunter2"))
+		Expect(string(b)).NotTo(ContainSubstring("abcdef"))
+	})
+	It("should only show ConfigMap keys", func() {
+		cm := &corev1.ConfigMap{
+			BinaryData: map[string][]byte{"abcde.memcached-operator.v0.0.1.clusterserviceversion.yaml": []byte("kind: ClusterServiceVersion")},
+		}
+		b, err := FormatObjectYAML(cm)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(ContainSubstring("abcde.memcached-operator.v0.0.1.clusterserviceversion.yaml: <omitted>\n"))
+		Expect(string(b)).NotTo(ContainSubstring("kind: ClusterServiceVersion"))
+	})
+})
+
+var _ = Describe("LogObject", func() {
+	It("should log an object's kind, name and YAML", func() {
+		var out string
+		logf := func(format string, args ...interface{}) { out = fmt.Sprintf(format, args...) }
+		cm := &corev1.ConfigMap{}
+		cm.SetName("foo")
+		cm.SetNamespace("testns")
+		LogObject(logf, "Creating", cm)
+		Expect(out).To(HavePrefix(`Creating ConfigMap "testns/foo":` + "\n"))
+		Expect(out).To(ContainSubstring("name: foo\n"))
+	})
+})
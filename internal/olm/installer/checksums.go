@@ -0,0 +1,89 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// releaseChecksumsFile is the name of the sha256sum-formatted file of manifest
+// checksums looked up in a release when none are supplied.
+const releaseChecksumsFile = "checksums.txt"
+
+// Checksums maps release file names to their hex-encoded sha256 sums.
+type Checksums map[string]string
+
+// ReadChecksumsFile reads checksums from the sha256sum-formatted file at path.
+func ReadChecksumsFile(path string) (Checksums, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading checksums file: %v", err)
+	}
+	sums, err := parseChecksums(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing checksums file %s: %v", path, err)
+	}
+	return sums, nil
+}
+
+// parseChecksums parses lines of the form "<sha256>  <file name>", as written
+// by sha256sum. File names may be paths, and only their base name is kept.
+func parseChecksums(r io.Reader) (Checksums, error) {
+	sums := Checksums{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<sha256>  <file name>\"", n)
+		}
+		sum, name := strings.ToLower(fields[0]), strings.TrimPrefix(fields[1], "*")
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("line %d: invalid sha256 sum %q", n, fields[0])
+		}
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		sums[name] = sum
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// Verify returns an error if data's sha256 sum does not match the sum for
+// file name, or if sums has no entry for name.
+func (sums Checksums) Verify(name string, data []byte) error {
+	want, ok := sums[name]
+	if !ok {
+		return fmt.Errorf("no checksum found for %s", name)
+	}
+	h := sha256.Sum256(data)
+	if got := hex.EncodeToString(h[:]); got != want {
+		return fmt.Errorf("checksum mismatch for %s: expected sha256 %s, got %s", name, want, got)
+	}
+	return nil
+}
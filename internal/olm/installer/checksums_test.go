@@ -0,0 +1,184 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	testCRDs = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: subscriptions.operators.coreos.com
+`
+	testOLM = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: olm
+`
+)
+
+func sha256Hex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+var _ = Describe("Checksums", func() {
+	Describe("parseChecksums", func() {
+		It("should parse sha256sum output", func() {
+			sums, err := parseChecksums(strings.NewReader(fmt.Sprintf(
+				"# OLM 0.15.1\n%s  crds.yaml\n%s *deploy/upstream/olm.yaml\n\n",
+				sha256Hex(testCRDs), strings.ToUpper(sha256Hex(testOLM)))))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sums).To(Equal(Checksums{crdsFile: sha256Hex(testCRDs), olmFile: sha256Hex(testOLM)}))
+		})
+		It("should reject malformed lines", func() {
+			_, err := parseChecksums(strings.NewReader("crds.yaml\n"))
+			Expect(err).To(MatchError(ContainSubstring("line 1")))
+			_, err = parseChecksums(strings.NewReader("abc123  crds.yaml\n"))
+			Expect(err).To(MatchError(`line 1: invalid sha256 sum "abc123"`))
+		})
+	})
+
+	Describe("Verify", func() {
+		sums := Checksums{crdsFile: sha256Hex(testCRDs)}
+
+		It("should accept matching data", func() {
+			Expect(sums.Verify(crdsFile, []byte(testCRDs))).To(Succeed())
+		})
+		It("should reject mismatched data", func() {
+			Expect(sums.Verify(crdsFile, []byte(testOLM))).To(MatchError(ContainSubstring("checksum mismatch for crds.yaml")))
+		})
+		It("should reject files without a checksum", func() {
+			Expect(sums.Verify(olmFile, []byte(testOLM))).To(MatchError("no checksum found for olm.yaml"))
+		})
+	})
+
+	Describe("ReadChecksumsFile", func() {
+		It("should read a checksums file", func() {
+			tmp, err := ioutil.TempDir("", "olm-checksums-")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmp)
+			path := filepath.Join(tmp, "sha256sums")
+			Expect(ioutil.WriteFile(path, []byte(sha256Hex(testOLM)+"  olm.yaml\n"), 0644)).To(Succeed())
+
+			sums, err := ReadChecksumsFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sums).To(HaveKeyWithValue(olmFile, sha256Hex(testOLM)))
+		})
+	})
+
+	Describe("getResources", func() {
+		var (
+			files  map[string]string
+			server *httptest.Server
+			c      Client
+		)
+
+		BeforeEach(func() {
+			files = map[string]string{crdsFile: testCRDs, olmFile: testOLM}
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, ok := files[filepath.Base(r.URL.Path)]
+				if !ok || !strings.HasPrefix(r.URL.Path, "/download/0.15.1/") {
+					http.NotFound(w, r)
+					return
+				}
+				fmt.Fprint(w, data)
+			}))
+			c = Client{HTTPClient: *server.Client(), BaseDownloadURL: server.URL}
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should fetch manifests without verification if the release has no checksums", func() {
+			resources, err := c.getResources(context.TODO(), "0.15.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resources).To(HaveLen(2))
+		})
+		It("should verify manifests against the release's checksums", func() {
+			files[releaseChecksumsFile] = fmt.Sprintf("%s  crds.yaml\n%s  olm.yaml\n", sha256Hex(testCRDs), sha256Hex(testOLM))
+			resources, err := c.getResources(context.TODO(), "0.15.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resources).To(HaveLen(2))
+
+			files[olmFile] = testOLM + "  labels:\n    tampered: \"true\"\n"
+			_, err = c.getResources(context.TODO(), "0.15.1")
+			Expect(err).To(MatchError(ContainSubstring("checksum mismatch for olm.yaml")))
+		})
+		It("should read manifests and checksums from a directory instead of downloading them", func() {
+			tmp, err := ioutil.TempDir("", "olm-manifests-")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmp)
+			files[releaseChecksumsFile] = fmt.Sprintf("%s  crds.yaml\n%s  olm.yaml\n", sha256Hex(testCRDs), sha256Hex(testOLM))
+			for name, data := range files {
+				Expect(ioutil.WriteFile(filepath.Join(tmp, name), []byte(data), 0644)).To(Succeed())
+			}
+
+			c.ManifestsDir = tmp
+			resources, err := c.getResources(context.TODO(), DefaultVersion)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resources).To(HaveLen(2))
+
+			Expect(ioutil.WriteFile(filepath.Join(tmp, olmFile), []byte(testCRDs), 0644)).To(Succeed())
+			_, err = c.getResources(context.TODO(), DefaultVersion)
+			Expect(err).To(MatchError(ContainSubstring("checksum mismatch for olm.yaml")))
+
+			Expect(os.Remove(filepath.Join(tmp, olmFile))).To(Succeed())
+			_, err = c.getResources(context.TODO(), DefaultVersion)
+			Expect(err).To(MatchError(ContainSubstring("olm.yaml not found in " + tmp)))
+		})
+		It("should rewrite images if a mirror is set", func() {
+			files[olmFile] = testOLM + "---\napiVersion: operators.coreos.com/v1alpha1\nkind: CatalogSource\n" +
+				"metadata:\n  name: operatorhubio-catalog\n  namespace: olm\nspec:\n  image: quay.io/operatorhubio/catalog:latest\n"
+			c.ImageMirror = "registry.example.com"
+			resources, err := c.getResources(context.TODO(), "0.15.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resources).To(HaveLen(3))
+			Expect(resources[2].Object["spec"]).To(HaveKeyWithValue("image", "registry.example.com/operatorhubio/catalog:latest"))
+		})
+		It("should prefer supplied checksums over the release's", func() {
+			files[releaseChecksumsFile] = fmt.Sprintf("%s  crds.yaml\n%s  olm.yaml\n", sha256Hex(testCRDs), sha256Hex(testOLM))
+			c.Checksums = Checksums{crdsFile: sha256Hex(testCRDs), olmFile: sha256Hex(testCRDs)}
+			_, err := c.getResources(context.TODO(), "0.15.1")
+			Expect(err).To(MatchError(ContainSubstring("checksum mismatch for olm.yaml")))
+		})
+	})
+})
+
+var _ = Describe("validateVersion", func() {
+	It("should accept latest and exact versions", func() {
+		Expect(validateVersion(DefaultVersion)).To(Succeed())
+		Expect(validateVersion("0.15.1")).To(Succeed())
+		Expect(validateVersion("v0.17.0")).To(Succeed())
+	})
+	It("should reject inexact versions", func() {
+		Expect(validateVersion("0.15")).To(MatchError(ContainSubstring(`invalid version "0.15"`)))
+		Expect(validateVersion("stable")).NotTo(Succeed())
+	})
+})
@@ -18,16 +18,23 @@
 package installer
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	olmapiv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -43,12 +50,44 @@ const (
 	olmOperatorName     = "olm-operator"
 	catalogOperatorName = "catalog-operator"
 	packageServerName   = "packageserver"
+	// packageServerVersionLabel labels the package server CSV with its OLM version.
+	packageServerVersionLabel = "olm.version"
 )
 
+// Manifest files of an OLM release.
+const (
+	crdsFile = "crds.yaml"
+	olmFile  = "olm.yaml"
+)
+
+var errNotFound = errors.New("not found")
+
 type Client struct {
 	*olmresourceclient.Client
 	HTTPClient      http.Client
 	BaseDownloadURL string
+	// Checksums, if set, verify downloaded manifests instead of the
+	// checksums file shipped with a release.
+	Checksums Checksums
+	// ManifestsDir, if set, is a local directory containing a release's
+	// manifests to read instead of downloading them.
+	ManifestsDir string
+	// ImageMirror, if set, replaces the registry of every image referenced
+	// by the manifests.
+	ImageMirror string
+	// Force, if set, makes UninstallVersion release finalizers of OLM objects
+	// in OLM's namespaces that are not deleted within ForceGracePeriod.
+	Force            bool
+	ForceGracePeriod time.Duration
+	// ComponentEnv, if set, is injected into the containers of OLM's
+	// component Deployments by InstallVersion.
+	ComponentEnv []corev1.EnvVar
+	// PreserveCRDs, if set, makes UninstallVersion leave OLM's CRDs, and
+	// therefore all OLM resources outside OLM's namespaces, in place.
+	PreserveCRDs bool
+	// Upgrade, if set, allows InstallVersion to apply a version other than
+	// the one already installed.
+	Upgrade bool
 }
 
 func ClientForConfig(cfg *rest.Config) (*Client, error) {
@@ -66,24 +105,47 @@ func ClientForConfig(cfg *rest.Config) (*Client, error) {
 
 func (c Client) InstallVersion(ctx context.Context, namespace, version string) (*olmresourceclient.Status, error) {
 
-	resources, err := c.getResources(ctx, version)
+	resources, err := c.getResources(ctx, namespace, version)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get resources: %v", err)
 	}
+	if version == DefaultVersion {
+		if v := getManifestVersion(resources); v != "" {
+			version = v
+		}
+	}
+	if len(c.ComponentEnv) != 0 {
+		log.Infof("Setting %s in the env of OLM's components", envNames(c.ComponentEnv))
+		if err := setComponentEnv(resources, c.ComponentEnv); err != nil {
+			return nil, err
+		}
+	}
 	objs := toObjects(resources...)
 
+	previous, err := c.checkConflicts(ctx, namespace, version, resources)
+	if err != nil {
+		return nil, err
+	}
 	status := c.GetObjectsStatus(ctx, objs...)
 	installed, err := status.HasInstalledResources()
-	if installed {
-		return nil, errors.New(
-			"detected existing OLM resources: OLM must be completely uninstalled before installation")
-	} else if err != nil {
+	if err != nil {
 		return nil, errors.New("detected errored OLM resources, see resource statuses for more details")
 	}
+	switch {
+	case previous != "" && !sameVersion(previous, version):
+		log.Infof("Upgrading OLM version %q to %q", previous, version)
+	case installed:
+		log.Print("Detected existing OLM resources, resuming installation by applying missing or outdated resources")
+	}
 
-	log.Print("Creating CRDs and resources")
-	if err := c.DoCreate(ctx, objs...); err != nil {
-		return nil, fmt.Errorf("failed to create CRDs and resources: %v", err)
+	// Stamp each resource with the version it was created for, so a partial
+	// installation can be detected and resumed.
+	if version != DefaultVersion {
+		setVersionAnnotation(resources, version)
+	}
+	log.Print("Applying CRDs and resources")
+	if err := c.DoApply(ctx, objs...); err != nil {
+		return nil, fmt.Errorf("failed to apply CRDs and resources: %v", err)
 	}
 
 	log.Print("Waiting for deployment/olm-operator rollout to complete")
@@ -126,12 +188,114 @@ func (c Client) InstallVersion(ctx context.Context, namespace, version string) (
 		return nil, fmt.Errorf("deployment/%s failed to rollout: %v", packageServerKey.Name, err)
 	}
 
-	status = c.GetObjectsStatus(ctx, objs...)
+	// OLM clients fail discovery until the package server's APIService is available.
+	log.Print("Waiting for the package server APIService to become available")
+	if err := c.DoPackageServerAPIServiceWait(ctx); err != nil {
+		return nil, err
+	}
+
+	// Record the exact version installed, so uninstall and status do not have
+	// to infer it from the package server CSV.
+	if version == DefaultVersion {
+		if version, err = c.GetInstalledVersion(ctx, namespace); err != nil {
+			return nil, fmt.Errorf("failed to resolve installed OLM version: %v", err)
+		}
+	}
+	if err := c.RecordVersion(ctx, namespace, version); err != nil {
+		log.Warnf("Could not record installed OLM version %q: %v", version, err)
+	} else {
+		log.Infof("Recorded installed OLM version %q", version)
+	}
+	if err := c.RecordNamespace(ctx, namespace); err != nil {
+		log.Warnf("Could not record OLM namespace %q: %v", namespace, err)
+	}
+	if err := c.RecordManifestsDir(ctx, c.ManifestsDir); err != nil {
+		log.Warnf("Could not record OLM manifests directory %q: %v", c.ManifestsDir, err)
+	}
+
+	status = c.GetObjectsStatus(ctx, withAPIService(objs)...)
 	return &status, nil
 }
 
+// checkConflicts returns an error if OLM is installed in a namespace other
+// than namespace, or if a version other than version is installed in namespace
+// and c.Upgrade is not set. The previously installed version, if any, is returned.
+func (c Client) checkConflicts(ctx context.Context, namespace, version string, resources []unstructured.Unstructured) (string, error) {
+	recorded, err := c.GetRecordedNamespace(ctx)
+	if err != nil {
+		return "", err
+	}
+	if recorded != "" && recorded != namespace {
+		return "", fmt.Errorf("OLM is already installed in namespace %q: "+
+			"uninstall it before installing OLM in namespace %q", recorded, namespace)
+	}
+	previous, err := c.getPreviousVersion(ctx, namespace, resources)
+	if err != nil {
+		return "", fmt.Errorf("failed to get OLM version installed in namespace %q: %v", namespace, err)
+	}
+	if previous != "" && !sameVersion(previous, version) && !c.Upgrade {
+		return "", fmt.Errorf("namespace %q already hosts OLM version %q, which conflicts with version %q: "+
+			"set --upgrade to upgrade it, or uninstall it before installing another version", namespace, previous, version)
+	}
+	return previous, nil
+}
+
+// getPreviousVersion returns the OLM version of a complete or partial
+// installation in namespace, or an empty string if OLM is not installed.
+// Versions stamped on existing resources take precedence, so installations
+// that failed before the version was recorded are still detected.
+func (c Client) getPreviousVersion(ctx context.Context, namespace string, resources []unstructured.Unstructured) (string, error) {
+	for _, r := range resources {
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(r.GroupVersionKind())
+		err := c.KubeClient.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: r.GetName()}, existing)
+		if err != nil {
+			if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+				continue
+			}
+			return "", err
+		}
+		if v := existing.GetAnnotations()[olmresourceclient.OLMVersionAnnotation]; v != "" {
+			return v, nil
+		}
+	}
+	version, err := c.GetInstalledVersion(ctx, namespace)
+	if errors.Is(err, olmresourceclient.ErrOLMNotInstalled) {
+		return "", nil
+	}
+	return version, err
+}
+
+// getManifestVersion returns the OLM version the package server CSV in
+// resources is labeled with, or an empty string if it has none.
+func getManifestVersion(resources []unstructured.Unstructured) string {
+	for _, r := range resources {
+		if r.GetKind() == olmapiv1alpha1.ClusterServiceVersionKind && r.GetName() == packageServerName {
+			return r.GetLabels()[packageServerVersionLabel]
+		}
+	}
+	return ""
+}
+
+// sameVersion returns true if a and b are the same version, ignoring "v" prefixes.
+func sameVersion(a, b string) bool {
+	return strings.TrimPrefix(a, "v") == strings.TrimPrefix(b, "v")
+}
+
+// setVersionAnnotation sets OLMVersionAnnotation to version on each resource.
+func setVersionAnnotation(resources []unstructured.Unstructured, version string) {
+	for i := range resources {
+		annotations := resources[i].GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[olmresourceclient.OLMVersionAnnotation] = version
+		resources[i].SetAnnotations(annotations)
+	}
+}
+
 func (c Client) UninstallVersion(ctx context.Context, namespace, version string) error {
-	resources, err := c.getResources(ctx, version)
+	resources, err := c.getResources(ctx, namespace, version)
 	if err != nil {
 		return fmt.Errorf("failed to get resources: %v", err)
 	}
@@ -144,6 +308,19 @@ func (c Client) UninstallVersion(ctx context.Context, namespace, version string)
 	}
 
 	log.Infof("Uninstalling resources for version %q", version)
+	if c.PreserveCRDs {
+		var crds []unstructured.Unstructured
+		crds, resources = splitCRDs(resources)
+		objs = toObjects(resources...)
+		log.Warn("Preserving OLM's CRDs: existing Subscriptions, ClusterServiceVersions and other OLM resources " +
+			"are kept, and operators they installed keep running unmanaged until OLM is reinstalled")
+		if err := c.releaseCRDs(ctx, crds); err != nil {
+			return err
+		}
+	}
+	if c.Force {
+		return c.forceDelete(ctx, resources)
+	}
 	if err := c.DoDelete(ctx, objs...); err != nil {
 		return err
 	}
@@ -151,61 +328,120 @@ func (c Client) UninstallVersion(ctx context.Context, namespace, version string)
 }
 
 func (c Client) GetStatus(ctx context.Context, namespace, version string) (*olmresourceclient.Status, error) {
-	resources, err := c.getResources(ctx, version)
+	resources, err := c.getResources(ctx, namespace, version)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get resources: %v", err)
 	}
-	objs := toObjects(resources...)
+	objs := withAPIService(toObjects(resources...))
 
 	status := c.GetObjectsStatus(ctx, objs...)
 	installed, err := status.HasInstalledResources()
 	if !installed && err == nil {
-		return nil, olmresourceclient.ErrOLMNotInstalled
+		// Return status so callers can still report which resources are missing.
+		return &status, olmresourceclient.ErrOLMNotInstalled
 	}
 	return &status, nil
 }
 
-func (c Client) getResources(ctx context.Context, version string) ([]unstructured.Unstructured, error) {
-	log.Infof("Fetching CRDs for version %q", version)
-	crdResources, err := c.getCRDs(ctx, version)
+// getResources returns OLM's release manifests for version, moved from
+// DefaultOLMNamespace to namespace.
+func (c Client) getResources(ctx context.Context, namespace, version string) ([]unstructured.Unstructured, error) {
+	sums, err := c.getChecksums(ctx, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch checksums: %v", err)
+	}
+
+	log.Infof("Fetching CRDs for %s", c.describeSource(version))
+	crdResources, err := c.getManifest(ctx, version, crdsFile, sums)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch CRDs: %v", err)
 	}
 
-	log.Infof("Fetching resources for version %q", version)
-	olmResources, err := c.getOLM(ctx, version)
+	log.Infof("Fetching resources for %s", c.describeSource(version))
+	olmResources, err := c.getManifest(ctx, version, olmFile, sums)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch resources: %v", err)
 	}
 
 	resources := append(crdResources, olmResources...)
+	if c.ImageMirror != "" {
+		for i := range resources {
+			mirrorImages(resources[i].Object, c.ImageMirror)
+		}
+	}
+	if namespace != DefaultOLMNamespace {
+		setNamespace(resources, DefaultOLMNamespace, namespace)
+	}
 	return resources, nil
 }
 
-func (c Client) getCRDs(ctx context.Context, version string) ([]unstructured.Unstructured, error) {
-	resp, err := c.doRequest(ctx, c.crdsURL(version))
+// getChecksums returns c.Checksums if set, otherwise the checksums shipped
+// with the release of version. Nil is returned if the release has none.
+func (c Client) getChecksums(ctx context.Context, version string) (Checksums, error) {
+	if c.Checksums != nil {
+		return c.Checksums, nil
+	}
+	rc, err := c.openFile(ctx, version, releaseChecksumsFile)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
+		if errors.Is(err, errNotFound) {
+			log.Warnf("No %s found for %s, manifests will not be verified", releaseChecksumsFile, c.describeSource(version))
+			return nil, nil
+		}
+		return nil, err
 	}
-	defer resp.Body.Close()
-	return decodeResources(resp.Body)
+	defer rc.Close()
+	return parseChecksums(rc)
 }
 
-func (c Client) getOLM(ctx context.Context, version string) ([]unstructured.Unstructured, error) {
-	resp, err := c.doRequest(ctx, c.olmURL(version))
+// getManifest reads and decodes the release file name of version, after
+// verifying it against sums if non-nil.
+func (c Client) getManifest(ctx context.Context, version, name string, sums Checksums) ([]unstructured.Unstructured, error) {
+	rc, err := c.openFile(ctx, version, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
+		return nil, fmt.Errorf("failed to read %s: %v", name, err)
 	}
-	defer resp.Body.Close()
-	return decodeResources(resp.Body)
+	if sums != nil {
+		if err := sums.Verify(name, data); err != nil {
+			return nil, err
+		}
+	}
+	return decodeResources(bytes.NewReader(data))
 }
 
-func (c Client) crdsURL(version string) string {
-	return fmt.Sprintf("%s/crds.yaml", c.getBaseDownloadURL(version))
+// openFile opens the release file name of version from c.ManifestsDir if set,
+// otherwise by downloading it. Missing files wrap errNotFound.
+func (c Client) openFile(ctx context.Context, version, name string) (io.ReadCloser, error) {
+	if c.ManifestsDir != "" {
+		f, err := os.Open(filepath.Join(c.ManifestsDir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("%s not found in %s: %w", name, c.ManifestsDir, errNotFound)
+			}
+			return nil, err
+		}
+		return f, nil
+	}
+	resp, err := c.doRequest(ctx, c.fileURL(version, name))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	return resp.Body, nil
 }
 
-func (c Client) olmURL(version string) string {
-	return fmt.Sprintf("%s/olm.yaml", c.getBaseDownloadURL(version))
+func (c Client) describeSource(version string) string {
+	if c.ManifestsDir != "" {
+		return fmt.Sprintf("directory %s", c.ManifestsDir)
+	}
+	return fmt.Sprintf("version %q", version)
+}
+
+func (c Client) fileURL(version, name string) string {
+	return fmt.Sprintf("%s/%s", c.getBaseDownloadURL(version), name)
 }
 
 func (c Client) getBaseDownloadURL(version string) string {
@@ -225,6 +461,10 @@ func (c Client) doRequest(ctx context.Context, url string) (*http.Response, erro
 	if err != nil {
 		return nil, fmt.Errorf("failed GET '%s': %v", url, err)
 	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed GET '%s': %w", url, errNotFound)
+	}
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
 		body, err := ioutil.ReadAll(resp.Body)
@@ -237,6 +477,12 @@ func (c Client) doRequest(ctx context.Context, url string) (*http.Response, erro
 	return resp, nil
 }
 
+// withAPIService appends the package server APIService, which OLM creates
+// rather than its manifests, to objs so its availability is part of a status.
+func withAPIService(objs []runtime.Object) []runtime.Object {
+	return append(objs, olmresourceclient.NewPackageServerAPIService())
+}
+
 func toObjects(us ...unstructured.Unstructured) (objs []runtime.Object) {
 	for i := range us {
 		objs = append(objs, &us[i])
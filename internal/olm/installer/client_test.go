@@ -0,0 +1,115 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	olmresourceclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+)
+
+const testPartialOLM = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: olm
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: olm-operator-serviceaccount
+  namespace: olm
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: olm-config
+  namespace: olm
+data:
+  level: debug
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: catalog-config
+  namespace: olm
+data:
+  level: info
+`
+
+var _ = Describe("Resuming a partial installation", func() {
+	var (
+		c         Client
+		resources []unstructured.Unstructured
+	)
+
+	BeforeEach(func() {
+		var err error
+		resources, err = decodeResources(strings.NewReader(testPartialOLM))
+		Expect(err).NotTo(HaveOccurred())
+		setVersionAnnotation(resources, "0.15.1")
+
+		// Simulate an installation that failed after creating half of its resources.
+		c = Client{Client: &olmresourceclient.Client{KubeClient: fake.NewFakeClient()}}
+		for _, r := range resources[:len(resources)/2] {
+			Expect(c.KubeClient.Create(context.TODO(), r.DeepCopy())).To(Succeed())
+		}
+	})
+
+	It("should detect the version of the partial installation", func() {
+		previous, err := c.getPreviousVersion(context.TODO(), "olm", resources)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(previous).To(Equal("0.15.1"))
+	})
+	It("should require an upgrade to install another version", func() {
+		_, err := c.checkConflicts(context.TODO(), "olm", "0.16.0", resources)
+		Expect(err).To(MatchError(ContainSubstring("set --upgrade")))
+	})
+	It("should create missing resources and update outdated ones", func() {
+		Expect(c.DoApply(context.TODO(), toObjects(resources...)...)).To(Succeed())
+		for _, r := range resources {
+			existing := &unstructured.Unstructured{}
+			existing.SetGroupVersionKind(r.GroupVersionKind())
+			key := types.NamespacedName{Namespace: r.GetNamespace(), Name: r.GetName()}
+			Expect(c.KubeClient.Get(context.TODO(), key, existing)).To(Succeed())
+			Expect(existing.GetAnnotations()).To(HaveKeyWithValue(olmresourceclient.OLMVersionAnnotation, "0.15.1"))
+		}
+
+		// Resuming again with changed manifests only updates what changed.
+		Expect(unstructured.SetNestedField(resources[3].Object, "warn", "data", "level")).To(Succeed())
+		Expect(c.DoApply(context.TODO(), toObjects(resources...)...)).To(Succeed())
+		cm := corev1.ConfigMap{}
+		Expect(c.KubeClient.Get(context.TODO(), types.NamespacedName{Namespace: "olm", Name: "catalog-config"}, &cm)).To(Succeed())
+		Expect(cm.Data).To(HaveKeyWithValue("level", "warn"))
+	})
+})
+
+var _ = Describe("getManifestVersion", func() {
+	It("should return the package server CSV's OLM version", func() {
+		csv := unstructured.Unstructured{}
+		csv.SetKind("ClusterServiceVersion")
+		csv.SetName("packageserver")
+		csv.SetLabels(map[string]string{"olm.version": "0.16.1"})
+		Expect(getManifestVersion([]unstructured.Unstructured{csv})).To(Equal("0.16.1"))
+		Expect(getManifestVersion(nil)).To(BeEmpty())
+	})
+})
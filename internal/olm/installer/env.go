@@ -0,0 +1,169 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	olmapiv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	olmresourceclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+)
+
+// proxyEnvNames are the environment variables copied by ProxyEnv.
+var proxyEnvNames = []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"}
+
+// ProxyEnv returns the proxy environment variables set in the current
+// environment as KEY=VALUE pairs. Upper case names take precedence over
+// lower case ones, ex. HTTP_PROXY over http_proxy.
+func ProxyEnv() (pairs []string) {
+	for _, name := range proxyEnvNames {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			value, ok = os.LookupEnv(strings.ToLower(name))
+		}
+		if ok {
+			pairs = append(pairs, name+"="+value)
+		}
+	}
+	return pairs
+}
+
+// ParseComponentEnv parses KEY=VALUE pairs into environment variables. Later
+// pairs override earlier ones with the same key.
+func ParseComponentEnv(pairs []string) ([]corev1.EnvVar, error) {
+	var env []corev1.EnvVar
+	for _, pair := range pairs {
+		split := strings.SplitN(pair, "=", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("invalid component env %q: must be of the form KEY=VALUE", pair)
+		}
+		if errs := validation.IsEnvVarName(split[0]); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid component env %q: %s", pair, strings.Join(errs, ", "))
+		}
+		env = setEnvVar(env, corev1.EnvVar{Name: split[0], Value: split[1]})
+	}
+	return env, nil
+}
+
+func setEnvVar(env []corev1.EnvVar, ev corev1.EnvVar) []corev1.EnvVar {
+	for i := range env {
+		if env[i].Name == ev.Name {
+			env[i] = ev
+			return env
+		}
+	}
+	return append(env, ev)
+}
+
+// setComponentEnv sets env on every container of the olm-operator and
+// catalog-operator Deployments, and of the packageserver Deployment in the
+// package server CSV's install strategy, in resources. The names of the set
+// variables are recorded in OLMComponentEnvAnnotation so status can report them.
+func setComponentEnv(resources []unstructured.Unstructured, env []corev1.EnvVar) error {
+	for i := range resources {
+		r := &resources[i]
+		var err error
+		switch {
+		case r.GetKind() == "Deployment" && (r.GetName() == olmOperatorName || r.GetName() == catalogOperatorName):
+			err = setContainersEnv(r.Object, env, "spec", "template", "spec", "containers")
+		case r.GetKind() == olmapiv1alpha1.ClusterServiceVersionKind && r.GetName() == packageServerName:
+			err = setCSVDeploymentEnv(r, packageServerName, env)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error setting env of %s %q: %v", r.GetKind(), r.GetName(), err)
+		}
+		annotations := r.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[olmresourceclient.OLMComponentEnvAnnotation] = envNames(env)
+		r.SetAnnotations(annotations)
+	}
+	return nil
+}
+
+// setCSVDeploymentEnv sets env on the containers of the install strategy
+// Deployment named name in csv.
+func setCSVDeploymentEnv(csv *unstructured.Unstructured, name string, env []corev1.EnvVar) error {
+	path := []string{"spec", "install", "spec", "deployments"}
+	deployments, _, err := unstructured.NestedSlice(csv.Object, path...)
+	if err != nil {
+		return err
+	}
+	for _, d := range deployments {
+		dep, ok := d.(map[string]interface{})
+		if !ok || dep["name"] != name {
+			continue
+		}
+		if err := setContainersEnv(dep, env, "spec", "template", "spec", "containers"); err != nil {
+			return err
+		}
+	}
+	return unstructured.SetNestedSlice(csv.Object, deployments, path...)
+}
+
+// setContainersEnv sets env on each container in the slice at path in obj,
+// replacing variables of the same name.
+func setContainersEnv(obj map[string]interface{}, env []corev1.EnvVar, path ...string) error {
+	containers, found, err := unstructured.NestedSlice(obj, path...)
+	if err != nil || !found {
+		return err
+	}
+	for _, c := range containers {
+		container, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		existing, _, err := unstructured.NestedSlice(container, "env")
+		if err != nil {
+			return err
+		}
+		for _, ev := range env {
+			existing = setUnstructuredEnvVar(existing, ev)
+		}
+		container["env"] = existing
+	}
+	return unstructured.SetNestedSlice(obj, containers, path...)
+}
+
+func setUnstructuredEnvVar(env []interface{}, ev corev1.EnvVar) []interface{} {
+	value := map[string]interface{}{"name": ev.Name, "value": ev.Value}
+	for i, e := range env {
+		if m, ok := e.(map[string]interface{}); ok && m["name"] == ev.Name {
+			env[i] = value
+			return env
+		}
+	}
+	return append(env, value)
+}
+
+// envNames returns the sorted, comma-separated names of env.
+func envNames(env []corev1.EnvVar) string {
+	var names []string
+	for _, ev := range env {
+		names = append(names, ev.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	olmapiv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	olmresourceclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+)
+
+const testComponentsOLM = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: olm-operator
+  namespace: olm
+spec:
+  template:
+    spec:
+      containers:
+      - name: olm-operator
+        env:
+        - name: OPERATOR_NAMESPACE
+          valueFrom:
+            fieldRef:
+              fieldPath: metadata.namespace
+        - name: HTTP_PROXY
+          value: http://old.example.com
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: catalog-operator
+  namespace: olm
+spec:
+  template:
+    spec:
+      containers:
+      - name: catalog-operator
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: other
+  namespace: olm
+spec:
+  template:
+    spec:
+      containers:
+      - name: other
+---
+apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: packageserver
+  namespace: olm
+spec:
+  install:
+    strategy: deployment
+    spec:
+      deployments:
+      - name: packageserver
+        spec:
+          template:
+            spec:
+              containers:
+              - name: packageserver
+`
+
+var _ = Describe("Component env", func() {
+	Describe("setComponentEnv", func() {
+		It("should patch the env of OLM's component Deployments", func() {
+			resources, err := decodeResources(strings.NewReader(testComponentsOLM))
+			Expect(err).NotTo(HaveOccurred())
+			env := []corev1.EnvVar{
+				{Name: "HTTP_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "NO_PROXY", Value: ".svc,10.0.0.0/8"},
+			}
+			Expect(setComponentEnv(resources, env)).To(Succeed())
+
+			toDeployment := func(i int) appsv1.Deployment {
+				dep := appsv1.Deployment{}
+				Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(resources[i].Object, &dep)).To(Succeed())
+				return dep
+			}
+			olmOperator := toDeployment(0)
+			Expect(olmOperator.Spec.Template.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+				{Name: "OPERATOR_NAMESPACE", ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+				}},
+				{Name: "HTTP_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "NO_PROXY", Value: ".svc,10.0.0.0/8"},
+			}))
+			Expect(olmOperator.GetAnnotations()).To(HaveKeyWithValue(olmresourceclient.OLMComponentEnvAnnotation, "HTTP_PROXY,NO_PROXY"))
+			Expect(toDeployment(1).Spec.Template.Spec.Containers[0].Env).To(Equal(env))
+			Expect(toDeployment(2).Spec.Template.Spec.Containers[0].Env).To(BeEmpty())
+			Expect(toDeployment(2).GetAnnotations()).To(BeEmpty())
+
+			csv := olmapiv1alpha1.ClusterServiceVersion{}
+			Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(resources[3].Object, &csv)).To(Succeed())
+			Expect(csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs[0].Spec.Template.Spec.Containers[0].Env).To(Equal(env))
+		})
+	})
+
+	Describe("ParseComponentEnv", func() {
+		It("should parse KEY=VALUE pairs, with later pairs taking precedence", func() {
+			env, err := ParseComponentEnv([]string{"HTTP_PROXY=http://a", "LOG=a=b", "HTTP_PROXY=http://b", "EMPTY="})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(Equal([]corev1.EnvVar{
+				{Name: "HTTP_PROXY", Value: "http://b"},
+				{Name: "LOG", Value: "a=b"},
+				{Name: "EMPTY", Value: ""},
+			}))
+		})
+		It("should fail on invalid pairs", func() {
+			_, err := ParseComponentEnv([]string{"HTTP_PROXY"})
+			Expect(err).To(MatchError(`invalid component env "HTTP_PROXY": must be of the form KEY=VALUE`))
+			_, err = ParseComponentEnv([]string{"=value"})
+			Expect(err).To(MatchError(ContainSubstring(`invalid component env "=value"`)))
+		})
+	})
+
+	Describe("ProxyEnv", func() {
+		var saved map[string]string
+
+		BeforeEach(func() {
+			saved = map[string]string{}
+			for _, name := range proxyEnvNames {
+				for _, n := range []string{name, strings.ToLower(name)} {
+					if v, ok := os.LookupEnv(n); ok {
+						saved[n] = v
+					}
+					Expect(os.Unsetenv(n)).To(Succeed())
+				}
+			}
+		})
+		AfterEach(func() {
+			for n, v := range saved {
+				Expect(os.Setenv(n, v)).To(Succeed())
+			}
+		})
+
+		It("should copy set proxy variables, preferring upper case names", func() {
+			Expect(os.Setenv("HTTPS_PROXY", "http://upper")).To(Succeed())
+			Expect(os.Setenv("https_proxy", "http://lower")).To(Succeed())
+			Expect(os.Setenv("no_proxy", "localhost")).To(Succeed())
+			Expect(ProxyEnv()).To(Equal([]string{"HTTPS_PROXY=http://upper", "NO_PROXY=localhost"}))
+			for _, n := range []string{"HTTPS_PROXY", "https_proxy", "no_proxy"} {
+				Expect(os.Unsetenv(n)).To(Succeed())
+			}
+		})
+	})
+})
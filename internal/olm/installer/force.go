@@ -0,0 +1,164 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	olmapiv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultForceGracePeriod is how long a forced uninstall waits for resources
+// to be deleted before releasing their finalizers.
+const DefaultForceGracePeriod = 30 * time.Second
+
+// releasableKinds are kinds of OLM objects whose finalizers a forced
+// uninstall releases.
+var releasableKinds = []schema.GroupVersionKind{
+	olmapiv1alpha1.SchemeGroupVersion.WithKind(olmapiv1alpha1.ClusterServiceVersionKind),
+	olmapiv1alpha1.SchemeGroupVersion.WithKind(olmapiv1alpha1.SubscriptionKind),
+	olmapiv1alpha1.SchemeGroupVersion.WithKind(olmapiv1alpha1.InstallPlanKind),
+	{Group: olmapiv1alpha1.GroupName, Version: "v1", Kind: "OperatorGroup"},
+}
+
+// forceDelete deletes resources without waiting on each, then waits
+// c.ForceGracePeriod for them to be deleted. Finalizers of releasableKinds
+// objects in namespaces created by resources are then removed, so stuck
+// objects and their namespaces can terminate. Objects in other namespaces
+// are never modified.
+func (c Client) forceDelete(ctx context.Context, resources []unstructured.Unstructured) error {
+	for i := range resources {
+		r := &resources[i]
+		log.Infof("  Deleting %s %q", r.GetKind(), getResourceName(r))
+		err := c.KubeClient.Delete(ctx, r, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !apierrors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			return err
+		}
+	}
+
+	grace := c.ForceGracePeriod
+	if grace <= 0 {
+		grace = DefaultForceGracePeriod
+	}
+	log.Infof("Waiting up to %s for resources to be deleted", grace)
+	graceCtx, cancel := context.WithTimeout(ctx, grace)
+	remaining, err := c.waitForDeletion(graceCtx, resources)
+	cancel()
+	if err != nil || len(remaining) == 0 {
+		return err
+	}
+
+	log.Infof("%d resources were not deleted, releasing finalizers of OLM objects", len(remaining))
+	for _, ns := range getNamespaces(resources) {
+		if err := c.releaseFinalizers(ctx, ns); err != nil {
+			return err
+		}
+	}
+	if remaining, err = c.waitForDeletion(ctx, remaining); err != nil {
+		return err
+	}
+	if len(remaining) != 0 {
+		var names []string
+		for _, r := range remaining {
+			names = append(names, fmt.Sprintf("%s %q", r.GetKind(), getResourceName(&r)))
+		}
+		return fmt.Errorf("timed out waiting for resources to be deleted: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// waitForDeletion returns the resources that still exist once all are
+// deleted or ctx is done.
+func (c Client) waitForDeletion(ctx context.Context, resources []unstructured.Unstructured) (remaining []unstructured.Unstructured, err error) {
+	remaining = resources
+	err = wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		var exist []unstructured.Unstructured
+		for _, r := range remaining {
+			u := unstructured.Unstructured{}
+			u.SetGroupVersionKind(r.GroupVersionKind())
+			err := c.KubeClient.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: r.GetName()}, &u)
+			if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+				continue
+			} else if err != nil {
+				return false, err
+			}
+			exist = append(exist, r)
+		}
+		remaining = exist
+		return len(remaining) == 0, nil
+	}, ctx.Done())
+	if err == wait.ErrWaitTimeout {
+		err = nil
+	}
+	return remaining, err
+}
+
+// releaseFinalizers removes finalizers from all releasableKinds objects in
+// namespace, logging each object released.
+func (c Client) releaseFinalizers(ctx context.Context, namespace string) error {
+	for _, gvk := range releasableKinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := c.KubeClient.List(ctx, list, client.InNamespace(namespace)); err != nil {
+			if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+				continue
+			}
+			return fmt.Errorf("failed to list %ss in namespace %q: %v", gvk.Kind, namespace, err)
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			finalizers := obj.GetFinalizers()
+			if len(finalizers) == 0 {
+				continue
+			}
+			patch := client.RawPatch(types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`))
+			if err := c.KubeClient.Patch(ctx, obj, patch); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to release %s %q: %v", gvk.Kind, getResourceName(obj), err)
+			}
+			log.Warnf("  Forcibly released %s %q by removing finalizers %s",
+				gvk.Kind, getResourceName(obj), strings.Join(finalizers, ", "))
+		}
+	}
+	return nil
+}
+
+// getNamespaces returns the names of Namespaces in resources.
+func getNamespaces(resources []unstructured.Unstructured) (namespaces []string) {
+	for _, r := range resources {
+		if r.GroupVersionKind() == (schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}) {
+			namespaces = append(namespaces, r.GetName())
+		}
+	}
+	return namespaces
+}
+
+func getResourceName(obj *unstructured.Unstructured) string {
+	if ns := obj.GetNamespace(); ns != "" {
+		return ns + "/" + obj.GetName()
+	}
+	return obj.GetName()
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	olmapiv1 "github.com/operator-framework/api/pkg/operators/v1"
+	olmapiv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	olmresourceclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+)
+
+var _ = Describe("Forced uninstall", func() {
+	const finalizer = "operators.coreos.com/stuck"
+
+	var c Client
+
+	newCSV := func(namespace string) *olmapiv1alpha1.ClusterServiceVersion {
+		csv := &olmapiv1alpha1.ClusterServiceVersion{}
+		csv.SetName("packageserver")
+		csv.SetNamespace(namespace)
+		csv.SetFinalizers([]string{finalizer})
+		return csv
+	}
+	getFinalizers := func(obj runtime.Object, namespace, name string) []string {
+		Expect(c.KubeClient.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, obj)).To(Succeed())
+		return obj.(interface{ GetFinalizers() []string }).GetFinalizers()
+	}
+
+	BeforeEach(func() {
+		sch := runtime.NewScheme()
+		Expect(corev1.AddToScheme(sch)).To(Succeed())
+		Expect(olmapiv1alpha1.AddToScheme(sch)).To(Succeed())
+		Expect(olmapiv1.AddToScheme(sch)).To(Succeed())
+
+		og := &olmapiv1.OperatorGroup{}
+		og.SetName("olm-operators")
+		og.SetNamespace("olm")
+		og.SetFinalizers([]string{finalizer})
+		sub := &olmapiv1alpha1.Subscription{}
+		sub.SetName("packageserver")
+		sub.SetNamespace("olm")
+		c = Client{Client: &olmresourceclient.Client{
+			KubeClient: fake.NewFakeClientWithScheme(sch, newCSV("olm"), newCSV("my-operator"), og, sub),
+		}}
+	})
+
+	It("should only release finalizers of OLM objects in the given namespace", func() {
+		Expect(c.releaseFinalizers(context.TODO(), "olm")).To(Succeed())
+		Expect(getFinalizers(&olmapiv1alpha1.ClusterServiceVersion{}, "olm", "packageserver")).To(BeEmpty())
+		Expect(getFinalizers(&olmapiv1.OperatorGroup{}, "olm", "olm-operators")).To(BeEmpty())
+		Expect(getFinalizers(&olmapiv1alpha1.ClusterServiceVersion{}, "my-operator", "packageserver")).
+			To(Equal([]string{finalizer}))
+	})
+	It("should find the namespaces OLM's manifests create", func() {
+		newResource := func(apiVersion, kind, name string) unstructured.Unstructured {
+			u := unstructured.Unstructured{}
+			u.SetAPIVersion(apiVersion)
+			u.SetKind(kind)
+			u.SetName(name)
+			return u
+		}
+		resources := []unstructured.Unstructured{
+			newResource("apiextensions.k8s.io/v1", "CustomResourceDefinition", "subscriptions.operators.coreos.com"),
+			newResource("v1", "Namespace", "olm"),
+			newResource("v1", "Namespace", "operators"),
+			newResource("v1", "ServiceAccount", "olm-operator-serviceaccount"),
+		}
+		Expect(getNamespaces(resources)).To(Equal([]string{"olm", "operators"}))
+	})
+	It("should not wait for resources that do not exist", func() {
+		csv := unstructured.Unstructured{}
+		csv.SetGroupVersionKind(olmapiv1alpha1.SchemeGroupVersion.WithKind(olmapiv1alpha1.ClusterServiceVersionKind))
+		csv.SetNamespace("olm")
+		csv.SetName("packageserver")
+		gone := csv.DeepCopy()
+		gone.SetName("gone")
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		remaining, err := c.waitForDeletion(ctx, []unstructured.Unstructured{csv, *gone})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(remaining).To(HaveLen(1))
+		Expect(remaining[0].GetName()).To(Equal("packageserver"))
+	})
+})
@@ -16,13 +16,22 @@ package installer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/blang/semver"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
+	"k8s.io/client-go/discovery"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	olmresourceclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+	"github.com/operator-framework/operator-sdk/internal/olm/snapshot"
 )
 
 const (
@@ -37,7 +46,36 @@ type Manager struct {
 	Version      string
 	Timeout      time.Duration
 	OLMNamespace string
-	once         sync.Once
+	// SHA256SumsFile, if set, is a sha256sum-formatted file that manifests
+	// are verified against instead of the checksums shipped with a release.
+	SHA256SumsFile string
+	// ManifestsDir, if set, is a local directory containing crds.yaml and
+	// olm.yaml of an OLM release, applied instead of downloaded manifests.
+	// Install records it, so Status and Uninstall default to it.
+	ManifestsDir string
+	// ImageMirror, if set, is a registry prefix that images in the OLM
+	// manifests are rewritten to be pulled from.
+	ImageMirror string
+	// Output, if set, is the format Status writes a StatusReport to stdout
+	// in instead of a table, one of: json, yaml.
+	Output string
+	// Force, if set, makes Uninstall remove finalizers of OLM objects in OLM's
+	// namespaces that are not deleted within ForceGracePeriod.
+	Force            bool
+	ForceGracePeriod time.Duration
+	// ComponentEnv contains KEY=VALUE pairs Install injects into the env of
+	// OLM's component Deployments.
+	ComponentEnv []string
+	// ProxyFromEnv, if set, makes Install inject the proxy variables of the
+	// current environment before ComponentEnv.
+	ProxyFromEnv bool
+	// PreserveCRDs, if set, makes Uninstall keep OLM's CRDs and the
+	// resources of those kinds.
+	PreserveCRDs bool
+	// Upgrade, if set, allows Install to replace an installed OLM version
+	// with Version.
+	Upgrade bool
+	once    sync.Once
 }
 
 func (m *Manager) initialize() (err error) {
@@ -59,21 +97,116 @@ func (m *Manager) initialize() (err error) {
 		if m.Timeout <= 0 {
 			m.Timeout = DefaultTimeout
 		}
-		if m.OLMNamespace == "" {
-			m.OLMNamespace = DefaultOLMNamespace
+		if m.ManifestsDir != "" {
+			if err = checkManifestsDir(m.ManifestsDir); err != nil {
+				return
+			}
+			// Record an absolute path, so status and uninstall find it from any directory.
+			if m.Client.ManifestsDir, err = filepath.Abs(m.ManifestsDir); err != nil {
+				err = fmt.Errorf("invalid OLM manifests directory: %v", err)
+				return
+			}
+		}
+		m.Client.ImageMirror = m.ImageMirror
+		m.Client.Force, m.Client.ForceGracePeriod = m.Force, m.ForceGracePeriod
+		m.Client.Upgrade = m.Upgrade
+		m.Client.PreserveCRDs = m.PreserveCRDs
+		if m.SHA256SumsFile != "" {
+			if m.Client.Checksums, err = ReadChecksumsFile(m.SHA256SumsFile); err != nil {
+				return
+			}
 		}
 	})
 	return err
 }
 
+// resolveNamespace sets m.OLMNamespace, if unset, to the namespace recorded by
+// Install, or DefaultOLMNamespace if none was recorded.
+func (m *Manager) resolveNamespace(ctx context.Context) error {
+	if m.OLMNamespace != "" {
+		return nil
+	}
+	ns, err := m.Client.GetRecordedNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	if ns == "" {
+		ns = DefaultOLMNamespace
+	}
+	m.OLMNamespace = ns
+	return nil
+}
+
+// resolveManifestsDir sets the directory OLM manifests are read from, if unset,
+// to the directory recorded by Install, so status and uninstall of OLM installed
+// without network access do not download manifests.
+func (m *Manager) resolveManifestsDir(ctx context.Context) error {
+	if m.Client.ManifestsDir != "" {
+		return nil
+	}
+	dir, err := m.Client.GetRecordedManifestsDir(ctx)
+	if err != nil || dir == "" {
+		return err
+	}
+	if err := checkManifestsDir(dir); err != nil {
+		return fmt.Errorf("OLM was installed from manifests directory %s (set --olm-manifests-dir to override it): %v", dir, err)
+	}
+	log.Infof("Reading OLM manifests from %s, which OLM was installed from", dir)
+	m.Client.ManifestsDir = dir
+	return nil
+}
+
+// checkManifestsDir returns an error if dir is not a directory.
+func checkManifestsDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err == nil && !info.IsDir() {
+		err = fmt.Errorf("%s is not a directory", dir)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid OLM manifests directory: %v", err)
+	}
+	return nil
+}
+
+// validateVersion returns an error if version is neither DefaultVersion nor
+// an exact release version, ex. "0.15.1".
+func validateVersion(version string) error {
+	if version == DefaultVersion {
+		return nil
+	}
+	if _, err := semver.Parse(strings.TrimPrefix(version, "v")); err != nil {
+		return fmt.Errorf("invalid version %q: must be %q or an exact release version, ex. 0.15.1", version, DefaultVersion)
+	}
+	return nil
+}
+
 func (m *Manager) Install() error {
+	if err := validateVersion(m.Version); err != nil {
+		return err
+	}
+	pairs := m.ComponentEnv
+	if m.ProxyFromEnv {
+		proxy := ProxyEnv()
+		if len(proxy) == 0 {
+			log.Warnf("No proxy variables (%s) are set in the current environment", strings.Join(proxyEnvNames, ", "))
+		}
+		pairs = append(proxy, pairs...)
+	}
+	env, err := ParseComponentEnv(pairs)
+	if err != nil {
+		return err
+	}
 	if err := m.initialize(); err != nil {
 		return err
 	}
+	m.Client.ComponentEnv = env
 
 	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
 	defer cancel()
 
+	if m.OLMNamespace == "" {
+		m.OLMNamespace = DefaultOLMNamespace
+	}
 	status, err := m.Client.InstallVersion(ctx, m.OLMNamespace, m.Version)
 	if err != nil {
 		return err
@@ -93,6 +226,12 @@ func (m *Manager) Uninstall() error {
 	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
 	defer cancel()
 
+	if err := m.resolveNamespace(ctx); err != nil {
+		return err
+	}
+	if err := m.resolveManifestsDir(ctx); err != nil {
+		return err
+	}
 	if version, err := m.Client.GetInstalledVersion(ctx, m.OLMNamespace); err != nil {
 		if m.Version == "" {
 			return fmt.Errorf("error getting installed OLM version (set --version to override the default version): %v", err)
@@ -114,6 +253,9 @@ func (m *Manager) Uninstall() error {
 }
 
 func (m *Manager) Status() error {
+	if err := olmresourceclient.ValidateOutputFormat(m.Output); err != nil {
+		return err
+	}
 	if err := m.initialize(); err != nil {
 		return err
 	}
@@ -121,9 +263,15 @@ func (m *Manager) Status() error {
 	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
 	defer cancel()
 
+	if err := m.resolveNamespace(ctx); err != nil {
+		return err
+	}
+	if err := m.resolveManifestsDir(ctx); err != nil {
+		return err
+	}
 	if version, err := m.Client.GetInstalledVersion(ctx, m.OLMNamespace); err != nil {
 		if m.Version == "" {
-			return fmt.Errorf("error getting installed OLM version (set --version to override the default version): %v", err)
+			return fmt.Errorf("error getting installed OLM version (set --version to override the default version): %w", err)
 		}
 	} else if m.Version != "" {
 		if version != m.Version {
@@ -134,13 +282,62 @@ func (m *Manager) Status() error {
 	}
 
 	status, err := m.Client.GetStatus(ctx, m.OLMNamespace, m.Version)
-	if err != nil {
+	if err != nil && !errors.Is(err, olmresourceclient.ErrOLMNotInstalled) {
 		return err
 	}
 
-	log.Infof("Successfully got OLM status for version %q", m.Version)
-	fmt.Print("\n")
-	fmt.Println(status)
+	report := status.Report(m.Version)
+	if m.Output != "" {
+		if err := report.Write(os.Stdout, m.Output); err != nil {
+			return err
+		}
+	} else {
+		if report.Healthy {
+			log.Infof("Successfully got OLM status for version %q", m.Version)
+		}
+		fmt.Print("\n")
+		fmt.Println(report)
+	}
+	if !report.Healthy {
+		return &olmresourceclient.UnhealthyError{Report: report}
+	}
+	return nil
+}
+
+func (m *Manager) Snapshot(path string) error {
+	if err := m.initialize(); err != nil {
+		return err
+	}
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get Kubernetes config: %v", err)
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create discovery client: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
+	defer cancel()
+
+	s, err := snapshot.Take(ctx, m.Client.KubeClient, dc, snapshot.DefaultMaxSize)
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		return s.Write(os.Stdout, snapshot.DefaultMaxSize)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := s.Write(f, snapshot.DefaultMaxSize); err != nil {
+		// Do not leave an empty snapshot behind.
+		_ = os.Remove(path)
+		return err
+	}
+	log.Infof("Wrote snapshot of %d objects to %s", len(s.Objects), path)
 	return nil
 }
 
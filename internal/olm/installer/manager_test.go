@@ -0,0 +1,80 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	olmresourceclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+)
+
+var _ = Describe("Manager", func() {
+	Describe("resolveManifestsDir", func() {
+		var (
+			m   *Manager
+			dir string
+		)
+
+		newManager := func(recorded string) *Manager {
+			crd := &unstructured.Unstructured{}
+			crd.SetAPIVersion("apiextensions.k8s.io/v1")
+			crd.SetKind("CustomResourceDefinition")
+			crd.SetName("clusterserviceversions.operators.coreos.com")
+			if recorded != "" {
+				crd.SetAnnotations(map[string]string{olmresourceclient.OLMManifestsDirAnnotation: recorded})
+			}
+			return &Manager{Client: &Client{Client: &olmresourceclient.Client{KubeClient: fake.NewFakeClient(crd)}}}
+		}
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "olm-manifests-")
+			Expect(err).NotTo(HaveOccurred())
+		})
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("should use the directory recorded at install", func() {
+			m = newManager(dir)
+			Expect(m.resolveManifestsDir(context.TODO())).To(Succeed())
+			Expect(m.Client.ManifestsDir).To(Equal(dir))
+		})
+		It("should prefer a directory that is already set", func() {
+			m = newManager(dir)
+			m.Client.ManifestsDir = filepath.Join(dir, "override")
+			Expect(m.resolveManifestsDir(context.TODO())).To(Succeed())
+			Expect(m.Client.ManifestsDir).To(Equal(filepath.Join(dir, "override")))
+		})
+		It("should download manifests if no directory was recorded", func() {
+			m = newManager("")
+			Expect(m.resolveManifestsDir(context.TODO())).To(Succeed())
+			Expect(m.Client.ManifestsDir).To(BeEmpty())
+		})
+		It("should fail if the recorded directory does not exist", func() {
+			m = newManager(filepath.Join(dir, "missing"))
+			err := m.resolveManifestsDir(context.TODO())
+			Expect(err).To(MatchError(ContainSubstring("set --olm-manifests-dir to override it")))
+		})
+	})
+})
@@ -0,0 +1,75 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"regexp"
+	"strings"
+)
+
+// imageArgRegexp matches container arguments that pass an image reference,
+// ex. "-configmapServerImage=quay.io/operator-framework/configmap-operator-registry:latest".
+var imageArgRegexp = regexp.MustCompile(`^(--?[A-Za-z-]*[iI]mage=)(.+)$`)
+
+// mirrorImages rewrites, in place, every image reference in obj against
+// mirror: "image" fields, such as those of containers and catalog sources,
+// and image arguments to containers.
+func mirrorImages(obj interface{}, mirror string) {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			switch s := value.(type) {
+			case string:
+				if key == "image" {
+					v[key] = mirrorImage(s, mirror)
+				}
+			case []interface{}:
+				if key == "args" || key == "command" {
+					for i, arg := range s {
+						if str, ok := arg.(string); ok {
+							if m := imageArgRegexp.FindStringSubmatch(str); m != nil {
+								s[i] = m[1] + mirrorImage(m[2], mirror)
+							}
+						}
+					}
+				}
+				mirrorImages(s, mirror)
+			default:
+				mirrorImages(value, mirror)
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			mirrorImages(e, mirror)
+		}
+	}
+}
+
+// mirrorImage replaces ref's registry with mirror, ex. for mirror
+// "registry.example.com/olm", "quay.io/operator-framework/olm@sha256:abc"
+// becomes "registry.example.com/olm/operator-framework/olm@sha256:abc".
+// Digests are kept, so mirrored images must be copied by digest.
+func mirrorImage(ref, mirror string) string {
+	if ref == "" {
+		return ref
+	}
+	path := ref
+	if i := strings.Index(ref, "/"); i >= 0 {
+		if domain := ref[:i]; strings.ContainsAny(domain, ".:") || domain == "localhost" {
+			path = ref[i+1:]
+		}
+	}
+	return strings.TrimSuffix(mirror, "/") + "/" + path
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("mirrorImage", func() {
+	const mirror = "registry.example.com/olm"
+
+	It("should replace an image's registry", func() {
+		Expect(mirrorImage("quay.io/operator-framework/olm@sha256:abc", mirror)).
+			To(Equal("registry.example.com/olm/operator-framework/olm@sha256:abc"))
+		Expect(mirrorImage("localhost:5000/olm:latest", mirror+"/")).To(Equal("registry.example.com/olm/olm:latest"))
+	})
+	It("should prefix images without a registry", func() {
+		Expect(mirrorImage("operator-framework/olm:latest", mirror)).
+			To(Equal("registry.example.com/olm/operator-framework/olm:latest"))
+		Expect(mirrorImage("busybox", mirror)).To(Equal("registry.example.com/olm/busybox"))
+	})
+})
+
+var _ = Describe("mirrorImages", func() {
+	It("should rewrite container images, image arguments, and catalog source images", func() {
+		deployment := map[string]interface{}{
+			"kind": "Deployment",
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{
+								"image": "quay.io/operator-framework/olm@sha256:abc",
+								"args": []interface{}{
+									"-namespace", "olm",
+									"-configmapServerImage=quay.io/operator-framework/configmap-operator-registry:latest",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		catsrc := map[string]interface{}{
+			"kind": "CatalogSource",
+			"spec": map[string]interface{}{"image": "quay.io/operatorhubio/catalog:latest"},
+		}
+		mirrorImages(deployment, "registry.example.com")
+		mirrorImages(catsrc, "registry.example.com")
+
+		u := unstructured.Unstructured{Object: deployment}
+		containers, _, err := unstructured.NestedSlice(u.Object, "spec", "template", "spec", "containers")
+		Expect(err).NotTo(HaveOccurred())
+		container := containers[0].(map[string]interface{})
+		Expect(container["image"]).To(Equal("registry.example.com/operator-framework/olm@sha256:abc"))
+		Expect(container["args"]).To(Equal([]interface{}{
+			"-namespace", "olm",
+			"-configmapServerImage=registry.example.com/operator-framework/configmap-operator-registry:latest",
+		}))
+		image, _, err := unstructured.NestedString(catsrc, "spec", "image")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(image).To(Equal("registry.example.com/operatorhubio/catalog:latest"))
+	})
+})
@@ -0,0 +1,104 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// namespaceArgs are container arguments of OLM components whose value is
+// OLM's namespace.
+var namespaceArgs = map[string]bool{
+	"-namespace": true, "--namespace": true,
+	"-global-namespace": true, "--global-namespace": true,
+}
+
+// setNamespace moves resources from OLM release manifests from namespace from
+// to namespace to, by renaming the Namespace itself and rewriting object
+// namespaces, RBAC subjects, OperatorGroup target namespaces, APIService
+// service references, and container namespace arguments.
+func setNamespace(resources []unstructured.Unstructured, from, to string) {
+	for i := range resources {
+		r := &resources[i]
+		if r.GetKind() == "Namespace" && r.GetName() == from {
+			r.SetName(to)
+		}
+		if r.GetNamespace() == from {
+			r.SetNamespace(to)
+		}
+		switch r.GetKind() {
+		case "ClusterRoleBinding", "RoleBinding":
+			subjects, _, _ := unstructured.NestedSlice(r.Object, "subjects")
+			for _, s := range subjects {
+				if subject, ok := s.(map[string]interface{}); ok && subject["namespace"] == from {
+					subject["namespace"] = to
+				}
+			}
+			if subjects != nil {
+				_ = unstructured.SetNestedSlice(r.Object, subjects, "subjects")
+			}
+		case "OperatorGroup":
+			targets, found, _ := unstructured.NestedStringSlice(r.Object, "spec", "targetNamespaces")
+			for j, t := range targets {
+				if t == from {
+					targets[j] = to
+				}
+			}
+			if found {
+				_ = unstructured.SetNestedStringSlice(r.Object, targets, "spec", "targetNamespaces")
+			}
+		case "APIService":
+			if ns, _, _ := unstructured.NestedString(r.Object, "spec", "service", "namespace"); ns == from {
+				_ = unstructured.SetNestedField(r.Object, to, "spec", "service", "namespace")
+			}
+		}
+		setNamespaceArgs(r.Object, from, to)
+	}
+}
+
+// setNamespaceArgs rewrites namespaceArgs with value from to to in all
+// container "args" and "command" lists in obj, as either "<arg> <value>" or
+// "<arg>=<value>".
+func setNamespaceArgs(obj interface{}, from, to string) {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if args, ok := value.([]interface{}); ok && (key == "args" || key == "command") {
+				for j, arg := range args {
+					str, ok := arg.(string)
+					if !ok {
+						continue
+					}
+					var prev string
+					if j > 0 {
+						prev, _ = args[j-1].(string)
+					}
+					if str == from && namespaceArgs[prev] {
+						args[j] = to
+					} else if split := strings.SplitN(str, "=", 2); len(split) == 2 && namespaceArgs[split[0]] && split[1] == from {
+						args[j] = split[0] + "=" + to
+					}
+				}
+			}
+			setNamespaceArgs(value, from, to)
+		}
+	case []interface{}:
+		for _, e := range v {
+			setNamespaceArgs(e, from, to)
+		}
+	}
+}
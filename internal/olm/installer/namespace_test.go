@@ -0,0 +1,183 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	olmapiv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	olmresourceclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+)
+
+const testNamespacedOLM = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: olm
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: operators
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: olm-operator-binding-olm
+subjects:
+- kind: ServiceAccount
+  name: olm-operator-serviceaccount
+  namespace: olm
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: catalog-operator
+  namespace: olm
+spec:
+  template:
+    spec:
+      containers:
+      - name: catalog-operator
+        args:
+        - -namespace
+        - olm
+        - -configmapServerImage=quay.io/operator-framework/configmap-operator-registry:latest
+---
+apiVersion: operators.coreos.com/v1
+kind: OperatorGroup
+metadata:
+  name: olm-operators
+  namespace: olm
+spec:
+  targetNamespaces:
+  - olm
+---
+apiVersion: operators.coreos.com/v1
+kind: OperatorGroup
+metadata:
+  name: global-operators
+  namespace: operators
+---
+apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: packageserver
+  namespace: olm
+spec:
+  install:
+    spec:
+      deployments:
+      - name: packageserver
+        spec:
+          template:
+            spec:
+              containers:
+              - name: packageserver
+                command:
+                - /bin/package-server
+                - --global-namespace=olm
+---
+apiVersion: apiregistration.k8s.io/v1
+kind: APIService
+metadata:
+  name: v1.packages.operators.coreos.com
+spec:
+  service:
+    name: v1-packages-operators-coreos-com
+    namespace: olm
+`
+
+var _ = Describe("setNamespace", func() {
+	It("should move OLM's components to another namespace", func() {
+		resources, err := decodeResources(strings.NewReader(testNamespacedOLM))
+		Expect(err).NotTo(HaveOccurred())
+		setNamespace(resources, DefaultOLMNamespace, "platform-olm")
+
+		b, err := yaml.Marshal(resources)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resources[0].GetName()).To(Equal("platform-olm"))
+		Expect(resources[1].GetName()).To(Equal("operators"))
+		subjects, _, _ := unstructured.NestedSlice(resources[2].Object, "subjects")
+		Expect(subjects[0]).To(HaveKeyWithValue("namespace", "platform-olm"))
+		Expect(resources[3].GetNamespace()).To(Equal("platform-olm"))
+		containers, _, _ := unstructured.NestedSlice(resources[3].Object, "spec", "template", "spec", "containers")
+		Expect(containers[0]).To(HaveKeyWithValue("args", []interface{}{
+			"-namespace", "platform-olm",
+			"-configmapServerImage=quay.io/operator-framework/configmap-operator-registry:latest",
+		}))
+		targets, _, _ := unstructured.NestedStringSlice(resources[4].Object, "spec", "targetNamespaces")
+		Expect(targets).To(Equal([]string{"platform-olm"}))
+		Expect(resources[5].GetNamespace()).To(Equal("operators"))
+		Expect(resources[6].GetNamespace()).To(Equal("platform-olm"))
+		Expect(string(b)).To(ContainSubstring("--global-namespace=platform-olm"))
+		ns, _, _ := unstructured.NestedString(resources[7].Object, "spec", "service", "namespace")
+		Expect(ns).To(Equal("platform-olm"))
+	})
+})
+
+var _ = Describe("checkConflicts", func() {
+	var c Client
+
+	BeforeEach(func() {
+		csv := &olmapiv1alpha1.ClusterServiceVersion{}
+		csv.SetName("packageserver")
+		csv.SetNamespace("olm")
+		csv.SetLabels(map[string]string{"olm.version": "0.15.1"})
+		crd := &unstructured.Unstructured{}
+		crd.SetAPIVersion("apiextensions.k8s.io/v1")
+		crd.SetKind("CustomResourceDefinition")
+		crd.SetName("clusterserviceversions.operators.coreos.com")
+		crd.SetAnnotations(map[string]string{olmresourceclient.OLMNamespaceAnnotation: "olm"})
+		c = Client{Client: &olmresourceclient.Client{
+			KubeClient: fake.NewFakeClientWithScheme(newTestScheme(), csv, crd),
+		}}
+	})
+
+	It("should fail if OLM is installed in another namespace", func() {
+		_, err := c.checkConflicts(context.TODO(), "platform-olm", "0.15.1", nil)
+		Expect(err).To(MatchError(`OLM is already installed in namespace "olm": ` +
+			`uninstall it before installing OLM in namespace "platform-olm"`))
+	})
+	It("should fail if another version of OLM is installed in the namespace", func() {
+		_, err := c.checkConflicts(context.TODO(), "olm", "0.16.0", nil)
+		Expect(err).To(MatchError(ContainSubstring(`namespace "olm" already hosts OLM version "0.15.1", ` +
+			`which conflicts with version "0.16.0": set --upgrade to upgrade it`)))
+	})
+	It("should return the installed version if upgrading", func() {
+		c.Upgrade = true
+		previous, err := c.checkConflicts(context.TODO(), "olm", "0.16.0", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(previous).To(Equal("0.15.1"))
+	})
+	It("should not fail for the installed version", func() {
+		previous, err := c.checkConflicts(context.TODO(), "olm", "v0.15.1", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(previous).To(Equal("0.15.1"))
+	})
+})
+
+func newTestScheme() *runtime.Scheme {
+	sch := runtime.NewScheme()
+	Expect(olmapiv1alpha1.AddToScheme(sch)).To(Succeed())
+	return sch
+}
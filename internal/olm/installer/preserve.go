@@ -0,0 +1,59 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	olmresourceclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+)
+
+// splitCRDs splits OLM's CRDs from the rest of resources, and adds the package
+// server APIService to the rest. The APIService is normally garbage collected
+// by OLM after the package server CSV is deleted, but OLM will not be running
+// to do so.
+func splitCRDs(resources []unstructured.Unstructured) (crds, others []unstructured.Unstructured) {
+	for _, r := range resources {
+		if r.GetKind() == "CustomResourceDefinition" {
+			crds = append(crds, r)
+		} else {
+			others = append(others, r)
+		}
+	}
+	return crds, append(others, *olmresourceclient.NewPackageServerAPIService())
+}
+
+// releaseCRDs removes the version, namespace, and manifests directory recorded
+// by InstallVersion from crds, so a later installation of any version in any namespace adopts
+// them rather than conflicting with the uninstalled one.
+func (c Client) releaseCRDs(ctx context.Context, crds []unstructured.Unstructured) error {
+	patch := client.RawPatch(types.MergePatchType, []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:null,%q:null,%q:null}}}`,
+		olmresourceclient.OLMVersionAnnotation, olmresourceclient.OLMNamespaceAnnotation, olmresourceclient.OLMManifestsDirAnnotation)))
+	for i := range crds {
+		crd := crds[i].DeepCopy()
+		log.Infof("  Preserving %s %q", crd.GetKind(), crd.GetName())
+		if err := c.KubeClient.Patch(ctx, crd, patch); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to release CRD %q: %v", crd.GetName(), err)
+		}
+	}
+	return nil
+}
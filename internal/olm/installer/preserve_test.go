@@ -0,0 +1,148 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	olmapiv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	olmresourceclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+)
+
+const testPreserveCRDs = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: subscriptions.operators.coreos.com
+`
+
+const testPreserveOLM = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: olm
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: olm-operator-serviceaccount
+  namespace: olm
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: olm-operator
+  namespace: olm
+`
+
+var _ = Describe("Uninstalling with preserved CRDs", func() {
+	var (
+		c         Client
+		dir       string
+		resources []unstructured.Unstructured
+	)
+
+	getKey := func(r unstructured.Unstructured) types.NamespacedName {
+		return types.NamespacedName{Namespace: r.GetNamespace(), Name: r.GetName()}
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "olm-manifests-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(dir, crdsFile), []byte(testPreserveCRDs), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, olmFile), []byte(testPreserveOLM), 0644)).To(Succeed())
+
+		sch := runtime.NewScheme()
+		Expect(corev1.AddToScheme(sch)).To(Succeed())
+		Expect(appsv1.AddToScheme(sch)).To(Succeed())
+		Expect(olmapiv1alpha1.AddToScheme(sch)).To(Succeed())
+		sub := &olmapiv1alpha1.Subscription{}
+		sub.SetName("memcached-operator")
+		sub.SetNamespace("my-operator")
+		c = Client{
+			Client:       &olmresourceclient.Client{KubeClient: fake.NewFakeClientWithScheme(sch, sub)},
+			ManifestsDir: dir,
+			PreserveCRDs: true,
+		}
+
+		resources, err = c.getResources(context.TODO(), DefaultOLMNamespace, "0.15.1")
+		Expect(err).NotTo(HaveOccurred())
+		setVersionAnnotation(resources, "0.15.1")
+		for _, r := range resources {
+			if r.GetKind() == "CustomResourceDefinition" {
+				r.SetAnnotations(map[string]string{
+					olmresourceclient.OLMVersionAnnotation:      "0.15.1",
+					olmresourceclient.OLMNamespaceAnnotation:    DefaultOLMNamespace,
+					olmresourceclient.OLMManifestsDirAnnotation: dir,
+				})
+			}
+			Expect(c.KubeClient.Create(context.TODO(), r.DeepCopy())).To(Succeed())
+		}
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("should keep CRDs and Subscriptions through an uninstall and reinstall", func() {
+		Expect(c.UninstallVersion(context.TODO(), DefaultOLMNamespace, "0.15.1")).To(Succeed())
+
+		for _, r := range resources {
+			existing := &unstructured.Unstructured{}
+			existing.SetGroupVersionKind(r.GroupVersionKind())
+			err := c.KubeClient.Get(context.TODO(), getKey(r), existing)
+			if r.GetKind() == "CustomResourceDefinition" {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(existing.GetAnnotations()).NotTo(HaveKey(olmresourceclient.OLMVersionAnnotation))
+				Expect(existing.GetAnnotations()).NotTo(HaveKey(olmresourceclient.OLMNamespaceAnnotation))
+				Expect(existing.GetAnnotations()).NotTo(HaveKey(olmresourceclient.OLMManifestsDirAnnotation))
+			} else {
+				Expect(apierrors.IsNotFound(err)).To(BeTrue(), "%s %s was not deleted", r.GetKind(), r.GetName())
+			}
+		}
+		sub := &olmapiv1alpha1.Subscription{}
+		subKey := types.NamespacedName{Namespace: "my-operator", Name: "memcached-operator"}
+		Expect(c.KubeClient.Get(context.TODO(), subKey, sub)).To(Succeed())
+
+		// Installing any version in any namespace adopts the preserved CRDs.
+		resources, err := c.getResources(context.TODO(), "platform-olm", "0.16.0")
+		Expect(err).NotTo(HaveOccurred())
+		previous, err := c.checkConflicts(context.TODO(), "platform-olm", "0.16.0", resources)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(previous).To(BeEmpty())
+		setVersionAnnotation(resources, "0.16.0")
+		Expect(c.DoApply(context.TODO(), toObjects(resources...)...)).To(Succeed())
+		Expect(c.KubeClient.Get(context.TODO(), subKey, sub)).To(Succeed())
+	})
+	It("should delete CRDs without it", func() {
+		c.PreserveCRDs = false
+		Expect(c.UninstallVersion(context.TODO(), DefaultOLMNamespace, "0.15.1")).To(Succeed())
+		crd := &unstructured.Unstructured{}
+		crd.SetGroupVersionKind(resources[0].GroupVersionKind())
+		err := c.KubeClient.Get(context.TODO(), getKey(resources[0]), crd)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
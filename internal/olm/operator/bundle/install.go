@@ -20,19 +20,44 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	apimanifests "github.com/operator-framework/api/pkg/manifests"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-registry/pkg/image/containerdregistry"
 	registrybundle "github.com/operator-framework/operator-registry/pkg/lib/bundle"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 
 	"github.com/operator-framework/operator-sdk/internal/olm/operator"
 	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/breaker"
 	registryutil "github.com/operator-framework/operator-sdk/internal/registry"
 )
 
 type Install struct {
 	BundleImage string
+	// AdditionalBundleImages are bundles of other packages the operator depends on,
+	// served by the same catalog. Only BundleImage's package is subscribed to, and
+	// OLM installs the additional bundles when resolving its dependencies.
+	AdditionalBundleImages []string
+	// CatalogSource, if set, is an existing CatalogSource of the form name[/namespace]
+	// to subscribe to instead of creating a catalog serving BundleImage.
+	CatalogSource string
+	// CreateNamespace creates the install namespace, labeled so cleanup
+	// --delete-namespace deletes it, if it does not exist.
+	CreateNamespace bool
+	// DiagnosticsDir is the directory diagnostics of a failed install are written to.
+	// Defaults to registry.DefaultDiagnosticsDir. Diagnostics are not collected if
+	// NoDiagnostics is set.
+	DiagnosticsDir string
+	NoDiagnostics  bool
+	// RegistryBudget and RegistryFailureThreshold bound the SDK's pulls of
+	// BundleImage and AdditionalBundleImages from each registry host, so a slow
+	// or failing registry fails the install instead of stalling it. Default to
+	// breaker.DefaultBudget and breaker.DefaultFailureThreshold.
+	RegistryBudget           time.Duration
+	RegistryFailureThreshold int
 
 	*registry.IndexImageCatalogCreator
 	*registry.OperatorInstaller
@@ -53,47 +78,306 @@ func NewInstall(cfg *operator.Configuration) Install {
 const defaultIndexImage = "quay.io/operator-framework/upstream-opm-builder:latest"
 
 func (i *Install) BindFlags(fs *pflag.FlagSet) {
-	fs.StringVar(&i.IndexImage, "index-image", defaultIndexImage, "index image in which to inject bundle")
+	fs.StringVar(&i.IndexImage, "index-image", defaultIndexImage, "index image in which to inject bundle. "+
+		"The catalog serves the index's existing packages as well as the bundle, which replaces "+
+		"a channel head of the same name")
+	fs.StringVar(&i.CatalogMode, "catalog-mode", "", "format of the catalog served by the registry pod, "+
+		"one of [sqlite, fbc]. In fbc mode the bundle is rendered into a file-based catalog served by 'opm serve'. "+
+		"Detected from the index image's labels if unset")
+	fs.StringVar(&i.RegistryImage, "registry-image", "",
+		"Image the registry pod runs opm from to serve the catalog, ex. a mirrored or newer opm image. "+
+			"The index image's catalog is copied into the registry pod by an init container. "+
+			"The registry pod fails with an explanation if the image's opm does not support the catalog mode. "+
+			"Defaults to the index image")
 	fs.Var(&i.InstallMode, "install-mode", "install mode")
+	fs.Var(&i.WatchNamespaces, "watch-namespaces", operator.WatchNamespacesUsage)
+	fs.Var(operator.NewResourceRequirementsValue(&i.RegistryResources), "registry-resources",
+		"Resource requests and limits of the registry pod container, "+
+			"ex. \"requests.cpu=100m,requests.memory=128Mi,limits.memory=512Mi\"")
+	fs.Var(&i.SecurityContextConfig, "security-context-config", operator.SecurityContextConfigUsage)
+	fs.StringVar(&i.PullSecretName, "pull-secret-name", "",
+		"Name of a kubernetes.io/dockerconfigjson Secret in the install namespace used to pull private index "+
+			"and bundle images. It is attached to the registry pod and set in the CatalogSource's secrets, "+
+			"which OLM uses to pull the bundle when unpacking it")
+	fs.StringVar(&i.CASecretName, "ca-secret-name", "",
+		"Name of a Secret in the install namespace whose cert.pem key contains PEM-encoded CA certificates, "+
+			"trusted by the registry pod when pulling bundle images from registries using them. "+
+			"Index images, and bundles unpacked by OLM, are pulled by the cluster's nodes, which must trust the CA themselves. "+
+			"Can't be used with --skip-tls-verify or --use-http")
+	fs.BoolVar(&i.SkipTLSVerify, "skip-tls-verify", false,
+		"Skip TLS certificate verification when the SDK and registry pod pull bundle and index images. "+
+			"Can't be used with --ca-secret-name. Images pulled by the cluster's nodes are verified as configured on the nodes")
+	fs.BoolVar(&i.UseHTTP, "use-http", false,
+		"Pull bundle and index images over plain HTTP in the SDK and registry pod, ex. from a local registry. "+
+			"Can't be used with --ca-secret-name. The cluster's nodes must be configured to pull from the registry "+
+			"over HTTP to start the registry pod and OLM's bundle unpack jobs")
+	fs.StringArrayVar(&i.AdditionalBundleImages, "additional-bundle", nil,
+		"Bundle image of another package the operator depends on, added to the catalog with the bundle. "+
+			"The operator's package is subscribed to, and OLM installs the additional bundle when resolving its "+
+			"dependencies. All resulting CSVs are waited for and deleted on cleanup. This flag can be repeated")
+	fs.StringVar(&i.CatalogSource, "catalog-source", "",
+		"Existing CatalogSource to subscribe to, of the form name[/namespace], instead of creating a catalog "+
+			"serving the bundle. The namespace defaults to the install namespace. The CatalogSource must be ready "+
+			"and serve the bundle's package, channel, and CSV; it is not deleted on cleanup. --index-image is ignored")
+	fs.StringVar(&i.ServiceAccountName, "service-account", "",
+		"Name of an existing ServiceAccount in the install namespace the registry pod runs as. "+
+			"Defaults to the "+operator.SDKRegistryServiceAccountName+" ServiceAccount, which is created without "+
+			"any RBAC and deleted on cleanup. OLM's bundle unpack jobs run as ServiceAccounts configured by OLM")
+	fs.Var(operator.NewNodeSelectorValue(&i.RegistryNodeSelector), "registry-pod-node-selector",
+		"Node selector of the registry pod, as <key>=<value> label pairs, ex. \"node-role.kubernetes.io/infra=\". "+
+			"This flag can be repeated. "+operator.RegistrySchedulingUsage)
+	fs.Var(operator.NewTolerationsFileValue(&i.RegistryTolerations), "registry-pod-tolerations-file",
+		"YAML file containing a list of tolerations of the registry pod, as in a pod spec, ex. to schedule it "+
+			"on tainted nodes. "+operator.RegistrySchedulingUsage)
+	fs.Var(operator.NewLabelsValue(&i.cfg.Labels), "label",
+		"Label added to every object run bundle creates, as <key>=<value>, ex. to satisfy admission policies. "+
+			"This flag can be repeated. Labels operator-sdk finds its objects by can't be set. "+
+			"Objects OLM creates, ex. bundle unpack jobs, are not labeled")
+	fs.Var(operator.NewAnnotationsValue(&i.cfg.Annotations), "annotation",
+		"Annotation added to every object run bundle creates, as <key>=<value>. This flag can be repeated. "+
+			"Annotations operator-sdk sets on an object take precedence. Objects OLM creates are not annotated")
+	fs.BoolVar(&i.CreateNamespace, "create-namespace", false,
+		"Create the install namespace if it does not exist. Namespaces created this way are labeled "+
+			"so that 'cleanup --delete-namespace' deletes them; existing namespaces are left unchanged")
+	fs.StringVar(&i.DiagnosticsDir, "diagnostics-dir", "",
+		"Directory to write diagnostics to if the install fails: registry and bundle unpack pod logs, "+
+			"Subscription, CSV, InstallPlan, and CatalogSource YAML, and namespace Events. "+
+			"Defaults to ./operator-sdk-diagnostics-<timestamp>")
+	fs.BoolVar(&i.NoDiagnostics, "no-diagnostics", false, "Do not collect diagnostics if the install fails")
+	fs.DurationVar(&i.RegistryBudget, "registry-budget", breaker.DefaultBudget,
+		"Total time pulling bundle images from a single registry may take. Once exceeded, bundle images "+
+			"remaining to be pulled from that registry are skipped and the install fails")
+	fs.IntVar(&i.RegistryFailureThreshold, "registry-failure-threshold", breaker.DefaultFailureThreshold,
+		"Number of failed bundle image pulls from a single registry after which bundle images remaining "+
+			"to be pulled from that registry are skipped and the install fails")
 	fs.StringVar(&i.InjectBundleMode, "mode", "", "mode to use for adding bundle to index")
 	_ = fs.MarkHidden("mode")
+	i.OperatorInstaller.BindStageTimeoutFlags(fs)
+}
+
+// Validate returns an error describing each of i's option rules that are violated.
+func (i Install) Validate() error {
+	return i.OptionRules().Validate()
+}
+
+// OptionRules returns the rules constraining i's fields, including those of
+// its embedded structs.
+func (i Install) OptionRules() operator.OptionRules {
+	catalogSource := operator.StringOption("CatalogSource", "--catalog-source", &i.CatalogSource)
+	rules := operator.OptionRules{
+		Rules: []operator.OptionRule{
+			operator.Required(operator.StringOption("BundleImage", "<bundle-image>", &i.BundleImage)),
+			operator.Constraint(func() error {
+				if i.CatalogSource == "" {
+					return nil
+				}
+				_, _, err := registry.ParseCatalogSource(i.CatalogSource)
+				return err
+			}, catalogSource),
+			operator.MutuallyExclusive(
+				operator.StringOption("DiagnosticsDir", "--diagnostics-dir", &i.DiagnosticsDir),
+				operator.BoolOption("NoDiagnostics", "--no-diagnostics", &i.NoDiagnostics)),
+			operator.Constraint(func() error {
+				if i.RegistryBudget < 0 || i.RegistryFailureThreshold < 0 {
+					return fmt.Errorf("registry budget and failure threshold must not be negative")
+				}
+				return nil
+			},
+				operator.Option{Field: "RegistryBudget", Flag: "--registry-budget", IsSet: func() bool { return i.RegistryBudget != 0 }},
+				operator.Option{Field: "RegistryFailureThreshold", Flag: "--registry-failure-threshold",
+					IsSet: func() bool { return i.RegistryFailureThreshold != 0 }}),
+		},
+		Unconstrained: []string{"CreateNamespace"},
+	}
+	// Options of the registry pod serving a catalog can't apply to an existing catalog.
+	for _, opt := range []operator.Option{
+		{Field: "AdditionalBundleImages", Flag: "--additional-bundle", IsSet: func() bool { return len(i.AdditionalBundleImages) != 0 }},
+		operator.StringOption("IndexImageCatalogCreator.CatalogMode", "--catalog-mode", &i.CatalogMode),
+		operator.StringOption("IndexImageCatalogCreator.PullSecretName", "--pull-secret-name", &i.PullSecretName),
+		operator.StringOption("IndexImageCatalogCreator.CASecretName", "--ca-secret-name", &i.CASecretName),
+		operator.StringOption("IndexImageCatalogCreator.RegistryImage", "--registry-image", &i.RegistryImage),
+		operator.StringOption("IndexImageCatalogCreator.ServiceAccountName", "--service-account", &i.ServiceAccountName),
+		{Field: "IndexImageCatalogCreator.RegistryNodeSelector", Flag: "--registry-pod-node-selector",
+			IsSet: func() bool { return len(i.RegistryNodeSelector) != 0 }},
+		{Field: "IndexImageCatalogCreator.RegistryTolerations", Flag: "--registry-pod-tolerations-file",
+			IsSet: func() bool { return len(i.RegistryTolerations) != 0 }},
+	} {
+		rules.Rules = append(rules.Rules, operator.MutuallyExclusive(catalogSource, opt))
+	}
+	return rules.Append(
+		i.IndexImageCatalogCreator.OptionRules().Embed("IndexImageCatalogCreator"),
+		i.OperatorInstaller.OptionRules().Embed("OperatorInstaller"),
+	)
 }
 
 func (i Install) Run(ctx context.Context) (*v1alpha1.ClusterServiceVersion, error) {
+	if err := i.Validate(); err != nil {
+		return nil, err
+	}
+	// Pull secrets and the service account are validated in the namespace, so
+	// it must exist before setup.
+	if i.CreateNamespace {
+		if _, err := operator.EnsureNamespace(ctx, i.cfg); err != nil {
+			return nil, err
+		}
+	}
 	if err := i.setup(ctx); err != nil {
 		return nil, err
 	}
-	return i.InstallOperator(ctx)
+	csv, err := i.InstallOperator(ctx)
+	if err != nil && !i.NoDiagnostics {
+		err = i.collectDiagnostics(err)
+	}
+	return csv, err
+}
+
+// collectDiagnostics writes diagnostics of the install that failed with installErr
+// to i.DiagnosticsDir, and returns installErr with the directory they were written to.
+// Collection has its own timeout since ctx may have expired.
+func (i Install) collectDiagnostics(installErr error) error {
+	dir := i.DiagnosticsDir
+	if dir == "" {
+		dir = registry.DefaultDiagnosticsDir(time.Now())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), registry.DiagnosticsTimeout)
+	defer cancel()
+	c := registry.NewDiagnosticsCollector(i.cfg)
+	c.PackageName = i.OperatorInstaller.PackageName
+	c.CatalogSourceName = i.OperatorInstaller.CatalogSourceName
+	if err := c.Collect(ctx, dir); err != nil {
+		log.Warnf("Failed to collect diagnostics: %v", err)
+		return installErr
+	}
+	return fmt.Errorf("%w (diagnostics written to %s)", installErr, dir)
 }
 
 func (i *Install) setup(ctx context.Context) error {
-	labels, csv, err := loadBundle(ctx, i.BundleImage)
+	// Check the pull secret before pulling anything, since a bad secret otherwise
+	// only surfaces as a registry pod stuck pulling images.
+	if err := i.IndexImageCatalogCreator.ValidatePullSecret(ctx); err != nil {
+		return err
+	}
+	if err := i.IndexImageCatalogCreator.ValidateCASecret(ctx); err != nil {
+		return err
+	}
+	if err := i.IndexImageCatalogCreator.ValidateServiceAccount(ctx); err != nil {
+		return err
+	}
+
+	breakers := breaker.New(breaker.Options{Budget: i.RegistryBudget, FailureThreshold: i.RegistryFailureThreshold})
+	defer i.reportRegistryStats(breakers)
+	labels, csv, err := i.pullBundle(ctx, breakers, i.BundleImage)
 	if err != nil {
 		return err
 	}
 
-	if err := i.InstallMode.CheckCompatibility(csv, i.cfg.Namespace); err != nil {
+	if err := i.OperatorInstaller.ResolveInstallMode(csv); err != nil {
 		return err
 	}
 
 	i.OperatorInstaller.PackageName = labels[registrybundle.PackageLabel]
+	if i.CatalogSource != "" {
+		return i.setupExistingCatalog(csv.Name, strings.Split(labels[registrybundle.ChannelsLabel], ",")[0])
+	}
 	i.OperatorInstaller.CatalogSourceName = fmt.Sprintf("%s-catalog", i.OperatorInstaller.PackageName)
 	i.OperatorInstaller.StartingCSV = csv.Name
-	i.OperatorInstaller.SupportedInstallModes = operator.GetSupportedInstallModes(csv.Spec.InstallModes)
 	i.OperatorInstaller.Channel = strings.Split(labels[registrybundle.ChannelsLabel], ",")[0]
+	i.IndexImageCatalogCreator.BundleChannels = strings.Split(labels[registrybundle.ChannelsLabel], ",")
+	i.IndexImageCatalogCreator.BundleDefaultChannel = labels[registrybundle.ChannelDefaultLabel]
+	i.IndexImageCatalogCreator.BundleCSVName = csv.Name
 	i.IndexImageCatalogCreator.BundleImage = i.BundleImage
 	i.IndexImageCatalogCreator.PackageName = i.OperatorInstaller.PackageName
-	i.IndexImageCatalogCreator.InjectBundles = []string{i.BundleImage}
-	i.IndexImageCatalogCreator.InjectBundleMode = "replaces"
-	if i.IndexImageCatalogCreator.IndexImage == defaultIndexImage {
-		i.IndexImageCatalogCreator.InjectBundleMode = "semver"
+	if err := i.setupAdditionalBundles(ctx, breakers); err != nil {
+		return err
+	}
+	i.IndexImageCatalogCreator.InjectBundles = append(i.IndexImageCatalogCreator.InjectBundles, i.BundleImage)
+	if i.IndexImageCatalogCreator.InjectBundleMode == "" {
+		// Existing indexes are most likely built with replaces mode. The bundle is
+		// the only one in the default index, so its version is all that matters.
+		i.IndexImageCatalogCreator.InjectBundleMode = "replaces"
+		if i.IndexImageCatalogCreator.IndexImage == defaultIndexImage {
+			i.IndexImageCatalogCreator.InjectBundleMode = "semver"
+		}
 	}
 
 	return nil
 }
 
-func loadBundle(ctx context.Context, bundleImage string) (registryutil.Labels, *v1alpha1.ClusterServiceVersion, error) {
-	bundlePath, err := registryutil.ExtractBundleImage(ctx, nil, bundleImage, false)
+// setupAdditionalBundles loads AdditionalBundleImages to be served by the
+// catalog before BundleImage, and waited for once OLM resolves them.
+func (i *Install) setupAdditionalBundles(ctx context.Context, breakers *breaker.Breakers) error {
+	loaded := map[string]bool{i.OperatorInstaller.PackageName: true}
+	for _, image := range i.AdditionalBundleImages {
+		labels, csv, err := i.pullBundle(ctx, breakers, image)
+		if err != nil {
+			return fmt.Errorf("additional bundle %s: %v", image, err)
+		}
+		pkgName := labels[registrybundle.PackageLabel]
+		if loaded[pkgName] {
+			return fmt.Errorf("additional bundle %s: package %s is already being installed", image, pkgName)
+		}
+		loaded[pkgName] = true
+		if err := i.OperatorInstaller.InstallMode.CheckCompatibility(csv, i.cfg.Namespace); err != nil {
+			return fmt.Errorf("additional bundle %s: %v", image, err)
+		}
+
+		i.IndexImageCatalogCreator.DependencyBundles = append(i.IndexImageCatalogCreator.DependencyBundles,
+			registry.DependencyBundle{
+				Image:          image,
+				PackageName:    pkgName,
+				CSVName:        csv.GetName(),
+				Channels:       strings.Split(labels[registrybundle.ChannelsLabel], ","),
+				DefaultChannel: labels[registrybundle.ChannelDefaultLabel],
+			})
+		i.IndexImageCatalogCreator.InjectBundles = append(i.IndexImageCatalogCreator.InjectBundles, image)
+		i.OperatorInstaller.DependencyCSVs = append(i.OperatorInstaller.DependencyCSVs, csv.GetName())
+	}
+	return nil
+}
+
+// setupExistingCatalog configures i to subscribe to i.CatalogSource, which must
+// serve csvName in channel.
+func (i *Install) setupExistingCatalog(csvName, channel string) error {
+	name, namespace, err := registry.ParseCatalogSource(i.CatalogSource)
+	if err != nil {
+		return err
+	}
+	c := registry.NewExistingCatalogCreator(i.cfg)
+	c.CatalogSourceName = name
+	c.CatalogSourceNamespace = namespace
+	c.PackageName = i.OperatorInstaller.PackageName
+	c.Channel = channel
+	c.CSVName = csvName
+
+	i.OperatorInstaller.CatalogCreator = c
+	i.OperatorInstaller.CatalogSourceName = name
+	i.OperatorInstaller.StartingCSV = csvName
+	i.OperatorInstaller.Channel = channel
+	return nil
+}
+
+// pullBundle loads bundleImage with loadBundle, bounded by the breaker of its registry host.
+func (i Install) pullBundle(ctx context.Context, breakers *breaker.Breakers,
+	bundleImage string) (labels registryutil.Labels, csv *v1alpha1.ClusterServiceVersion, err error) {
+	err = breakers.Do(ctx, bundleImage, func(ctx context.Context) (err error) {
+		labels, csv, err = loadBundle(ctx, bundleImage, i.IndexImageCatalogCreator.PullOptions()...)
+		return err
+	})
+	return labels, csv, err
+}
+
+// reportRegistryStats logs a summary of the bundle pulls bounded by breakers,
+// and records their stats in the install's result.
+func (i Install) reportRegistryStats(breakers *breaker.Breakers) {
+	for _, line := range breakers.Summary() {
+		log.Info(line)
+	}
+	i.OperatorInstaller.Result().SetRegistryStats(breakers.Stats())
+}
+
+func loadBundle(ctx context.Context, bundleImage string,
+	opts ...containerdregistry.RegistryOption) (registryutil.Labels, *v1alpha1.ClusterServiceVersion, error) {
+	bundlePath, err := registryutil.ExtractBundleImage(ctx, nil, bundleImage, false, opts...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("pull bundle image: %v", err)
 	}
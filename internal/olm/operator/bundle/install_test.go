@@ -0,0 +1,129 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/breaker"
+)
+
+var _ = Describe("Install options", func() {
+	var i Install
+
+	BeforeEach(func() {
+		i = NewInstall(&operator.Configuration{Namespace: "testns"})
+		i.BundleImage = "quay.io/example/memcached-operator-bundle:v0.0.1"
+		i.IndexImage = defaultIndexImage
+	})
+
+	It("should constrain every option", func() {
+		Expect(i.OptionRules().Uncovered(i)).To(BeEmpty())
+	})
+	It("should accept valid options", func() {
+		Expect(i.Validate()).To(Succeed())
+	})
+	It("should accept an existing catalog source", func() {
+		i.CatalogSource = "admin-catalog/olm"
+		Expect(i.Validate()).To(Succeed())
+	})
+	It("should accept a registry image", func() {
+		i.RegistryImage = "mirror.example.com/operator-framework/opm:v1.19.0"
+		Expect(i.Validate()).To(Succeed())
+	})
+	It("should accept skipping TLS verification over plain HTTP", func() {
+		i.SkipTLSVerify, i.UseHTTP = true, true
+		Expect(i.Validate()).To(Succeed())
+	})
+
+	DescribeTable("should report violated rules",
+		func(f func(*Install), msg string) {
+			f(&i)
+			Expect(i.Validate()).To(MatchError(ContainSubstring(msg)))
+		},
+		Entry("without a bundle image", func(i *Install) { i.BundleImage = "" },
+			"BundleImage (<bundle-image>) must be set"),
+		Entry("without an index image", func(i *Install) { i.IndexImage = "" },
+			"IndexImageCatalogCreator.IndexImage (--index-image) must be set"),
+		Entry("with an invalid catalog mode", func(i *Install) { i.CatalogMode = "json" },
+			"IndexImageCatalogCreator.CatalogMode (--catalog-mode): must be one of [sqlite, fbc]"),
+		Entry("with an unknown security context config", func(i *Install) { i.SecurityContextConfig = "foo" },
+			`IndexImageCatalogCreator.SecurityContextConfig (--security-context-config): unknown security context config "foo"`),
+		Entry("with a CA secret and skipping TLS verification", func(i *Install) {
+			i.CASecretName, i.SkipTLSVerify = "registry-ca", true
+		}, "IndexImageCatalogCreator.CASecretName (--ca-secret-name), IndexImageCatalogCreator.SkipTLSVerify (--skip-tls-verify) are mutually exclusive"),
+		Entry("with a CA secret and plain HTTP", func(i *Install) {
+			i.CASecretName, i.UseHTTP = "registry-ca", true
+		}, "IndexImageCatalogCreator.CASecretName (--ca-secret-name), IndexImageCatalogCreator.UseHTTP (--use-http) are mutually exclusive"),
+		Entry("with a malformed catalog source", func(i *Install) { i.CatalogSource = "admin-catalog/olm/foo" },
+			`CatalogSource (--catalog-source): catalog source "admin-catalog/olm/foo" must be of the form name[/namespace]`),
+		Entry("with a catalog source and a pull secret", func(i *Install) {
+			i.CatalogSource, i.PullSecretName = "admin-catalog", "regcred"
+		}, "CatalogSource (--catalog-source), IndexImageCatalogCreator.PullSecretName (--pull-secret-name) are mutually exclusive"),
+		Entry("with a catalog source and a registry image", func(i *Install) {
+			i.CatalogSource, i.RegistryImage = "admin-catalog", "mirror.example.com/operator-framework/opm:v1.19.0"
+		}, "CatalogSource (--catalog-source), IndexImageCatalogCreator.RegistryImage (--registry-image) are mutually exclusive"),
+		Entry("with a diagnostics directory and no diagnostics", func(i *Install) {
+			i.DiagnosticsDir, i.NoDiagnostics = "diagnostics", true
+		}, "DiagnosticsDir (--diagnostics-dir), NoDiagnostics (--no-diagnostics) are mutually exclusive"),
+		Entry("with a catalog source and a service account", func(i *Install) {
+			i.CatalogSource, i.ServiceAccountName = "admin-catalog", "registry"
+		}, "CatalogSource (--catalog-source), IndexImageCatalogCreator.ServiceAccountName (--service-account) are mutually exclusive"),
+		Entry("with a catalog source and a registry node selector", func(i *Install) {
+			i.CatalogSource = "admin-catalog"
+			i.RegistryNodeSelector = map[string]string{"disktype": "ssd"}
+		}, "CatalogSource (--catalog-source), IndexImageCatalogCreator.RegistryNodeSelector (--registry-pod-node-selector) are mutually exclusive"),
+		Entry("with an invalid registry toleration", func(i *Install) {
+			i.RegistryTolerations = []corev1.Toleration{{Key: "dedicated", Operator: "In"}}
+		}, `unknown operator "In"`),
+		Entry("with a catalog source and additional bundles", func(i *Install) {
+			i.CatalogSource = "admin-catalog"
+			i.AdditionalBundleImages = []string{"quay.io/example/cert-manager-bundle:v1.0.0"}
+		}, "CatalogSource (--catalog-source), AdditionalBundleImages (--additional-bundle) are mutually exclusive"),
+		Entry("with both an install mode and watch namespaces", func(i *Install) {
+			Expect(i.InstallMode.Set(string(v1alpha1.InstallModeTypeOwnNamespace))).To(Succeed())
+			Expect(i.WatchNamespaces.Set("testns")).To(Succeed())
+		}, "OperatorInstaller.InstallMode (--install-mode), OperatorInstaller.WatchNamespaces (--watch-namespaces) are mutually exclusive"),
+		Entry("with a negative registry budget", func(i *Install) { i.RegistryBudget = -time.Second },
+			"registry budget and failure threshold must not be negative"),
+		Entry("with a negative registry failure threshold", func(i *Install) { i.RegistryFailureThreshold = -1 },
+			"registry budget and failure threshold must not be negative"),
+	)
+
+	It("should skip pulling bundles from a registry whose breaker is open", func() {
+		breakers := breaker.New(breaker.Options{FailureThreshold: 1})
+		Expect(breakers.Do(context.TODO(), "flaky.example.com/example/foo:v0.0.1", func(context.Context) error {
+			return errors.New("500 Internal Server Error")
+		})).NotTo(Succeed())
+
+		_, _, err := i.pullBundle(context.TODO(), breakers, "flaky.example.com/example/memcached-operator-bundle:v0.0.1")
+		Expect(errors.Is(err, breaker.ErrOpen)).To(BeTrue())
+		Expect(breakers.Stats()["flaky.example.com"].Skipped).To(Equal(1))
+	})
+	It("should validate options before pulling the bundle", func() {
+		i.BundleImage = ""
+		_, err := i.Run(context.TODO())
+		Expect(err).To(MatchError(ContainSubstring("BundleImage (<bundle-image>) must be set")))
+	})
+})
@@ -0,0 +1,139 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"context"
+	"strings"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	registrybundle "github.com/operator-framework/operator-registry/pkg/lib/bundle"
+	"github.com/spf13/pflag"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry"
+)
+
+// Upgrade upgrades an operator installed by Install to the operator in BundleImage.
+type Upgrade struct {
+	BundleImage string
+
+	*registry.IndexImageCatalogCreator
+	*registry.OperatorInstaller
+
+	cfg *operator.Configuration
+}
+
+func NewUpgrade(cfg *operator.Configuration) Upgrade {
+	u := Upgrade{
+		OperatorInstaller: registry.NewOperatorInstaller(cfg),
+		cfg:               cfg,
+	}
+	u.IndexImageCatalogCreator = registry.NewIndexImageCatalogCreator(cfg)
+	u.CatalogCreator = u.IndexImageCatalogCreator
+	u.CatalogUpdater = u.IndexImageCatalogCreator
+	return u
+}
+
+func (u *Upgrade) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&u.RegistryImage, "registry-image", "",
+		"Image the registry pod runs opm from to serve the catalog. Defaults to the registry image "+
+			"the operator was installed with")
+	fs.Var(&u.SecurityContextConfig, "security-context-config", operator.SecurityContextConfigUsage)
+	fs.StringVar(&u.PullSecretName, "pull-secret-name", "",
+		"Name of a kubernetes.io/dockerconfigjson Secret in the install namespace used to pull private bundle "+
+			"images. Defaults to the pull secret the operator was installed with")
+	fs.StringVar(&u.CASecretName, "ca-secret-name", "",
+		"Name of a Secret in the install namespace whose cert.pem key contains PEM-encoded CA certificates, "+
+			"trusted by the registry pod when pulling bundle images from registries using them. "+
+			"Defaults to the CA secret the operator was installed with. Index images, and bundles unpacked by OLM, "+
+			"are pulled by the cluster's nodes, which must trust the CA themselves. "+
+			"Can't be used with --skip-tls-verify or --use-http")
+	fs.BoolVar(&u.SkipTLSVerify, "skip-tls-verify", false,
+		"Skip TLS certificate verification when the SDK and registry pod pull bundle and index images. "+
+			"Can't be used with --ca-secret-name. Images pulled by the cluster's nodes are verified as configured on the nodes")
+	fs.BoolVar(&u.UseHTTP, "use-http", false,
+		"Pull bundle and index images over plain HTTP in the SDK and registry pod, ex. from a local registry. "+
+			"Can't be used with --ca-secret-name. The cluster's nodes must be configured to pull from the registry "+
+			"over HTTP to start the registry pod and OLM's bundle unpack jobs")
+	fs.StringVar(&u.ServiceAccountName, "service-account", "",
+		"Name of an existing ServiceAccount in the install namespace the registry pod runs as. "+
+			"Defaults to the ServiceAccount the operator was installed with")
+	fs.Var(operator.NewNodeSelectorValue(&u.RegistryNodeSelector), "registry-pod-node-selector",
+		"Node selector of the registry pod, as <key>=<value> label pairs. This flag can be repeated. "+
+			"Defaults to the node selector the operator was installed with. "+operator.RegistrySchedulingUsage)
+	fs.Var(operator.NewTolerationsFileValue(&u.RegistryTolerations), "registry-pod-tolerations-file",
+		"YAML file containing a list of tolerations of the registry pod, as in a pod spec. "+
+			"Defaults to the tolerations the operator was installed with. "+operator.RegistrySchedulingUsage)
+	u.OperatorInstaller.BindStageTimeoutFlags(fs)
+}
+
+// Validate returns an error describing each of u's option rules that are violated.
+func (u Upgrade) Validate() error {
+	return u.OptionRules().Validate()
+}
+
+// OptionRules returns the rules constraining u's fields, including those of
+// its embedded structs.
+func (u Upgrade) OptionRules() operator.OptionRules {
+	rules := operator.OptionRules{
+		Rules: []operator.OptionRule{
+			operator.Required(operator.StringOption("BundleImage", "<bundle-image>", &u.BundleImage)),
+		},
+	}
+	return rules.Append(
+		u.IndexImageCatalogCreator.UpdateOptionRules().Embed("IndexImageCatalogCreator"),
+		u.OperatorInstaller.OptionRules().Embed("OperatorInstaller"),
+	)
+}
+
+func (u Upgrade) Run(ctx context.Context) (*v1alpha1.ClusterServiceVersion, error) {
+	if err := u.Validate(); err != nil {
+		return nil, err
+	}
+	if err := u.setup(ctx); err != nil {
+		return nil, err
+	}
+	return u.UpgradeOperator(ctx)
+}
+
+func (u *Upgrade) setup(ctx context.Context) error {
+	if err := u.IndexImageCatalogCreator.ValidatePullSecret(ctx); err != nil {
+		return err
+	}
+	if err := u.IndexImageCatalogCreator.ValidateCASecret(ctx); err != nil {
+		return err
+	}
+	if err := u.IndexImageCatalogCreator.ValidateServiceAccount(ctx); err != nil {
+		return err
+	}
+
+	labels, csv, err := loadBundle(ctx, u.BundleImage, u.IndexImageCatalogCreator.PullOptions()...)
+	if err != nil {
+		return err
+	}
+
+	u.OperatorInstaller.PackageName = labels[registrybundle.PackageLabel]
+	u.OperatorInstaller.StartingCSV = csv.Name
+	u.OperatorInstaller.Channel = strings.Split(labels[registrybundle.ChannelsLabel], ",")[0]
+	u.IndexImageCatalogCreator.BundleChannels = strings.Split(labels[registrybundle.ChannelsLabel], ",")
+	u.IndexImageCatalogCreator.BundleDefaultChannel = labels[registrybundle.ChannelDefaultLabel]
+	u.IndexImageCatalogCreator.BundleCSVName = csv.Name
+	u.IndexImageCatalogCreator.BundleReplaces = csv.Spec.Replaces
+	u.IndexImageCatalogCreator.BundleImage = u.BundleImage
+	u.IndexImageCatalogCreator.PackageName = u.OperatorInstaller.PackageName
+
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+)
+
+var _ = Describe("Upgrade options", func() {
+	var u Upgrade
+
+	BeforeEach(func() {
+		u = NewUpgrade(&operator.Configuration{Namespace: "testns"})
+		u.BundleImage = "quay.io/example/memcached-operator-bundle:v0.0.2"
+	})
+
+	It("should constrain every option", func() {
+		Expect(u.OptionRules().Uncovered(u)).To(BeEmpty())
+	})
+	It("should accept valid options without an index image", func() {
+		Expect(u.Validate()).To(Succeed())
+	})
+
+	DescribeTable("should report violated rules",
+		func(f func(*Upgrade), msg string) {
+			f(&u)
+			Expect(u.Validate()).To(MatchError(ContainSubstring(msg)))
+		},
+		Entry("without a bundle image", func(u *Upgrade) { u.BundleImage = "" },
+			"BundleImage (<bundle-image>) must be set"),
+		Entry("with an unknown security context config", func(u *Upgrade) { u.SecurityContextConfig = "foo" },
+			`IndexImageCatalogCreator.SecurityContextConfig (--security-context-config): unknown security context config "foo"`),
+		Entry("with a CA secret and plain HTTP", func(u *Upgrade) {
+			u.CASecretName, u.UseHTTP = "registry-ca", true
+		}, "IndexImageCatalogCreator.CASecretName (--ca-secret-name), IndexImageCatalogCreator.UseHTTP (--use-http) are mutually exclusive"),
+	)
+
+	It("should validate options before pulling the bundle", func() {
+		u.BundleImage = ""
+		_, err := u.Run(context.TODO())
+		Expect(err).To(MatchError(ContainSubstring("BundleImage (<bundle-image>) must be set")))
+	})
+})
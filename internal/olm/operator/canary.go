@@ -0,0 +1,203 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultCanaryCleanupTimeout is the time given to uninstall a canary operator.
+// A fresh context is used for cleanup so a cancelled or expired install context
+// does not prevent resources from being removed.
+const defaultCanaryCleanupTimeout = 2 * time.Minute
+
+// CanaryInstaller installs an operator for a canary run.
+type CanaryInstaller interface {
+	Run(context.Context) (*v1alpha1.ClusterServiceVersion, error)
+	// GetPackageName returns the name of the package being installed.
+	// It is only guaranteed to be set once Run has been called.
+	GetPackageName() string
+}
+
+// VerifyFunc checks that an installed operator works as expected.
+type VerifyFunc func(context.Context, *v1alpha1.ClusterServiceVersion) error
+
+// VerifyOptions configures verification of a canary install.
+type VerifyOptions struct {
+	// Checks are run in order against the installed CSV. If empty, a
+	// successful install is considered a successful verification.
+	Checks []VerifyFunc
+	// CleanupTimeout bounds the time spent uninstalling the canary.
+	// Defaults to defaultCanaryCleanupTimeout.
+	CleanupTimeout time.Duration
+}
+
+// CanaryResult holds the outcome of each phase of a canary run. Verification
+// and cleanup outcomes are reported separately so a passing verification
+// followed by a failed cleanup can be distinguished from a failed verification.
+type CanaryResult struct {
+	CSV *v1alpha1.ClusterServiceVersion
+	// VerifyErr is non-nil if either the install or one of the verification
+	// checks failed.
+	VerifyErr error
+	// CleanupErr is non-nil if uninstalling the canary failed or resources
+	// remained on-cluster afterwards.
+	CleanupErr error
+	// Diagnostics contains information collected from the cluster on failure.
+	Diagnostics []string
+}
+
+// Passed returns true if the canary installed and verified successfully.
+func (r CanaryResult) Passed() bool {
+	return r.VerifyErr == nil
+}
+
+// RunCanary installs an operator with install, runs verify's checks against it,
+// then unconditionally uninstalls everything that was created. Cleanup is run
+// on panic, SIGINT/SIGTERM, and ctx cancellation.
+func RunCanary(ctx context.Context, cfg *Configuration, install CanaryInstaller, verify VerifyOptions) (res CanaryResult) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	go func() {
+		select {
+		case sig := <-sigCh:
+			log.Infof("Received signal %s, cleaning up canary", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	defer func() {
+		r := recover()
+		if r != nil && res.VerifyErr == nil {
+			res.VerifyErr = fmt.Errorf("canary panicked: %v", r)
+		}
+		res.CleanupErr = cleanupCanary(cfg, install.GetPackageName(), getAdditionalPackageNames(install), verify.CleanupTimeout)
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	csv, err := install.Run(ctx)
+	if err != nil {
+		res.VerifyErr = fmt.Errorf("install: %v", err)
+		res.Diagnostics = collectCanaryDiagnostics(cfg, install.GetPackageName())
+		return res
+	}
+	res.CSV = csv
+
+	for _, check := range verify.Checks {
+		if err := check(ctx, csv); err != nil {
+			res.VerifyErr = fmt.Errorf("verify: %v", err)
+			res.Diagnostics = collectCanaryDiagnostics(cfg, install.GetPackageName())
+			return res
+		}
+	}
+	return res
+}
+
+// RunCanaryOrDie runs a canary with install and logs its outcome, exiting
+// non-zero only if verification failed. Cleanup failures are logged separately.
+func RunCanaryOrDie(ctx context.Context, cfg *Configuration, install CanaryInstaller) {
+	res := RunCanary(ctx, cfg, install, VerifyOptions{})
+	for _, diag := range res.Diagnostics {
+		log.Info(diag)
+	}
+	if res.CleanupErr != nil {
+		log.Errorf("Failed to clean up canary: %v", res.CleanupErr)
+	} else {
+		log.Info("Canary cleaned up")
+	}
+	if !res.Passed() {
+		log.Fatalf("Canary failed: %v\n", res.VerifyErr)
+	}
+	log.Info("Canary passed")
+}
+
+// getAdditionalPackageNames returns the names of packages install subscribes
+// to in addition to its main package, if it installs more than one.
+func getAdditionalPackageNames(install CanaryInstaller) []string {
+	if mi, ok := install.(interface{ GetAdditionalPackageNames() []string }); ok {
+		return mi.GetAdditionalPackageNames()
+	}
+	return nil
+}
+
+// cleanupCanary uninstalls pkgName and additionalPkgNames, and verifies
+// nothing was left behind.
+func cleanupCanary(cfg *Configuration, pkgName string, additionalPkgNames []string, timeout time.Duration) error {
+	if pkgName == "" {
+		// Install failed before any resources were created.
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = defaultCanaryCleanupTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	u := NewUninstall(cfg)
+	u.Package = pkgName
+	u.AdditionalPackages = additionalPkgNames
+	u.DeleteAll = true
+	u.DeleteOperatorGroupNames = []string{SDKOperatorGroupName}
+	u.Logf = log.Infof
+	if err := u.Run(ctx); err != nil && !errors.Is(err, ErrPackageNotFound) {
+		return fmt.Errorf("uninstall: %v", err)
+	}
+	var errs []error
+	for _, name := range append([]string{pkgName}, additionalPkgNames...) {
+		if err := VerifyNoResiduals(ctx, cfg, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// collectCanaryDiagnostics returns a human-readable summary of the state of
+// pkgName's CSVs in the configured namespace. Like cleanup, a fresh context is
+// used since the install context may have been cancelled.
+func collectCanaryDiagnostics(cfg *Configuration, pkgName string) (diags []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	csvs := v1alpha1.ClusterServiceVersionList{}
+	if err := cfg.Client.List(ctx, &csvs, client.InNamespace(cfg.Namespace)); err != nil {
+		return []string{fmt.Sprintf("list clusterserviceversions: %v", err)}
+	}
+	for _, csv := range csvs.Items {
+		diags = append(diags, fmt.Sprintf("clusterserviceversion %q: phase=%q reason=%q message=%q",
+			csv.GetName(), csv.Status.Phase, csv.Status.Reason, csv.Status.Message))
+	}
+	if len(diags) == 0 {
+		diags = append(diags, fmt.Sprintf("no clusterserviceversions found for package %q", pkgName))
+	}
+	return diags
+}
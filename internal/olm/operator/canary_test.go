@@ -0,0 +1,101 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeCanaryInstaller struct {
+	pkgName string
+	csv     *v1alpha1.ClusterServiceVersion
+	err     error
+}
+
+func (f *fakeCanaryInstaller) Run(context.Context) (*v1alpha1.ClusterServiceVersion, error) {
+	return f.csv, f.err
+}
+
+func (f *fakeCanaryInstaller) GetPackageName() string {
+	return f.pkgName
+}
+
+var _ = Describe("RunCanary", func() {
+	var cfg *Configuration
+
+	BeforeEach(func() {
+		sch := runtime.NewScheme()
+		Expect(corev1.AddToScheme(sch)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+		cfg = &Configuration{
+			Scheme:    sch,
+			Client:    fake.NewFakeClientWithScheme(sch),
+			Namespace: "testns",
+		}
+	})
+
+	It("should pass and clean up if install and checks succeed", func() {
+		inst := &fakeCanaryInstaller{pkgName: "memcached-operator", csv: &v1alpha1.ClusterServiceVersion{}}
+		checked := false
+		res := RunCanary(context.TODO(), cfg, inst, VerifyOptions{
+			Checks: []VerifyFunc{func(context.Context, *v1alpha1.ClusterServiceVersion) error {
+				checked = true
+				return nil
+			}},
+		})
+		Expect(checked).To(BeTrue())
+		Expect(res.Passed()).To(BeTrue())
+		Expect(res.CleanupErr).NotTo(HaveOccurred())
+	})
+	It("should fail with diagnostics if a check fails", func() {
+		inst := &fakeCanaryInstaller{pkgName: "memcached-operator", csv: &v1alpha1.ClusterServiceVersion{}}
+		res := RunCanary(context.TODO(), cfg, inst, VerifyOptions{
+			Checks: []VerifyFunc{func(context.Context, *v1alpha1.ClusterServiceVersion) error {
+				return errors.New("smoke test failed")
+			}},
+		})
+		Expect(res.Passed()).To(BeFalse())
+		Expect(res.VerifyErr.Error()).To(ContainSubstring("smoke test failed"))
+		Expect(res.Diagnostics).NotTo(BeEmpty())
+		Expect(res.CleanupErr).NotTo(HaveOccurred())
+	})
+	It("should fail if install fails", func() {
+		inst := &fakeCanaryInstaller{err: errors.New("bad image")}
+		res := RunCanary(context.TODO(), cfg, inst, VerifyOptions{})
+		Expect(res.Passed()).To(BeFalse())
+		Expect(res.VerifyErr.Error()).To(ContainSubstring("bad image"))
+		Expect(res.CleanupErr).NotTo(HaveOccurred())
+	})
+	It("should report cleanup failures separately from verification", func() {
+		sub := &v1alpha1.Subscription{}
+		sub.SetName("memcached-operator-sub")
+		sub.SetNamespace("testns")
+		sub.Spec = &v1alpha1.SubscriptionSpec{Package: "memcached-operator", CatalogSource: "missing"}
+		Expect(cfg.Client.Create(context.TODO(), sub)).To(Succeed())
+
+		inst := &fakeCanaryInstaller{pkgName: "memcached-operator", csv: &v1alpha1.ClusterServiceVersion{}}
+		res := RunCanary(context.TODO(), cfg, inst, VerifyOptions{})
+		Expect(res.Passed()).To(BeTrue())
+		Expect(res.CleanupErr).To(HaveOccurred())
+	})
+})
@@ -16,16 +16,27 @@ package operator
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
 
 	v1 "github.com/operator-framework/api/pkg/operators/v1"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
 )
 
 type Configuration struct {
@@ -34,6 +45,26 @@ type Configuration struct {
 	RESTConfig     *rest.Config
 	Client         client.Client
 	Scheme         *runtime.Scheme
+	// ImpersonateUser and ImpersonateGroups are the user and groups to act as
+	// for all requests. ImpersonateGroups requires ImpersonateUser to be set.
+	ImpersonateUser   string
+	ImpersonateGroups []string
+	// SkipSchemaDriftCheck disables comparing created OLM objects against the
+	// schemas served by the cluster's OLM CRDs.
+	SkipSchemaDriftCheck bool
+	// Verbose enables logging each object as YAML with Logf before it is
+	// created or updated.
+	Verbose bool
+	// Logf logs verbose output. Defaults to logrus.Infof.
+	Logf func(string, ...interface{})
+	// Labels and Annotations are merged onto the metadata of every object created
+	// with Client. Keys already set on an object, ex. labels the SDK finds its
+	// objects by, are not overridden.
+	Labels      map[string]string
+	Annotations map[string]string
+	// WrapTransport, if set, wraps the transport of all clients created from
+	// RESTConfig, ex. to record API interactions in tests.
+	WrapTransport func(http.RoundTripper) http.RoundTripper
 
 	overrides *clientcmd.ConfigOverrides
 }
@@ -54,6 +85,17 @@ func (c *Configuration) BindFlags(fs *pflag.FlagSet) {
 	})
 	fs.StringVar(&c.KubeconfigPath, "kubeconfig", "",
 		"Path to the kubeconfig file to use for CLI requests.")
+	fs.StringVar(&c.ImpersonateUser, "as", "",
+		"Username to impersonate for the operation. User could be a regular user or a service account in a namespace.")
+	fs.StringSliceVar(&c.ImpersonateGroups, "as-group", nil,
+		"Group to impersonate for the operation, this flag can be repeated to specify multiple groups.")
+}
+
+// BindSchemaDriftFlags binds flags for c's schema drift checks to fs. These
+// are only bound by commands that create OLM objects.
+func (c *Configuration) BindSchemaDriftFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&c.SkipSchemaDriftCheck, "skip-schema-drift-check", false,
+		"Do not check OLM objects against the schemas served by the cluster before creating them")
 }
 
 func (c *Configuration) Load() error {
@@ -71,21 +113,27 @@ func (c *Configuration) Load() error {
 	if err != nil {
 		return err
 	}
+	if err := c.applyImpersonation(cc); err != nil {
+		return err
+	}
+	if c.WrapTransport != nil {
+		wrapped := cc.WrapTransport
+		cc.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+			if wrapped != nil {
+				rt = wrapped(rt)
+			}
+			return c.WrapTransport(rt)
+		}
+	}
 
 	ns, _, err := cfg.Namespace()
 	if err != nil {
 		return err
 	}
 
-	sch := scheme.Scheme
-	for _, f := range []func(*runtime.Scheme) error{
-		v1alpha1.AddToScheme,
-		v1.AddToScheme,
-		apiextv1.AddToScheme,
-	} {
-		if err := f(sch); err != nil {
-			return err
-		}
+	sch, err := newScheme()
+	if err != nil {
+		return err
 	}
 	cl, err := client.New(cc, client.Options{
 		Scheme: sch,
@@ -95,7 +143,12 @@ func (c *Configuration) Load() error {
 	}
 
 	c.Scheme = sch
-	c.Client = &operatorClient{cl}
+	oc := &operatorClient{Client: cl, impersonate: cc.Impersonate, logf: c.VerboseLogf(),
+		labels: c.Labels, annotations: c.Annotations}
+	if !c.SkipSchemaDriftCheck {
+		oc.drift = newSchemaDriftChecker(cl, sch)
+	}
+	c.Client = oc
 	if c.Namespace == "" {
 		c.Namespace = ns
 	}
@@ -104,11 +157,278 @@ func (c *Configuration) Load() error {
 	return nil
 }
 
+// LoadOffline sets c's Scheme and, if unset, its Namespace from the kubeconfig
+// or "default", without creating a client, since creating one contacts the
+// cluster. Callers must set c.Client before reading with it.
+func (c *Configuration) LoadOffline() error {
+	sch, err := newScheme()
+	if err != nil {
+		return err
+	}
+	c.Scheme = sch
+	if c.Namespace != "" {
+		return nil
+	}
+	if c.overrides == nil {
+		c.overrides = &clientcmd.ConfigOverrides{}
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = c.KubeconfigPath
+	ns, _, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, c.overrides).Namespace()
+	if err != nil {
+		log.Debugf("Failed to get namespace from kubeconfig, using \"default\": %v", err)
+		ns = "default"
+	}
+	c.Namespace = ns
+	return nil
+}
+
+// newScheme returns the client-go scheme with OLM and CRD types added.
+func newScheme() (*runtime.Scheme, error) {
+	sch := scheme.Scheme
+	for _, f := range []func(*runtime.Scheme) error{
+		v1alpha1.AddToScheme,
+		v1.AddToScheme,
+		apiextv1.AddToScheme,
+	} {
+		if err := f(sch); err != nil {
+			return nil, err
+		}
+	}
+	return sch, nil
+}
+
+// VerboseLogf returns the function objects should be logged with before they
+// are created or updated, or nil if c.Verbose is false.
+func (c *Configuration) VerboseLogf() func(string, ...interface{}) {
+	if !c.Verbose {
+		return nil
+	}
+	if c.Logf != nil {
+		return c.Logf
+	}
+	return log.Infof
+}
+
+// applyImpersonation sets cc's impersonation config from c, overriding any
+// impersonation configured in the kubeconfig.
+func (c *Configuration) applyImpersonation(cc *rest.Config) error {
+	if c.ImpersonateUser == "" {
+		if len(c.ImpersonateGroups) != 0 {
+			return errors.New("impersonating groups requires impersonating a user")
+		}
+		return nil
+	}
+	cc.Impersonate = rest.ImpersonationConfig{
+		UserName: c.ImpersonateUser,
+		Groups:   c.ImpersonateGroups,
+	}
+	return nil
+}
+
+// ForbiddenError annotates a Forbidden error with the RBAC permission the
+// denied request required and, if impersonating, the impersonated identity,
+// so the missing permission and who lacks it are apparent.
+type ForbiddenError struct {
+	// Verb and Resource are the denied request's RBAC verb and resource,
+	// ex. "create" and "subscriptions.operators.coreos.com".
+	Verb     string
+	Resource string
+	// Namespace is empty for cluster-scoped requests.
+	Namespace string
+	// User and Groups are empty if not impersonating.
+	User   string
+	Groups []string
+	Err    error
+}
+
+func (e *ForbiddenError) Error() string {
+	msg := fmt.Sprintf("requires RBAC permission to %s %s", e.Verb, e.Resource)
+	if e.Namespace != "" {
+		msg += fmt.Sprintf(" in namespace %q", e.Namespace)
+	} else {
+		msg += " cluster-wide"
+	}
+	if e.User != "" {
+		msg += fmt.Sprintf(", impersonating user %q", e.User)
+		if len(e.Groups) != 0 {
+			msg += fmt.Sprintf(", groups %q", e.Groups)
+		}
+	}
+	return fmt.Sprintf("%v (%s)", e.Err, msg)
+}
+
+func (e *ForbiddenError) Unwrap() error {
+	return e.Err
+}
+
+// Status implements apierrors.APIStatus so apierrors.IsForbidden(e) is true.
+func (e *ForbiddenError) Status() metav1.Status {
+	if s, ok := e.Err.(apierrors.APIStatus); ok {
+		return s.Status()
+	}
+	return metav1.Status{}
+}
+
 type operatorClient struct {
 	client.Client
+
+	impersonate rest.ImpersonationConfig
+	// drift is nil if schema drift checks are disabled.
+	drift *schemaDriftChecker
+	// logf is nil if verbose logging is disabled.
+	logf func(string, ...interface{})
+	// labels and annotations are added to created objects.
+	labels, annotations map[string]string
 }
 
 func (c *operatorClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
 	opts = append(opts, client.FieldOwner("operator-sdk"))
-	return c.Client.Create(ctx, obj, opts...)
+	c.addMetadata(obj)
+	if c.drift != nil {
+		if u := c.checkDrift(ctx, obj); u != nil {
+			c.logObject("Creating", u)
+			return c.annotate(c.createUnstructured(ctx, obj, u, opts...), "create", obj, objectNamespace(obj))
+		}
+	}
+	c.logObject("Creating", obj)
+	return c.annotate(c.Client.Create(ctx, obj, opts...), "create", obj, objectNamespace(obj))
+}
+
+// addMetadata adds c's labels and annotations to obj, keeping obj's values of
+// keys it already sets.
+func (c *operatorClient) addMetadata(obj runtime.Object) {
+	if len(c.labels) == 0 && len(c.annotations) == 0 {
+		return
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	accessor.SetLabels(mergeMetadata(accessor.GetLabels(), c.labels))
+	accessor.SetAnnotations(mergeMetadata(accessor.GetAnnotations(), c.annotations))
+}
+
+// mergeMetadata returns a copy of existing with the keys of extra it does not set.
+func mergeMetadata(existing, extra map[string]string) map[string]string {
+	if len(extra) == 0 {
+		return existing
+	}
+	merged := make(map[string]string, len(existing)+len(extra))
+	for k, v := range extra {
+		merged[k] = v
+	}
+	for k, v := range existing {
+		merged[k] = v
+	}
+	return merged
+}
+
+// checkDrift warns about differences between obj and its served schema. If
+// the server would prune fields set in obj, obj's unstructured representation
+// without those fields is returned.
+func (c *operatorClient) checkDrift(ctx context.Context, obj runtime.Object) *unstructured.Unstructured {
+	r, u, err := c.drift.Check(ctx, obj)
+	if err != nil {
+		log.Debugf("Skipping schema drift check: %v", err)
+		return nil
+	}
+	name := ""
+	if accessor, err := meta.Accessor(obj); err == nil {
+		name = accessor.GetName()
+	}
+	for _, f := range r.MissingRequired {
+		log.Warnf("%s %q: field %q is required by the cluster's OLM but not set by this version of operator-sdk",
+			r.GVK.Kind, name, f)
+	}
+	for _, f := range r.Pruned {
+		log.Warnf("%s %q: field %q is not served by the cluster's OLM and will be dropped", r.GVK.Kind, name, f)
+	}
+	if len(r.Pruned) == 0 {
+		return nil
+	}
+	uobj := &unstructured.Unstructured{Object: u}
+	uobj.SetGroupVersionKind(r.GVK)
+	return uobj
+}
+
+// createUnstructured creates u in place of obj, then decodes the created object into obj.
+func (c *operatorClient) createUnstructured(ctx context.Context, obj runtime.Object, u *unstructured.Unstructured, opts ...client.CreateOption) error {
+	if err := c.Client.Create(ctx, u, opts...); err != nil {
+		return err
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), obj)
+}
+
+func (c *operatorClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	return c.annotate(c.Client.Get(ctx, key, obj), "get", obj, key.Namespace)
+}
+
+func (c *operatorClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	return c.annotate(c.Client.List(ctx, list, opts...), "list", list, listOpts.Namespace)
+}
+
+func (c *operatorClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	c.logObject("Updating", obj)
+	return c.annotate(c.Client.Update(ctx, obj, opts...), "update", obj, objectNamespace(obj))
+}
+
+func (c *operatorClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return c.annotate(c.Client.Patch(ctx, obj, patch, opts...), "patch", obj, objectNamespace(obj))
+}
+
+func (c *operatorClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	return c.annotate(c.Client.Delete(ctx, obj, opts...), "delete", obj, objectNamespace(obj))
+}
+
+func (c *operatorClient) logObject(verb string, obj runtime.Object) {
+	if c.logf != nil {
+		olmclient.LogObject(c.logf, verb, obj)
+	}
+}
+
+// annotate wraps a Forbidden error returned for a request to verb obj in
+// namespace in a ForbiddenError.
+func (c *operatorClient) annotate(err error, verb string, obj runtime.Object, namespace string) error {
+	if err == nil || !apierrors.IsForbidden(err) {
+		return err
+	}
+	return &ForbiddenError{
+		Verb:      verb,
+		Resource:  forbiddenResource(err, obj),
+		Namespace: namespace,
+		User:      c.impersonate.UserName,
+		Groups:    c.impersonate.Groups,
+		Err:       err,
+	}
+}
+
+// forbiddenResource returns the resource a Forbidden error was returned for,
+// ex. "subscriptions.operators.coreos.com". The server sets the resource in
+// the error's details; if unset, obj's kind is used.
+func forbiddenResource(err error, obj runtime.Object) string {
+	if status, ok := err.(apierrors.APIStatus); ok {
+		if d := status.Status().Details; d != nil && d.Kind != "" {
+			return schema.GroupResource{Group: d.Group, Resource: d.Kind}.String()
+		}
+	}
+	if obj == nil {
+		return "unknown"
+	}
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Kind == "" {
+		return "unknown"
+	}
+	return schema.GroupKind{Group: gvk.Group, Kind: gvk.Kind}.String()
+}
+
+// objectNamespace returns obj's namespace, if any.
+func objectNamespace(obj runtime.Object) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return accessor.GetNamespace()
 }
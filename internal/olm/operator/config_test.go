@@ -0,0 +1,218 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "github.com/operator-framework/api/pkg/operators/v1"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/spf13/pflag"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
+)
+
+// forbiddenClient returns a Forbidden error for all Get calls.
+type forbiddenClient struct {
+	client.Client
+}
+
+func (forbiddenClient) Get(_ context.Context, key client.ObjectKey, _ runtime.Object) error {
+	gr := schema.GroupResource{Group: v1alpha1.GroupName, Resource: "subscriptions"}
+	return apierrors.NewForbidden(gr, key.Name, errors.New("cannot get resource"))
+}
+
+var _ = Describe("Configuration", func() {
+	Describe("BindFlags", func() {
+		It("should bind impersonation flags", func() {
+			cfg := &Configuration{}
+			fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			cfg.BindFlags(fs)
+			Expect(fs.Parse([]string{"--as", "system:serviceaccount:testns:installer", "--as-group", "a", "--as-group", "b"})).To(Succeed())
+			Expect(cfg.ImpersonateUser).To(Equal("system:serviceaccount:testns:installer"))
+			Expect(cfg.ImpersonateGroups).To(Equal([]string{"a", "b"}))
+		})
+	})
+
+	Describe("applyImpersonation", func() {
+		It("should set the rest config's impersonation user and groups", func() {
+			cfg := &Configuration{ImpersonateUser: "jane", ImpersonateGroups: []string{"devs"}}
+			cc := &rest.Config{}
+			Expect(cfg.applyImpersonation(cc)).To(Succeed())
+			Expect(cc.Impersonate.UserName).To(Equal("jane"))
+			Expect(cc.Impersonate.Groups).To(Equal([]string{"devs"}))
+		})
+		It("should not override kubeconfig impersonation if unset", func() {
+			cfg := &Configuration{}
+			cc := &rest.Config{Impersonate: rest.ImpersonationConfig{UserName: "kubeconfig-user"}}
+			Expect(cfg.applyImpersonation(cc)).To(Succeed())
+			Expect(cc.Impersonate.UserName).To(Equal("kubeconfig-user"))
+		})
+		It("should fail if groups are set without a user", func() {
+			cfg := &Configuration{ImpersonateGroups: []string{"devs"}}
+			Expect(cfg.applyImpersonation(&rest.Config{})).NotTo(Succeed())
+		})
+	})
+
+	Describe("operatorClient", func() {
+		It("should annotate forbidden errors with the impersonated identity", func() {
+			c := &operatorClient{
+				Client:      forbiddenClient{},
+				impersonate: rest.ImpersonationConfig{UserName: "jane", Groups: []string{"devs"}},
+			}
+			err := c.Get(context.TODO(), client.ObjectKey{Name: "sub"}, &v1alpha1.Subscription{})
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			forbiddenErr := &ForbiddenError{}
+			Expect(errors.As(err, &forbiddenErr)).To(BeTrue())
+			Expect(forbiddenErr.User).To(Equal("jane"))
+			Expect(err.Error()).To(ContainSubstring(`impersonating user "jane", groups ["devs"]`))
+		})
+		It("should annotate forbidden errors with the denied verb and resource", func() {
+			c := &operatorClient{Client: forbiddenClient{}}
+			err := c.Get(context.TODO(), client.ObjectKey{Namespace: "testns", Name: "sub"}, &v1alpha1.Subscription{})
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			forbiddenErr := &ForbiddenError{}
+			Expect(errors.As(err, &forbiddenErr)).To(BeTrue())
+			Expect(forbiddenErr.Verb).To(Equal("get"))
+			Expect(forbiddenErr.Resource).To(Equal("subscriptions.operators.coreos.com"))
+			Expect(forbiddenErr.Namespace).To(Equal("testns"))
+			Expect(err.Error()).To(HaveSuffix(
+				`(requires RBAC permission to get subscriptions.operators.coreos.com in namespace "testns")`))
+		})
+		It("should describe cluster-scoped requests", func() {
+			c := &operatorClient{Client: forbiddenClient{}}
+			err := c.Get(context.TODO(), client.ObjectKey{Name: "sub"}, &v1alpha1.Subscription{})
+			Expect(err.Error()).To(HaveSuffix(`(requires RBAC permission to get subscriptions.operators.coreos.com cluster-wide)`))
+		})
+		It("should log objects before creating them if verbose", func() {
+			var logs []string
+			cfg := &Configuration{Verbose: true, Logf: func(format string, args ...interface{}) {
+				logs = append(logs, fmt.Sprintf(format, args...))
+			}}
+			rc := &recordingClient{}
+			c := &operatorClient{Client: rc, logf: cfg.VerboseLogf()}
+			sub := &v1alpha1.Subscription{}
+			sub.SetName("sub")
+			Expect(c.Create(context.TODO(), sub)).To(Succeed())
+			Expect(logs).To(HaveLen(1))
+			Expect(logs[0]).To(HavePrefix(`Creating Subscription "sub":`))
+		})
+		It("should not log objects if not verbose", func() {
+			cfg := &Configuration{Logf: func(string, ...interface{}) { Fail("unexpected log") }}
+			Expect(cfg.VerboseLogf()).To(BeNil())
+		})
+		Context("with labels and annotations", func() {
+			const ns = "testns"
+
+			var (
+				sch *runtime.Scheme
+				c   *operatorClient
+			)
+
+			BeforeEach(func() {
+				sch = runtime.NewScheme()
+				Expect(corev1.AddToScheme(sch)).To(Succeed())
+				Expect(batchv1.AddToScheme(sch)).To(Succeed())
+				Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+				Expect(v1.AddToScheme(sch)).To(Succeed())
+				c = &operatorClient{
+					Client:      fake.NewFakeClientWithScheme(sch),
+					labels:      map[string]string{"team": "acme"},
+					annotations: map[string]string{"example.com/cost-center": "1234"},
+				}
+			})
+
+			It("should add them to every type of object created", func() {
+				objs := []runtime.Object{
+					&corev1.ConfigMap{}, &corev1.Pod{}, &corev1.ServiceAccount{}, &batchv1.Job{},
+					&v1alpha1.CatalogSource{}, &v1.OperatorGroup{}, &v1alpha1.Subscription{},
+				}
+				for _, obj := range objs {
+					accessor, err := meta.Accessor(obj)
+					Expect(err).NotTo(HaveOccurred())
+					accessor.SetName("created")
+					accessor.SetNamespace(ns)
+					Expect(c.Create(context.TODO(), obj)).To(Succeed())
+
+					key := client.ObjectKey{Namespace: ns, Name: "created"}
+					got := obj.DeepCopyObject()
+					Expect(c.Get(context.TODO(), key, got)).To(Succeed())
+					gotAccessor, err := meta.Accessor(got)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(gotAccessor.GetLabels()).To(HaveKeyWithValue("team", "acme"), "%T", obj)
+					Expect(gotAccessor.GetAnnotations()).To(HaveKeyWithValue("example.com/cost-center", "1234"), "%T", obj)
+				}
+			})
+			It("should not override labels and annotations set on an object", func() {
+				c.labels["owner"] = "someone-else"
+				pod := &corev1.Pod{}
+				pod.SetName("registry")
+				pod.SetNamespace(ns)
+				pod.SetLabels(configmap.MakeRegistryLabels("memcached-operator"))
+				pod.SetAnnotations(map[string]string{"example.com/cost-center": "5678"})
+				Expect(c.Create(context.TODO(), pod)).To(Succeed())
+				Expect(pod.GetLabels()).To(HaveKeyWithValue("owner", "operator-sdk"))
+				Expect(pod.GetLabels()).To(HaveKeyWithValue("team", "acme"))
+				Expect(pod.GetAnnotations()).To(HaveKeyWithValue("example.com/cost-center", "5678"))
+			})
+			It("should let uninstall find labeled objects", func() {
+				cfg := &Configuration{Scheme: sch, Client: c, Namespace: ns}
+				catsrc := &v1alpha1.CatalogSource{}
+				catsrc.SetName("memcached-operator-catalog")
+				catsrc.SetNamespace(ns)
+				catsrc.SetLabels(configmap.MakeRegistryLabels("memcached-operator"))
+				catsrc.SetAnnotations(map[string]string{InstalledCSVAnnotation: "memcached-operator.v0.0.1"})
+				csv := &v1alpha1.ClusterServiceVersion{}
+				csv.SetName("memcached-operator.v0.0.1")
+				csv.SetNamespace(ns)
+				Expect(c.Create(context.TODO(), catsrc)).To(Succeed())
+				Expect(c.Create(context.TODO(), csv)).To(Succeed())
+
+				u := NewUninstall(cfg)
+				u.Package = "memcached-operator"
+				u.Logf = func(string, ...interface{}) {}
+				Expect(u.Run(context.TODO())).To(Succeed())
+				err := c.Get(context.TODO(), client.ObjectKey{Namespace: ns, Name: catsrc.GetName()}, &v1alpha1.CatalogSource{})
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+				err = c.Get(context.TODO(), client.ObjectKey{Namespace: ns, Name: csv.GetName()}, &v1alpha1.ClusterServiceVersion{})
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			})
+		})
+		It("should not annotate other errors", func() {
+			sch := runtime.NewScheme()
+			Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+			c := &operatorClient{
+				Client:      fake.NewFakeClientWithScheme(sch),
+				impersonate: rest.ImpersonationConfig{UserName: "jane"},
+			}
+			err := c.Get(context.TODO(), client.ObjectKey{Name: "sub"}, &v1alpha1.Subscription{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			Expect(errors.As(err, new(*ForbiddenError))).To(BeFalse())
+		})
+	})
+})
@@ -16,4 +16,18 @@ package operator
 
 const (
 	SDKOperatorGroupName = "operator-sdk-og"
+
+	// SDKRegistryServiceAccountName is the ServiceAccount registry pods run as if
+	// none is set. The SDK creates it without any RBAC, since registries do not
+	// use the Kubernetes API.
+	SDKRegistryServiceAccountName = "operator-sdk-registry"
+
+	// ExistingCatalogSourceAnnotation is set to "true" on Subscriptions to
+	// CatalogSources the SDK did not create, which uninstall does not delete.
+	ExistingCatalogSourceAnnotation = "operators.operatorframework.io/existing-catalog-source"
+
+	// InstalledCSVAnnotation is set on CatalogSources the SDK created to the name of
+	// the CSV installed from them, so uninstall can find the CSV once its
+	// Subscription has been deleted.
+	InstalledCSVAnnotation = "operators.operatorframework.io/installed-csv"
 )
@@ -0,0 +1,229 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	v1 "github.com/operator-framework/api/pkg/operators/v1"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// driftCheckedCRDs maps OLM kinds created by the SDK to the names of the CRDs
+// serving them.
+var driftCheckedCRDs = map[schema.GroupKind]string{
+	{Group: v1alpha1.GroupName, Kind: v1alpha1.SubscriptionKind}:          "subscriptions." + v1alpha1.GroupName,
+	{Group: v1alpha1.GroupName, Kind: v1alpha1.ClusterServiceVersionKind}: "clusterserviceversions." + v1alpha1.GroupName,
+	{Group: v1alpha1.GroupName, Kind: v1alpha1.CatalogSourceKind}:         "catalogsources." + v1alpha1.GroupName,
+	{Group: v1.SchemeGroupVersion.Group, Kind: v1.OperatorGroupKind}:      "operatorgroups." + v1.SchemeGroupVersion.Group,
+}
+
+// DriftReport describes differences between an object built from the SDK's
+// vendored OLM API types and the schema served for that object's kind.
+type DriftReport struct {
+	GVK schema.GroupVersionKind
+	// Pruned contains paths of fields set by the SDK that the server will prune.
+	Pruned []string
+	// MissingRequired contains paths of fields required by the server that
+	// the SDK did not set.
+	MissingRequired []string
+}
+
+// HasDrift returns true if r contains any pruned or missing required fields.
+func (r DriftReport) HasDrift() bool {
+	return len(r.Pruned) != 0 || len(r.MissingRequired) != 0
+}
+
+// schemaDriftChecker compares objects against served CRD schemas, which are
+// fetched once per kind and cached.
+type schemaDriftChecker struct {
+	client client.Client
+	scheme *runtime.Scheme
+
+	mu sync.Mutex
+	// schemas is keyed by GVK. A nil value means the schema could not be
+	// found and the kind should not be checked.
+	schemas map[schema.GroupVersionKind]*apiextv1.JSONSchemaProps
+}
+
+func newSchemaDriftChecker(c client.Client, sch *runtime.Scheme) *schemaDriftChecker {
+	return &schemaDriftChecker{
+		client:  c,
+		scheme:  sch,
+		schemas: map[schema.GroupVersionKind]*apiextv1.JSONSchemaProps{},
+	}
+}
+
+// Check returns a DriftReport for obj and obj's unstructured representation
+// with fields the server would prune removed.
+// Objects of kinds not in driftCheckedCRDs, or whose schema can't be fetched,
+// have an empty report.
+func (d *schemaDriftChecker) Check(ctx context.Context, obj runtime.Object) (DriftReport, map[string]interface{}, error) {
+	gvk, err := apiutil.GVKForObject(obj, d.scheme)
+	if err != nil {
+		return DriftReport{}, nil, err
+	}
+	r := DriftReport{GVK: gvk}
+	if _, ok := driftCheckedCRDs[gvk.GroupKind()]; !ok {
+		return r, nil, nil
+	}
+	props := d.getSchema(ctx, gvk)
+	if props == nil {
+		return r, nil, nil
+	}
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return r, nil, err
+	}
+	checkObject(u, props, &r)
+	return r, u, nil
+}
+
+// getSchema returns the cached OpenAPI schema served for gvk, fetching it
+// from gvk's CRD if not yet cached.
+func (d *schemaDriftChecker) getSchema(ctx context.Context, gvk schema.GroupVersionKind) *apiextv1.JSONSchemaProps {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if props, ok := d.schemas[gvk]; ok {
+		return props
+	}
+
+	var props *apiextv1.JSONSchemaProps
+	crd := &apiextv1.CustomResourceDefinition{}
+	key := types.NamespacedName{Name: driftCheckedCRDs[gvk.GroupKind()]}
+	if err := d.client.Get(ctx, key, crd); err != nil {
+		// Users may not be able to read CRDs, in which case creation should proceed as usual.
+		log.Debugf("Skipping schema drift check for %s: get CRD %q: %v", gvk.Kind, key.Name, err)
+	} else {
+		for _, ver := range crd.Spec.Versions {
+			if ver.Name == gvk.Version && ver.Schema != nil {
+				props = ver.Schema.OpenAPIV3Schema
+			}
+		}
+		if props == nil {
+			log.Debugf("Skipping schema drift check for %s: CRD %q serves no schema for version %s",
+				gvk.Kind, key.Name, gvk.Version)
+		}
+	}
+	d.schemas[gvk] = props
+	return props
+}
+
+// checkObject records and deletes fields in u that props does not contain,
+// and records fields props requires that u does not contain. Object metadata
+// and status are not checked, since the SDK does not set them.
+func checkObject(u map[string]interface{}, props *apiextv1.JSONSchemaProps, r *DriftReport) {
+	top := make(map[string]interface{}, len(u))
+	for k, v := range u {
+		switch k {
+		case "apiVersion", "kind", "metadata", "status":
+		default:
+			top[k] = v
+		}
+	}
+	for _, req := range props.Required {
+		switch req {
+		case "apiVersion", "kind", "metadata", "status":
+			continue
+		}
+		if _, ok := u[req]; !ok {
+			r.MissingRequired = append(r.MissingRequired, req)
+		}
+	}
+	checkFields("", top, props, r, u)
+}
+
+// checkFields walks fields in obj against props. Pruned fields are deleted
+// from parent, which is obj unless obj is a filtered view of parent.
+func checkFields(path string, obj map[string]interface{}, props *apiextv1.JSONSchemaProps, r *DriftReport, parent map[string]interface{}) {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := obj[k]
+		if v == nil {
+			continue
+		}
+		fieldPath := joinFieldPath(path, k)
+		var fieldProps *apiextv1.JSONSchemaProps
+		if p, ok := props.Properties[k]; ok {
+			fieldProps = &p
+		} else if ap := props.AdditionalProperties; ap != nil && ap.Schema != nil {
+			fieldProps = ap.Schema
+		} else if !isPreservingUnknownFields(props) {
+			r.Pruned = append(r.Pruned, fieldPath)
+			delete(parent, k)
+			continue
+		}
+		if fieldProps != nil {
+			checkValue(fieldPath, v, fieldProps, r)
+		}
+	}
+}
+
+func checkValue(path string, v interface{}, props *apiextv1.JSONSchemaProps, r *DriftReport) {
+	if props.XPreserveUnknownFields != nil && *props.XPreserveUnknownFields {
+		return
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, req := range props.Required {
+			if _, ok := val[req]; !ok {
+				r.MissingRequired = append(r.MissingRequired, joinFieldPath(path, req))
+			}
+		}
+		checkFields(path, val, props, r, val)
+	case []interface{}:
+		if props.Items == nil || props.Items.Schema == nil {
+			return
+		}
+		for i, item := range val {
+			checkValue(fmt.Sprintf("%s[%d]", path, i), item, props.Items.Schema, r)
+		}
+	}
+}
+
+// isPreservingUnknownFields returns true if props allows fields it does not
+// explicitly declare. Schemas without any declared properties are treated as
+// preserving, since they describe opaque or non-structural values.
+func isPreservingUnknownFields(props *apiextv1.JSONSchemaProps) bool {
+	if props.XPreserveUnknownFields != nil && *props.XPreserveUnknownFields {
+		return true
+	}
+	if ap := props.AdditionalProperties; ap != nil && ap.Allows {
+		return true
+	}
+	return len(props.Properties) == 0
+}
+
+func joinFieldPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
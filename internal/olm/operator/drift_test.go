@@ -0,0 +1,191 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// recordingClient records objects passed to Create without creating them.
+type recordingClient struct {
+	client.Client
+	created []runtime.Object
+}
+
+func (c *recordingClient) Create(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+	c.created = append(c.created, obj)
+	return nil
+}
+
+var _ = Describe("Schema drift", func() {
+	var (
+		sch *runtime.Scheme
+		cl  client.Client
+		d   *schemaDriftChecker
+		sub *v1alpha1.Subscription
+	)
+
+	// newSubscriptionCRD returns a Subscription CRD whose spec schema has
+	// specFields as string properties, and requires specRequired.
+	newSubscriptionCRD := func(specFields []string, specRequired ...string) *apiextv1.CustomResourceDefinition {
+		specProps := map[string]apiextv1.JSONSchemaProps{}
+		for _, f := range specFields {
+			specProps[f] = apiextv1.JSONSchemaProps{Type: "string"}
+		}
+		preserve := true
+		crd := &apiextv1.CustomResourceDefinition{}
+		crd.SetName("subscriptions." + v1alpha1.GroupName)
+		crd.Spec.Versions = []apiextv1.CustomResourceDefinitionVersion{{
+			Name: v1alpha1.GroupVersion,
+			Schema: &apiextv1.CustomResourceValidation{
+				OpenAPIV3Schema: &apiextv1.JSONSchemaProps{
+					Type:     "object",
+					Required: []string{"metadata", "spec"},
+					Properties: map[string]apiextv1.JSONSchemaProps{
+						"apiVersion": {Type: "string"},
+						"kind":       {Type: "string"},
+						"metadata":   {Type: "object"},
+						"spec":       {Type: "object", Properties: specProps, Required: specRequired},
+						"status":     {Type: "object", XPreserveUnknownFields: &preserve},
+					},
+				},
+			},
+		}}
+		return crd
+	}
+
+	BeforeEach(func() {
+		sch = runtime.NewScheme()
+		Expect(corev1.AddToScheme(sch)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+		Expect(apiextv1.AddToScheme(sch)).To(Succeed())
+		cl = fake.NewFakeClientWithScheme(sch)
+		d = newSchemaDriftChecker(cl, sch)
+
+		sub = &v1alpha1.Subscription{}
+		sub.SetName("memcached-operator-sub")
+		sub.SetNamespace("testns")
+		sub.Spec = &v1alpha1.SubscriptionSpec{
+			CatalogSource:          "memcached-operator-catalog",
+			CatalogSourceNamespace: "testns",
+			Package:                "memcached-operator",
+			Channel:                "alpha",
+			StartingCSV:            "memcached-operator.v0.0.1",
+		}
+	})
+
+	Describe("Check", func() {
+		It("should not report drift if the schema matches", func() {
+			Expect(cl.Create(context.TODO(), newSubscriptionCRD(
+				[]string{"source", "sourceNamespace", "name", "channel", "startingCSV"}, "source", "name"))).To(Succeed())
+			r, _, err := d.Check(context.TODO(), sub)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.HasDrift()).To(BeFalse())
+		})
+		It("should detect fields an older schema will prune", func() {
+			Expect(cl.Create(context.TODO(), newSubscriptionCRD(
+				[]string{"source", "sourceNamespace", "name", "channel"}))).To(Succeed())
+			r, u, err := d.Check(context.TODO(), sub)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Pruned).To(Equal([]string{"spec.startingCSV"}))
+			Expect(r.MissingRequired).To(BeEmpty())
+			_, found, err := unstructured.NestedString(u, "spec", "startingCSV")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+		It("should detect fields a newer schema requires", func() {
+			Expect(cl.Create(context.TODO(), newSubscriptionCRD(
+				[]string{"source", "sourceNamespace", "name", "channel", "startingCSV", "newField"}, "source", "newField"))).To(Succeed())
+			r, _, err := d.Check(context.TODO(), sub)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Pruned).To(BeEmpty())
+			Expect(r.MissingRequired).To(Equal([]string{"spec.newField"}))
+		})
+		It("should not check kinds the SDK does not track", func() {
+			cm := &corev1.ConfigMap{}
+			cm.SetName("foo")
+			r, u, err := d.Check(context.TODO(), cm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.HasDrift()).To(BeFalse())
+			Expect(u).To(BeNil())
+		})
+		It("should not check objects whose CRD can't be found", func() {
+			r, u, err := d.Check(context.TODO(), sub)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.HasDrift()).To(BeFalse())
+			Expect(u).To(BeNil())
+		})
+		It("should cache served schemas", func() {
+			crd := newSubscriptionCRD([]string{"source", "sourceNamespace", "name", "channel"})
+			Expect(cl.Create(context.TODO(), crd)).To(Succeed())
+			r, _, err := d.Check(context.TODO(), sub)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Pruned).To(HaveLen(1))
+
+			Expect(cl.Delete(context.TODO(), crd)).To(Succeed())
+			r, _, err = d.Check(context.TODO(), sub)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Pruned).To(HaveLen(1))
+		})
+	})
+
+	Describe("operatorClient.Create", func() {
+		var rc *recordingClient
+
+		BeforeEach(func() {
+			rc = &recordingClient{Client: cl}
+		})
+
+		It("should create typed objects if no fields will be pruned", func() {
+			Expect(cl.Create(context.TODO(), newSubscriptionCRD(
+				[]string{"source", "sourceNamespace", "name", "channel", "startingCSV", "newField"}, "newField"))).To(Succeed())
+			c := &operatorClient{Client: rc, drift: d}
+			Expect(c.Create(context.TODO(), sub)).To(Succeed())
+			Expect(rc.created).To(HaveLen(1))
+			Expect(rc.created[0]).To(BeIdenticalTo(sub))
+		})
+		It("should fall back to unstructured objects if fields will be pruned", func() {
+			Expect(cl.Create(context.TODO(), newSubscriptionCRD(
+				[]string{"source", "sourceNamespace", "name", "channel"}))).To(Succeed())
+			c := &operatorClient{Client: rc, drift: d}
+			Expect(c.Create(context.TODO(), sub)).To(Succeed())
+			Expect(rc.created).To(HaveLen(1))
+			u, ok := rc.created[0].(*unstructured.Unstructured)
+			Expect(ok).To(BeTrue())
+			Expect(u.GetKind()).To(Equal(v1alpha1.SubscriptionKind))
+			Expect(u.GetName()).To(Equal(sub.GetName()))
+			// The typed object reflects what the server stores.
+			Expect(sub.Spec.StartingCSV).To(BeEmpty())
+			Expect(sub.Spec.Package).To(Equal("memcached-operator"))
+		})
+		It("should create typed objects if drift checks are disabled", func() {
+			Expect(cl.Create(context.TODO(), newSubscriptionCRD(
+				[]string{"source", "sourceNamespace", "name", "channel"}))).To(Succeed())
+			c := &operatorClient{Client: rc}
+			Expect(c.Create(context.TODO(), sub)).To(Succeed())
+			Expect(rc.created[0]).To(BeIdenticalTo(sub))
+		})
+	})
+})
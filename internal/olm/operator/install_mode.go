@@ -140,3 +140,81 @@ func GetSupportedInstallModes(csvInstallModes []v1alpha1.InstallMode) sets.Strin
 	}
 	return supported
 }
+
+// WatchNamespacesUsage is the usage string of WatchNamespaces flags.
+const WatchNamespacesUsage = "Comma-separated namespaces the operator watches, from which its install mode is inferred: " +
+	"empty for AllNamespaces, the install namespace for OwnNamespace, another namespace for SingleNamespace, " +
+	"or several namespaces for MultiNamespace. Mutually exclusive with --install-mode"
+
+// WatchNamespaces is a flag value listing namespaces an operator watches,
+// set from comma-separated namespaces. An empty value means all namespaces.
+// The install mode is inferred from the list with InferInstallMode.
+type WatchNamespaces struct {
+	Namespaces []string
+	set        bool
+}
+
+var _ flag.Value = &WatchNamespaces{}
+
+func (w *WatchNamespaces) Set(str string) error {
+	w.set = true
+	for _, ns := range strings.Split(str, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "" {
+			continue
+		}
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+			return fmt.Errorf("invalid watch namespace %q: %v", ns, strings.Join(errs, ", "))
+		}
+		if !sets.NewString(w.Namespaces...).Has(ns) {
+			w.Namespaces = append(w.Namespaces, ns)
+		}
+	}
+	sort.Strings(w.Namespaces)
+	return nil
+}
+
+// IsSet returns true if w was set, even to all namespaces.
+func (w WatchNamespaces) IsSet() bool {
+	return w.set
+}
+
+func (w WatchNamespaces) String() string {
+	return strings.Join(w.Namespaces, ",")
+}
+
+func (WatchNamespaces) Type() string {
+	return "strings"
+}
+
+// InferInstallMode returns the install mode an operator in operatorNamespace
+// needs to watch watchNamespaces: AllNamespaces if empty, OwnNamespace if
+// only operatorNamespace, SingleNamespace if one other namespace, and
+// MultiNamespace otherwise. An error listing csv's supported install modes
+// is returned if csv does not support the inferred mode.
+func InferInstallMode(watchNamespaces []string, operatorNamespace string, csv *v1alpha1.ClusterServiceVersion) (InstallMode, error) {
+	var mode InstallMode
+	switch {
+	case len(watchNamespaces) == 0:
+		mode = InstallMode{InstallModeType: v1alpha1.InstallModeTypeAllNamespaces}
+	case len(watchNamespaces) == 1 && watchNamespaces[0] == operatorNamespace:
+		mode = InstallMode{InstallModeType: v1alpha1.InstallModeTypeOwnNamespace}
+	case len(watchNamespaces) == 1:
+		mode = InstallMode{InstallModeType: v1alpha1.InstallModeTypeSingleNamespace, TargetNamespaces: watchNamespaces}
+	default:
+		mode = InstallMode{InstallModeType: v1alpha1.InstallModeTypeMultiNamespace, TargetNamespaces: watchNamespaces}
+	}
+	if err := mode.Validate(); err != nil {
+		return InstallMode{}, err
+	}
+
+	supported := GetSupportedInstallModes(csv.Spec.InstallModes)
+	if supported.Len() == 0 {
+		return InstallMode{}, fmt.Errorf("operator %q is not installable: no supported install modes", csv.GetName())
+	}
+	if !supported.Has(string(mode.InstallModeType)) {
+		return InstallMode{}, fmt.Errorf("operator %q does not support install mode %s, inferred from watch namespaces %+q; supported install modes: %s",
+			csv.GetName(), mode.InstallModeType, watchNamespaces, strings.Join(supported.List(), ", "))
+	}
+	return mode, nil
+}
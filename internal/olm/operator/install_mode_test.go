@@ -16,6 +16,7 @@ package operator
 
 import (
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
 )
@@ -63,4 +64,91 @@ var _ = Describe("InstallMode", func() {
 			Expect(supported.Has(string(v1alpha1.InstallModeTypeAllNamespaces))).Should(BeFalse())
 		})
 	})
+
+	Describe("WatchNamespaces", func() {
+		It("should be set to all namespaces by an empty value", func() {
+			w := WatchNamespaces{}
+			Expect(w.IsSet()).To(BeFalse())
+			Expect(w.Set("")).To(Succeed())
+			Expect(w.IsSet()).To(BeTrue())
+			Expect(w.Namespaces).To(BeEmpty())
+		})
+		It("should sort and deduplicate namespaces", func() {
+			w := WatchNamespaces{}
+			Expect(w.Set("ns2, ns1")).To(Succeed())
+			Expect(w.Set("ns1")).To(Succeed())
+			Expect(w.Namespaces).To(Equal([]string{"ns1", "ns2"}))
+			Expect(w.String()).To(Equal("ns1,ns2"))
+		})
+		It("should reject invalid namespaces", func() {
+			w := WatchNamespaces{}
+			Expect(w.Set("Bad_NS")).To(MatchError(ContainSubstring(`invalid watch namespace "Bad_NS"`)))
+		})
+	})
+
+	Describe("InferInstallMode", func() {
+		const operatorNamespace = "opns"
+		all := []v1alpha1.InstallModeType{
+			v1alpha1.InstallModeTypeOwnNamespace,
+			v1alpha1.InstallModeTypeSingleNamespace,
+			v1alpha1.InstallModeTypeMultiNamespace,
+			v1alpha1.InstallModeTypeAllNamespaces,
+		}
+		newCSV := func(supported ...v1alpha1.InstallModeType) *v1alpha1.ClusterServiceVersion {
+			csv := &v1alpha1.ClusterServiceVersion{}
+			csv.SetName("memcached-operator.v0.0.1")
+			for _, t := range all {
+				mode := v1alpha1.InstallMode{Type: t}
+				for _, s := range supported {
+					mode.Supported = mode.Supported || s == t
+				}
+				csv.Spec.InstallModes = append(csv.Spec.InstallModes, mode)
+			}
+			return csv
+		}
+
+		DescribeTable("should infer the install mode from watch namespaces",
+			func(watchNamespaces []string, expected InstallMode) {
+				mode, err := InferInstallMode(watchNamespaces, operatorNamespace, newCSV(all...))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mode).To(Equal(expected))
+			},
+			Entry("for no namespaces", []string{},
+				InstallMode{InstallModeType: v1alpha1.InstallModeTypeAllNamespaces}),
+			Entry("for the operator namespace", []string{operatorNamespace},
+				InstallMode{InstallModeType: v1alpha1.InstallModeTypeOwnNamespace}),
+			Entry("for another namespace", []string{"ns1"},
+				InstallMode{InstallModeType: v1alpha1.InstallModeTypeSingleNamespace, TargetNamespaces: []string{"ns1"}}),
+			Entry("for several namespaces", []string{"ns1", "ns2"},
+				InstallMode{InstallModeType: v1alpha1.InstallModeTypeMultiNamespace, TargetNamespaces: []string{"ns1", "ns2"}}),
+			Entry("for several namespaces including the operator namespace", []string{"ns1", operatorNamespace},
+				InstallMode{InstallModeType: v1alpha1.InstallModeTypeMultiNamespace, TargetNamespaces: []string{"ns1", operatorNamespace}}),
+		)
+
+		DescribeTable("should fail if the CSV does not support the inferred install mode",
+			func(watchNamespaces []string, supported []v1alpha1.InstallModeType, msg string) {
+				_, err := InferInstallMode(watchNamespaces, operatorNamespace, newCSV(supported...))
+				Expect(err).To(MatchError(msg))
+			},
+			Entry("for AllNamespaces", []string{},
+				[]v1alpha1.InstallModeType{v1alpha1.InstallModeTypeOwnNamespace, v1alpha1.InstallModeTypeSingleNamespace},
+				`operator "memcached-operator.v0.0.1" does not support install mode AllNamespaces, inferred from watch namespaces []; `+
+					`supported install modes: OwnNamespace, SingleNamespace`),
+			Entry("for OwnNamespace", []string{operatorNamespace},
+				[]v1alpha1.InstallModeType{v1alpha1.InstallModeTypeAllNamespaces},
+				`operator "memcached-operator.v0.0.1" does not support install mode OwnNamespace, inferred from watch namespaces ["opns"]; `+
+					`supported install modes: AllNamespaces`),
+			Entry("for SingleNamespace", []string{"ns1"},
+				[]v1alpha1.InstallModeType{v1alpha1.InstallModeTypeOwnNamespace},
+				`operator "memcached-operator.v0.0.1" does not support install mode SingleNamespace, inferred from watch namespaces ["ns1"]; `+
+					`supported install modes: OwnNamespace`),
+			Entry("for MultiNamespace", []string{"ns1", "ns2"},
+				[]v1alpha1.InstallModeType{v1alpha1.InstallModeTypeSingleNamespace, v1alpha1.InstallModeTypeAllNamespaces},
+				`operator "memcached-operator.v0.0.1" does not support install mode MultiNamespace, inferred from watch namespaces ["ns1" "ns2"]; `+
+					`supported install modes: AllNamespaces, SingleNamespace`),
+			Entry("for a CSV without supported install modes", []string{},
+				[]v1alpha1.InstallModeType{},
+				`operator "memcached-operator.v0.0.1" is not installable: no supported install modes`),
+		)
+	})
 })
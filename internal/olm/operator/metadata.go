@@ -0,0 +1,105 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
+)
+
+// MetadataValue is a flag value that adds "<key>=<value>" pairs to labels or
+// annotations, either comma-separated or with the flag repeated.
+type MetadataValue struct {
+	m        *map[string]string
+	validate func(k, v string) error
+}
+
+var _ pflag.Value = &MetadataValue{}
+
+// NewLabelsValue returns a flag value that adds labels to m. Labels the SDK
+// finds its objects by are reserved.
+func NewLabelsValue(m *map[string]string) *MetadataValue {
+	return &MetadataValue{m: m, validate: validateLabel}
+}
+
+// NewAnnotationsValue returns a flag value that adds annotations to m.
+func NewAnnotationsValue(m *map[string]string) *MetadataValue {
+	return &MetadataValue{m: m, validate: validateAnnotation}
+}
+
+func (v *MetadataValue) Set(str string) error {
+	m := map[string]string{}
+	for k, val := range *v.m {
+		m[k] = val
+	}
+	for _, pair := range strings.Split(str, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		split := strings.SplitN(pair, "=", 2)
+		if len(split) != 2 {
+			return fmt.Errorf("invalid %q: must be of the form <key>=<value>", pair)
+		}
+		if err := v.validate(split[0], split[1]); err != nil {
+			return err
+		}
+		m[split[0]] = split[1]
+	}
+	*v.m = m
+	return nil
+}
+
+func validateLabel(k, v string) error {
+	if errs := validation.IsQualifiedName(k); len(errs) != 0 {
+		return fmt.Errorf("invalid label key %q: %s", k, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsValidLabelValue(v); len(errs) != 0 {
+		return fmt.Errorf("invalid label value %q of key %q: %s", v, k, strings.Join(errs, ", "))
+	}
+	if _, ok := configmap.MakeRegistryLabels("")[k]; ok {
+		return fmt.Errorf("label %q is reserved by operator-sdk", k)
+	}
+	return nil
+}
+
+func validateAnnotation(k, _ string) error {
+	if errs := validation.IsQualifiedName(k); len(errs) != 0 {
+		return fmt.Errorf("invalid annotation key %q: %s", k, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func (v *MetadataValue) String() string {
+	if v.m == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*v.m))
+	for k, val := range *v.m {
+		pairs = append(pairs, k+"="+val)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (*MetadataValue) Type() string {
+	return "MetadataValue"
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MetadataValue", func() {
+	var m map[string]string
+
+	BeforeEach(func() {
+		m = nil
+	})
+
+	Context("for labels", func() {
+		var v *MetadataValue
+
+		BeforeEach(func() {
+			v = NewLabelsValue(&m)
+		})
+
+		It("should add pairs when set repeatedly", func() {
+			Expect(v.Set("team=acme, tier=")).To(Succeed())
+			Expect(v.Set("env=dev")).To(Succeed())
+			Expect(m).To(Equal(map[string]string{"team": "acme", "tier": "", "env": "dev"}))
+			Expect(v.String()).To(Equal("env=dev,team=acme,tier="))
+		})
+		It("should reject pairs without a value", func() {
+			Expect(v.Set("team")).To(MatchError(ContainSubstring("must be of the form <key>=<value>")))
+		})
+		It("should reject invalid label keys and values", func() {
+			Expect(v.Set("my team=acme")).To(MatchError(ContainSubstring(`invalid label key "my team"`)))
+			Expect(v.Set("team=a/b")).To(MatchError(ContainSubstring(`invalid label value "a/b"`)))
+			Expect(m).To(BeNil())
+		})
+		It("should reject labels operator-sdk finds its objects by", func() {
+			Expect(v.Set("owner=me")).To(MatchError(`label "owner" is reserved by operator-sdk`))
+			Expect(v.Set("package-name=foo")).To(MatchError(`label "package-name" is reserved by operator-sdk`))
+			Expect(m).To(BeNil())
+		})
+	})
+
+	Context("for annotations", func() {
+		var v *MetadataValue
+
+		BeforeEach(func() {
+			v = NewAnnotationsValue(&m)
+		})
+
+		It("should allow any value", func() {
+			Expect(v.Set("example.com/owner=Jane Doe <jane@example.com>")).To(Succeed())
+			Expect(m).To(Equal(map[string]string{"example.com/owner": "Jane Doe <jane@example.com>"}))
+		})
+		It("should reject invalid annotation keys", func() {
+			Expect(v.Set("cost center=42")).To(MatchError(ContainSubstring(`invalid annotation key "cost center"`)))
+			Expect(m).To(BeNil())
+		})
+	})
+})
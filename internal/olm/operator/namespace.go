@@ -0,0 +1,57 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
+)
+
+// EnsureNamespace creates cfg's namespace if it does not exist, labeled with
+// configmap.SDKLabels so it may be deleted by `cleanup --delete-namespace`.
+// Existing namespaces, including one created concurrently, are left unchanged.
+// Returns true if the namespace was created.
+func EnsureNamespace(ctx context.Context, cfg *Configuration) (bool, error) {
+	name := cfg.Namespace
+	err := cfg.Client.Get(ctx, types.NamespacedName{Name: name}, &corev1.Namespace{})
+	if err == nil {
+		return false, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("get namespace %q: %v", name, err)
+	}
+
+	ns := &corev1.Namespace{}
+	ns.SetName(name)
+	ns.SetLabels(make(map[string]string, len(configmap.SDKLabels)))
+	for k, v := range configmap.SDKLabels {
+		ns.Labels[k] = v
+	}
+	if err := cfg.Client.Create(ctx, ns); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("create namespace %q: %v", name, err)
+	}
+	log.Infof("Created namespace %q", name)
+	return true, nil
+}
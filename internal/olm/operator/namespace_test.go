@@ -0,0 +1,105 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "github.com/operator-framework/api/pkg/operators/v1"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("EnsureNamespace", func() {
+	const ns = "test-xyz"
+
+	var cfg *Configuration
+
+	getNamespace := func() (*corev1.Namespace, error) {
+		namespace := &corev1.Namespace{}
+		err := cfg.Client.Get(context.TODO(), types.NamespacedName{Name: ns}, namespace)
+		return namespace, err
+	}
+
+	BeforeEach(func() {
+		sch := runtime.NewScheme()
+		Expect(corev1.AddToScheme(sch)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+		Expect(v1.AddToScheme(sch)).To(Succeed())
+		Expect(batchv1.AddToScheme(sch)).To(Succeed())
+		cfg = &Configuration{
+			Scheme:    sch,
+			Client:    fake.NewFakeClientWithScheme(sch),
+			Namespace: ns,
+		}
+	})
+
+	It("should create and label a namespace that does not exist", func() {
+		created, err := EnsureNamespace(context.TODO(), cfg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created).To(BeTrue())
+		namespace, err := getNamespace()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(namespace.GetLabels()).To(HaveKeyWithValue("owner", "operator-sdk"))
+	})
+	It("should not label a namespace that already exists", func() {
+		existing := &corev1.Namespace{}
+		existing.SetName(ns)
+		existing.SetLabels(map[string]string{"team": "acme"})
+		Expect(cfg.Client.Create(context.TODO(), existing)).To(Succeed())
+
+		created, err := EnsureNamespace(context.TODO(), cfg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created).To(BeFalse())
+		namespace, err := getNamespace()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(namespace.GetLabels()).To(Equal(map[string]string{"team": "acme"}))
+	})
+	It("should be a no-op when called again", func() {
+		_, err := EnsureNamespace(context.TODO(), cfg)
+		Expect(err).NotTo(HaveOccurred())
+		created, err := EnsureNamespace(context.TODO(), cfg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created).To(BeFalse())
+	})
+	It("should create a namespace uninstall deletes with DeleteNamespace", func() {
+		_, err := EnsureNamespace(context.TODO(), cfg)
+		Expect(err).NotTo(HaveOccurred())
+		sub := &v1alpha1.Subscription{}
+		sub.SetName("acme-sub")
+		sub.SetNamespace(ns)
+		sub.Spec = &v1alpha1.SubscriptionSpec{Package: "acme-thing", CatalogSource: "acme-thing-catalog", CatalogSourceNamespace: ns}
+		Expect(cfg.Client.Create(context.TODO(), sub)).To(Succeed())
+		catsrc := &v1alpha1.CatalogSource{}
+		catsrc.SetName("acme-thing-catalog")
+		catsrc.SetNamespace(ns)
+		Expect(cfg.Client.Create(context.TODO(), catsrc)).To(Succeed())
+
+		u := NewUninstall(cfg)
+		u.Package = "acme-thing"
+		u.DeleteNamespace = true
+		u.Logf = func(string, ...interface{}) {}
+		Expect(u.Run(context.TODO())).To(Succeed())
+		_, err = getNamespace()
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,220 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Option identifies a field of an options struct and the CLI flag or argument
+// that sets it.
+type Option struct {
+	// Field is the field's path in its options struct, ex. "OperatorInstaller.StartingCSV".
+	Field string
+	// Flag is the CLI flag or argument that sets Field, ex. "--version".
+	// Empty if Field cannot be set from the CLI.
+	Flag string
+	// IsSet returns true if Field has a non-zero value.
+	IsSet func() bool
+}
+
+// StringOption returns an Option for a string field, which is set if non-empty.
+func StringOption(field, flag string, v *string) Option {
+	return Option{Field: field, Flag: flag, IsSet: func() bool { return *v != "" }}
+}
+
+// BoolOption returns an Option for a bool field, which is set if true.
+func BoolOption(field, flag string, v *bool) Option {
+	return Option{Field: field, Flag: flag, IsSet: func() bool { return *v }}
+}
+
+func (o Option) String() string {
+	if o.Flag == "" {
+		return o.Field
+	}
+	return fmt.Sprintf("%s (%s)", o.Field, o.Flag)
+}
+
+// OptionRule constrains one or more options.
+type OptionRule struct {
+	Options []Option
+	check   func([]Option) error
+}
+
+// MutuallyExclusive returns a rule allowing at most one of opts to be set.
+func MutuallyExclusive(opts ...Option) OptionRule {
+	return OptionRule{Options: opts, check: func(opts []Option) error {
+		var set []Option
+		for _, o := range opts {
+			if o.IsSet() {
+				set = append(set, o)
+			}
+		}
+		if len(set) > 1 {
+			return fmt.Errorf("%s are mutually exclusive", joinOptions(set))
+		}
+		return nil
+	}}
+}
+
+// Requires returns a rule requiring each of deps to be set if opt is set.
+func Requires(opt Option, deps ...Option) OptionRule {
+	return OptionRule{Options: append([]Option{opt}, deps...), check: func(opts []Option) error {
+		if !opts[0].IsSet() {
+			return nil
+		}
+		var unset []Option
+		for _, o := range opts[1:] {
+			if !o.IsSet() {
+				unset = append(unset, o)
+			}
+		}
+		if len(unset) != 0 {
+			return fmt.Errorf("%s requires %s to be set", opts[0], joinOptions(unset))
+		}
+		return nil
+	}}
+}
+
+// Required returns a rule requiring opt to be set.
+func Required(opt Option) OptionRule {
+	return OptionRule{Options: []Option{opt}, check: func(opts []Option) error {
+		if !opts[0].IsSet() {
+			return fmt.Errorf("%s must be set", opts[0])
+		}
+		return nil
+	}}
+}
+
+// Constraint returns a rule checking the values of opts with check.
+func Constraint(check func() error, opts ...Option) OptionRule {
+	return OptionRule{Options: opts, check: func(opts []Option) error {
+		if err := check(); err != nil {
+			return fmt.Errorf("%s: %v", joinOptions(opts), err)
+		}
+		return nil
+	}}
+}
+
+func joinOptions(opts []Option) string {
+	strs := make([]string, len(opts))
+	for i, o := range opts {
+		strs[i] = o.String()
+	}
+	return strings.Join(strs, ", ")
+}
+
+// OptionRules is the table of rules constraining an options struct's fields.
+type OptionRules struct {
+	Rules []OptionRule
+	// Unconstrained contains paths of fields that intentionally have no rules,
+	// ex. those set by the struct itself while running.
+	Unconstrained []string
+}
+
+// Validate evaluates every rule in r, and returns an error naming each
+// violated rule if any are violated.
+func (r OptionRules) Validate() error {
+	var errs []error
+	for _, rule := range r.Rules {
+		if err := rule.check(rule.Options); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid options: %v", utilerrors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// Embed returns a copy of r with field paths prefixed by field, the name of
+// the embedded struct r constrains.
+func (r OptionRules) Embed(field string) OptionRules {
+	out := OptionRules{}
+	for _, rule := range r.Rules {
+		opts := make([]Option, len(rule.Options))
+		for i, o := range rule.Options {
+			o.Field = field + "." + o.Field
+			opts[i] = o
+		}
+		out.Rules = append(out.Rules, OptionRule{Options: opts, check: rule.check})
+	}
+	for _, f := range r.Unconstrained {
+		out.Unconstrained = append(out.Unconstrained, field+"."+f)
+	}
+	return out
+}
+
+// Append returns r with the rules and unconstrained fields of others added.
+func (r OptionRules) Append(others ...OptionRules) OptionRules {
+	out := OptionRules{
+		Rules:         append([]OptionRule{}, r.Rules...),
+		Unconstrained: append([]string{}, r.Unconstrained...),
+	}
+	for _, o := range others {
+		out.Rules = append(out.Rules, o.Rules...)
+		out.Unconstrained = append(out.Unconstrained, o.Unconstrained...)
+	}
+	return out
+}
+
+// Uncovered returns sorted paths of exported fields of the struct opts that
+// are neither in a rule of r nor unconstrained. Fields of embedded structs are
+// prefixed by the embedded struct's name.
+func (r OptionRules) Uncovered(opts interface{}) []string {
+	covered := sets.NewString(r.Unconstrained...)
+	for _, rule := range r.Rules {
+		for _, o := range rule.Options {
+			covered.Insert(o.Field)
+		}
+	}
+	var uncovered []string
+	for _, f := range getOptionFields("", reflect.TypeOf(opts)) {
+		if !covered.Has(f) {
+			uncovered = append(uncovered, f)
+		}
+	}
+	sort.Strings(uncovered)
+	return uncovered
+}
+
+// getOptionFields returns paths of exported fields of the struct typ.
+func getOptionFields(prefix string, typ reflect.Type) (fields []string) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct {
+			fields = append(fields, getOptionFields(prefix+f.Name+".", ft)...)
+			continue
+		}
+		fields = append(fields, prefix+f.Name)
+	}
+	return fields
+}
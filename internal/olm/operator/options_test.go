@@ -0,0 +1,135 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OptionRules", func() {
+	var (
+		a, b, c string
+		rules   OptionRules
+	)
+
+	BeforeEach(func() {
+		a, b, c = "", "", ""
+		optA := StringOption("A", "--a", &a)
+		optB := StringOption("B", "--b", &b)
+		optC := StringOption("C", "", &c)
+		rules = OptionRules{Rules: []OptionRule{
+			MutuallyExclusive(optA, optB),
+			Requires(optC, optA),
+		}}
+	})
+
+	Describe("Validate", func() {
+		It("should succeed if no rules are violated", func() {
+			a = "a"
+			Expect(rules.Validate()).To(Succeed())
+		})
+		It("should name the fields and flags of mutually exclusive options", func() {
+			a, b = "a", "b"
+			Expect(rules.Validate()).To(MatchError("invalid options: A (--a), B (--b) are mutually exclusive"))
+		})
+		It("should name unset required options", func() {
+			c = "c"
+			Expect(rules.Validate()).To(MatchError("invalid options: C requires A (--a) to be set"))
+		})
+		It("should report every violated rule", func() {
+			rules.Rules = append(rules.Rules, Required(StringOption("D", "--d", new(string))))
+			a, b, c = "a", "b", "c"
+			err := rules.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("A (--a), B (--b) are mutually exclusive"))
+			Expect(err.Error()).To(ContainSubstring("D (--d) must be set"))
+		})
+		It("should prefix constraint errors with the constrained options", func() {
+			rules = OptionRules{Rules: []OptionRule{
+				Constraint(func() error { return errors.New("bad value") }, StringOption("A", "--a", &a)),
+			}}
+			Expect(rules.Validate()).To(MatchError("invalid options: A (--a): bad value"))
+		})
+	})
+
+	Describe("Embed", func() {
+		It("should prefix field paths", func() {
+			c = "c"
+			rules.Unconstrained = []string{"E"}
+			embedded := rules.Embed("Inner")
+			Expect(embedded.Unconstrained).To(Equal([]string{"Inner.E"}))
+			Expect(embedded.Validate()).To(MatchError("invalid options: Inner.C requires Inner.A (--a) to be set"))
+		})
+	})
+
+	Describe("Uncovered", func() {
+		type Inner struct {
+			E string
+		}
+		type opts struct {
+			A, B, C string
+			D       bool
+			*Inner
+			unexported string
+		}
+
+		It("should return exported fields not in any rule", func() {
+			Expect(rules.Uncovered(opts{})).To(Equal([]string{"D", "Inner.E"}))
+		})
+		It("should not return unconstrained fields", func() {
+			rules.Unconstrained = []string{"D", "Inner.E"}
+			Expect(rules.Uncovered(&opts{})).To(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("Uninstall options", func() {
+	var u *Uninstall
+
+	BeforeEach(func() {
+		u = NewUninstall(&Configuration{})
+		u.Package = "memcached-operator"
+	})
+
+	It("should constrain every option", func() {
+		Expect(u.OptionRules().Uncovered(u)).To(BeEmpty())
+	})
+
+	DescribeTable("should report violated rules",
+		func(f func(*Uninstall), msg string) {
+			f(u)
+			Expect(u.Validate()).To(MatchError(ContainSubstring(msg)))
+		},
+		Entry("without a package", func(u *Uninstall) { u.Package = "" },
+			"Package (<operatorPackageName>) must be set"),
+		Entry("with --force but not --delete-namespace", func(u *Uninstall) { u.Force = true },
+			"Force (--force) requires DeleteNamespace (--delete-namespace) to be set"),
+		Entry("with operator group names but without deleting operator groups", func(u *Uninstall) {
+			u.DeleteOperatorGroupNames = []string{SDKOperatorGroupName}
+		}, "DeleteOperatorGroupNames requires DeleteOperatorGroups to be set"),
+		Entry("with --yes but not --all-namespaces", func(u *Uninstall) { u.Yes = true },
+			"Yes (--yes) requires AllNamespaces (--all-namespaces) to be set"),
+	)
+
+	It("should accept operator group names if all objects are deleted", func() {
+		u.DeleteAll = true
+		u.DeleteOperatorGroupNames = []string{SDKOperatorGroupName}
+		Expect(u.Validate()).To(Succeed())
+	})
+})
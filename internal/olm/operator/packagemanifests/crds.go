@@ -0,0 +1,177 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagemanifests
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	log "github.com/sirupsen/logrus"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
+)
+
+// minV1beta1CRDRemovedKubeVersion is the first Kubernetes version that no
+// longer serves apiextensions.k8s.io/v1beta1 CustomResourceDefinitions.
+var minV1beta1CRDRemovedKubeVersion = semver.MustParse("1.22.0")
+
+// checkOwnedCRDs returns an error if a CRD version owned by b's CSV is not
+// defined by one of b's CRDs, which may be any mix of apiextensions.k8s.io
+// v1 and v1beta1 CRDs.
+func checkOwnedCRDs(b *apimanifests.Bundle) error {
+	defined := map[string]bool{}
+	for _, crd := range b.V1CRDs {
+		for _, key := range k8sutil.DefinitionsForV1CustomResourceDefinitions(*crd) {
+			defined[key.Name+"/"+key.Version] = true
+		}
+	}
+	for _, crd := range b.V1beta1CRDs {
+		for _, key := range k8sutil.DefinitionsForV1beta1CustomResourceDefinitions(*crd) {
+			defined[key.Name+"/"+key.Version] = true
+		}
+	}
+	var missing []string
+	for _, desc := range b.CSV.Spec.CustomResourceDefinitions.Owned {
+		if !defined[desc.Name+"/"+desc.Version] {
+			missing = append(missing, fmt.Sprintf("%s (version %s)", desc.Name, desc.Version))
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("CSV %s owns CRDs not defined in its bundle: %s", b.CSV.GetName(), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkCRDAPIVersions returns an error if the cluster discovered by dc no longer
+// serves v1beta1 CRDs and any bundle in bundles contains one, since the
+// server would reject it only once the install plan is executed. If the
+// cluster's version can't be found, no error is returned.
+func checkCRDAPIVersions(dc discovery.ServerVersionInterface, bundles ...*apimanifests.Bundle) error {
+	var v1beta1CRDs []string
+	for _, b := range bundles {
+		for _, crd := range b.V1beta1CRDs {
+			v1beta1CRDs = append(v1beta1CRDs, fmt.Sprintf("%s (bundle %s)", crd.GetName(), b.CSV.GetName()))
+		}
+	}
+	if len(v1beta1CRDs) == 0 {
+		return nil
+	}
+
+	info, err := dc.ServerVersion()
+	if err != nil {
+		log.Debugf("Failed to get Kubernetes version, not checking CRD API versions: %v", err)
+		return nil
+	}
+	ver, err := semver.ParseTolerant(info.GitVersion)
+	if err != nil {
+		log.Debugf("Failed to parse Kubernetes version %q, not checking CRD API versions: %v", info.GitVersion, err)
+		return nil
+	}
+	// Compare release versions only, so pre-releases of 1.22 are included.
+	ver.Pre, ver.Build = nil, nil
+	if ver.LT(minV1beta1CRDRemovedKubeVersion) {
+		return nil
+	}
+	return fmt.Errorf("the cluster's Kubernetes version %s does not serve %s CustomResourceDefinitions, which must be migrated to %s: %s",
+		info.GitVersion, apiextv1beta1.SchemeGroupVersion, apiextv1.SchemeGroupVersion, strings.Join(v1beta1CRDs, ", "))
+}
+
+// stripCRDs returns copies of bundles without CRD manifests, whose CSVs own
+// no CRDs, so OLM neither creates nor adopts CRDs when installing them.
+func stripCRDs(bundles []*apimanifests.Bundle) []*apimanifests.Bundle {
+	stripped := make([]*apimanifests.Bundle, len(bundles))
+	for i, b := range bundles {
+		sb := *b
+		sb.V1CRDs, sb.V1beta1CRDs = nil, nil
+		sb.CSV = b.CSV.DeepCopy()
+		sb.CSV.Spec.CustomResourceDefinitions.Owned = nil
+		sb.Objects = nil
+		for _, obj := range b.Objects {
+			switch obj.GetKind() {
+			case "CustomResourceDefinition":
+				continue
+			case "ClusterServiceVersion":
+				obj = obj.DeepCopy()
+				unstructured.RemoveNestedField(obj.Object, "spec", "customresourcedefinitions", "owned")
+			}
+			sb.Objects = append(sb.Objects, obj)
+		}
+		stripped[i] = &sb
+	}
+	return stripped
+}
+
+// checkSkippedCRDs returns an error containing a line for each CRD version
+// owned by a CSV in bundles that is not served by the cluster, since those
+// CRDs are expected to be managed outside of OLM.
+func checkSkippedCRDs(ctx context.Context, c client.Client, bundles ...*apimanifests.Bundle) error {
+	// served maps CRD names to their served versions, or nil if not found.
+	served := map[string][]string{}
+	var diff []string
+	for _, b := range bundles {
+		for _, desc := range b.CSV.Spec.CustomResourceDefinitions.Owned {
+			versions, ok := served[desc.Name]
+			if !ok {
+				crd := &apiextv1.CustomResourceDefinition{}
+				if err := c.Get(ctx, types.NamespacedName{Name: desc.Name}, crd); err != nil {
+					if !apierrors.IsNotFound(err) {
+						return fmt.Errorf("error getting CRD %s: %v", desc.Name, err)
+					}
+				} else {
+					versions = []string{}
+					for _, v := range crd.Spec.Versions {
+						if v.Served {
+							versions = append(versions, v.Name)
+						}
+					}
+					sort.Strings(versions)
+				}
+				served[desc.Name] = versions
+			}
+			switch {
+			case versions == nil:
+				diff = append(diff, fmt.Sprintf("  %s (CSV %s): expected served version %s, CRD not found",
+					desc.Name, b.CSV.GetName(), desc.Version))
+			case !containsString(versions, desc.Version):
+				diff = append(diff, fmt.Sprintf("  %s (CSV %s): expected served version %s, found served versions [%s]",
+					desc.Name, b.CSV.GetName(), desc.Version, strings.Join(versions, ", ")))
+			}
+		}
+	}
+	if len(diff) != 0 {
+		return fmt.Errorf("skipped CRDs are not served by the cluster as expected:\n%s", strings.Join(diff, "\n"))
+	}
+	return nil
+}
+
+func containsString(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
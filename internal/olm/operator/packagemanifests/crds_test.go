@@ -0,0 +1,184 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagemanifests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/fbc"
+)
+
+// serverVersion is a discovery.ServerVersionInterface returning a fixed version.
+type serverVersion struct {
+	gitVersion string
+	err        error
+}
+
+func (v serverVersion) ServerVersion() (*version.Info, error) {
+	return &version.Info{GitVersion: v.gitVersion}, v.err
+}
+
+var _ = Describe("CRD loading", func() {
+	var (
+		pkg    *apimanifests.PackageManifest
+		bundle *apimanifests.Bundle
+	)
+
+	BeforeEach(func() {
+		var bundles []*apimanifests.Bundle
+		var err error
+		pkg, bundles, err = loadPackageManifests(filepath.Join("testdata", "mixed-crds-operator"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bundles).To(HaveLen(1))
+		bundle = bundles[0]
+	})
+
+	It("should load v1 and v1beta1 CRDs from the same bundle", func() {
+		Expect(bundle.V1CRDs).To(HaveLen(1))
+		Expect(bundle.V1CRDs[0].GetName()).To(Equal("widgets.example.com"))
+		Expect(bundle.V1beta1CRDs).To(HaveLen(1))
+		Expect(bundle.V1beta1CRDs[0].GetName()).To(Equal("gadgets.example.com"))
+		Expect(checkOwnedCRDs(bundle)).To(Succeed())
+	})
+
+	It("should preserve v1 schemas and conversion strategies in the catalog", func() {
+		cfg, err := fbc.New(pkg, []*apimanifests.Bundle{bundle})
+		Expect(err).NotTo(HaveOccurred())
+		var crd *unstructured.Unstructured
+		for _, p := range cfg.Bundles[0].Properties {
+			if p.Type != fbc.PropertyBundleObject {
+				continue
+			}
+			v := struct {
+				Data []byte `json:"data"`
+			}{}
+			Expect(json.Unmarshal(p.Value, &v)).To(Succeed())
+			u := &unstructured.Unstructured{}
+			Expect(u.UnmarshalJSON(v.Data)).To(Succeed())
+			if u.GetName() == "widgets.example.com" {
+				crd = u
+			}
+		}
+		Expect(crd).NotTo(BeNil())
+		Expect(crd.GetAPIVersion()).To(Equal("apiextensions.k8s.io/v1"))
+		strategy, _, err := unstructured.NestedString(crd.Object, "spec", "conversion", "strategy")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strategy).To(Equal("None"))
+		versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(HaveLen(1))
+		required, _, err := unstructured.NestedStringSlice(versions[0].(map[string]interface{}),
+			"schema", "openAPIV3Schema", "properties", "spec", "required")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(required).To(Equal([]string{"size"}))
+	})
+
+	It("should fail if the CSV owns a CRD version not in the bundle", func() {
+		bundle.CSV.Spec.CustomResourceDefinitions.Owned = append(bundle.CSV.Spec.CustomResourceDefinitions.Owned,
+			v1alpha1.CRDDescription{Name: "widgets.example.com", Version: "v1beta1", Kind: "Widget"})
+		Expect(checkOwnedCRDs(bundle)).To(MatchError("CSV mixed-crds-operator.v0.0.1 owns CRDs not defined in its bundle: " +
+			"widgets.example.com (version v1beta1)"))
+	})
+
+	Describe("checkCRDAPIVersions", func() {
+		It("should fail for v1beta1 CRDs on Kubernetes 1.22 and newer", func() {
+			for _, v := range []string{"v1.22.0", "v1.22.0-rc.0", "v1.25.3+k3s1"} {
+				err := checkCRDAPIVersions(serverVersion{gitVersion: v}, bundle)
+				Expect(err).To(MatchError(ContainSubstring("Kubernetes version " + v + " does not serve apiextensions.k8s.io/v1beta1")))
+				Expect(err).To(MatchError(ContainSubstring("gadgets.example.com (bundle mixed-crds-operator.v0.0.1)")))
+			}
+		})
+		It("should allow v1beta1 CRDs on older Kubernetes versions", func() {
+			Expect(checkCRDAPIVersions(serverVersion{gitVersion: "v1.21.2"}, bundle)).To(Succeed())
+		})
+		It("should allow v1 CRDs on any Kubernetes version", func() {
+			bundle.V1beta1CRDs = nil
+			Expect(checkCRDAPIVersions(serverVersion{gitVersion: "v1.25.0"}, bundle)).To(Succeed())
+		})
+		It("should not fail if the Kubernetes version can't be found", func() {
+			Expect(checkCRDAPIVersions(serverVersion{err: errors.New("connection refused")}, bundle)).To(Succeed())
+		})
+	})
+
+	Describe("stripCRDs", func() {
+		It("should remove CRDs from copies of bundles", func() {
+			stripped := stripCRDs([]*apimanifests.Bundle{bundle})
+			Expect(stripped).To(HaveLen(1))
+			sb := stripped[0]
+			Expect(sb.V1CRDs).To(BeEmpty())
+			Expect(sb.V1beta1CRDs).To(BeEmpty())
+			Expect(sb.CSV.Spec.CustomResourceDefinitions.Owned).To(BeEmpty())
+			Expect(sb.Objects).To(HaveLen(1))
+			owned, found, err := unstructured.NestedSlice(sb.Objects[0].Object, "spec", "customresourcedefinitions", "owned")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+			Expect(owned).To(BeEmpty())
+
+			Expect(bundle.V1CRDs).To(HaveLen(1))
+			Expect(bundle.CSV.Spec.CustomResourceDefinitions.Owned).To(HaveLen(2))
+			Expect(bundle.Objects).To(HaveLen(3))
+		})
+	})
+
+	Describe("checkSkippedCRDs", func() {
+		var sch *runtime.Scheme
+
+		newCRD := func(name string, versions ...apiextv1.CustomResourceDefinitionVersion) *apiextv1.CustomResourceDefinition {
+			crd := &apiextv1.CustomResourceDefinition{}
+			crd.SetName(name)
+			crd.Spec.Versions = versions
+			return crd
+		}
+
+		BeforeEach(func() {
+			sch = runtime.NewScheme()
+			Expect(apiextv1.AddToScheme(sch)).To(Succeed())
+		})
+
+		It("should succeed if each owned CRD version is served", func() {
+			cl := fake.NewFakeClientWithScheme(sch,
+				newCRD("widgets.example.com", apiextv1.CustomResourceDefinitionVersion{Name: "v1alpha1", Served: true}),
+				newCRD("gadgets.example.com",
+					apiextv1.CustomResourceDefinitionVersion{Name: "v1", Served: true},
+					apiextv1.CustomResourceDefinitionVersion{Name: "v1alpha1", Served: true}),
+			)
+			Expect(checkSkippedCRDs(context.TODO(), cl, bundle)).To(Succeed())
+		})
+		It("should fail with each owned CRD version that is not served", func() {
+			cl := fake.NewFakeClientWithScheme(sch,
+				newCRD("widgets.example.com",
+					apiextv1.CustomResourceDefinitionVersion{Name: "v1alpha1", Served: false},
+					apiextv1.CustomResourceDefinitionVersion{Name: "v1beta1", Served: true}),
+			)
+			Expect(checkSkippedCRDs(context.TODO(), cl, bundle)).To(MatchError(
+				"skipped CRDs are not served by the cluster as expected:\n" +
+					"  gadgets.example.com (CSV mixed-crds-operator.v0.0.1): expected served version v1alpha1, CRD not found\n" +
+					"  widgets.example.com (CSV mixed-crds-operator.v0.0.1): expected served version v1alpha1, found served versions [v1beta1]"))
+		})
+	})
+})
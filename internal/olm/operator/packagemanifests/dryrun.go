@@ -0,0 +1,58 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagemanifests
+
+import (
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+
+	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+)
+
+// writeObjects writes objs to w as a multi-document YAML stream. Fields set
+// only by the server, like status, are omitted.
+func writeObjects(w io.Writer, objs []runtime.Object) error {
+	for _, obj := range objs {
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		if gvk.Empty() {
+			if gvk, err = apiutil.GVKForObject(obj, olmclient.Scheme); err != nil {
+				return err
+			}
+		}
+		u["apiVersion"], u["kind"] = gvk.ToAPIVersionAndKind()
+		delete(u, "status")
+		unstructured.RemoveNestedField(u, "metadata", "creationTimestamp")
+		unstructured.RemoveNestedField(u, "spec", "template", "metadata", "creationTimestamp")
+
+		b, err := yaml.Marshal(u)
+		if err != nil {
+			name, _, _ := unstructured.NestedString(u, "metadata", "name")
+			return fmt.Errorf("error marshaling %s %q: %v", gvk.Kind, name, err)
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
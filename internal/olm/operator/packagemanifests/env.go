@@ -0,0 +1,184 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagemanifests
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// envOverridesValue is a repeatable flag value that appends environment
+// variables of the form "<name>=<value>" to envs.
+type envOverridesValue struct {
+	envs *[]corev1.EnvVar
+}
+
+var _ pflag.Value = envOverridesValue{}
+
+func (v envOverridesValue) Set(str string) error {
+	split := strings.SplitN(str, "=", 2)
+	if len(split) != 2 || split[0] == "" {
+		return fmt.Errorf("invalid environment variable %q: must be of the form <name>=<value>", str)
+	}
+	*v.envs = append(*v.envs, corev1.EnvVar{Name: split[0], Value: split[1]})
+	return nil
+}
+
+func (v envOverridesValue) String() string {
+	if v.envs == nil {
+		return ""
+	}
+	strs := make([]string, len(*v.envs))
+	for i, env := range *v.envs {
+		strs[i] = env.Name + "=" + env.Value
+	}
+	return strings.Join(strs, ",")
+}
+
+func (envOverridesValue) Type() string {
+	return "stringArray"
+}
+
+// validateEnvOverrides returns an error if any of envs has an invalid name,
+// or a name set more than once.
+func validateEnvOverrides(envs []corev1.EnvVar) error {
+	seen := map[string]bool{}
+	for _, env := range envs {
+		if errs := validation.IsEnvVarName(env.Name); len(errs) != 0 {
+			return fmt.Errorf("invalid environment variable name %q: %s", env.Name, strings.Join(errs, ", "))
+		}
+		if seen[env.Name] {
+			return fmt.Errorf("environment variable %s is set more than once", env.Name)
+		}
+		seen[env.Name] = true
+	}
+	return nil
+}
+
+// applyEnvOverrides returns copies of bundles whose CSVs set envs in every
+// container of their install strategy Deployments, replacing variables of the
+// same name. CSVs are modified as unstructured objects, which are what
+// registries serve, and decoded again so each bundle's CSV matches its object.
+func applyEnvOverrides(bundles []*apimanifests.Bundle, envs []corev1.EnvVar) ([]*apimanifests.Bundle, error) {
+	out := make([]*apimanifests.Bundle, len(bundles))
+	for i, b := range bundles {
+		ob := *b
+		ob.Objects = make([]*unstructured.Unstructured, len(b.Objects))
+		for j, obj := range b.Objects {
+			if obj.GetKind() == v1alpha1.ClusterServiceVersionKind {
+				obj = obj.DeepCopy()
+				if err := setDeploymentEnvs(obj, envs); err != nil {
+					return nil, fmt.Errorf("error overriding environment of CSV %s: %v", obj.GetName(), err)
+				}
+				csv, err := decodeCSV(obj)
+				if err != nil {
+					return nil, fmt.Errorf("error decoding CSV %s: %v", obj.GetName(), err)
+				}
+				ob.CSV = csv
+			}
+			ob.Objects[j] = obj
+		}
+		out[i] = &ob
+	}
+	return out, nil
+}
+
+// setDeploymentEnvs sets envs in each container of csv's install strategy Deployments.
+func setDeploymentEnvs(csv *unstructured.Unstructured, envs []corev1.EnvVar) error {
+	deps, _, err := unstructured.NestedSlice(csv.Object, "spec", "install", "spec", "deployments")
+	if err != nil {
+		return err
+	}
+	for _, d := range deps {
+		dep, ok := d.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("install strategy deployment is not an object")
+		}
+		depName, _, _ := unstructured.NestedString(dep, "name")
+		containers, _, err := unstructured.NestedSlice(dep, "spec", "template", "spec", "containers")
+		if err != nil {
+			return fmt.Errorf("deployment %s: %v", depName, err)
+		}
+		for k, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("deployment %s: container is not an object", depName)
+			}
+			containerName, _, _ := unstructured.NestedString(container, "name")
+			containerEnv, _, err := unstructured.NestedSlice(container, "env")
+			if err != nil {
+				return fmt.Errorf("deployment %s container %s: %v", depName, containerName, err)
+			}
+			for _, env := range envs {
+				newVar := map[string]interface{}{"name": env.Name, "value": env.Value}
+				replaced := false
+				for n, e := range containerEnv {
+					if old, ok := e.(map[string]interface{}); ok && old["name"] == env.Name {
+						log.Infof("Overriding environment variable %s=%s with %q in CSV %s Deployment %s container %s",
+							env.Name, describeEnvValue(old), env.Value, csv.GetName(), depName, containerName)
+						containerEnv[n] = newVar
+						replaced = true
+					}
+				}
+				if !replaced {
+					containerEnv = append(containerEnv, newVar)
+				}
+			}
+			if err := unstructured.SetNestedSlice(container, containerEnv, "env"); err != nil {
+				return err
+			}
+			containers[k] = container
+		}
+		if err := unstructured.SetNestedSlice(dep, containers, "spec", "template", "spec", "containers"); err != nil {
+			return err
+		}
+	}
+	if len(deps) == 0 {
+		return nil
+	}
+	return unstructured.SetNestedSlice(csv.Object, deps, "spec", "install", "spec", "deployments")
+}
+
+// describeEnvValue returns env's quoted value, or the kind of its source.
+func describeEnvValue(env map[string]interface{}) string {
+	if from, ok := env["valueFrom"].(map[string]interface{}); ok {
+		for kind := range from {
+			return "<from " + kind + ">"
+		}
+	}
+	value, _ := env["value"].(string)
+	return fmt.Sprintf("%q", value)
+}
+
+func decodeCSV(obj *unstructured.Unstructured) (*v1alpha1.ClusterServiceVersion, error) {
+	b, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	csv := &v1alpha1.ClusterServiceVersion{}
+	if err := json.Unmarshal(b, csv); err != nil {
+		return nil, err
+	}
+	return csv, nil
+}
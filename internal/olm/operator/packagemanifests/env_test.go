@@ -0,0 +1,141 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagemanifests
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const envCSV = `apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: memcached-operator.v0.0.2
+spec:
+  install:
+    spec:
+      deployments:
+      - name: memcached-operator-controller-manager
+        spec:
+          selector:
+            matchLabels:
+              control-plane: controller-manager
+          template:
+            metadata:
+              labels:
+                control-plane: controller-manager
+            spec:
+              containers:
+              - name: kube-rbac-proxy
+                image: gcr.io/kubebuilder/kube-rbac-proxy:v0.5.0
+              - name: manager
+                image: quay.io/example/memcached-operator:v0.0.2
+                env:
+                - name: WATCH_NAMESPACE
+                  valueFrom:
+                    fieldRef:
+                      fieldPath: metadata.annotations['olm.targetNamespaces']
+                - name: LOG_LEVEL
+                  value: info
+    strategy: deployment
+  version: 0.0.2
+`
+
+var _ = Describe("Env overrides", func() {
+	Describe("envOverridesValue", func() {
+		var (
+			envs []corev1.EnvVar
+			v    envOverridesValue
+		)
+
+		BeforeEach(func() {
+			envs = nil
+			v = envOverridesValue{envs: &envs}
+		})
+
+		It("should append variables in order", func() {
+			Expect(v.Set("WATCH_NAMESPACE=testns")).To(Succeed())
+			Expect(v.Set("FEATURE_GATES=Foo=true,Bar=false")).To(Succeed())
+			Expect(v.Set("EMPTY=")).To(Succeed())
+			Expect(envs).To(Equal([]corev1.EnvVar{
+				{Name: "WATCH_NAMESPACE", Value: "testns"},
+				{Name: "FEATURE_GATES", Value: "Foo=true,Bar=false"},
+				{Name: "EMPTY", Value: ""},
+			}))
+			Expect(v.String()).To(Equal("WATCH_NAMESPACE=testns,FEATURE_GATES=Foo=true,Bar=false,EMPTY="))
+		})
+		It("should reject malformed values", func() {
+			Expect(v.Set("WATCH_NAMESPACE")).To(MatchError(ContainSubstring(`invalid environment variable "WATCH_NAMESPACE"`)))
+			Expect(v.Set("=testns")).To(MatchError(ContainSubstring(`invalid environment variable "=testns"`)))
+		})
+	})
+
+	Describe("applyEnvOverrides", func() {
+		var bundle *apimanifests.Bundle
+
+		getContainerEnvs := func(csv *v1alpha1.ClusterServiceVersion) map[string][]corev1.EnvVar {
+			envs := map[string][]corev1.EnvVar{}
+			for _, c := range csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs[0].Spec.Template.Spec.Containers {
+				envs[c.Name] = c.Env
+			}
+			return envs
+		}
+
+		BeforeEach(func() {
+			obj := &unstructured.Unstructured{}
+			Expect(yaml.Unmarshal([]byte(envCSV), &obj.Object)).To(Succeed())
+			csv, err := decodeCSV(obj)
+			Expect(err).NotTo(HaveOccurred())
+			bundle = &apimanifests.Bundle{Name: csv.GetName(), CSV: csv, Objects: []*unstructured.Unstructured{obj}}
+		})
+
+		It("should replace and add variables in every container", func() {
+			bundles, err := applyEnvOverrides([]*apimanifests.Bundle{bundle}, []corev1.EnvVar{
+				{Name: "WATCH_NAMESPACE", Value: "testns"},
+				{Name: "FEATURE_GATES", Value: "Foo=true"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bundles).To(HaveLen(1))
+			Expect(getContainerEnvs(bundles[0].CSV)).To(Equal(map[string][]corev1.EnvVar{
+				"kube-rbac-proxy": {
+					{Name: "WATCH_NAMESPACE", Value: "testns"},
+					{Name: "FEATURE_GATES", Value: "Foo=true"},
+				},
+				"manager": {
+					{Name: "WATCH_NAMESPACE", Value: "testns"},
+					{Name: "LOG_LEVEL", Value: "info"},
+					{Name: "FEATURE_GATES", Value: "Foo=true"},
+				},
+			}))
+			// The served object matches the bundle's CSV.
+			csv, err := decodeCSV(bundles[0].Objects[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(csv).To(Equal(bundles[0].CSV))
+		})
+		It("should not modify the original bundles", func() {
+			_, err := applyEnvOverrides([]*apimanifests.Bundle{bundle}, []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(getContainerEnvs(bundle.CSV)["manager"][1]).To(Equal(corev1.EnvVar{Name: "LOG_LEVEL", Value: "info"}))
+			csv, err := decodeCSV(bundle.Objects[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(csv).To(Equal(bundle.CSV))
+		})
+	})
+})
@@ -18,30 +18,100 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
 
+	"github.com/blang/semver"
 	apimanifests "github.com/operator-framework/api/pkg/manifests"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/discovery"
 
 	"github.com/operator-framework/operator-sdk/internal/olm/operator"
 	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
+	"github.com/operator-framework/operator-sdk/internal/olm/snapshot"
 )
 
+// Dry run strategies, named after kubectl's.
+const (
+	DryRunNone   = "none"
+	DryRunClient = "client"
+)
+
+// registryPodSchedulingUsage describes which pods registry scheduling flags apply to.
+const registryPodSchedulingUsage = "Applies to the registry pod serving the catalog from ConfigMaps. " +
+	"Can't be set with --use-registry-image, since OLM creates the pod serving a registry image"
+
 type Install struct {
 	PackageManifestsDirectory string
 	Version                   string
+	// PackageDirectories are package manifests root directories of additional
+	// packages, ex. the operator's dependencies, served by the same catalog and
+	// subscribed to after the operator's package. OLM resolves their install order.
+	PackageDirectories []string
+	// PackageVersions maps package names to versions to install, and takes
+	// the place of Version for the operator's package if it contains it.
+	// Additional packages without a version are installed at the head of
+	// their default channel.
+	PackageVersions map[string]string
+	// IncludeVersions and ExcludeVersions select the versions of the
+	// operator's package served by the catalog. All versions are included if
+	// IncludeVersions is empty. The installed version and the versions in its
+	// replaces chain are always served.
+	IncludeVersions []string
+	ExcludeVersions []string
+	// Channel is the only channel served by the catalog and subscribed to. If
+	// set, Version must be reachable from the channel's head by its replaces
+	// chain. If empty, the channel whose head is Version is subscribed to, and
+	// all channels are served.
+	Channel string
+	// CatalogFormat is the format the catalog is served in. If empty, it is
+	// selected based on the on-cluster OLM version.
+	CatalogFormat string
+	// DryRun is either DryRunNone (the default) or DryRunClient, in which case
+	// objects are written to stdout as YAML instead of being created.
+	DryRun string
+	// UseRegistryImage builds and pushes a registry image serving the catalog,
+	// which the CatalogSource pulls, instead of storing manifests in ConfigMaps.
+	UseRegistryImage bool
+	// SkipCRDs removes CRD manifests from the catalog and owned CRDs from the
+	// served CSVs, so OLM installs the operator without creating or adopting
+	// CRDs managed elsewhere. Each CRD version owned by a CSV to install must
+	// already be served by the cluster. Skipped CRDs are never deleted on uninstall,
+	// since they are not part of the install plan.
+	SkipCRDs bool
+	// EnvOverrides are set in every container of the install strategy
+	// Deployments of each served CSV of the operator's package, replacing
+	// variables of the same name. Additional packages are not modified.
+	EnvOverrides []corev1.EnvVar
+	// SnapshotFile is a cluster snapshot, written by 'olm snapshot', to run
+	// preflight checks against instead of installing the operator. Checks
+	// reading state not captured in the snapshot are skipped.
+	SnapshotFile string
 
 	*registry.ConfigMapCatalogCreator
+	*registry.ImageCatalogCreator
 	*registry.OperatorInstaller
 
 	cfg *operator.Configuration
+	// out is where dry run output is written. Defaults to stdout.
+	out io.Writer
+	// installBundles are the bundles of each package to install, set by setup.
+	installBundles []*apimanifests.Bundle
 }
 
 func NewInstall(cfg *operator.Configuration) Install {
 	i := Install{
 		ConfigMapCatalogCreator: registry.NewConfigMapCatalogCreator(cfg),
+		ImageCatalogCreator:     registry.NewImageCatalogCreator(cfg),
 		OperatorInstaller:       registry.NewOperatorInstaller(cfg),
 		cfg:                     cfg,
+		out:                     os.Stdout,
 	}
 	i.OperatorInstaller.CatalogCreator = i.ConfigMapCatalogCreator
 	return i
@@ -49,53 +119,413 @@ func NewInstall(cfg *operator.Configuration) Install {
 
 func (i *Install) BindFlags(fs *pflag.FlagSet) {
 	fs.Var(&i.InstallMode, "install-mode", "install mode")
-	fs.StringVar(&i.Version, "version", "", "Packaged version of the operator to deploy")
+	fs.Var(&i.WatchNamespaces, "watch-namespaces", operator.WatchNamespacesUsage)
+	fs.Var(versionsValue{version: &i.Version, versions: &i.PackageVersions}, "version",
+		"Packaged version of the operator to deploy. Versions of packages in --package-dir are set as "+
+			"<package>=<version>, and this flag can be repeated to set each one")
+	fs.StringArrayVar(&i.PackageDirectories, "package-dir", nil,
+		"Package manifests root directory of an additional package, ex. a dependency of the operator, "+
+			"served by the same catalog and installed with it. This flag can be repeated")
+	fs.StringVar(&i.Channel, "channel", "",
+		"Channel to deploy the operator from, which must contain --version. If set, only this channel is served by the catalog. "+
+			"Defaults to the channel whose current CSV is --version")
+	fs.StringSliceVar(&i.IncludeVersions, "include-versions", nil,
+		"Comma-separated versions of the operator to serve from the catalog. Defaults to all versions. "+
+			"--version and the versions it replaces are always served")
+	fs.StringSliceVar(&i.ExcludeVersions, "exclude-versions", nil,
+		"Comma-separated versions of the operator not to serve from the catalog, except those --version replaces")
+	fs.BoolVar(&i.SkipCleanupOrphans, "skip-cleanup-orphans", false,
+		"Do not delete registry objects left behind by previous installs of this package")
+	fs.Var(catalogFormatValue{&i.CatalogFormat}, "catalog-format",
+		fmt.Sprintf("Format of the generated catalog, one of: %s, %s. Defaults to %s if supported by the on-cluster OLM version, "+
+			"otherwise %s, and the format used is logged",
+			registry.CatalogFormatConfigMap, registry.CatalogFormatFBC, registry.CatalogFormatFBC, registry.CatalogFormatConfigMap))
+	fs.Var(operator.NewResourceRequirementsValue(&i.RegistryResources), "registry-resources",
+		"Resource requests and limits of the registry server container, "+
+			"ex. \"requests.cpu=100m,requests.memory=128Mi,limits.memory=512Mi\"")
+	fs.Var(&i.SecurityContextConfig, "security-context-config", operator.SecurityContextConfigUsage)
+	fs.Var(operator.NewNodeSelectorValue(&i.RegistryNodeSelector), "registry-pod-node-selector",
+		"Node selector of the registry pod, as <key>=<value> label pairs, ex. \"node-role.kubernetes.io/infra=\". "+
+			"This flag can be repeated. "+registryPodSchedulingUsage)
+	fs.Var(operator.NewTolerationsFileValue(&i.RegistryTolerations), "registry-pod-tolerations-file",
+		"YAML file containing a list of tolerations of the registry pod, as in a pod spec, ex. to schedule it "+
+			"on tainted nodes. "+registryPodSchedulingUsage)
+	fs.StringVar(&i.DryRun, "dry-run", DryRunNone,
+		fmt.Sprintf("Must be %q or %q. If %q, print the objects that would be created as YAML without creating them. "+
+			"The catalog format defaults to %s, and server-generated values are replaced by placeholders",
+			DryRunNone, DryRunClient, DryRunClient, registry.CatalogFormatConfigMap))
+	fs.StringVar(&i.ConfigMapCatalogCreator.RegistryImage, "registry-base-image", "",
+		fmt.Sprintf("Image the registry server is run from, ex. a mirrored image. Must contain /bin/initializer and "+
+			"/bin/registry-server for the %s catalog format, or an opm supporting 'opm serve' at /bin/opm for %s, "+
+			"which the registry pod checks before serving. Defaults to %s or %s respectively. "+
+			"If --use-registry-image is set, the registry image is built from this image instead",
+			registry.CatalogFormatConfigMap, registry.CatalogFormatFBC, configmap.DefaultRegistryBaseImage, configmap.DefaultFBCRegistryImage))
+	fs.BoolVar(&i.UseRegistryImage, "use-registry-image", false,
+		"Build a registry image serving the catalog and push it to --registry-image, "+
+			"instead of serving manifests from ConfigMaps")
+	fs.StringVar(&i.ImageCatalogCreator.Image, "registry-image", "",
+		"Image reference to push the registry image to if --use-registry-image is set, which must be pullable from the cluster")
+	fs.StringVar(&i.ContainerTool, "container-tool", "",
+		fmt.Sprintf("Tool to build and push the registry image with, one of: %s, %s. Defaults to %s",
+			registry.ContainerToolDocker, registry.ContainerToolPodman, registry.ContainerToolDocker))
+	fs.BoolVar(&i.SkipCRDs, "skip-crds", false,
+		"Do not serve or install the operator's CRDs, which must already be served by the cluster at the versions its CSV owns. "+
+			"Skipped CRDs are not deleted by cleanup")
+	fs.Var(envOverridesValue{envs: &i.EnvOverrides}, "env",
+		"Environment variable to set in the operator's Deployment containers, of the form <name>=<value>, "+
+			"replacing any variable of the same name in the CSV. This flag can be repeated")
+	fs.StringVar(&i.PullSecret, "pull-secret", "",
+		"Name of a kubernetes.io/dockerconfigjson Secret in the install namespace used to push and pull the registry image")
+	fs.StringVar(&i.SnapshotFile, "snapshot-file", "",
+		"Cluster snapshot written by 'olm snapshot' to run preflight checks against, without accessing the cluster, "+
+			"instead of installing the operator. Checks of state not captured in the snapshot are reported as skipped")
+	i.OperatorInstaller.BindStageTimeoutFlags(fs)
+}
+
+// Validate returns an error describing each of i's option rules that are violated.
+func (i Install) Validate() error {
+	return i.OptionRules().Validate()
+}
+
+// OptionRules returns the rules constraining i's fields, including those of
+// its embedded structs.
+func (i Install) OptionRules() operator.OptionRules {
+	useRegistryImage := operator.BoolOption("UseRegistryImage", "--use-registry-image", &i.UseRegistryImage)
+	includeVersions := operator.Option{Field: "IncludeVersions", Flag: "--include-versions", IsSet: func() bool { return len(i.IncludeVersions) != 0 }}
+	excludeVersions := operator.Option{Field: "ExcludeVersions", Flag: "--exclude-versions", IsSet: func() bool { return len(i.ExcludeVersions) != 0 }}
+	rules := operator.OptionRules{
+		Rules: []operator.OptionRule{
+			operator.Required(operator.StringOption("PackageManifestsDirectory", "[packagemanifests-root-dir]", &i.PackageManifestsDirectory)),
+			operator.Required(operator.Option{Field: "Version", Flag: "--version", IsSet: func() bool {
+				return i.Version != "" || len(i.PackageVersions) != 0
+			}}),
+			operator.Constraint(func() error {
+				return validateCatalogFormat(i.CatalogFormat)
+			}, operator.StringOption("CatalogFormat", "--catalog-format", &i.CatalogFormat)),
+			operator.Constraint(func() error {
+				switch i.DryRun {
+				case "", DryRunNone, DryRunClient:
+					return nil
+				}
+				return fmt.Errorf("unknown dry run strategy %q, must be one of: %s, %s", i.DryRun, DryRunNone, DryRunClient)
+			}, operator.StringOption("DryRun", "--dry-run", &i.DryRun)),
+			operator.Constraint(func() error {
+				exclude := sets.NewString(i.ExcludeVersions...)
+				if both := exclude.Intersection(sets.NewString(i.IncludeVersions...)); both.Len() != 0 {
+					return fmt.Errorf("versions both included and excluded: %s", strings.Join(both.List(), ", "))
+				}
+				if i.Version != "" && exclude.Has(i.Version) {
+					return fmt.Errorf("version %s to install is excluded", i.Version)
+				}
+				return nil
+			}, includeVersions, excludeVersions),
+			operator.Constraint(func() error {
+				return validateEnvOverrides(i.EnvOverrides)
+			}, operator.Option{Field: "EnvOverrides", Flag: "--env", IsSet: func() bool { return len(i.EnvOverrides) != 0 }}),
+			operator.Requires(operator.StringOption("ImageCatalogCreator.Image", "--registry-image", &i.ImageCatalogCreator.Image), useRegistryImage),
+			operator.Requires(operator.StringOption("ImageCatalogCreator.ContainerTool", "--container-tool", &i.ContainerTool), useRegistryImage),
+			operator.Requires(operator.StringOption("ImageCatalogCreator.PullSecret", "--pull-secret", &i.PullSecret), useRegistryImage),
+			operator.Constraint(func() error {
+				if i.UseRegistryImage && i.DryRun == DryRunClient {
+					return errors.New("a registry image is not built in a dry run")
+				}
+				return nil
+			}, useRegistryImage, operator.StringOption("DryRun", "--dry-run", &i.DryRun)),
+			operator.Constraint(func() error {
+				if i.UseRegistryImage && i.CatalogFormat != "" && i.CatalogFormat != registry.CatalogFormatFBC {
+					return fmt.Errorf("a registry image serves the %s catalog format", registry.CatalogFormatFBC)
+				}
+				return nil
+			}, useRegistryImage, operator.StringOption("CatalogFormat", "--catalog-format", &i.CatalogFormat)),
+			operator.MutuallyExclusive(operator.StringOption("SnapshotFile", "--snapshot-file", &i.SnapshotFile), useRegistryImage),
+			// OLM creates the pod serving a registry image, whose scheduling can't be configured.
+			operator.MutuallyExclusive(operator.Option{Field: "ConfigMapCatalogCreator.RegistryNodeSelector", Flag: "--registry-pod-node-selector",
+				IsSet: func() bool { return len(i.RegistryNodeSelector) != 0 }}, useRegistryImage),
+			operator.MutuallyExclusive(operator.Option{Field: "ConfigMapCatalogCreator.RegistryTolerations", Flag: "--registry-pod-tolerations-file",
+				IsSet: func() bool { return len(i.RegistryTolerations) != 0 }}, useRegistryImage),
+			operator.Constraint(func() error {
+				if i.SnapshotFile != "" && i.DryRun == DryRunClient {
+					return errors.New("preflight checks against a snapshot are not run in a dry run")
+				}
+				return nil
+			}, operator.StringOption("SnapshotFile", "--snapshot-file", &i.SnapshotFile), operator.StringOption("DryRun", "--dry-run", &i.DryRun)),
+		},
+		// Whether Channel exists and contains Version, and which packages
+		// PackageVersions may name, depends on the package manifests.
+		Unconstrained: []string{"Channel", "PackageDirectories", "PackageVersions", "SkipCRDs"},
+	}
+	imageRules := i.ImageCatalogCreator.OptionRules().Embed("ImageCatalogCreator")
+	if !i.UseRegistryImage {
+		// Registry image options only apply if a registry image is built.
+		imageRules.Rules = nil
+	}
+	return rules.Append(
+		i.ConfigMapCatalogCreator.OptionRules().Embed("ConfigMapCatalogCreator"),
+		imageRules,
+		i.OperatorInstaller.OptionRules().Embed("OperatorInstaller"),
+	)
 }
 
 func (i Install) Run(ctx context.Context) (*v1alpha1.ClusterServiceVersion, error) {
+	if err := i.Validate(); err != nil {
+		return nil, err
+	}
 	if err := i.setup(); err != nil {
 		return nil, err
 	}
+	if i.SnapshotFile != "" {
+		return nil, i.runSnapshotPreflights(ctx)
+	}
+
+	if i.DryRun != DryRunClient {
+		if i.SkipCRDs {
+			if err := checkSkippedCRDs(ctx, i.cfg.Client, i.installBundles...); err != nil {
+				return nil, err
+			}
+		} else if i.cfg.RESTConfig != nil {
+			dc, err := discovery.NewDiscoveryClientForConfig(i.cfg.RESTConfig)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkCRDAPIVersions(dc, i.installBundles...); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if i.UseRegistryImage {
+		// Build and push before creating any cluster objects so a failure
+		// leaves nothing behind.
+		if i.ConfigMapCatalogCreator.RegistryImage != "" {
+			i.ImageCatalogCreator.BaseImage = i.ConfigMapCatalogCreator.RegistryImage
+		}
+		if err := i.ImageCatalogCreator.BuildAndPush(ctx); err != nil {
+			return nil, err
+		}
+		i.OperatorInstaller.CatalogCreator = i.ImageCatalogCreator
+		return i.InstallOperator(ctx)
+	}
+
+	format := i.CatalogFormat
+	if format == "" && i.DryRun == DryRunClient {
+		// The on-cluster OLM version is not looked up for dry runs.
+		format = registry.CatalogFormatConfigMap
+	}
+	format, err := registry.ResolveCatalogFormat(ctx, i.cfg, format)
+	if err != nil {
+		return nil, err
+	}
+	i.ConfigMapCatalogCreator.Format = format
+
+	if i.DryRun == DryRunClient {
+		objs, err := i.RenderInstall()
+		if err != nil {
+			return nil, err
+		}
+		return nil, writeObjects(i.out, objs)
+	}
 	return i.InstallOperator(ctx)
 }
 
+// runSnapshotPreflights runs the checks Run does before installing, as well as
+// the OperatorInstaller's preflight checks, against i's snapshot, and writes a
+// report to i.out. An error is returned if any check failed.
+func (i Install) runSnapshotPreflights(ctx context.Context) error {
+	s, err := snapshot.Load(i.SnapshotFile, 0)
+	if err != nil {
+		return err
+	}
+	// The OperatorInstaller shares i.cfg, so its checks read the snapshot too.
+	i.cfg.Client = snapshot.NewReader(s, i.cfg.Scheme).Client()
+
+	checks := i.OperatorInstaller.PreflightChecks(s)
+	if i.SkipCRDs {
+		checks = append(checks, registry.PreflightCheck{Name: "skipped-crds", Run: func(ctx context.Context) error {
+			return checkSkippedCRDs(ctx, i.cfg.Client, i.installBundles...)
+		}})
+	} else {
+		checks = append(checks, registry.PreflightCheck{Name: "crd-api-versions", Run: func(context.Context) error {
+			return checkCRDAPIVersions(s, i.installBundles...)
+		}})
+	}
+	results := registry.RunPreflights(ctx, checks)
+	if err := registry.WritePreflightReport(i.out, results); err != nil {
+		return err
+	}
+	return registry.PreflightError(results)
+}
+
 func (i *Install) setup() error {
 	pkg, bundles, err := loadPackageManifests(i.PackageManifestsDirectory)
 	if err != nil {
 		return fmt.Errorf("load package manifests: %v", err)
 	}
-	bundle, err := getPackageForVersion(bundles, i.Version)
+	version := i.Version
+	if v, ok := i.PackageVersions[pkg.PackageName]; ok {
+		if version != "" && version != v {
+			return fmt.Errorf("conflicting versions %s and %s set for package %s", version, v, pkg.PackageName)
+		}
+		version = v
+	}
+	if version == "" {
+		return fmt.Errorf("no version set for package %s", pkg.PackageName)
+	}
+	bundle, err := getPackageForVersion(bundles, version)
 	if err != nil {
 		return err
 	}
 
-	if err := i.InstallMode.CheckCompatibility(bundle.CSV, i.cfg.Namespace); err != nil {
+	if err := i.OperatorInstaller.ResolveInstallMode(bundle.CSV); err != nil {
 		return err
 	}
+	if !i.SkipCRDs {
+		if err := checkOwnedCRDs(bundle); err != nil {
+			return err
+		}
+	}
+	i.installBundles = []*apimanifests.Bundle{bundle}
 
 	i.OperatorInstaller.PackageName = pkg.PackageName
 	i.OperatorInstaller.CatalogSourceName = fmt.Sprintf("%s-catalog", i.OperatorInstaller.PackageName)
 	i.OperatorInstaller.StartingCSV = bundle.CSV.GetName()
-	i.OperatorInstaller.SupportedInstallModes = operator.GetSupportedInstallModes(bundle.CSV.Spec.InstallModes)
 
-	if i.OperatorInstaller.SupportedInstallModes.Len() == 0 {
-		return fmt.Errorf("operator %q is not installable: no supported install modes", bundle.CSV.GetName())
+	if i.Channel != "" {
+		if pkg, bundles, err = getChannelPackage(pkg, bundles, i.Channel, i.OperatorInstaller.StartingCSV); err != nil {
+			return err
+		}
+		i.OperatorInstaller.Channel = i.Channel
+	} else {
+		i.OperatorInstaller.Channel, err = getChannelForCSVName(pkg, bundles, i.OperatorInstaller.StartingCSV)
+		if err != nil {
+			return err
+		}
 	}
 
-	i.OperatorInstaller.Channel, err = getChannelForCSVName(pkg, i.OperatorInstaller.StartingCSV)
-	if err != nil {
-		return err
+	if len(i.IncludeVersions) != 0 || len(i.ExcludeVersions) != 0 {
+		pkg, bundles, err = filterVersions(pkg, bundles, i.OperatorInstaller.StartingCSV, i.OperatorInstaller.Channel,
+			i.IncludeVersions, i.ExcludeVersions)
+		if err != nil {
+			return err
+		}
+	}
+
+	if i.SkipCRDs {
+		bundles = stripCRDs(bundles)
+	}
+	if len(i.EnvOverrides) != 0 {
+		if bundles, err = applyEnvOverrides(bundles, i.EnvOverrides); err != nil {
+			return err
+		}
 	}
 
 	i.ConfigMapCatalogCreator.Package = pkg
 	i.ConfigMapCatalogCreator.Bundles = bundles
+	i.ImageCatalogCreator.Package = pkg
+	i.ImageCatalogCreator.Bundles = bundles
+
+	return i.setupAdditionalPackages(pkg.PackageName)
+}
 
+// setupAdditionalPackages loads the packages in PackageDirectories to be
+// served by the same catalog as the operator's package mainPkgName, and
+// subscribed to after it.
+func (i *Install) setupAdditionalPackages(mainPkgName string) error {
+	var manifests []configmap.PackageManifests
+	var subs []registry.PackageSubscription
+	loaded := map[string]bool{mainPkgName: true}
+	for _, dir := range i.PackageDirectories {
+		pkg, bundles, err := loadPackageManifests(dir)
+		if err != nil {
+			return fmt.Errorf("load package manifests %s: %v", dir, err)
+		}
+		if loaded[pkg.PackageName] {
+			return fmt.Errorf("package %s in %s is already being installed", pkg.PackageName, dir)
+		}
+		loaded[pkg.PackageName] = true
+
+		bundle, channel, err := getAdditionalPackageBundle(pkg, bundles, i.PackageVersions[pkg.PackageName])
+		if err != nil {
+			return err
+		}
+		if err := i.InstallMode.CheckCompatibility(bundle.CSV, i.cfg.Namespace); err != nil {
+			return fmt.Errorf("package %s: %v", pkg.PackageName, err)
+		}
+		// bundle keeps its CRDs so skipped CRDs can be checked.
+		if i.SkipCRDs {
+			bundles = stripCRDs(bundles)
+		} else if err := checkOwnedCRDs(bundle); err != nil {
+			return fmt.Errorf("package %s: %v", pkg.PackageName, err)
+		}
+		i.installBundles = append(i.installBundles, bundle)
+		manifests = append(manifests, configmap.PackageManifests{Package: pkg, Bundles: bundles})
+		subs = append(subs, registry.PackageSubscription{
+			PackageName: pkg.PackageName,
+			Channel:     channel,
+			StartingCSV: bundle.CSV.GetName(),
+		})
+	}
+
+	var unknown []string
+	for name := range i.PackageVersions {
+		if !loaded[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("versions set for packages not being installed: %s", strings.Join(unknown, ", "))
+	}
+
+	i.ConfigMapCatalogCreator.AdditionalPackages = manifests
+	i.ImageCatalogCreator.AdditionalPackages = manifests
+	i.OperatorInstaller.AdditionalPackages = subs
 	return nil
 }
 
+// getAdditionalPackageBundle returns the bundle in bundles with version and
+// the channel to subscribe to it from. If version is empty, the head of pkg's
+// default channel is returned.
+func getAdditionalPackageBundle(pkg *apimanifests.PackageManifest, bundles []*apimanifests.Bundle,
+	version string) (*apimanifests.Bundle, string, error) {
+
+	if version != "" {
+		bundle, err := getPackageForVersion(bundles, version)
+		if err != nil {
+			return nil, "", fmt.Errorf("package %s: %v", pkg.PackageName, err)
+		}
+		channel, err := getChannelForCSVName(pkg, bundles, bundle.CSV.GetName())
+		if err != nil {
+			return nil, "", err
+		}
+		return bundle, channel, nil
+	}
+
+	defaultChannel := pkg.DefaultChannelName
+	if defaultChannel == "" && len(pkg.Channels) == 1 {
+		defaultChannel = pkg.Channels[0].Name
+	}
+	for _, c := range pkg.Channels {
+		if c.Name != defaultChannel {
+			continue
+		}
+		if bundle, ok := getBundlesByName(bundles)[c.CurrentCSVName]; ok {
+			return bundle, c.Name, nil
+		}
+		return nil, "", fmt.Errorf("package %s: no bundle found for channel %s head %s", pkg.PackageName, c.Name, c.CurrentCSVName)
+	}
+	return nil, "", fmt.Errorf("package %s: no default channel found, set its version with --version %s=<version>",
+		pkg.PackageName, pkg.PackageName)
+}
+
 func loadPackageManifests(rootDir string) (*apimanifests.PackageManifest, []*apimanifests.Bundle, error) {
+	dir, err := stageLayout(rootDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(dir)
+
 	// Operator bundles and metadata.
-	pkg, bundles, err := apimanifests.GetManifestsDir(rootDir)
+	pkg, bundles, err := apimanifests.GetManifestsDir(dir)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -108,23 +538,200 @@ func loadPackageManifests(rootDir string) (*apimanifests.PackageManifest, []*api
 	return pkg, bundles, nil
 }
 
+// getPackageForVersion returns the bundle in bundles whose CSV has version.
+// The error returned if none exists lists all versions, sorted, to make typos obvious.
 func getPackageForVersion(bundles []*apimanifests.Bundle, version string) (*apimanifests.Bundle, error) {
-	versions := []string{}
+	versions := []semver.Version{}
 	for _, bundle := range bundles {
-		verStr := bundle.CSV.Spec.Version.String()
-		if verStr == version {
+		if bundle.CSV.Spec.Version.String() == version {
 			return bundle, nil
 		}
-		versions = append(versions, verStr)
+		versions = append(versions, bundle.CSV.Spec.Version.Version)
 	}
-	return nil, fmt.Errorf("no package found for version %s; valid versions: %+q", version, versions)
+	semver.Sort(versions)
+	verStrs := make([]string, len(versions))
+	for i, v := range versions {
+		verStrs[i] = v.String()
+	}
+	return nil, fmt.Errorf("no package found for version %s; valid versions: %+q", version, verStrs)
 }
 
-func getChannelForCSVName(pkg *apimanifests.PackageManifest, csvName string) (string, error) {
+// getChannelForCSVName returns the name of the channel whose head is csvName,
+// or if none exists, of the first channel containing csvName in its replaces
+// chain, preferring pkg's default channel.
+func getChannelForCSVName(pkg *apimanifests.PackageManifest, bundles []*apimanifests.Bundle, csvName string) (string, error) {
 	for _, c := range pkg.Channels {
 		if c.CurrentCSVName == csvName {
 			return c.Name, nil
 		}
 	}
+	bundlesByName := getBundlesByName(bundles)
+	channels := append([]apimanifests.PackageChannel{}, pkg.Channels...)
+	sort.SliceStable(channels, func(i, j int) bool {
+		return channels[i].Name == pkg.DefaultChannelName && channels[j].Name != pkg.DefaultChannelName
+	})
+	for _, c := range channels {
+		for _, name := range getChannelCSVNames(c, bundlesByName) {
+			if name == csvName {
+				return c.Name, nil
+			}
+		}
+	}
 	return "", fmt.Errorf("no channel in package manifest %s exists for CSV %s", pkg.PackageName, csvName)
 }
+
+func getBundlesByName(bundles []*apimanifests.Bundle) map[string]*apimanifests.Bundle {
+	bundlesByName := make(map[string]*apimanifests.Bundle, len(bundles))
+	for _, b := range bundles {
+		bundlesByName[b.CSV.GetName()] = b
+	}
+	return bundlesByName
+}
+
+// getChannelCSVNames returns the names of CSVs in c's replaces chain, starting at its head.
+func getChannelCSVNames(c apimanifests.PackageChannel, bundlesByName map[string]*apimanifests.Bundle) (names []string) {
+	seen := map[string]bool{}
+	for name := c.CurrentCSVName; name != "" && !seen[name]; {
+		b, ok := bundlesByName[name]
+		if !ok {
+			break
+		}
+		seen[name] = true
+		names = append(names, name)
+		name = b.CSV.Spec.Replaces
+	}
+	return names
+}
+
+// getChannelPackage returns a package manifest containing only pkg's channel
+// named channelName, and the bundles in that channel's replaces chain.
+// An error is returned if csvName is not in the chain.
+func getChannelPackage(pkg *apimanifests.PackageManifest, bundles []*apimanifests.Bundle,
+	channelName, csvName string) (*apimanifests.PackageManifest, []*apimanifests.Bundle, error) {
+
+	var channel *apimanifests.PackageChannel
+	channelNames := []string{}
+	for i, c := range pkg.Channels {
+		if c.Name == channelName {
+			channel = &pkg.Channels[i]
+		}
+		channelNames = append(channelNames, c.Name)
+	}
+	if channel == nil {
+		return nil, nil, fmt.Errorf("no channel %s in package manifest %s; valid channels: %+q", channelName, pkg.PackageName, channelNames)
+	}
+
+	chain := getChannelCSVNames(*channel, getBundlesByName(bundles))
+	inChannel := map[string]bool{}
+	for _, name := range chain {
+		inChannel[name] = true
+	}
+	if !inChannel[csvName] {
+		return nil, nil, fmt.Errorf("CSV %s is not in channel %s of package manifest %s; CSVs in channel: %+q",
+			csvName, channelName, pkg.PackageName, chain)
+	}
+
+	channelPkg := &apimanifests.PackageManifest{
+		PackageName:        pkg.PackageName,
+		Channels:           []apimanifests.PackageChannel{*channel},
+		DefaultChannelName: channel.Name,
+	}
+	var channelBundles []*apimanifests.Bundle
+	for _, b := range bundles {
+		if inChannel[b.CSV.GetName()] {
+			channelBundles = append(channelBundles, b)
+		}
+	}
+	return channelPkg, channelBundles, nil
+}
+
+// validateCatalogFormat returns an error if format is set to a format package
+// manifests can't be served in.
+func validateCatalogFormat(format string) error {
+	switch format {
+	case "", registry.CatalogFormatConfigMap, registry.CatalogFormatFBC:
+		return nil
+	case registry.CatalogFormatSQLite:
+		return fmt.Errorf("catalog format %q is not supported for package manifests, use \"run bundle\" instead", format)
+	}
+	return fmt.Errorf("unknown catalog format %q, must be one of: %s, %s",
+		format, registry.CatalogFormatConfigMap, registry.CatalogFormatFBC)
+}
+
+// catalogFormatValue is a flag value that sets format to a catalog format
+// package manifests can be served in, so other formats fail flag parsing.
+type catalogFormatValue struct {
+	format *string
+}
+
+var _ pflag.Value = catalogFormatValue{}
+
+func (v catalogFormatValue) Set(str string) error {
+	if err := validateCatalogFormat(str); err != nil {
+		return err
+	}
+	*v.format = str
+	return nil
+}
+
+func (v catalogFormatValue) String() string {
+	if v.format == nil {
+		return ""
+	}
+	return *v.format
+}
+
+func (catalogFormatValue) Type() string {
+	return "string"
+}
+
+// versionsValue is a repeatable flag value that sets version from values of
+// the form "<version>", and versions from values of the form "<package>=<version>".
+type versionsValue struct {
+	version  *string
+	versions *map[string]string
+}
+
+var _ pflag.Value = versionsValue{}
+
+func (v versionsValue) Set(str string) error {
+	split := strings.SplitN(str, "=", 2)
+	if len(split) == 1 {
+		*v.version = str
+		return nil
+	}
+	pkgName, version := split[0], split[1]
+	if pkgName == "" || version == "" {
+		return fmt.Errorf("invalid version %q: must be of the form <version> or <package>=<version>", str)
+	}
+	if *v.versions == nil {
+		*v.versions = map[string]string{}
+	}
+	if existing, ok := (*v.versions)[pkgName]; ok && existing != version {
+		return fmt.Errorf("conflicting versions %s and %s set for package %s", existing, version, pkgName)
+	}
+	(*v.versions)[pkgName] = version
+	return nil
+}
+
+func (v versionsValue) String() string {
+	var strs []string
+	if v.version != nil && *v.version != "" {
+		strs = append(strs, *v.version)
+	}
+	if v.versions != nil {
+		pkgNames := make([]string, 0, len(*v.versions))
+		for pkgName := range *v.versions {
+			pkgNames = append(pkgNames, pkgName)
+		}
+		sort.Strings(pkgNames)
+		for _, pkgName := range pkgNames {
+			strs = append(strs, pkgName+"="+(*v.versions)[pkgName])
+		}
+	}
+	return strings.Join(strs, ",")
+}
+
+func (versionsValue) Type() string {
+	return "string"
+}
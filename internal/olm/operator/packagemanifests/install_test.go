@@ -0,0 +1,646 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagemanifests
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/blang/semver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/api/pkg/lib/version"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	operatorsv1 "github.com/operator-framework/api/pkg/operators/v1"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/spf13/pflag"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sversion "k8s.io/apimachinery/pkg/version"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry"
+	"github.com/operator-framework/operator-sdk/internal/olm/snapshot"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func newBundle(csvName, ver string) *apimanifests.Bundle {
+	csv := &v1alpha1.ClusterServiceVersion{}
+	csv.SetName(csvName)
+	csv.Spec.Version = version.OperatorVersion{Version: semver.MustParse(ver)}
+	return &apimanifests.Bundle{CSV: csv}
+}
+
+var _ = Describe("Install", func() {
+	// The package name is not a prefix of its CSV names, which carry a vendor prefix.
+	pkg := &apimanifests.PackageManifest{
+		PackageName: "acme-thing",
+		Channels: []apimanifests.PackageChannel{
+			{Name: "alpha", CurrentCSVName: "acme-thing-operator.v0.0.1"},
+			{Name: "stable", CurrentCSVName: "acme-thing-operator.v0.0.2"},
+		},
+	}
+	bundles := []*apimanifests.Bundle{
+		newBundle("acme-thing-operator.v0.0.1", "0.0.1"),
+		newBundle("acme-thing-operator.v0.0.2", "0.0.2"),
+	}
+
+	Describe("getPackageForVersion", func() {
+		It("should find a bundle with a mismatched CSV name by version", func() {
+			b, err := getPackageForVersion(bundles, "0.0.2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(b.CSV.GetName()).To(Equal("acme-thing-operator.v0.0.2"))
+		})
+		It("should return an error listing sorted versions for an unknown version", func() {
+			unsorted := []*apimanifests.Bundle{
+				newBundle("acme-thing-operator.v0.0.10", "0.0.10"),
+				newBundle("acme-thing-operator.v0.0.2", "0.0.2"),
+			}
+			_, err := getPackageForVersion(unsorted, "0.0.20")
+			Expect(err).To(MatchError(`no package found for version 0.0.20; valid versions: ["0.0.2" "0.0.10"]`))
+		})
+	})
+
+	Describe("getChannelForCSVName", func() {
+		It("should find the channel for a mismatched CSV name", func() {
+			c, err := getChannelForCSVName(pkg, bundles, "acme-thing-operator.v0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c).To(Equal("alpha"))
+		})
+		It("should not fall back to matching the package name", func() {
+			_, err := getChannelForCSVName(pkg, bundles, "acme-thing.v0.0.1")
+			Expect(err).To(HaveOccurred())
+		})
+		It("should find a channel containing the CSV in its replaces chain", func() {
+			v1, v2 := newBundle("acme-thing-operator.v0.0.1", "0.0.1"), newBundle("acme-thing-operator.v0.0.2", "0.0.2")
+			v2.CSV.Spec.Replaces = v1.CSV.GetName()
+			chainPkg := &apimanifests.PackageManifest{
+				PackageName:        "acme-thing",
+				DefaultChannelName: "stable",
+				Channels: []apimanifests.PackageChannel{
+					{Name: "fast", CurrentCSVName: "acme-thing-operator.v0.0.2"},
+					{Name: "stable", CurrentCSVName: "acme-thing-operator.v0.0.2"},
+				},
+			}
+			c, err := getChannelForCSVName(chainPkg, []*apimanifests.Bundle{v1, v2}, "acme-thing-operator.v0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c).To(Equal("stable"))
+		})
+	})
+
+	Describe("getChannelPackage", func() {
+		v1, v2 := newBundle("acme-thing-operator.v0.0.1", "0.0.1"), newBundle("acme-thing-operator.v0.0.2", "0.0.2")
+		v2.CSV.Spec.Replaces = v1.CSV.GetName()
+		chainBundles := []*apimanifests.Bundle{v1, v2}
+
+		It("should only contain the channel and bundles in its replaces chain", func() {
+			p, bs, err := getChannelPackage(pkg, chainBundles, "stable", "acme-thing-operator.v0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.PackageName).To(Equal("acme-thing"))
+			Expect(p.DefaultChannelName).To(Equal("stable"))
+			Expect(p.Channels).To(Equal([]apimanifests.PackageChannel{{Name: "stable", CurrentCSVName: "acme-thing-operator.v0.0.2"}}))
+			Expect(bs).To(Equal(chainBundles))
+
+			p, bs, err = getChannelPackage(pkg, chainBundles, "alpha", "acme-thing-operator.v0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.Channels).To(HaveLen(1))
+			Expect(bs).To(Equal([]*apimanifests.Bundle{v1}))
+		})
+		It("should return an error if the CSV is not reachable from the channel head", func() {
+			_, _, err := getChannelPackage(pkg, chainBundles, "alpha", "acme-thing-operator.v0.0.2")
+			Expect(err).To(MatchError(`CSV acme-thing-operator.v0.0.2 is not in channel alpha of package manifest acme-thing; ` +
+				`CSVs in channel: ["acme-thing-operator.v0.0.1"]`))
+		})
+		It("should list valid channels if the channel does not exist", func() {
+			_, _, err := getChannelPackage(pkg, chainBundles, "beta", "acme-thing-operator.v0.0.1")
+			Expect(err).To(MatchError(`no channel beta in package manifest acme-thing; valid channels: ["alpha" "stable"]`))
+		})
+	})
+
+	Describe("Run with a client dry run", func() {
+		const golden = "testdata/memcached-operator.dryrun.yaml"
+		var (
+			i   Install
+			out *bytes.Buffer
+		)
+
+		BeforeEach(func() {
+			i = NewInstall(&operator.Configuration{Namespace: "testns"})
+			out = &bytes.Buffer{}
+			i.out = out
+			i.PackageManifestsDirectory = filepath.Join("..", "registry", "fbc", "testdata", "memcached-operator")
+			i.Version = "0.0.2"
+			i.DryRun = DryRunClient
+			Expect(i.InstallMode.Set(string(v1alpha1.InstallModeTypeOwnNamespace))).To(Succeed())
+		})
+
+		It("should match the golden file", func() {
+			i.CatalogFormat = registry.CatalogFormatFBC
+			csv, err := i.Run(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(csv).To(BeNil())
+			if *updateGolden {
+				Expect(ioutil.WriteFile(golden, out.Bytes(), 0644)).To(Succeed())
+			}
+			b, err := ioutil.ReadFile(golden)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.String()).To(Equal(string(b)))
+		})
+		It("should render objects in creation order", func() {
+			_, err := i.Run(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			var kinds []string
+			for _, m := range regexp.MustCompile(`(?m)^kind: (\w+)$`).FindAllStringSubmatch(out.String(), -1) {
+				kinds = append(kinds, m[1])
+			}
+			Expect(kinds).To(Equal([]string{
+				"CatalogSource", "ServiceAccount", "ConfigMap", "ConfigMap", "ConfigMap", "Deployment", "Service", "OperatorGroup", "Subscription",
+			}))
+		})
+		It("should set the registry server container's resource requirements", func() {
+			Expect(operator.NewResourceRequirementsValue(&i.RegistryResources).Set(
+				"requests.cpu=100m,requests.memory=128Mi,limits.memory=512Mi")).To(Succeed())
+			Expect(i.setup()).To(Succeed())
+			objs, err := i.RenderInstall()
+			Expect(err).NotTo(HaveOccurred())
+			var dep *appsv1.Deployment
+			for _, obj := range objs {
+				if d, ok := obj.(*appsv1.Deployment); ok {
+					dep = d
+				}
+			}
+			Expect(dep).NotTo(BeNil())
+			Expect(dep.Spec.Template.Spec.Containers).To(HaveLen(1))
+			Expect(dep.Spec.Template.Spec.Containers[0].Resources).To(Equal(corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("100m"),
+					corev1.ResourceMemory: resource.MustParse("128Mi"),
+				},
+				Limits: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("512Mi"),
+				},
+			}))
+		})
+		It("should run the registry server as the SDK's registry ServiceAccount", func() {
+			Expect(i.setup()).To(Succeed())
+			objs, err := i.RenderInstall()
+			Expect(err).NotTo(HaveOccurred())
+			var sa *corev1.ServiceAccount
+			var dep *appsv1.Deployment
+			for _, obj := range objs {
+				switch o := obj.(type) {
+				case *corev1.ServiceAccount:
+					sa = o
+				case *appsv1.Deployment:
+					dep = o
+				}
+			}
+			Expect(sa).NotTo(BeNil())
+			Expect(sa.GetName()).To(Equal(operator.SDKRegistryServiceAccountName))
+			Expect(*sa.AutomountServiceAccountToken).To(BeFalse())
+			Expect(dep).NotTo(BeNil())
+			Expect(dep.Spec.Template.Spec.ServiceAccountName).To(Equal(operator.SDKRegistryServiceAccountName))
+		})
+		It("should override environment variables in served CSVs", func() {
+			i.EnvOverrides = []corev1.EnvVar{{Name: "WATCH_NAMESPACE", Value: "testns"}}
+			Expect(i.setup()).To(Succeed())
+			Expect(i.ConfigMapCatalogCreator.Bundles).To(HaveLen(2))
+			for _, b := range i.ConfigMapCatalogCreator.Bundles {
+				for _, ds := range b.CSV.Spec.InstallStrategy.StrategySpec.DeploymentSpecs {
+					for _, c := range ds.Spec.Template.Spec.Containers {
+						Expect(c.Env).To(ContainElement(corev1.EnvVar{Name: "WATCH_NAMESPACE", Value: "testns"}))
+					}
+				}
+			}
+		})
+		It("should fail if the version set for the operator's package is excluded", func() {
+			i.Version = ""
+			i.PackageVersions = map[string]string{"memcached-operator": "0.0.2"}
+			i.ExcludeVersions = []string{"0.0.2"}
+			_, err := i.Run(context.TODO())
+			Expect(err).To(MatchError("version 0.0.2 to install is excluded"))
+			Expect(out.Len()).To(BeZero())
+		})
+		It("should fail without output if the install mode is not supported", func() {
+			Expect(i.InstallMode.Set(string(v1alpha1.InstallModeTypeMultiNamespace) + "=ns1,ns2")).To(Succeed())
+			_, err := i.Run(context.TODO())
+			Expect(err).To(MatchError(ContainSubstring("not supported")))
+			Expect(out.Len()).To(BeZero())
+		})
+		It("should fail for an unknown version before creating cluster resources", func() {
+			sch := runtime.NewScheme()
+			Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+			cl := fake.NewFakeClientWithScheme(sch)
+			i = NewInstall(&operator.Configuration{Namespace: "testns", Client: cl, Scheme: sch})
+			i.PackageManifestsDirectory = filepath.Join("..", "registry", "fbc", "testdata", "memcached-operator")
+			i.Version = "0.0.20"
+			i.CatalogFormat = registry.CatalogFormatConfigMap
+			_, err := i.Run(context.TODO())
+			Expect(err).To(MatchError(ContainSubstring("no package found for version 0.0.20; valid versions:")))
+			catsrcs := v1alpha1.CatalogSourceList{}
+			Expect(cl.List(context.TODO(), &catsrcs)).To(Succeed())
+			Expect(catsrcs.Items).To(BeEmpty())
+		})
+		Context("with additional packages", func() {
+			getSubscriptions := func() []*v1alpha1.Subscription {
+				objs, err := i.RenderInstall()
+				Expect(err).NotTo(HaveOccurred())
+				var subs []*v1alpha1.Subscription
+				for _, obj := range objs {
+					if sub, ok := obj.(*v1alpha1.Subscription); ok {
+						subs = append(subs, sub)
+					}
+				}
+				return subs
+			}
+
+			BeforeEach(func() {
+				i.PackageDirectories = []string{filepath.Join("testdata", "kvstore-operator")}
+			})
+
+			It("should subscribe to each package from one catalog", func() {
+				Expect(i.setup()).To(Succeed())
+				subs := getSubscriptions()
+				Expect(subs).To(HaveLen(2))
+				Expect(subs[0].Spec.Package).To(Equal("memcached-operator"))
+				Expect(subs[0].Spec.StartingCSV).To(Equal("memcached-operator.v0.0.2"))
+				Expect(subs[1].Spec.Package).To(Equal("kvstore-operator"))
+				Expect(subs[1].Spec.Channel).To(Equal("alpha"))
+				// Defaults to the head of the default channel.
+				Expect(subs[1].Spec.StartingCSV).To(Equal("kvstore-operator.v0.0.2"))
+				for _, sub := range subs {
+					Expect(sub.Spec.CatalogSource).To(Equal("memcached-operator-catalog"))
+				}
+				Expect(i.GetAdditionalPackageNames()).To(Equal([]string{"kvstore-operator"}))
+			})
+			It("should serve every package from the catalog", func() {
+				_, err := i.Run(context.TODO())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(out.String()).To(ContainSubstring("name: memcached-operator-registry-manifests-package\n"))
+				Expect(out.String()).To(ContainSubstring("name: kvstore-operator-registry-manifests-package\n"))
+			})
+			It("should install the version set for each package", func() {
+				i.Version = ""
+				i.PackageVersions = map[string]string{"memcached-operator": "0.0.1", "kvstore-operator": "0.0.1"}
+				Expect(i.setup()).To(Succeed())
+				subs := getSubscriptions()
+				Expect(subs).To(HaveLen(2))
+				Expect(subs[0].Spec.StartingCSV).To(Equal("memcached-operator.v0.0.1"))
+				Expect(subs[1].Spec.StartingCSV).To(Equal("kvstore-operator.v0.0.1"))
+			})
+			It("should fail if a version is set for a package not being installed", func() {
+				i.PackageVersions = map[string]string{"etcd-operator": "0.0.1"}
+				Expect(i.setup()).To(MatchError("versions set for packages not being installed: etcd-operator"))
+			})
+			It("should fail if a package is loaded more than once", func() {
+				i.PackageDirectories = append(i.PackageDirectories, i.PackageDirectories[0])
+				Expect(i.setup()).To(MatchError(ContainSubstring("package kvstore-operator in testdata/kvstore-operator is already being installed")))
+			})
+			It("should fail if an additional package does not support the install mode", func() {
+				Expect(i.InstallMode.Set(string(v1alpha1.InstallModeTypeAllNamespaces))).To(Succeed())
+				Expect(i.setup()).To(MatchError(ContainSubstring("package kvstore-operator:")))
+			})
+		})
+		It("should not serve CRDs if they are skipped", func() {
+			i.SkipCRDs = true
+			Expect(i.setup()).To(Succeed())
+			for _, b := range i.ConfigMapCatalogCreator.Bundles {
+				Expect(b.V1CRDs).To(BeEmpty())
+				Expect(b.V1beta1CRDs).To(BeEmpty())
+				Expect(b.CSV.Spec.CustomResourceDefinitions.Owned).To(BeEmpty())
+				for _, obj := range b.Objects {
+					Expect(obj.GetKind()).NotTo(Equal("CustomResourceDefinition"))
+				}
+			}
+			// The CRDs of the CSV to install are still checked against the cluster.
+			Expect(i.installBundles).To(HaveLen(1))
+			Expect(i.installBundles[0].CSV.Spec.CustomResourceDefinitions.Owned).NotTo(BeEmpty())
+		})
+		It("should serve only the selected versions", func() {
+			i.Version = "0.0.1"
+			i.ExcludeVersions = []string{"0.0.2"}
+			_, err := i.Run(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.String()).To(ContainSubstring("name: memcached-operator-registry-manifests-0-0-1\n"))
+			Expect(out.String()).NotTo(ContainSubstring("name: memcached-operator-registry-manifests-0-0-2\n"))
+			Expect(i.ConfigMapCatalogCreator.Package.Channels).To(ConsistOf(
+				apimanifests.PackageChannel{Name: "alpha", CurrentCSVName: "memcached-operator.v0.0.1"},
+				apimanifests.PackageChannel{Name: "stable", CurrentCSVName: "memcached-operator.v0.0.1"},
+			))
+		})
+		It("should serve the versions the installed version replaces", func() {
+			i.IncludeVersions = []string{"0.0.2"}
+			_, err := i.Run(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.String()).To(ContainSubstring("name: memcached-operator-registry-manifests-0-0-1\n"))
+			Expect(out.String()).To(ContainSubstring("name: memcached-operator-registry-manifests-0-0-2\n"))
+		})
+		Context("with watch namespaces", func() {
+			getOperatorGroup := func() *operatorsv1.OperatorGroup {
+				objs, err := i.RenderInstall()
+				Expect(err).NotTo(HaveOccurred())
+				for _, obj := range objs {
+					if og, ok := obj.(*operatorsv1.OperatorGroup); ok {
+						return og
+					}
+				}
+				return nil
+			}
+
+			BeforeEach(func() {
+				i.InstallMode = operator.InstallMode{}
+			})
+
+			It("should target the inferred install mode's namespaces", func() {
+				Expect(i.WatchNamespaces.Set("ns1")).To(Succeed())
+				Expect(i.setup()).To(Succeed())
+				Expect(i.InstallMode).To(Equal(operator.InstallMode{
+					InstallModeType:  v1alpha1.InstallModeTypeSingleNamespace,
+					TargetNamespaces: []string{"ns1"},
+				}))
+				og := getOperatorGroup()
+				Expect(og).NotTo(BeNil())
+				Expect(og.Spec.TargetNamespaces).To(Equal([]string{"ns1"}))
+			})
+			It("should fail if the CSV does not support the inferred install mode", func() {
+				Expect(i.WatchNamespaces.Set("ns1,ns2")).To(Succeed())
+				Expect(i.setup()).To(MatchError(ContainSubstring(
+					"does not support install mode MultiNamespace, inferred from watch namespaces [\"ns1\" \"ns2\"]; " +
+						"supported install modes: AllNamespaces, OwnNamespace, SingleNamespace")))
+			})
+		})
+		It("should fail for an unknown dry run strategy", func() {
+			i.DryRun = "server"
+			_, err := i.Run(context.TODO())
+			Expect(err).To(MatchError(ContainSubstring(`unknown dry run strategy "server"`)))
+		})
+	})
+
+	Describe("Run with a snapshot file", func() {
+		var (
+			i    Install
+			out  *bytes.Buffer
+			snap *snapshot.Snapshot
+			tmp  string
+		)
+
+		newObject := func(apiVersion, kind, namespace, name string) unstructured.Unstructured {
+			u := unstructured.Unstructured{}
+			u.SetAPIVersion(apiVersion)
+			u.SetKind(kind)
+			u.SetNamespace(namespace)
+			u.SetName(name)
+			return u
+		}
+		// run writes snap to a file and runs i against it.
+		run := func() error {
+			path := filepath.Join(tmp, "snapshot.json")
+			buf := &bytes.Buffer{}
+			Expect(snap.Write(buf, 0)).To(Succeed())
+			Expect(ioutil.WriteFile(path, buf.Bytes(), 0644)).To(Succeed())
+			i.SnapshotFile = path
+			csv, err := i.Run(context.TODO())
+			Expect(csv).To(BeNil())
+			return err
+		}
+
+		BeforeEach(func() {
+			var err error
+			tmp, err = ioutil.TempDir("", "packagemanifests-snapshot-")
+			Expect(err).NotTo(HaveOccurred())
+
+			sch := runtime.NewScheme()
+			Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+			Expect(operatorsv1.AddToScheme(sch)).To(Succeed())
+			i = NewInstall(&operator.Configuration{Namespace: "testns", Scheme: sch})
+			out = &bytes.Buffer{}
+			i.out = out
+			i.PackageManifestsDirectory = filepath.Join("..", "registry", "fbc", "testdata", "memcached-operator")
+			i.Version = "0.0.2"
+			Expect(i.InstallMode.Set(string(v1alpha1.InstallModeTypeOwnNamespace))).To(Succeed())
+
+			apiService := newObject("apiregistration.k8s.io/v1", "APIService", "", "v1.packages.operators.coreos.com")
+			Expect(unstructured.SetNestedSlice(apiService.Object, []interface{}{
+				map[string]interface{}{"type": "Available", "status": "True"},
+			}, "status", "conditions")).To(Succeed())
+			snap = &snapshot.Snapshot{
+				APIVersion:        snapshot.APIVersion,
+				Kind:              snapshot.Kind,
+				KubernetesVersion: k8sversion.Info{GitVersion: "v1.19.1"},
+				APIGroups: []metav1.APIGroup{{
+					Name:     v1alpha1.GroupName,
+					Versions: []metav1.GroupVersionForDiscovery{{GroupVersion: v1alpha1.SchemeGroupVersion.String(), Version: "v1alpha1"}},
+				}},
+				Captured: []metav1.GroupVersionKind{
+					{Group: "apiregistration.k8s.io", Version: "v1", Kind: "APIService"},
+					{Group: operatorsv1.GroupName, Version: "v1", Kind: "OperatorGroup"},
+				},
+				Objects: []unstructured.Unstructured{apiService},
+			}
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(tmp)).To(Succeed())
+		})
+
+		It("should report passed checks without installing", func() {
+			Expect(run()).To(Succeed())
+			Expect(out.String()).To(Equal("olm-installed: passed\noperator-group: passed\ncrd-api-versions: passed\n"))
+		})
+		It("should fail if the install namespace's operator group is incompatible", func() {
+			og := newObject("operators.coreos.com/v1", "OperatorGroup", "testns", "other-og")
+			Expect(unstructured.SetNestedStringSlice(og.Object, []string{"otherns"}, "spec", "targetNamespaces")).To(Succeed())
+			snap.Objects = append(snap.Objects, og)
+			Expect(run()).To(MatchError("preflight checks failed: operator-group"))
+			Expect(out.String()).To(ContainSubstring("operator-group: failed: "))
+		})
+		It("should fail if OLM is not installed", func() {
+			snap.APIGroups = nil
+			Expect(run()).To(MatchError("preflight checks failed: olm-installed"))
+		})
+		It("should skip checks of kinds not captured", func() {
+			snap.Captured, snap.Objects = snap.Captured[1:], nil
+			Expect(run()).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("olm-installed: skipped: requires a live cluster: APIService is not captured in the cluster snapshot\n"))
+		})
+	})
+})
+
+var _ = Describe("versionsValue", func() {
+	var (
+		ver  string
+		vers map[string]string
+		v    versionsValue
+	)
+
+	BeforeEach(func() {
+		ver, vers = "", nil
+		v = versionsValue{version: &ver, versions: &vers}
+	})
+
+	It("should set unqualified and per-package versions", func() {
+		Expect(v.Set("0.0.2")).To(Succeed())
+		Expect(v.Set("kvstore-operator=0.0.1")).To(Succeed())
+		Expect(v.Set("etcd-operator=0.9.4")).To(Succeed())
+		Expect(ver).To(Equal("0.0.2"))
+		Expect(vers).To(Equal(map[string]string{"kvstore-operator": "0.0.1", "etcd-operator": "0.9.4"}))
+		Expect(v.String()).To(Equal("0.0.2,etcd-operator=0.9.4,kvstore-operator=0.0.1"))
+	})
+	It("should reject malformed values", func() {
+		Expect(v.Set("kvstore-operator=")).To(MatchError(ContainSubstring(`invalid version "kvstore-operator="`)))
+		Expect(v.Set("=0.0.1")).To(MatchError(ContainSubstring(`invalid version "=0.0.1"`)))
+	})
+	It("should reject conflicting versions for a package", func() {
+		Expect(v.Set("kvstore-operator=0.0.1")).To(Succeed())
+		Expect(v.Set("kvstore-operator=0.0.2")).To(MatchError("conflicting versions 0.0.1 and 0.0.2 set for package kvstore-operator"))
+	})
+})
+
+var _ = Describe("catalogFormatValue", func() {
+	var (
+		format string
+		v      catalogFormatValue
+	)
+
+	BeforeEach(func() {
+		format = ""
+		v = catalogFormatValue{format: &format}
+	})
+
+	It("should set formats package manifests can be served in", func() {
+		Expect(v.Set(registry.CatalogFormatFBC)).To(Succeed())
+		Expect(format).To(Equal(registry.CatalogFormatFBC))
+		Expect(v.Set(registry.CatalogFormatConfigMap)).To(Succeed())
+		Expect(format).To(Equal(registry.CatalogFormatConfigMap))
+	})
+	It("should fail flag parsing for other formats", func() {
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		fs.Var(v, "catalog-format", "")
+		Expect(fs.Parse([]string{"--catalog-format", registry.CatalogFormatSQLite})).To(MatchError(ContainSubstring(`use "run bundle" instead`)))
+		Expect(fs.Parse([]string{"--catalog-format", "foo"})).To(MatchError(ContainSubstring(`unknown catalog format "foo"`)))
+		Expect(format).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Install options", func() {
+	var i Install
+
+	BeforeEach(func() {
+		i = NewInstall(&operator.Configuration{Namespace: "testns"})
+		i.PackageManifestsDirectory = "packagemanifests"
+		i.Version = "0.0.2"
+	})
+
+	It("should constrain every option", func() {
+		Expect(i.OptionRules().Uncovered(i)).To(BeEmpty())
+	})
+	It("should accept valid options", func() {
+		Expect(i.Validate()).To(Succeed())
+	})
+	It("should accept valid registry image options", func() {
+		i.UseRegistryImage, i.ImageCatalogCreator.Image = true, "quay.io/example/registry:v0.0.1"
+		i.ContainerTool, i.PullSecret = registry.ContainerToolPodman, "registry-creds"
+		Expect(i.Validate()).To(Succeed())
+	})
+
+	DescribeTable("should report violated rules",
+		func(f func(*Install), msg string) {
+			f(&i)
+			Expect(i.Validate()).To(MatchError(ContainSubstring(msg)))
+		},
+		Entry("without a version", func(i *Install) { i.Version = "" },
+			"Version (--version) must be set"),
+		Entry("with an unknown catalog format", func(i *Install) { i.CatalogFormat = "foo" },
+			`CatalogFormat (--catalog-format): unknown catalog format "foo"`),
+		Entry("with the sqlite catalog format", func(i *Install) { i.CatalogFormat = registry.CatalogFormatSQLite },
+			`use "run bundle" instead`),
+		Entry("with an invalid install mode", func(i *Install) {
+			i.InstallMode = operator.InstallMode{InstallModeType: v1alpha1.InstallModeTypeSingleNamespace}
+		}, "OperatorInstaller.InstallMode (--install-mode): install mode \"SingleNamespace\" must have exactly one target namespace"),
+		Entry("with requests exceeding limits", func(i *Install) {
+			i.RegistryResources = corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+			}
+		}, "ConfigMapCatalogCreator.RegistryResources (--registry-resources): invalid resource requirements"),
+		Entry("with an unknown security context config", func(i *Install) { i.SecurityContextConfig = "foo" },
+			`ConfigMapCatalogCreator.SecurityContextConfig (--security-context-config): unknown security context config "foo"`),
+		Entry("with both an install mode and watch namespaces", func(i *Install) {
+			Expect(i.InstallMode.Set(string(v1alpha1.InstallModeTypeOwnNamespace))).To(Succeed())
+			Expect(i.WatchNamespaces.Set("testns")).To(Succeed())
+		}, "OperatorInstaller.InstallMode (--install-mode), OperatorInstaller.WatchNamespaces (--watch-namespaces) are mutually exclusive"),
+		Entry("with a version both included and excluded", func(i *Install) {
+			i.IncludeVersions, i.ExcludeVersions = []string{"0.0.1", "0.0.2"}, []string{"0.0.1"}
+		}, "IncludeVersions (--include-versions), ExcludeVersions (--exclude-versions): versions both included and excluded: 0.0.1"),
+		Entry("with the installed version excluded", func(i *Install) { i.ExcludeVersions = []string{"0.0.2"} },
+			"version 0.0.2 to install is excluded"),
+		Entry("with an invalid environment variable name", func(i *Install) {
+			i.EnvOverrides = []corev1.EnvVar{{Name: "FEATURE GATES", Value: "Foo=true"}}
+		}, `EnvOverrides (--env): invalid environment variable name "FEATURE GATES"`),
+		Entry("with an environment variable set twice", func(i *Install) {
+			i.EnvOverrides = []corev1.EnvVar{{Name: "WATCH_NAMESPACE", Value: "a"}, {Name: "WATCH_NAMESPACE", Value: "b"}}
+		}, "environment variable WATCH_NAMESPACE is set more than once"),
+		Entry("with a negative stage timeout", func(i *Install) { i.CSVSucceededTimeout = -time.Second },
+			"OperatorInstaller.CSVSucceededTimeout"),
+		Entry("with a registry image but not using it", func(i *Install) { i.ImageCatalogCreator.Image = "quay.io/example/registry:v0.0.1" },
+			"ImageCatalogCreator.Image (--registry-image) requires UseRegistryImage (--use-registry-image) to be set"),
+		Entry("using a registry image without one", func(i *Install) { i.UseRegistryImage = true },
+			"ImageCatalogCreator.Image (--registry-image) must be set"),
+		Entry("using a registry image with an unknown container tool", func(i *Install) {
+			i.UseRegistryImage, i.ImageCatalogCreator.Image, i.ContainerTool = true, "quay.io/example/registry:v0.0.1", "buildah"
+		}, `ImageCatalogCreator.ContainerTool (--container-tool): unknown container tool "buildah"`),
+		Entry("using a registry image in a dry run", func(i *Install) {
+			i.UseRegistryImage, i.ImageCatalogCreator.Image, i.DryRun = true, "quay.io/example/registry:v0.0.1", DryRunClient
+		}, "a registry image is not built in a dry run"),
+		Entry("using a registry image with the configmap catalog format", func(i *Install) {
+			i.UseRegistryImage, i.ImageCatalogCreator.Image, i.CatalogFormat = true, "quay.io/example/registry:v0.0.1", registry.CatalogFormatConfigMap
+		}, "a registry image serves the fbc catalog format"),
+		Entry("with a snapshot file and a registry image", func(i *Install) {
+			i.SnapshotFile, i.UseRegistryImage, i.ImageCatalogCreator.Image = "snapshot.json", true, "quay.io/example/registry:v0.0.1"
+		}, "SnapshotFile (--snapshot-file), UseRegistryImage (--use-registry-image) are mutually exclusive"),
+		Entry("with an invalid registry pod node selector", func(i *Install) {
+			i.RegistryNodeSelector = map[string]string{"infra node": ""}
+		}, `ConfigMapCatalogCreator.RegistryNodeSelector (--registry-pod-node-selector): invalid node selector key "infra node"`),
+		Entry("with registry pod tolerations and a registry image", func(i *Install) {
+			i.RegistryTolerations = []corev1.Toleration{{Key: "infra", Operator: corev1.TolerationOpExists}}
+			i.UseRegistryImage, i.ImageCatalogCreator.Image = true, "quay.io/example/registry:v0.0.1"
+		}, "ConfigMapCatalogCreator.RegistryTolerations (--registry-pod-tolerations-file), UseRegistryImage (--use-registry-image) are mutually exclusive"),
+		Entry("with a snapshot file in a dry run", func(i *Install) {
+			i.SnapshotFile, i.DryRun = "snapshot.json", DryRunClient
+		}, "preflight checks against a snapshot are not run in a dry run"),
+	)
+
+	It("should report every violated rule before doing any work", func() {
+		i.PackageManifestsDirectory = ""
+		i.DryRun = "server"
+		_, err := i.Run(context.TODO())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("PackageManifestsDirectory ([packagemanifests-root-dir]) must be set"))
+		Expect(err.Error()).To(ContainSubstring(`DryRun (--dry-run): unknown dry run strategy "server"`))
+	})
+})
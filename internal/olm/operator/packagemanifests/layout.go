@@ -0,0 +1,305 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagemanifests
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// Directory names with special meaning in package manifests layouts.
+const (
+	manifestsDirName = "manifests"
+	metadataDirName  = "metadata"
+)
+
+// layoutHelp describes the package manifests layouts understood by stageLayout.
+const layoutHelp = `expected a package manifest file and bundles in one of these layouts:
+  <root>/<package>.package.yaml, <root>/<version>/<manifest>.yaml
+  <root>/<package>.package.yaml, <root>/<version>/manifests/<manifest>.yaml, <root>/<version>/metadata/
+  <root>/<package>.package.yaml, <root>/manifests/<manifest>.yaml, <root>/metadata/`
+
+// stageLayout copies the package manifest and bundle manifests in rootDir to
+// a new temporary directory in the flat layout read by apimanifests, so all
+// layouts are loaded identically. Hidden files are skipped, and symlinks are
+// followed, with errors naming real paths. Bundles and manifests found through
+// more than one link are staged once. The returned directory must be removed
+// by the caller.
+func stageLayout(rootDir string) (string, error) {
+	realRoot, err := resolvePath(rootDir)
+	if err != nil {
+		return "", err
+	}
+	entries, err := readDir(realRoot)
+	if err != nil {
+		return "", err
+	}
+
+	var pkgFile string
+	bundleDirs := map[string]string{}
+	for _, e := range entries {
+		switch {
+		case !e.info.IsDir():
+			if pkgFile == "" && isPackageManifestFile(e.path) {
+				pkgFile = e.path
+			}
+		case e.name == manifestsDirName:
+			// A single bundle, whose version directory is rootDir.
+			if err := checkLinkCycle(realRoot, e.name, e.path); err != nil {
+				return "", err
+			}
+			bundleDirs[filepath.Base(realRoot)] = e.path
+		case e.name == metadataDirName:
+		default:
+			if err := checkLinkCycle(realRoot, e.name, e.path); err != nil {
+				return "", err
+			}
+			bundleDirs[e.name] = e.path
+			nested := filepath.Join(e.path, manifestsDirName)
+			if _, err := os.Lstat(nested); err != nil {
+				continue
+			}
+			realNested, err := resolvePath(nested)
+			if err != nil {
+				return "", err
+			}
+			if err := checkLinkCycle(e.path, manifestsDirName, realNested); err != nil {
+				return "", err
+			}
+			if info, err := os.Stat(realNested); err == nil && info.IsDir() {
+				bundleDirs[e.name] = realNested
+			}
+		}
+	}
+	if pkgFile == "" {
+		return "", fmt.Errorf("no package manifest found in %s; %s", realRoot, layoutHelp)
+	}
+	if len(bundleDirs) == 0 {
+		return "", fmt.Errorf("no bundles found in %s; %s", realRoot, layoutHelp)
+	}
+
+	tmp, err := ioutil.TempDir("", "operator-sdk-packagemanifests-")
+	if err != nil {
+		return "", err
+	}
+	if err := stageBundles(tmp, pkgFile, bundleDirs); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+// stageBundles copies pkgFile and the manifests in each of bundleDirs, keyed by
+// bundle name, to tmp. Bundle directories with the same manifests as one
+// staged before them, by name, are skipped.
+func stageBundles(tmp, pkgFile string, bundleDirs map[string]string) error {
+	if err := copyFile(pkgFile, filepath.Join(tmp, filepath.Base(pkgFile))); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(bundleDirs))
+	for name := range bundleDirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	stagedBundles := map[string]string{}
+	for _, name := range names {
+		dir := bundleDirs[name]
+		files, hash, err := readBundleFiles(dir)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("bundle directory %s contains no manifests; %s", dir, layoutHelp)
+		}
+		if staged, ok := stagedBundles[hash]; ok {
+			log.Debugf("Skipping bundle directory %s (%s), which contains the same manifests as %s", name, dir, staged)
+			continue
+		}
+		stagedBundles[hash] = name
+
+		dst := filepath.Join(tmp, name)
+		if err := os.Mkdir(dst, 0755); err != nil {
+			return err
+		}
+		for _, f := range files {
+			if err := ioutil.WriteFile(filepath.Join(dst, f.name), f.data, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bundleFile is a manifest file in a bundle directory.
+type bundleFile struct {
+	name string
+	data []byte
+}
+
+// readBundleFiles returns the manifest files in dir, skipping files with the
+// same content as one before them, and a digest of all files' contents.
+func readBundleFiles(dir string) ([]bundleFile, string, error) {
+	entries, err := readDir(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	var files []bundleFile
+	var hashes []string
+	names := map[string]string{}
+	for _, e := range entries {
+		if e.info.IsDir() {
+			continue
+		}
+		b, err := ioutil.ReadFile(e.path)
+		if err != nil {
+			return nil, "", err
+		}
+		hash := fmt.Sprintf("%x", sha256.Sum256(b))
+		if name, ok := names[hash]; ok {
+			log.Debugf("Skipping manifest %s (%s), which has the same content as %s", e.name, e.path, name)
+			continue
+		}
+		names[hash] = e.name
+		hashes = append(hashes, hash)
+		files = append(files, bundleFile{name: e.name, data: b})
+	}
+	sort.Strings(hashes)
+	return files, fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(hashes, "")))), nil
+}
+
+// dirEntry is a directory entry with symlinks resolved.
+type dirEntry struct {
+	name string
+	// path is the entry's real path.
+	path string
+	info os.FileInfo
+}
+
+// readDir returns non-hidden entries of dir, following symlinks.
+func readDir(dir string) ([]dirEntry, error) {
+	names, err := readDirNames(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []dirEntry
+	for _, name := range names {
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		path, err := resolvePath(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, dirEntry{name: name, path: path, info: info})
+	}
+	return entries, nil
+}
+
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	sort.Strings(names)
+	return names, err
+}
+
+// resolvePath returns the absolute path of path with all symlinks resolved.
+// Links that form a cycle or whose target does not exist are described in
+// the error returned.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	realPath, err := filepath.EvalSymlinks(abs)
+	if err == nil {
+		return realPath, nil
+	}
+	// Follow links one at a time to find the one that can't be resolved.
+	seen := map[string]bool{}
+	for cur := abs; ; {
+		info, lerr := os.Lstat(cur)
+		if lerr != nil || info.Mode()&os.ModeSymlink == 0 {
+			break
+		}
+		if seen[cur] {
+			return "", fmt.Errorf("symlink cycle at %s", cur)
+		}
+		seen[cur] = true
+		target, lerr := os.Readlink(cur)
+		if lerr != nil {
+			break
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(cur), target)
+		}
+		if _, lerr := os.Lstat(target); os.IsNotExist(lerr) {
+			return "", fmt.Errorf("dangling symlink %s: target %s does not exist", cur, target)
+		}
+		cur = target
+	}
+	return "", err
+}
+
+// checkLinkCycle returns an error if realDir, the real path of name in
+// parentDir, is or contains parentDir, which happens when a link points back
+// up the tree.
+func checkLinkCycle(parentDir, name, realDir string) error {
+	if parentDir == realDir || strings.HasPrefix(parentDir, realDir+string(filepath.Separator)) {
+		return fmt.Errorf("symlink cycle: %s resolves to %s, which contains it", filepath.Join(parentDir, name), realDir)
+	}
+	return nil
+}
+
+// isPackageManifestFile returns true if path is a YAML or JSON file containing
+// a package manifest.
+func isPackageManifestFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return false
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	pkg := apimanifests.PackageManifest{}
+	return yaml.Unmarshal(b, &pkg) == nil && pkg.PackageName != ""
+}
+
+func copyFile(src, dst string) error {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, b, 0644)
+}
@@ -0,0 +1,198 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagemanifests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+)
+
+var _ = Describe("loadPackageManifests", func() {
+	const flatDir = "../registry/fbc/testdata/memcached-operator"
+
+	var tmp string
+
+	BeforeEach(func() {
+		var err error
+		tmp, err = ioutil.TempDir("", "packagemanifests-layout-")
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	getCSVNames := func(bundles []*apimanifests.Bundle) (names []string) {
+		for _, b := range bundles {
+			names = append(names, b.CSV.GetName())
+		}
+		return names
+	}
+
+	It("should load the flat layout", func() {
+		pkg, bundles, err := loadPackageManifests(flatDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkg.PackageName).To(Equal("memcached-operator"))
+		Expect(getCSVNames(bundles)).To(ConsistOf("memcached-operator.v0.0.1", "memcached-operator.v0.0.2"))
+	})
+	It("should load the nested manifests/ layout the same as the flat layout", func() {
+		flatPkg, flatBundles, err := loadPackageManifests(flatDir)
+		Expect(err).NotTo(HaveOccurred())
+		pkg, bundles, err := loadPackageManifests(filepath.Join("testdata", "layouts", "nested"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkg).To(Equal(flatPkg))
+		Expect(getCSVNames(bundles)).To(ConsistOf(getCSVNames(flatBundles)))
+		for _, b := range bundles {
+			Expect(b.Objects).To(HaveLen(len(flatBundles[0].Objects)))
+		}
+	})
+	It("should load a single bundle with a package manifest alongside metadata/", func() {
+		pkg, bundles, err := loadPackageManifests(filepath.Join("testdata", "layouts", "bundle"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkg.DefaultChannelName).To(Equal("stable"))
+		Expect(getCSVNames(bundles)).To(Equal([]string{"memcached-operator.v0.0.2"}))
+	})
+	It("should follow symlinked version directories and skip hidden files", func() {
+		abs, err := filepath.Abs(flatDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(copyFile(filepath.Join(abs, "memcached-operator.package.yaml"),
+			filepath.Join(tmp, "memcached-operator.package.yaml"))).To(Succeed())
+		for _, v := range []string{"0.0.1", "0.0.2"} {
+			Expect(os.Symlink(filepath.Join(abs, v), filepath.Join(tmp, v))).To(Succeed())
+		}
+		Expect(ioutil.WriteFile(filepath.Join(tmp, ".DS_Store"), []byte{0, 0, 0, 1, 'B', 'u', 'd', '1'}, 0644)).To(Succeed())
+		Expect(os.Mkdir(filepath.Join(tmp, ".git"), 0755)).To(Succeed())
+
+		_, bundles, err := loadPackageManifests(tmp)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getCSVNames(bundles)).To(ConsistOf("memcached-operator.v0.0.1", "memcached-operator.v0.0.2"))
+	})
+	Describe("symlinks", func() {
+		var flatAbs, shared, root string
+
+		// linkPackageManifest links the flat layout's package manifest into root.
+		linkPackageManifest := func() {
+			Expect(os.Symlink(filepath.Join(flatAbs, "memcached-operator.package.yaml"),
+				filepath.Join(root, "memcached-operator.package.yaml"))).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			var err error
+			flatAbs, err = filepath.Abs(flatDir)
+			Expect(err).NotTo(HaveOccurred())
+			// Temp dirs may themselves be symlinked, ex. on macOS.
+			realTmp, err := filepath.EvalSymlinks(tmp)
+			Expect(err).NotTo(HaveOccurred())
+			shared = filepath.Join(realTmp, "shared")
+			Expect(os.Mkdir(shared, 0755)).To(Succeed())
+			root = filepath.Join(realTmp, "root")
+			Expect(os.Mkdir(root, 0755)).To(Succeed())
+		})
+
+		It("should load a relative root directory with nested version and manifests links", func() {
+			linkPackageManifest()
+			// root/0.0.1 -> shared/v1 -> shared/v1-real, whose manifests/ -> flat/0.0.1
+			Expect(os.Mkdir(filepath.Join(shared, "v1-real"), 0755)).To(Succeed())
+			Expect(os.Symlink(filepath.Join(flatAbs, "0.0.1"), filepath.Join(shared, "v1-real", manifestsDirName))).To(Succeed())
+			Expect(os.Symlink("v1-real", filepath.Join(shared, "v1"))).To(Succeed())
+			Expect(os.Symlink(filepath.Join("..", "shared", "v1"), filepath.Join(root, "0.0.1"))).To(Succeed())
+			Expect(os.Symlink(filepath.Join(flatAbs, "0.0.2"), filepath.Join(root, "0.0.2"))).To(Succeed())
+
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			rel, err := filepath.Rel(wd, root)
+			Expect(err).NotTo(HaveOccurred())
+			_, bundles, err := loadPackageManifests(rel)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(getCSVNames(bundles)).To(ConsistOf("memcached-operator.v0.0.1", "memcached-operator.v0.0.2"))
+		})
+		It("should load bundles and manifests linked more than once only once", func() {
+			linkPackageManifest()
+			for _, name := range []string{"0.0.1", "0.0.2", "latest"} {
+				v := name
+				if name == "latest" {
+					v = "0.0.2"
+				}
+				Expect(os.Mkdir(filepath.Join(root, name), 0755)).To(Succeed())
+				for _, f := range []string{"cache.example.com_memcacheds.yaml", "memcached-operator.clusterserviceversion.yaml"} {
+					Expect(os.Symlink(filepath.Join(flatAbs, v, f), filepath.Join(root, name, f))).To(Succeed())
+				}
+			}
+			Expect(os.Symlink(filepath.Join(flatAbs, "0.0.1", "memcached-operator.clusterserviceversion.yaml"),
+				filepath.Join(root, "0.0.1", "csv-link.yaml"))).To(Succeed())
+
+			_, flatBundles, err := loadPackageManifests(flatDir)
+			Expect(err).NotTo(HaveOccurred())
+			_, bundles, err := loadPackageManifests(root)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(getCSVNames(bundles)).To(ConsistOf("memcached-operator.v0.0.1", "memcached-operator.v0.0.2"))
+			for _, b := range bundles {
+				Expect(b.Objects).To(HaveLen(len(flatBundles[0].Objects)))
+			}
+		})
+		It("should fail on a version directory linking to the root directory", func() {
+			linkPackageManifest()
+			Expect(os.Symlink(filepath.Join(flatAbs, "0.0.1"), filepath.Join(root, "0.0.1"))).To(Succeed())
+			Expect(os.Symlink(".", filepath.Join(root, "0.0.2"))).To(Succeed())
+			_, _, err := loadPackageManifests(root)
+			Expect(err).To(MatchError(ContainSubstring("symlink cycle: " + filepath.Join(root, "0.0.2") + " resolves to " + root + ",")))
+		})
+		It("should fail on a manifests directory linking to its version directory", func() {
+			linkPackageManifest()
+			Expect(os.Mkdir(filepath.Join(root, "0.0.1"), 0755)).To(Succeed())
+			Expect(os.Symlink("..", filepath.Join(root, "0.0.1", manifestsDirName))).To(Succeed())
+			_, _, err := loadPackageManifests(root)
+			Expect(err).To(MatchError(ContainSubstring("symlink cycle")))
+		})
+		It("should fail on links that link to each other", func() {
+			linkPackageManifest()
+			Expect(os.Symlink("b", filepath.Join(root, "a"))).To(Succeed())
+			Expect(os.Symlink("a", filepath.Join(root, "b"))).To(Succeed())
+			_, _, err := loadPackageManifests(root)
+			Expect(err).To(MatchError(ContainSubstring("symlink cycle at " + root)))
+		})
+		It("should name the missing target of a dangling link", func() {
+			linkPackageManifest()
+			Expect(os.Symlink(filepath.Join(shared, "0.0.1"), filepath.Join(root, "0.0.1"))).To(Succeed())
+			_, _, err := loadPackageManifests(root)
+			Expect(err).To(MatchError(ContainSubstring(
+				"dangling symlink " + filepath.Join(root, "0.0.1") + ": target " + filepath.Join(shared, "0.0.1") + " does not exist")))
+		})
+		It("should report the real path of a linked root directory in errors", func() {
+			linkPackageManifest()
+			link := filepath.Join(shared, "root-link")
+			Expect(os.Symlink(root, link)).To(Succeed())
+			_, _, err := loadPackageManifests(link)
+			Expect(err).To(MatchError(ContainSubstring("no bundles found in " + root + ";")))
+		})
+	})
+	It("should describe the expected layouts if there is no package manifest", func() {
+		Expect(os.Mkdir(filepath.Join(tmp, "0.0.1"), 0755)).To(Succeed())
+		_, _, err := loadPackageManifests(tmp)
+		Expect(err).To(MatchError(ContainSubstring("no package manifest found")))
+		Expect(err).To(MatchError(ContainSubstring("<root>/<version>/manifests/<manifest>.yaml")))
+	})
+	It("should describe the expected layouts if there are no bundles", func() {
+		Expect(copyFile(filepath.Join(flatDir, "memcached-operator.package.yaml"),
+			filepath.Join(tmp, "memcached-operator.package.yaml"))).To(Succeed())
+		_, _, err := loadPackageManifests(tmp)
+		Expect(err).To(MatchError(ContainSubstring("no bundles found")))
+		Expect(err).To(MatchError(ContainSubstring("expected a package manifest file and bundles")))
+	})
+})
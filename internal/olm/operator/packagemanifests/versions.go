@@ -0,0 +1,128 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagemanifests
+
+import (
+	"fmt"
+
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	log "github.com/sirupsen/logrus"
+)
+
+// filterVersions returns a copy of pkg and the bundles in bundles selected by
+// include and exclude, which contain bundle versions. If include is empty all
+// versions are included. csvName, the CSV being installed from channelName, and
+// the CSVs in its replaces chain are always retained so upgrades resolve, and
+// an error is returned if csvName's version is excluded.
+// Each channel's head is moved to its newest retained CSV, and channels
+// without retained CSVs are removed.
+func filterVersions(pkg *apimanifests.PackageManifest, bundles []*apimanifests.Bundle,
+	csvName, channelName string, include, exclude []string) (*apimanifests.PackageManifest, []*apimanifests.Bundle, error) {
+
+	bundlesByName := getBundlesByName(bundles)
+	keep := map[string]bool{}
+	if len(include) == 0 {
+		for name := range bundlesByName {
+			keep[name] = true
+		}
+	}
+	for _, v := range include {
+		b, err := getPackageForVersion(bundles, v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("include version: %v", err)
+		}
+		keep[b.CSV.GetName()] = true
+	}
+	excluded := map[string]bool{}
+	for _, v := range exclude {
+		b, err := getPackageForVersion(bundles, v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("exclude version: %v", err)
+		}
+		excluded[b.CSV.GetName()] = true
+		delete(keep, b.CSV.GetName())
+	}
+	// The installed version may be set by package, so it is only known here.
+	if excluded[csvName] {
+		return nil, nil, fmt.Errorf("version %s to install is excluded", bundlesByName[csvName].CSV.Spec.Version)
+	}
+
+	// Always retain the installed CSV's replaces chain.
+	seen := map[string]bool{}
+	for name := csvName; name != "" && !seen[name]; {
+		b, ok := bundlesByName[name]
+		if !ok {
+			break
+		}
+		seen[name] = true
+		if excluded[name] {
+			log.Infof("Retaining excluded version %s, which is in the replaces chain of %s", b.CSV.Spec.Version, csvName)
+		}
+		keep[name] = true
+		name = b.CSV.Spec.Replaces
+	}
+
+	// Move channel heads to their newest retained CSV, and serve only CSVs
+	// reachable from a head without crossing a filtered CSV.
+	filteredPkg := &apimanifests.PackageManifest{
+		PackageName:        pkg.PackageName,
+		DefaultChannelName: pkg.DefaultChannelName,
+	}
+	reachable := map[string]bool{}
+	for _, c := range pkg.Channels {
+		var head string
+		for _, name := range getChannelCSVNames(c, bundlesByName) {
+			if head == "" && keep[name] {
+				head = name
+			}
+			if head != "" {
+				if !keep[name] {
+					break
+				}
+				reachable[name] = true
+			}
+		}
+		if head == "" {
+			log.Debugf("Removing channel %s, which contains no retained versions", c.Name)
+			continue
+		}
+		filteredPkg.Channels = append(filteredPkg.Channels, apimanifests.PackageChannel{Name: c.Name, CurrentCSVName: head})
+		if c.Name == channelName && !reachable[csvName] {
+			return nil, nil, fmt.Errorf("CSV %s is not reachable from the head %s of channel %s once versions are filtered; "+
+				"include the versions replaced between them", csvName, head, channelName)
+		}
+	}
+
+	var hasDefault bool
+	for _, c := range filteredPkg.Channels {
+		hasDefault = hasDefault || c.Name == filteredPkg.DefaultChannelName
+	}
+	if !hasDefault {
+		filteredPkg.DefaultChannelName = channelName
+	}
+
+	var filteredBundles []*apimanifests.Bundle
+	for _, b := range bundles {
+		name := b.CSV.GetName()
+		switch {
+		case reachable[name]:
+			filteredBundles = append(filteredBundles, b)
+		case keep[name]:
+			log.Infof("Removing version %s, which is not reachable from any channel head once versions are filtered",
+				b.CSV.Spec.Version)
+		}
+	}
+	return filteredPkg, filteredBundles, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagemanifests
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+)
+
+var _ = Describe("filterVersions", func() {
+	var (
+		pkg     *apimanifests.PackageManifest
+		bundles []*apimanifests.Bundle
+	)
+
+	// newChainBundle returns a bundle for version that replaces version replaces, if set.
+	newChainBundle := func(ver, replaces string) *apimanifests.Bundle {
+		b := newBundle("acme-thing-operator.v"+ver, ver)
+		if replaces != "" {
+			b.CSV.Spec.Replaces = "acme-thing-operator.v" + replaces
+		}
+		return b
+	}
+	getVersions := func(bundles []*apimanifests.Bundle) (versions []string) {
+		for _, b := range bundles {
+			versions = append(versions, b.CSV.Spec.Version.String())
+		}
+		return versions
+	}
+
+	BeforeEach(func() {
+		pkg = &apimanifests.PackageManifest{
+			PackageName: "acme-thing",
+			Channels: []apimanifests.PackageChannel{
+				{Name: "alpha", CurrentCSVName: "acme-thing-operator.v0.0.4"},
+				{Name: "stable", CurrentCSVName: "acme-thing-operator.v0.0.2"},
+			},
+			DefaultChannelName: "stable",
+		}
+		bundles = []*apimanifests.Bundle{
+			newChainBundle("0.0.1", ""),
+			newChainBundle("0.0.2", "0.0.1"),
+			newChainBundle("0.0.3", "0.0.2"),
+			newChainBundle("0.0.4", "0.0.3"),
+		}
+	})
+
+	It("should serve only included versions and the installed version's replaces chain", func() {
+		fpkg, fbundles, err := filterVersions(pkg, bundles, "acme-thing-operator.v0.0.3", "alpha", []string{"0.0.3"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getVersions(fbundles)).To(Equal([]string{"0.0.1", "0.0.2", "0.0.3"}))
+		Expect(fpkg.Channels).To(Equal([]apimanifests.PackageChannel{
+			{Name: "alpha", CurrentCSVName: "acme-thing-operator.v0.0.3"},
+			{Name: "stable", CurrentCSVName: "acme-thing-operator.v0.0.2"},
+		}))
+		Expect(fpkg.DefaultChannelName).To(Equal("stable"))
+		// The original package manifest is unchanged.
+		Expect(pkg.Channels[0].CurrentCSVName).To(Equal("acme-thing-operator.v0.0.4"))
+	})
+	It("should not serve excluded versions", func() {
+		fpkg, fbundles, err := filterVersions(pkg, bundles, "acme-thing-operator.v0.0.1", "stable", nil, []string{"0.0.2", "0.0.3", "0.0.4"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getVersions(fbundles)).To(Equal([]string{"0.0.1"}))
+		Expect(fpkg.Channels).To(Equal([]apimanifests.PackageChannel{
+			{Name: "alpha", CurrentCSVName: "acme-thing-operator.v0.0.1"},
+			{Name: "stable", CurrentCSVName: "acme-thing-operator.v0.0.1"},
+		}))
+	})
+	It("should retain excluded versions in the installed version's replaces chain", func() {
+		_, fbundles, err := filterVersions(pkg, bundles, "acme-thing-operator.v0.0.2", "stable", nil, []string{"0.0.1", "0.0.4"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getVersions(fbundles)).To(Equal([]string{"0.0.1", "0.0.2", "0.0.3"}))
+	})
+	It("should remove channels without retained versions", func() {
+		bundles = append(bundles, newChainBundle("1.0.0", ""))
+		pkg.Channels = append(pkg.Channels, apimanifests.PackageChannel{Name: "fast", CurrentCSVName: "acme-thing-operator.v1.0.0"})
+		pkg.DefaultChannelName = "fast"
+		fpkg, fbundles, err := filterVersions(pkg, bundles, "acme-thing-operator.v0.0.2", "stable", nil, []string{"1.0.0"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getVersions(fbundles)).To(Equal([]string{"0.0.1", "0.0.2", "0.0.3", "0.0.4"}))
+		Expect(fpkg.Channels).To(Equal([]apimanifests.PackageChannel{
+			{Name: "alpha", CurrentCSVName: "acme-thing-operator.v0.0.4"},
+			{Name: "stable", CurrentCSVName: "acme-thing-operator.v0.0.2"},
+		}))
+		// The default channel is replaced by the installed version's channel.
+		Expect(fpkg.DefaultChannelName).To(Equal("stable"))
+	})
+	It("should fail if the installed version is not reachable from its channel head", func() {
+		_, _, err := filterVersions(pkg, bundles, "acme-thing-operator.v0.0.1", "alpha", []string{"0.0.4"}, nil)
+		Expect(err).To(MatchError("CSV acme-thing-operator.v0.0.1 is not reachable from the head acme-thing-operator.v0.0.4 " +
+			"of channel alpha once versions are filtered; include the versions replaced between them"))
+	})
+	It("should fail if the installed version is excluded", func() {
+		_, _, err := filterVersions(pkg, bundles, "acme-thing-operator.v0.0.2", "stable", nil, []string{"0.0.2"})
+		Expect(err).To(MatchError("version 0.0.2 to install is excluded"))
+	})
+	It("should fail for unknown versions", func() {
+		_, _, err := filterVersions(pkg, bundles, "acme-thing-operator.v0.0.1", "alpha", []string{"0.0.5"}, nil)
+		Expect(err).To(MatchError(ContainSubstring("include version: no package found for version 0.0.5")))
+		_, _, err = filterVersions(pkg, bundles, "acme-thing-operator.v0.0.1", "alpha", nil, []string{"0.0.5"})
+		Expect(err).To(MatchError(ContainSubstring("exclude version: no package found for version 0.0.5")))
+	})
+})
@@ -0,0 +1,186 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package breaker bounds client-side calls to image registries, like digest
+// resolution and image pre-pulls, with a circuit breaker and time budget per
+// registry host, so one slow or failing registry can't stall an operation.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned for calls to a registry host whose breaker is open.
+var ErrOpen = errors.New("registry circuit breaker is open")
+
+const (
+	// DefaultBudget is the default total time calls to one registry host may take.
+	DefaultBudget = 2 * time.Minute
+	// DefaultFailureThreshold is the default number of failed calls to one
+	// registry host after which its breaker opens.
+	DefaultFailureThreshold = 3
+	// defaultRegistryHost is the host of images without a registry component.
+	defaultRegistryHost = "docker.io"
+)
+
+// Options configure each registry host's breaker.
+type Options struct {
+	// Budget is the total time calls to a single registry host may take.
+	// Defaults to DefaultBudget.
+	Budget time.Duration
+	// FailureThreshold is the number of failed calls to a single registry host
+	// after which remaining calls to that host are skipped.
+	// Defaults to DefaultFailureThreshold.
+	FailureThreshold int
+}
+
+// HostStats are the results of calls to one registry host.
+type HostStats struct {
+	Succeeded int
+	Failed    int
+	// Skipped calls were not made because the host's breaker was open.
+	Skipped int
+	// Elapsed is the total time spent in calls to the host.
+	Elapsed time.Duration
+	// Open is true if the host's breaker is open.
+	Open bool
+}
+
+// Breakers tracks a breaker per registry host for the lifetime of one
+// operation. It is safe for concurrent use.
+type Breakers struct {
+	budget    time.Duration
+	threshold int
+
+	mu    sync.Mutex
+	hosts map[string]*HostStats
+}
+
+// New returns Breakers configured by opts, with unset options defaulted.
+func New(opts Options) *Breakers {
+	b := &Breakers{
+		budget:    opts.Budget,
+		threshold: opts.FailureThreshold,
+		hosts:     map[string]*HostStats{},
+	}
+	if b.budget <= 0 {
+		b.budget = DefaultBudget
+	}
+	if b.threshold <= 0 {
+		b.threshold = DefaultFailureThreshold
+	}
+	return b
+}
+
+// Do calls fn for image with a context bound by the remaining budget of
+// image's registry host. If the host's breaker is open, fn is not called and
+// an error wrapping ErrOpen is returned. Calls in flight at the same time each
+// get the budget remaining when they started.
+func (b *Breakers) Do(ctx context.Context, image string, fn func(context.Context) error) error {
+	host := RegistryHost(image)
+
+	b.mu.Lock()
+	h := b.getHost(host)
+	remaining := b.budget - h.Elapsed
+	if h.Open || remaining <= 0 {
+		h.Open = true
+		h.Skipped++
+		b.mu.Unlock()
+		return fmt.Errorf("skipping %s: %w for %s", image, ErrOpen, host)
+	}
+	b.mu.Unlock()
+
+	callCtx, cancel := context.WithTimeout(ctx, remaining)
+	defer cancel()
+	start := time.Now()
+	err := fn(callCtx)
+	elapsed := time.Since(start)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h.Elapsed += elapsed
+	switch {
+	case err == nil:
+		h.Succeeded++
+	case ctx.Err() != nil:
+		// The operation was cancelled, which says nothing about the registry.
+	default:
+		h.Failed++
+		if h.Failed >= b.threshold {
+			h.Open = true
+		}
+	}
+	if h.Elapsed >= b.budget {
+		h.Open = true
+	}
+	return err
+}
+
+func (b *Breakers) getHost(host string) *HostStats {
+	h, ok := b.hosts[host]
+	if !ok {
+		h = &HostStats{}
+		b.hosts[host] = h
+	}
+	return h
+}
+
+// Stats returns a copy of the stats of each registry host called so far.
+func (b *Breakers) Stats() map[string]HostStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := make(map[string]HostStats, len(b.hosts))
+	for host, h := range b.hosts {
+		stats[host] = *h
+	}
+	return stats
+}
+
+// Summary returns one line of stats per registry host, sorted by host.
+func (b *Breakers) Summary() []string {
+	stats := b.Stats()
+	hosts := make([]string, 0, len(stats))
+	for host := range stats {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	lines := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		s := stats[host]
+		lines = append(lines, fmt.Sprintf("registry %s: %d succeeded, %d failed, %d skipped, %s spent",
+			host, s.Succeeded, s.Failed, s.Skipped, s.Elapsed.Round(time.Millisecond)))
+	}
+	return lines
+}
+
+// RegistryHost returns the registry host of image, following the same rules as
+// docker: the first path component is a host if it contains a "." or ":", or
+// is "localhost". Otherwise the image is on docker.io.
+func RegistryHost(image string) string {
+	i := strings.IndexRune(image, '/')
+	if i < 0 {
+		return defaultRegistryHost
+	}
+	first := image[:i]
+	if strings.ContainsAny(first, ".:") || first == "localhost" {
+		return first
+	}
+	return defaultRegistryHost
+}
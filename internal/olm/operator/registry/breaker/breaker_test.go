@@ -0,0 +1,113 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var errServer = errors.New("500 Internal Server Error")
+
+// fakeRegistry alternates between timing out after timeout and failing with errServer.
+type fakeRegistry struct {
+	timeout time.Duration
+	calls   int
+}
+
+func (r *fakeRegistry) resolve(ctx context.Context) error {
+	r.calls++
+	if r.calls%2 == 0 {
+		return errServer
+	}
+	select {
+	case <-time.After(r.timeout):
+		return context.DeadlineExceeded
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var _ = Describe("Breakers", func() {
+	images := func(host string, n int) (imgs []string) {
+		for i := 0; i < n; i++ {
+			imgs = append(imgs, fmt.Sprintf("%s/org/operator-%d:v0.0.1", host, i))
+		}
+		return imgs
+	}
+
+	Describe("Do", func() {
+		It("should open at the failure threshold and skip remaining images", func() {
+			b := New(Options{Budget: time.Minute, FailureThreshold: 3})
+			flaky := &fakeRegistry{timeout: 10 * time.Millisecond}
+			var skipped int
+			for _, img := range images("flaky.example.com", 10) {
+				if err := b.Do(context.TODO(), img, flaky.resolve); errors.Is(err, ErrOpen) {
+					skipped++
+				}
+			}
+			Expect(flaky.calls).To(Equal(3))
+			Expect(skipped).To(Equal(7))
+			stats := b.Stats()["flaky.example.com"]
+			Expect(stats.Failed).To(Equal(3))
+			Expect(stats.Skipped).To(Equal(7))
+			Expect(stats.Open).To(BeTrue())
+		})
+		It("should complete within the budget", func() {
+			budget := 100 * time.Millisecond
+			b := New(Options{Budget: budget, FailureThreshold: 1000})
+			flaky := &fakeRegistry{timeout: 40 * time.Millisecond}
+			start := time.Now()
+			var skipped int
+			for _, img := range images("flaky.example.com", 20) {
+				if err := b.Do(context.TODO(), img, flaky.resolve); errors.Is(err, ErrOpen) {
+					skipped++
+				}
+			}
+			Expect(time.Since(start)).To(BeNumerically("<", budget+50*time.Millisecond))
+			Expect(skipped).To(BeNumerically(">", 0))
+			Expect(b.Stats()["flaky.example.com"].Open).To(BeTrue())
+		})
+		It("should not open breakers of other hosts", func() {
+			b := New(Options{FailureThreshold: 1})
+			flaky := &fakeRegistry{timeout: time.Millisecond}
+			Expect(b.Do(context.TODO(), "flaky.example.com/org/operator:v0.0.1", flaky.resolve)).NotTo(Succeed())
+			for _, img := range images("quay.io", 3) {
+				Expect(b.Do(context.TODO(), img, func(context.Context) error { return nil })).To(Succeed())
+			}
+			stats := b.Stats()
+			Expect(stats["flaky.example.com"].Open).To(BeTrue())
+			Expect(stats["quay.io"].Succeeded).To(Equal(3))
+			Expect(stats["quay.io"].Open).To(BeFalse())
+		})
+	})
+
+	DescribeTable("RegistryHost",
+		func(image, host string) {
+			Expect(RegistryHost(image)).To(Equal(host))
+		},
+		Entry("with a registry", "quay.io/example/memcached-operator:v0.0.1", "quay.io"),
+		Entry("with a registry port", "registry.local:5000/memcached-operator@sha256:abc", "registry.local:5000"),
+		Entry("on localhost", "localhost/memcached-operator", "localhost"),
+		Entry("without a registry", "example/memcached-operator:v0.0.1", "docker.io"),
+		Entry("with only a name", "busybox", "docker.io"),
+	)
+})
@@ -16,10 +16,108 @@ package registry
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
+	"github.com/blang/semver"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+	"github.com/operator-framework/operator-sdk/internal/olm/installer"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+)
+
+// Formats a catalog's content can be served in.
+const (
+	// CatalogFormatConfigMap serves manifests stored in ConfigMaps from a
+	// database built in the registry pod.
+	CatalogFormatConfigMap = "configmap"
+	// CatalogFormatSQLite serves a pre-built SQLite index image.
+	CatalogFormatSQLite = "sqlite"
+	// CatalogFormatFBC serves a file-based catalog (declarative config) with `opm serve`.
+	CatalogFormatFBC = "fbc"
 )
 
+// minFBCOLMVersion is the first OLM release that ships file-based catalog support.
+var minFBCOLMVersion = semver.MustParse("0.19.0")
+
 type CatalogCreator interface {
 	CreateCatalog(ctx context.Context, name string) (*v1alpha1.CatalogSource, error)
 }
+
+// CatalogUpdater is implemented by CatalogCreators that can add a bundle to a
+// catalog they created, so operators installed from that catalog can be upgraded.
+type CatalogUpdater interface {
+	UpdateCatalog(ctx context.Context, cs *v1alpha1.CatalogSource) error
+}
+
+// registryImageAnnotation records the image serving a catalog created by the SDK,
+// which is otherwise only found on the registry's pods.
+const registryImageAnnotation = "operators.operatorframework.io/registry-image"
+
+// DryRunUID is the placeholder UID of objects rendered for a dry run, which
+// would otherwise be generated by the server.
+const DryRunUID types.UID = "dry-run-placeholder-uid"
+
+// CatalogRenderer is implemented by CatalogCreators that can render the
+// objects they create without contacting the cluster. The first object
+// returned must be the CatalogSource.
+type CatalogRenderer interface {
+	RenderCatalog(name string) ([]runtime.Object, error)
+}
+
+// CatalogConditioner is implemented by CatalogCreators that can describe the
+// state of the objects serving a catalog, ex. a registry pod, which is more
+// useful than the CatalogSource's state when catalog creation times out.
+type CatalogConditioner interface {
+	GetCatalogCondition(ctx context.Context, name string) string
+}
+
+// ResolveCatalogFormat returns format if non-empty. Otherwise CatalogFormatFBC
+// is returned if the version of OLM installed on-cluster supports file-based
+// catalogs, and CatalogFormatConfigMap if not or if the version can't be found.
+// The format chosen when format is empty is logged, since it depends on the cluster.
+func ResolveCatalogFormat(ctx context.Context, cfg *operator.Configuration, format string) (string, error) {
+	switch format {
+	case CatalogFormatConfigMap, CatalogFormatSQLite, CatalogFormatFBC:
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unknown catalog format %q, must be one of: %s, %s, %s",
+			format, CatalogFormatConfigMap, CatalogFormatSQLite, CatalogFormatFBC)
+	}
+
+	c, err := olmclient.NewClientForConfig(cfg.RESTConfig)
+	if err != nil {
+		return "", err
+	}
+	olmNamespace, err := c.GetRecordedNamespace(ctx)
+	if err != nil || olmNamespace == "" {
+		olmNamespace = installer.DefaultOLMNamespace
+	}
+	verStr, err := c.GetInstalledVersion(ctx, olmNamespace)
+	if err != nil {
+		if !errors.Is(err, olmclient.ErrOLMNotInstalled) {
+			log.Debugf("Failed to get OLM version: %v", err)
+		}
+		log.Infof("Using the %s catalog format, since the on-cluster OLM version is unknown", CatalogFormatConfigMap)
+		return CatalogFormatConfigMap, nil
+	}
+	ver, err := semver.ParseTolerant(verStr)
+	if err != nil {
+		log.Debugf("Failed to parse OLM version %q: %v", verStr, err)
+		log.Infof("Using the %s catalog format, since the on-cluster OLM version is unknown", CatalogFormatConfigMap)
+		return CatalogFormatConfigMap, nil
+	}
+	if ver.GTE(minFBCOLMVersion) {
+		log.Infof("Using the %s catalog format, since on-cluster OLM version %s supports file-based catalogs",
+			CatalogFormatFBC, verStr)
+		return CatalogFormatFBC, nil
+	}
+	log.Infof("Using the %s catalog format, since on-cluster OLM version %s does not support file-based catalogs",
+		CatalogFormatConfigMap, verStr)
+	return CatalogFormatConfigMap, nil
+}
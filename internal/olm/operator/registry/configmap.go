@@ -21,18 +21,41 @@ import (
 	apimanifests "github.com/operator-framework/api/pkg/manifests"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/retry"
 
 	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
 	"github.com/operator-framework/operator-sdk/internal/olm/operator"
 	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/fbc"
 )
 
 type ConfigMapCatalogCreator struct {
 	Package *apimanifests.PackageManifest
 	Bundles []*apimanifests.Bundle
+	// AdditionalPackages are served by the same catalog as Package.
+	AdditionalPackages []configmap.PackageManifests
+	// RegistryImage is the image serving the registry. Defaults to
+	// configmap.DefaultRegistryBaseImage.
+	RegistryImage string
+	// SkipCleanupOrphans disables deletion of registry objects left behind by
+	// previous installs of the package.
+	SkipCleanupOrphans bool
+	// Format is either CatalogFormatConfigMap (the default) or CatalogFormatFBC.
+	Format string
+	// RegistryResources are the registry server container's resource
+	// requirements. Empty by default.
+	RegistryResources corev1.ResourceRequirements
+	// SecurityContextConfig selects the registry pod's security context.
+	// Defaults to legacy.
+	SecurityContextConfig operator.SecurityContextConfig
+	// RegistryNodeSelector and RegistryTolerations constrain the nodes the registry
+	// pod is scheduled on, ex. to a tainted pool for infrastructure pods.
+	RegistryNodeSelector map[string]string
+	RegistryTolerations  []corev1.Toleration
 
 	cfg *operator.Configuration
 }
@@ -43,47 +66,153 @@ func NewConfigMapCatalogCreator(cfg *operator.Configuration) *ConfigMapCatalogCr
 	}
 }
 
+// OptionRules returns the rules constraining c's fields. Package, Bundles,
+// AdditionalPackages, and Format are set by c's embedding struct.
+func (c ConfigMapCatalogCreator) OptionRules() operator.OptionRules {
+	return operator.OptionRules{
+		Rules: []operator.OptionRule{
+			operator.Constraint(func() error { return operator.ValidateResourceRequirements(c.RegistryResources) },
+				registryResourcesOption(c.RegistryResources)),
+			operator.Constraint(c.SecurityContextConfig.Validate, securityContextConfigOption(c.SecurityContextConfig)),
+			operator.Constraint(func() error { return operator.ValidateNodeSelector(c.RegistryNodeSelector) },
+				operator.Option{Field: "RegistryNodeSelector", Flag: "--registry-pod-node-selector",
+					IsSet: func() bool { return len(c.RegistryNodeSelector) != 0 }}),
+			operator.Constraint(func() error { return operator.ValidateTolerations(c.RegistryTolerations) },
+				operator.Option{Field: "RegistryTolerations", Flag: "--registry-pod-tolerations-file",
+					IsSet: func() bool { return len(c.RegistryTolerations) != 0 }}),
+		},
+		Unconstrained: []string{"Package", "Bundles", "AdditionalPackages", "Format", "RegistryImage", "SkipCleanupOrphans"},
+	}
+}
+
+// registryResourcesOption returns an Option for a catalog creator's RegistryResources.
+func registryResourcesOption(r corev1.ResourceRequirements) operator.Option {
+	return operator.Option{Field: "RegistryResources", Flag: "--registry-resources", IsSet: func() bool {
+		return len(r.Requests) != 0 || len(r.Limits) != 0
+	}}
+}
+
+// securityContextConfigOption returns an Option for a catalog creator's SecurityContextConfig.
+func securityContextConfigOption(c operator.SecurityContextConfig) operator.Option {
+	return operator.Option{Field: "SecurityContextConfig", Flag: "--security-context-config", IsSet: func() bool {
+		return c != ""
+	}}
+}
+
 func (c ConfigMapCatalogCreator) CreateCatalog(ctx context.Context, name string) (*v1alpha1.CatalogSource, error) {
+	rr, err := c.newRegistryResources()
+	if err != nil {
+		return nil, err
+	}
+	catalogHash, err := rr.GetCatalogHash()
+	if err != nil {
+		return nil, err
+	}
+
 	cs := newCatalogSource(name, c.cfg.Namespace,
 		withSDKPublisher(c.Package.PackageName))
 	if err := c.cfg.Client.Create(ctx, cs); err != nil {
-		return nil, fmt.Errorf("error creating catalog source: %w", err)
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("error creating catalog source: %w", err)
+		}
+		// Reuse the CatalogSource of a previous install, whose registry is updated below.
+		log.Infof("CatalogSource %q already exists", name)
 	}
 
-	if err := c.registryUp(ctx, cs); err != nil {
+	if err := c.registryUp(ctx, cs, rr); err != nil {
 		return nil, fmt.Errorf("error creating registry resources: %w", err)
 	}
 
-	if err := c.updateCatalogSource(ctx, cs); err != nil {
+	if err := c.updateCatalogSource(ctx, cs, catalogHash, rr.GetImage()); err != nil {
 		return nil, fmt.Errorf("error updating catalog source: %w", err)
 	}
 
 	return cs, nil
 }
 
-func (c ConfigMapCatalogCreator) registryUp(ctx context.Context, cs *v1alpha1.CatalogSource) (err error) {
-	rr := configmap.RegistryResources{
-		Pkg:     c.Package,
-		Bundles: c.Bundles,
+// RenderCatalog returns the CatalogSource with name and registry objects
+// CreateCatalog would create, without contacting the cluster. The
+// CatalogSource's UID, which owner references of registry objects are set to,
+// is DryRunUID.
+func (c ConfigMapCatalogCreator) RenderCatalog(name string) ([]runtime.Object, error) {
+	cs := newCatalogSource(name, c.cfg.Namespace,
+		withSDKPublisher(c.Package.PackageName))
+	cs.SetUID(DryRunUID)
+	cs.Spec.Address = configmap.GetRegistryServiceAddr(c.Package.PackageName, c.cfg.Namespace)
+	cs.Spec.SourceType = v1alpha1.SourceTypeGrpc
+
+	rr, err := c.newRegistryResources()
+	if err != nil {
+		return nil, err
+	}
+	catalogHash, err := rr.GetCatalogHash()
+	if err != nil {
+		return nil, err
+	}
+	cs.SetAnnotations(map[string]string{
+		configmap.ContentHashAnnotation: catalogHash,
+		registryImageAnnotation:         rr.GetImage(),
+	})
+	objs, err := rr.MakePackageManifestsRegistryObjects(cs, c.cfg.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering registry resources: %w", err)
+	}
+	return append([]runtime.Object{cs, newRegistryServiceAccount(c.cfg.Namespace)}, objs...), nil
+}
+
+// newRegistryResources returns registry resources for c's package in c.Format.
+func (c ConfigMapCatalogCreator) newRegistryResources() (rr configmap.RegistryResources, err error) {
+	rr = configmap.RegistryResources{
+		Pkg:                       c.Package,
+		Bundles:                   c.Bundles,
+		AdditionalPackages:        c.AdditionalPackages,
+		Image:                     c.RegistryImage,
+		Resources:                 c.RegistryResources,
+		ServiceAccountName:        operator.SDKRegistryServiceAccountName,
+		NodeSelector:              c.RegistryNodeSelector,
+		Tolerations:               c.RegistryTolerations,
+		RestrictedSecurityContext: c.SecurityContextConfig.IsRestricted(),
 	}
+	switch c.Format {
+	case "", CatalogFormatConfigMap:
+	case CatalogFormatFBC:
+		if rr.FBC, err = makeFBC(c.Package, c.Bundles, c.AdditionalPackages...); err != nil {
+			return rr, err
+		}
+	default:
+		return rr, fmt.Errorf("catalog format %q is not supported for package manifests", c.Format)
+	}
+	return rr, nil
+}
+
+// registryUp creates rr's registry objects, or updates them if a registry
+// already exists so unchanged content is not recreated.
+func (c ConfigMapCatalogCreator) registryUp(ctx context.Context, cs *v1alpha1.CatalogSource, rr configmap.RegistryResources) (err error) {
 	if rr.Client, err = olmclient.NewClientForConfig(c.cfg.RESTConfig); err != nil {
 		return err
 	}
+	rr.Client.Logf = c.cfg.VerboseLogf()
+	if _, err := createRegistryServiceAccount(ctx, c.cfg); err != nil {
+		return err
+	}
 
 	if exists, err := rr.IsRegistryExist(ctx, c.cfg.Namespace); err != nil {
 		return fmt.Errorf("error checking registry existence: %v", err)
 	} else if exists {
-		if isRegistryStale, err := rr.IsRegistryDataStale(ctx, c.cfg.Namespace); err == nil {
-			if !isRegistryStale {
-				log.Infof("%s registry data is current", c.Package.PackageName)
-				return nil
-			}
-			log.Infof("A stale %s registry exists, deleting", c.Package.PackageName)
-			if err = rr.DeletePackageManifestsRegistry(ctx, c.cfg.Namespace); err != nil {
-				return fmt.Errorf("error deleting registered package: %w", err)
-			}
-		} else if !apierrors.IsNotFound(err) {
-			return fmt.Errorf("error checking registry data: %w", err)
+		updated, err := rr.UpdatePackageManifestsRegistry(ctx, cs, c.cfg.Namespace)
+		if err != nil {
+			return fmt.Errorf("error updating registry: %w", err)
+		}
+		if updated {
+			log.Infof("Updated %s registry", c.Package.PackageName)
+		} else {
+			log.Infof("%s registry data is current", c.Package.PackageName)
+		}
+		return nil
+	}
+	if !c.SkipCleanupOrphans {
+		if err := rr.DeleteOrphanedRegistryResources(ctx, c.cfg.Namespace); err != nil {
+			return err
 		}
 	}
 	log.Infof("Creating %s registry", c.Package.PackageName)
@@ -94,11 +223,38 @@ func (c ConfigMapCatalogCreator) registryUp(ctx context.Context, cs *v1alpha1.Ca
 	return nil
 }
 
+// makeFBC generates and validates a file-based catalog for pkg and bundles,
+// and any additional packages.
+func makeFBC(pkg *apimanifests.PackageManifest, bundles []*apimanifests.Bundle,
+	additional ...configmap.PackageManifests) ([]byte, error) {
+
+	cfg, err := fbc.New(pkg, bundles)
+	if err != nil {
+		return nil, fmt.Errorf("error generating file-based catalog: %v", err)
+	}
+	for _, p := range additional {
+		pcfg, err := fbc.New(p.Package, p.Bundles)
+		if err != nil {
+			return nil, fmt.Errorf("error generating file-based catalog for package %s: %v", p.Package.PackageName, err)
+		}
+		cfg.Packages = append(cfg.Packages, pcfg.Packages...)
+		cfg.Channels = append(cfg.Channels, pcfg.Channels...)
+		cfg.Bundles = append(cfg.Bundles, pcfg.Bundles...)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid file-based catalog: %v", err)
+	}
+	return cfg.Marshal()
+}
+
 // updateCatalogSource gets the registry address of the newly created
 // ephemeral packagemanifest index pod and updates the catalog source
 // with the necessary address and source type fields to enable the
-// catalog source to connect to the registry.
-func (c *ConfigMapCatalogCreator) updateCatalogSource(ctx context.Context, cs *v1alpha1.CatalogSource) error {
+// catalog source to connect to the registry. The catalog source is
+// annotated with catalogHash so OLM re-syncs it when content changes,
+// and with registryImage for debugging.
+func (c *ConfigMapCatalogCreator) updateCatalogSource(ctx context.Context, cs *v1alpha1.CatalogSource,
+	catalogHash, registryImage string) error {
 	registryGRPCAddr := configmap.GetRegistryServiceAddr(c.Package.PackageName, c.cfg.Namespace)
 	catsrcKey := types.NamespacedName{
 		Namespace: c.cfg.Namespace,
@@ -110,6 +266,13 @@ func (c *ConfigMapCatalogCreator) updateCatalogSource(ctx context.Context, cs *v
 		}
 		cs.Spec.Address = registryGRPCAddr
 		cs.Spec.SourceType = v1alpha1.SourceTypeGrpc
+		annotations := cs.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[configmap.ContentHashAnnotation] = catalogHash
+		annotations[registryImageAnnotation] = registryImage
+		cs.SetAnnotations(annotations)
 		if err := c.cfg.Client.Update(ctx, cs); err != nil {
 			return err
 		}
@@ -15,10 +15,17 @@
 package configmap
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/sha256"
 	"encoding/base32"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	apimanifests "github.com/operator-framework/api/pkg/manifests"
@@ -35,7 +42,7 @@ import (
 func (rr *RegistryResources) getRegistryConfigMaps(ctx context.Context, namespace string) ([]corev1.ConfigMap, error) {
 	list := corev1.ConfigMapList{}
 	opts := []client.ListOption{
-		client.MatchingLabels(makeRegistryLabels(rr.Pkg.PackageName)),
+		client.MatchingLabels(MakeRegistryLabels(rr.Pkg.PackageName)),
 		client.InNamespace(namespace),
 	}
 	err := rr.Client.KubeClient.List(ctx, &list, opts...)
@@ -45,19 +52,97 @@ func (rr *RegistryResources) getRegistryConfigMaps(ctx context.Context, namespac
 	return list.Items, nil
 }
 
+// getRegistryPods performs a List operation to get all Pods labelled as
+// belonging to an operator's registry created by operator-sdk.
+func (rr *RegistryResources) getRegistryPods(ctx context.Context, namespace string) ([]corev1.Pod, error) {
+	list := corev1.PodList{}
+	opts := []client.ListOption{
+		client.MatchingLabels(MakeRegistryLabels(rr.Pkg.PackageName)),
+		client.InNamespace(namespace),
+	}
+	err := rr.Client.KubeClient.List(ctx, &list, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing operator %q Pods: %w", rr.Pkg.PackageName, err)
+	}
+	return list.Items, nil
+}
+
+const (
+	// maxConfigMapDataSize is the maximum size of a registry ConfigMap's binary
+	// data. ConfigMaps are limited to 1MiB, so room is left for metadata.
+	maxConfigMapDataSize = 950 * 1024
+	// shardIndexKey is the binary data key in the first of a set of ConfigMap
+	// shards listing the names of all shards in the set, one per line.
+	shardIndexKey = "shards"
+	// maxShardIndexSize is the maximum size of a shard index.
+	maxShardIndexSize = 8 * 1024
+	// gzipExt is the file name suffix of gzipped manifests in ConfigMap shards.
+	gzipExt = ".gz"
+	// partExt separates a gzipped manifest's file name from the index of one
+	// of its parts, for manifests too large for one shard.
+	partExt = ".part-"
+)
+
+// makeConfigMaps creates a set of ConfigMap binary data for rr's manifests,
+// indexed by ConfigMap name. If rr.FBC is set, a single ConfigMap containing
+// the file-based catalog is created.
+func (rr *RegistryResources) makeConfigMaps() (map[string]map[string][]byte, error) {
+	if rr.FBC == nil {
+		binaryDataByConfigMap, err := makeConfigMapsForPackageManifests(rr.Pkg, rr.Bundles)
+		if err != nil {
+			return nil, err
+		}
+		// ConfigMap names are prefixed by package name, so they don't collide.
+		for _, p := range rr.AdditionalPackages {
+			additional, err := makeConfigMapsForPackageManifests(p.Package, p.Bundles)
+			if err != nil {
+				return nil, err
+			}
+			for name, binaryData := range additional {
+				binaryDataByConfigMap[name] = binaryData
+			}
+		}
+		return binaryDataByConfigMap, nil
+	}
+	// opm reads the catalog file directly, so it cannot be sharded.
+	if len(rr.FBC) > maxConfigMapDataSize {
+		return nil, fmt.Errorf("file-based catalog is %d bytes, larger than the maximum ConfigMap data size of %d bytes; "+
+			"use the %q catalog format instead", len(rr.FBC), maxConfigMapDataSize, "configmap")
+	}
+	cmName := getRegistryConfigMapName(rr.Pkg.PackageName) + "-fbc"
+	return map[string]map[string][]byte{
+		cmName: {hashContents(rr.FBC) + "." + fbcFileName: rr.FBC},
+	}, nil
+}
+
 // makeConfigMapsForPackageManifests creates a set of ConfigMap binary data
 // for a given PackageManifest and Bundles. Each ConfigMaps's binary data is
-// indexed by the ConfigMap's name.
+// indexed by the ConfigMap's name. The package manifest and each bundle are
+// stored gzipped in a set of ConfigMap shards, see shardBinaryData.
 func makeConfigMapsForPackageManifests(pkg *apimanifests.PackageManifest,
 	bundles []*apimanifests.Bundle) (_ map[string]map[string][]byte, err error) {
 
 	binaryDataByConfigMap := make(map[string]map[string][]byte)
+	addShards := func(cmName string, binaryData map[string][]byte) error {
+		shards, err := shardBinaryData(cmName, binaryData)
+		if err != nil {
+			return err
+		}
+		for name, data := range shards {
+			binaryDataByConfigMap[name] = data
+		}
+		return nil
+	}
+
 	// Create a PackageManifest ConfigMap.
 	cmName := getRegistryConfigMapName(pkg.PackageName) + "-package"
-	binaryDataByConfigMap[cmName], err = makeObjectBinaryData(pkg)
+	binaryData, err := makeObjectBinaryData(pkg)
 	if err != nil {
 		return nil, err
 	}
+	if err := addShards(cmName, binaryData); err != nil {
+		return nil, err
+	}
 
 	// Create Bundle ConfigMaps.
 	for _, bundle := range bundles {
@@ -67,15 +152,100 @@ func makeConfigMapsForPackageManifests(pkg *apimanifests.PackageManifest,
 		}
 		// ConfigMap name containing the bundle's version.
 		cmName := getRegistryConfigMapName(pkg.PackageName) + "-" + k8sutil.FormatOperatorNameDNS1123(version)
-		binaryDataByConfigMap[cmName], err = makeBundleBinaryData(bundle)
+		binaryData, err := makeBundleBinaryData(bundle)
 		if err != nil {
 			return nil, err
 		}
+		if err := addShards(cmName, binaryData); err != nil {
+			return nil, err
+		}
 	}
 
 	return binaryDataByConfigMap, nil
 }
 
+// shardBinaryData gzips each file in binaryData and splits the compressed
+// files across as few ConfigMaps as possible without exceeding
+// maxConfigMapDataSize, indexed by ConfigMap name. The first shard is named
+// cmName and lists all shard names under shardIndexKey; subsequent shards are
+// named cmName-shard-<n>. File keys are suffixed with gzipExt but otherwise
+// unchanged, so they still contain a digest of uncompressed contents.
+// A compressed file larger than a shard is split into parts stored in
+// consecutive shards, keyed by the file's key suffixed with partExt and
+// a zero-padded part index, which are concatenated in order when read.
+func shardBinaryData(cmName string, binaryData map[string][]byte) (map[string]map[string][]byte, error) {
+	keys := make([]string, 0, len(binaryData))
+	for k := range binaryData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Leave room in each shard for the index.
+	maxShardSize := maxConfigMapDataSize - maxShardIndexSize
+	var shards []map[string][]byte
+	var size int
+	for _, k := range keys {
+		b, err := gzipBytes(binaryData[k])
+		if err != nil {
+			return nil, fmt.Errorf("error compressing %s: %v", k, err)
+		}
+		if len(b) <= maxShardSize {
+			if len(shards) == 0 || size+len(b) > maxShardSize {
+				shards = append(shards, map[string][]byte{})
+				size = 0
+			}
+			shards[len(shards)-1][k+gzipExt] = b
+			size += len(b)
+			continue
+		}
+		// Fill the current shard, then as many new shards as needed.
+		for i := 0; len(b) != 0; i++ {
+			if len(shards) == 0 || size == maxShardSize {
+				shards = append(shards, map[string][]byte{})
+				size = 0
+			}
+			n := maxShardSize - size
+			if n > len(b) {
+				n = len(b)
+			}
+			shards[len(shards)-1][fmt.Sprintf("%s%s%s%04d", k, gzipExt, partExt, i)] = b[:n]
+			size += n
+			b = b[n:]
+		}
+	}
+
+	names := make([]string, len(shards))
+	byName := make(map[string]map[string][]byte, len(shards))
+	for i, shard := range shards {
+		names[i] = cmName
+		if i > 0 {
+			names[i] = fmt.Sprintf("%s-shard-%d", cmName, i)
+		}
+		byName[names[i]] = shard
+	}
+	if len(shards) != 0 {
+		index := []byte(strings.Join(names, "\n") + "\n")
+		if len(index) > maxShardIndexSize {
+			return nil, fmt.Errorf("manifests for ConfigMap %s require too many shards (%d)", cmName, len(shards))
+		}
+		byName[cmName][shardIndexKey] = index
+	}
+	return byName, nil
+}
+
+// gzipBytes returns b compressed with gzip.
+func gzipBytes(b []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // makeObjectBinaryData creates a ConfigMap's binary data, indexed by a file
 // name key containing names.
 func makeObjectBinaryData(obj interface{}, names ...string) (map[string][]byte, error) {
@@ -120,6 +290,117 @@ func makeObjectFileName(b []byte, names ...string) string {
 	return fileName + "yaml"
 }
 
+// ContentHashAnnotation is the annotation on each registry ConfigMap containing
+// a digest of its binary data. The registry Deployment's pod template and the
+// CatalogSource are annotated with a digest of all registry ConfigMaps, so that
+// changed content restarts the registry pod and re-syncs the catalog.
+const ContentHashAnnotation = "operators.operatorframework.io/registry-content-hash"
+
+// SpecHashAnnotation is set on the registry Deployment to a digest of the spec
+// it was created or last updated with, since the server defaults fields of the
+// stored spec, which therefore can't be compared with a newly generated one.
+const SpecHashAnnotation = "operators.operatorframework.io/registry-spec-hash"
+
+// GetCatalogHash returns a digest of all registry ConfigMaps' binary data.
+func (rr *RegistryResources) GetCatalogHash() (string, error) {
+	binaryDataByConfigMap, err := rr.makeConfigMaps()
+	if err != nil {
+		return "", err
+	}
+	return getCatalogHash(binaryDataByConfigMap), nil
+}
+
+// WriteManifestsDir writes the manifests directory a registry pod assembles
+// from rr's ConfigMaps to dir, so content served from ConfigMaps can be
+// compared with that of the package manifests they were created from.
+// Each set of ConfigMap shards is assembled into a subdirectory named after
+// its first shard, as getAssembleManifestsCmd does.
+func (rr *RegistryResources) WriteManifestsDir(dir string) error {
+	if rr.FBC != nil {
+		return errors.New("a file-based catalog is not assembled into a manifests directory")
+	}
+	binaryDataByConfigMap, err := rr.makeConfigMaps()
+	if err != nil {
+		return err
+	}
+	for _, cmName := range getSortedKeys(binaryDataByConfigMap) {
+		index, ok := binaryDataByConfigMap[cmName][shardIndexKey]
+		if !ok {
+			continue
+		}
+		// Parts are keyed in order, and shards are listed in order.
+		files := map[string][]byte{}
+		for _, shard := range strings.Fields(string(index)) {
+			binaryData := binaryDataByConfigMap[shard]
+			keys := make([]string, 0, len(binaryData))
+			for k := range binaryData {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if !strings.Contains(k, gzipExt) {
+					continue
+				}
+				name := strings.SplitN(k, partExt, 2)[0]
+				files[name] = append(files[name], binaryData[k]...)
+			}
+		}
+		cmDir := filepath.Join(dir, cmName)
+		if err := os.MkdirAll(cmDir, 0755); err != nil {
+			return err
+		}
+		for name, b := range files {
+			zr, err := gzip.NewReader(bytes.NewReader(b))
+			if err != nil {
+				return fmt.Errorf("error decompressing %s: %v", name, err)
+			}
+			data, err := ioutil.ReadAll(zr)
+			if err != nil {
+				return fmt.Errorf("error decompressing %s: %v", name, err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(cmDir, strings.TrimSuffix(name, gzipExt)), data, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getCatalogHash returns a digest of the names and binary data digests of
+// ConfigMaps in binaryDataByConfigMap.
+func getCatalogHash(binaryDataByConfigMap map[string]map[string][]byte) string {
+	buf := &bytes.Buffer{}
+	for _, name := range getSortedKeys(binaryDataByConfigMap) {
+		buf.WriteString(name + "\x00" + hashBinaryData(binaryDataByConfigMap[name]) + "\x00")
+	}
+	return hashContents(buf.Bytes())
+}
+
+// hashBinaryData returns a digest of the keys and values of binaryData.
+func hashBinaryData(binaryData map[string][]byte) string {
+	keys := make([]string, 0, len(binaryData))
+	for k := range binaryData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buf := &bytes.Buffer{}
+	for _, k := range keys {
+		buf.WriteString(k + "\x00")
+		buf.Write(binaryData[k])
+		buf.WriteByte(0)
+	}
+	return hashContents(buf.Bytes())
+}
+
+func getSortedKeys(binaryDataByConfigMap map[string]map[string][]byte) []string {
+	names := make([]string, 0, len(binaryDataByConfigMap))
+	for name := range binaryDataByConfigMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // hashContents creates a sha256 digest of b's bytes.
 func hashContents(b []byte) string {
 	h := sha256.New()
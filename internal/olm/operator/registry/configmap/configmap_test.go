@@ -0,0 +1,437 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configmap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+)
+
+var _ = Describe("Registry ConfigMaps", func() {
+	const (
+		namespace = "testns"
+		pkgName   = "memcached-operator"
+	)
+
+	var (
+		cl     client.Client
+		rr     *RegistryResources
+		catsrc *v1alpha1.CatalogSource
+		bundle *apimanifests.Bundle
+	)
+
+	// newLargeCRD returns a CRD whose description is size bytes of
+	// incompressible text, so its manifest must be split across shards.
+	newLargeCRD := func(size int) *unstructured.Unstructured {
+		b := make([]byte, size*3/4)
+		_, _ = rand.New(rand.NewSource(1)).Read(b)
+		crd := &unstructured.Unstructured{}
+		crd.SetAPIVersion("apiextensions.k8s.io/v1")
+		crd.SetKind("CustomResourceDefinition")
+		crd.SetName("memcacheds.cache.example.com")
+		Expect(unstructured.SetNestedField(crd.Object, base64.StdEncoding.EncodeToString(b), "spec", "validation", "openAPIV3Schema", "description")).To(Succeed())
+		return crd
+	}
+
+	gunzip := func(b []byte) []byte {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		Expect(err).NotTo(HaveOccurred())
+		out, err := ioutil.ReadAll(zr)
+		Expect(err).NotTo(HaveOccurred())
+		return out
+	}
+
+	listConfigMaps := func() []corev1.ConfigMap {
+		cms := corev1.ConfigMapList{}
+		Expect(cl.List(context.TODO(), &cms, client.InNamespace(namespace), client.MatchingLabels(MakeRegistryLabels(pkgName)))).To(Succeed())
+		return cms.Items
+	}
+
+	BeforeEach(func() {
+		sch := runtime.NewScheme()
+		Expect(corev1.AddToScheme(sch)).To(Succeed())
+		Expect(appsv1.AddToScheme(sch)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+		cl = fake.NewFakeClientWithScheme(sch)
+
+		csv := &v1alpha1.ClusterServiceVersion{}
+		Expect(csv.Spec.Version.Set("0.0.1")).To(Succeed())
+		csvU := &unstructured.Unstructured{}
+		csvU.SetAPIVersion(v1alpha1.SchemeGroupVersion.String())
+		csvU.SetKind(v1alpha1.ClusterServiceVersionKind)
+		csvU.SetName("memcached-operator.v0.0.1")
+		csv.SetName(csvU.GetName())
+		bundle = &apimanifests.Bundle{
+			CSV:     csv,
+			Objects: []*unstructured.Unstructured{csvU, newLargeCRD(3 * 1024 * 1024)},
+		}
+
+		rr = &RegistryResources{
+			Client: &olmclient.Client{KubeClient: cl},
+			Pkg: &apimanifests.PackageManifest{
+				PackageName:        pkgName,
+				DefaultChannelName: "alpha",
+				Channels:           []apimanifests.PackageChannel{{Name: "alpha", CurrentCSVName: csv.GetName()}},
+			},
+			Bundles: []*apimanifests.Bundle{bundle},
+		}
+		catsrc = &v1alpha1.CatalogSource{}
+		catsrc.SetName(pkgName + "-catalog")
+		catsrc.SetNamespace(namespace)
+	})
+
+	It("should shard gzipped manifests across ConfigMaps within the size limit", func() {
+		binaryDataByConfigMap, err := rr.makeConfigMaps()
+		Expect(err).NotTo(HaveOccurred())
+
+		bundleCM := getRegistryConfigMapName(pkgName) + "-0-0-1"
+		index, ok := binaryDataByConfigMap[bundleCM][shardIndexKey]
+		Expect(ok).To(BeTrue())
+		shardNames := strings.Fields(string(index))
+		Expect(len(shardNames)).To(BeNumerically(">", 1))
+		Expect(shardNames[0]).To(Equal(bundleCM))
+		// The package ConfigMap and each bundle shard.
+		Expect(binaryDataByConfigMap).To(HaveLen(1 + len(shardNames)))
+
+		// Concatenate parts in shard and part order, as the registry pod does.
+		compressed := map[string][]byte{}
+		var parts int
+		for _, name := range shardNames {
+			var size int
+			keys := []string{}
+			for k, v := range binaryDataByConfigMap[name] {
+				size += len(v)
+				if k != shardIndexKey {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				file := k
+				if i := strings.Index(k, gzipExt+partExt); i >= 0 {
+					file = k[:i+len(gzipExt)]
+					parts++
+				}
+				Expect(file).To(HaveSuffix(gzipExt))
+				compressed[file] = append(compressed[file], binaryDataByConfigMap[name][k]...)
+			}
+			Expect(size).To(BeNumerically("<=", maxConfigMapDataSize))
+		}
+		// The large CRD is larger than a shard even when compressed.
+		Expect(parts).To(BeNumerically(">", 1))
+		assembled := map[string][]byte{}
+		for k, v := range compressed {
+			assembled[strings.TrimSuffix(k, gzipExt)] = gunzip(v)
+		}
+		expected, err := makeBundleBinaryData(bundle)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assembled).To(Equal(expected))
+	})
+
+	It("should write the manifests directory assembled from shards", func() {
+		dir, err := ioutil.TempDir("", "configmap-test-")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		Expect(rr.WriteManifestsDir(dir)).To(Succeed())
+
+		expected, err := makeBundleBinaryData(bundle)
+		Expect(err).NotTo(HaveOccurred())
+		bundleDir := filepath.Join(dir, getRegistryConfigMapName(pkgName)+"-0-0-1")
+		infos, err := ioutil.ReadDir(bundleDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(infos).To(HaveLen(len(expected)))
+		for name, data := range expected {
+			Expect(ioutil.ReadFile(filepath.Join(bundleDir, name))).To(Equal(data))
+		}
+		infos, err = ioutil.ReadDir(filepath.Join(dir, getRegistryConfigMapName(pkgName)+"-package"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(infos).To(HaveLen(1))
+	})
+
+	It("should label and mount every shard", func() {
+		objs, err := rr.MakePackageManifestsRegistryObjects(catsrc, namespace)
+		Expect(err).NotTo(HaveOccurred())
+		var cmNames []string
+		var dep *appsv1.Deployment
+		for _, obj := range objs {
+			switch o := obj.(type) {
+			case *corev1.ConfigMap:
+				Expect(o.GetLabels()).To(Equal(MakeRegistryLabels(pkgName)))
+				cmNames = append(cmNames, o.GetName())
+			case *appsv1.Deployment:
+				dep = o
+			}
+		}
+		Expect(len(cmNames)).To(BeNumerically(">", 2))
+		Expect(dep).NotTo(BeNil())
+		var mounted []string
+		for _, vol := range dep.Spec.Template.Spec.Volumes {
+			mounted = append(mounted, vol.ConfigMap.Name)
+		}
+		Expect(mounted).To(ConsistOf(cmNames))
+	})
+
+	It("should set restricted security contexts on registry pods", func() {
+		getDeployment := func() *appsv1.Deployment {
+			objs, err := rr.MakePackageManifestsRegistryObjects(catsrc, namespace)
+			Expect(err).NotTo(HaveOccurred())
+			for _, obj := range objs {
+				if dep, ok := obj.(*appsv1.Deployment); ok {
+					return dep
+				}
+			}
+			Fail("no registry Deployment")
+			return nil
+		}
+
+		dep := getDeployment()
+		Expect(dep.Spec.Template.Spec.SecurityContext).To(BeNil())
+		Expect(dep.Spec.Template.GetAnnotations()).NotTo(HaveKey(corev1.SeccompPodAnnotationKey))
+
+		rr.RestrictedSecurityContext = true
+		dep = getDeployment()
+		Expect(dep.Spec.Template.GetAnnotations()).To(HaveKey(ContentHashAnnotation))
+		Expect(dep.Spec.Template.GetAnnotations()).To(HaveKeyWithValue(
+			corev1.SeccompPodAnnotationKey, corev1.SeccompProfileRuntimeDefault))
+		Expect(*dep.Spec.Template.Spec.SecurityContext.RunAsNonRoot).To(BeTrue())
+		for _, c := range dep.Spec.Template.Spec.Containers {
+			Expect(*c.SecurityContext.AllowPrivilegeEscalation).To(BeFalse())
+			Expect(c.SecurityContext.Capabilities.Drop).To(Equal([]corev1.Capability{"ALL"}))
+		}
+	})
+
+	It("should schedule registry pods with the node selector and tolerations", func() {
+		rr.NodeSelector = map[string]string{"node-role.kubernetes.io/infra": ""}
+		rr.Tolerations = []corev1.Toleration{{Key: "infra", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}}
+		objs, err := rr.MakePackageManifestsRegistryObjects(catsrc, namespace)
+		Expect(err).NotTo(HaveOccurred())
+		var dep *appsv1.Deployment
+		for _, obj := range objs {
+			if d, ok := obj.(*appsv1.Deployment); ok {
+				dep = d
+			}
+		}
+		Expect(dep).NotTo(BeNil())
+		Expect(dep.Spec.Template.Spec.NodeSelector).To(Equal(rr.NodeSelector))
+		Expect(dep.Spec.Template.Spec.Tolerations).To(Equal(rr.Tolerations))
+	})
+
+	It("should check that a non-default registry image can serve", func() {
+		const image = "mirror.example.com/operator-framework/upstream-registry-builder:v1.13.3"
+		getPodSpec := func() corev1.PodSpec {
+			objs, err := rr.MakePackageManifestsRegistryObjects(catsrc, namespace)
+			Expect(err).NotTo(HaveOccurred())
+			for _, obj := range objs {
+				if dep, ok := obj.(*appsv1.Deployment); ok {
+					return dep.Spec.Template.Spec
+				}
+			}
+			Fail("no registry Deployment")
+			return corev1.PodSpec{}
+		}
+
+		spec := getPodSpec()
+		Expect(spec.Containers[0].Image).To(Equal(DefaultRegistryBaseImage))
+		Expect(spec.Containers[0].Args[1]).NotTo(ContainSubstring("does not contain"))
+
+		rr.Image = image
+		spec = getPodSpec()
+		Expect(spec.Containers[0].Image).To(Equal(image))
+		Expect(spec.Containers[0].Args[1]).To(HavePrefix("for bin in /bin/initializer /bin/registry-server; do " +
+			"[ -x $bin ] || { echo \"registry image " + image + " does not contain $bin\" >&2; exit 1; }; done && "))
+		Expect(spec.InitContainers).To(BeEmpty())
+
+		rr.FBC = []byte(`{"schema":"olm.package","name":"memcached-operator","defaultChannel":"alpha"}`)
+		rr.Image = ""
+		Expect(getPodSpec().InitContainers).To(BeEmpty())
+		rr.Image = "mirror.example.com/operator-framework/opm:v1.19.0"
+		spec = getPodSpec()
+		Expect(spec.InitContainers).To(HaveLen(1))
+		Expect(spec.InitContainers[0].Name).To(Equal(checkRegistryImageContainerName))
+		Expect(spec.InitContainers[0].Image).To(Equal(rr.Image))
+		Expect(spec.InitContainers[0].Command).To(Equal([]string{"/bin/opm"}))
+		Expect(spec.InitContainers[0].Args).To(Equal([]string{"serve", "--help"}))
+	})
+
+	It("should create and delete every shard", func() {
+		objs, err := rr.MakePackageManifestsRegistryObjects(catsrc, namespace)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rr.Client.DoCreate(context.TODO(), objs...)).To(Succeed())
+		cms := listConfigMaps()
+		Expect(len(cms)).To(BeNumerically(">", 2))
+
+		stale, err := rr.IsRegistryDataStale(context.TODO(), namespace)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stale).To(BeFalse())
+		Expect(rr.DeleteOrphanedRegistryResources(context.TODO(), namespace)).To(Succeed())
+		Expect(listConfigMaps()).To(HaveLen(len(cms)))
+
+		Expect(rr.DeletePackageManifestsRegistry(context.TODO(), namespace)).To(Succeed())
+		Expect(listConfigMaps()).To(BeEmpty())
+	})
+
+	It("should reject a file-based catalog larger than a ConfigMap", func() {
+		rr.FBC = make([]byte, maxConfigMapDataSize+1)
+		_, err := rr.makeConfigMaps()
+		Expect(err).To(MatchError(ContainSubstring("larger than the maximum ConfigMap data size")))
+	})
+
+	Describe("UpdatePackageManifestsRegistry", func() {
+		var bundles map[string]*apimanifests.Bundle
+
+		newBundle := func(version, replaces string) *apimanifests.Bundle {
+			csv := &v1alpha1.ClusterServiceVersion{}
+			csv.SetName("memcached-operator.v" + version)
+			Expect(csv.Spec.Version.Set(version)).To(Succeed())
+			csv.Spec.Replaces = replaces
+			csvU := &unstructured.Unstructured{}
+			csvU.SetAPIVersion(v1alpha1.SchemeGroupVersion.String())
+			csvU.SetKind(v1alpha1.ClusterServiceVersionKind)
+			csvU.SetName(csv.GetName())
+			return &apimanifests.Bundle{CSV: csv, Objects: []*unstructured.Unstructured{csvU}}
+		}
+
+		getConfigMaps := func() map[string]corev1.ConfigMap {
+			cms := map[string]corev1.ConfigMap{}
+			for _, cm := range listConfigMaps() {
+				cms[cm.GetName()] = cm
+			}
+			return cms
+		}
+
+		getDeployment := func() *appsv1.Deployment {
+			dep := &appsv1.Deployment{}
+			key := client.ObjectKey{Namespace: namespace, Name: getRegistryServerName(pkgName)}
+			Expect(cl.Get(context.TODO(), key, dep)).To(Succeed())
+			return dep
+		}
+
+		BeforeEach(func() {
+			bundles = map[string]*apimanifests.Bundle{"0.0.1": newBundle("0.0.1", "")}
+			rr.Bundles = []*apimanifests.Bundle{bundles["0.0.1"]}
+			Expect(cl.Create(context.TODO(), catsrc)).To(Succeed())
+
+			objs, err := rr.MakePackageManifestsRegistryObjects(catsrc, namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rr.Client.DoCreate(context.TODO(), objs...)).To(Succeed())
+			// Mark the registry as rolled out, which the fake client does not do.
+			dep := getDeployment()
+			dep.Status = appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+			Expect(cl.Update(context.TODO(), dep)).To(Succeed())
+		})
+
+		It("should not change anything if content is unchanged", func() {
+			before, dep := getConfigMaps(), getDeployment()
+			updated, err := rr.UpdatePackageManifestsRegistry(context.TODO(), catsrc, namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+			Expect(getConfigMaps()).To(Equal(before))
+			Expect(getDeployment().GetResourceVersion()).To(Equal(dep.GetResourceVersion()))
+		})
+		It("should update the changed bundle and restart the registry", func() {
+			before := getConfigMaps()
+			bundle := getRegistryConfigMapName(pkgName) + "-0-0-1"
+			pkgCM := getRegistryConfigMapName(pkgName) + "-package"
+			oldHash := getDeployment().Spec.Template.GetAnnotations()[ContentHashAnnotation]
+
+			bundles["0.0.1"].Objects[0].SetAnnotations(map[string]string{"description": "changed"})
+			updated, err := rr.UpdatePackageManifestsRegistry(context.TODO(), catsrc, namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+
+			after := getConfigMaps()
+			Expect(after).To(HaveLen(len(before)))
+			Expect(after[bundle].GetAnnotations()[ContentHashAnnotation]).NotTo(Equal(before[bundle].GetAnnotations()[ContentHashAnnotation]))
+			Expect(after[bundle].BinaryData).NotTo(Equal(before[bundle].BinaryData))
+			Expect(after[pkgCM]).To(Equal(before[pkgCM]))
+			newHash := getDeployment().Spec.Template.GetAnnotations()[ContentHashAnnotation]
+			Expect(newHash).NotTo(Equal(oldHash))
+			catalogHash, err := rr.GetCatalogHash()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(newHash).To(Equal(catalogHash))
+
+			stale, err := rr.IsRegistryDataStale(context.TODO(), namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stale).To(BeFalse())
+		})
+		It("should add a ConfigMap for an added version", func() {
+			bundles["0.0.2"] = newBundle("0.0.2", "memcached-operator.v0.0.1")
+			rr.Bundles = append(rr.Bundles, bundles["0.0.2"])
+			rr.Pkg.Channels[0].CurrentCSVName = "memcached-operator.v0.0.2"
+
+			updated, err := rr.UpdatePackageManifestsRegistry(context.TODO(), catsrc, namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			newCM := getRegistryConfigMapName(pkgName) + "-0-0-2"
+			Expect(getConfigMaps()).To(HaveKey(newCM))
+			var mounted []string
+			for _, vol := range getDeployment().Spec.Template.Spec.Volumes {
+				mounted = append(mounted, vol.ConfigMap.Name)
+			}
+			Expect(mounted).To(ContainElement(newCM))
+		})
+		It("should delete the ConfigMap of a removed version", func() {
+			rr.Bundles = []*apimanifests.Bundle{newBundle("0.0.2", "")}
+			rr.Pkg.Channels[0].CurrentCSVName = "memcached-operator.v0.0.2"
+
+			updated, err := rr.UpdatePackageManifestsRegistry(context.TODO(), catsrc, namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			cms := getConfigMaps()
+			Expect(cms).To(HaveKey(getRegistryConfigMapName(pkgName) + "-0-0-2"))
+			Expect(cms).NotTo(HaveKey(getRegistryConfigMapName(pkgName) + "-0-0-1"))
+		})
+		It("should update the Deployment if only its spec changed", func() {
+			before := getConfigMaps()
+			oldSpecHash := getDeployment().GetAnnotations()[SpecHashAnnotation]
+			rr.Image = "registry.example.com/operator-framework/upstream-registry-builder:v1.15.3"
+			rr.RestrictedSecurityContext = true
+
+			updated, err := rr.UpdatePackageManifestsRegistry(context.TODO(), catsrc, namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			Expect(getConfigMaps()).To(Equal(before))
+			dep := getDeployment()
+			Expect(dep.GetAnnotations()[SpecHashAnnotation]).NotTo(Equal(oldSpecHash))
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal(rr.Image))
+			Expect(dep.Spec.Template.Spec.SecurityContext).NotTo(BeNil())
+
+			updated, err = rr.UpdatePackageManifestsRegistry(context.TODO(), catsrc, namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+		})
+	})
+})
@@ -16,6 +16,7 @@ package configmap
 
 import (
 	"fmt"
+	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -25,10 +26,13 @@ import (
 )
 
 const (
-	// The image operator-registry's initializer and registry-server binaries
-	// are run from.
+	// DefaultRegistryBaseImage is the image operator-registry's initializer and
+	// registry-server binaries are run from.
 	// QUESTION(estroz): version registry image?
-	registryBaseImage = "quay.io/operator-framework/upstream-registry-builder:latest"
+	DefaultRegistryBaseImage = "quay.io/operator-framework/upstream-registry-builder:latest"
+	// DefaultFBCRegistryImage is the image `opm serve` is run from to serve a
+	// file-based catalog.
+	DefaultFBCRegistryImage = "quay.io/operator-framework/opm:latest"
 	// The port registry-server will listen on within a container.
 	registryGRPCPort = 50051
 	// Path of the bundle database generated by initializer. Use /tmp since it is
@@ -37,6 +41,10 @@ const (
 	// Path of the log file generated by registry-server. Use /tmp since it is
 	// typically world-writable.
 	registryLogFile = "/tmp/termination.log"
+	// Seconds between and before the first registry server readiness checks.
+	registryProbeSeconds = 5
+	// Name of the init container checking that a non-default FBC registry image can serve.
+	checkRegistryImageContainerName = "check-registry-image"
 )
 
 func getRegistryServerName(pkgName string) string {
@@ -47,7 +55,7 @@ func getRegistryServerName(pkgName string) string {
 // getRegistryDeploymentLabels creates a set of labels to identify
 // operator-registry Deployment objects.
 func getRegistryDeploymentLabels(pkgName string) map[string]string {
-	labels := makeRegistryLabels(pkgName)
+	labels := MakeRegistryLabels(pkgName)
 	labels["server-name"] = getRegistryServerName(pkgName)
 	return labels
 }
@@ -99,33 +107,95 @@ func withContainerVolumeMounts(volName string, paths ...string) func(*appsv1.Dep
 	}
 }
 
-// getDBContainerCmd returns a command string that, when run, does two things:
-// 1. Runs a database initializer on the manifests in the /registry
-//    directory.
-// 2. Runs an operator-registry server serving the bundle database.
+// withContainerFileMount returns a function that appends a volumeMount of
+// the file at subPath in volume with name volName to mountPath for each
+// container in the Deployment argument's pod template spec.
+func withContainerFileMount(volName, mountPath, subPath string) func(*appsv1.Deployment) {
+	volumeMount := corev1.VolumeMount{
+		Name:      volName,
+		MountPath: mountPath,
+		SubPath:   subPath,
+	}
+	return func(dep *appsv1.Deployment) {
+		applyToDeploymentPodSpec(dep, func(spec *corev1.PodSpec) {
+			for i := range spec.Containers {
+				spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, volumeMount)
+			}
+		})
+	}
+}
+
+// withContainerResources returns a function that sets the resource
+// requirements of each container in the Deployment argument's pod template
+// spec to resources.
+func withContainerResources(resources corev1.ResourceRequirements) func(*appsv1.Deployment) {
+	return func(dep *appsv1.Deployment) {
+		applyToDeploymentPodSpec(dep, func(spec *corev1.PodSpec) {
+			for i := range spec.Containers {
+				spec.Containers[i].Resources = *resources.DeepCopy()
+			}
+		})
+	}
+}
+
+// getDBContainerCmd returns a command string that, when run, does three things:
+// 1. Assembles manifests from ConfigMap shards into the manifests directory.
+// 2. Runs a database initializer on the manifests directory.
+// 3. Runs an operator-registry server serving the bundle database.
 func getDBContainerCmd(dbPath, logPath string) string {
 	initCmd := fmt.Sprintf("/bin/initializer -o %s -m %s", dbPath, containerManifestsDir)
 	srvCmd := fmt.Sprintf("/bin/registry-server -d %s -t %s", dbPath, logPath)
-	return fmt.Sprintf("%s && %s", initCmd, srvCmd)
+	return fmt.Sprintf("%s && %s && %s", getAssembleManifestsCmd(), initCmd, srvCmd)
+}
+
+// getAssembleManifestsCmd returns a command string that, when run, decompresses
+// the files of each set of ConfigMap shards listed in a shard index into a
+// directory named after the set's first shard, which contains the index.
+// Parts of files split across shards are concatenated in shard and part
+// order before decompression.
+func getAssembleManifestsCmd() string {
+	return fmt.Sprintf("for idx in %[1]s/*/%[2]s; do "+
+		"dir=%[3]s/$(basename $(dirname $idx)) && rm -rf $dir && mkdir -p $dir || exit 1; "+
+		"for shard in $(cat $idx); do for f in %[1]s/$shard/*%[4]s %[1]s/$shard/*%[4]s%[5]s*; do "+
+		"[ -e $f ] || continue; cat $f >> $dir/$(basename ${f%%%[5]s*}) || exit 1; "+
+		"done; done; "+
+		"for f in $dir/*%[4]s; do [ -e $f ] || continue; gunzip $f || exit 1; done; done",
+		containerShardsDir, shardIndexKey, containerManifestsDir, gzipExt, partExt)
+}
+
+// getCheckBinariesCmd returns a command string that, when run, fails with an
+// explanation if image does not contain each of bins.
+func getCheckBinariesCmd(image string, bins ...string) string {
+	return fmt.Sprintf("for bin in %s; do [ -x $bin ] || "+
+		"{ echo \"registry image %s does not contain $bin\" >&2; exit 1; }; done",
+		strings.Join(bins, " "), image)
 }
 
 // withRegistryGRPCContainer returns a function that appends a container
-// running an operator-registry GRPC server to the Deployment argument's
-// pod template spec.
-func withRegistryGRPCContainer(pkgName string) func(*appsv1.Deployment) {
+// running an operator-registry GRPC server from image to the Deployment
+// argument's pod template spec. Images other than DefaultRegistryBaseImage
+// are checked for the binaries the container runs before running them.
+func withRegistryGRPCContainer(pkgName, image string) func(*appsv1.Deployment) {
+	if image == "" {
+		image = DefaultRegistryBaseImage
+	}
+	cmd := getDBContainerCmd(registryDBName, registryLogFile)
+	if image != DefaultRegistryBaseImage {
+		cmd = getCheckBinariesCmd(image, "/bin/initializer", "/bin/registry-server") + " && " + cmd
+	}
 	container := corev1.Container{
 		Name:       getRegistryServerName(pkgName),
-		Image:      registryBaseImage,
+		Image:      image,
 		WorkingDir: "/tmp",
 		Command:    []string{"/bin/sh"},
 		Args: []string{
 			"-c",
-			// TODO(estroz): grab logs and print if error
-			getDBContainerCmd(registryDBName, registryLogFile),
+			cmd,
 		},
 		Ports: []corev1.ContainerPort{
 			{Name: "registry-grpc", ContainerPort: registryGRPCPort},
 		},
+		ReadinessProbe: newRegistryReadinessProbe(),
 	}
 	return func(dep *appsv1.Deployment) {
 		applyToDeploymentPodSpec(dep, func(spec *corev1.PodSpec) {
@@ -134,6 +204,56 @@ func withRegistryGRPCContainer(pkgName string) func(*appsv1.Deployment) {
 	}
 }
 
+// newRegistryReadinessProbe returns a probe that checks the registry server's
+// gRPC health endpoint with grpc_health_probe, which registry images contain,
+// as OLM does for the registry pods it creates.
+func newRegistryReadinessProbe() *corev1.Probe {
+	return &corev1.Probe{
+		Handler: corev1.Handler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"grpc_health_probe", fmt.Sprintf("-addr=localhost:%d", registryGRPCPort)},
+			},
+		},
+		InitialDelaySeconds: registryProbeSeconds,
+		PeriodSeconds:       registryProbeSeconds,
+		TimeoutSeconds:      registryProbeSeconds,
+	}
+}
+
+// withFBCRegistryGRPCContainer returns a function that appends a container
+// running `opm serve` on the file-based catalog in containerFBCDir from image
+// to the Deployment argument's pod template spec. Since opm images may not
+// contain a shell, images other than DefaultFBCRegistryImage are checked for an
+// opm supporting `serve` by an init container.
+func withFBCRegistryGRPCContainer(pkgName, image string) func(*appsv1.Deployment) {
+	if image == "" {
+		image = DefaultFBCRegistryImage
+	}
+	container := corev1.Container{
+		Name:    getRegistryServerName(pkgName),
+		Image:   image,
+		Command: []string{"/bin/opm"},
+		Args:    []string{"serve", containerFBCDir, "-p", fmt.Sprintf("%d", registryGRPCPort)},
+		Ports: []corev1.ContainerPort{
+			{Name: "registry-grpc", ContainerPort: registryGRPCPort},
+		},
+		ReadinessProbe: newRegistryReadinessProbe(),
+	}
+	return func(dep *appsv1.Deployment) {
+		applyToDeploymentPodSpec(dep, func(spec *corev1.PodSpec) {
+			if image != DefaultFBCRegistryImage {
+				spec.InitContainers = append(spec.InitContainers, corev1.Container{
+					Name:    checkRegistryImageContainerName,
+					Image:   image,
+					Command: []string{"/bin/opm"},
+					Args:    []string{"serve", "--help"},
+				})
+			}
+			spec.Containers = append(spec.Containers, container)
+		})
+	}
+}
+
 // newRegistryDeployment creates a new Deployment with a name derived from
 // pkgName, the package manifest's packageName, in namespace. The Deployment
 // and replicas are created with labels derived from pkgName. opts will be
@@ -16,16 +16,20 @@ package configmap
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"path"
+	"sort"
 
 	apimanifests "github.com/operator-framework/api/pkg/manifests"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
 	log "github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
@@ -33,9 +37,17 @@ import (
 )
 
 const (
+	// The directory each registry ConfigMap shard is mounted in, by name.
+	containerShardsDir = "/registry/shards"
 	// The root directory containing of a package manifests format for an
-	// operator, with the package manifest being top-level.
-	containerManifestsDir = "/registry/manifests"
+	// operator, with the package manifest being top-level. Manifests are
+	// assembled here from ConfigMap shards on container start, so the
+	// directory must be writable.
+	containerManifestsDir = "/tmp/registry/manifests"
+	// The root directory containing a file-based catalog served by opm.
+	containerFBCDir = "/configs"
+	// File name suffix of a file-based catalog in a ConfigMap and container.
+	fbcFileName = "catalog.json"
 )
 
 // SDKLabels are used to identify certain operator-sdk resources.
@@ -49,6 +61,45 @@ type RegistryResources struct {
 	Client  *olmclient.Client
 	Pkg     *apimanifests.PackageManifest
 	Bundles []*apimanifests.Bundle
+	// AdditionalPackages are served by the same registry as Pkg, whose name
+	// registry objects are named and labeled after.
+	AdditionalPackages []PackageManifests
+	// Image is the registry server image. Defaults to DefaultRegistryBaseImage,
+	// or DefaultFBCRegistryImage if FBC is set.
+	Image string
+	// FBC is a file-based catalog generated from Pkg, Bundles, and
+	// AdditionalPackages. If set, it is served by `opm serve` instead of
+	// loading manifests into a database.
+	FBC []byte
+	// Resources are the registry server container's resource requirements.
+	Resources corev1.ResourceRequirements
+	// ServiceAccountName is the name of the ServiceAccount the registry pod runs
+	// as, the namespace's default ServiceAccount if empty.
+	ServiceAccountName string
+	// NodeSelector and Tolerations constrain the nodes the registry pod is
+	// scheduled on.
+	NodeSelector map[string]string
+	Tolerations  []corev1.Toleration
+	// RestrictedSecurityContext sets security contexts satisfying the restricted
+	// Pod Security Standard on registry pods.
+	RestrictedSecurityContext bool
+}
+
+// GetImage returns the registry server image rr's registry Deployment runs.
+func (rr RegistryResources) GetImage() string {
+	switch {
+	case rr.Image != "":
+		return rr.Image
+	case rr.FBC != nil:
+		return DefaultFBCRegistryImage
+	}
+	return DefaultRegistryBaseImage
+}
+
+// PackageManifests are a package manifest and its bundles.
+type PackageManifests struct {
+	Package *apimanifests.PackageManifest
+	Bundles []*apimanifests.Bundle
 }
 
 // IsRegistryExist returns true if a registry Deployment exists in namespace.
@@ -74,15 +125,14 @@ func (rr *RegistryResources) IsRegistryDataStale(ctx context.Context, namespace
 	if err != nil {
 		return false, err
 	}
-	// Simple length comparison for packages + package manifest ConfigMaps.
-	if len(configMaps) != len(rr.Bundles)+1 {
-		return true, nil
-	}
-
-	binaryDataByConfigMap, err := makeConfigMapsForPackageManifests(rr.Pkg, rr.Bundles)
+	binaryDataByConfigMap, err := rr.makeConfigMaps()
 	if err != nil {
 		return false, err
 	}
+	// Simple length comparison for packages + package manifest ConfigMaps.
+	if len(configMaps) != len(binaryDataByConfigMap) {
+		return true, nil
+	}
 
 	for _, configMap := range configMaps {
 		binaryData, hasName := binaryDataByConfigMap[configMap.GetName()]
@@ -114,73 +164,297 @@ func (rr *RegistryResources) IsRegistryDataStale(ctx context.Context, namespace
 // manifests from rr.manifests in namespace.
 func (rr *RegistryResources) CreatePackageManifestsRegistry(ctx context.Context, catsrc *v1alpha1.CatalogSource, namespace string) error {
 	pkgName := rr.Pkg.PackageName
-	labels := makeRegistryLabels(pkgName)
 
-	binaryDataByConfigMap, err := makeConfigMapsForPackageManifests(rr.Pkg, rr.Bundles)
+	catsrcKey := types.NamespacedName{
+		Namespace: catsrc.Namespace,
+		Name:      catsrc.Name,
+	}
+	if err := rr.Client.KubeClient.Get(ctx, catsrcKey, catsrc); err != nil {
+		return fmt.Errorf("get catalog source: %v", err)
+	}
+
+	objs, err := rr.MakePackageManifestsRegistryObjects(catsrc, namespace)
 	if err != nil {
 		return err
 	}
+	if err := rr.Client.DoCreate(ctx, objs...); err != nil {
+		return fmt.Errorf("error creating operator %q registry-server objects: %w", pkgName, err)
+	}
+
+	// Wait for registry Deployment rollout.
+	depKey := types.NamespacedName{
+		Name:      getRegistryServerName(pkgName),
+		Namespace: namespace,
+	}
+	log.Infof("Waiting for Deployment %q rollout to complete", depKey)
+	if err := rr.Client.DoRolloutWaitCheckPods(ctx, depKey); err != nil {
+		return fmt.Errorf("error waiting for Deployment %q to roll out: %w", depKey, err)
+	}
+
+	return nil
+}
+
+// UpdatePackageManifestsRegistry updates the registry objects in namespace to
+// serve manifests from rr, and returns true if any objects changed. ConfigMaps
+// whose ContentHashAnnotation matches their new content are left alone, new
+// ConfigMaps are created, and ConfigMaps no longer needed are deleted once the
+// registry Deployment, whose pod template is annotated with the catalog's
+// digest, has rolled out pods serving the new content. The Deployment is also
+// updated if its SpecHashAnnotation differs, ex. because its image, resources,
+// or security context changed, even if the content did not.
+func (rr *RegistryResources) UpdatePackageManifestsRegistry(ctx context.Context, catsrc *v1alpha1.CatalogSource, namespace string) (bool, error) {
+	pkgName := rr.Pkg.PackageName
 
 	catsrcKey := types.NamespacedName{
 		Namespace: catsrc.Namespace,
 		Name:      catsrc.Name,
 	}
 	if err := rr.Client.KubeClient.Get(ctx, catsrcKey, catsrc); err != nil {
-		return fmt.Errorf("get catalog source: %v", err)
+		return false, fmt.Errorf("get catalog source: %v", err)
+	}
+
+	objs, err := rr.MakePackageManifestsRegistryObjects(catsrc, namespace)
+	if err != nil {
+		return false, err
+	}
+	configMaps, err := rr.getRegistryConfigMaps(ctx, namespace)
+	if err != nil {
+		return false, err
+	}
+	existing := make(map[string]*corev1.ConfigMap, len(configMaps))
+	for i := range configMaps {
+		existing[configMaps[i].GetName()] = &configMaps[i]
+	}
+
+	var toCreate, toUpdate, toDelete []runtime.Object
+	var dep *appsv1.Deployment
+	for _, obj := range objs {
+		switch o := obj.(type) {
+		case *corev1.ConfigMap:
+			old, ok := existing[o.GetName()]
+			delete(existing, o.GetName())
+			if !ok {
+				toCreate = append(toCreate, o)
+			} else if old.GetAnnotations()[ContentHashAnnotation] != o.GetAnnotations()[ContentHashAnnotation] {
+				o.SetResourceVersion(old.GetResourceVersion())
+				toUpdate = append(toUpdate, o)
+			}
+		case *appsv1.Deployment:
+			dep = o
+		}
+	}
+	oldNames := make([]string, 0, len(existing))
+	for name := range existing {
+		oldNames = append(oldNames, name)
+	}
+	sort.Strings(oldNames)
+	for _, name := range oldNames {
+		toDelete = append(toDelete, existing[name])
+	}
+	depKey := types.NamespacedName{
+		Name:      dep.GetName(),
+		Namespace: namespace,
+	}
+	current := &appsv1.Deployment{}
+	if err := rr.Client.KubeClient.Get(ctx, depKey, current); err != nil {
+		return false, fmt.Errorf("error getting Deployment %q: %w", depKey, err)
+	}
+	depChanged := current.GetAnnotations()[SpecHashAnnotation] != dep.GetAnnotations()[SpecHashAnnotation]
+	if len(toCreate)+len(toUpdate)+len(toDelete) == 0 && !depChanged {
+		return false, nil
 	}
 
+	if err := rr.Client.DoCreate(ctx, toCreate...); err != nil {
+		return false, fmt.Errorf("error creating operator %q registry ConfigMaps: %w", pkgName, err)
+	}
+	for _, obj := range toUpdate {
+		cm := obj.(*corev1.ConfigMap)
+		log.Infof("  Updating ConfigMap %q", cm.GetName())
+		if err := rr.Client.KubeClient.Update(ctx, cm); err != nil {
+			return false, fmt.Errorf("error updating operator %q registry ConfigMap %q: %w", pkgName, cm.GetName(), err)
+		}
+	}
+
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		current := &appsv1.Deployment{}
+		if err := rr.Client.KubeClient.Get(ctx, depKey, current); err != nil {
+			return err
+		}
+		current.Spec = dep.Spec
+		annotations := current.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[SpecHashAnnotation] = dep.GetAnnotations()[SpecHashAnnotation]
+		current.SetAnnotations(annotations)
+		return rr.Client.KubeClient.Update(ctx, current)
+	}); err != nil {
+		return false, fmt.Errorf("error updating Deployment %q: %w", depKey, err)
+	}
+	log.Infof("Waiting for Deployment %q rollout to complete", depKey)
+	if err := rr.Client.DoRolloutWaitCheckPods(ctx, depKey); err != nil {
+		return false, fmt.Errorf("error waiting for Deployment %q to roll out: %w", depKey, err)
+	}
+
+	if err := rr.Client.DoDelete(ctx, toDelete...); err != nil {
+		return false, fmt.Errorf("error deleting unused operator %q registry ConfigMaps: %w", pkgName, err)
+	}
+	return true, nil
+}
+
+// MakePackageManifestsRegistryObjects returns the registry objects required to
+// serve manifests from rr in namespace, owned by catsrc: ConfigMaps sorted by
+// name, followed by the registry Deployment and Service. No objects are created.
+func (rr *RegistryResources) MakePackageManifestsRegistryObjects(catsrc *v1alpha1.CatalogSource, namespace string) ([]runtime.Object, error) {
+	pkgName := rr.Pkg.PackageName
+	labels := MakeRegistryLabels(pkgName)
+
+	binaryDataByConfigMap, err := rr.makeConfigMaps()
+	if err != nil {
+		return nil, err
+	}
+	cmNames := getSortedKeys(binaryDataByConfigMap)
+
 	// Objects to create.
 	objs := make([]runtime.Object, 0, len(binaryDataByConfigMap)+2)
 	// Options for creating a Deployment, since we need to mount all package
 	// ConfigMaps as volumes into pods.
 	opts := make([]func(*appsv1.Deployment), 0, 2*len(binaryDataByConfigMap)+1)
-	opts = append(opts, withRegistryGRPCContainer(pkgName))
+	if rr.FBC != nil {
+		opts = append(opts, withFBCRegistryGRPCContainer(pkgName, rr.Image))
+	} else {
+		opts = append(opts, withRegistryGRPCContainer(pkgName, rr.Image))
+	}
+	opts = append(opts, withContainerResources(rr.Resources))
 	// Build all package ConfigMaps.
-	for cmName, binaryData := range binaryDataByConfigMap {
+	for _, cmName := range cmNames {
+		binaryData := binaryDataByConfigMap[cmName]
 		cm := newConfigMap(cmName, namespace, withBinaryData(binaryData))
 		cm.SetLabels(labels)
+		cm.SetAnnotations(map[string]string{ContentHashAnnotation: hashBinaryData(binaryData)})
 		if err := controllerutil.SetOwnerReference(catsrc, cm, olmclient.Scheme); err != nil {
-			return fmt.Errorf("set configmap %q owner reference: %v", cm.GetName(), err)
+			return nil, fmt.Errorf("set configmap %q owner reference: %v", cm.GetName(), err)
 		}
 		objs = append(objs, cm)
 
 		volName := k8sutil.TrimDNS1123Label(cmName + "-volume")
-		opts = append(opts,
-			withConfigMapVolume(volName, cmName),
-			withContainerVolumeMounts(volName, path.Join(containerManifestsDir, cmName)),
-		)
+		opts = append(opts, withConfigMapVolume(volName, cmName))
+		if rr.FBC != nil {
+			// Mount the catalog file directly so opm does not read ConfigMap volume metadata.
+			for fileKey := range binaryData {
+				mountPath := path.Join(containerFBCDir, k8sutil.FormatOperatorNameDNS1123(pkgName), fbcFileName)
+				opts = append(opts, withContainerFileMount(volName, mountPath, fileKey))
+			}
+		} else {
+			opts = append(opts, withContainerVolumeMounts(volName, path.Join(containerShardsDir, cmName)))
+		}
 	}
 
 	// Add registry Deployment and Service to objects.
 	dep := newRegistryDeployment(pkgName, namespace, opts...)
 	dep.SetLabels(labels)
+	// Pods are replaced when the catalog changes, so updated content is served.
+	dep.Spec.Template.SetAnnotations(map[string]string{ContentHashAnnotation: getCatalogHash(binaryDataByConfigMap)})
+	dep.Spec.Template.Spec.ServiceAccountName = rr.ServiceAccountName
+	dep.Spec.Template.Spec.NodeSelector = rr.NodeSelector
+	dep.Spec.Template.Spec.Tolerations = rr.Tolerations
+	if rr.RestrictedSecurityContext {
+		k8sutil.SetRestrictedSecurityContext(&dep.Spec.Template.ObjectMeta, &dep.Spec.Template.Spec)
+	}
+	specHash, err := hashDeploymentSpec(dep.Spec)
+	if err != nil {
+		return nil, err
+	}
+	dep.SetAnnotations(map[string]string{SpecHashAnnotation: specHash})
 	if err := controllerutil.SetOwnerReference(catsrc, dep, olmclient.Scheme); err != nil {
-		return fmt.Errorf("set deployment %q owner reference: %v", dep.GetName(), err)
+		return nil, fmt.Errorf("set deployment %q owner reference: %v", dep.GetName(), err)
 	}
 	service := newRegistryService(pkgName, namespace, withTCPPort("grpc", registryGRPCPort))
 	service.SetLabels(labels)
 	if err := controllerutil.SetOwnerReference(catsrc, service, olmclient.Scheme); err != nil {
-		return fmt.Errorf("set service %q owner reference: %v", service.GetName(), err)
+		return nil, fmt.Errorf("set service %q owner reference: %v", service.GetName(), err)
 	}
-	objs = append(objs, dep, service)
+	return append(objs, dep, service), nil
+}
 
-	if err := rr.Client.DoCreate(ctx, objs...); err != nil {
-		return fmt.Errorf("error creating operator %q registry-server objects: %w", pkgName, err)
+// hashDeploymentSpec returns a digest of spec's JSON encoding. Object keys
+// are sorted, so the digest does not change if a newer API type only reorders
+// its fields.
+func hashDeploymentSpec(spec appsv1.DeploymentSpec) (string, error) {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("error encoding registry Deployment spec: %v", err)
+	}
+	var obj interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return "", fmt.Errorf("error encoding registry Deployment spec: %v", err)
 	}
+	if b, err = json.Marshal(obj); err != nil {
+		return "", fmt.Errorf("error encoding registry Deployment spec: %v", err)
+	}
+	return hashContents(b), nil
+}
 
-	// Wait for registry Deployment rollout.
-	depKey := types.NamespacedName{
-		Name:      dep.GetName(),
-		Namespace: namespace,
+// DeleteOrphanedRegistryResources deletes registry ConfigMaps whose content no
+// longer matches rr's manifests, as well as registry pods left behind by a
+// registry Deployment that no longer exists. Such objects are typically left in
+// namespace by previous failed installs.
+func (rr *RegistryResources) DeleteOrphanedRegistryResources(ctx context.Context, namespace string) error {
+	configMaps, err := rr.getRegistryConfigMaps(ctx, namespace)
+	if err != nil {
+		return err
 	}
-	log.Infof("Waiting for Deployment %q rollout to complete", depKey)
-	if err := rr.Client.DoRolloutWait(ctx, depKey); err != nil {
-		return fmt.Errorf("error waiting for Deployment %q to roll out: %w", depKey, err)
+	binaryDataByConfigMap, err := rr.makeConfigMaps()
+	if err != nil {
+		return err
+	}
+
+	var orphans []runtime.Object
+	for i := range configMaps {
+		cm := &configMaps[i]
+		if !isBinaryDataEqual(binaryDataByConfigMap[cm.GetName()], cm.BinaryData) {
+			orphans = append(orphans, cm)
+		}
+	}
+
+	exists, err := rr.IsRegistryExist(ctx, namespace)
+	if err != nil {
+		return fmt.Errorf("error checking registry existence: %v", err)
+	}
+	if !exists {
+		pods, err := rr.getRegistryPods(ctx, namespace)
+		if err != nil {
+			return err
+		}
+		for i := range pods {
+			orphans = append(orphans, &pods[i])
+		}
 	}
 
+	if len(orphans) == 0 {
+		return nil
+	}
+	log.Infof("Deleting %d orphaned %s registry objects", len(orphans), rr.Pkg.PackageName)
+	if err := rr.Client.DoDelete(ctx, orphans...); err != nil {
+		return fmt.Errorf("error deleting orphaned operator %q registry objects: %w", rr.Pkg.PackageName, err)
+	}
 	return nil
 }
 
+// isBinaryDataEqual returns true if a and b contain the same file keys. Keys
+// contain a digest of file contents, so values do not need to be compared.
+func isBinaryDataEqual(a, b map[string][]byte) bool {
+	if len(a) == 0 || len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
 // DeletePackageManifestsRegistry deletes all registry objects serving manifests
 // for an operator in namespace.
 // TODO: delete by owner reference.
@@ -215,8 +489,9 @@ func GetRegistryServiceAddr(pkgName, namespace string) string {
 	return fmt.Sprintf("%s.%s.svc.cluster.local:%d", name, namespace, registryGRPCPort)
 }
 
-// makeRegistryLabels creates a set of labels to identify operator-registry objects.
-func makeRegistryLabels(pkgName string) map[string]string {
+// MakeRegistryLabels creates a set of labels to identify operator-registry objects
+// created by operator-sdk for pkgName.
+func MakeRegistryLabels(pkgName string) map[string]string {
 	labels := map[string]string{
 		"package-name": k8sutil.TrimDNS1123Label(pkgName),
 	}
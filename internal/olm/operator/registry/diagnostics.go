@@ -0,0 +1,263 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+
+	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
+)
+
+// DiagnosticsTimeout bounds the time spent collecting diagnostics of a failed
+// install, so an unresponsive cluster can't delay the failure much further.
+const DiagnosticsTimeout = 30 * time.Second
+
+// DefaultDiagnosticsDir returns the directory diagnostics collected at now are
+// written to by default, relative to the working directory.
+func DefaultDiagnosticsDir(now time.Time) string {
+	return "operator-sdk-diagnostics-" + now.UTC().Format("20060102T150405Z")
+}
+
+// DiagnosticsCollector writes the state of an install's namespace to a local
+// directory, so a failed install can be debugged once the namespace is gone.
+type DiagnosticsCollector struct {
+	// PackageName selects the registry pods whose logs are collected.
+	PackageName string
+	// CatalogSourceName selects the bundle unpack pods whose logs are collected.
+	CatalogSourceName string
+	// GetPodLogs returns logs of the Pod with key. Defaults to the OLM client's
+	// getter, which gets them from the cluster's API.
+	GetPodLogs func(ctx context.Context, key types.NamespacedName, opts *corev1.PodLogOptions) ([]byte, error)
+
+	cfg *operator.Configuration
+}
+
+func NewDiagnosticsCollector(cfg *operator.Configuration) *DiagnosticsCollector {
+	return &DiagnosticsCollector{cfg: cfg}
+}
+
+// Collect writes Subscriptions, CSVs, InstallPlans, CatalogSources, and Events of
+// the install namespace to dir as YAML, along with a summary of the conditions of
+// Subscriptions and InstallPlans, and the logs of registry and bundle unpack pods.
+// Failures to get individual objects or logs are written to dir/errors.txt so the
+// rest are still collected; only failing to write to dir is returned.
+func (c DiagnosticsCollector) Collect(ctx context.Context, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating diagnostics directory: %v", err)
+	}
+	d := diagnostics{dir: dir, scheme: c.cfg.Scheme}
+
+	subs := v1alpha1.SubscriptionList{}
+	ips := v1alpha1.InstallPlanList{}
+	csvs := v1alpha1.ClusterServiceVersionList{}
+	catsrcs := v1alpha1.CatalogSourceList{}
+	events := corev1.EventList{}
+	for name, list := range map[string]runtime.Object{
+		"subscriptions.yaml":          &subs,
+		"installplans.yaml":           &ips,
+		"clusterserviceversions.yaml": &csvs,
+		"catalogsources.yaml":         &catsrcs,
+		"events.yaml":                 &events,
+	} {
+		if err := c.cfg.Client.List(ctx, list, client.InNamespace(c.cfg.Namespace)); err != nil {
+			d.addError("list %s: %v", name, err)
+			continue
+		}
+		if el, ok := list.(*corev1.EventList); ok {
+			sortEvents(el.Items)
+		}
+		if err := d.writeList(name, list); err != nil {
+			return err
+		}
+	}
+
+	summary := &bytes.Buffer{}
+	for i := range subs.Items {
+		fmt.Fprintln(summary, getSubscriptionCondition(&subs.Items[i]))
+	}
+	for i := range ips.Items {
+		fmt.Fprintln(summary, getInstallPlanCondition(&ips.Items[i]))
+	}
+	if err := d.writeFile("conditions.txt", summary.Bytes()); err != nil {
+		return err
+	}
+
+	pods, err := c.getInstallPods(ctx, catsrcs.Items)
+	if err != nil {
+		d.addError("get registry and bundle unpack pods: %v", err)
+	}
+	podList := &corev1.PodList{Items: pods}
+	if err := d.writeList("pods.yaml", podList); err != nil {
+		return err
+	}
+	getPodLogs := c.GetPodLogs
+	if getPodLogs == nil {
+		if cl, err := olmclient.NewClientForConfig(c.cfg.RESTConfig); err != nil {
+			d.addError("get pod logs: %v", err)
+		} else {
+			getPodLogs = cl.GetPodLogs
+		}
+	}
+	for _, pod := range pods {
+		if getPodLogs == nil {
+			break
+		}
+		key := types.NamespacedName{Namespace: pod.GetNamespace(), Name: pod.GetName()}
+		for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+			logs, err := getPodLogs(ctx, key, &corev1.PodLogOptions{Container: container.Name})
+			if err != nil {
+				d.addError("get pod %q container %q logs: %v", key, container.Name, err)
+				continue
+			}
+			if err := d.writeFile(filepath.Join("logs", pod.GetName(), container.Name+".log"), logs); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(d.errs) != 0 {
+		return d.writeFile("errors.txt", d.errs)
+	}
+	return nil
+}
+
+// getInstallPods returns the registry pods labeled as c.PackageName's, and the
+// pods of OLM's bundle unpack Jobs for the catalog source named c.CatalogSourceName
+// in catsrcs. Unpack pods are unlabeled, so are found by owner reference: they are
+// owned by Jobs owned by ConfigMaps owned by the catalog source.
+func (c DiagnosticsCollector) getInstallPods(ctx context.Context, catsrcs []v1alpha1.CatalogSource) ([]corev1.Pod, error) {
+	opts := client.InNamespace(c.cfg.Namespace)
+	pods := corev1.PodList{}
+	if err := c.cfg.Client.List(ctx, &pods, opts); err != nil {
+		return nil, err
+	}
+	owners := map[types.UID]bool{}
+	for _, cs := range catsrcs {
+		if cs.GetName() == c.CatalogSourceName && cs.GetUID() != "" {
+			owners[cs.GetUID()] = true
+		}
+	}
+	if len(owners) != 0 {
+		cms := corev1.ConfigMapList{}
+		if err := c.cfg.Client.List(ctx, &cms, opts); err != nil {
+			return nil, err
+		}
+		jobs := batchv1.JobList{}
+		if err := c.cfg.Client.List(ctx, &jobs, opts); err != nil {
+			return nil, err
+		}
+		for i := range cms.Items {
+			if operator.IsOwnedByAny(&cms.Items[i], owners) {
+				owners[cms.Items[i].GetUID()] = true
+			}
+		}
+		for i := range jobs.Items {
+			if operator.IsOwnedByAny(&jobs.Items[i], owners) {
+				owners[jobs.Items[i].GetUID()] = true
+			}
+		}
+	}
+
+	registryLabels := configmap.MakeRegistryLabels(c.PackageName)
+	var installPods []corev1.Pod
+	for _, pod := range pods.Items {
+		if hasLabels(pod.GetLabels(), registryLabels) || operator.IsOwnedByAny(&pod, owners) {
+			installPods = append(installPods, pod)
+		}
+	}
+	return installPods, nil
+}
+
+func hasLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// sortEvents sorts events by the time they were last seen.
+func sortEvents(events []corev1.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastTimestamp.Before(&events[j].LastTimestamp)
+	})
+}
+
+// diagnostics writes diagnostics files to dir, and accumulates collection errors.
+type diagnostics struct {
+	dir string
+	// scheme, if set, is used to set the kind of written objects.
+	scheme *runtime.Scheme
+	errs   []byte
+}
+
+func (d *diagnostics) addError(format string, args ...interface{}) {
+	d.errs = append(d.errs, fmt.Sprintf(format+"\n", args...)...)
+}
+
+// writeList writes the items of list to name as a YAML stream.
+func (d *diagnostics) writeList(name string, list runtime.Object) error {
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	for _, item := range items {
+		if d.scheme != nil {
+			if gvk, err := apiutil.GVKForObject(item, d.scheme); err == nil {
+				item.GetObjectKind().SetGroupVersionKind(gvk)
+			}
+		}
+		b, err := yaml.Marshal(item)
+		if err != nil {
+			d.addError("marshal %s item: %v", name, err)
+			continue
+		}
+		buf.WriteString("---\n")
+		buf.Write(b)
+	}
+	return d.writeFile(name, buf.Bytes())
+}
+
+func (d *diagnostics) writeFile(name string, data []byte) error {
+	path := filepath.Join(d.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating diagnostics directory: %v", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing diagnostics: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
+)
+
+var _ = Describe("DiagnosticsCollector", func() {
+	const (
+		ns      = "testns"
+		pkgName = "memcached-operator"
+	)
+
+	var (
+		c   *DiagnosticsCollector
+		dir string
+	)
+
+	// newPod returns a pod with a container of the same name, owned by owner if set.
+	newPod := func(name string, owner metav1.Object) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.SetName(name)
+		pod.SetNamespace(ns)
+		pod.Spec.Containers = []corev1.Container{{Name: name}}
+		if owner != nil {
+			pod.SetOwnerReferences([]metav1.OwnerReference{{Name: owner.GetName(), UID: owner.GetUID()}})
+		}
+		return pod
+	}
+	readFile := func(name string) string {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		Expect(err).NotTo(HaveOccurred())
+		return string(b)
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "operator-sdk-diagnostics-test-")
+		Expect(err).NotTo(HaveOccurred())
+
+		sch := runtime.NewScheme()
+		Expect(corev1.AddToScheme(sch)).To(Succeed())
+		Expect(batchv1.AddToScheme(sch)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+
+		cs := newCatalogSource(pkgName+"-catalog", ns)
+		cs.SetUID("catsrc-uid")
+		unpackCM := &corev1.ConfigMap{}
+		unpackCM.SetName("unpack-cm")
+		unpackCM.SetNamespace(ns)
+		unpackCM.SetUID("cm-uid")
+		unpackCM.SetOwnerReferences([]metav1.OwnerReference{{Name: cs.GetName(), UID: cs.GetUID()}})
+		unpackJob := &batchv1.Job{}
+		unpackJob.SetName("unpack-job")
+		unpackJob.SetNamespace(ns)
+		unpackJob.SetUID("job-uid")
+		unpackJob.SetOwnerReferences([]metav1.OwnerReference{{Name: unpackCM.GetName(), UID: unpackCM.GetUID()}})
+		registryPod := newPod("registry-pod", nil)
+		registryPod.SetLabels(configmap.MakeRegistryLabels(pkgName))
+		sub := newSubscription("memcached-operator.v0.0.1", ns)
+		sub.SetName("memcached-operator-sub")
+		sub.Status.State = v1alpha1.SubscriptionStateUpgradePending
+		ip := &v1alpha1.InstallPlan{}
+		ip.SetName("install-abcde")
+		ip.SetNamespace(ns)
+		ip.Status.Phase = v1alpha1.InstallPlanPhaseInstalling
+		event := &corev1.Event{Reason: "BackOff", Message: "Back-off pulling image"}
+		event.SetName("registry-pod.abcde")
+		event.SetNamespace(ns)
+		event.LastTimestamp = metav1.NewTime(time.Now())
+
+		cl := fake.NewFakeClientWithScheme(sch, cs, unpackCM, unpackJob, registryPod,
+			newPod("unpack-pod", unpackJob), newPod("unrelated-pod", nil), sub, ip, event)
+		c = NewDiagnosticsCollector(&operator.Configuration{Client: cl, Scheme: sch, Namespace: ns})
+		c.PackageName = pkgName
+		c.CatalogSourceName = cs.GetName()
+		c.GetPodLogs = func(_ context.Context, key types.NamespacedName, opts *corev1.PodLogOptions) ([]byte, error) {
+			if key.Name == "unpack-pod" {
+				return nil, errors.New("container not started")
+			}
+			return []byte(key.Name + "/" + opts.Container + " logs\n"), nil
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("should write namespace objects and events as YAML", func() {
+		Expect(c.Collect(context.TODO(), dir)).To(Succeed())
+		Expect(readFile("subscriptions.yaml")).To(ContainSubstring("kind: Subscription"))
+		Expect(readFile("installplans.yaml")).To(ContainSubstring("name: install-abcde"))
+		Expect(readFile("catalogsources.yaml")).To(ContainSubstring("name: memcached-operator-catalog"))
+		Expect(readFile("events.yaml")).To(ContainSubstring("message: Back-off pulling image"))
+		Expect(readFile("clusterserviceversions.yaml")).To(BeEmpty())
+	})
+
+	It("should summarize subscription and install plan conditions", func() {
+		Expect(c.Collect(context.TODO(), dir)).To(Succeed())
+		Expect(readFile("conditions.txt")).To(Equal(
+			`subscription "memcached-operator-sub" has no conditions (state "UpgradePending")` + "\n" +
+				`installplan "install-abcde" phase "Installing"` + "\n"))
+	})
+
+	It("should collect logs of registry and bundle unpack pods only", func() {
+		Expect(c.Collect(context.TODO(), dir)).To(Succeed())
+		Expect(readFile(filepath.Join("logs", "registry-pod", "registry-pod.log"))).To(Equal("registry-pod/registry-pod logs\n"))
+		pods := readFile("pods.yaml")
+		Expect(pods).To(ContainSubstring("name: registry-pod"))
+		Expect(pods).To(ContainSubstring("name: unpack-pod"))
+		Expect(pods).NotTo(ContainSubstring("name: unrelated-pod"))
+		_, err := os.Stat(filepath.Join(dir, "logs", "unrelated-pod"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should record collection failures and collect the rest", func() {
+		Expect(c.Collect(context.TODO(), dir)).To(Succeed())
+		Expect(readFile("errors.txt")).To(Equal(
+			`get pod "testns/unpack-pod" container "unpack-pod" logs: container not started` + "\n"))
+		Expect(readFile(filepath.Join("logs", "registry-pod", "registry-pod.log"))).NotTo(BeEmpty())
+	})
+
+	It("should record failures once its context is done", func() {
+		ctx, cancel := context.WithCancel(context.TODO())
+		c.GetPodLogs = func(ctx context.Context, key types.NamespacedName, _ *corev1.PodLogOptions) ([]byte, error) {
+			cancel()
+			return nil, ctx.Err()
+		}
+		Expect(c.Collect(ctx, dir)).To(Succeed())
+		Expect(readFile("errors.txt")).To(ContainSubstring("context canceled"))
+	})
+
+	It("should name the default directory after the time", func() {
+		now := time.Date(2020, 10, 14, 9, 30, 5, 0, time.UTC)
+		Expect(DefaultDiagnosticsDir(now)).To(Equal("operator-sdk-diagnostics-20201014T093005Z"))
+	})
+})
@@ -0,0 +1,216 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+)
+
+// PackageManifestGVK is the kind served by OLM's packageserver describing the
+// packages of each ready catalog source.
+var PackageManifestGVK = schema.GroupVersionKind{Group: "packages.operators.coreos.com", Version: "v1", Kind: "PackageManifest"}
+
+// Labels packageserver sets on package manifests identifying their catalog source.
+const (
+	packageManifestCatalogLabel          = "catalog"
+	packageManifestCatalogNamespaceLabel = "catalog-namespace"
+)
+
+// ExistingCatalogCreator "creates" a catalog by checking that an existing
+// CatalogSource, not created by the SDK, is ready and serves the bundle being
+// installed. No registry objects are created.
+type ExistingCatalogCreator struct {
+	// CatalogSourceName and CatalogSourceNamespace identify the existing
+	// CatalogSource. CatalogSourceNamespace defaults to the install namespace.
+	CatalogSourceName      string
+	CatalogSourceNamespace string
+	// PackageName, Channel, and CSVName identify the bundle that must be in the catalog.
+	PackageName string
+	Channel     string
+	CSVName     string
+
+	cfg *operator.Configuration
+}
+
+func NewExistingCatalogCreator(cfg *operator.Configuration) *ExistingCatalogCreator {
+	return &ExistingCatalogCreator{
+		cfg: cfg,
+	}
+}
+
+// ParseCatalogSource parses a CatalogSource reference of the form name[/namespace].
+// namespace is empty if not specified.
+func ParseCatalogSource(ref string) (name, namespace string, err error) {
+	split := strings.Split(ref, "/")
+	if len(split) > 2 || split[0] == "" || (len(split) == 2 && split[1] == "") {
+		return "", "", fmt.Errorf("catalog source %q must be of the form name[/namespace]", ref)
+	}
+	if len(split) == 2 {
+		namespace = split[1]
+	}
+	return split[0], namespace, nil
+}
+
+func (c ExistingCatalogCreator) getKey() types.NamespacedName {
+	key := types.NamespacedName{Namespace: c.CatalogSourceNamespace, Name: c.CatalogSourceName}
+	if key.Namespace == "" {
+		key.Namespace = c.cfg.Namespace
+	}
+	return key
+}
+
+// CreateCatalog returns the existing CatalogSource once its connection is READY
+// and it serves c.CSVName in c.Channel of c.PackageName. name is ignored.
+func (c ExistingCatalogCreator) CreateCatalog(ctx context.Context, _ string) (*v1alpha1.CatalogSource, error) {
+	key := c.getKey()
+	cs := &v1alpha1.CatalogSource{}
+	if err := c.cfg.Client.Get(ctx, key, cs); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("catalog source %q not found", key)
+		}
+		return nil, fmt.Errorf("error getting catalog source %q: %v", key, err)
+	}
+	log.Infof("Using existing CatalogSource: %s", key)
+
+	if err := c.waitForReady(ctx, cs); err != nil {
+		return nil, err
+	}
+	if err := c.checkBundle(ctx, key); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// waitForReady waits for cs's connection state to be READY.
+func (c ExistingCatalogCreator) waitForReady(ctx context.Context, cs *v1alpha1.CatalogSource) error {
+	key := c.getKey()
+	err := wait.PollImmediateUntil(200*time.Millisecond, func() (bool, error) {
+		if err := c.cfg.Client.Get(ctx, key, cs); err != nil {
+			return false, err
+		}
+		state := cs.Status.GRPCConnectionState
+		return state != nil && state.LastObservedState == "READY", nil
+	}, ctx.Done())
+	if err != nil {
+		state := "none"
+		if cs.Status.GRPCConnectionState != nil {
+			state = cs.Status.GRPCConnectionState.LastObservedState
+		}
+		return fmt.Errorf("catalog source %q is not ready (connection state %q): %v", key, state, err)
+	}
+	return nil
+}
+
+// packageManifestStatus contains the fields of a package manifest's status
+// identifying the bundles in each of its channels.
+type packageManifestStatus struct {
+	Channels []struct {
+		Name       string `json:"name"`
+		CurrentCSV string `json:"currentCSV"`
+		// Entries is only populated by OLM versions listing every bundle in a channel.
+		Entries []struct {
+			Name string `json:"name"`
+		} `json:"entries"`
+	} `json:"channels"`
+}
+
+// checkBundle returns an error if the package manifests of the catalog source
+// named by key do not contain c.CSVName in c.Channel of c.PackageName.
+// Since packageserver lists a catalog's packages some time after it becomes
+// ready, an empty list of package manifests is polled until ctx is done.
+func (c ExistingCatalogCreator) checkBundle(ctx context.Context, key types.NamespacedName) error {
+	pms := &unstructured.UnstructuredList{}
+	pms.SetGroupVersionKind(PackageManifestGVK.GroupVersion().WithKind(PackageManifestGVK.Kind + "List"))
+	opts := []client.ListOption{
+		client.InNamespace(key.Namespace),
+		client.MatchingLabels{
+			packageManifestCatalogLabel:          key.Name,
+			packageManifestCatalogNamespaceLabel: key.Namespace,
+		},
+	}
+	var listErr error
+	err := wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		if listErr = c.cfg.Client.List(ctx, pms, opts...); listErr != nil {
+			return false, listErr
+		}
+		return len(pms.Items) != 0, nil
+	}, ctx.Done())
+	if listErr != nil {
+		return fmt.Errorf("error listing packages of catalog source %q: %v", key, listErr)
+	}
+	if err != nil {
+		return fmt.Errorf("no packages found in catalog source %q: %v", key, err)
+	}
+
+	var pm *unstructured.Unstructured
+	for i := range pms.Items {
+		if pms.Items[i].GetName() == c.PackageName {
+			pm = &pms.Items[i]
+			break
+		}
+	}
+	if pm == nil {
+		return fmt.Errorf("package %q not found in catalog source %q", c.PackageName, key)
+	}
+	status := packageManifestStatus{}
+	statusObj, _, _ := unstructured.NestedMap(pm.Object, "status")
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(statusObj, &status); err != nil {
+		return fmt.Errorf("error decoding package manifest %q: %v", c.PackageName, err)
+	}
+	for _, ch := range status.Channels {
+		if ch.Name != c.Channel {
+			continue
+		}
+		if ch.CurrentCSV == c.CSVName {
+			return nil
+		}
+		for _, e := range ch.Entries {
+			if e.Name == c.CSVName {
+				return nil
+			}
+		}
+		return fmt.Errorf("bundle %q not found in channel %q of package %q in catalog source %q",
+			c.CSVName, c.Channel, c.PackageName, key)
+	}
+	return fmt.Errorf("channel %q of package %q not found in catalog source %q", c.Channel, c.PackageName, key)
+}
+
+// GetCatalogCondition describes the connection state of the existing catalog source.
+func (c ExistingCatalogCreator) GetCatalogCondition(ctx context.Context, _ string) string {
+	cs := &v1alpha1.CatalogSource{}
+	key := c.getKey()
+	if err := c.cfg.Client.Get(ctx, key, cs); err != nil {
+		return fmt.Sprintf("error getting catalog source %q: %v", key, err)
+	}
+	if state := cs.Status.GRPCConnectionState; state != nil && state.LastObservedState != "" {
+		return fmt.Sprintf("catalog source %q connection state %q", key, state.LastObservedState)
+	}
+	return fmt.Sprintf("catalog source %q has no connection state", key)
+}
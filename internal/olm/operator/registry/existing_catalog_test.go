@@ -0,0 +1,164 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+)
+
+var _ = Describe("ExistingCatalogCreator", func() {
+	const (
+		ns      = "testns"
+		csNS    = "olm"
+		pkgName = "memcached-operator"
+		csvName = "memcached-operator.v0.0.2"
+	)
+
+	var (
+		cfg *operator.Configuration
+		c   *ExistingCatalogCreator
+	)
+
+	newCatalogSource := func(state string) *v1alpha1.CatalogSource {
+		cs := &v1alpha1.CatalogSource{}
+		cs.SetName("admin-catalog")
+		cs.SetNamespace(csNS)
+		if state != "" {
+			cs.Status.GRPCConnectionState = &v1alpha1.GRPCConnectionState{LastObservedState: state}
+		}
+		return cs
+	}
+	newPackageManifest := func(name string, channels ...interface{}) *unstructured.Unstructured {
+		pm := &unstructured.Unstructured{}
+		pm.SetGroupVersionKind(PackageManifestGVK)
+		pm.SetName(name)
+		pm.SetNamespace(csNS)
+		pm.SetLabels(map[string]string{"catalog": "admin-catalog", "catalog-namespace": csNS})
+		Expect(unstructured.SetNestedSlice(pm.Object, channels, "status", "channels")).To(Succeed())
+		return pm
+	}
+
+	BeforeEach(func() {
+		sch := runtime.NewScheme()
+		Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+		sch.AddKnownTypeWithName(PackageManifestGVK, &unstructured.Unstructured{})
+		sch.AddKnownTypeWithName(PackageManifestGVK.GroupVersion().WithKind(PackageManifestGVK.Kind+"List"),
+			&unstructured.UnstructuredList{})
+		cfg = &operator.Configuration{
+			Scheme:    sch,
+			Client:    fake.NewFakeClientWithScheme(sch),
+			Namespace: ns,
+		}
+		c = NewExistingCatalogCreator(cfg)
+		c.CatalogSourceName = "admin-catalog"
+		c.CatalogSourceNamespace = csNS
+		c.PackageName = pkgName
+		c.Channel = "alpha"
+		c.CSVName = csvName
+	})
+
+	Describe("ParseCatalogSource", func() {
+		It("should parse a name", func() {
+			name, namespace, err := ParseCatalogSource("admin-catalog")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("admin-catalog"))
+			Expect(namespace).To(BeEmpty())
+		})
+		It("should parse a name and namespace", func() {
+			name, namespace, err := ParseCatalogSource("admin-catalog/olm")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("admin-catalog"))
+			Expect(namespace).To(Equal("olm"))
+		})
+		It("should reject malformed references", func() {
+			for _, ref := range []string{"/olm", "admin-catalog/", "admin-catalog/olm/foo"} {
+				_, _, err := ParseCatalogSource(ref)
+				Expect(err).To(MatchError(ContainSubstring("must be of the form name[/namespace]")), ref)
+			}
+		})
+	})
+
+	Describe("CreateCatalog", func() {
+		It("should return a ready catalog source serving the bundle", func() {
+			Expect(cfg.Client.Create(context.TODO(), newCatalogSource("READY"))).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newPackageManifest(pkgName, map[string]interface{}{
+				"name":       "alpha",
+				"currentCSV": "memcached-operator.v0.0.3",
+				"entries": []interface{}{
+					map[string]interface{}{"name": "memcached-operator.v0.0.3"},
+					map[string]interface{}{"name": csvName},
+				},
+			}))).To(Succeed())
+
+			cs, err := c.CreateCatalog(context.TODO(), "memcached-operator-catalog")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cs.GetName()).To(Equal("admin-catalog"))
+			Expect(cs.GetNamespace()).To(Equal(csNS))
+		})
+		It("should default the namespace to the install namespace", func() {
+			c.CatalogSourceNamespace = ""
+			Expect(c.getKey().Namespace).To(Equal(ns))
+		})
+		It("should return an error if the catalog source does not exist", func() {
+			_, err := c.CreateCatalog(context.TODO(), "")
+			Expect(err).To(MatchError(`catalog source "olm/admin-catalog" not found`))
+		})
+		It("should return an error if the catalog source is not ready", func() {
+			Expect(cfg.Client.Create(context.TODO(), newCatalogSource("TRANSIENT_FAILURE"))).To(Succeed())
+			ctx, cancel := context.WithTimeout(context.TODO(), 500*time.Millisecond)
+			defer cancel()
+			_, err := c.CreateCatalog(ctx, "")
+			Expect(err).To(MatchError(ContainSubstring(`catalog source "olm/admin-catalog" is not ready (connection state "TRANSIENT_FAILURE")`)))
+		})
+		It("should return an error if the package is not in the catalog", func() {
+			Expect(cfg.Client.Create(context.TODO(), newCatalogSource("READY"))).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newPackageManifest("etcd-operator", map[string]interface{}{
+				"name": "alpha", "currentCSV": "etcd-operator.v0.9.4",
+			}))).To(Succeed())
+
+			_, err := c.CreateCatalog(context.TODO(), "")
+			Expect(err).To(MatchError(`package "memcached-operator" not found in catalog source "olm/admin-catalog"`))
+		})
+		It("should return an error if the bundle is not in the channel", func() {
+			Expect(cfg.Client.Create(context.TODO(), newCatalogSource("READY"))).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newPackageManifest(pkgName, map[string]interface{}{
+				"name": "alpha", "currentCSV": "memcached-operator.v0.0.1",
+			}))).To(Succeed())
+
+			_, err := c.CreateCatalog(context.TODO(), "")
+			Expect(err).To(MatchError(`bundle "memcached-operator.v0.0.2" not found in channel "alpha" of package ` +
+				`"memcached-operator" in catalog source "olm/admin-catalog"`))
+		})
+		It("should return an error if the channel is not in the package", func() {
+			Expect(cfg.Client.Create(context.TODO(), newCatalogSource("READY"))).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newPackageManifest(pkgName, map[string]interface{}{
+				"name": "stable", "currentCSV": csvName,
+			}))).To(Succeed())
+
+			_, err := c.CreateCatalog(context.TODO(), "")
+			Expect(err).To(MatchError(`channel "alpha" of package "memcached-operator" not found in catalog source "olm/admin-catalog"`))
+		})
+	})
+})
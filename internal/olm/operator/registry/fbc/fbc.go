@@ -0,0 +1,309 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fbc generates file-based catalogs (declarative config) from
+// package manifests, as served by `opm serve`.
+package fbc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+)
+
+// Schemas of declarative config blobs.
+const (
+	SchemaPackage = "olm.package"
+	SchemaChannel = "olm.channel"
+	SchemaBundle  = "olm.bundle"
+)
+
+// Types of bundle properties.
+const (
+	PropertyPackage      = "olm.package"
+	PropertyGVK          = "olm.gvk"
+	PropertyGVKRequired  = "olm.gvk.required"
+	PropertyBundleObject = "olm.bundle.object"
+)
+
+// skipRangeAnnotation is the CSV annotation containing a bundle's skip range.
+const skipRangeAnnotation = "olm.skipRange"
+
+// DeclarativeConfig is a file-based catalog of one or more packages.
+type DeclarativeConfig struct {
+	Packages []Package
+	Channels []Channel
+	Bundles  []Bundle
+}
+
+type Package struct {
+	Schema         string `json:"schema"`
+	Name           string `json:"name"`
+	DefaultChannel string `json:"defaultChannel"`
+}
+
+type Channel struct {
+	Schema  string         `json:"schema"`
+	Package string         `json:"package"`
+	Name    string         `json:"name"`
+	Entries []ChannelEntry `json:"entries"`
+}
+
+type ChannelEntry struct {
+	Name      string   `json:"name"`
+	Replaces  string   `json:"replaces,omitempty"`
+	Skips     []string `json:"skips,omitempty"`
+	SkipRange string   `json:"skipRange,omitempty"`
+}
+
+type Bundle struct {
+	Schema     string     `json:"schema"`
+	Name       string     `json:"name"`
+	Package    string     `json:"package"`
+	Image      string     `json:"image,omitempty"`
+	Properties []Property `json:"properties"`
+}
+
+type Property struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+type packageValue struct {
+	PackageName string `json:"packageName"`
+	Version     string `json:"version"`
+}
+
+type gvkValue struct {
+	Group   string `json:"group"`
+	Kind    string `json:"kind"`
+	Version string `json:"version"`
+}
+
+type bundleObjectValue struct {
+	Data []byte `json:"data"`
+}
+
+// New creates a DeclarativeConfig for pkg and bundles. Bundles are not backed
+// by images, so all bundle manifests are embedded as olm.bundle.object properties.
+func New(pkg *apimanifests.PackageManifest, bundles []*apimanifests.Bundle) (*DeclarativeConfig, error) {
+	cfg := &DeclarativeConfig{
+		Packages: []Package{{
+			Schema:         SchemaPackage,
+			Name:           pkg.PackageName,
+			DefaultChannel: getDefaultChannel(pkg),
+		}},
+	}
+
+	bundlesByName := make(map[string]*apimanifests.Bundle, len(bundles))
+	for _, b := range bundles {
+		bundlesByName[b.CSV.GetName()] = b
+		fb, err := newBundle(pkg.PackageName, b)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Bundles = append(cfg.Bundles, fb)
+	}
+	sort.Slice(cfg.Bundles, func(i, j int) bool { return cfg.Bundles[i].Name < cfg.Bundles[j].Name })
+
+	for _, c := range pkg.Channels {
+		ch := Channel{Schema: SchemaChannel, Package: pkg.PackageName, Name: c.Name}
+		// Walk the replaces chain back from the channel head.
+		seen := map[string]bool{}
+		for name := c.CurrentCSVName; name != "" && !seen[name]; {
+			seen[name] = true
+			b, ok := bundlesByName[name]
+			if !ok {
+				break
+			}
+			ch.Entries = append(ch.Entries, ChannelEntry{
+				Name:      name,
+				Replaces:  b.CSV.Spec.Replaces,
+				Skips:     b.CSV.Spec.Skips,
+				SkipRange: b.CSV.GetAnnotations()[skipRangeAnnotation],
+			})
+			name = b.CSV.Spec.Replaces
+		}
+		cfg.Channels = append(cfg.Channels, ch)
+	}
+	sort.Slice(cfg.Channels, func(i, j int) bool { return cfg.Channels[i].Name < cfg.Channels[j].Name })
+
+	return cfg, nil
+}
+
+// getDefaultChannel returns pkg's default channel, or its only channel if unset.
+func getDefaultChannel(pkg *apimanifests.PackageManifest) string {
+	if pkg.DefaultChannelName == "" && len(pkg.Channels) == 1 {
+		return pkg.Channels[0].Name
+	}
+	return pkg.DefaultChannelName
+}
+
+func newBundle(pkgName string, b *apimanifests.Bundle) (Bundle, error) {
+	fb := Bundle{Schema: SchemaBundle, Name: b.CSV.GetName(), Package: pkgName}
+
+	pkgProp, err := newProperty(PropertyPackage, packageValue{
+		PackageName: pkgName,
+		Version:     b.CSV.Spec.Version.String(),
+	})
+	if err != nil {
+		return Bundle{}, err
+	}
+	fb.Properties = append(fb.Properties, pkgProp)
+
+	for _, gvk := range getGVKs(b.CSV.Spec.CustomResourceDefinitions.Owned) {
+		p, err := newProperty(PropertyGVK, gvk)
+		if err != nil {
+			return Bundle{}, err
+		}
+		fb.Properties = append(fb.Properties, p)
+	}
+	for _, gvk := range getGVKs(b.CSV.Spec.CustomResourceDefinitions.Required) {
+		p, err := newProperty(PropertyGVKRequired, gvk)
+		if err != nil {
+			return Bundle{}, err
+		}
+		fb.Properties = append(fb.Properties, p)
+	}
+
+	objs := append(b.Objects[:0:0], b.Objects...)
+	sort.SliceStable(objs, func(i, j int) bool {
+		if objs[i].GetKind() != objs[j].GetKind() {
+			return objs[i].GetKind() < objs[j].GetKind()
+		}
+		return objs[i].GetName() < objs[j].GetName()
+	})
+	for _, obj := range objs {
+		data, err := json.Marshal(obj.Object)
+		if err != nil {
+			return Bundle{}, fmt.Errorf("error marshaling bundle %s object %s %q: %v", fb.Name, obj.GetKind(), obj.GetName(), err)
+		}
+		p, err := newProperty(PropertyBundleObject, bundleObjectValue{Data: data})
+		if err != nil {
+			return Bundle{}, err
+		}
+		fb.Properties = append(fb.Properties, p)
+	}
+	return fb, nil
+}
+
+// getGVKs returns sorted GVKs for a CSV's CRD descriptions.
+func getGVKs(descs []v1alpha1.CRDDescription) (gvks []gvkValue) {
+	for _, d := range descs {
+		group := d.Name
+		if i := strings.Index(d.Name, "."); i >= 0 {
+			group = d.Name[i+1:]
+		}
+		gvks = append(gvks, gvkValue{Group: group, Kind: d.Kind, Version: d.Version})
+	}
+	sort.Slice(gvks, func(i, j int) bool {
+		a, b := gvks[i], gvks[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Version < b.Version
+	})
+	return gvks
+}
+
+func newProperty(typ string, v interface{}) (Property, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return Property{}, fmt.Errorf("error marshaling %s property: %v", typ, err)
+	}
+	return Property{Type: typ, Value: b}, nil
+}
+
+// WriteJSON writes cfg to w as a stream of indented JSON blobs, with the
+// package blob first, followed by channels and bundles.
+func (cfg DeclarativeConfig) WriteJSON(w io.Writer) error {
+	var blobs []interface{}
+	for _, p := range cfg.Packages {
+		blobs = append(blobs, p)
+	}
+	for _, c := range cfg.Channels {
+		blobs = append(blobs, c)
+	}
+	for _, b := range cfg.Bundles {
+		blobs = append(blobs, b)
+	}
+	for _, blob := range blobs {
+		b, err := json.MarshalIndent(blob, "", "    ")
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadJSON reads a DeclarativeConfig from a stream of JSON blobs, as written by
+// WriteJSON. Blobs of unknown schemas are an error.
+func ReadJSON(r io.Reader) (*DeclarativeConfig, error) {
+	cfg := &DeclarativeConfig{}
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("error decoding declarative config: %v", err)
+		}
+		meta := struct {
+			Schema string `json:"schema"`
+		}{}
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return nil, fmt.Errorf("error decoding declarative config: %v", err)
+		}
+		var err error
+		switch meta.Schema {
+		case SchemaPackage:
+			p := Package{}
+			err = json.Unmarshal(raw, &p)
+			cfg.Packages = append(cfg.Packages, p)
+		case SchemaChannel:
+			c := Channel{}
+			err = json.Unmarshal(raw, &c)
+			cfg.Channels = append(cfg.Channels, c)
+		case SchemaBundle:
+			b := Bundle{}
+			err = json.Unmarshal(raw, &b)
+			cfg.Bundles = append(cfg.Bundles, b)
+		default:
+			return nil, fmt.Errorf("unknown declarative config schema %q", meta.Schema)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error decoding %s blob: %v", meta.Schema, err)
+		}
+	}
+	return cfg, nil
+}
+
+// Marshal returns cfg as written by WriteJSON.
+func (cfg DeclarativeConfig) Marshal() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := cfg.WriteJSON(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fbc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+)
+
+var _ = Describe("DeclarativeConfig", func() {
+	var (
+		pkg     *apimanifests.PackageManifest
+		bundles []*apimanifests.Bundle
+	)
+
+	BeforeEach(func() {
+		var err error
+		pkg, bundles, err = apimanifests.GetManifestsDir(filepath.Join("testdata", "memcached-operator"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bundles).To(HaveLen(2))
+	})
+
+	Describe("New", func() {
+		It("should match the golden file for a multi-channel package", func() {
+			cfg, err := New(pkg, bundles)
+			Expect(err).NotTo(HaveOccurred())
+			b, err := cfg.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+			golden, err := ioutil.ReadFile(filepath.Join("testdata", "memcached-operator.catalog.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(b)).To(Equal(string(golden)))
+		})
+		It("should default to the only channel", func() {
+			pkg.DefaultChannelName = ""
+			pkg.Channels = pkg.Channels[1:]
+			cfg, err := New(pkg, bundles)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Packages[0].DefaultChannel).To(Equal("stable"))
+		})
+	})
+
+	Describe("ReadJSON", func() {
+		It("should read what WriteJSON writes", func() {
+			cfg, err := New(pkg, bundles)
+			Expect(err).NotTo(HaveOccurred())
+			b, err := cfg.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+			read, err := ReadJSON(bytes.NewReader(b))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read.Packages).To(Equal(cfg.Packages))
+			Expect(read.Channels).To(Equal(cfg.Channels))
+			// Property values are re-indented when written, so only compare their content.
+			Expect(read.Bundles).To(HaveLen(len(cfg.Bundles)))
+			for i, b := range read.Bundles {
+				Expect(b.Name).To(Equal(cfg.Bundles[i].Name))
+				Expect(b.Properties).To(HaveLen(len(cfg.Bundles[i].Properties)))
+				for j, p := range b.Properties {
+					Expect(string(p.Value)).To(MatchJSON(string(cfg.Bundles[i].Properties[j].Value)))
+				}
+			}
+		})
+		It("should fail on an unknown schema", func() {
+			_, err := ReadJSON(strings.NewReader(`{"schema": "olm.foo"}`))
+			Expect(err).To(MatchError(`unknown declarative config schema "olm.foo"`))
+		})
+	})
+
+	Describe("Validate", func() {
+		var cfg *DeclarativeConfig
+
+		BeforeEach(func() {
+			var err error
+			cfg, err = New(pkg, bundles)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should succeed for a generated catalog", func() {
+			Expect(cfg.Validate()).To(Succeed())
+		})
+		It("should fail if the default channel does not exist", func() {
+			cfg.Packages[0].DefaultChannel = "beta"
+			Expect(cfg.Validate()).To(MatchError(ContainSubstring(`"beta"`)))
+		})
+		It("should fail if a channel has more than one head", func() {
+			cfg.Channels[1].Entries[0].Replaces = ""
+			Expect(cfg.Validate()).To(MatchError(ContainSubstring("head")))
+		})
+		It("should fail if a channel entry is not a bundle in the package", func() {
+			cfg.Channels[0].Entries = append(cfg.Channels[0].Entries, ChannelEntry{Name: "memcached-operator.v0.0.3"})
+			Expect(cfg.Validate()).To(MatchError(ContainSubstring(`"memcached-operator.v0.0.3"`)))
+		})
+		It("should fail if a bundle is in no channel", func() {
+			cfg.Channels = cfg.Channels[:1]
+			cfg.Packages[0].DefaultChannel = "alpha"
+			Expect(cfg.Validate()).To(MatchError(ContainSubstring(`"memcached-operator.v0.0.2"`)))
+		})
+		It("should fail if a bundle has no package property", func() {
+			cfg.Bundles[0].Properties = cfg.Bundles[0].Properties[1:]
+			Expect(cfg.Validate()).To(MatchError(ContainSubstring(PropertyPackage)))
+		})
+	})
+})
@@ -0,0 +1,79 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fbc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+// Validate checks that cfg is a valid file-based catalog by converting it to
+// opm's catalog model and validating that, as `opm validate` does.
+func (cfg DeclarativeConfig) Validate() error {
+	dcfg, err := cfg.toDeclcfg()
+	if err != nil {
+		return err
+	}
+	m, err := declcfg.ConvertToModel(*dcfg)
+	if err != nil {
+		return err
+	}
+	return m.Validate()
+}
+
+// toDeclcfg converts cfg to opm's declarative config types. Like opm's loader,
+// bundle objects are populated from olm.bundle.object properties, since model
+// validation requires a bundle without an image to embed its objects.
+func (cfg DeclarativeConfig) toDeclcfg() (*declcfg.DeclarativeConfig, error) {
+	dcfg := &declcfg.DeclarativeConfig{}
+	for _, p := range cfg.Packages {
+		dcfg.Packages = append(dcfg.Packages, declcfg.Package{
+			Schema:         p.Schema,
+			Name:           p.Name,
+			DefaultChannel: p.DefaultChannel,
+		})
+	}
+	for _, c := range cfg.Channels {
+		dc := declcfg.Channel{Schema: c.Schema, Package: c.Package, Name: c.Name}
+		for _, e := range c.Entries {
+			dc.Entries = append(dc.Entries, declcfg.ChannelEntry{
+				Name:      e.Name,
+				Replaces:  e.Replaces,
+				Skips:     e.Skips,
+				SkipRange: e.SkipRange,
+			})
+		}
+		dcfg.Channels = append(dcfg.Channels, dc)
+	}
+	for _, b := range cfg.Bundles {
+		db := declcfg.Bundle{Schema: b.Schema, Name: b.Name, Package: b.Package, Image: b.Image}
+		for _, p := range b.Properties {
+			db.Properties = append(db.Properties, property.Property{Type: p.Type, Value: p.Value})
+			if p.Type != PropertyBundleObject {
+				continue
+			}
+			v := bundleObjectValue{}
+			if err := json.Unmarshal(p.Value, &v); err != nil {
+				return nil, fmt.Errorf("bundle %q: parse %s property: %v", b.Name, p.Type, err)
+			}
+			db.Objects = append(db.Objects, string(v.Data))
+		}
+		dcfg.Bundles = append(dcfg.Bundles, db)
+	}
+	return dcfg, nil
+}
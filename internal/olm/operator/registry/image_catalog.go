@@ -0,0 +1,242 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
+	"github.com/operator-framework/operator-sdk/internal/util/projutil"
+)
+
+// Container tools that can build and push registry images.
+const (
+	ContainerToolDocker = "docker"
+	ContainerToolPodman = "podman"
+)
+
+const (
+	// imageCatalogDir is the directory catalogs are served from in a registry image.
+	imageCatalogDir = "/configs"
+	// imageCatalogFileName is the name of each package's catalog file in imageCatalogDir.
+	imageCatalogFileName = "catalog.json"
+)
+
+// registryDockerfileTmpl is equivalent to the Dockerfile generated by
+// `opm generate dockerfile`, with the base image and catalog directory
+// formatted in.
+const registryDockerfileTmpl = `FROM %[1]s
+ENTRYPOINT ["/bin/opm"]
+CMD ["serve", "%[2]s"]
+ADD catalog %[2]s
+LABEL operators.operatorframework.io.index.configs.v1=%[2]s
+`
+
+// ImageCatalogCreator builds a registry image serving a file-based catalog
+// of Package and Bundles, pushes it, and creates a CatalogSource pulling it.
+type ImageCatalogCreator struct {
+	Package *apimanifests.PackageManifest
+	Bundles []*apimanifests.Bundle
+	// AdditionalPackages are served by the same catalog as Package.
+	AdditionalPackages []configmap.PackageManifests
+	// Image is the reference the registry image is tagged and pushed as.
+	// It must be pullable from the cluster.
+	Image string
+	// BaseImage is the image containing `opm` the registry image is built
+	// from. Defaults to configmap.DefaultFBCRegistryImage.
+	BaseImage string
+	// ContainerTool is the tool used to build and push Image, one of
+	// ContainerToolDocker (the default) or ContainerToolPodman.
+	ContainerTool string
+	// PullSecret is the name of a kubernetes.io/dockerconfigjson Secret in the
+	// install namespace. If set, its credentials are used to push Image and
+	// the CatalogSource pulls Image with it.
+	PullSecret string
+
+	cfg *operator.Configuration
+	// run runs a container tool command. Defaults to running cmd with output
+	// sent to the debug log.
+	run func(cmd *exec.Cmd) error
+	// pushed is set once BuildAndPush succeeds.
+	pushed bool
+}
+
+func NewImageCatalogCreator(cfg *operator.Configuration) *ImageCatalogCreator {
+	return &ImageCatalogCreator{
+		cfg: cfg,
+		run: projutil.RunCmd,
+	}
+}
+
+// OptionRules returns the rules constraining c's fields. Package, Bundles, and
+// AdditionalPackages are set by c's embedding struct.
+func (c ImageCatalogCreator) OptionRules() operator.OptionRules {
+	return operator.OptionRules{
+		Rules: []operator.OptionRule{
+			operator.Required(operator.StringOption("Image", "--registry-image", &c.Image)),
+			operator.Constraint(func() error {
+				switch c.ContainerTool {
+				case "", ContainerToolDocker, ContainerToolPodman:
+					return nil
+				}
+				return fmt.Errorf("unknown container tool %q, must be one of: %s, %s",
+					c.ContainerTool, ContainerToolDocker, ContainerToolPodman)
+			}, operator.StringOption("ContainerTool", "--container-tool", &c.ContainerTool)),
+		},
+		Unconstrained: []string{"Package", "Bundles", "AdditionalPackages", "BaseImage", "PullSecret"},
+	}
+}
+
+// BuildAndPush builds and pushes c.Image. No cluster objects are created, so
+// a failure leaves nothing to clean up; the pull secret is only read.
+func (c *ImageCatalogCreator) BuildAndPush(ctx context.Context) error {
+	catalog, err := makeFBC(c.Package, c.Bundles, c.AdditionalPackages...)
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "operator-sdk-registry-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Warnf("Failed to remove registry image build directory %s: %v", dir, err)
+		}
+	}()
+	if err := c.writeBuildContext(dir, catalog); err != nil {
+		return fmt.Errorf("error writing registry image build context: %v", err)
+	}
+
+	var authArgs []string
+	if c.PullSecret != "" {
+		if authArgs, err = c.writeAuthConfig(ctx, dir); err != nil {
+			return err
+		}
+	}
+
+	tool := c.getContainerTool()
+	log.Infof("Building registry image %s", c.Image)
+	build := exec.CommandContext(ctx, tool, "build", "-f", filepath.Join(dir, "Dockerfile"), "-t", c.Image, dir)
+	if err := c.run(build); err != nil {
+		return fmt.Errorf("error building registry image %s: %v", c.Image, err)
+	}
+	log.Infof("Pushing registry image %s", c.Image)
+	push := exec.CommandContext(ctx, tool, c.getPushArgs(authArgs)...)
+	if err := c.run(push); err != nil {
+		return fmt.Errorf("error pushing registry image %s: %v", c.Image, err)
+	}
+	c.pushed = true
+	return nil
+}
+
+func (c ImageCatalogCreator) getContainerTool() string {
+	if c.ContainerTool == "" {
+		return ContainerToolDocker
+	}
+	return c.ContainerTool
+}
+
+// getPushArgs returns push arguments for c's container tool, which differ in
+// where credentials are passed: docker takes a global config directory and
+// podman an auth file.
+func (c ImageCatalogCreator) getPushArgs(authArgs []string) []string {
+	if c.getContainerTool() == ContainerToolDocker {
+		return append(authArgs, "push", c.Image)
+	}
+	return append(append([]string{"push"}, authArgs...), c.Image)
+}
+
+// writeBuildContext writes a registry image Dockerfile and catalog to dir.
+func (c ImageCatalogCreator) writeBuildContext(dir string, catalog []byte) error {
+	baseImage := c.BaseImage
+	if baseImage == "" {
+		baseImage = configmap.DefaultFBCRegistryImage
+	}
+	dockerfile := fmt.Sprintf(registryDockerfileTmpl, baseImage, imageCatalogDir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(dockerfile), 0644); err != nil {
+		return err
+	}
+	pkgDir := filepath.Join(dir, "catalog", c.Package.PackageName)
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(pkgDir, imageCatalogFileName), catalog, 0644)
+}
+
+// writeAuthConfig writes the credentials in c.PullSecret to dir and returns
+// the container tool arguments that use them.
+func (c ImageCatalogCreator) writeAuthConfig(ctx context.Context, dir string) ([]string, error) {
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: c.cfg.Namespace, Name: c.PullSecret}
+	if err := c.cfg.Client.Get(ctx, key, secret); err != nil {
+		return nil, fmt.Errorf("error getting pull secret %q: %v", c.PullSecret, err)
+	}
+	data, ok := secret.Data[corev1.DockerConfigJsonKey]
+	if !ok {
+		return nil, fmt.Errorf("pull secret %q has no %s key, it must be of type %s",
+			c.PullSecret, corev1.DockerConfigJsonKey, corev1.SecretTypeDockerConfigJson)
+	}
+	authDir := filepath.Join(dir, "auth")
+	if err := os.Mkdir(authDir, 0700); err != nil {
+		return nil, err
+	}
+	authFile := filepath.Join(authDir, "config.json")
+	if err := ioutil.WriteFile(authFile, data, 0600); err != nil {
+		return nil, err
+	}
+	if c.getContainerTool() == ContainerToolDocker {
+		return []string{"--config", authDir}, nil
+	}
+	return []string{"--authfile=" + authFile}, nil
+}
+
+// CreateCatalog creates a CatalogSource named name pulling c.Image, which
+// must have been pushed by BuildAndPush.
+func (c ImageCatalogCreator) CreateCatalog(ctx context.Context, name string) (*v1alpha1.CatalogSource, error) {
+	if !c.pushed {
+		return nil, fmt.Errorf("registry image %s has not been pushed", c.Image)
+	}
+	cs := newCatalogSource(name, c.cfg.Namespace,
+		withSDKPublisher(c.Package.PackageName),
+		withImage(c.Image, c.PullSecret))
+	if err := c.cfg.Client.Create(ctx, cs); err != nil {
+		return nil, fmt.Errorf("error creating catalog source: %w", err)
+	}
+	return cs, nil
+}
+
+// withImage returns a function that sets the CatalogSource argument to serve
+// image, pulled with pullSecret if set.
+func withImage(image, pullSecret string) func(*v1alpha1.CatalogSource) {
+	return func(cs *v1alpha1.CatalogSource) {
+		cs.Spec.SourceType = v1alpha1.SourceTypeGrpc
+		cs.Spec.Image = image
+		if pullSecret != "" {
+			cs.Spec.Secrets = []string{pullSecret}
+		}
+	}
+}
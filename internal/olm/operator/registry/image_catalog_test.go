@@ -0,0 +1,166 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+)
+
+var _ = Describe("ImageCatalogCreator", func() {
+	const image = "quay.io/example/memcached-operator-registry:v0.0.1"
+
+	var (
+		c      *ImageCatalogCreator
+		client crclient.Client
+		// cmds are the arguments of each command run.
+		cmds [][]string
+		// files are the build context's files and contents when the build command ran.
+		files map[string]string
+		// runErr is returned by commands whose first argument after the tool is runErrOn.
+		runErr   error
+		runErrOn string
+	)
+
+	BeforeEach(func() {
+		sch := runtime.NewScheme()
+		Expect(corev1.AddToScheme(sch)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+		client = fake.NewFakeClientWithScheme(sch)
+		cmds, files, runErr, runErrOn = nil, map[string]string{}, nil, ""
+
+		csv := &v1alpha1.ClusterServiceVersion{}
+		csv.SetName("memcached-operator.v0.0.1")
+		Expect(csv.Spec.Version.Set("0.0.1")).To(Succeed())
+		csvU := &unstructured.Unstructured{}
+		csvU.SetAPIVersion(v1alpha1.SchemeGroupVersion.String())
+		csvU.SetKind(v1alpha1.ClusterServiceVersionKind)
+		csvU.SetName(csv.GetName())
+
+		c = NewImageCatalogCreator(&operator.Configuration{Client: client, Namespace: "testns"})
+		c.Image = image
+		c.Package = &apimanifests.PackageManifest{
+			PackageName:        "memcached-operator",
+			DefaultChannelName: "alpha",
+			Channels:           []apimanifests.PackageChannel{{Name: "alpha", CurrentCSVName: csv.GetName()}},
+		}
+		c.Bundles = []*apimanifests.Bundle{{CSV: csv, Objects: []*unstructured.Unstructured{csvU}}}
+		c.run = func(cmd *exec.Cmd) error {
+			cmds = append(cmds, cmd.Args)
+			for i, arg := range cmd.Args {
+				if arg == "build" {
+					dir := cmd.Args[len(cmd.Args)-1]
+					Expect(filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+						if err != nil || info.IsDir() {
+							return err
+						}
+						b, err := ioutil.ReadFile(path)
+						rel, _ := filepath.Rel(dir, path)
+						files[rel] = string(b)
+						return err
+					})).To(Succeed())
+				}
+				if i > 0 && arg == runErrOn {
+					return runErr
+				}
+			}
+			return nil
+		}
+	})
+
+	listCatalogSources := func() []v1alpha1.CatalogSource {
+		list := v1alpha1.CatalogSourceList{}
+		Expect(client.List(context.TODO(), &list)).To(Succeed())
+		return list.Items
+	}
+
+	It("should build an image serving the catalog, push it, and create a CatalogSource pulling it", func() {
+		Expect(c.BuildAndPush(context.TODO())).To(Succeed())
+		Expect(cmds).To(HaveLen(2))
+		Expect(cmds[0][:5]).To(Equal([]string{ContainerToolDocker, "build", "-f", cmds[0][3], "-t"}))
+		Expect(cmds[1]).To(Equal([]string{ContainerToolDocker, "push", image}))
+
+		Expect(files).To(HaveKey("Dockerfile"))
+		Expect(files["Dockerfile"]).To(HavePrefix("FROM quay.io/operator-framework/opm:latest\n"))
+		Expect(files["Dockerfile"]).To(ContainSubstring("LABEL operators.operatorframework.io.index.configs.v1=/configs\n"))
+		catalog, err := makeFBC(c.Package, c.Bundles)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveKeyWithValue(filepath.Join("catalog", "memcached-operator", "catalog.json"), string(catalog)))
+
+		cs, err := c.CreateCatalog(context.TODO(), "memcached-operator-catalog")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs.Spec.Image).To(Equal(image))
+		Expect(cs.Spec.SourceType).To(Equal(v1alpha1.SourceTypeGrpc))
+		Expect(cs.Spec.Secrets).To(BeEmpty())
+		Expect(listCatalogSources()).To(HaveLen(1))
+	})
+
+	It("should push and pull with the pull secret's credentials", func() {
+		secret := &corev1.Secret{Type: corev1.SecretTypeDockerConfigJson}
+		secret.SetName("registry-creds")
+		secret.SetNamespace("testns")
+		secret.Data = map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)}
+		Expect(client.Create(context.TODO(), secret)).To(Succeed())
+		c.PullSecret = secret.GetName()
+		c.ContainerTool = ContainerToolPodman
+
+		Expect(c.BuildAndPush(context.TODO())).To(Succeed())
+		Expect(cmds).To(HaveLen(2))
+		Expect(cmds[1]).To(HaveLen(4))
+		Expect(cmds[1][:2]).To(Equal([]string{ContainerToolPodman, "push"}))
+		Expect(cmds[1][2]).To(HavePrefix("--authfile="))
+		Expect(cmds[1][3]).To(Equal(image))
+		Expect(files).To(HaveKeyWithValue(filepath.Join("auth", "config.json"), `{"auths":{}}`))
+
+		cs, err := c.CreateCatalog(context.TODO(), "memcached-operator-catalog")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs.Spec.Secrets).To(Equal([]string{secret.GetName()}))
+	})
+
+	It("should fail if the pull secret has no docker config", func() {
+		secret := &corev1.Secret{}
+		secret.SetName("registry-creds")
+		secret.SetNamespace("testns")
+		Expect(client.Create(context.TODO(), secret)).To(Succeed())
+		c.PullSecret = secret.GetName()
+		Expect(c.BuildAndPush(context.TODO())).To(MatchError(ContainSubstring(`pull secret "registry-creds" has no .dockerconfigjson key`)))
+		Expect(cmds).To(BeEmpty())
+	})
+
+	It("should not create cluster objects if the push fails", func() {
+		runErr, runErrOn = errors.New("unauthorized"), "push"
+		err := c.BuildAndPush(context.TODO())
+		Expect(err).To(MatchError(ContainSubstring("error pushing registry image " + image + ": unauthorized")))
+		_, err = c.CreateCatalog(context.TODO(), "memcached-operator-catalog")
+		Expect(err).To(MatchError(ContainSubstring("has not been pushed")))
+		Expect(listCatalogSources()).To(BeEmpty())
+	})
+})
@@ -27,12 +27,15 @@ import (
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
 	log "github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
 	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
 )
 
@@ -45,14 +48,49 @@ const (
 	// ReplacesBundleAddMode - bundle add mode for replaces
 	ReplacesBundleAddMode BundleAddModeType = "replaces"
 )
+
+// CatalogModeType - type of CatalogMode in RegistryPod struct
+type CatalogModeType = string
+
+const (
+	// SQLiteCatalogMode - catalog mode for a sqlite database served by `opm registry serve`
+	SQLiteCatalogMode CatalogModeType = "sqlite"
+	// FBCCatalogMode - catalog mode for a file-based catalog (declarative config) served by `opm serve`
+	FBCCatalogMode CatalogModeType = "fbc"
+)
+
 const (
 	// defaultGRPCPort is the default grpc container port that the registry pod exposes
 	defaultGRPCPort          = 50051
 	defaultIndexImage        = "quay.io/operator-framework/upstream-opm-builder:latest"
 	defaultContainerName     = "registry-grpc"
 	defaultContainerPortName = "grpc"
+	// packageConfigEnvVar holds the bundle's package and channel blobs in fbc mode
+	packageConfigEnvVar = "PACKAGE_CONFIG"
+	// restrictedWorkDir contains writable copies of the index's catalog when the pod
+	// runs as a non-root user, since index images' catalogs are owned by root
+	restrictedWorkDir = "/tmp"
+	// pullSecretVolumeName is the name of the volume containing the pull secret's docker config
+	pullSecretVolumeName = "pull-secret"
+	// pullSecretMountPath is the docker config directory opm reads credentials from
+	pullSecretMountPath = "/etc/operator-sdk/docker"
+	// caSecretVolumeName is the name of the volume containing the CA secret's certificates
+	caSecretVolumeName = "ca-secret"
+	// caSecretMountPath is the certificate directory opm trusts in addition to the system's
+	caSecretMountPath = "/etc/operator-sdk/certs"
+	// indexContentVolumeName is the name of the volume the index's catalog is copied to
+	// when the registry container does not run the index image
+	indexContentVolumeName = "index-content"
+	// indexContentDir is where the index's catalog is copied to and served from
+	// when the registry container does not run the index image
+	indexContentDir = "/var/lib/operator-sdk/index"
+	// indexContainerName is the name of the init container copying the index's catalog
+	indexContainerName = "copy-index"
 )
 
+// CASecretKey is the key of a CA secret's PEM-encoded certificates
+const CASecretKey = "cert.pem"
+
 var (
 	// Internal error
 	errPodNotInit = errors.New("internal error: RegistryPod not initialized")
@@ -67,26 +105,100 @@ type RegistryPod struct {
 	// BundleImage specifies the container image that opm uses to generate and incrementally update the database
 	BundleImage string
 
+	// InjectedBundleImages are bundles added to the index in order before BundleImage, ex.
+	// those previously added by the catalog being upgraded, or bundles of other packages
+	// BundleImage depends on. In fbc mode they are rendered alongside BundleImage
+	InjectedBundleImages []string
+
 	// Index image contains a database of pointers to operator manifest content that is queriable via an API.
 	// new version of an operator bundle when published can be added to an index image
 	IndexImage string
 
+	// RegistryImage is the image the registry container runs opm from, IndexImage by default.
+	// If it differs from IndexImage, an init container copies IndexImage's catalog into a
+	// volume the registry container serves it from, ex. to serve an index with a newer opm
+	RegistryImage string
+
 	// DBPath refers to the registry DB;
 	// if an index image is provided, the existing registry DB is located at /database/index.db
 	DBPath string
 
+	// CatalogMode is the format of the catalog served by the pod, sqlite by default
+	CatalogMode CatalogModeType
+
+	// ConfigsDir refers to the file-based catalog directory in fbc mode;
+	// if an index image is provided, existing declarative config is located in /configs
+	ConfigsDir string
+
+	// PackageName is the bundle's package name, and the name of the directory in
+	// ConfigsDir to which the bundle's declarative config is written in fbc mode
+	PackageName string
+
+	// PackageConfig is declarative config JSON of the bundle's olm.package and olm.channel
+	// blobs, written alongside the blob rendered from BundleImage in fbc mode
+	PackageConfig string
+
 	// GRPCPort is the container grpc port
 	GRPCPort int32
 
+	// Resources are the container's resource requirements
+	Resources corev1.ResourceRequirements
+
+	// RestrictedSecurityContext sets security contexts satisfying the restricted Pod Security
+	// Standard on the pod, which then serves a writable copy of the index's catalog
+	RestrictedSecurityContext bool
+
+	// PullSecretName is the name of a kubernetes.io/dockerconfigjson Secret used to pull
+	// the index image, and mounted as opm's docker config to pull the bundle image
+	PullSecretName string
+
+	// CASecretName is the name of a Secret containing PEM-encoded CA certificates in
+	// CASecretKey, which opm trusts when pulling the bundle image
+	CASecretName string
+
+	// SkipTLSVerify and UseHTTP make opm skip TLS certificate verification, or use plain
+	// HTTP, when pulling bundle images from insecure registries
+	SkipTLSVerify bool
+	UseHTTP       bool
+
+	// ServiceAccountName is the name of the ServiceAccount the pod runs as, the
+	// namespace's default ServiceAccount if empty
+	ServiceAccountName string
+
+	// NodeSelector and Tolerations constrain the nodes the pod is scheduled on
+	NodeSelector map[string]string
+	Tolerations  []corev1.Toleration
+
 	// pod represents a kubernetes *corev1.pod that will be created on a cluster using an index image
 	pod *corev1.Pod
 
 	cfg *operator.Configuration
 }
 
-// NewRegistryPod initializes the RegistryPod struct and sets defaults for empty fields
-func NewRegistryPod(cfg *operator.Configuration, dbPath, bundleImage string) (*RegistryPod, error) {
-	rp := &RegistryPod{}
+// NewRegistryPod initializes a RegistryPod from the exported fields of opts other
+// than GRPCPort, and sets defaults for empty fields
+func NewRegistryPod(cfg *operator.Configuration, opts RegistryPod) (*RegistryPod, error) {
+	rp := &RegistryPod{
+		IndexImage:                opts.IndexImage,
+		RegistryImage:             opts.RegistryImage,
+		CatalogMode:               opts.CatalogMode,
+		DBPath:                    opts.DBPath,
+		ConfigsDir:                opts.ConfigsDir,
+		BundleImage:               opts.BundleImage,
+		InjectedBundleImages:      opts.InjectedBundleImages,
+		BundleAddMode:             opts.BundleAddMode,
+		PackageName:               opts.PackageName,
+		PackageConfig:             opts.PackageConfig,
+		Resources:                 opts.Resources,
+		RestrictedSecurityContext: opts.RestrictedSecurityContext,
+		PullSecretName:            opts.PullSecretName,
+		CASecretName:              opts.CASecretName,
+		SkipTLSVerify:             opts.SkipTLSVerify,
+		UseHTTP:                   opts.UseHTTP,
+		ServiceAccountName:        opts.ServiceAccountName,
+		NodeSelector:              opts.NodeSelector,
+		Tolerations:               opts.Tolerations,
+	}
 
 	if rp.GRPCPort == 0 {
 		rp.GRPCPort = defaultGRPCPort
@@ -96,7 +208,15 @@ func NewRegistryPod(cfg *operator.Configuration, dbPath, bundleImage string) (*R
 		rp.IndexImage = defaultIndexImage
 	}
 
-	if len(strings.TrimSpace(rp.BundleAddMode)) < 1 {
+	if len(strings.TrimSpace(rp.RegistryImage)) < 1 {
+		rp.RegistryImage = rp.IndexImage
+	}
+
+	if len(strings.TrimSpace(rp.CatalogMode)) < 1 {
+		rp.CatalogMode = SQLiteCatalogMode
+	}
+
+	if rp.CatalogMode == SQLiteCatalogMode && len(strings.TrimSpace(rp.BundleAddMode)) < 1 {
 		if rp.IndexImage == defaultIndexImage {
 			rp.BundleAddMode = SemverBundleAddMode
 		} else {
@@ -105,8 +225,6 @@ func NewRegistryPod(cfg *operator.Configuration, dbPath, bundleImage string) (*R
 	}
 
 	rp.cfg = cfg
-	rp.DBPath = dbPath
-	rp.BundleImage = bundleImage
 
 	// validate the RegistryPod struct and ensure required fields are set
 	if err := rp.validate(); err != nil {
@@ -180,6 +298,16 @@ func (rp *RegistryPod) validate() error {
 	if len(strings.TrimSpace(rp.BundleImage)) < 1 {
 		return errors.New("bundle image cannot be empty")
 	}
+
+	switch rp.CatalogMode {
+	case SQLiteCatalogMode:
+	case FBCCatalogMode:
+		return rp.validateFBC()
+	default:
+		return fmt.Errorf("invalid catalog mode %q: must be one of [%q, %q]",
+			rp.CatalogMode, SQLiteCatalogMode, FBCCatalogMode)
+	}
+
 	if len(strings.TrimSpace(rp.DBPath)) < 1 {
 		return errors.New("registry database path cannot be empty")
 	}
@@ -196,10 +324,69 @@ func (rp *RegistryPod) validate() error {
 	return nil
 }
 
+// validateFBC will ensure that RegistryPod fields required in fbc mode are set
+func (rp *RegistryPod) validateFBC() error {
+	if len(strings.TrimSpace(rp.ConfigsDir)) < 1 {
+		return errors.New("catalog configs directory cannot be empty")
+	}
+	if len(strings.TrimSpace(rp.PackageName)) < 1 {
+		return errors.New("package name cannot be empty")
+	}
+	if len(strings.TrimSpace(rp.PackageConfig)) < 1 {
+		return errors.New("package config cannot be empty")
+	}
+	return nil
+}
+
 func GetRegistryPodHost(ipStr string) string {
 	return fmt.Sprintf("%s:%d", ipStr, defaultGRPCPort)
 }
 
+// GetRegistryPodCondition describes the phase of bundleImage's registry pod in namespace,
+// and why each of its containers is waiting if any are, ex. while pulling the index image
+func GetRegistryPodCondition(ctx context.Context, cl client.Client, namespace, bundleImage string) string {
+	pod := &corev1.Pod{}
+	key := types.NamespacedName{Namespace: namespace, Name: getPodName(bundleImage)}
+	if err := cl.Get(ctx, key, pod); err != nil {
+		return fmt.Sprintf("error getting registry pod %q: %v", key, err)
+	}
+	desc := fmt.Sprintf("registry pod %q phase %q", key, pod.Status.Phase)
+	for _, cs := range pod.Status.ContainerStatuses {
+		if w := cs.State.Waiting; w != nil {
+			desc += fmt.Sprintf("; container %q waiting: %s: %s", cs.Name, w.Reason, w.Message)
+		}
+	}
+	return desc
+}
+
+// GetRegistryPodPackageConfig returns the package config of bundleImage's fbc mode registry pod in namespace
+func GetRegistryPodPackageConfig(ctx context.Context, cl client.Client, namespace, bundleImage string) (string, error) {
+	pod := &corev1.Pod{}
+	key := types.NamespacedName{Namespace: namespace, Name: getPodName(bundleImage)}
+	if err := cl.Get(ctx, key, pod); err != nil {
+		return "", fmt.Errorf("error getting registry pod %q: %v", key, err)
+	}
+	for _, c := range pod.Spec.Containers {
+		for _, env := range c.Env {
+			if env.Name == packageConfigEnvVar {
+				return env.Value, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("registry pod %q has no package config", key)
+}
+
+// DeleteRegistryPod deletes bundleImage's registry pod in namespace if it exists
+func DeleteRegistryPod(ctx context.Context, cl client.Client, namespace, bundleImage string) error {
+	pod := &corev1.Pod{}
+	pod.SetName(getPodName(bundleImage))
+	pod.SetNamespace(namespace)
+	if err := cl.Delete(ctx, pod); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("error deleting registry pod %q: %v", pod.GetName(), err)
+	}
+	return nil
+}
+
 // getPodName will return a string constructed from the bundle Image name
 func getPodName(bundleImage string) string {
 	// todo(rashmigottipati): need to come up with human-readable references
@@ -223,10 +410,13 @@ func (rp *RegistryPod) podForBundleRegistry() (*corev1.Pod, error) {
 			Namespace: rp.cfg.Namespace,
 		},
 		Spec: corev1.PodSpec{
+			ServiceAccountName: rp.ServiceAccountName,
+			NodeSelector:       rp.NodeSelector,
+			Tolerations:        rp.Tolerations,
 			Containers: []corev1.Container{
 				{
 					Name:  defaultContainerName,
-					Image: rp.IndexImage,
+					Image: rp.RegistryImage,
 					Command: []string{
 						"/bin/sh",
 						"-c",
@@ -235,27 +425,184 @@ func (rp *RegistryPod) podForBundleRegistry() (*corev1.Pod, error) {
 					Ports: []corev1.ContainerPort{
 						{Name: defaultContainerPortName, ContainerPort: rp.GRPCPort},
 					},
+					Resources: rp.Resources,
 				},
 			},
 		},
 	}
+	if rp.CatalogMode == FBCCatalogMode {
+		rp.pod.Spec.Containers[0].Env = []corev1.EnvVar{
+			{Name: packageConfigEnvVar, Value: rp.PackageConfig},
+		}
+	}
+	if rp.PackageName != "" {
+		// Labeled like other SDK registry objects so uninstall can find it by label.
+		rp.pod.SetLabels(configmap.MakeRegistryLabels(rp.PackageName))
+	}
+	if rp.hasRegistryImage() {
+		rp.addIndexContainer()
+	}
+	if rp.PullSecretName != "" {
+		rp.addPullSecret()
+	}
+	if rp.CASecretName != "" {
+		rp.addCASecret()
+	}
+	if rp.RestrictedSecurityContext {
+		k8sutil.SetRestrictedSecurityContext(&rp.pod.ObjectMeta, &rp.pod.Spec)
+	}
 
 	return rp.pod, nil
 }
 
+// hasRegistryImage returns true if the registry container does not run the index image
+func (rp *RegistryPod) hasRegistryImage() bool {
+	return rp.RegistryImage != rp.IndexImage
+}
+
+// getIndexContentPath returns where the index's catalog at p is copied to
+func getIndexContentPath(p string) string {
+	return path.Join(indexContentDir, p)
+}
+
+// addIndexContainer adds an init container copying the index's catalog into a volume,
+// which the registry container adds the bundle to and serves
+func (rp *RegistryPod) addIndexContainer() {
+	spec := &rp.pod.Spec
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name:         indexContentVolumeName,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	})
+	mount := corev1.VolumeMount{Name: indexContentVolumeName, MountPath: indexContentDir}
+
+	var copyCmd string
+	if rp.CatalogMode == FBCCatalogMode {
+		copyCmd = fmt.Sprintf("/bin/mkdir -p %[2]s && if [ -d %[1]s ]; then /bin/cp -r %[1]s/. %[2]s; fi",
+			rp.ConfigsDir, getIndexContentPath(rp.ConfigsDir))
+	} else {
+		dbPath := getIndexContentPath(rp.DBPath)
+		copyCmd = fmt.Sprintf("/bin/mkdir -p %[3]s && if [ -f %[1]s ]; then /bin/cp %[1]s %[2]s; fi",
+			rp.DBPath, dbPath, path.Dir(dbPath))
+	}
+	spec.InitContainers = append(spec.InitContainers, corev1.Container{
+		Name:         indexContainerName,
+		Image:        rp.IndexImage,
+		Command:      []string{"/bin/sh", "-c", copyCmd},
+		Resources:    rp.Resources,
+		VolumeMounts: []corev1.VolumeMount{mount},
+	})
+	container := &spec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, mount)
+}
+
+// getEntrypointCheck returns a command failing the registry container with an explanation
+// if the registry image's opm does not support each of subcommands. Images other than
+// the index image are not assumed to contain a compatible opm
+func (rp *RegistryPod) getEntrypointCheck(subcommands ...string) string {
+	if !rp.hasRegistryImage() {
+		return ""
+	}
+	return fmt.Sprintf("for cmd in %s; do /bin/opm $cmd --help > /dev/null 2>&1 || "+
+		"{ echo \"registry image %s does not support /bin/opm $cmd\" >&2; exit 1; }; done &&",
+		"\""+strings.Join(subcommands, "\" \"")+"\"", rp.RegistryImage)
+}
+
+// addPullSecret sets rp.PullSecretName as the pod's image pull secret, and mounts its
+// docker config in the registry container so opm can pull the bundle image with it
+func (rp *RegistryPod) addPullSecret() {
+	spec := &rp.pod.Spec
+	spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: rp.PullSecretName}}
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: pullSecretVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: rp.PullSecretName,
+				Items:      []corev1.KeyToPath{{Key: corev1.DockerConfigJsonKey, Path: "config.json"}},
+			},
+		},
+	})
+	container := &spec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      pullSecretVolumeName,
+		MountPath: pullSecretMountPath,
+		ReadOnly:  true,
+	})
+	container.Env = append(container.Env, corev1.EnvVar{Name: "DOCKER_CONFIG", Value: pullSecretMountPath})
+}
+
+// addCASecret mounts rp.CASecretName's certificates in the registry container's certificate
+// directory, which opm trusts in addition to the system's certificate bundle
+func (rp *RegistryPod) addCASecret() {
+	spec := &rp.pod.Spec
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: caSecretVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: rp.CASecretName,
+				Items:      []corev1.KeyToPath{{Key: CASecretKey, Path: CASecretKey}},
+			},
+		},
+	})
+	container := &spec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      caSecretVolumeName,
+		MountPath: caSecretMountPath,
+		ReadOnly:  true,
+	})
+	// Go, and therefore opm, reads certificates from SSL_CERT_DIR in place of the
+	// default directories, but still loads the system's certificate bundle file
+	container.Env = append(container.Env, corev1.EnvVar{Name: "SSL_CERT_DIR", Value: caSecretMountPath})
+}
+
+// getBundleImages returns the bundles to add to the index, in order
+func (rp *RegistryPod) getBundleImages() []string {
+	return append(append([]string{}, rp.InjectedBundleImages...), rp.BundleImage)
+}
+
+// getPullFlags returns opm flags configuring how bundle images are pulled. opm
+// pulls and unpacks bundle images with its own registry client, so the pod needs
+// no container tool or runtime socket from the node
+func (rp *RegistryPod) getPullFlags() string {
+	var flags string
+	if rp.SkipTLSVerify {
+		flags += " --skip-tls-verify"
+	}
+	if rp.UseHTTP {
+		flags += " --use-http"
+	}
+	return flags
+}
+
 // getContainerCmd uses templating to construct the container command
 // and throws error if unable to parse and execute the container command
 func (rp *RegistryPod) getContainerCmd() (string, error) {
-	const containerCommand = "/bin/mkdir -p {{ .DBPath | dirname }} &&" +
-		"/bin/opm registry add -d {{ .DBPath }} -b {{.BundleImage}} --mode={{.BundleAddMode}} &&" +
+	if rp.CatalogMode == FBCCatalogMode {
+		return rp.getFBCContainerCmd()
+	}
+
+	const containerCommand = "{{ .EntrypointCheck }}/bin/mkdir -p {{ .DBPath | dirname }} &&" +
+		"{{ if .SourceDBPath }}if [ -f {{ .SourceDBPath }} ]; then /bin/cp {{ .SourceDBPath }} {{ .DBPath }}; fi &&{{ end }}" +
+		"{{ range .BundleImages }}/bin/opm registry add -d {{ $.DBPath }} -b {{ . }} --mode={{ $.BundleAddMode }}" +
+		"{{ if $.OverwriteLatest }} --overwrite-latest{{ end }}{{ $.PullFlags }} &&{{ end }}" +
 		"/bin/opm registry serve -d {{ .DBPath }} -p {{.GRPCPort}}"
 	type bundleCmd struct {
-		BundleImage, DBPath, BundleAddMode string
-		GRPCPort                           int32
+		BundleImages                                                    []string
+		DBPath, SourceDBPath, BundleAddMode, PullFlags, EntrypointCheck string
+		GRPCPort                                                        int32
+		OverwriteLatest                                                 bool
 	}
 
-	var command = bundleCmd{rp.BundleImage, rp.DBPath,
-		rp.BundleAddMode, rp.GRPCPort}
+	// An existing index may already contain the bundle as a channel head, ex. when
+	// testing a rebuilt bundle, in which case the existing entry is replaced.
+	var command = bundleCmd{rp.getBundleImages(), rp.DBPath, "", rp.BundleAddMode, rp.getPullFlags(),
+		rp.getEntrypointCheck("registry add", "registry serve"), rp.GRPCPort, rp.IndexImage != defaultIndexImage}
+	switch {
+	case rp.hasRegistryImage():
+		// The init container's writable copy is used.
+		command.DBPath = getIndexContentPath(rp.DBPath)
+	case rp.RestrictedSecurityContext:
+		command.DBPath, command.SourceDBPath = path.Join(restrictedWorkDir, rp.DBPath), rp.DBPath
+	}
 
 	out := &bytes.Buffer{}
 
@@ -276,3 +623,41 @@ func (rp *RegistryPod) getContainerCmd() (string, error) {
 
 	return out.String(), nil
 }
+
+// getFBCContainerCmd constructs the container command for fbc mode, which renders
+// the bundle into a new package directory in ConfigsDir and serves ConfigsDir.
+// An existing package of the same name can't be merged with, so is an error.
+func (rp *RegistryPod) getFBCContainerCmd() (string, error) {
+	const containerCommand = "{{ .EntrypointCheck }}{{ if .SourceConfigsDir }}/bin/mkdir -p {{ .ConfigsDir }} &&" +
+		"if [ -d {{ .SourceConfigsDir }} ]; then /bin/cp -r {{ .SourceConfigsDir }}/. {{ .ConfigsDir }}; fi &&{{ end }}" +
+		"if [ -e {{ .PackageDir }} ]; then " +
+		"echo \"package directory {{ .PackageDir }} already exists in the index\" >&2; exit 1; fi &&" +
+		"/bin/mkdir -p {{ .PackageDir }} &&" +
+		"/bin/opm render {{ range .BundleImages }}{{ . }} {{ end }}-o json{{ .PullFlags }} > {{ .PackageDir }}/bundle.json &&" +
+		"printf '%s\\n' \"${{ .PackageConfigEnvVar }}\" > {{ .PackageDir }}/package.json &&" +
+		"/bin/opm serve {{ .ConfigsDir }} -p {{ .GRPCPort }}"
+	type bundleCmd struct {
+		BundleImages                                                                              []string
+		ConfigsDir, SourceConfigsDir, PackageDir, PackageConfigEnvVar, PullFlags, EntrypointCheck string
+		GRPCPort                                                                                  int32
+	}
+
+	var command = bundleCmd{rp.getBundleImages(), rp.ConfigsDir, "", "", packageConfigEnvVar, rp.getPullFlags(),
+		rp.getEntrypointCheck("render", "serve"), rp.GRPCPort}
+	switch {
+	case rp.hasRegistryImage():
+		// The init container's writable copy is used.
+		command.ConfigsDir = getIndexContentPath(rp.ConfigsDir)
+	case rp.RestrictedSecurityContext:
+		command.ConfigsDir, command.SourceConfigsDir = path.Join(restrictedWorkDir, rp.ConfigsDir), rp.ConfigsDir
+	}
+	command.PackageDir = path.Join(command.ConfigsDir, rp.PackageName)
+
+	out := &bytes.Buffer{}
+	tmp := template.Must(template.New("containerCommand").Parse(containerCommand))
+	if err := tmp.Execute(out, command); err != nil {
+		return "", fmt.Errorf("parse container command: %w", err)
+	}
+
+	return out.String(), nil
+}
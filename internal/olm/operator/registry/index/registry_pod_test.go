@@ -17,6 +17,7 @@ package index
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -24,6 +25,10 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -53,13 +58,21 @@ var _ = Describe("RegistryPod", func() {
 			var rp *RegistryPod
 			var cfg *operator.Configuration
 			var err error
+			resources := corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+			}
 
 			BeforeEach(func() {
 				cfg = &operator.Configuration{
 					Client:    newFakeClient(),
 					Namespace: "test-default",
 				}
-				rp, err = NewRegistryPod(cfg, "/database/index.db", "quay.io/example/example-operator-bundle:0.2.0")
+				rp, err = NewRegistryPod(cfg, RegistryPod{
+					DBPath:      "/database/index.db",
+					BundleImage: "quay.io/example/example-operator-bundle:0.2.0",
+					Resources:   resources,
+				})
 				Expect(err).To(BeNil())
 			})
 
@@ -81,6 +94,10 @@ var _ = Describe("RegistryPod", func() {
 				}
 			})
 
+			It("should set the container's resource requirements", func() {
+				Expect(rp.pod.Spec.Containers[0].Resources).To(Equal(resources))
+			})
+
 			It("should return a valid container command", func() {
 				output, err := rp.getContainerCmd()
 
@@ -113,6 +130,506 @@ var _ = Describe("RegistryPod", func() {
 			})
 		})
 
+		Context("with an existing index image", func() {
+			const indexImage = "quay.io/example/example-operator-index:latest"
+			var rp *RegistryPod
+
+			BeforeEach(func() {
+				cfg := &operator.Configuration{
+					Client:    newFakeClient(),
+					Namespace: "test-default",
+				}
+				var err error
+				rp, err = NewRegistryPod(cfg, RegistryPod{
+					IndexImage:  indexImage,
+					DBPath:      "/database/index.db",
+					BundleImage: "quay.io/example/example-operator-bundle:0.2.0",
+				})
+				Expect(err).To(BeNil())
+			})
+
+			It("should run the index image", func() {
+				Expect(rp.pod.Spec.Containers[0].Image).To(Equal(indexImage))
+			})
+
+			It("should add the bundle by replaces, overwriting an existing channel head", func() {
+				Expect(rp.BundleAddMode).To(Equal(ReplacesBundleAddMode))
+				output, err := rp.getContainerCmd()
+				Expect(err).To(BeNil())
+				Expect(output).To(Equal("/bin/mkdir -p /database &&" +
+					"/bin/opm registry add -d /database/index.db -b quay.io/example/example-operator-bundle:0.2.0 " +
+					"--mode=replaces --overwrite-latest &&" +
+					"/bin/opm registry serve -d /database/index.db -p 50051"))
+			})
+
+			It("should use a bundle add mode that is set", func() {
+				cfg := &operator.Configuration{Client: newFakeClient(), Namespace: "test-default"}
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					IndexImage:    indexImage,
+					DBPath:        "/database/index.db",
+					BundleImage:   "quay.io/example/example-operator-bundle:0.2.0",
+					BundleAddMode: SemverBundleAddMode,
+				})
+				Expect(err).To(BeNil())
+				Expect(rp.BundleAddMode).To(Equal(SemverBundleAddMode))
+			})
+		})
+
+		Context("with fbc catalog mode", func() {
+			const packageConfig = `{"schema":"olm.package","name":"example-operator","defaultChannel":"alpha"}`
+			var cfg *operator.Configuration
+			var rp *RegistryPod
+
+			BeforeEach(func() {
+				cfg = &operator.Configuration{
+					Client:    newFakeClient(),
+					Namespace: "test-default",
+				}
+				var err error
+				rp, err = NewRegistryPod(cfg, RegistryPod{
+					CatalogMode:   FBCCatalogMode,
+					ConfigsDir:    "/configs",
+					BundleImage:   "quay.io/example/example-operator-bundle:0.2.0",
+					PackageName:   "example-operator",
+					PackageConfig: packageConfig,
+				})
+				Expect(err).To(BeNil())
+			})
+
+			It("should render the bundle and serve the configs directory", func() {
+				output, err := rp.getContainerCmd()
+				Expect(err).To(BeNil())
+				Expect(output).To(Equal("if [ -e /configs/example-operator ]; then " +
+					"echo \"package directory /configs/example-operator already exists in the index\" >&2; exit 1; fi &&" +
+					"/bin/mkdir -p /configs/example-operator &&" +
+					"/bin/opm render quay.io/example/example-operator-bundle:0.2.0 -o json > /configs/example-operator/bundle.json &&" +
+					"printf '%s\\n' \"$PACKAGE_CONFIG\" > /configs/example-operator/package.json &&" +
+					"/bin/opm serve /configs -p 50051"))
+			})
+
+			It("should set the package config in the container environment", func() {
+				Expect(rp.pod.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+					{Name: "PACKAGE_CONFIG", Value: packageConfig},
+				}))
+			})
+
+			It("should expose the same grpc port as sqlite mode", func() {
+				Expect(rp.pod.Spec.Containers[0].Ports).To(Equal([]corev1.ContainerPort{
+					{Name: "grpc", ContainerPort: 50051},
+				}))
+			})
+
+			It("should label the pod with its package for cleanup", func() {
+				Expect(rp.pod.GetLabels()).To(Equal(map[string]string{
+					"owner":        "operator-sdk",
+					"package-name": "example-operator",
+				}))
+			})
+
+			It("should not require a database path or bundle add mode", func() {
+				Expect(rp.DBPath).To(BeEmpty())
+				Expect(rp.BundleAddMode).To(BeEmpty())
+				Expect(rp.validate()).To(Succeed())
+			})
+
+			It("should not create a registry pod when the package config is empty", func() {
+				_, err := NewRegistryPod(cfg, RegistryPod{
+					CatalogMode: FBCCatalogMode,
+					ConfigsDir:  "/configs",
+					BundleImage: "quay.io/example/example-operator-bundle:0.2.0",
+					PackageName: "example-operator",
+				})
+				Expect(err).To(MatchError(ContainSubstring("package config cannot be empty")))
+			})
+		})
+
+		Context("with injected bundles", func() {
+			const (
+				prevBundle = "quay.io/example/example-operator-bundle:0.1.0"
+				bundle     = "quay.io/example/example-operator-bundle:0.2.0"
+			)
+			var cfg *operator.Configuration
+
+			BeforeEach(func() {
+				cfg = &operator.Configuration{Client: newFakeClient(), Namespace: "test-default"}
+			})
+
+			It("should add each injected bundle before the bundle", func() {
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					DBPath:               "/database/index.db",
+					BundleImage:          bundle,
+					InjectedBundleImages: []string{prevBundle},
+				})
+				Expect(err).To(BeNil())
+				output, err := rp.getContainerCmd()
+				Expect(err).To(BeNil())
+				Expect(output).To(Equal("/bin/mkdir -p /database &&" +
+					"/bin/opm registry add -d /database/index.db -b " + prevBundle + " --mode=semver &&" +
+					"/bin/opm registry add -d /database/index.db -b " + bundle + " --mode=semver &&" +
+					"/bin/opm registry serve -d /database/index.db -p 50051"))
+			})
+
+			It("should render each injected bundle with the bundle", func() {
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					CatalogMode:          FBCCatalogMode,
+					ConfigsDir:           "/configs",
+					BundleImage:          bundle,
+					InjectedBundleImages: []string{prevBundle},
+					PackageName:          "example-operator",
+					PackageConfig:        `{"schema":"olm.package","name":"example-operator","defaultChannel":"alpha"}`,
+				})
+				Expect(err).To(BeNil())
+				output, err := rp.getContainerCmd()
+				Expect(err).To(BeNil())
+				Expect(output).To(ContainSubstring(
+					"/bin/opm render " + prevBundle + " " + bundle + " -o json > /configs/example-operator/bundle.json &&"))
+			})
+		})
+
+		Context("with a restricted security context", func() {
+			var cfg *operator.Configuration
+
+			BeforeEach(func() {
+				cfg = &operator.Configuration{
+					Client:    newFakeClient(),
+					Namespace: "test-default",
+				}
+			})
+
+			It("should not set security contexts by default", func() {
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					DBPath:      "/database/index.db",
+					BundleImage: "quay.io/example/example-operator-bundle:0.2.0",
+				})
+				Expect(err).To(BeNil())
+				Expect(rp.pod.Spec.SecurityContext).To(BeNil())
+				Expect(rp.pod.Spec.Containers[0].SecurityContext).To(BeNil())
+				Expect(rp.pod.GetAnnotations()).NotTo(HaveKey(corev1.SeccompPodAnnotationKey))
+			})
+
+			It("should set restricted security contexts", func() {
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					DBPath:                    "/database/index.db",
+					BundleImage:               "quay.io/example/example-operator-bundle:0.2.0",
+					RestrictedSecurityContext: true,
+				})
+				Expect(err).To(BeNil())
+				Expect(rp.pod.GetAnnotations()).To(HaveKeyWithValue(
+					corev1.SeccompPodAnnotationKey, corev1.SeccompProfileRuntimeDefault))
+				Expect(*rp.pod.Spec.SecurityContext.RunAsNonRoot).To(BeTrue())
+				sc := rp.pod.Spec.Containers[0].SecurityContext
+				Expect(*sc.AllowPrivilegeEscalation).To(BeFalse())
+				Expect(sc.Capabilities.Drop).To(Equal([]corev1.Capability{"ALL"}))
+			})
+
+			It("should add the bundle to a writable copy of the database", func() {
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					DBPath:                    "/database/index.db",
+					BundleImage:               "quay.io/example/example-operator-bundle:0.2.0",
+					RestrictedSecurityContext: true,
+				})
+				Expect(err).To(BeNil())
+				output, err := rp.getContainerCmd()
+				Expect(err).To(BeNil())
+				Expect(output).To(Equal("/bin/mkdir -p /tmp/database &&" +
+					"if [ -f /database/index.db ]; then /bin/cp /database/index.db /tmp/database/index.db; fi &&" +
+					"/bin/opm registry add -d /tmp/database/index.db -b quay.io/example/example-operator-bundle:0.2.0 --mode=semver &&" +
+					"/bin/opm registry serve -d /tmp/database/index.db -p 50051"))
+			})
+
+			It("should render the bundle into a writable copy of the configs directory", func() {
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					CatalogMode:               FBCCatalogMode,
+					ConfigsDir:                "/configs",
+					BundleImage:               "quay.io/example/example-operator-bundle:0.2.0",
+					PackageName:               "example-operator",
+					PackageConfig:             "{}",
+					RestrictedSecurityContext: true,
+				})
+				Expect(err).To(BeNil())
+				output, err := rp.getContainerCmd()
+				Expect(err).To(BeNil())
+				Expect(output).To(HavePrefix("/bin/mkdir -p /tmp/configs &&" +
+					"if [ -d /configs ]; then /bin/cp -r /configs/. /tmp/configs; fi &&" +
+					"if [ -e /tmp/configs/example-operator ]; then "))
+				Expect(output).To(HaveSuffix("/bin/opm serve /tmp/configs -p 50051"))
+			})
+		})
+
+		Context("with a service account", func() {
+			It("should run as the service account", func() {
+				cfg := &operator.Configuration{
+					Client:    newFakeClient(),
+					Namespace: "test-default",
+				}
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					DBPath:             "/database/index.db",
+					BundleImage:        "quay.io/example/example-operator-bundle:0.2.0",
+					ServiceAccountName: "registry",
+				})
+				Expect(err).To(BeNil())
+				Expect(rp.pod.Spec.ServiceAccountName).To(Equal("registry"))
+			})
+		})
+
+		Context("with a node selector and tolerations", func() {
+			It("should set them in the pod spec", func() {
+				cfg := &operator.Configuration{
+					Client:    newFakeClient(),
+					Namespace: "test-default",
+				}
+				nodeSelector := map[string]string{"node-role.kubernetes.io/infra": ""}
+				tolerations := []corev1.Toleration{{
+					Key:      "dedicated",
+					Operator: corev1.TolerationOpEqual,
+					Value:    "infra",
+					Effect:   corev1.TaintEffectNoSchedule,
+				}}
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					DBPath:       "/database/index.db",
+					BundleImage:  "quay.io/example/example-operator-bundle:0.2.0",
+					NodeSelector: nodeSelector,
+					Tolerations:  tolerations,
+				})
+				Expect(err).To(BeNil())
+				Expect(rp.pod.Spec.NodeSelector).To(Equal(nodeSelector))
+				Expect(rp.pod.Spec.Tolerations).To(Equal(tolerations))
+			})
+			It("should leave them unset by default", func() {
+				cfg := &operator.Configuration{
+					Client:    newFakeClient(),
+					Namespace: "test-default",
+				}
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					DBPath:      "/database/index.db",
+					BundleImage: "quay.io/example/example-operator-bundle:0.2.0",
+				})
+				Expect(err).To(BeNil())
+				Expect(rp.pod.Spec.NodeSelector).To(BeNil())
+				Expect(rp.pod.Spec.Tolerations).To(BeNil())
+			})
+		})
+
+		Context("with a pull secret", func() {
+			var cfg *operator.Configuration
+
+			BeforeEach(func() {
+				cfg = &operator.Configuration{
+					Client:    newFakeClient(),
+					Namespace: "test-default",
+				}
+			})
+
+			It("should not set pull secrets by default", func() {
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					DBPath:      "/database/index.db",
+					BundleImage: "quay.io/example/example-operator-bundle:0.2.0",
+				})
+				Expect(err).To(BeNil())
+				Expect(rp.pod.Spec.ImagePullSecrets).To(BeEmpty())
+				Expect(rp.pod.Spec.Volumes).To(BeEmpty())
+				Expect(rp.pod.Spec.Containers[0].Env).To(BeEmpty())
+			})
+
+			It("should pull images with the pull secret", func() {
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					CatalogMode:    FBCCatalogMode,
+					ConfigsDir:     "/configs",
+					BundleImage:    "quay.io/private/example-operator-bundle:0.2.0",
+					PackageName:    "example-operator",
+					PackageConfig:  "{}",
+					PullSecretName: "registry-creds",
+				})
+				Expect(err).To(BeNil())
+				spec := rp.pod.Spec
+				Expect(spec.ImagePullSecrets).To(Equal([]corev1.LocalObjectReference{{Name: "registry-creds"}}))
+				Expect(spec.Volumes).To(HaveLen(1))
+				Expect(spec.Volumes[0].Secret.SecretName).To(Equal("registry-creds"))
+				Expect(spec.Volumes[0].Secret.Items).To(Equal([]corev1.KeyToPath{
+					{Key: corev1.DockerConfigJsonKey, Path: "config.json"},
+				}))
+				container := spec.Containers[0]
+				Expect(container.VolumeMounts).To(Equal([]corev1.VolumeMount{
+					{Name: spec.Volumes[0].Name, MountPath: pullSecretMountPath, ReadOnly: true},
+				}))
+				Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "DOCKER_CONFIG", Value: pullSecretMountPath}))
+				Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: packageConfigEnvVar, Value: "{}"}))
+			})
+		})
+
+		Context("with a CA secret", func() {
+			It("should trust the CA secret's certificates", func() {
+				cfg := &operator.Configuration{Client: newFakeClient(), Namespace: "test-default"}
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					DBPath:       "/database/index.db",
+					BundleImage:  "registry.example.com/example-operator-bundle:0.2.0",
+					CASecretName: "registry-ca",
+				})
+				Expect(err).To(BeNil())
+				spec := rp.pod.Spec
+				Expect(spec.Volumes).To(HaveLen(1))
+				Expect(spec.Volumes[0].Secret.SecretName).To(Equal("registry-ca"))
+				Expect(spec.Volumes[0].Secret.Items).To(Equal([]corev1.KeyToPath{{Key: "cert.pem", Path: "cert.pem"}}))
+				container := spec.Containers[0]
+				Expect(container.VolumeMounts).To(Equal([]corev1.VolumeMount{
+					{Name: spec.Volumes[0].Name, MountPath: caSecretMountPath, ReadOnly: true},
+				}))
+				Expect(container.Env).To(Equal([]corev1.EnvVar{{Name: "SSL_CERT_DIR", Value: caSecretMountPath}}))
+			})
+		})
+
+		Context("on nodes without a docker daemon", func() {
+			It("should only mount secrets and empty directories", func() {
+				cfg := &operator.Configuration{Client: newFakeClient(), Namespace: "test-default"}
+				for _, mode := range []string{SQLiteCatalogMode, FBCCatalogMode} {
+					rp, err := NewRegistryPod(cfg, RegistryPod{
+						CatalogMode:               mode,
+						DBPath:                    "/database/index.db",
+						ConfigsDir:                "/configs",
+						RegistryImage:             "mirror.example.com/operator-framework/opm:v1.19.0",
+						BundleImage:               "registry.example.com/example-operator-bundle:0.2.0",
+						PackageName:               "example-operator",
+						PackageConfig:             "{}",
+						RestrictedSecurityContext: true,
+						PullSecretName:            "registry-creds",
+						CASecretName:              "registry-ca",
+					})
+					Expect(err).To(BeNil())
+					for _, v := range rp.pod.Spec.Volumes {
+						Expect(v.Secret != nil || v.EmptyDir != nil).To(BeTrue(), "%s volume %q", mode, v.Name)
+					}
+					// opm pulls bundles from registries itself, rather than through a container tool.
+					for _, c := range append(rp.pod.Spec.InitContainers, rp.pod.Spec.Containers...) {
+						Expect(strings.Join(c.Command, " ")).NotTo(MatchRegexp(`docker |podman |--container-tool`))
+					}
+				}
+			})
+		})
+
+		Context("with an insecure registry", func() {
+			var cfg *operator.Configuration
+
+			BeforeEach(func() {
+				cfg = &operator.Configuration{Client: newFakeClient(), Namespace: "test-default"}
+			})
+
+			It("should add the bundle without verifying TLS over plain HTTP", func() {
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					DBPath:        "/database/index.db",
+					BundleImage:   "localhost:5000/example-operator-bundle:0.2.0",
+					SkipTLSVerify: true,
+					UseHTTP:       true,
+				})
+				Expect(err).To(BeNil())
+				output, err := rp.getContainerCmd()
+				Expect(err).To(BeNil())
+				Expect(output).To(Equal("/bin/mkdir -p /database &&" +
+					"/bin/opm registry add -d /database/index.db -b localhost:5000/example-operator-bundle:0.2.0 " +
+					"--mode=semver --skip-tls-verify --use-http &&" +
+					"/bin/opm registry serve -d /database/index.db -p 50051"))
+			})
+
+			It("should render the bundle over plain HTTP", func() {
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					CatalogMode:   FBCCatalogMode,
+					ConfigsDir:    "/configs",
+					BundleImage:   "localhost:5000/example-operator-bundle:0.2.0",
+					PackageName:   "example-operator",
+					PackageConfig: "{}",
+					UseHTTP:       true,
+				})
+				Expect(err).To(BeNil())
+				output, err := rp.getContainerCmd()
+				Expect(err).To(BeNil())
+				Expect(output).To(ContainSubstring("/bin/opm render localhost:5000/example-operator-bundle:0.2.0 " +
+					"-o json --use-http > /configs/example-operator/bundle.json &&"))
+			})
+		})
+
+		Context("with a registry image", func() {
+			const (
+				indexImage    = "quay.io/example/example-operator-index:latest"
+				registryImage = "mirror.example.com/operator-framework/opm:v1.19.0"
+			)
+			var cfg *operator.Configuration
+
+			BeforeEach(func() {
+				cfg = &operator.Configuration{Client: newFakeClient(), Namespace: "test-default"}
+			})
+
+			It("should run the index image by default", func() {
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					IndexImage:  indexImage,
+					DBPath:      "/database/index.db",
+					BundleImage: "quay.io/example/example-operator-bundle:0.2.0",
+				})
+				Expect(err).To(BeNil())
+				Expect(rp.RegistryImage).To(Equal(indexImage))
+				Expect(rp.pod.Spec.InitContainers).To(BeEmpty())
+				Expect(rp.pod.Spec.Volumes).To(BeEmpty())
+			})
+
+			It("should serve a copy of the index database from the registry image", func() {
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					IndexImage:    indexImage,
+					RegistryImage: registryImage,
+					DBPath:        "/database/index.db",
+					BundleImage:   "quay.io/example/example-operator-bundle:0.2.0",
+				})
+				Expect(err).To(BeNil())
+				spec := rp.pod.Spec
+				Expect(spec.Volumes).To(Equal([]corev1.Volume{{
+					Name:         indexContentVolumeName,
+					VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+				}}))
+				mount := corev1.VolumeMount{Name: indexContentVolumeName, MountPath: indexContentDir}
+				Expect(spec.InitContainers).To(HaveLen(1))
+				Expect(spec.InitContainers[0].Image).To(Equal(indexImage))
+				Expect(spec.InitContainers[0].Command).To(Equal([]string{"/bin/sh", "-c",
+					"/bin/mkdir -p /var/lib/operator-sdk/index/database && " +
+						"if [ -f /database/index.db ]; then /bin/cp /database/index.db /var/lib/operator-sdk/index/database/index.db; fi"}))
+				Expect(spec.InitContainers[0].VolumeMounts).To(Equal([]corev1.VolumeMount{mount}))
+				Expect(spec.Containers[0].Image).To(Equal(registryImage))
+				Expect(spec.Containers[0].VolumeMounts).To(Equal([]corev1.VolumeMount{mount}))
+
+				output, err := rp.getContainerCmd()
+				Expect(err).To(BeNil())
+				Expect(output).To(Equal("for cmd in \"registry add\" \"registry serve\"; do " +
+					"/bin/opm $cmd --help > /dev/null 2>&1 || { echo \"registry image " + registryImage +
+					" does not support /bin/opm $cmd\" >&2; exit 1; }; done &&" +
+					"/bin/mkdir -p /var/lib/operator-sdk/index/database &&" +
+					"/bin/opm registry add -d /var/lib/operator-sdk/index/database/index.db " +
+					"-b quay.io/example/example-operator-bundle:0.2.0 --mode=replaces --overwrite-latest &&" +
+					"/bin/opm registry serve -d /var/lib/operator-sdk/index/database/index.db -p 50051"))
+			})
+
+			It("should serve a copy of the index configs directory from the registry image", func() {
+				rp, err := NewRegistryPod(cfg, RegistryPod{
+					IndexImage:    indexImage,
+					RegistryImage: registryImage,
+					CatalogMode:   FBCCatalogMode,
+					ConfigsDir:    "/configs",
+					BundleImage:   "quay.io/example/example-operator-bundle:0.2.0",
+					PackageName:   "example-operator",
+					PackageConfig: "{}",
+				})
+				Expect(err).To(BeNil())
+				spec := rp.pod.Spec
+				Expect(spec.InitContainers).To(HaveLen(1))
+				Expect(spec.InitContainers[0].Command).To(Equal([]string{"/bin/sh", "-c",
+					"/bin/mkdir -p /var/lib/operator-sdk/index/configs && " +
+						"if [ -d /configs ]; then /bin/cp -r /configs/. /var/lib/operator-sdk/index/configs; fi"}))
+				Expect(spec.Containers[0].Image).To(Equal(registryImage))
+
+				output, err := rp.getContainerCmd()
+				Expect(err).To(BeNil())
+				Expect(output).To(HavePrefix("for cmd in \"render\" \"serve\"; do "))
+				Expect(output).To(ContainSubstring("/bin/opm render quay.io/example/example-operator-bundle:0.2.0 " +
+					"-o json > /var/lib/operator-sdk/index/configs/example-operator/bundle.json &&"))
+				Expect(output).To(HaveSuffix("/bin/opm serve /var/lib/operator-sdk/index/configs -p 50051"))
+			})
+		})
+
 		Context("with invalid registry pod values", func() {
 			var cfg *operator.Configuration
 			BeforeEach(func() {
@@ -125,7 +642,7 @@ var _ = Describe("RegistryPod", func() {
 			It("should error when bundle image is not provided", func() {
 				expectedErr := "bundle image cannot be empty"
 
-				_, err := NewRegistryPod(cfg, "/database/index.db", "")
+				_, err := NewRegistryPod(cfg, RegistryPod{DBPath: "/database/index.db"})
 
 				Expect(err).NotTo(BeNil())
 				Expect(err.Error()).Should(ContainSubstring(expectedErr))
@@ -134,8 +651,7 @@ var _ = Describe("RegistryPod", func() {
 			It("should not create a registry pod when database path is not provided", func() {
 				expectedErr := "registry database path cannot be empty"
 
-				_, err := NewRegistryPod(cfg, "",
-					"quay.io/example/example-operator-bundle:0.2.0")
+				_, err := NewRegistryPod(cfg, RegistryPod{BundleImage: "quay.io/example/example-operator-bundle:0.2.0"})
 
 				Expect(err).NotTo(BeNil())
 				Expect(err.Error()).Should(ContainSubstring(expectedErr))
@@ -144,8 +660,10 @@ var _ = Describe("RegistryPod", func() {
 			It("should not create a registry pod when bundle add mode is empty", func() {
 				expectedErr := "bundle add mode cannot be empty"
 
-				rp, _ := NewRegistryPod(cfg, "/database/index.db",
-					"quay.io/example/example-operator-bundle:0.2.0")
+				rp, _ := NewRegistryPod(cfg, RegistryPod{
+					DBPath:      "/database/index.db",
+					BundleImage: "quay.io/example/example-operator-bundle:0.2.0",
+				})
 				rp.BundleAddMode = ""
 
 				err := rp.validate()
@@ -156,8 +674,10 @@ var _ = Describe("RegistryPod", func() {
 			It("should not accept any other bundle add mode other than semver or replaces", func() {
 				expectedErr := "invalid bundle mode"
 
-				rp, _ := NewRegistryPod(cfg, "/database/index.db",
-					"quay.io/example/example-operator-bundle:0.2.0")
+				rp, _ := NewRegistryPod(cfg, RegistryPod{
+					DBPath:      "/database/index.db",
+					BundleImage: "quay.io/example/example-operator-bundle:0.2.0",
+				})
 				rp.BundleAddMode = "invalid"
 
 				err := rp.validate()
@@ -165,9 +685,20 @@ var _ = Describe("RegistryPod", func() {
 				Expect(err.Error()).Should(ContainSubstring(expectedErr))
 			})
 
+			It("should not accept any other catalog mode other than sqlite or fbc", func() {
+				_, err := NewRegistryPod(cfg, RegistryPod{
+					CatalogMode: "invalid",
+					DBPath:      "/database/index.db",
+					BundleImage: "quay.io/example/example-operator-bundle:0.2.0",
+				})
+				Expect(err).To(MatchError(ContainSubstring(`invalid catalog mode "invalid"`)))
+			})
+
 			It("checkPodStatus should return error when pod check is false and context is done", func() {
-				rp, _ := NewRegistryPod(cfg, "/database/index.db",
-					"quay.io/example/example-operator-bundle:0.2.0")
+				rp, _ := NewRegistryPod(cfg, RegistryPod{
+					DBPath:      "/database/index.db",
+					BundleImage: "quay.io/example/example-operator-bundle:0.2.0",
+				})
 
 				mockBadPodCheck := wait.ConditionFunc(func() (done bool, err error) {
 					return false, fmt.Errorf("error waiting for registry pod")
@@ -196,4 +727,83 @@ var _ = Describe("RegistryPod", func() {
 
 		})
 	})
+
+	Describe("GetRegistryPodPackageConfig", func() {
+		const bundleImage = "quay.io/example/example-operator-bundle:0.2.0"
+
+		It("should return the package config of a fbc mode registry pod", func() {
+			cl := newFakeClient()
+			pod := &corev1.Pod{}
+			pod.SetName(getPodName(bundleImage))
+			pod.SetNamespace("test-default")
+			pod.Spec.Containers = []corev1.Container{{
+				Name: defaultContainerName,
+				Env:  []corev1.EnvVar{{Name: "PACKAGE_CONFIG", Value: "{}"}},
+			}}
+			Expect(cl.Create(context.TODO(), pod)).To(Succeed())
+
+			Expect(GetRegistryPodPackageConfig(context.TODO(), cl, "test-default", bundleImage)).To(Equal("{}"))
+		})
+
+		It("should error if the registry pod has no package config", func() {
+			cl := newFakeClient()
+			pod := &corev1.Pod{}
+			pod.SetName(getPodName(bundleImage))
+			pod.SetNamespace("test-default")
+			pod.Spec.Containers = []corev1.Container{{Name: defaultContainerName}}
+			Expect(cl.Create(context.TODO(), pod)).To(Succeed())
+
+			_, err := GetRegistryPodPackageConfig(context.TODO(), cl, "test-default", bundleImage)
+			Expect(err).To(MatchError(HaveSuffix("has no package config")))
+		})
+	})
+
+	Describe("DeleteRegistryPod", func() {
+		const bundleImage = "quay.io/example/example-operator-bundle:0.2.0"
+
+		It("should delete the registry pod", func() {
+			cl := newFakeClient()
+			pod := &corev1.Pod{}
+			pod.SetName(getPodName(bundleImage))
+			pod.SetNamespace("test-default")
+			Expect(cl.Create(context.TODO(), pod)).To(Succeed())
+
+			Expect(DeleteRegistryPod(context.TODO(), cl, "test-default", bundleImage)).To(Succeed())
+			err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "test-default", Name: pod.GetName()}, pod)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should ignore a registry pod that does not exist", func() {
+			Expect(DeleteRegistryPod(context.TODO(), newFakeClient(), "test-default", bundleImage)).To(Succeed())
+		})
+	})
+
+	Describe("GetRegistryPodCondition", func() {
+		const bundleImage = "quay.io/example/example-operator-bundle:0.2.0"
+
+		It("should describe why a pending pod's containers are waiting", func() {
+			cl := newFakeClient()
+			pod := &corev1.Pod{}
+			pod.SetName(getPodName(bundleImage))
+			pod.SetNamespace("test-default")
+			pod.Status.Phase = corev1.PodPending
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+				Name: defaultContainerName,
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+					Reason:  "ImagePullBackOff",
+					Message: "Back-off pulling image",
+				}},
+			}}
+			Expect(cl.Create(context.TODO(), pod)).To(Succeed())
+
+			Expect(GetRegistryPodCondition(context.TODO(), cl, "test-default", bundleImage)).To(Equal(
+				`registry pod "test-default/` + pod.GetName() + `" phase "Pending"; ` +
+					`container "registry-grpc" waiting: ImagePullBackOff: Back-off pulling image`))
+		})
+
+		It("should describe a pod that does not exist", func() {
+			Expect(GetRegistryPodCondition(context.TODO(), newFakeClient(), "test-default", bundleImage)).To(
+				HavePrefix(`error getting registry pod "test-default/`))
+		})
+	})
 })
@@ -16,44 +16,254 @@ package registry
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-registry/pkg/image/containerdregistry"
+	log "github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/retry"
 
 	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/fbc"
 	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/index"
 	registryutil "github.com/operator-framework/operator-sdk/internal/registry"
 )
 
 type IndexImageCatalogCreator struct {
-	PackageName      string
-	IndexImage       string
+	PackageName string
+	IndexImage  string
+	// RegistryImage is the image the registry pod runs opm from, IndexImage by default.
+	RegistryImage    string
 	InjectBundles    []string
 	InjectBundleMode string
 	BundleImage      string
+	// CatalogMode is the format of IndexImage's catalog, one of "sqlite" or "fbc".
+	// If empty, the mode is detected from IndexImage's labels.
+	CatalogMode string
+	// BundleChannels, BundleDefaultChannel, BundleCSVName, and BundleReplaces describe
+	// BundleImage's package, and are used to write its declarative config in fbc mode.
+	BundleChannels       []string
+	BundleDefaultChannel string
+	BundleCSVName        string
+	BundleReplaces       string
+	// DependencyBundles are bundles of other packages served alongside BundleImage,
+	// ex. ones the operator depends on, which OLM resolves from the catalog.
+	DependencyBundles []DependencyBundle
+	// RegistryResources are the registry pod container's resource
+	// requirements. Empty by default.
+	RegistryResources corev1.ResourceRequirements
+	// SecurityContextConfig selects the registry pod's security context.
+	// Defaults to legacy.
+	SecurityContextConfig operator.SecurityContextConfig
+	// PullSecretName is the name of a kubernetes.io/dockerconfigjson Secret in the
+	// install namespace. If set, the registry pod pulls IndexImage and BundleImage with
+	// it, and the CatalogSource lists it so OLM pulls bundles with it when unpacking.
+	PullSecretName string
+	// CASecretName is the name of a Secret in the install namespace containing
+	// PEM-encoded CA certificates in its cert.pem key. If set, the registry pod's opm
+	// trusts them when pulling BundleImage. Images pulled by the kubelet, and OLM's
+	// bundle unpack jobs, can't be configured to trust them.
+	CASecretName string
+	// SkipTLSVerify and UseHTTP pull IndexImage's labels and BundleImage from insecure
+	// registries, without verifying TLS certificates or over plain HTTP, both by the SDK
+	// and by the registry pod's opm. As with CASecretName, images pulled by the kubelet
+	// are not affected.
+	SkipTLSVerify bool
+	UseHTTP       bool
+	// ServiceAccountName is the name of an existing ServiceAccount in the install
+	// namespace the registry pod runs as. If empty, the pod runs as the SDK's registry
+	// ServiceAccount, which is created if it does not exist.
+	ServiceAccountName string
+	// RegistryNodeSelector and RegistryTolerations constrain the nodes the registry
+	// pod is scheduled on, ex. to a tainted pool for infrastructure pods.
+	RegistryNodeSelector map[string]string
+	RegistryTolerations  []corev1.Toleration
 
 	cfg *operator.Configuration
 }
 
+// DependencyBundle describes a bundle of a package other than the operator's
+// served by an IndexImageCatalogCreator's catalog.
+type DependencyBundle struct {
+	Image          string
+	PackageName    string
+	CSVName        string
+	Channels       []string
+	DefaultChannel string
+}
+
 func NewIndexImageCatalogCreator(cfg *operator.Configuration) *IndexImageCatalogCreator {
 	return &IndexImageCatalogCreator{
 		cfg: cfg,
 	}
 }
 
+// OptionRules returns the rules constraining c's fields. Bundle and package
+// fields are set by c's embedding struct.
+func (c IndexImageCatalogCreator) OptionRules() operator.OptionRules {
+	rules := operator.OptionRules{
+		Rules: []operator.OptionRule{
+			operator.Required(operator.StringOption("IndexImage", "--index-image", &c.IndexImage)),
+		},
+	}
+	return rules.Append(c.registryPodOptionRules())
+}
+
+// UpdateOptionRules returns the rules constraining c's fields when running UpdateCatalog,
+// which reads the index image from the catalog being updated.
+func (c IndexImageCatalogCreator) UpdateOptionRules() operator.OptionRules {
+	rules := c.registryPodOptionRules()
+	rules.Unconstrained = append(rules.Unconstrained, "IndexImage")
+	return rules
+}
+
+// registryPodOptionRules returns the rules constraining c's registry pod and catalog fields.
+func (c IndexImageCatalogCreator) registryPodOptionRules() operator.OptionRules {
+	caSecret := operator.StringOption("CASecretName", "--ca-secret-name", &c.CASecretName)
+	return operator.OptionRules{
+		Rules: []operator.OptionRule{
+			operator.Constraint(func() error { return operator.ValidateResourceRequirements(c.RegistryResources) },
+				registryResourcesOption(c.RegistryResources)),
+			operator.Constraint(c.SecurityContextConfig.Validate, securityContextConfigOption(c.SecurityContextConfig)),
+			operator.Constraint(c.validateCatalogMode,
+				operator.StringOption("CatalogMode", "--catalog-mode", &c.CatalogMode)),
+			// A custom CA is only trusted when verifying a registry's TLS certificate.
+			operator.MutuallyExclusive(caSecret, operator.BoolOption("SkipTLSVerify", "--skip-tls-verify", &c.SkipTLSVerify)),
+			operator.MutuallyExclusive(caSecret, operator.BoolOption("UseHTTP", "--use-http", &c.UseHTTP)),
+			operator.Constraint(func() error { return operator.ValidateNodeSelector(c.RegistryNodeSelector) },
+				operator.Option{Field: "RegistryNodeSelector", Flag: "--registry-pod-node-selector",
+					IsSet: func() bool { return len(c.RegistryNodeSelector) != 0 }}),
+			operator.Constraint(func() error { return operator.ValidateTolerations(c.RegistryTolerations) },
+				operator.Option{Field: "RegistryTolerations", Flag: "--registry-pod-tolerations-file",
+					IsSet: func() bool { return len(c.RegistryTolerations) != 0 }}),
+		},
+		Unconstrained: []string{"PackageName", "InjectBundles", "InjectBundleMode", "BundleImage",
+			"BundleChannels", "BundleDefaultChannel", "BundleCSVName", "BundleReplaces", "DependencyBundles", "PullSecretName",
+			"RegistryImage", "ServiceAccountName"},
+	}
+}
+
+func (c IndexImageCatalogCreator) validateCatalogMode() error {
+	switch c.CatalogMode {
+	case "", index.SQLiteCatalogMode, index.FBCCatalogMode:
+		return nil
+	}
+	return fmt.Errorf("must be one of [%s, %s]", index.SQLiteCatalogMode, index.FBCCatalogMode)
+}
+
+// ValidatePullSecret returns an error if c.PullSecretName is set and is not the
+// name of a kubernetes.io/dockerconfigjson Secret in the install namespace.
+func (c IndexImageCatalogCreator) ValidatePullSecret(ctx context.Context) error {
+	if c.PullSecretName == "" {
+		return nil
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: c.cfg.Namespace, Name: c.PullSecretName}
+	if err := c.cfg.Client.Get(ctx, key, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("pull secret %q not found in namespace %q", c.PullSecretName, c.cfg.Namespace)
+		}
+		return fmt.Errorf("error getting pull secret %q: %v", c.PullSecretName, err)
+	}
+	if secret.Type != corev1.SecretTypeDockerConfigJson {
+		return fmt.Errorf("pull secret %q is of type %q, it must be of type %s",
+			c.PullSecretName, secret.Type, corev1.SecretTypeDockerConfigJson)
+	}
+	return nil
+}
+
+// ValidateCASecret returns an error if c.CASecretName is set and is not the name of
+// a Secret in the install namespace whose cert.pem key contains PEM-encoded certificates.
+func (c IndexImageCatalogCreator) ValidateCASecret(ctx context.Context) error {
+	if c.CASecretName == "" {
+		return nil
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: c.cfg.Namespace, Name: c.CASecretName}
+	if err := c.cfg.Client.Get(ctx, key, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("CA secret %q not found in namespace %q", c.CASecretName, c.cfg.Namespace)
+		}
+		return fmt.Errorf("error getting CA secret %q: %v", c.CASecretName, err)
+	}
+	data, ok := secret.Data[index.CASecretKey]
+	if !ok {
+		return fmt.Errorf("CA secret %q has no %s key", c.CASecretName, index.CASecretKey)
+	}
+	var certs int
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return fmt.Errorf("CA secret %q %s contains an invalid certificate: %v", c.CASecretName, index.CASecretKey, err)
+		}
+		certs++
+	}
+	if certs == 0 {
+		return fmt.Errorf("CA secret %q %s contains no PEM-encoded certificates", c.CASecretName, index.CASecretKey)
+	}
+	return nil
+}
+
+// ValidateServiceAccount returns an error if c.ServiceAccountName is set and is
+// not the name of a ServiceAccount in the install namespace.
+func (c IndexImageCatalogCreator) ValidateServiceAccount(ctx context.Context) error {
+	if c.ServiceAccountName == "" {
+		return nil
+	}
+	key := types.NamespacedName{Namespace: c.cfg.Namespace, Name: c.ServiceAccountName}
+	if err := c.cfg.Client.Get(ctx, key, &corev1.ServiceAccount{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("service account %q not found in namespace %q", c.ServiceAccountName, c.cfg.Namespace)
+		}
+		return fmt.Errorf("error getting service account %q: %v", c.ServiceAccountName, err)
+	}
+	return nil
+}
+
+// ensureServiceAccount returns the name of the ServiceAccount the registry pod runs
+// as, creating the SDK's registry ServiceAccount if c.ServiceAccountName is not set.
+func (c IndexImageCatalogCreator) ensureServiceAccount(ctx context.Context) (string, error) {
+	if c.ServiceAccountName != "" {
+		return c.ServiceAccountName, nil
+	}
+	return createRegistryServiceAccount(ctx, c.cfg)
+}
+
+// PullOptions returns options for the registry the SDK pulls images from.
+func (c IndexImageCatalogCreator) PullOptions() []containerdregistry.RegistryOption {
+	// The registry skips TLS verification and falls back to plain HTTP together.
+	return []containerdregistry.RegistryOption{containerdregistry.SkipTLS(c.SkipTLSVerify || c.UseHTTP)}
+}
+
 func (c IndexImageCatalogCreator) CreateCatalog(ctx context.Context, name string) (*v1alpha1.CatalogSource, error) {
-	dbPath, err := c.getDBPath(ctx)
+	labels, err := registryutil.GetImageLabels(ctx, nil, c.IndexImage, false, c.PullOptions()...)
 	if err != nil {
-		return nil, fmt.Errorf("get database path: %v", err)
+		return nil, fmt.Errorf("get index image labels: %v", err)
+	}
+	opts := c.getCatalogOptions(labels)
+	for _, b := range c.DependencyBundles {
+		opts.InjectedBundleImages = append(opts.InjectedBundleImages, b.Image)
+	}
+	if opts.CatalogMode == index.FBCCatalogMode {
+		if opts.PackageConfig, err = c.getPackageConfig(); err != nil {
+			return nil, fmt.Errorf("get package config: %v", err)
+		}
 	}
 
 	// create a basic catalog source type
 	cs := newCatalogSource(name, c.cfg.Namespace,
-		withSDKPublisher(c.PackageName))
+		withSDKPublisher(c.PackageName),
+		withPullSecret(c.PullSecretName))
 
 	// create catalog source resource
 	if err := c.cfg.Client.Create(ctx, cs); err != nil {
@@ -61,35 +271,313 @@ func (c IndexImageCatalogCreator) CreateCatalog(ctx context.Context, name string
 	}
 
 	// create registry pod
-	pod, err := c.createRegistryPod(ctx, dbPath, cs)
+	pod, err := c.createRegistryPod(ctx, opts, cs)
 	if err != nil {
 		return nil, fmt.Errorf("error creating registry pod: %v", err)
 	}
 
 	// update catalog source with source type, address and annotations
-	if err := c.updateCatalogSource(ctx, pod.Status.PodIP, cs); err != nil {
+	if err := c.updateCatalogSource(ctx, pod.Status.PodIP, opts.CatalogMode, cs); err != nil {
 		return nil, fmt.Errorf("error updating catalog source: %v", err)
 	}
 
 	return cs, nil
 }
 
-const defaultDBPath = "/database/index.db"
+// UpdateCatalog adds c.BundleImage to cs, a catalog created by CreateCatalog. The bundles
+// cs already serves and c.BundleImage are added to cs's index image in a new registry pod,
+// then cs is pointed at the new pod and the previous one is deleted.
+func (c IndexImageCatalogCreator) UpdateCatalog(ctx context.Context, cs *v1alpha1.CatalogSource) error {
+	annotations := cs.GetAnnotations()
+	indexImage, ok := annotations[indexImageAnnotation]
+	if !ok {
+		return fmt.Errorf("catalog source %q was not created by run bundle: it has no %s annotation",
+			cs.GetName(), indexImageAnnotation)
+	}
+	var injected []string
+	if err := json.Unmarshal([]byte(annotations[injectedBundlesAnnotation]), &injected); err != nil || len(injected) == 0 {
+		return fmt.Errorf("catalog source %q has no injected bundles in its %s annotation",
+			cs.GetName(), injectedBundlesAnnotation)
+	}
+	for _, b := range injected {
+		if b == c.BundleImage {
+			return fmt.Errorf("bundle %s is already served by catalog source %q", c.BundleImage, cs.GetName())
+		}
+	}
+	prevBundle := injected[len(injected)-1]
+
+	// The catalog is rebuilt the way it was created.
+	c.IndexImage = indexImage
+	if img := annotations[registryImageAnnotation]; c.RegistryImage == "" && img != indexImage {
+		c.RegistryImage = img
+	}
+	c.CatalogMode = annotations[catalogModeAnnotation]
+	if c.InjectBundleMode == "" {
+		c.InjectBundleMode = annotations[injectBundleModeAnnotation]
+	}
+	if c.PullSecretName == "" && len(cs.Spec.Secrets) != 0 {
+		c.PullSecretName = cs.Spec.Secrets[0]
+	}
+	if c.CASecretName == "" {
+		c.CASecretName = annotations[caSecretAnnotation]
+	}
+	if c.ServiceAccountName == "" {
+		c.ServiceAccountName = annotations[serviceAccountAnnotation]
+	}
+	if err := c.setSchedulingFromAnnotations(annotations); err != nil {
+		return fmt.Errorf("catalog source %q: %v", cs.GetName(), err)
+	}
+	c.SkipTLSVerify = c.SkipTLSVerify || annotations[skipTLSVerifyAnnotation] == "true"
+	c.UseHTTP = c.UseHTTP || annotations[useHTTPAnnotation] == "true"
+	c.InjectBundles = append(injected, c.BundleImage)
 
-func (c IndexImageCatalogCreator) getDBPath(ctx context.Context) (string, error) {
-	labels, err := registryutil.GetImageLabels(ctx, nil, c.IndexImage, false)
+	labels, err := registryutil.GetImageLabels(ctx, nil, c.IndexImage, false, c.PullOptions()...)
 	if err != nil {
-		return "", fmt.Errorf("get index image labels: %v", err)
+		return fmt.Errorf("get index image labels: %v", err)
+	}
+	opts := c.getCatalogOptions(labels)
+	opts.InjectedBundleImages = injected
+	if opts.CatalogMode == index.FBCCatalogMode {
+		prevConfig, err := index.GetRegistryPodPackageConfig(ctx, c.cfg.Client, c.cfg.Namespace, prevBundle)
+		if err != nil {
+			return fmt.Errorf("get previous package config: %v", err)
+		}
+		if opts.PackageConfig, err = c.getUpgradePackageConfig(prevConfig); err != nil {
+			return fmt.Errorf("get package config: %v", err)
+		}
 	}
-	if dbPath, ok := labels["operators.operatorframework.io.index.database.v1"]; ok {
-		return dbPath, nil
+
+	pod, err := c.createRegistryPod(ctx, opts, cs)
+	if err != nil {
+		return fmt.Errorf("error creating registry pod: %v", err)
 	}
-	return defaultDBPath, nil
+	if err := c.updateCatalogSource(ctx, pod.Status.PodIP, opts.CatalogMode, cs); err != nil {
+		return fmt.Errorf("error updating catalog source: %v", err)
+	}
+	if err := index.DeleteRegistryPod(ctx, c.cfg.Client, c.cfg.Namespace, prevBundle); err != nil {
+		log.Warnf("Failed to delete previous registry pod: %v", err)
+	}
+	return nil
+}
+
+// GetCatalogCondition describes the state of the registry pod serving the
+// catalog, which is where a catalog stage times out, ex. while pulling images.
+func (c IndexImageCatalogCreator) GetCatalogCondition(ctx context.Context, _ string) string {
+	return index.GetRegistryPodCondition(ctx, c.cfg.Client, c.cfg.Namespace, c.BundleImage)
+}
+
+// Annotations on CatalogSources created by IndexImageCatalogCreator, from which
+// UpdateCatalog rebuilds the catalog.
+const (
+	indexImageAnnotation       = "operators.operatorframework.io/index-image"
+	injectBundleModeAnnotation = "operators.operatorframework.io/inject-bundle-mode"
+	injectedBundlesAnnotation  = "operators.operatorframework.io/injected-bundles"
+	catalogModeAnnotation      = "operators.operatorframework.io/catalog-mode"
+	caSecretAnnotation         = "operators.operatorframework.io/ca-secret-name"
+	skipTLSVerifyAnnotation    = "operators.operatorframework.io/skip-tls-verify"
+	useHTTPAnnotation          = "operators.operatorframework.io/use-http"
+	serviceAccountAnnotation   = "operators.operatorframework.io/registry-service-account"
+	nodeSelectorAnnotation     = "operators.operatorframework.io/registry-node-selector"
+	tolerationsAnnotation      = "operators.operatorframework.io/registry-tolerations"
+)
+
+const (
+	defaultDBPath     = "/database/index.db"
+	defaultConfigsDir = "/configs"
+
+	dbPathLabel     = "operators.operatorframework.io.index.database.v1"
+	configsDirLabel = "operators.operatorframework.io.index.configs.v1"
+)
+
+// getCatalogOptions returns registry pod options for c's catalog mode and the
+// catalog location in an index image labeled with labels. If c's catalog mode is
+// not set, images labeled with a configs directory are file-based catalogs.
+func (c IndexImageCatalogCreator) getCatalogOptions(labels map[string]string) index.RegistryPod {
+	opts := index.RegistryPod{
+		IndexImage:                c.IndexImage,
+		RegistryImage:             c.RegistryImage,
+		CatalogMode:               c.CatalogMode,
+		BundleImage:               c.BundleImage,
+		BundleAddMode:             c.InjectBundleMode,
+		PackageName:               c.PackageName,
+		Resources:                 c.RegistryResources,
+		RestrictedSecurityContext: c.SecurityContextConfig.IsRestricted(),
+		PullSecretName:            c.PullSecretName,
+		CASecretName:              c.CASecretName,
+		SkipTLSVerify:             c.SkipTLSVerify,
+		UseHTTP:                   c.UseHTTP,
+		ServiceAccountName:        c.ServiceAccountName,
+		NodeSelector:              c.RegistryNodeSelector,
+		Tolerations:               c.RegistryTolerations,
+	}
+	configsDir, hasConfigsDir := labels[configsDirLabel]
+	if opts.CatalogMode == "" {
+		opts.CatalogMode = index.SQLiteCatalogMode
+		if hasConfigsDir {
+			opts.CatalogMode = index.FBCCatalogMode
+		}
+	}
+
+	switch opts.CatalogMode {
+	case index.FBCCatalogMode:
+		opts.BundleAddMode = ""
+		opts.ConfigsDir = defaultConfigsDir
+		if hasConfigsDir {
+			opts.ConfigsDir = configsDir
+		}
+	default:
+		opts.DBPath = defaultDBPath
+		if dbPath, ok := labels[dbPathLabel]; ok {
+			opts.DBPath = dbPath
+		}
+	}
+	return opts
+}
+
+// getPackageConfig returns the olm.package and olm.channel declarative config
+// blobs for c's bundle and dependency bundles, in which each bundle is the only
+// entry of its package's channels.
+func (c IndexImageCatalogCreator) getPackageConfig() (string, error) {
+	cfg := fbc.DeclarativeConfig{}
+	addPackage(&cfg, c.PackageName, c.BundleDefaultChannel, c.BundleCSVName, c.BundleChannels)
+	for _, b := range c.DependencyBundles {
+		addPackage(&cfg, b.PackageName, b.DefaultChannel, b.CSVName, b.Channels)
+	}
+	b, err := cfg.Marshal()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// addPackage adds package pkgName to cfg, with csvName as the only entry of each
+// of channels. The default channel is the first channel if unset.
+func addPackage(cfg *fbc.DeclarativeConfig, pkgName, defaultChannel, csvName string, channels []string) {
+	if defaultChannel == "" && len(channels) != 0 {
+		defaultChannel = channels[0]
+	}
+	cfg.Packages = append(cfg.Packages, fbc.Package{
+		Schema:         fbc.SchemaPackage,
+		Name:           pkgName,
+		DefaultChannel: defaultChannel,
+	})
+	for _, ch := range channels {
+		cfg.Channels = append(cfg.Channels, fbc.Channel{
+			Schema:  fbc.SchemaChannel,
+			Package: pkgName,
+			Name:    ch,
+			Entries: []fbc.ChannelEntry{{Name: csvName}},
+		})
+	}
+}
+
+// getUpgradePackageConfig returns prevConfig, the package config of the catalog's
+// previous registry pod, with c's bundle added as the head of each of its channels.
+// In each channel the bundle replaces c.BundleReplaces if the channel contains it,
+// otherwise the channel's previous head, so each channel keeps a single head.
+func (c IndexImageCatalogCreator) getUpgradePackageConfig(prevConfig string) (string, error) {
+	cfg, err := fbc.ReadJSON(strings.NewReader(prevConfig))
+	if err != nil {
+		return "", err
+	}
+	var pkg *fbc.Package
+	for i := range cfg.Packages {
+		if cfg.Packages[i].Name == c.PackageName {
+			pkg = &cfg.Packages[i]
+		}
+	}
+	if pkg == nil {
+		return "", fmt.Errorf("previous package config is not for package %q", c.PackageName)
+	}
+	if c.BundleDefaultChannel != "" {
+		pkg.DefaultChannel = c.BundleDefaultChannel
+	}
+	for _, name := range c.BundleChannels {
+		entry := fbc.ChannelEntry{Name: c.BundleCSVName}
+		var ch *fbc.Channel
+		for i := range cfg.Channels {
+			if cfg.Channels[i].Package == c.PackageName && cfg.Channels[i].Name == name {
+				ch = &cfg.Channels[i]
+			}
+		}
+		if ch == nil {
+			cfg.Channels = append(cfg.Channels, fbc.Channel{
+				Schema:  fbc.SchemaChannel,
+				Package: c.PackageName,
+				Name:    name,
+				Entries: []fbc.ChannelEntry{entry},
+			})
+			continue
+		}
+		for _, e := range ch.Entries {
+			if e.Name == c.BundleReplaces {
+				entry.Replaces = e.Name
+			}
+		}
+		if entry.Replaces == "" && len(ch.Entries) != 0 {
+			entry.Replaces = ch.Entries[0].Name
+		}
+		ch.Entries = append([]fbc.ChannelEntry{entry}, ch.Entries...)
+	}
+	b, err := cfg.Marshal()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// getSchedulingAnnotations returns JSON encodings of c's registry pod node selector
+// and tolerations, each empty if unset.
+func (c IndexImageCatalogCreator) getSchedulingAnnotations() (nodeSelector, tolerations string, err error) {
+	if len(c.RegistryNodeSelector) != 0 {
+		b, err := json.Marshal(c.RegistryNodeSelector)
+		if err != nil {
+			return "", "", fmt.Errorf("error marshaling registry node selector: %v", err)
+		}
+		nodeSelector = string(b)
+	}
+	if len(c.RegistryTolerations) != 0 {
+		b, err := json.Marshal(c.RegistryTolerations)
+		if err != nil {
+			return "", "", fmt.Errorf("error marshaling registry tolerations: %v", err)
+		}
+		tolerations = string(b)
+	}
+	return nodeSelector, tolerations, nil
+}
+
+// setSchedulingFromAnnotations sets c's registry pod node selector and tolerations,
+// if unset, from those recorded in a catalog source's annotations.
+func (c *IndexImageCatalogCreator) setSchedulingFromAnnotations(annotations map[string]string) error {
+	if s := annotations[nodeSelectorAnnotation]; len(c.RegistryNodeSelector) == 0 && s != "" {
+		if err := json.Unmarshal([]byte(s), &c.RegistryNodeSelector); err != nil {
+			return fmt.Errorf("error decoding %s annotation: %v", nodeSelectorAnnotation, err)
+		}
+	}
+	if s := annotations[tolerationsAnnotation]; len(c.RegistryTolerations) == 0 && s != "" {
+		if err := json.Unmarshal([]byte(s), &c.RegistryTolerations); err != nil {
+			return fmt.Errorf("error decoding %s annotation: %v", tolerationsAnnotation, err)
+		}
+	}
+	return nil
 }
 
-func (c IndexImageCatalogCreator) createRegistryPod(ctx context.Context, dbPath string, cs *v1alpha1.CatalogSource) (*corev1.Pod, error) {
+// getRegistryImage returns the image the registry pod runs.
+func (c IndexImageCatalogCreator) getRegistryImage() string {
+	if c.RegistryImage != "" {
+		return c.RegistryImage
+	}
+	return c.IndexImage
+}
+
+func (c IndexImageCatalogCreator) createRegistryPod(ctx context.Context, opts index.RegistryPod, cs *v1alpha1.CatalogSource) (*corev1.Pod, error) {
+	var err error
+	if opts.ServiceAccountName, err = c.ensureServiceAccount(ctx); err != nil {
+		return nil, err
+	}
+
 	// Initialize registry pod
-	registryPod, err := index.NewRegistryPod(c.cfg, dbPath, c.BundleImage)
+	registryPod, err := index.NewRegistryPod(c.cfg, opts)
 	if err != nil {
 		return nil, fmt.Errorf("error initializing registry pod: %v", err)
 	}
@@ -103,13 +591,18 @@ func (c IndexImageCatalogCreator) createRegistryPod(ctx context.Context, dbPath
 	return pod, nil
 }
 
-func (c IndexImageCatalogCreator) updateCatalogSource(ctx context.Context, podAddr string, cs *v1alpha1.CatalogSource) error {
+func (c IndexImageCatalogCreator) updateCatalogSource(ctx context.Context, podAddr, catalogMode string, cs *v1alpha1.CatalogSource) error {
 	// JSON marshal injected bundles
 	injectedBundlesJSON, err := json.Marshal(c.InjectBundles)
 	if err != nil {
 		return fmt.Errorf("error marshaling injected bundles: %v", err)
 	}
 
+	nodeSelector, tolerations, err := c.getSchedulingAnnotations()
+	if err != nil {
+		return err
+	}
+
 	// Get catalog source key
 	catsrcKey := types.NamespacedName{
 		Namespace: cs.GetNamespace(),
@@ -118,9 +611,17 @@ func (c IndexImageCatalogCreator) updateCatalogSource(ctx context.Context, podAd
 
 	// Annotations for catalog source
 	annotationMapping := map[string]string{
-		"operators.operatorframework.io/index-image":        c.IndexImage,
-		"operators.operatorframework.io/inject-bundle-mode": c.InjectBundleMode,
-		"operators.operatorframework.io/injected-bundles":   string(injectedBundlesJSON),
+		indexImageAnnotation:       c.IndexImage,
+		injectBundleModeAnnotation: c.InjectBundleMode,
+		injectedBundlesAnnotation:  string(injectedBundlesJSON),
+		catalogModeAnnotation:      catalogMode,
+		caSecretAnnotation:         c.CASecretName,
+		skipTLSVerifyAnnotation:    strconv.FormatBool(c.SkipTLSVerify),
+		useHTTPAnnotation:          strconv.FormatBool(c.UseHTTP),
+		serviceAccountAnnotation:   c.ServiceAccountName,
+		nodeSelectorAnnotation:     nodeSelector,
+		tolerationsAnnotation:      tolerations,
+		registryImageAnnotation:    c.getRegistryImage(),
 	}
 	// Update catalog source with source type as grpc and address as the pod IP,
 	// and annotations for index image, injected bundles, and registry bundle add mode
@@ -130,6 +631,7 @@ func (c IndexImageCatalogCreator) updateCatalogSource(ctx context.Context, podAd
 		}
 		cs.Spec.Address = index.GetRegistryPodHost(podAddr)
 		cs.Spec.SourceType = v1alpha1.SourceTypeGrpc
+		withPullSecret(c.PullSecretName)(cs)
 		annotations := cs.GetAnnotations()
 		if annotations == nil {
 			annotations = make(map[string]string, len(annotationMapping))
@@ -0,0 +1,447 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/fbc"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/index"
+)
+
+var _ = Describe("IndexImageCatalogCreator", func() {
+	var c *IndexImageCatalogCreator
+
+	BeforeEach(func() {
+		c = &IndexImageCatalogCreator{
+			PackageName:          "memcached-operator",
+			IndexImage:           "quay.io/example/memcached-operator-index:v0.0.1",
+			InjectBundleMode:     index.ReplacesBundleAddMode,
+			BundleImage:          "quay.io/example/memcached-operator-bundle:v0.0.2",
+			BundleChannels:       []string{"alpha", "stable"},
+			BundleDefaultChannel: "stable",
+			BundleCSVName:        "memcached-operator.v0.0.2",
+		}
+	})
+
+	Describe("getCatalogOptions", func() {
+		It("should detect a sqlite index", func() {
+			opts := c.getCatalogOptions(map[string]string{dbPathLabel: "/db/index.db"})
+			Expect(opts.CatalogMode).To(Equal(index.SQLiteCatalogMode))
+			Expect(opts.DBPath).To(Equal("/db/index.db"))
+			Expect(opts.BundleAddMode).To(Equal(index.ReplacesBundleAddMode))
+			Expect(opts.ConfigsDir).To(BeEmpty())
+		})
+		It("should default to a sqlite index without labels", func() {
+			opts := c.getCatalogOptions(nil)
+			Expect(opts.CatalogMode).To(Equal(index.SQLiteCatalogMode))
+			Expect(opts.DBPath).To(Equal(defaultDBPath))
+		})
+		It("should detect a file-based catalog index", func() {
+			opts := c.getCatalogOptions(map[string]string{configsDirLabel: "/catalog"})
+			Expect(opts.CatalogMode).To(Equal(index.FBCCatalogMode))
+			Expect(opts.ConfigsDir).To(Equal("/catalog"))
+			Expect(opts.DBPath).To(BeEmpty())
+			Expect(opts.BundleAddMode).To(BeEmpty())
+		})
+		It("should use a catalog mode that is set", func() {
+			c.CatalogMode = index.FBCCatalogMode
+			opts := c.getCatalogOptions(map[string]string{dbPathLabel: "/db/index.db"})
+			Expect(opts.CatalogMode).To(Equal(index.FBCCatalogMode))
+			Expect(opts.ConfigsDir).To(Equal(defaultConfigsDir))
+		})
+		It("should pull with the pull secret", func() {
+			c.PullSecretName = "registry-creds"
+			Expect(c.getCatalogOptions(nil).PullSecretName).To(Equal("registry-creds"))
+		})
+		It("should trust the CA secret", func() {
+			c.CASecretName = "registry-ca"
+			Expect(c.getCatalogOptions(nil).CASecretName).To(Equal("registry-ca"))
+		})
+		It("should pull from insecure registries", func() {
+			c.SkipTLSVerify, c.UseHTTP = true, true
+			opts := c.getCatalogOptions(nil)
+			Expect(opts.SkipTLSVerify).To(BeTrue())
+			Expect(opts.UseHTTP).To(BeTrue())
+		})
+		It("should run the registry image", func() {
+			c.RegistryImage = "mirror.example.com/operator-framework/opm:v1.19.0"
+			Expect(c.getCatalogOptions(nil).RegistryImage).To(Equal(c.RegistryImage))
+			Expect(c.getRegistryImage()).To(Equal(c.RegistryImage))
+		})
+		It("should run the index image without a registry image", func() {
+			Expect(c.getRegistryImage()).To(Equal(c.IndexImage))
+		})
+		It("should run as the service account", func() {
+			c.ServiceAccountName = "registry"
+			Expect(c.getCatalogOptions(nil).ServiceAccountName).To(Equal("registry"))
+		})
+		It("should schedule with the node selector and tolerations", func() {
+			c.RegistryNodeSelector = map[string]string{"node-role.kubernetes.io/infra": ""}
+			c.RegistryTolerations = []corev1.Toleration{{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule}}
+			opts := c.getCatalogOptions(nil)
+			Expect(opts.NodeSelector).To(Equal(c.RegistryNodeSelector))
+			Expect(opts.Tolerations).To(Equal(c.RegistryTolerations))
+		})
+	})
+
+	Describe("service accounts", func() {
+		var client crclient.Client
+
+		BeforeEach(func() {
+			sch := runtime.NewScheme()
+			Expect(corev1.AddToScheme(sch)).To(Succeed())
+			client = fake.NewFakeClientWithScheme(sch)
+			c.cfg = &operator.Configuration{Client: client, Namespace: "testns"}
+		})
+
+		getSDKServiceAccount := func() (*corev1.ServiceAccount, error) {
+			sa := &corev1.ServiceAccount{}
+			key := crclient.ObjectKey{Namespace: "testns", Name: operator.SDKRegistryServiceAccountName}
+			return sa, client.Get(context.TODO(), key, sa)
+		}
+
+		It("should create the SDK's service account without a token if none is set", func() {
+			Expect(c.ValidateServiceAccount(context.TODO())).To(Succeed())
+			name, err := c.ensureServiceAccount(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal(operator.SDKRegistryServiceAccountName))
+			sa, err := getSDKServiceAccount()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sa.GetLabels()).To(Equal(map[string]string{"owner": "operator-sdk"}))
+			Expect(sa.AutomountServiceAccountToken).NotTo(BeNil())
+			Expect(*sa.AutomountServiceAccountToken).To(BeFalse())
+		})
+		It("should reuse the SDK's existing service account", func() {
+			_, err := c.ensureServiceAccount(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			name, err := c.ensureServiceAccount(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal(operator.SDKRegistryServiceAccountName))
+		})
+		It("should use a service account that is set without creating one", func() {
+			sa := &corev1.ServiceAccount{}
+			sa.SetName("registry")
+			sa.SetNamespace("testns")
+			Expect(client.Create(context.TODO(), sa)).To(Succeed())
+			c.ServiceAccountName = "registry"
+
+			Expect(c.ValidateServiceAccount(context.TODO())).To(Succeed())
+			name, err := c.ensureServiceAccount(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("registry"))
+			_, err = getSDKServiceAccount()
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+		It("should fail if the service account that is set does not exist", func() {
+			c.ServiceAccountName = "registry"
+			Expect(c.ValidateServiceAccount(context.TODO())).To(MatchError(
+				`service account "registry" not found in namespace "testns"`))
+		})
+	})
+
+	Describe("ValidatePullSecret", func() {
+		var client crclient.Client
+
+		newSecret := func(typ corev1.SecretType) *corev1.Secret {
+			secret := &corev1.Secret{Type: typ}
+			secret.SetName("registry-creds")
+			secret.SetNamespace("testns")
+			return secret
+		}
+
+		BeforeEach(func() {
+			sch := runtime.NewScheme()
+			Expect(corev1.AddToScheme(sch)).To(Succeed())
+			client = fake.NewFakeClientWithScheme(sch)
+			c.cfg = &operator.Configuration{Client: client, Namespace: "testns"}
+			c.PullSecretName = "registry-creds"
+		})
+
+		It("should succeed without a pull secret", func() {
+			c.PullSecretName = ""
+			Expect(c.ValidatePullSecret(context.TODO())).To(Succeed())
+		})
+		It("should succeed if the pull secret is a docker config", func() {
+			Expect(client.Create(context.TODO(), newSecret(corev1.SecretTypeDockerConfigJson))).To(Succeed())
+			Expect(c.ValidatePullSecret(context.TODO())).To(Succeed())
+		})
+		It("should fail if the pull secret does not exist", func() {
+			Expect(c.ValidatePullSecret(context.TODO())).To(MatchError(
+				`pull secret "registry-creds" not found in namespace "testns"`))
+		})
+		It("should fail if the pull secret is not a docker config", func() {
+			Expect(client.Create(context.TODO(), newSecret(corev1.SecretTypeOpaque))).To(Succeed())
+			Expect(c.ValidatePullSecret(context.TODO())).To(MatchError(
+				`pull secret "registry-creds" is of type "Opaque", it must be of type kubernetes.io/dockerconfigjson`))
+		})
+	})
+
+	Describe("ValidateCASecret", func() {
+		var client crclient.Client
+
+		newSecret := func(data map[string][]byte) *corev1.Secret {
+			secret := &corev1.Secret{Data: data}
+			secret.SetName("registry-ca")
+			secret.SetNamespace("testns")
+			return secret
+		}
+		newCertPEM := func() []byte {
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			tmpl := &x509.Certificate{
+				SerialNumber: big.NewInt(1),
+				Subject:      pkix.Name{CommonName: "registry.example.com"},
+				NotBefore:    time.Now(),
+				NotAfter:     time.Now().Add(time.Hour),
+				IsCA:         true,
+			}
+			der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+			Expect(err).NotTo(HaveOccurred())
+			return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		}
+
+		BeforeEach(func() {
+			sch := runtime.NewScheme()
+			Expect(corev1.AddToScheme(sch)).To(Succeed())
+			client = fake.NewFakeClientWithScheme(sch)
+			c.cfg = &operator.Configuration{Client: client, Namespace: "testns"}
+			c.CASecretName = "registry-ca"
+		})
+
+		It("should succeed without a CA secret", func() {
+			c.CASecretName = ""
+			Expect(c.ValidateCASecret(context.TODO())).To(Succeed())
+		})
+		It("should succeed if cert.pem contains a certificate", func() {
+			Expect(client.Create(context.TODO(), newSecret(map[string][]byte{"cert.pem": newCertPEM()}))).To(Succeed())
+			Expect(c.ValidateCASecret(context.TODO())).To(Succeed())
+		})
+		It("should fail if the CA secret does not exist", func() {
+			Expect(c.ValidateCASecret(context.TODO())).To(MatchError(
+				`CA secret "registry-ca" not found in namespace "testns"`))
+		})
+		It("should fail if the CA secret has no cert.pem", func() {
+			Expect(client.Create(context.TODO(), newSecret(map[string][]byte{"ca.crt": newCertPEM()}))).To(Succeed())
+			Expect(c.ValidateCASecret(context.TODO())).To(MatchError(`CA secret "registry-ca" has no cert.pem key`))
+		})
+		It("should fail if cert.pem is not PEM-encoded", func() {
+			Expect(client.Create(context.TODO(), newSecret(map[string][]byte{"cert.pem": []byte("not a cert")}))).To(Succeed())
+			Expect(c.ValidateCASecret(context.TODO())).To(MatchError(
+				`CA secret "registry-ca" cert.pem contains no PEM-encoded certificates`))
+		})
+		It("should fail if cert.pem contains an invalid certificate", func() {
+			data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("garbage")})
+			Expect(client.Create(context.TODO(), newSecret(map[string][]byte{"cert.pem": data}))).To(Succeed())
+			Expect(c.ValidateCASecret(context.TODO())).To(MatchError(
+				HavePrefix(`CA secret "registry-ca" cert.pem contains an invalid certificate`)))
+		})
+	})
+
+	Describe("getPackageConfig", func() {
+		decode := func(s string) (blobs []map[string]interface{}) {
+			dec := json.NewDecoder(bytes.NewBufferString(s))
+			for dec.More() {
+				blob := map[string]interface{}{}
+				Expect(dec.Decode(&blob)).To(Succeed())
+				blobs = append(blobs, blob)
+			}
+			return blobs
+		}
+
+		It("should write the bundle's package and channels", func() {
+			s, err := c.getPackageConfig()
+			Expect(err).NotTo(HaveOccurred())
+			blobs := decode(s)
+			Expect(blobs).To(HaveLen(3))
+			Expect(blobs[0]).To(Equal(map[string]interface{}{
+				"schema": fbc.SchemaPackage, "name": "memcached-operator", "defaultChannel": "stable",
+			}))
+			for i, ch := range []string{"alpha", "stable"} {
+				Expect(blobs[i+1]).To(Equal(map[string]interface{}{
+					"schema": fbc.SchemaChannel, "package": "memcached-operator", "name": ch,
+					"entries": []interface{}{map[string]interface{}{"name": "memcached-operator.v0.0.2"}},
+				}))
+			}
+		})
+		It("should default to the bundle's first channel", func() {
+			c.BundleDefaultChannel = ""
+			s, err := c.getPackageConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decode(s)[0]["defaultChannel"]).To(Equal("alpha"))
+		})
+		It("should write each dependency bundle's package and channels", func() {
+			c.DependencyBundles = []DependencyBundle{{
+				Image:       "quay.io/example/cert-manager-bundle:v1.0.0",
+				PackageName: "cert-manager",
+				CSVName:     "cert-manager.v1.0.0",
+				Channels:    []string{"stable"},
+			}}
+			s, err := c.getPackageConfig()
+			Expect(err).NotTo(HaveOccurred())
+			blobs := decode(s)
+			Expect(blobs).To(HaveLen(5))
+			Expect(blobs[1]).To(Equal(map[string]interface{}{
+				"schema": fbc.SchemaPackage, "name": "cert-manager", "defaultChannel": "stable",
+			}))
+			Expect(blobs[4]).To(Equal(map[string]interface{}{
+				"schema": fbc.SchemaChannel, "package": "cert-manager", "name": "stable",
+				"entries": []interface{}{map[string]interface{}{"name": "cert-manager.v1.0.0"}},
+			}))
+		})
+	})
+
+	Describe("getUpgradePackageConfig", func() {
+		prevConfig := func(channels ...string) string {
+			cfg := fbc.DeclarativeConfig{
+				Packages: []fbc.Package{{Schema: fbc.SchemaPackage, Name: "memcached-operator", DefaultChannel: channels[0]}},
+			}
+			for _, ch := range channels {
+				cfg.Channels = append(cfg.Channels, fbc.Channel{
+					Schema: fbc.SchemaChannel, Package: "memcached-operator", Name: ch,
+					Entries: []fbc.ChannelEntry{{Name: "memcached-operator.v0.0.1"}},
+				})
+			}
+			b, err := cfg.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+			return string(b)
+		}
+		read := func(s string) *fbc.DeclarativeConfig {
+			cfg, err := fbc.ReadJSON(bytes.NewBufferString(s))
+			Expect(err).NotTo(HaveOccurred())
+			return cfg
+		}
+
+		It("should add the bundle as the head of each of its channels", func() {
+			s, err := c.getUpgradePackageConfig(prevConfig("alpha"))
+			Expect(err).NotTo(HaveOccurred())
+			cfg := read(s)
+			Expect(cfg.Packages[0].DefaultChannel).To(Equal("stable"))
+			Expect(cfg.Channels).To(Equal([]fbc.Channel{
+				{Schema: fbc.SchemaChannel, Package: "memcached-operator", Name: "alpha", Entries: []fbc.ChannelEntry{
+					{Name: "memcached-operator.v0.0.2", Replaces: "memcached-operator.v0.0.1"},
+					{Name: "memcached-operator.v0.0.1"},
+				}},
+				{Schema: fbc.SchemaChannel, Package: "memcached-operator", Name: "stable", Entries: []fbc.ChannelEntry{
+					{Name: "memcached-operator.v0.0.2"},
+				}},
+			}))
+		})
+		It("should replace the previous head if the bundle's replaces is not in the channel", func() {
+			c.BundleChannels = []string{"alpha"}
+			c.BundleReplaces = "memcached-operator.v0.0.0"
+			s, err := c.getUpgradePackageConfig(prevConfig("alpha"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read(s).Channels[0].Entries[0]).To(Equal(fbc.ChannelEntry{
+				Name: "memcached-operator.v0.0.2", Replaces: "memcached-operator.v0.0.1",
+			}))
+		})
+		It("should leave dependency packages unchanged", func() {
+			prev := read(prevConfig("alpha"))
+			prev.Packages = append(prev.Packages, fbc.Package{Schema: fbc.SchemaPackage, Name: "cert-manager", DefaultChannel: "alpha"})
+			dep := fbc.Channel{Schema: fbc.SchemaChannel, Package: "cert-manager", Name: "alpha",
+				Entries: []fbc.ChannelEntry{{Name: "cert-manager.v1.0.0"}}}
+			prev.Channels = append(prev.Channels, dep)
+			b, err := prev.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+
+			s, err := c.getUpgradePackageConfig(string(b))
+			Expect(err).NotTo(HaveOccurred())
+			cfg := read(s)
+			Expect(cfg.Packages[1]).To(Equal(prev.Packages[1]))
+			Expect(cfg.Channels).To(ContainElement(dep))
+			Expect(cfg.Channels[0].Entries[0].Name).To(Equal("memcached-operator.v0.0.2"))
+		})
+		It("should fail if the previous config is for another package", func() {
+			c.PackageName = "other-operator"
+			_, err := c.getUpgradePackageConfig(prevConfig("alpha"))
+			Expect(err).To(MatchError(`previous package config is not for package "other-operator"`))
+		})
+	})
+
+	Describe("scheduling annotations", func() {
+		It("should be empty if unset", func() {
+			nodeSelector, tolerations, err := c.getSchedulingAnnotations()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nodeSelector).To(BeEmpty())
+			Expect(tolerations).To(BeEmpty())
+		})
+		It("should round-trip the node selector and tolerations", func() {
+			c.RegistryNodeSelector = map[string]string{"disktype": "ssd"}
+			c.RegistryTolerations = []corev1.Toleration{{Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}}
+			nodeSelector, tolerations, err := c.getSchedulingAnnotations()
+			Expect(err).NotTo(HaveOccurred())
+
+			updated := &IndexImageCatalogCreator{}
+			Expect(updated.setSchedulingFromAnnotations(map[string]string{
+				nodeSelectorAnnotation: nodeSelector,
+				tolerationsAnnotation:  tolerations,
+			})).To(Succeed())
+			Expect(updated.RegistryNodeSelector).To(Equal(c.RegistryNodeSelector))
+			Expect(updated.RegistryTolerations).To(Equal(c.RegistryTolerations))
+		})
+		It("should not override a node selector that is set", func() {
+			c.RegistryNodeSelector = map[string]string{"disktype": "ssd"}
+			Expect(c.setSchedulingFromAnnotations(map[string]string{nodeSelectorAnnotation: `{"zone":"a"}`})).To(Succeed())
+			Expect(c.RegistryNodeSelector).To(Equal(map[string]string{"disktype": "ssd"}))
+		})
+		It("should fail on a malformed annotation", func() {
+			Expect(c.setSchedulingFromAnnotations(map[string]string{tolerationsAnnotation: "{"})).To(
+				MatchError(ContainSubstring("error decoding " + tolerationsAnnotation)))
+		})
+	})
+
+	Describe("UpdateCatalog", func() {
+		var cs *v1alpha1.CatalogSource
+
+		BeforeEach(func() {
+			cs = newCatalogSource("memcached-operator-catalog", "testns")
+		})
+
+		It("should refuse a catalog not created by run bundle", func() {
+			Expect(c.UpdateCatalog(context.TODO(), cs)).To(MatchError(
+				`catalog source "memcached-operator-catalog" was not created by run bundle: ` +
+					`it has no operators.operatorframework.io/index-image annotation`))
+		})
+		It("should refuse a bundle the catalog already serves", func() {
+			cs.SetAnnotations(map[string]string{
+				indexImageAnnotation:      c.IndexImage,
+				injectedBundlesAnnotation: `["` + c.BundleImage + `"]`,
+			})
+			Expect(c.UpdateCatalog(context.TODO(), cs)).To(MatchError(
+				`bundle ` + c.BundleImage + ` is already served by catalog source "memcached-operator-catalog"`))
+		})
+	})
+})
@@ -15,12 +15,16 @@
 package registry
 
 import (
+	"context"
 	"fmt"
 
 	v1 "github.com/operator-framework/api/pkg/operators/v1"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
 	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
 	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
 )
 
@@ -74,10 +78,54 @@ func newSubscription(csvName, namespace string, opts ...func(*v1alpha1.Subscript
 	return sub
 }
 
+// newRegistryServiceAccount returns the SDK's registry ServiceAccount in namespace.
+func newRegistryServiceAccount(namespace string) *corev1.ServiceAccount {
+	sa := &corev1.ServiceAccount{}
+	sa.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ServiceAccount"))
+	sa.SetName(operator.SDKRegistryServiceAccountName)
+	sa.SetNamespace(namespace)
+	labels := make(map[string]string, len(configmap.SDKLabels))
+	for k, v := range configmap.SDKLabels {
+		labels[k] = v
+	}
+	sa.SetLabels(labels)
+	// Registries do not use the Kubernetes API, so are not given a token.
+	automountToken := false
+	sa.AutomountServiceAccountToken = &automountToken
+	return sa
+}
+
+// createRegistryServiceAccount creates the SDK's registry ServiceAccount in
+// cfg's namespace if it does not exist, and returns its name. It is shared by
+// the registry pods of every package, so it is not owned by a CatalogSource.
+func createRegistryServiceAccount(ctx context.Context, cfg *operator.Configuration) (string, error) {
+	sa := newRegistryServiceAccount(cfg.Namespace)
+	if err := cfg.Client.Create(ctx, sa); err != nil && !apierrors.IsAlreadyExists(err) {
+		return "", fmt.Errorf("error creating service account %q: %v", sa.GetName(), err)
+	}
+	return sa.GetName(), nil
+}
+
+// sdkPublisher is the publisher of CatalogSources created by the SDK.
+const sdkPublisher = "operator-sdk"
+
+// withSDKPublisher returns a function that marks the CatalogSource argument as
+// created by the SDK for pkgName, with the registry labels uninstall sweeps by.
 func withSDKPublisher(pkgName string) func(*v1alpha1.CatalogSource) {
 	return func(cs *v1alpha1.CatalogSource) {
 		cs.Spec.DisplayName = pkgName
-		cs.Spec.Publisher = "operator-sdk"
+		cs.Spec.Publisher = sdkPublisher
+		cs.SetLabels(configmap.MakeRegistryLabels(pkgName))
+	}
+}
+
+// withPullSecret returns a function that sets the CatalogSource argument's
+// secrets to pullSecret, used to pull its images, if pullSecret is set.
+func withPullSecret(pullSecret string) func(*v1alpha1.CatalogSource) {
+	return func(cs *v1alpha1.CatalogSource) {
+		if pullSecret != "" {
+			cs.Spec.Secrets = []string{pullSecret}
+		}
 	}
 }
 
@@ -17,14 +17,20 @@ package registry
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	v1 "github.com/operator-framework/api/pkg/operators/v1"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -33,27 +39,266 @@ import (
 )
 
 type OperatorInstaller struct {
-	CatalogSourceName     string
-	PackageName           string
-	StartingCSV           string
-	Channel               string
-	InstallMode           operator.InstallMode
+	CatalogSourceName string
+	PackageName       string
+	StartingCSV       string
+	Channel           string
+	InstallMode       operator.InstallMode
+	// WatchNamespaces, if set, are the namespaces the operator watches, from
+	// which InstallMode is inferred by ResolveInstallMode.
+	WatchNamespaces       operator.WatchNamespaces
 	CatalogCreator        CatalogCreator
 	SupportedInstallModes sets.String
+	// AdditionalPackages are subscribed to from the same catalog after
+	// PackageName. OLM resolves any dependencies between them.
+	AdditionalPackages []PackageSubscription
+	// DependencyCSVs are CSVs served by the catalog that OLM installs when resolving
+	// PackageName's dependencies, without a Subscription created by o. They are waited
+	// for along with StartingCSV.
+	DependencyCSVs []string
+	// CatalogUpdater adds the bundle being upgraded to in UpgradeOperator.
+	CatalogUpdater CatalogUpdater
+
+	// CatalogReadyTimeout bounds the time spent creating the catalog and waiting
+	// for its registry to serve. Defaults to 40% of the overall deadline.
+	CatalogReadyTimeout time.Duration
+	// SubscriptionResolveTimeout bounds the time spent waiting for OLM to resolve
+	// the Subscription into an InstallPlan. Defaults to 20% of the overall deadline.
+	SubscriptionResolveTimeout time.Duration
+	// CSVSucceededTimeout bounds the time spent waiting for the CSV to reach the
+	// Succeeded phase. Defaults to the remainder of the overall deadline.
+	CSVSucceededTimeout time.Duration
 
 	cfg *operator.Configuration
+	// result is recorded by InstallOperator.
+	result *InstallResult
+}
+
+// PackageSubscription identifies a package's channel and starting CSV to
+// subscribe to.
+type PackageSubscription struct {
+	PackageName string
+	Channel     string
+	StartingCSV string
+}
+
+// Install stage names, used in StageTimeoutError.
+const (
+	StageCatalog      = "catalog"
+	StageSubscription = "subscription"
+	StageCSV          = "csv"
+	// StageBundleUnpack is reported instead of StageCSV if the CSV stage times
+	// out before OLM has unpacked the bundle creating the CSV.
+	StageBundleUnpack = "bundle unpack"
+)
+
+// StageTimeoutError is returned when an install stage does not complete within
+// its deadline.
+type StageTimeoutError struct {
+	Stage   string
+	Timeout time.Duration
+	// LastCondition describes the last observed state of the stage's object.
+	LastCondition string
+	Err           error
+}
+
+func (e *StageTimeoutError) Error() string {
+	return fmt.Sprintf("%s stage timed out after %s (last observed condition: %s): %v",
+		e.Stage, e.Timeout, e.LastCondition, e.Err)
+}
+
+func (e *StageTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// stageDeadlines holds the resolved timeout of each install stage. A zero value
+// means the stage is bound only by the parent context.
+type stageDeadlines struct {
+	catalog, subscription, csv time.Duration
+}
+
+// getStageDeadlines resolves per-stage timeouts, defaulting unset ones to a share
+// of ctx's remaining deadline.
+func (o OperatorInstaller) getStageDeadlines(ctx context.Context) stageDeadlines {
+	d := stageDeadlines{
+		catalog:      o.CatalogReadyTimeout,
+		subscription: o.SubscriptionResolveTimeout,
+		csv:          o.CSVSucceededTimeout,
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return d
+	}
+	total := time.Until(deadline)
+	if d.catalog == 0 {
+		d.catalog = total * 2 / 5
+	}
+	if d.subscription == 0 {
+		d.subscription = total / 5
+	}
+	return d
+}
+
+// withStageTimeout returns a context bound by timeout, or ctx itself if timeout is zero,
+// and the time the stage has to complete, which is ctx's remaining time if shorter.
+func withStageTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc, time.Duration) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); timeout <= 0 || remaining < timeout {
+			timeout = remaining
+		}
+	}
+	if timeout <= 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, 0
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, timeout
+}
+
+// stageDescriber returns the furthest stage reached and a description of the
+// last observed state of that stage's object.
+type stageDescriber func(context.Context) (stage, condition string)
+
+// describeStage returns a stageDescriber for a stage whose object is described by lastCondition.
+func describeStage(stage string, lastCondition func(context.Context) string) stageDescriber {
+	return func(ctx context.Context) (string, string) {
+		return stage, lastCondition(ctx)
+	}
+}
+
+// stageError wraps err in a StageTimeoutError if stageCtx's deadline was exceeded.
+// describe is called with a fresh context, since the parent context may
+// have expired as well.
+func stageError(stageCtx context.Context, timeout time.Duration, describe stageDescriber, err error) error {
+	if stageCtx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stage, condition := describe(ctx)
+	return &StageTimeoutError{
+		Stage:         stage,
+		Timeout:       timeout,
+		LastCondition: condition,
+		Err:           err,
+	}
 }
 
 func NewOperatorInstaller(cfg *operator.Configuration) *OperatorInstaller {
-	return &OperatorInstaller{cfg: cfg}
+	return &OperatorInstaller{cfg: cfg, result: &InstallResult{}}
+}
+
+// BindStageTimeoutFlags binds flags setting o's per-stage timeouts, which are
+// each bound by the overall --timeout.
+func (o *OperatorInstaller) BindStageTimeoutFlags(fs *pflag.FlagSet) {
+	fs.DurationVar(&o.CatalogReadyTimeout, "catalog-ready-timeout", 0,
+		"Time to wait for the catalog to be created and its registry to serve. Defaults to 40% of --timeout")
+	fs.DurationVar(&o.SubscriptionResolveTimeout, "subscription-resolve-timeout", 0,
+		"Time to wait for OLM to resolve the Subscription into an InstallPlan. Defaults to 20% of --timeout")
+	fs.DurationVar(&o.CSVSucceededTimeout, "csv-succeeded-timeout", 0,
+		"Time to wait for the CSV to reach the Succeeded phase. Defaults to the remainder of --timeout")
+}
+
+// Result returns the objects created and stage durations recorded by the last
+// call to InstallOperator.
+func (o OperatorInstaller) Result() *InstallResult {
+	return o.result
+}
+
+// OptionRules returns the rules constraining o's fields. Package, channel, and
+// catalog fields are set from the operator's manifests by o's embedding struct.
+func (o OperatorInstaller) OptionRules() operator.OptionRules {
+	installMode := operator.Option{Field: "InstallMode", Flag: "--install-mode", IsSet: func() bool { return !o.InstallMode.IsEmpty() }}
+	timeouts := []operator.Option{
+		{Field: "CatalogReadyTimeout", Flag: "--catalog-ready-timeout", IsSet: func() bool { return o.CatalogReadyTimeout != 0 }},
+		{Field: "SubscriptionResolveTimeout", Flag: "--subscription-resolve-timeout", IsSet: func() bool { return o.SubscriptionResolveTimeout != 0 }},
+		{Field: "CSVSucceededTimeout", Flag: "--csv-succeeded-timeout", IsSet: func() bool { return o.CSVSucceededTimeout != 0 }},
+	}
+	return operator.OptionRules{
+		Rules: []operator.OptionRule{
+			operator.Constraint(o.InstallMode.Validate, installMode),
+			operator.MutuallyExclusive(installMode,
+				operator.Option{Field: "WatchNamespaces", Flag: "--watch-namespaces", IsSet: o.WatchNamespaces.IsSet}),
+			operator.Constraint(func() error {
+				for _, d := range []time.Duration{o.CatalogReadyTimeout, o.SubscriptionResolveTimeout, o.CSVSucceededTimeout} {
+					if d < 0 {
+						return fmt.Errorf("stage timeouts must not be negative")
+					}
+				}
+				return nil
+			}, timeouts...),
+		},
+		Unconstrained: []string{
+			"CatalogSourceName", "PackageName", "StartingCSV", "Channel", "CatalogCreator", "CatalogUpdater",
+			"SupportedInstallModes", "AdditionalPackages", "DependencyCSVs",
+		},
+	}
+}
+
+// GetPackageName returns the name of the package being installed.
+func (o OperatorInstaller) GetPackageName() string {
+	return o.PackageName
+}
+
+// ResolveInstallMode sets o.SupportedInstallModes from csv and infers
+// o.InstallMode from o.WatchNamespaces if set, then checks that it is
+// compatible with csv, so incompatible install modes are rejected before
+// any resources are created.
+func (o *OperatorInstaller) ResolveInstallMode(csv *v1alpha1.ClusterServiceVersion) error {
+	o.SupportedInstallModes = operator.GetSupportedInstallModes(csv.Spec.InstallModes)
+	if o.SupportedInstallModes.Len() == 0 {
+		return fmt.Errorf("operator %q is not installable: no supported install modes", csv.GetName())
+	}
+	if o.WatchNamespaces.IsSet() {
+		mode, err := operator.InferInstallMode(o.WatchNamespaces.Namespaces, o.cfg.Namespace, csv)
+		if err != nil {
+			return err
+		}
+		o.InstallMode = mode
+	}
+	if err := o.InstallMode.CheckCompatibility(csv, o.cfg.Namespace); err != nil {
+		return err
+	}
+	if !o.InstallMode.IsEmpty() && !o.SupportedInstallModes.Has(string(o.InstallMode.InstallModeType)) {
+		return fmt.Errorf("operator %q does not support install mode %s; supported install modes: %s",
+			csv.GetName(), o.InstallMode.InstallModeType, strings.Join(o.SupportedInstallModes.List(), ", "))
+	}
+	return nil
+}
+
+// GetAdditionalPackageNames returns the names of o.AdditionalPackages in
+// the order they are subscribed to.
+func (o OperatorInstaller) GetAdditionalPackageNames() []string {
+	names := make([]string, len(o.AdditionalPackages))
+	for i, p := range o.AdditionalPackages {
+		names[i] = p.PackageName
+	}
+	return names
+}
+
+// getPackageSubscriptions returns the packages o subscribes to, in order.
+func (o OperatorInstaller) getPackageSubscriptions() []PackageSubscription {
+	main := PackageSubscription{PackageName: o.PackageName, Channel: o.Channel, StartingCSV: o.StartingCSV}
+	return append([]PackageSubscription{main}, o.AdditionalPackages...)
 }
 
 func (o OperatorInstaller) InstallOperator(ctx context.Context) (*v1alpha1.ClusterServiceVersion, error) {
-	cs, err := o.CatalogCreator.CreateCatalog(ctx, o.CatalogSourceName)
+	if err := o.checkOLMInstalled(ctx); err != nil {
+		return nil, err
+	}
+	deadlines := o.getStageDeadlines(ctx)
+
+	endStage := o.result.beginStage(StageCatalog)
+	catCtx, catCancel, catTimeout := withStageTimeout(ctx, deadlines.catalog)
+	defer catCancel()
+	cs, err := o.CatalogCreator.CreateCatalog(catCtx, o.CatalogSourceName)
 	if err != nil {
-		return nil, fmt.Errorf("create catalog: %v", err)
+		err = stageError(catCtx, catTimeout, describeStage(StageCatalog, o.getCatalogCondition), err)
+		return nil, fmt.Errorf("create catalog: %w", err)
 	}
 	log.Infof("Created CatalogSource: %s", cs.GetName())
+	o.result.setCatalogSource(cs)
+	endStage()
 
 	// TODO: OLM doesn't appear to propagate the "READY" connection status to the
 	// catalogsource in a timely manner even though its catalog-operator reports
@@ -66,37 +311,195 @@ func (o OperatorInstaller) InstallOperator(ctx context.Context) (*v1alpha1.Clust
 	// }
 
 	// Ensure Operator Group
+	endStage = o.result.beginStage(StageSubscription)
 	if err = o.ensureOperatorGroup(ctx); err != nil {
 		return nil, err
 	}
 
-	var subscription *v1alpha1.Subscription
-	// Create Subscription
-	if subscription, err = o.createSubscription(ctx, cs); err != nil {
-		return nil, err
+	// Create a Subscription for each package
+	pkgSubs := o.getPackageSubscriptions()
+	subscriptions := make([]*v1alpha1.Subscription, len(pkgSubs))
+	for i, ps := range pkgSubs {
+		if subscriptions[i], err = o.createSubscription(ctx, cs, ps); err != nil {
+			return nil, err
+		}
+		o.result.addSubscription(subscriptions[i])
 	}
 
-	// Wait for the Install Plan to be generated
-	if err = o.waitForInstallPlan(ctx, subscription); err != nil {
-		return nil, err
+	// Wait for the Install Plans to be generated
+	subCtx, subCancel, subTimeout := withStageTimeout(ctx, deadlines.subscription)
+	defer subCancel()
+	for _, subscription := range subscriptions {
+		subscription := subscription
+		if err = o.waitForInstallPlan(subCtx, subscription); err != nil {
+			return nil, stageError(subCtx, subTimeout, describeStage(StageSubscription, func(context.Context) string {
+				return getSubscriptionCondition(subscription)
+			}), err)
+		}
 	}
 
-	// Approve Install Plan for the subscription
-	if err = o.approveInstallPlan(ctx, subscription); err != nil {
-		return nil, err
+	// Approve Install Plans for the subscriptions
+	for _, subscription := range subscriptions {
+		if err = o.approveInstallPlan(ctx, subscription); err != nil {
+			return nil, err
+		}
+	}
+	endStage()
+
+	// Wait for successfully installed CSVs
+	endStage = o.result.beginStage(StageCSV)
+	csvCtx, csvCancel, csvTimeout := withStageTimeout(ctx, deadlines.csv)
+	defer csvCancel()
+	var csv *v1alpha1.ClusterServiceVersion
+	for i, ps := range pkgSubs {
+		installed, err := o.getInstalledCSV(csvCtx, ps.StartingCSV)
+		if err != nil {
+			subscription := subscriptions[i]
+			return nil, stageError(csvCtx, csvTimeout, func(ctx context.Context) (string, string) {
+				return o.getCSVStageCondition(ctx, subscription, ps.StartingCSV)
+			}, err)
+		}
+		if i == 0 {
+			csv = installed
+		}
+		log.Infof("OLM has successfully installed %q", ps.StartingCSV)
 	}
+	for _, name := range o.DependencyCSVs {
+		name := name
+		if _, err := o.getInstalledCSV(csvCtx, name); err != nil {
+			return nil, stageError(csvCtx, csvTimeout, func(ctx context.Context) (string, string) {
+				return o.getCSVStageCondition(ctx, subscriptions[0], name)
+			}, err)
+		}
+		log.Infof("OLM has successfully installed dependency %q", name)
+	}
+	endStage()
+	o.recordInstalledCSV(ctx, cs, csv.GetName())
+	o.result.setCSV(csv)
 
-	// Wait for successfully installed CSV
-	csv, err := o.getInstalledCSV(ctx)
+	return csv, nil
+}
+
+// UpgradeOperator upgrades the operator subscribed to PackageName from an SDK-managed catalog
+// to StartingCSV, by adding StartingCSV's bundle to the catalog with o.CatalogUpdater and
+// approving the InstallPlan OLM creates for the upgrade.
+func (o OperatorInstaller) UpgradeOperator(ctx context.Context) (*v1alpha1.ClusterServiceVersion, error) {
+	if err := o.checkOLMInstalled(ctx); err != nil {
+		return nil, err
+	}
+	sub, cs, err := o.getUpgradeSubscription(ctx)
 	if err != nil {
 		return nil, err
 	}
+	prevCSV, prevInstallPlan := sub.Status.InstalledCSV, sub.Status.InstallPlanRef
+	o.CatalogSourceName = cs.GetName()
+	deadlines := o.getStageDeadlines(ctx)
+
+	catCtx, catCancel, catTimeout := withStageTimeout(ctx, deadlines.catalog)
+	defer catCancel()
+	if err := o.CatalogUpdater.UpdateCatalog(catCtx, cs); err != nil {
+		err = stageError(catCtx, catTimeout, describeStage(StageCatalog, o.getCatalogCondition), err)
+		return nil, fmt.Errorf("update catalog: %w", err)
+	}
+	log.Infof("Updated CatalogSource: %s", cs.GetName())
+
+	// Wait for OLM to resolve the upgrade from the updated catalog
+	subCtx, subCancel, subTimeout := withStageTimeout(ctx, deadlines.subscription)
+	defer subCancel()
+	if err := o.waitForUpgradeInstallPlan(subCtx, sub, prevInstallPlan); err != nil {
+		return nil, stageError(subCtx, subTimeout, describeStage(StageSubscription, func(context.Context) string {
+			return getSubscriptionCondition(sub)
+		}), err)
+	}
+	if err := o.approveInstallPlan(ctx, sub); err != nil {
+		return nil, err
+	}
 
-	log.Infof("OLM has successfully installed %q", o.StartingCSV)
+	// Wait for the new CSV to succeed, and OLM to replace the previous one
+	csvCtx, csvCancel, csvTimeout := withStageTimeout(ctx, deadlines.csv)
+	defer csvCancel()
+	csv, err := o.getInstalledCSV(csvCtx, o.StartingCSV)
+	if err != nil {
+		return nil, stageError(csvCtx, csvTimeout, func(ctx context.Context) (string, string) {
+			return o.getCSVStageCondition(ctx, sub, o.StartingCSV)
+		}, err)
+	}
+	if prevCSV != "" {
+		if err := o.waitForCSVReplaced(csvCtx, prevCSV); err != nil {
+			return nil, stageError(csvCtx, csvTimeout, describeStage(StageCSV, func(ctx context.Context) string {
+				return o.getCSVCondition(ctx, prevCSV)
+			}), err)
+		}
+	}
+	log.Infof("OLM has successfully upgraded %q to %q", prevCSV, o.StartingCSV)
+	o.recordInstalledCSV(ctx, cs, csv.GetName())
 
 	return csv, nil
 }
 
+// getUpgradeSubscription returns the Subscription to o.PackageName in o's namespace
+// and the CatalogSource it subscribes from, which must have been created by the SDK.
+func (o OperatorInstaller) getUpgradeSubscription(ctx context.Context) (*v1alpha1.Subscription, *v1alpha1.CatalogSource, error) {
+	subList := &v1alpha1.SubscriptionList{}
+	if err := o.cfg.Client.List(ctx, subList, client.InNamespace(o.cfg.Namespace)); err != nil {
+		return nil, nil, fmt.Errorf("error listing subscriptions: %v", err)
+	}
+	var sub *v1alpha1.Subscription
+	for i := range subList.Items {
+		if s := &subList.Items[i]; s.Spec != nil && s.Spec.Package == o.PackageName {
+			sub = s
+			break
+		}
+	}
+	if sub == nil {
+		return nil, nil, fmt.Errorf("no subscription to package %q found in namespace %q", o.PackageName, o.cfg.Namespace)
+	}
+	if sub.Status.InstalledCSV == o.StartingCSV {
+		return nil, nil, fmt.Errorf("operator %q is already installed", o.StartingCSV)
+	}
+
+	csKey := types.NamespacedName{Namespace: sub.Spec.CatalogSourceNamespace, Name: sub.Spec.CatalogSource}
+	if csKey.Namespace == "" {
+		csKey.Namespace = o.cfg.Namespace
+	}
+	cs := &v1alpha1.CatalogSource{}
+	if err := o.cfg.Client.Get(ctx, csKey, cs); err != nil {
+		return nil, nil, fmt.Errorf("error getting catalog source %q of subscription %q: %v", csKey, sub.GetName(), err)
+	}
+	if cs.Spec.Publisher != sdkPublisher {
+		return nil, nil, fmt.Errorf("package %q was not installed from an SDK-managed catalog: catalog source %q has publisher %q",
+			o.PackageName, csKey, cs.Spec.Publisher)
+	}
+	return sub, cs, nil
+}
+
+// RenderInstall returns the objects InstallOperator would create, in order,
+// without contacting the cluster. Since existing OperatorGroups can't be
+// listed, the SDK's OperatorGroup is always rendered; InstallOperator uses an
+// existing OperatorGroup instead if it is compatible with o.InstallMode.
+func (o OperatorInstaller) RenderInstall() ([]runtime.Object, error) {
+	r, ok := o.CatalogCreator.(CatalogRenderer)
+	if !ok {
+		return nil, fmt.Errorf("catalog creator %T does not support dry runs", o.CatalogCreator)
+	}
+	objs, err := r.RenderCatalog(o.CatalogSourceName)
+	if err != nil {
+		return nil, fmt.Errorf("render catalog: %w", err)
+	}
+
+	targetNamespaces, err := o.getOperatorGroupTargetNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	og := newSDKOperatorGroup(o.cfg.Namespace, withTargetNamespaces(targetNamespaces...))
+
+	objs = append(objs, og)
+	for _, ps := range o.getPackageSubscriptions() {
+		objs = append(objs, o.newSubscription(o.CatalogSourceName, o.cfg.Namespace, ps))
+	}
+	return objs, nil
+}
+
 //nolint:unused
 func (o OperatorInstaller) waitForCatalogSource(ctx context.Context, cs *v1alpha1.CatalogSource) error {
 	catSrcKey, err := client.ObjectKeyFromObject(cs)
@@ -131,22 +534,7 @@ func (o OperatorInstaller) ensureOperatorGroup(ctx context.Context) error {
 		return err
 	}
 
-	supported := o.SupportedInstallModes
-
-	// --install-mode was given
-	if !o.InstallMode.IsEmpty() {
-		if o.InstallMode.InstallModeType == v1alpha1.InstallModeTypeSingleNamespace &&
-			o.InstallMode.TargetNamespaces[0] == o.cfg.Namespace {
-			return fmt.Errorf("use install mode %q to watch operator's namespace %q", v1alpha1.InstallModeTypeOwnNamespace, o.cfg.Namespace)
-		}
-
-		supported = supported.Intersection(sets.NewString(string(o.InstallMode.InstallModeType)))
-		if supported.Len() == 0 {
-			return fmt.Errorf("operator %q does not support install mode %q", o.StartingCSV, o.InstallMode.InstallModeType)
-		}
-	}
-
-	targetNamespaces, err := o.getTargetNamespaces(supported)
+	targetNamespaces, err := o.getOperatorGroupTargetNamespaces()
 	if err != nil {
 		return err
 	}
@@ -163,6 +551,28 @@ func (o OperatorInstaller) ensureOperatorGroup(ctx context.Context) error {
 	return nil
 }
 
+// getOperatorGroupTargetNamespaces validates o.InstallMode against the
+// operator's supported install modes, and returns the target namespaces of
+// an OperatorGroup satisfying them.
+func (o OperatorInstaller) getOperatorGroupTargetNamespaces() ([]string, error) {
+	supported := o.SupportedInstallModes
+
+	// --install-mode was given
+	if !o.InstallMode.IsEmpty() {
+		if o.InstallMode.InstallModeType == v1alpha1.InstallModeTypeSingleNamespace &&
+			o.InstallMode.TargetNamespaces[0] == o.cfg.Namespace {
+			return nil, fmt.Errorf("use install mode %q to watch operator's namespace %q", v1alpha1.InstallModeTypeOwnNamespace, o.cfg.Namespace)
+		}
+
+		supported = supported.Intersection(sets.NewString(string(o.InstallMode.InstallModeType)))
+		if supported.Len() == 0 {
+			return nil, fmt.Errorf("operator %q does not support install mode %q", o.StartingCSV, o.InstallMode.InstallModeType)
+		}
+	}
+
+	return o.getTargetNamespaces(supported)
+}
+
 func (o *OperatorInstaller) createOperatorGroup(ctx context.Context, targetNamespaces []string) (*v1.OperatorGroup, error) {
 	og := newSDKOperatorGroup(o.cfg.Namespace, withTargetNamespaces(targetNamespaces...))
 	if err := o.cfg.Client.Create(ctx, og); err != nil {
@@ -208,12 +618,12 @@ func (o OperatorInstaller) getOperatorGroup(ctx context.Context) (*v1.OperatorGr
 	return &ogList.Items[0], true, nil
 }
 
-func (o OperatorInstaller) createSubscription(ctx context.Context, cs *v1alpha1.CatalogSource) (*v1alpha1.Subscription, error) {
-	sub := newSubscription(o.StartingCSV, o.cfg.Namespace,
-		withPackageChannel(o.PackageName, o.Channel, o.StartingCSV),
-		withCatalogSource(cs.GetName(), o.cfg.Namespace),
-		withInstallPlanApproval(v1alpha1.ApprovalManual))
-
+func (o OperatorInstaller) createSubscription(ctx context.Context, cs *v1alpha1.CatalogSource, ps PackageSubscription) (*v1alpha1.Subscription, error) {
+	sub := o.newSubscription(cs.GetName(), cs.GetNamespace(), ps)
+	// Catalogs the SDK did not create must outlive the operators installed from them.
+	if cs.Spec.Publisher != sdkPublisher {
+		sub.SetAnnotations(map[string]string{operator.ExistingCatalogSourceAnnotation: "true"})
+	}
 	if err := o.cfg.Client.Create(ctx, sub); err != nil {
 		return nil, fmt.Errorf("error creating subscription: %w", err)
 	}
@@ -222,7 +632,44 @@ func (o OperatorInstaller) createSubscription(ctx context.Context, cs *v1alpha1.
 	return sub, nil
 }
 
-func (o OperatorInstaller) getInstalledCSV(ctx context.Context) (*v1alpha1.ClusterServiceVersion, error) {
+// recordInstalledCSV sets operator.InstalledCSVAnnotation on cs to csvName if cs was
+// created by the SDK. Failures are only logged, since the install has succeeded.
+func (o OperatorInstaller) recordInstalledCSV(ctx context.Context, cs *v1alpha1.CatalogSource, csvName string) {
+	if cs.Spec.Publisher != sdkPublisher {
+		return
+	}
+	patch := client.MergeFrom(cs.DeepCopy())
+	annotations := cs.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[operator.InstalledCSVAnnotation] = csvName
+	cs.SetAnnotations(annotations)
+	if err := o.cfg.Client.Patch(ctx, cs, patch); err != nil {
+		log.Warnf("Failed to record installed CSV %q on CatalogSource %q: %v", csvName, cs.GetName(), err)
+	}
+}
+
+func (o OperatorInstaller) newSubscription(catalogSourceName, catalogSourceNamespace string, ps PackageSubscription) *v1alpha1.Subscription {
+	return newSubscription(ps.StartingCSV, o.cfg.Namespace,
+		withPackageChannel(ps.PackageName, ps.Channel, ps.StartingCSV),
+		withCatalogSource(catalogSourceName, catalogSourceNamespace),
+		withInstallPlanApproval(v1alpha1.ApprovalManual))
+}
+
+// checkOLMInstalled returns an error wrapping olmclient.ErrOLMNotInstalled if
+// OLM is not installed or is unhealthy, so users get guidance instead of
+// "no matches for kind" errors on the first create.
+func (o OperatorInstaller) checkOLMInstalled(ctx context.Context) error {
+	dc, err := discovery.NewDiscoveryClientForConfig(o.cfg.RESTConfig)
+	if err != nil {
+		return fmt.Errorf("error creating discovery client: %v", err)
+	}
+	c := olmclient.Client{KubeClient: o.cfg.Client}
+	return c.CheckOLMInstalled(ctx, dc)
+}
+
+func (o OperatorInstaller) getInstalledCSV(ctx context.Context, csvName string) (*v1alpha1.ClusterServiceVersion, error) {
 	c, err := olmclient.NewClientForConfig(o.cfg.RESTConfig)
 	if err != nil {
 		return nil, err
@@ -231,7 +678,7 @@ func (o OperatorInstaller) getInstalledCSV(ctx context.Context) (*v1alpha1.Clust
 	// BUG(estroz): if namespace is not contained in targetNamespaces,
 	// DoCSVWait will fail because the CSV is not deployed in namespace.
 	nn := types.NamespacedName{
-		Name:      o.StartingCSV,
+		Name:      csvName,
 		Namespace: o.cfg.Namespace,
 	}
 	log.Infof("Waiting for ClusterServiceVersion %q to reach 'Succeeded' phase", nn)
@@ -247,6 +694,86 @@ func (o OperatorInstaller) getInstalledCSV(ctx context.Context) (*v1alpha1.Clust
 	return csv, nil
 }
 
+// getCatalogCondition describes the state of the objects serving the catalog
+// if o.CatalogCreator can, otherwise the state of the catalog source.
+func (o OperatorInstaller) getCatalogCondition(ctx context.Context) string {
+	if c, ok := o.CatalogCreator.(CatalogConditioner); ok {
+		return c.GetCatalogCondition(ctx, o.CatalogSourceName)
+	}
+	return o.getCatalogSourceCondition(ctx)
+}
+
+// getCatalogSourceCondition describes the connection state of the catalog source.
+func (o OperatorInstaller) getCatalogSourceCondition(ctx context.Context) string {
+	cs := &v1alpha1.CatalogSource{}
+	key := types.NamespacedName{Namespace: o.cfg.Namespace, Name: o.CatalogSourceName}
+	if err := o.cfg.Client.Get(ctx, key, cs); err != nil {
+		return fmt.Sprintf("error getting catalog source %q: %v", key, err)
+	}
+	if state := cs.Status.GRPCConnectionState; state != nil && state.LastObservedState != "" {
+		return fmt.Sprintf("catalog source %q connection state %q", key, state.LastObservedState)
+	}
+	return fmt.Sprintf("catalog source %q has no connection state", key)
+}
+
+// getSubscriptionCondition describes the most recent condition of sub.
+func getSubscriptionCondition(sub *v1alpha1.Subscription) string {
+	conds := sub.Status.Conditions
+	if len(conds) == 0 {
+		return fmt.Sprintf("subscription %q has no conditions (state %q)", sub.GetName(), sub.Status.State)
+	}
+	last := conds[len(conds)-1]
+	return fmt.Sprintf("subscription %q condition %s=%s: %s: %s",
+		sub.GetName(), last.Type, last.Status, last.Reason, last.Message)
+}
+
+// getCSVCondition describes the phase of the CSV named csvName.
+func (o OperatorInstaller) getCSVCondition(ctx context.Context, csvName string) string {
+	csv := &v1alpha1.ClusterServiceVersion{}
+	key := types.NamespacedName{Namespace: o.cfg.Namespace, Name: csvName}
+	if err := o.cfg.Client.Get(ctx, key, csv); err != nil {
+		return fmt.Sprintf("error getting clusterserviceversion %q: %v", key, err)
+	}
+	return fmt.Sprintf("clusterserviceversion %q phase %q: %s: %s",
+		key, csv.Status.Phase, csv.Status.Reason, csv.Status.Message)
+}
+
+// getCSVStageCondition returns StageCSV and the CSV named csvName's phase if the CSV
+// exists. Otherwise OLM has not yet unpacked the bundle sub's InstallPlan installs
+// the CSV from, so StageBundleUnpack and the InstallPlan's bundle lookups are returned.
+func (o OperatorInstaller) getCSVStageCondition(ctx context.Context, sub *v1alpha1.Subscription, csvName string) (string, string) {
+	csvKey := types.NamespacedName{Namespace: o.cfg.Namespace, Name: csvName}
+	if err := o.cfg.Client.Get(ctx, csvKey, &v1alpha1.ClusterServiceVersion{}); !apierrors.IsNotFound(err) {
+		return StageCSV, o.getCSVCondition(ctx, csvName)
+	}
+	if sub.Status.InstallPlanRef == nil {
+		return StageBundleUnpack, fmt.Sprintf("clusterserviceversion %q not found, and subscription %q has no install plan",
+			csvKey, sub.GetName())
+	}
+	ip := &v1alpha1.InstallPlan{}
+	ipKey := types.NamespacedName{Namespace: sub.Status.InstallPlanRef.Namespace, Name: sub.Status.InstallPlanRef.Name}
+	if err := o.cfg.Client.Get(ctx, ipKey, ip); err != nil {
+		return StageBundleUnpack, fmt.Sprintf("error getting install plan %q: %v", ipKey, err)
+	}
+	return StageBundleUnpack, getInstallPlanCondition(ip)
+}
+
+// getInstallPlanCondition describes the phase of ip and the most recent
+// condition of each of its bundle lookups, which are pending while OLM unpacks bundles.
+func getInstallPlanCondition(ip *v1alpha1.InstallPlan) string {
+	desc := fmt.Sprintf("installplan %q phase %q", ip.GetName(), ip.Status.Phase)
+	for _, lookup := range ip.Status.BundleLookups {
+		if n := len(lookup.Conditions); n != 0 {
+			last := lookup.Conditions[n-1]
+			desc += fmt.Sprintf("; bundle %q condition %s=%s: %s: %s",
+				lookup.Path, last.Type, last.Status, last.Reason, last.Message)
+		} else {
+			desc += fmt.Sprintf("; bundle %q has no conditions", lookup.Path)
+		}
+	}
+	return desc
+}
+
 // approveInstallPlan approves the install plan for a subscription, which will
 // generate a CSV
 func (o OperatorInstaller) approveInstallPlan(ctx context.Context, sub *v1alpha1.Subscription) error {
@@ -299,6 +826,56 @@ func (o OperatorInstaller) waitForInstallPlan(ctx context.Context, sub *v1alpha1
 	return nil
 }
 
+// waitForUpgradeInstallPlan waits for sub to reference an InstallPlan other than prev
+// that upgrades it to o.StartingCSV.
+func (o OperatorInstaller) waitForUpgradeInstallPlan(ctx context.Context, sub *v1alpha1.Subscription, prev *corev1.ObjectReference) error {
+	subKey := types.NamespacedName{
+		Namespace: sub.GetNamespace(),
+		Name:      sub.GetName(),
+	}
+
+	ipCheck := wait.ConditionFunc(func() (done bool, err error) {
+		if err := o.cfg.Client.Get(ctx, subKey, sub); err != nil {
+			return false, err
+		}
+		ref := sub.Status.InstallPlanRef
+		if ref == nil || (prev != nil && ref.Name == prev.Name) {
+			return false, nil
+		}
+		return sub.Status.CurrentCSV == o.StartingCSV, nil
+	})
+
+	if err := wait.PollImmediateUntil(200*time.Millisecond, ipCheck, ctx.Done()); err != nil {
+		return fmt.Errorf("install plan upgrading to %s is not available for the subscription %s: %v", o.StartingCSV, sub.Name, err)
+	}
+	return nil
+}
+
+// waitForCSVReplaced waits for OLM to replace the CSV named csvName, after which it is deleted.
+func (o OperatorInstaller) waitForCSVReplaced(ctx context.Context, csvName string) error {
+	key := types.NamespacedName{Namespace: o.cfg.Namespace, Name: csvName}
+
+	csvCheck := wait.ConditionFunc(func() (done bool, err error) {
+		csv := &v1alpha1.ClusterServiceVersion{}
+		if err := o.cfg.Client.Get(ctx, key, csv); err != nil {
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		switch csv.Status.Phase {
+		case v1alpha1.CSVPhaseReplacing, v1alpha1.CSVPhaseDeleting:
+			return true, nil
+		}
+		return false, nil
+	})
+
+	if err := wait.PollImmediateUntil(200*time.Millisecond, csvCheck, ctx.Done()); err != nil {
+		return fmt.Errorf("clusterserviceversion %q was not replaced: %v", key, err)
+	}
+	return nil
+}
+
 func (o *OperatorInstaller) getTargetNamespaces(supported sets.String) ([]string, error) {
 	switch {
 	case supported.Has(string(v1alpha1.InstallModeTypeAllNamespaces)):
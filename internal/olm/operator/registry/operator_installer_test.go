@@ -16,11 +16,14 @@ package registry
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	v1 "github.com/operator-framework/api/pkg/operators/v1"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
 	crclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -34,6 +37,55 @@ var _ = Describe("OperatorInstaller", func() {
 		// TODO: fill this in once run bundle is done
 	})
 
+	Describe("ResolveInstallMode", func() {
+		var (
+			oi  OperatorInstaller
+			csv *v1alpha1.ClusterServiceVersion
+		)
+		BeforeEach(func() {
+			oi = OperatorInstaller{cfg: &operator.Configuration{Namespace: "testns"}}
+			csv = &v1alpha1.ClusterServiceVersion{}
+			csv.SetName("memcached-operator.v0.0.1")
+			csv.Spec.InstallModes = []v1alpha1.InstallMode{
+				{Type: v1alpha1.InstallModeTypeOwnNamespace, Supported: true},
+				{Type: v1alpha1.InstallModeTypeSingleNamespace, Supported: true},
+				{Type: v1alpha1.InstallModeTypeAllNamespaces, Supported: false},
+			}
+		})
+
+		It("should set supported install modes", func() {
+			Expect(oi.ResolveInstallMode(csv)).To(Succeed())
+			Expect(oi.SupportedInstallModes.List()).To(Equal([]string{
+				string(v1alpha1.InstallModeTypeOwnNamespace), string(v1alpha1.InstallModeTypeSingleNamespace),
+			}))
+		})
+		It("should accept a supported install mode", func() {
+			Expect(oi.InstallMode.Set(string(v1alpha1.InstallModeTypeOwnNamespace))).To(Succeed())
+			Expect(oi.ResolveInstallMode(csv)).To(Succeed())
+		})
+		It("should infer the install mode from watch namespaces", func() {
+			Expect(oi.WatchNamespaces.Set("testns")).To(Succeed())
+			Expect(oi.ResolveInstallMode(csv)).To(Succeed())
+			Expect(oi.InstallMode.InstallModeType).To(Equal(v1alpha1.InstallModeTypeOwnNamespace))
+		})
+		It("should reject an install mode the CSV marks unsupported", func() {
+			Expect(oi.InstallMode.Set(string(v1alpha1.InstallModeTypeAllNamespaces))).To(Succeed())
+			Expect(oi.ResolveInstallMode(csv)).To(MatchError(
+				`install mode type "AllNamespaces" not supported in CSV "memcached-operator.v0.0.1"`))
+		})
+		It("should reject an install mode the CSV does not list", func() {
+			Expect(oi.InstallMode.Set(string(v1alpha1.InstallModeTypeMultiNamespace) + "=ns1,ns2")).To(Succeed())
+			Expect(oi.ResolveInstallMode(csv)).To(MatchError(
+				`operator "memcached-operator.v0.0.1" does not support install mode MultiNamespace; ` +
+					`supported install modes: OwnNamespace, SingleNamespace`))
+		})
+		It("should reject a CSV without supported install modes", func() {
+			csv.Spec.InstallModes = nil
+			Expect(oi.ResolveInstallMode(csv)).To(MatchError(
+				`operator "memcached-operator.v0.0.1" is not installable: no supported install modes`))
+		})
+	})
+
 	Describe("ensureOperatorGroup", func() {
 		var (
 			oi     OperatorInstaller
@@ -336,7 +388,40 @@ var _ = Describe("OperatorInstaller", func() {
 	})
 
 	Describe("createSubscription", func() {
-		// TODO: add them as part of a different story
+		var (
+			oi OperatorInstaller
+			cs *v1alpha1.CatalogSource
+			ps PackageSubscription
+		)
+
+		BeforeEach(func() {
+			sch := runtime.NewScheme()
+			Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+			oi = OperatorInstaller{cfg: &operator.Configuration{
+				Scheme:    sch,
+				Client:    fake.NewFakeClientWithScheme(sch),
+				Namespace: "testns",
+			}}
+			cs = newCatalogSource("memcached-operator-catalog", "testns", withSDKPublisher("memcached-operator"))
+			ps = PackageSubscription{PackageName: "memcached-operator", Channel: "alpha", StartingCSV: "memcached-operator.v0.0.1"}
+		})
+
+		It("should subscribe to the catalog source", func() {
+			sub, err := oi.createSubscription(context.TODO(), cs, ps)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sub.Spec.CatalogSource).To(Equal("memcached-operator-catalog"))
+			Expect(sub.Spec.CatalogSourceNamespace).To(Equal("testns"))
+			Expect(sub.GetAnnotations()).NotTo(HaveKey(operator.ExistingCatalogSourceAnnotation))
+		})
+		It("should mark catalog sources not created by the SDK", func() {
+			cs = newCatalogSource("admin-catalog", "olm")
+			sub, err := oi.createSubscription(context.TODO(), cs, ps)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sub.GetNamespace()).To(Equal("testns"))
+			Expect(sub.Spec.CatalogSource).To(Equal("admin-catalog"))
+			Expect(sub.Spec.CatalogSourceNamespace).To(Equal("olm"))
+			Expect(sub.GetAnnotations()).To(HaveKeyWithValue(operator.ExistingCatalogSourceAnnotation, "true"))
+		})
 	})
 
 	Describe("getTargetNamespaces", func() {
@@ -384,6 +469,205 @@ var _ = Describe("OperatorInstaller", func() {
 			Expect(err).To(BeNil())
 		})
 	})
+
+	Describe("getStageDeadlines", func() {
+		var oi OperatorInstaller
+		BeforeEach(func() {
+			oi = OperatorInstaller{cfg: &operator.Configuration{}}
+		})
+		It("should leave unset stages unbounded without a parent deadline", func() {
+			d := oi.getStageDeadlines(context.TODO())
+			Expect(d).To(Equal(stageDeadlines{}))
+		})
+		It("should default unset stages to a share of the parent deadline", func() {
+			ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Minute)
+			defer cancel()
+			d := oi.getStageDeadlines(ctx)
+			Expect(d.catalog).To(BeNumerically("~", 4*time.Minute, time.Second))
+			Expect(d.subscription).To(BeNumerically("~", 2*time.Minute, time.Second))
+			Expect(d.csv).To(BeZero())
+		})
+		It("should use explicitly set stage timeouts", func() {
+			oi.CatalogReadyTimeout = time.Second
+			oi.SubscriptionResolveTimeout = 2 * time.Second
+			oi.CSVSucceededTimeout = 3 * time.Second
+			ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Minute)
+			defer cancel()
+			d := oi.getStageDeadlines(ctx)
+			Expect(d).To(Equal(stageDeadlines{catalog: time.Second, subscription: 2 * time.Second, csv: 3 * time.Second}))
+		})
+	})
+
+	Describe("withStageTimeout", func() {
+		It("should not bound a stage without a timeout or parent deadline", func() {
+			ctx, cancel, timeout := withStageTimeout(context.TODO(), 0)
+			defer cancel()
+			_, ok := ctx.Deadline()
+			Expect(ok).To(BeFalse())
+			Expect(timeout).To(BeZero())
+		})
+		It("should bound a stage without a timeout by the parent deadline", func() {
+			parent, parentCancel := context.WithTimeout(context.TODO(), time.Minute)
+			defer parentCancel()
+			_, cancel, timeout := withStageTimeout(parent, 0)
+			defer cancel()
+			Expect(timeout).To(BeNumerically("~", time.Minute, time.Second))
+		})
+		It("should report the parent deadline if it is sooner than the stage's", func() {
+			parent, parentCancel := context.WithTimeout(context.TODO(), time.Minute)
+			defer parentCancel()
+			_, cancel, timeout := withStageTimeout(parent, time.Hour)
+			defer cancel()
+			Expect(timeout).To(BeNumerically("~", time.Minute, time.Second))
+		})
+	})
+
+	Describe("stageError", func() {
+		describe := describeStage(StageCatalog, func(context.Context) string { return "connection state \"CONNECTING\"" })
+		It("should return err unchanged if the stage did not time out", func() {
+			err := errors.New("boom")
+			Expect(stageError(context.TODO(), time.Second, describe, err)).To(Equal(err))
+		})
+		It("should name the stage and last condition on timeout", func() {
+			ctx, cancel := context.WithTimeout(context.TODO(), time.Nanosecond)
+			defer cancel()
+			<-ctx.Done()
+			err := stageError(ctx, time.Second, describe, errors.New("timed out waiting for the condition"))
+			stageErr := &StageTimeoutError{}
+			Expect(errors.As(err, &stageErr)).To(BeTrue())
+			Expect(stageErr.Stage).To(Equal(StageCatalog))
+			Expect(err.Error()).To(ContainSubstring("catalog stage timed out"))
+			Expect(err.Error()).To(ContainSubstring("CONNECTING"))
+		})
+	})
+
+	Describe("getCSVStageCondition", func() {
+		const csvName = "memcached-operator.v0.0.1"
+		var (
+			oi     OperatorInstaller
+			client crclient.Client
+			sub    *v1alpha1.Subscription
+			ip     *v1alpha1.InstallPlan
+		)
+		BeforeEach(func() {
+			sch := runtime.NewScheme()
+			Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+			client = fake.NewFakeClientWithScheme(sch)
+			oi = OperatorInstaller{cfg: &operator.Configuration{Scheme: sch, Client: client, Namespace: "testns"}}
+
+			ip = &v1alpha1.InstallPlan{}
+			ip.SetName("install-abcde")
+			ip.SetNamespace("testns")
+			ip.Status.Phase = v1alpha1.InstallPlanPhaseInstalling
+			ip.Status.BundleLookups = []v1alpha1.BundleLookup{{
+				Path: "quay.io/example/memcached-operator-bundle:v0.0.1",
+				Conditions: []v1alpha1.BundleLookupCondition{{
+					Type:    v1alpha1.BundleLookupPending,
+					Status:  corev1.ConditionTrue,
+					Reason:  "JobIncomplete",
+					Message: "unpack job not completed",
+				}},
+			}}
+			Expect(client.Create(context.TODO(), ip)).To(Succeed())
+			sub = &v1alpha1.Subscription{}
+			sub.SetName("memcached-operator-sub")
+			sub.SetNamespace("testns")
+			sub.Status.InstallPlanRef = &corev1.ObjectReference{Name: ip.GetName(), Namespace: ip.GetNamespace()}
+		})
+
+		It("should attribute a missing CSV to bundle unpacking", func() {
+			stage, condition := oi.getCSVStageCondition(context.TODO(), sub, csvName)
+			Expect(stage).To(Equal(StageBundleUnpack))
+			Expect(condition).To(Equal(`installplan "install-abcde" phase "Installing"; ` +
+				`bundle "quay.io/example/memcached-operator-bundle:v0.0.1" condition ` +
+				`BundleLookupPending=True: JobIncomplete: unpack job not completed`))
+		})
+		It("should attribute a CSV that never succeeds to the CSV stage", func() {
+			csv := &v1alpha1.ClusterServiceVersion{}
+			csv.SetName(csvName)
+			csv.SetNamespace("testns")
+			csv.Status.Phase = v1alpha1.CSVPhaseInstalling
+			csv.Status.Reason = v1alpha1.CSVReasonWaiting
+			csv.Status.Message = "installing: waiting for deployment memcached-operator to become ready"
+			Expect(client.Create(context.TODO(), csv)).To(Succeed())
+
+			stage, condition := oi.getCSVStageCondition(context.TODO(), sub, csvName)
+			Expect(stage).To(Equal(StageCSV))
+			Expect(condition).To(ContainSubstring(`phase "Installing"`))
+			Expect(condition).To(ContainSubstring("waiting for deployment memcached-operator to become ready"))
+		})
+		It("should report a never-ready CSV's stage on timeout", func() {
+			ctx, cancel := context.WithTimeout(context.TODO(), time.Nanosecond)
+			defer cancel()
+			<-ctx.Done()
+			err := stageError(ctx, time.Minute, func(ctx context.Context) (string, string) {
+				return oi.getCSVStageCondition(ctx, sub, csvName)
+			}, errors.New("timed out waiting for the condition"))
+			stageErr := &StageTimeoutError{}
+			Expect(errors.As(err, &stageErr)).To(BeTrue())
+			Expect(stageErr.Stage).To(Equal(StageBundleUnpack))
+			Expect(err.Error()).To(HavePrefix("bundle unpack stage timed out after 1m0s"))
+			Expect(err.Error()).To(ContainSubstring("unpack job not completed"))
+		})
+		It("should report a subscription without an install plan", func() {
+			sub.Status.InstallPlanRef = nil
+			stage, condition := oi.getCSVStageCondition(context.TODO(), sub, csvName)
+			Expect(stage).To(Equal(StageBundleUnpack))
+			Expect(condition).To(ContainSubstring("has no install plan"))
+		})
+	})
+
+	Describe("getUpgradeSubscription", func() {
+		var (
+			oi     OperatorInstaller
+			client crclient.Client
+			sub    *v1alpha1.Subscription
+			cs     *v1alpha1.CatalogSource
+		)
+		BeforeEach(func() {
+			sch := runtime.NewScheme()
+			Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+			client = fake.NewFakeClientWithScheme(sch)
+			oi = OperatorInstaller{
+				PackageName: "memcached-operator",
+				StartingCSV: "memcached-operator.v0.0.2",
+				cfg:         &operator.Configuration{Scheme: sch, Client: client, Namespace: "testns"},
+			}
+
+			cs = newCatalogSource("memcached-operator-catalog", "testns", withSDKPublisher("memcached-operator"))
+			sub = newSubscription("memcached-operator.v0.0.1", "testns",
+				withPackageChannel("memcached-operator", "alpha", "memcached-operator.v0.0.1"),
+				withCatalogSource(cs.GetName(), "testns"))
+			sub.Status.InstalledCSV = "memcached-operator.v0.0.1"
+		})
+
+		It("should return the subscription and its SDK-managed catalog", func() {
+			Expect(client.Create(context.TODO(), cs)).To(Succeed())
+			Expect(client.Create(context.TODO(), sub)).To(Succeed())
+			s, c, err := oi.getUpgradeSubscription(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.GetName()).To(Equal(sub.GetName()))
+			Expect(c.GetName()).To(Equal(cs.GetName()))
+		})
+		It("should fail if the package has no subscription", func() {
+			_, _, err := oi.getUpgradeSubscription(context.TODO())
+			Expect(err).To(MatchError(`no subscription to package "memcached-operator" found in namespace "testns"`))
+		})
+		It("should refuse an operator not installed from an SDK-managed catalog", func() {
+			cs.Spec.Publisher = "Red Hat"
+			Expect(client.Create(context.TODO(), cs)).To(Succeed())
+			Expect(client.Create(context.TODO(), sub)).To(Succeed())
+			_, _, err := oi.getUpgradeSubscription(context.TODO())
+			Expect(err).To(MatchError(`package "memcached-operator" was not installed from an SDK-managed catalog: ` +
+				`catalog source "testns/memcached-operator-catalog" has publisher "Red Hat"`))
+		})
+		It("should fail if the bundle is already installed", func() {
+			sub.Status.InstalledCSV = oi.StartingCSV
+			Expect(client.Create(context.TODO(), sub)).To(Succeed())
+			_, _, err := oi.getUpgradeSubscription(context.TODO())
+			Expect(err).To(MatchError(`operator "memcached-operator.v0.0.2" is already installed`))
+		})
+	})
 })
 
 func createOperatorGroupHelper(ctx context.Context, c crclient.Client, name, namespace string, targetNamespaces ...string) v1.OperatorGroup {
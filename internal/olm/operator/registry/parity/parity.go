@@ -0,0 +1,391 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package parity compares catalog content generated by operator-sdk for a
+// package manifests directory, in both the file-based catalog and ConfigMap
+// formats, with content generated for the same directory by operator-registry,
+// so discrepancies between locally-run and published catalogs can be caught in tests.
+package parity
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/operator-registry/pkg/sqlite"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/fbc"
+)
+
+// Catalog is a normalized view of catalog content that does not depend on
+// the format it was generated in.
+type Catalog struct {
+	Packages map[string]Package `json:"packages"`
+	// Bundles are keyed by CSV name.
+	Bundles map[string]Bundle `json:"bundles"`
+}
+
+type Package struct {
+	DefaultChannel string             `json:"defaultChannel"`
+	Channels       map[string]Channel `json:"channels"`
+}
+
+type Channel struct {
+	Head string `json:"head"`
+	// Entries are sorted CSV names of bundles in the channel.
+	Entries []string `json:"entries"`
+}
+
+type Bundle struct {
+	Package   string   `json:"package"`
+	Version   string   `json:"version"`
+	Replaces  string   `json:"replaces,omitempty"`
+	Skips     []string `json:"skips,omitempty"`
+	SkipRange string   `json:"skipRange,omitempty"`
+	// Objects are keyed by "<kind>/<name>".
+	Objects map[string]interface{} `json:"objects"`
+}
+
+// Allowlist contains paths of known-acceptable differences between catalogs.
+// Paths are slash-separated keys into a Catalog's JSON encoding, ex.
+// "bundles/memcached-operator.v0.0.1/skipRange", and "*" matches any key.
+type Allowlist []string
+
+// DefaultAllowlist contains differences between operator-sdk and
+// operator-registry that are known and do not affect installs. Neither of
+// operator-sdk's generators has such differences for the test fixtures.
+var DefaultAllowlist = Allowlist{}
+
+// Check generates catalogs for the package manifests in dir with each of
+// operator-sdk's generators and with operator-registry, and returns an error
+// containing a diff of each operator-sdk catalog that differs from
+// operator-registry's outside of allow.
+func Check(ctx context.Context, dir string, allow Allowlist) error {
+	registryCatalog, err := NewRegistryCatalog(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("error generating operator-registry catalog: %v", err)
+	}
+	generators := []struct {
+		format     string
+		newCatalog func() (*Catalog, error)
+	}{
+		{"file-based", func() (*Catalog, error) { return NewSDKCatalog(dir) }},
+		{"ConfigMap", func() (*Catalog, error) { return NewSDKConfigMapCatalog(ctx, dir) }},
+	}
+	var diffs []string
+	for _, g := range generators {
+		sdkCatalog, err := g.newCatalog()
+		if err != nil {
+			return fmt.Errorf("error generating operator-sdk %s catalog: %v", g.format, err)
+		}
+		diff, err := Diff(sdkCatalog, registryCatalog, allow)
+		if err != nil {
+			return err
+		}
+		if diff != "" {
+			diffs = append(diffs, fmt.Sprintf("operator-sdk %s (-) and operator-registry (+) catalogs for %s differ:\n%s",
+				g.format, dir, diff))
+		}
+	}
+	if len(diffs) != 0 {
+		return errors.New(strings.Join(diffs, "\n"))
+	}
+	return nil
+}
+
+// NewSDKCatalog returns the file-based catalog operator-sdk generates for the
+// package manifests in dir.
+func NewSDKCatalog(dir string) (*Catalog, error) {
+	pkg, bundles, err := apimanifests.GetManifestsDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := fbc.New(pkg, bundles)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	c := newCatalog()
+	for _, p := range cfg.Packages {
+		c.Packages[p.Name] = Package{DefaultChannel: p.DefaultChannel, Channels: map[string]Channel{}}
+	}
+	entries := map[string]fbc.ChannelEntry{}
+	for _, ch := range cfg.Channels {
+		channel := Channel{}
+		for i, e := range ch.Entries {
+			if i == 0 {
+				channel.Head = e.Name
+			}
+			channel.Entries = append(channel.Entries, e.Name)
+			entries[e.Name] = e
+		}
+		sort.Strings(channel.Entries)
+		c.Packages[ch.Package].Channels[ch.Name] = channel
+	}
+	for _, b := range cfg.Bundles {
+		bundle := Bundle{Package: b.Package, Objects: map[string]interface{}{}}
+		e := entries[b.Name]
+		bundle.Replaces, bundle.Skips, bundle.SkipRange = e.Replaces, e.Skips, e.SkipRange
+		for _, p := range b.Properties {
+			switch p.Type {
+			case fbc.PropertyPackage:
+				v := struct {
+					Version string `json:"version"`
+				}{}
+				if err := json.Unmarshal(p.Value, &v); err != nil {
+					return nil, err
+				}
+				bundle.Version = v.Version
+			case fbc.PropertyBundleObject:
+				v := struct {
+					Data []byte `json:"data"`
+				}{}
+				if err := json.Unmarshal(p.Value, &v); err != nil {
+					return nil, err
+				}
+				if err := addObject(bundle.Objects, v.Data); err != nil {
+					return nil, fmt.Errorf("bundle %q: %v", b.Name, err)
+				}
+			}
+		}
+		c.Bundles[b.Name] = bundle
+	}
+	return c, nil
+}
+
+// NewSDKConfigMapCatalog returns the catalog served from the ConfigMaps
+// operator-sdk generates for the package manifests in dir, by assembling the
+// manifests directory a registry pod assembles from them and loading it as
+// the registry pod does.
+func NewSDKConfigMapCatalog(ctx context.Context, dir string) (*Catalog, error) {
+	pkg, bundles, err := apimanifests.GetManifestsDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempDir("", "operator-sdk-parity-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	rr := configmap.RegistryResources{Pkg: pkg, Bundles: bundles}
+	if err := rr.WriteManifestsDir(tmp); err != nil {
+		return nil, err
+	}
+	return NewRegistryCatalog(ctx, tmp)
+}
+
+// NewRegistryCatalog returns the catalog operator-registry generates for the
+// package manifests in dir, by loading dir into a database as `opm` and the
+// registry initializer do.
+func NewRegistryCatalog(ctx context.Context, dir string) (*Catalog, error) {
+	tmp, err := ioutil.TempDir("", "operator-sdk-parity-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	db, err := sql.Open("sqlite3", filepath.Join(tmp, "bundles.db"))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	loader, err := sqlite.NewSQLLiteLoader(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := loader.Migrate(ctx); err != nil {
+		return nil, err
+	}
+	if err := sqlite.NewSQLLoaderForDirectory(loader, dir).Populate(); err != nil {
+		return nil, err
+	}
+	querier := sqlite.NewSQLLiteQuerierFromDb(db)
+
+	c := newCatalog()
+	pkgNames, err := querier.ListPackages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkgName := range pkgNames {
+		pkg, err := querier.GetPackage(ctx, pkgName)
+		if err != nil {
+			return nil, err
+		}
+		p := Package{DefaultChannel: pkg.DefaultChannelName, Channels: map[string]Channel{}}
+		for _, ch := range pkg.Channels {
+			channel := Channel{Head: ch.CurrentCSVName}
+			// Walk the replaces chain back from the channel head.
+			for csvName := ch.CurrentCSVName; csvName != ""; {
+				if contains(channel.Entries, csvName) {
+					break
+				}
+				b, err := querier.GetBundle(ctx, pkgName, ch.Name, csvName)
+				if err != nil {
+					// The replaced bundle is not in this channel.
+					break
+				}
+				bundle, err := newRegistryBundle(pkgName, b.CsvJson, b.Object)
+				if err != nil {
+					return nil, fmt.Errorf("bundle %q: %v", csvName, err)
+				}
+				c.Bundles[csvName] = bundle
+				channel.Entries = append(channel.Entries, csvName)
+				csvName = bundle.Replaces
+			}
+			sort.Strings(channel.Entries)
+			p.Channels[ch.Name] = channel
+		}
+		c.Packages[pkgName] = p
+	}
+	return c, nil
+}
+
+func newRegistryBundle(pkgName, csvJSON string, objs []string) (Bundle, error) {
+	csv := unstructured.Unstructured{}
+	if err := csv.UnmarshalJSON([]byte(csvJSON)); err != nil {
+		return Bundle{}, err
+	}
+	bundle := Bundle{Package: pkgName, Objects: map[string]interface{}{}}
+	bundle.Version, _, _ = unstructured.NestedString(csv.Object, "spec", "version")
+	bundle.Replaces, _, _ = unstructured.NestedString(csv.Object, "spec", "replaces")
+	bundle.Skips, _, _ = unstructured.NestedStringSlice(csv.Object, "spec", "skips")
+	bundle.SkipRange = csv.GetAnnotations()["olm.skipRange"]
+	for _, obj := range objs {
+		if err := addObject(bundle.Objects, []byte(obj)); err != nil {
+			return Bundle{}, err
+		}
+	}
+	return bundle, nil
+}
+
+// addObject decodes data and adds it to objs keyed by the object's kind and name.
+func addObject(objs map[string]interface{}, data []byte) error {
+	u := unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(data); err != nil {
+		return fmt.Errorf("error decoding object: %v", err)
+	}
+	objs[u.GetKind()+"/"+u.GetName()] = u.Object
+	return nil
+}
+
+// Diff returns a line diff of the canonical JSON encodings of a and b with
+// paths in allow removed, or an empty string if they are equal.
+func Diff(a, b *Catalog, allow Allowlist) (string, error) {
+	aJSON, err := canonicalize(a, allow)
+	if err != nil {
+		return "", err
+	}
+	bJSON, err := canonicalize(b, allow)
+	if err != nil {
+		return "", err
+	}
+	if aJSON == bJSON {
+		return "", nil
+	}
+	return lineDiff(aJSON, bJSON), nil
+}
+
+// canonicalize encodes c as indented JSON with sorted keys, after deleting
+// values at each path in allow.
+func canonicalize(c *Catalog, allow Allowlist) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return "", err
+	}
+	for _, p := range allow {
+		deletePath(v, strings.Split(p, "/"))
+	}
+	if b, err = json.MarshalIndent(v, "", "  "); err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// deletePath deletes values at path in v, where "*" matches any key.
+func deletePath(v interface{}, path []string) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(path) == 0 {
+		return
+	}
+	key, rest := path[0], path[1:]
+	for k, child := range m {
+		if key != "*" && key != k {
+			continue
+		}
+		if len(rest) == 0 {
+			delete(m, k)
+		} else {
+			deletePath(child, rest)
+		}
+	}
+}
+
+// lineDiff returns a diff of a and b, prefixing removed lines with "-",
+// added lines with "+", and only showing lines that differ.
+func lineDiff(a, b string) string {
+	dmp := diffmatchpatch.New()
+	aRunes, bRunes, lines := dmp.DiffLinesToRunes(a, b)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMainRunes(aRunes, bRunes, false), lines)
+	buf := &bytes.Buffer{}
+	for _, d := range diffs {
+		var prefix string
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			prefix = "+"
+		case diffmatchpatch.DiffDelete:
+			prefix = "-"
+		default:
+			continue
+		}
+		for _, line := range strings.SplitAfter(d.Text, "\n") {
+			if line != "" {
+				buf.WriteString(prefix + line)
+			}
+		}
+	}
+	return buf.String()
+}
+
+func newCatalog() *Catalog {
+	return &Catalog{Packages: map[string]Package{}, Bundles: map[string]Bundle{}}
+}
+
+func contains(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
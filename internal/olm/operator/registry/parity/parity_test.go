@@ -0,0 +1,94 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parity
+
+import (
+	"context"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Check", func() {
+	DescribeTable("should find no differences for fixtures",
+		func(dir string) {
+			Expect(Check(context.TODO(), dir, DefaultAllowlist)).To(Succeed())
+		},
+		Entry("multi-channel", filepath.Join("..", "fbc", "testdata", "memcached-operator")),
+		Entry("skipRange", filepath.Join("testdata", "skiprange-operator")),
+	)
+	DescribeTable("should generate the same catalog in each operator-sdk format",
+		func(dir string) {
+			fbcCatalog, err := NewSDKCatalog(dir)
+			Expect(err).NotTo(HaveOccurred())
+			cmCatalog, err := NewSDKConfigMapCatalog(context.TODO(), dir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cmCatalog.Bundles).NotTo(BeEmpty())
+			Expect(Diff(fbcCatalog, cmCatalog, nil)).To(BeEmpty())
+		},
+		Entry("multi-channel", filepath.Join("..", "fbc", "testdata", "memcached-operator")),
+		Entry("skipRange", filepath.Join("testdata", "skiprange-operator")),
+	)
+})
+
+var _ = Describe("Diff", func() {
+	var a, b *Catalog
+
+	BeforeEach(func() {
+		newTestCatalog := func() *Catalog {
+			return &Catalog{
+				Packages: map[string]Package{
+					"memcached-operator": {
+						DefaultChannel: "alpha",
+						Channels: map[string]Channel{
+							"alpha": {Head: "memcached-operator.v0.0.2", Entries: []string{"memcached-operator.v0.0.1", "memcached-operator.v0.0.2"}},
+						},
+					},
+				},
+				Bundles: map[string]Bundle{
+					"memcached-operator.v0.0.1": {Package: "memcached-operator", Version: "0.0.1", Objects: map[string]interface{}{}},
+					"memcached-operator.v0.0.2": {
+						Package:   "memcached-operator",
+						Version:   "0.0.2",
+						Replaces:  "memcached-operator.v0.0.1",
+						SkipRange: "0.0.1 - 0.0.2",
+						Objects:   map[string]interface{}{},
+					},
+				},
+			}
+		}
+		a, b = newTestCatalog(), newTestCatalog()
+	})
+
+	It("should return an empty diff for equal catalogs", func() {
+		Expect(Diff(a, b, nil)).To(BeEmpty())
+	})
+	It("should return a readable diff of differing fields", func() {
+		bundle := b.Bundles["memcached-operator.v0.0.2"]
+		bundle.SkipRange = ""
+		b.Bundles["memcached-operator.v0.0.2"] = bundle
+		Expect(Diff(a, b, nil)).To(Equal(`-      "skipRange": "0.0.1 - 0.0.2",` + "\n"))
+	})
+	It("should ignore allowlisted differences", func() {
+		bundle := b.Bundles["memcached-operator.v0.0.2"]
+		bundle.SkipRange = ""
+		b.Bundles["memcached-operator.v0.0.2"] = bundle
+		Expect(Diff(a, b, Allowlist{"bundles/*/skipRange"})).To(BeEmpty())
+		Expect(Diff(a, b, Allowlist{"bundles/memcached-operator.v0.0.2/skipRange"})).To(BeEmpty())
+		Expect(Diff(a, b, Allowlist{"bundles/memcached-operator.v0.0.1/skipRange"})).NotTo(BeEmpty())
+	})
+})
@@ -0,0 +1,134 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+	"github.com/operator-framework/operator-sdk/internal/olm/snapshot"
+)
+
+// Preflight check results.
+const (
+	PreflightPassed  = "passed"
+	PreflightFailed  = "failed"
+	PreflightSkipped = "skipped"
+)
+
+// PreflightCheck is a check of the cluster state an install requires, which
+// only reads from the cluster.
+type PreflightCheck struct {
+	Name string
+	Run  func(context.Context) error
+}
+
+// PreflightResult is the result of running a PreflightCheck.
+type PreflightResult struct {
+	Name   string
+	Result string
+	// Reason describes why the check failed or was skipped.
+	Reason string
+}
+
+// RunPreflights runs checks in order. Checks reading kinds not captured in a
+// cluster snapshot require a live cluster, and are skipped.
+func RunPreflights(ctx context.Context, checks []PreflightCheck) []PreflightResult {
+	results := make([]PreflightResult, 0, len(checks))
+	for _, check := range checks {
+		res := PreflightResult{Name: check.Name, Result: PreflightPassed}
+		var ncErr *snapshot.NotCapturedError
+		switch err := check.Run(ctx); {
+		case errors.As(err, &ncErr):
+			res.Result, res.Reason = PreflightSkipped, fmt.Sprintf("requires a live cluster: %v", err)
+		case err != nil:
+			res.Result, res.Reason = PreflightFailed, err.Error()
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// WritePreflightReport writes a line per result to w.
+func WritePreflightReport(w io.Writer, results []PreflightResult) error {
+	for _, res := range results {
+		line := fmt.Sprintf("%s: %s", res.Name, res.Result)
+		if res.Reason != "" {
+			line += ": " + res.Reason
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PreflightError returns an error naming each failed check in results, or nil
+// if none failed.
+func PreflightError(results []PreflightResult) error {
+	var failed []string
+	for _, res := range results {
+		if res.Result == PreflightFailed {
+			failed = append(failed, res.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("preflight checks failed: %s", strings.Join(failed, ", "))
+}
+
+// PreflightChecks returns checks of the cluster state InstallOperator requires,
+// which read with o's configured client and discover the cluster with dc.
+func (o OperatorInstaller) PreflightChecks(dc olmclient.ServerDiscovery) []PreflightCheck {
+	return []PreflightCheck{
+		{Name: "olm-installed", Run: func(ctx context.Context) error {
+			c := olmclient.Client{KubeClient: o.cfg.Client}
+			if err := c.CheckOLMInstalled(ctx, dc); err != nil {
+				return err
+			}
+			// CheckOLMInstalled passes if the package server APIService can't
+			// be read, so report an uncaptured APIService as skipped.
+			apiService := olmclient.NewPackageServerAPIService()
+			err := o.cfg.Client.Get(ctx, client.ObjectKey{Name: apiService.GetName()}, apiService)
+			var ncErr *snapshot.NotCapturedError
+			if errors.As(err, &ncErr) {
+				return err
+			}
+			return nil
+		}},
+		{Name: "operator-group", Run: o.checkOperatorGroup},
+	}
+}
+
+// checkOperatorGroup returns an error if the install namespace's OperatorGroup,
+// if any, can't be used to install the operator in o's install mode.
+func (o OperatorInstaller) checkOperatorGroup(ctx context.Context) error {
+	og, found, err := o.getOperatorGroup(ctx)
+	if err != nil {
+		return err
+	}
+	targetNamespaces, err := o.getOperatorGroupTargetNamespaces()
+	if err != nil || !found {
+		return err
+	}
+	return o.isOperatorGroupCompatible(*og, targetNamespaces)
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/snapshot"
+)
+
+var _ = Describe("Preflights", func() {
+	check := func(name string, err error) PreflightCheck {
+		return PreflightCheck{Name: name, Run: func(context.Context) error { return err }}
+	}
+	notCaptured := &snapshot.NotCapturedError{GVK: schema.GroupVersionKind{Group: "apiregistration.k8s.io", Version: "v1", Kind: "APIService"}}
+
+	It("should record each check's result in order", func() {
+		results := RunPreflights(context.TODO(), []PreflightCheck{
+			check("a", nil),
+			check("b", errors.New("bad operator group")),
+			check("c", notCaptured),
+		})
+		Expect(results).To(Equal([]PreflightResult{
+			{Name: "a", Result: PreflightPassed},
+			{Name: "b", Result: PreflightFailed, Reason: "bad operator group"},
+			{Name: "c", Result: PreflightSkipped, Reason: "requires a live cluster: APIService is not captured in the cluster snapshot"},
+		}))
+
+		out := &bytes.Buffer{}
+		Expect(WritePreflightReport(out, results)).To(Succeed())
+		Expect(out.String()).To(Equal("a: passed\n" +
+			"b: failed: bad operator group\n" +
+			"c: skipped: requires a live cluster: APIService is not captured in the cluster snapshot\n"))
+		Expect(PreflightError(results)).To(MatchError("preflight checks failed: b"))
+	})
+	It("should skip checks with wrapped NotCapturedErrors", func() {
+		results := RunPreflights(context.TODO(), []PreflightCheck{check("a", fmt.Errorf("error listing operator groups: %w", notCaptured))})
+		Expect(results[0].Result).To(Equal(PreflightSkipped))
+		Expect(PreflightError(results)).To(Succeed())
+	})
+})
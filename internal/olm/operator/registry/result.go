@@ -0,0 +1,192 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/breaker"
+)
+
+// Install result output formats, validated by olmclient.ValidateOutputFormat.
+const (
+	OutputJSON = olmclient.OutputJSON
+	OutputYAML = olmclient.OutputYAML
+)
+
+// StageSetup is the stage reported for failures before the catalog stage,
+// ex. validating options or pulling the bundle.
+const StageSetup = "setup"
+
+// InstallResult describes the objects an install created and the time spent in
+// each of its stages, for machine-readable output.
+type InstallResult struct {
+	CSV           *ResultObject  `json:"csv,omitempty"`
+	CatalogSource *ResultObject  `json:"catalogSource,omitempty"`
+	Subscriptions []ResultObject `json:"subscriptions,omitempty"`
+	// Deployments are the CSV's install strategy Deployments.
+	Deployments []ResultObject `json:"deployments,omitempty"`
+	// Stages are the completed install stages, in order.
+	Stages []StageResult `json:"stages"`
+	// Registries are the results of the SDK's calls to each image registry
+	// host, ex. to pull bundles, sorted by host.
+	Registries []RegistryResult `json:"registries,omitempty"`
+	// Stage and Error are set if the install failed, to the stage the
+	// install failed in and the failure.
+	Stage string `json:"stage,omitempty"`
+	Error string `json:"error,omitempty"`
+
+	// current is the stage in progress.
+	current string
+}
+
+// ResultObject identifies an object created by an install.
+type ResultObject struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// StageResult is the time an install stage took to complete.
+type StageResult struct {
+	Name    string          `json:"name"`
+	Elapsed metav1.Duration `json:"elapsed"`
+}
+
+// RegistryResult describes the SDK's calls to one image registry host.
+type RegistryResult struct {
+	Host      string `json:"host"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+	// Skipped calls were not made because the host exceeded its time
+	// budget or failure threshold.
+	Skipped int             `json:"skipped"`
+	Elapsed metav1.Duration `json:"elapsed"`
+}
+
+// SetRegistryStats records stats of calls to each registry host. r may be nil.
+func (r *InstallResult) SetRegistryStats(stats map[string]breaker.HostStats) {
+	if r == nil {
+		return
+	}
+	r.Registries = nil
+	for host, s := range stats {
+		r.Registries = append(r.Registries, RegistryResult{
+			Host:      host,
+			Succeeded: s.Succeeded,
+			Failed:    s.Failed,
+			Skipped:   s.Skipped,
+			Elapsed:   metav1.Duration{Duration: s.Elapsed},
+		})
+	}
+	sort.Slice(r.Registries, func(i, j int) bool { return r.Registries[i].Host < r.Registries[j].Host })
+}
+
+// beginStage records stage as in progress, and returns a function recording
+// the stage's elapsed time once it completes. r may be nil.
+func (r *InstallResult) beginStage(stage string) func() {
+	if r == nil {
+		return func() {}
+	}
+	r.current = stage
+	start := time.Now()
+	return func() {
+		r.Stages = append(r.Stages, StageResult{Name: stage, Elapsed: metav1.Duration{Duration: time.Since(start)}})
+		r.current = ""
+	}
+}
+
+// setCatalogSource records cs. r may be nil.
+func (r *InstallResult) setCatalogSource(cs *v1alpha1.CatalogSource) {
+	if r != nil {
+		r.CatalogSource = &ResultObject{Name: cs.GetName(), Namespace: cs.GetNamespace()}
+	}
+}
+
+// addSubscription records sub. r may be nil.
+func (r *InstallResult) addSubscription(sub *v1alpha1.Subscription) {
+	if r != nil {
+		r.Subscriptions = append(r.Subscriptions, ResultObject{Name: sub.GetName(), Namespace: sub.GetNamespace()})
+	}
+}
+
+// setCSV records csv and its install strategy Deployments. r may be nil.
+func (r *InstallResult) setCSV(csv *v1alpha1.ClusterServiceVersion) {
+	if r == nil || csv == nil {
+		return
+	}
+	r.CSV = &ResultObject{Name: csv.GetName(), Namespace: csv.GetNamespace()}
+	for _, ds := range csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs {
+		r.Deployments = append(r.Deployments, ResultObject{Name: ds.Name, Namespace: csv.GetNamespace()})
+	}
+}
+
+// SetError records err as the install's failure, in the stage that timed out
+// if err is a StageTimeoutError, otherwise in the stage in progress.
+func (r *InstallResult) SetError(err error) {
+	if err == nil {
+		return
+	}
+	r.Error = err.Error()
+	var stErr *StageTimeoutError
+	switch {
+	case errors.As(err, &stErr):
+		r.Stage = stErr.Stage
+	case r.current != "":
+		r.Stage = r.current
+	default:
+		r.Stage = StageSetup
+	}
+}
+
+// Write writes r to w in format, either OutputJSON or OutputYAML.
+func (r InstallResult) Write(w io.Writer, format string) error {
+	if r.Stages == nil {
+		r.Stages = []StageResult{}
+	}
+	var b []byte
+	var err error
+	switch format {
+	case OutputJSON:
+		if b, err = json.MarshalIndent(r, "", "  "); err == nil {
+			b = append(b, '\n')
+		}
+	case OutputYAML:
+		b, err = yaml.Marshal(r)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+	if err != nil {
+		return fmt.Errorf("error marshaling install result: %v", err)
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// WriteWithError records err as the install's failure, then writes r to w in
+// format. A nil err records a successful install.
+func (r *InstallResult) WriteWithError(w io.Writer, format string, err error) error {
+	r.SetError(err)
+	return r.Write(w, format)
+}
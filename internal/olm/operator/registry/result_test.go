@@ -0,0 +1,181 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/breaker"
+)
+
+var _ = Describe("InstallResult", func() {
+	const ns = "testns"
+	var res *InstallResult
+
+	newStage := func(name string, d time.Duration) StageResult {
+		return StageResult{Name: name, Elapsed: metav1.Duration{Duration: d}}
+	}
+
+	BeforeEach(func() {
+		res = &InstallResult{
+			CatalogSource: &ResultObject{Name: "memcached-operator-catalog", Namespace: ns},
+			Subscriptions: []ResultObject{{Name: "memcached-operator-v0-0-1-sub", Namespace: ns}},
+			Stages: []StageResult{
+				newStage(StageCatalog, 12500*time.Millisecond),
+				newStage(StageSubscription, 3*time.Second),
+			},
+		}
+	})
+
+	// checkGolden writes res in format and compares it to the golden file name,
+	// which must also decode into an InstallResult without unknown fields.
+	checkGolden := func(format, name string) {
+		golden, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		Expect(err).NotTo(HaveOccurred())
+		out := &bytes.Buffer{}
+		Expect(res.Write(out, format)).To(Succeed())
+		Expect(out.String()).To(Equal(string(golden)))
+
+		if format == OutputJSON {
+			dec := json.NewDecoder(bytes.NewReader(golden))
+			dec.DisallowUnknownFields()
+			Expect(dec.Decode(&InstallResult{})).To(Succeed())
+		}
+	}
+
+	Describe("Write", func() {
+		BeforeEach(func() {
+			csv := &v1alpha1.ClusterServiceVersion{}
+			csv.SetName("memcached-operator.v0.0.1")
+			csv.SetNamespace(ns)
+			csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs = []v1alpha1.StrategyDeploymentSpec{
+				{Name: "memcached-operator-controller-manager"},
+			}
+			res.Stages = append(res.Stages, newStage(StageCSV, 64*time.Second))
+			res.setCSV(csv)
+		})
+
+		It("should match the JSON golden file", func() {
+			checkGolden(OutputJSON, "install-result.json")
+		})
+		It("should match the YAML golden file", func() {
+			checkGolden(OutputYAML, "install-result.yaml")
+		})
+		It("should reject an unknown format", func() {
+			Expect(res.Write(&bytes.Buffer{}, "table")).To(MatchError(`unknown output format "table"`))
+		})
+	})
+
+	Describe("SetError", func() {
+		It("should match the JSON golden file for a failed install", func() {
+			res.current = StageCSV
+			res.SetError(&StageTimeoutError{
+				Stage:         StageBundleUnpack,
+				Timeout:       3 * time.Minute,
+				LastCondition: "bundle lookup pending",
+				Err:           context.DeadlineExceeded,
+			})
+			checkGolden(OutputJSON, "install-result-failed.json")
+		})
+		It("should report the stage of a wrapped stage timeout", func() {
+			res.current = StageCatalog
+			res.SetError(fmt.Errorf("create catalog: %w", &StageTimeoutError{Stage: StageCatalog, Err: context.DeadlineExceeded}))
+			Expect(res.Stage).To(Equal(StageCatalog))
+		})
+		It("should report the stage in progress", func() {
+			res.current = StageSubscription
+			res.SetError(errors.New("create subscription: forbidden"))
+			Expect(res.Stage).To(Equal(StageSubscription))
+			Expect(res.Error).To(Equal("create subscription: forbidden"))
+		})
+		It("should report failures before any stage as setup failures", func() {
+			res = &InstallResult{}
+			res.SetError(errors.New("pull bundle: not found"))
+			Expect(res.Stage).To(Equal(StageSetup))
+
+			out := &bytes.Buffer{}
+			Expect(res.Write(out, OutputJSON)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring(`"stages": []`))
+		})
+		It("should not record a nil error", func() {
+			res.SetError(nil)
+			Expect(res.Stage).To(BeEmpty())
+			Expect(res.Error).To(BeEmpty())
+		})
+	})
+
+	Describe("WriteWithError", func() {
+		It("should write the result with the error and its stage", func() {
+			res.current = StageSubscription
+			out := &bytes.Buffer{}
+			Expect(res.WriteWithError(out, OutputJSON, errors.New("create subscription: forbidden"))).To(Succeed())
+			Expect(out.String()).To(ContainSubstring(`"stage": "` + StageSubscription + `"`))
+			Expect(out.String()).To(ContainSubstring(`"error": "create subscription: forbidden"`))
+		})
+		It("should reject an unknown format", func() {
+			Expect(res.WriteWithError(&bytes.Buffer{}, "table", nil)).To(MatchError(`unknown output format "table"`))
+		})
+	})
+
+	Describe("SetRegistryStats", func() {
+		It("should record stats of each registry host sorted by host", func() {
+			res.SetRegistryStats(map[string]breaker.HostStats{
+				"quay.io":           {Succeeded: 2, Elapsed: 3 * time.Second},
+				"flaky.example.com": {Failed: 3, Skipped: 1, Elapsed: time.Minute, Open: true},
+			})
+			Expect(res.Registries).To(Equal([]RegistryResult{
+				{Host: "flaky.example.com", Failed: 3, Skipped: 1, Elapsed: metav1.Duration{Duration: time.Minute}},
+				{Host: "quay.io", Succeeded: 2, Elapsed: metav1.Duration{Duration: 3 * time.Second}},
+			}))
+
+			out := &bytes.Buffer{}
+			Expect(res.Write(out, OutputJSON)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring(`"host": "flaky.example.com"`))
+		})
+		It("should not record stats in a nil result", func() {
+			var r *InstallResult
+			r.SetRegistryStats(map[string]breaker.HostStats{"quay.io": {Succeeded: 1}})
+		})
+	})
+
+	Describe("beginStage", func() {
+		It("should record a completed stage", func() {
+			res = &InstallResult{}
+			end := res.beginStage(StageCatalog)
+			Expect(res.current).To(Equal(StageCatalog))
+			end()
+			Expect(res.current).To(BeEmpty())
+			Expect(res.Stages).To(HaveLen(1))
+			Expect(res.Stages[0].Name).To(Equal(StageCatalog))
+		})
+		It("should not record stages of a nil result", func() {
+			var nilRes *InstallResult
+			nilRes.beginStage(StageCatalog)()
+			nilRes.setCSV(&v1alpha1.ClusterServiceVersion{})
+		})
+	})
+})
@@ -0,0 +1,76 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
+)
+
+// VerifyNoResiduals returns an error listing any resources for operator package
+// pkgName that remain in the configured namespace after an uninstall.
+func VerifyNoResiduals(ctx context.Context, cfg *Configuration, pkgName string) error {
+	var residuals []string
+
+	subs := v1alpha1.SubscriptionList{}
+	if err := cfg.Client.List(ctx, &subs, client.InNamespace(cfg.Namespace)); err != nil {
+		return fmt.Errorf("list subscriptions: %v", err)
+	}
+	for _, sub := range subs.Items {
+		if sub.Spec != nil && sub.Spec.Package == pkgName {
+			residuals = append(residuals, "subscription/"+sub.GetName())
+		}
+	}
+
+	catsrcs := v1alpha1.CatalogSourceList{}
+	if err := cfg.Client.List(ctx, &catsrcs, client.InNamespace(cfg.Namespace)); err != nil {
+		return fmt.Errorf("list catalog sources: %v", err)
+	}
+	for _, cs := range catsrcs.Items {
+		if cs.Spec.Publisher == "operator-sdk" && cs.Spec.DisplayName == pkgName {
+			residuals = append(residuals, "catalogsource/"+cs.GetName())
+		}
+	}
+
+	// Registry objects created by operator-sdk are labeled by package name.
+	registryLabels := client.MatchingLabels(configmap.MakeRegistryLabels(pkgName))
+	cms := corev1.ConfigMapList{}
+	if err := cfg.Client.List(ctx, &cms, client.InNamespace(cfg.Namespace), registryLabels); err != nil {
+		return fmt.Errorf("list configmaps: %v", err)
+	}
+	for _, cm := range cms.Items {
+		residuals = append(residuals, "configmap/"+cm.GetName())
+	}
+	pods := corev1.PodList{}
+	if err := cfg.Client.List(ctx, &pods, client.InNamespace(cfg.Namespace), registryLabels); err != nil {
+		return fmt.Errorf("list pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		residuals = append(residuals, "pod/"+pod.GetName())
+	}
+
+	if len(residuals) != 0 {
+		return fmt.Errorf("resources for package %q remain in namespace %q: %s",
+			pkgName, cfg.Namespace, strings.Join(residuals, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ResourceRequirementsValue is a flag value that sets resource requests and
+// limits from comma-separated "requests.<resource>=<quantity>" and
+// "limits.<resource>=<quantity>" pairs, ex. "requests.cpu=100m,limits.memory=256Mi".
+type ResourceRequirementsValue struct {
+	r *corev1.ResourceRequirements
+}
+
+var _ pflag.Value = &ResourceRequirementsValue{}
+
+// NewResourceRequirementsValue returns a flag value that sets r.
+func NewResourceRequirementsValue(r *corev1.ResourceRequirements) *ResourceRequirementsValue {
+	return &ResourceRequirementsValue{r: r}
+}
+
+func (v *ResourceRequirementsValue) Set(str string) error {
+	r := corev1.ResourceRequirements{}
+	for _, pair := range strings.Split(str, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		split := strings.SplitN(pair, "=", 2)
+		if len(split) != 2 {
+			return fmt.Errorf("invalid resource requirement %q: must be of the form <requests|limits>.<resource>=<quantity>", pair)
+		}
+		kind, name := split[0], ""
+		if i := strings.Index(kind, "."); i >= 0 {
+			kind, name = kind[:i], kind[i+1:]
+		}
+		if errs := validation.IsQualifiedName(name); len(errs) != 0 {
+			return fmt.Errorf("invalid resource requirement %q: invalid resource name %q: %s", pair, name, strings.Join(errs, ", "))
+		}
+		q, err := resource.ParseQuantity(split[1])
+		if err != nil {
+			return fmt.Errorf("invalid resource requirement %q: %v", pair, err)
+		}
+		switch kind {
+		case "requests":
+			if r.Requests == nil {
+				r.Requests = corev1.ResourceList{}
+			}
+			r.Requests[corev1.ResourceName(name)] = q
+		case "limits":
+			if r.Limits == nil {
+				r.Limits = corev1.ResourceList{}
+			}
+			r.Limits[corev1.ResourceName(name)] = q
+		default:
+			return fmt.Errorf("invalid resource requirement %q: must be of the form <requests|limits>.<resource>=<quantity>", pair)
+		}
+	}
+	if err := ValidateResourceRequirements(r); err != nil {
+		return err
+	}
+	*v.r = r
+	return nil
+}
+
+// ValidateResourceRequirements returns an error if any of r's requests
+// exceeds the limit of the same resource.
+func ValidateResourceRequirements(r corev1.ResourceRequirements) error {
+	for name, req := range r.Requests {
+		if limit, ok := r.Limits[name]; ok && req.Cmp(limit) > 0 {
+			return fmt.Errorf("invalid resource requirements: %s request %s must be less than or equal to limit %s",
+				name, req.String(), limit.String())
+		}
+	}
+	return nil
+}
+
+func (v *ResourceRequirementsValue) String() string {
+	if v.r == nil {
+		return ""
+	}
+	var pairs []string
+	for _, l := range []struct {
+		kind string
+		list corev1.ResourceList
+	}{{"requests", v.r.Requests}, {"limits", v.r.Limits}} {
+		names := make([]string, 0, len(l.list))
+		for name := range l.list {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			q := l.list[corev1.ResourceName(name)]
+			pairs = append(pairs, fmt.Sprintf("%s.%s=%s", l.kind, name, q.String()))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (*ResourceRequirementsValue) Type() string {
+	return "ResourceRequirementsValue"
+}
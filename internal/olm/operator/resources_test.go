@@ -0,0 +1,64 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var _ = Describe("ResourceRequirementsValue", func() {
+	var (
+		r corev1.ResourceRequirements
+		v *ResourceRequirementsValue
+	)
+
+	BeforeEach(func() {
+		r = corev1.ResourceRequirements{}
+		v = NewResourceRequirementsValue(&r)
+	})
+
+	It("should leave requirements empty by default", func() {
+		Expect(v.String()).To(Equal(""))
+		Expect(r.Requests).To(BeNil())
+		Expect(r.Limits).To(BeNil())
+	})
+	It("should parse requests and limits", func() {
+		Expect(v.Set("requests.cpu=100m, requests.memory=128Mi,limits.memory=512Mi")).To(Succeed())
+		Expect(r.Requests).To(Equal(corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		}))
+		Expect(r.Limits).To(Equal(corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse("512Mi"),
+		}))
+		Expect(v.String()).To(Equal("requests.cpu=100m,requests.memory=128Mi,limits.memory=512Mi"))
+	})
+	It("should reject invalid requirement kinds", func() {
+		Expect(v.Set("request.cpu=100m")).To(MatchError(ContainSubstring("must be of the form")))
+	})
+	It("should reject missing resource names", func() {
+		Expect(v.Set("limits=100m")).To(MatchError(ContainSubstring("invalid resource name")))
+	})
+	It("should reject invalid quantities", func() {
+		Expect(v.Set("limits.memory=lots")).To(HaveOccurred())
+	})
+	It("should reject requests greater than limits", func() {
+		Expect(v.Set("requests.memory=1Gi,limits.memory=512Mi")).To(MatchError(ContainSubstring("less than or equal to limit")))
+		Expect(r.Requests).To(BeNil())
+	})
+})
@@ -0,0 +1,162 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// RegistrySchedulingUsage describes which pods registry scheduling flags apply to.
+const RegistrySchedulingUsage = "Applies to the registry pod the SDK creates. The catalog is served by that pod, " +
+	"so OLM creates no catalog pod; OLM's bundle unpack jobs can't be configured and are scheduled as OLM configures them"
+
+// NodeSelectorValue is a flag value that adds "<key>=<value>" label pairs to a
+// node selector, either comma-separated or with the flag repeated.
+type NodeSelectorValue struct {
+	m *map[string]string
+}
+
+var _ pflag.Value = &NodeSelectorValue{}
+
+// NewNodeSelectorValue returns a flag value that sets m.
+func NewNodeSelectorValue(m *map[string]string) *NodeSelectorValue {
+	return &NodeSelectorValue{m: m}
+}
+
+func (v *NodeSelectorValue) Set(str string) error {
+	selector := map[string]string{}
+	for k, val := range *v.m {
+		selector[k] = val
+	}
+	for _, pair := range strings.Split(str, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		split := strings.SplitN(pair, "=", 2)
+		if len(split) != 2 {
+			return fmt.Errorf("invalid node selector %q: must be of the form <key>=<value>", pair)
+		}
+		selector[split[0]] = split[1]
+	}
+	if err := ValidateNodeSelector(selector); err != nil {
+		return err
+	}
+	*v.m = selector
+	return nil
+}
+
+// ValidateNodeSelector returns an error if any of selector's keys or values
+// is not a valid label key or value.
+func ValidateNodeSelector(selector map[string]string) error {
+	for k, v := range selector {
+		if errs := validation.IsQualifiedName(k); len(errs) != 0 {
+			return fmt.Errorf("invalid node selector key %q: %s", k, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) != 0 {
+			return fmt.Errorf("invalid node selector value %q of key %q: %s", v, k, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+func (v *NodeSelectorValue) String() string {
+	if v.m == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*v.m))
+	for k, val := range *v.m {
+		pairs = append(pairs, k+"="+val)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (*NodeSelectorValue) Type() string {
+	return "NodeSelectorValue"
+}
+
+// TolerationsFileValue is a flag value that sets tolerations from a YAML or
+// JSON file containing a list of tolerations, as in a pod spec.
+type TolerationsFileValue struct {
+	path        string
+	tolerations *[]corev1.Toleration
+}
+
+var _ pflag.Value = &TolerationsFileValue{}
+
+// NewTolerationsFileValue returns a flag value that sets tolerations.
+func NewTolerationsFileValue(tolerations *[]corev1.Toleration) *TolerationsFileValue {
+	return &TolerationsFileValue{tolerations: tolerations}
+}
+
+func (v *TolerationsFileValue) Set(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read tolerations file: %v", err)
+	}
+	var tolerations []corev1.Toleration
+	if err := yaml.UnmarshalStrict(b, &tolerations); err != nil {
+		return fmt.Errorf("decode tolerations file %s: %v", path, err)
+	}
+	if err := ValidateTolerations(tolerations); err != nil {
+		return fmt.Errorf("tolerations file %s: %v", path, err)
+	}
+	v.path, *v.tolerations = path, tolerations
+	return nil
+}
+
+// ValidateTolerations returns an error if any of tolerations has an unknown
+// operator or effect, or a value with the Exists operator.
+func ValidateTolerations(tolerations []corev1.Toleration) error {
+	for i, t := range tolerations {
+		switch t.Operator {
+		case "", corev1.TolerationOpEqual:
+		case corev1.TolerationOpExists:
+			if t.Value != "" {
+				return fmt.Errorf("toleration %d: value must be empty with operator %s", i, t.Operator)
+			}
+		default:
+			return fmt.Errorf("toleration %d: unknown operator %q: must be one of [%s, %s]",
+				i, t.Operator, corev1.TolerationOpEqual, corev1.TolerationOpExists)
+		}
+		switch t.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return fmt.Errorf("toleration %d: unknown effect %q: must be one of [%s, %s, %s]", i, t.Effect,
+				corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute)
+		}
+		if t.Key == "" && t.Operator != corev1.TolerationOpExists {
+			return fmt.Errorf("toleration %d: operator must be %s if key is empty", i, corev1.TolerationOpExists)
+		}
+	}
+	return nil
+}
+
+func (v *TolerationsFileValue) String() string {
+	return v.path
+}
+
+func (*TolerationsFileValue) Type() string {
+	return "TolerationsFileValue"
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("NodeSelectorValue", func() {
+	var (
+		m map[string]string
+		v *NodeSelectorValue
+	)
+
+	BeforeEach(func() {
+		m = nil
+		v = NewNodeSelectorValue(&m)
+	})
+
+	It("should leave the node selector empty by default", func() {
+		Expect(v.String()).To(Equal(""))
+		Expect(m).To(BeNil())
+	})
+	It("should add pairs when set repeatedly", func() {
+		Expect(v.Set("node-role.kubernetes.io/infra=, disktype=ssd")).To(Succeed())
+		Expect(v.Set("zone=a")).To(Succeed())
+		Expect(m).To(Equal(map[string]string{"node-role.kubernetes.io/infra": "", "disktype": "ssd", "zone": "a"}))
+		Expect(v.String()).To(Equal("disktype=ssd,node-role.kubernetes.io/infra=,zone=a"))
+	})
+	It("should reject pairs without a value", func() {
+		Expect(v.Set("disktype")).To(MatchError(ContainSubstring("must be of the form <key>=<value>")))
+	})
+	It("should reject invalid label keys and values", func() {
+		Expect(v.Set("disk type=ssd")).To(MatchError(ContainSubstring(`invalid node selector key "disk type"`)))
+		Expect(v.Set("disktype=s/d")).To(MatchError(ContainSubstring(`invalid node selector value "s/d"`)))
+		Expect(m).To(BeNil())
+	})
+})
+
+var _ = Describe("TolerationsFileValue", func() {
+	var (
+		tmp         string
+		tolerations []corev1.Toleration
+		v           *TolerationsFileValue
+	)
+
+	writeFile := func(content string) string {
+		path := filepath.Join(tmp, "tolerations.yaml")
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmp, err = ioutil.TempDir("", "tolerations-")
+		Expect(err).NotTo(HaveOccurred())
+		tolerations = nil
+		v = NewTolerationsFileValue(&tolerations)
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	It("should read a list of tolerations", func() {
+		path := writeFile(`
+- key: dedicated
+  operator: Equal
+  value: infra
+  effect: NoSchedule
+- operator: Exists
+  effect: NoExecute
+`)
+		Expect(v.Set(path)).To(Succeed())
+		Expect(tolerations).To(Equal([]corev1.Toleration{
+			{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "infra", Effect: corev1.TaintEffectNoSchedule},
+			{Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+		}))
+		Expect(v.String()).To(Equal(path))
+	})
+	It("should fail if the file does not exist", func() {
+		Expect(v.Set(filepath.Join(tmp, "missing.yaml"))).To(MatchError(ContainSubstring("read tolerations file")))
+	})
+	It("should reject unknown fields", func() {
+		Expect(v.Set(writeFile("- key: dedicated\n  efect: NoSchedule\n"))).To(MatchError(ContainSubstring("decode tolerations file")))
+	})
+	It("should reject invalid tolerations", func() {
+		Expect(v.Set(writeFile("- key: dedicated\n  operator: In\n"))).To(MatchError(ContainSubstring(`unknown operator "In"`)))
+		Expect(v.Set(writeFile("- key: dedicated\n  effect: NoRun\n"))).To(MatchError(ContainSubstring(`unknown effect "NoRun"`)))
+		Expect(v.Set(writeFile("- key: dedicated\n  operator: Exists\n  value: infra\n"))).To(MatchError(
+			ContainSubstring("value must be empty with operator Exists")))
+		Expect(v.Set(writeFile("- value: infra\n"))).To(MatchError(ContainSubstring("operator must be Exists if key is empty")))
+		Expect(tolerations).To(BeNil())
+	})
+})
@@ -0,0 +1,73 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// SecurityContextConfig is a flag value selecting the security context of
+// registry pods the SDK creates.
+type SecurityContextConfig string
+
+const (
+	// SecurityContextConfigLegacy leaves pod security contexts unset, for
+	// registry images that must run as root. This is the default.
+	SecurityContextConfigLegacy SecurityContextConfig = "legacy"
+	// SecurityContextConfigRestricted sets pod security contexts satisfying
+	// the restricted Pod Security Standard.
+	SecurityContextConfigRestricted SecurityContextConfig = "restricted"
+)
+
+// SecurityContextConfigUsage is the usage string of SecurityContextConfig flags.
+const SecurityContextConfigUsage = "Security context of registry pods the SDK creates, one of [legacy, restricted]. " +
+	"restricted runs registry containers as a non-root user with the runtime default seccomp profile, " +
+	"no privilege escalation, and all capabilities dropped, as namespaces enforcing the restricted " +
+	"Pod Security Standard require. Pods created by OLM, ex. for bundle unpacking, are not affected"
+
+var _ pflag.Value = new(SecurityContextConfig)
+
+func (c *SecurityContextConfig) Set(str string) error {
+	*c = SecurityContextConfig(str)
+	return c.Validate()
+}
+
+func (c SecurityContextConfig) String() string {
+	if c == "" {
+		return string(SecurityContextConfigLegacy)
+	}
+	return string(c)
+}
+
+func (SecurityContextConfig) Type() string {
+	return "SecurityContextConfig"
+}
+
+// Validate returns an error if c is not empty, legacy, or restricted.
+func (c SecurityContextConfig) Validate() error {
+	switch c {
+	case "", SecurityContextConfigLegacy, SecurityContextConfigRestricted:
+		return nil
+	}
+	return fmt.Errorf("unknown security context config %q: must be one of [%s, %s]",
+		string(c), SecurityContextConfigLegacy, SecurityContextConfigRestricted)
+}
+
+// IsRestricted returns true if c is SecurityContextConfigRestricted.
+func (c SecurityContextConfig) IsRestricted() bool {
+	return c == SecurityContextConfigRestricted
+}
@@ -0,0 +1,40 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SecurityContextConfig", func() {
+	It("should default to legacy", func() {
+		var c SecurityContextConfig
+		Expect(c.String()).To(Equal("legacy"))
+		Expect(c.IsRestricted()).To(BeFalse())
+		Expect(c.Validate()).To(Succeed())
+	})
+	It("should set restricted", func() {
+		var c SecurityContextConfig
+		Expect(c.Set("restricted")).To(Succeed())
+		Expect(c).To(Equal(SecurityContextConfigRestricted))
+		Expect(c.IsRestricted()).To(BeTrue())
+	})
+	It("should reject unknown configs", func() {
+		var c SecurityContextConfig
+		Expect(c.Set("privileged")).To(MatchError(
+			`unknown security context config "privileged": must be one of [legacy, restricted]`))
+	})
+})
@@ -16,47 +16,188 @@ package operator
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	v1 "github.com/operator-framework/api/pkg/operators/v1"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/kubectl/pkg/util/slice"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
 )
 
+// ErrPackageNotFound is returned by Uninstall.Run when no Subscription exists
+// for the operator package.
+var ErrPackageNotFound = errors.New("operator package not found")
+
 type Uninstall struct {
 	config *Configuration
 
-	Package                  string
+	Package string
+	// AdditionalPackages were installed from the same catalog as Package, and
+	// are uninstalled in reverse order before Package.
+	AdditionalPackages       []string
 	DeleteAll                bool
 	DeleteCRDs               bool
 	DeleteOperatorGroups     bool
 	DeleteOperatorGroupNames []string
+	// SkipCleanupOrphans disables deletion of all operator-sdk registry objects
+	// for Package, regardless of version, once the catalog source is deleted.
+	SkipCleanupOrphans bool
+	// DeleteNamespace deletes the operator's namespace once all operator objects
+	// are deleted, and waits for the namespace to finish terminating. Only
+	// namespaces labeled as created by operator-sdk are deleted unless Force is set.
+	DeleteNamespace bool
+	Force           bool
+	// AllNamespaces uninstalls Package from every namespace containing a
+	// Subscription for it, instead of only the configured namespace.
+	// Uninstalling from more than one namespace requires Yes to be set.
+	AllNamespaces bool
+	Yes           bool
 
 	Logf func(string, ...interface{})
+
+	// sharedCatalog is set when uninstalling one of several packages served
+	// by the same catalog source, which may have already been deleted.
+	sharedCatalog bool
 }
 
+// protectedNamespaces are never deleted by Uninstall.
+var protectedNamespaces = []string{"default", "kube-system"}
+
 func NewUninstall(cfg *Configuration) *Uninstall {
 	return &Uninstall{
 		config: cfg,
 	}
 }
 
+// Validate returns an error describing each of u's option rules that are violated.
+func (u *Uninstall) Validate() error {
+	return u.OptionRules().Validate()
+}
+
+// OptionRules returns the rules constraining u's fields.
+func (u *Uninstall) OptionRules() OptionRules {
+	// DeleteAll implies DeleteOperatorGroups.
+	deleteOperatorGroups := Option{Field: "DeleteOperatorGroups", IsSet: func() bool { return u.DeleteOperatorGroups || u.DeleteAll }}
+	return OptionRules{
+		Rules: []OptionRule{
+			Required(StringOption("Package", "<operatorPackageName>", &u.Package)),
+			Requires(BoolOption("Force", "--force", &u.Force), BoolOption("DeleteNamespace", "--delete-namespace", &u.DeleteNamespace)),
+			Requires(Option{Field: "DeleteOperatorGroupNames", IsSet: func() bool { return len(u.DeleteOperatorGroupNames) != 0 }},
+				deleteOperatorGroups),
+			Requires(BoolOption("Yes", "--yes", &u.Yes), BoolOption("AllNamespaces", "--all-namespaces", &u.AllNamespaces)),
+			MutuallyExclusive(BoolOption("AllNamespaces", "--all-namespaces", &u.AllNamespaces),
+				Option{Field: "AdditionalPackages", Flag: "[<additionalPackageName>...]", IsSet: func() bool { return len(u.AdditionalPackages) != 0 }}),
+		},
+		Unconstrained: []string{"DeleteAll", "DeleteCRDs", "SkipCleanupOrphans", "Logf"},
+	}
+}
+
 func (u *Uninstall) Run(ctx context.Context) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
 	if u.DeleteAll {
 		u.DeleteCRDs = true
 		u.DeleteOperatorGroups = true
 	}
+	if u.AllNamespaces {
+		return u.runAllNamespaces(ctx)
+	}
+	if len(u.AdditionalPackages) != 0 {
+		return u.runPackages(ctx)
+	}
+	return u.run(ctx)
+}
+
+// runPackages uninstalls AdditionalPackages in reverse order, then Package,
+// from the configured namespace. The namespace is only deleted once all
+// packages are uninstalled.
+func (u *Uninstall) runPackages(ctx context.Context) error {
+	// Check that the namespace can be deleted before deleting anything else.
+	if u.DeleteNamespace {
+		if _, err := u.getDeletableNamespace(ctx); err != nil {
+			return err
+		}
+	}
+
+	pkgs := make([]string, 0, len(u.AdditionalPackages)+1)
+	for i := len(u.AdditionalPackages) - 1; i >= 0; i-- {
+		pkgs = append(pkgs, u.AdditionalPackages[i])
+	}
+	pkgs = append(pkgs, u.Package)
+	for i, pkg := range pkgs {
+		u.Logf("Uninstalling package %q", pkg)
+		pu := *u
+		pu.Package = pkg
+		pu.AdditionalPackages = nil
+		pu.sharedCatalog = true
+		pu.DeleteNamespace = u.DeleteNamespace && i == len(pkgs)-1
+		if err := pu.run(ctx); err != nil {
+			return fmt.Errorf("package %q: %w", pkg, err)
+		}
+	}
+	return nil
+}
+
+// runAllNamespaces uninstalls Package from each namespace containing a
+// Subscription for it, continuing past failures in individual namespaces.
+func (u *Uninstall) runAllNamespaces(ctx context.Context) error {
+	subs := v1alpha1.SubscriptionList{}
+	if err := u.config.Client.List(ctx, &subs); err != nil {
+		return fmt.Errorf("list subscriptions: %v", err)
+	}
+	nsSet := map[string]struct{}{}
+	for _, s := range subs.Items {
+		if s.Spec != nil && s.Spec.Package == u.Package {
+			nsSet[s.GetNamespace()] = struct{}{}
+		}
+	}
+	namespaces := make([]string, 0, len(nsSet))
+	for ns := range nsSet {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
 
+	switch {
+	case len(namespaces) == 0:
+		return fmt.Errorf("%w in any namespace: %q", ErrPackageNotFound, u.Package)
+	case len(namespaces) > 1 && !u.Yes:
+		return fmt.Errorf("package %q is installed in %d namespaces: %s; set --yes to uninstall it from all of them",
+			u.Package, len(namespaces), strings.Join(namespaces, ", "))
+	}
+
+	var errs []error
+	for _, ns := range namespaces {
+		u.Logf("Uninstalling package %q from namespace %q", u.Package, ns)
+		cfg := *u.config
+		cfg.Namespace = ns
+		nsu := *u
+		nsu.config = &cfg
+		if err := nsu.run(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("namespace %q: %v", ns, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// run uninstalls Package from the configured namespace.
+func (u *Uninstall) run(ctx context.Context) error {
 	subs := v1alpha1.SubscriptionList{}
 	if err := u.config.Client.List(ctx, &subs, client.InNamespace(u.config.Namespace)); err != nil {
 		return fmt.Errorf("list subscriptions: %v", err)
@@ -70,39 +211,127 @@ func (u *Uninstall) Run(ctx context.Context) error {
 			break
 		}
 	}
+	// Without a Subscription, ex. if it was deleted by hand, the catalog sources
+	// the SDK created for Package are found by label instead.
+	var orphanedCatalogs []v1alpha1.CatalogSource
 	if sub == nil {
-		return fmt.Errorf("operator package %q not found", u.Package)
+		var err error
+		if orphanedCatalogs, err = u.getSDKCatalogSources(ctx); err != nil {
+			return err
+		}
+		if len(orphanedCatalogs) == 0 {
+			return fmt.Errorf("%w: %q", ErrPackageNotFound, u.Package)
+		}
+		u.Logf("subscription for package %q not found, deleting operator-sdk resources labeled for it", u.Package)
+	}
+
+	// Check that the namespace can be deleted before deleting anything else.
+	var ns *corev1.Namespace
+	if u.DeleteNamespace {
+		var err error
+		if ns, err = u.getDeletableNamespace(ctx); err != nil {
+			return err
+		}
 	}
 
+	if sub != nil {
+		if err := u.deleteSubscription(ctx, sub); err != nil {
+			return err
+		}
+	} else if err := u.deleteOrphanedCatalogs(ctx, orphanedCatalogs); err != nil {
+		return err
+	}
+
+	// Registry objects from previous installs may not be owned by this catalog
+	// source, so sweep them by label.
+	if !u.SkipCleanupOrphans {
+		if err := u.deleteRegistryObjects(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := u.deleteRegistryServiceAccount(ctx); err != nil {
+		return err
+	}
+
+	// If this was the last subscription in the namespace and the operator group is
+	// the one we created, delete it
+	if u.DeleteOperatorGroups {
+		if err := u.config.Client.List(ctx, &subs, client.InNamespace(u.config.Namespace)); err != nil {
+			return fmt.Errorf("list subscriptions: %v", err)
+		}
+		if len(subs.Items) == 0 {
+			ogs := v1.OperatorGroupList{}
+			if err := u.config.Client.List(ctx, &ogs, client.InNamespace(u.config.Namespace)); err != nil {
+				return fmt.Errorf("list operatorgroups: %v", err)
+			}
+			for _, og := range ogs.Items {
+				og := og
+				if len(u.DeleteOperatorGroupNames) == 0 || slice.ContainsString(u.DeleteOperatorGroupNames, og.GetName(), nil) {
+					if err := u.deleteObjects(ctx, false, &og); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	if ns != nil {
+		return u.deleteNamespace(ctx, ns)
+	}
+	return nil
+}
+
+// deleteSubscription deletes sub, the objects its install plan created, and its
+// catalog source if created by the SDK, along with the Subscriptions OLM created
+// from that catalog source for the operator's dependencies.
+func (u *Uninstall) deleteSubscription(ctx context.Context, sub *v1alpha1.Subscription) error {
 	catsrcKey := types.NamespacedName{
 		Namespace: sub.Spec.CatalogSourceNamespace,
 		Name:      sub.Spec.CatalogSource,
 	}
 	catsrc := &v1alpha1.CatalogSource{}
-	if err := u.config.Client.Get(ctx, catsrcKey, catsrc); err != nil {
-		return fmt.Errorf("get catalog source: %v", err)
+	if sub.GetAnnotations()[ExistingCatalogSourceAnnotation] == "true" {
+		// The catalog source was not created by the SDK, so may serve other operators.
+		u.Logf("catalog source %q was not created by operator-sdk, skipping deletion", catsrcKey)
+		catsrc = nil
+	} else if err := u.config.Client.Get(ctx, catsrcKey, catsrc); err != nil {
+		if !u.sharedCatalog || !apierrors.IsNotFound(err) {
+			return fmt.Errorf("get catalog source: %v", err)
+		}
+		// Deleted while uninstalling another package served by it.
+		catsrc = nil
+	} else {
+		catsrc.SetGroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind(v1alpha1.CatalogSourceKind))
+	}
+
+	// OLM creates Subscriptions for dependencies it resolves from the catalog,
+	// which are deleted with it so that OLM does not reinstall their CSVs.
+	subs := []*v1alpha1.Subscription{sub}
+	if catsrc != nil && !u.sharedCatalog {
+		resolved, err := u.getResolvedSubscriptions(ctx, sub)
+		if err != nil {
+			return err
+		}
+		subs = append(subs, resolved...)
 	}
-	catsrc.SetGroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind(v1alpha1.CatalogSourceKind))
 
 	// Since the install plan is owned by the subscription, we need to
 	// read all of the resource references from the install plan before
 	// deleting the subscription.
-	var crds, csvs, others []controllerutil.Object
-	if sub.Status.InstallPlanRef != nil {
-		ipKey := types.NamespacedName{
-			Namespace: sub.Status.InstallPlanRef.Namespace,
-			Name:      sub.Status.InstallPlanRef.Name,
-		}
-		var err error
-		crds, csvs, others, err = u.getInstallPlanResources(ctx, ipKey)
+	var subObjs, crds, csvs, others []controllerutil.Object
+	for _, s := range subs {
+		sCRDs, sCSVs, sOthers, err := u.getSubscriptionResources(ctx, s)
 		if err != nil {
-			return fmt.Errorf("get install plan resources: %v", err)
+			return err
 		}
+		subObjs = append(subObjs, s)
+		crds, csvs, others = append(crds, sCRDs...), append(csvs, sCSVs...), append(others, sOthers...)
 	}
 
-	// Delete the subscription first, so that no further installs or upgrades
+	// Delete the subscriptions first, so that no further installs or upgrades
 	// of the operator occur while we're cleaning up.
-	if err := u.deleteObjects(ctx, false, sub); err != nil {
+	if err := u.deleteObjects(ctx, false, subObjs...); err != nil {
 		return err
 	}
 
@@ -120,37 +349,272 @@ func (u *Uninstall) Run(ctx context.Context) error {
 		return err
 	}
 
-	// Delete the catalog source. This assumes that all underlying resources related
-	// to this catalog source have an owner reference to this catalog source so that
-	// they are automatically garbage-collected.
-	if err := u.deleteObjects(ctx, true, catsrc); err != nil {
-		return err
+	if catsrc != nil {
+		return u.deleteCatalogSource(ctx, catsrc)
 	}
+	return nil
+}
 
-	// If this was the last subscription in the namespace and the operator group is
-	// the one we created, delete it
-	if u.DeleteOperatorGroups {
-		if err := u.config.Client.List(ctx, &subs, client.InNamespace(u.config.Namespace)); err != nil {
-			return fmt.Errorf("list subscriptions: %v", err)
+// getResolvedSubscriptions returns the Subscriptions other than sub in sub's
+// namespace to the same catalog source, which OLM created for dependencies.
+func (u *Uninstall) getResolvedSubscriptions(ctx context.Context, sub *v1alpha1.Subscription) ([]*v1alpha1.Subscription, error) {
+	subs := v1alpha1.SubscriptionList{}
+	if err := u.config.Client.List(ctx, &subs, client.InNamespace(sub.GetNamespace())); err != nil {
+		return nil, fmt.Errorf("list subscriptions: %v", err)
+	}
+	var resolved []*v1alpha1.Subscription
+	for i := range subs.Items {
+		s := &subs.Items[i]
+		if s.GetName() == sub.GetName() || s.Spec == nil ||
+			s.Spec.CatalogSource != sub.Spec.CatalogSource || s.Spec.CatalogSourceNamespace != sub.Spec.CatalogSourceNamespace {
+			continue
 		}
-		if len(subs.Items) == 0 {
-			ogs := v1.OperatorGroupList{}
-			if err := u.config.Client.List(ctx, &ogs, client.InNamespace(u.config.Namespace)); err != nil {
-				return fmt.Errorf("list operatorgroups: %v", err)
+		u.Logf("deleting subscription %q to package %q resolved from catalog source %q",
+			s.GetName(), s.Spec.Package, sub.Spec.CatalogSource)
+		resolved = append(resolved, s)
+	}
+	return resolved, nil
+}
+
+// getSubscriptionResources returns the objects created by sub's install plan.
+func (u *Uninstall) getSubscriptionResources(ctx context.Context, sub *v1alpha1.Subscription) (crds, csvs, others []controllerutil.Object, err error) {
+	if sub.Status.InstallPlanRef != nil {
+		ipKey := types.NamespacedName{
+			Namespace: sub.Status.InstallPlanRef.Namespace,
+			Name:      sub.Status.InstallPlanRef.Name,
+		}
+		if crds, csvs, others, err = u.getInstallPlanResources(ctx, ipKey); err != nil {
+			return nil, nil, nil, fmt.Errorf("get install plan resources: %v", err)
+		}
+	}
+	// CSV names are not guaranteed to be derived from the package name, so if the
+	// install plan yielded no CSV fall back to the one recorded in the Subscription's
+	// status rather than guessing.
+	if len(csvs) == 0 {
+		if csv := getSubscriptionCSV(sub); csv != nil {
+			csvs = append(csvs, csv)
+		}
+	}
+	return crds, csvs, others, nil
+}
+
+// getSDKCatalogSources returns the catalog sources in the configured namespace
+// labeled as created by the SDK for Package.
+func (u *Uninstall) getSDKCatalogSources(ctx context.Context) ([]v1alpha1.CatalogSource, error) {
+	catsrcs := v1alpha1.CatalogSourceList{}
+	if err := u.config.Client.List(ctx, &catsrcs, client.InNamespace(u.config.Namespace),
+		client.MatchingLabels(configmap.MakeRegistryLabels(u.Package))); err != nil {
+		return nil, fmt.Errorf("list catalog sources: %v", err)
+	}
+	return catsrcs.Items, nil
+}
+
+// deleteOrphanedCatalogs deletes catalogs whose Subscription no longer exists,
+// and the CSVs recorded as installed from them. CRDs and other objects the
+// install plan created can't be found without the Subscription, so remain.
+func (u *Uninstall) deleteOrphanedCatalogs(ctx context.Context, catalogs []v1alpha1.CatalogSource) error {
+	for i := range catalogs {
+		catsrc := &catalogs[i]
+		catsrc.SetGroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind(v1alpha1.CatalogSourceKind))
+		if name := catsrc.GetAnnotations()[InstalledCSVAnnotation]; name != "" {
+			csv := &v1alpha1.ClusterServiceVersion{}
+			csv.SetGroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind(v1alpha1.ClusterServiceVersionKind))
+			csv.SetName(name)
+			csv.SetNamespace(catsrc.GetNamespace())
+			if err := u.deleteObjects(ctx, true, csv); err != nil {
+				return err
 			}
-			for _, og := range ogs.Items {
-				og := og
-				if len(u.DeleteOperatorGroupNames) == 0 || slice.ContainsString(u.DeleteOperatorGroupNames, og.GetName(), nil) {
-					if err := u.deleteObjects(ctx, false, &og); err != nil {
-						return err
-					}
-				}
+		}
+		if err := u.deleteCatalogSource(ctx, catsrc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteCatalogSource deletes catsrc and the registry and bundle unpack objects
+// owned by it. OLM does not label the ConfigMaps it unpacks bundles into, or the
+// Jobs unpacking them, so they are found by owner reference. Deleting them before
+// catsrc rather than leaving them to garbage collection means their Pods have
+// terminated once catsrc is deleted.
+func (u *Uninstall) deleteCatalogSource(ctx context.Context, catsrc *v1alpha1.CatalogSource) error {
+	opts := client.InNamespace(catsrc.GetNamespace())
+	cms := corev1.ConfigMapList{}
+	if err := u.config.Client.List(ctx, &cms, opts); err != nil {
+		return fmt.Errorf("list bundle unpack configmaps: %v", err)
+	}
+	jobs := batchv1.JobList{}
+	if err := u.config.Client.List(ctx, &jobs, opts); err != nil {
+		return fmt.Errorf("list bundle unpack jobs: %v", err)
+	}
+	pods := corev1.PodList{}
+	if err := u.config.Client.List(ctx, &pods, opts); err != nil {
+		return fmt.Errorf("list catalog source pods: %v", err)
+	}
+
+	// Unpack Jobs are owned by unpack ConfigMaps, and registry Pods by catsrc.
+	var objs []controllerutil.Object
+	owners := map[types.UID]bool{catsrc.GetUID(): true}
+	var unpackCMs []controllerutil.Object
+	for i := range cms.Items {
+		if IsOwnedByAny(&cms.Items[i], owners) {
+			cms.Items[i].SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+			unpackCMs = append(unpackCMs, &cms.Items[i])
+		}
+	}
+	for _, cm := range unpackCMs {
+		owners[cm.GetUID()] = true
+	}
+	for i := range jobs.Items {
+		if IsOwnedByAny(&jobs.Items[i], owners) {
+			jobs.Items[i].SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+			objs = append(objs, &jobs.Items[i])
+			owners[jobs.Items[i].GetUID()] = true
+		}
+	}
+	for i := range pods.Items {
+		if IsOwnedByAny(&pods.Items[i], owners) {
+			pods.Items[i].SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Pod"))
+			objs = append(objs, &pods.Items[i])
+		}
+	}
+	objs = append(append(objs, unpackCMs...), catsrc)
+	return u.deleteObjects(ctx, true, objs...)
+}
+
+// IsOwnedByAny returns true if one of obj's owner references has a UID in owners.
+func IsOwnedByAny(obj controllerutil.Object, owners map[types.UID]bool) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID != "" && owners[ref.UID] {
+			return true
+		}
+	}
+	return false
+}
+
+// getDeletableNamespace returns the configured namespace if it may be deleted.
+func (u *Uninstall) getDeletableNamespace(ctx context.Context) (*corev1.Namespace, error) {
+	name := u.config.Namespace
+	if slice.ContainsString(protectedNamespaces, name, nil) {
+		return nil, fmt.Errorf("refusing to delete namespace %q", name)
+	}
+	ns := &corev1.Namespace{}
+	if err := u.config.Client.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+		return nil, fmt.Errorf("get namespace: %v", err)
+	}
+	if !u.Force {
+		labels := ns.GetLabels()
+		for k, v := range configmap.SDKLabels {
+			if labels[k] != v {
+				return nil, fmt.Errorf("refusing to delete namespace %q not labeled %s=%s, use --force to delete it anyway", name, k, v)
 			}
 		}
 	}
+	ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+	return ns, nil
+}
+
+// deleteNamespace deletes ns and waits for it to leave the Terminating phase.
+// If ctx is done first, the returned error describes what is blocking
+// namespace finalization.
+func (u *Uninstall) deleteNamespace(ctx context.Context, ns *corev1.Namespace) error {
+	if err := u.deleteObjects(ctx, true, ns); err != nil {
+		if ctx.Err() == nil {
+			return err
+		}
+		return fmt.Errorf("%v: %s", err, u.getNamespaceBlockers(ns.GetName()))
+	}
 	return nil
 }
 
+// getNamespaceBlockers returns a summary of the content and finalizers
+// preventing namespace name from being deleted. A fresh context is used since
+// the uninstall context has likely expired.
+func (u *Uninstall) getNamespaceBlockers(name string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ns := &corev1.Namespace{}
+	if err := u.config.Client.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+		return fmt.Sprintf("unable to get namespace status: %v", err)
+	}
+	var blockers []string
+	for _, c := range ns.Status.Conditions {
+		switch c.Type {
+		case corev1.NamespaceContentRemaining, corev1.NamespaceFinalizersRemaining,
+			corev1.NamespaceDeletionContentFailure, corev1.NamespaceDeletionDiscoveryFailure:
+			if c.Status == corev1.ConditionTrue {
+				blockers = append(blockers, c.Message)
+			}
+		}
+	}
+	for _, f := range ns.Spec.Finalizers {
+		blockers = append(blockers, fmt.Sprintf("namespace finalizer %q remains", f))
+	}
+	if len(blockers) == 0 {
+		return fmt.Sprintf("namespace %q is in phase %q", name, ns.Status.Phase)
+	}
+	return fmt.Sprintf("namespace %q is blocked: %s", name, strings.Join(blockers, "; "))
+}
+
+// deleteRegistryObjects deletes all ConfigMaps and Pods labeled as belonging
+// to u.Package's operator-sdk registry.
+func (u *Uninstall) deleteRegistryObjects(ctx context.Context) error {
+	opts := []client.ListOption{
+		client.InNamespace(u.config.Namespace),
+		client.MatchingLabels(configmap.MakeRegistryLabels(u.Package)),
+	}
+	cms := corev1.ConfigMapList{}
+	if err := u.config.Client.List(ctx, &cms, opts...); err != nil {
+		return fmt.Errorf("list registry configmaps: %v", err)
+	}
+	pods := corev1.PodList{}
+	if err := u.config.Client.List(ctx, &pods, opts...); err != nil {
+		return fmt.Errorf("list registry pods: %v", err)
+	}
+	objs := make([]controllerutil.Object, 0, len(cms.Items)+len(pods.Items))
+	for i := range cms.Items {
+		cms.Items[i].SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+		objs = append(objs, &cms.Items[i])
+	}
+	for i := range pods.Items {
+		pods.Items[i].SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Pod"))
+		objs = append(objs, &pods.Items[i])
+	}
+	return u.deleteObjects(ctx, true, objs...)
+}
+
+// deleteRegistryServiceAccount deletes the registry ServiceAccount the SDK creates
+// if no remaining Pods in the namespace run as it, since it is shared by the
+// registry pods of every package. ServiceAccounts the SDK did not create are kept.
+func (u *Uninstall) deleteRegistryServiceAccount(ctx context.Context) error {
+	sa := &corev1.ServiceAccount{}
+	key := types.NamespacedName{Namespace: u.config.Namespace, Name: SDKRegistryServiceAccountName}
+	if err := u.config.Client.Get(ctx, key, sa); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("get registry service account: %v", err)
+	}
+	labels := sa.GetLabels()
+	for k, v := range configmap.SDKLabels {
+		if labels[k] != v {
+			return nil
+		}
+	}
+	pods := corev1.PodList{}
+	if err := u.config.Client.List(ctx, &pods, client.InNamespace(u.config.Namespace)); err != nil {
+		return fmt.Errorf("list pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.ServiceAccountName == sa.GetName() {
+			return nil
+		}
+	}
+	sa.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ServiceAccount"))
+	return u.deleteObjects(ctx, false, sa)
+}
+
 func (u *Uninstall) deleteObjects(ctx context.Context, waitForDelete bool, objs ...controllerutil.Object) error {
 	for _, obj := range objs {
 		obj := obj
@@ -180,6 +644,23 @@ func (u *Uninstall) deleteObjects(ctx context.Context, waitForDelete bool, objs
 	return nil
 }
 
+// getSubscriptionCSV returns the CSV installed by sub as recorded in its status,
+// or nil if none is recorded.
+func getSubscriptionCSV(sub *v1alpha1.Subscription) controllerutil.Object {
+	name := sub.Status.InstalledCSV
+	if name == "" {
+		name = sub.Status.CurrentCSV
+	}
+	if name == "" {
+		return nil
+	}
+	csv := &v1alpha1.ClusterServiceVersion{}
+	csv.SetGroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind(v1alpha1.ClusterServiceVersionKind))
+	csv.SetName(name)
+	csv.SetNamespace(sub.GetNamespace())
+	return csv
+}
+
 func (u *Uninstall) getInstallPlanResources(ctx context.Context, installPlanKey types.NamespacedName) (crds, csvs, others []controllerutil.Object, err error) {
 	installPlan := &v1alpha1.InstallPlan{}
 	if err := u.config.Client.Get(ctx, installPlanKey, installPlan); err != nil {
@@ -0,0 +1,532 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "github.com/operator-framework/api/pkg/operators/v1"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
+)
+
+var _ = Describe("Uninstall", func() {
+	const (
+		ns      = "testns"
+		pkgName = "acme-thing"
+		// CSV names with a vendor prefix that differs from the package name.
+		csvName      = "acme-thing-operator.v0.0.1"
+		otherPkgName = "acme-thing-operator"
+		otherCSVName = "acme-thing-operator-other.v0.0.1"
+	)
+
+	var (
+		cfg *Configuration
+		u   *Uninstall
+	)
+
+	newCSV := func(name string) *v1alpha1.ClusterServiceVersion {
+		csv := &v1alpha1.ClusterServiceVersion{}
+		csv.SetGroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind(v1alpha1.ClusterServiceVersionKind))
+		csv.SetName(name)
+		csv.SetNamespace(ns)
+		return csv
+	}
+	newSub := func(name, pkg string) *v1alpha1.Subscription {
+		sub := &v1alpha1.Subscription{}
+		sub.SetName(name)
+		sub.SetNamespace(ns)
+		sub.Spec = &v1alpha1.SubscriptionSpec{Package: pkg, CatalogSource: pkg + "-catalog", CatalogSourceNamespace: ns}
+		return sub
+	}
+	newCatalogSource := func(name string) *v1alpha1.CatalogSource {
+		cs := &v1alpha1.CatalogSource{}
+		cs.SetName(name)
+		cs.SetNamespace(ns)
+		return cs
+	}
+	csvExists := func(name string) bool {
+		err := cfg.Client.Get(context.TODO(), types.NamespacedName{Namespace: ns, Name: name}, &v1alpha1.ClusterServiceVersion{})
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+
+	BeforeEach(func() {
+		sch := runtime.NewScheme()
+		Expect(corev1.AddToScheme(sch)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(sch)).To(Succeed())
+		Expect(v1.AddToScheme(sch)).To(Succeed())
+		Expect(batchv1.AddToScheme(sch)).To(Succeed())
+		cfg = &Configuration{
+			Scheme:    sch,
+			Client:    fake.NewFakeClientWithScheme(sch),
+			Namespace: ns,
+		}
+		u = NewUninstall(cfg)
+		u.Package = pkgName
+		u.Logf = func(string, ...interface{}) {}
+
+		// A package whose name is a prefix-match for the CSV above must not be touched.
+		otherSub := newSub("other-sub", otherPkgName)
+		otherSub.Status.InstalledCSV = otherCSVName
+		Expect(cfg.Client.Create(context.TODO(), otherSub)).To(Succeed())
+		Expect(cfg.Client.Create(context.TODO(), newCatalogSource(otherPkgName+"-catalog"))).To(Succeed())
+		Expect(cfg.Client.Create(context.TODO(), newCSV(otherCSVName))).To(Succeed())
+	})
+
+	It("should return ErrPackageNotFound if no subscription exists for the package", func() {
+		u.Package = "not-a-package"
+		err := u.Run(context.TODO())
+		Expect(errors.Is(err, ErrPackageNotFound)).To(BeTrue())
+	})
+
+	It("should discover a mismatched CSV name through the install plan", func() {
+		csv := newCSV(csvName)
+		Expect(cfg.Client.Create(context.TODO(), csv)).To(Succeed())
+		manifest, err := yaml.Marshal(csv)
+		Expect(err).NotTo(HaveOccurred())
+		ip := &v1alpha1.InstallPlan{}
+		ip.SetName("install-abcde")
+		ip.SetNamespace(ns)
+		ip.Status.Plan = []*v1alpha1.Step{{
+			Resource: v1alpha1.StepResource{
+				Group:    v1alpha1.GroupName,
+				Version:  v1alpha1.GroupVersion,
+				Kind:     v1alpha1.ClusterServiceVersionKind,
+				Name:     csvName,
+				Manifest: string(manifest),
+			},
+			Status: v1alpha1.StepStatusCreated,
+		}}
+		Expect(cfg.Client.Create(context.TODO(), ip)).To(Succeed())
+
+		sub := newSub("acme-sub", pkgName)
+		sub.Status.InstallPlanRef = &corev1.ObjectReference{Namespace: ns, Name: ip.GetName()}
+		Expect(cfg.Client.Create(context.TODO(), sub)).To(Succeed())
+		Expect(cfg.Client.Create(context.TODO(), newCatalogSource(pkgName+"-catalog"))).To(Succeed())
+
+		Expect(u.Run(context.TODO())).To(Succeed())
+		Expect(csvExists(csvName)).To(BeFalse())
+		Expect(csvExists(otherCSVName)).To(BeTrue())
+	})
+
+	It("should discover a mismatched CSV name through subscription status", func() {
+		Expect(cfg.Client.Create(context.TODO(), newCSV(csvName))).To(Succeed())
+		sub := newSub("acme-sub", pkgName)
+		sub.Status.InstalledCSV = csvName
+		Expect(cfg.Client.Create(context.TODO(), sub)).To(Succeed())
+		Expect(cfg.Client.Create(context.TODO(), newCatalogSource(pkgName+"-catalog"))).To(Succeed())
+
+		Expect(u.Run(context.TODO())).To(Succeed())
+		Expect(csvExists(csvName)).To(BeFalse())
+		Expect(csvExists(otherCSVName)).To(BeTrue())
+	})
+
+	It("should not delete a catalog source the SDK did not create", func() {
+		Expect(cfg.Client.Create(context.TODO(), newCSV(csvName))).To(Succeed())
+		sub := newSub("acme-sub", pkgName)
+		sub.SetAnnotations(map[string]string{ExistingCatalogSourceAnnotation: "true"})
+		sub.Status.InstalledCSV = csvName
+		Expect(cfg.Client.Create(context.TODO(), sub)).To(Succeed())
+		Expect(cfg.Client.Create(context.TODO(), newCatalogSource(pkgName+"-catalog"))).To(Succeed())
+
+		Expect(u.Run(context.TODO())).To(Succeed())
+		Expect(csvExists(csvName)).To(BeFalse())
+		key := types.NamespacedName{Namespace: ns, Name: pkgName + "-catalog"}
+		Expect(cfg.Client.Get(context.TODO(), key, &v1alpha1.CatalogSource{})).To(Succeed())
+	})
+
+	Context("with bundle unpack objects", func() {
+		var cs *v1alpha1.CatalogSource
+
+		// ownedBy sets an owner reference on obj to owner, as OLM and the
+		// job controller do, since the fake client does not set UIDs.
+		ownedBy := func(obj, owner metav1.Object) {
+			owner.SetUID(types.UID(owner.GetName() + "-uid"))
+			obj.SetOwnerReferences([]metav1.OwnerReference{{Name: owner.GetName(), UID: owner.GetUID()}})
+		}
+		exists := func(name string, obj runtime.Object) bool {
+			err := cfg.Client.Get(context.TODO(), types.NamespacedName{Namespace: ns, Name: name}, obj)
+			if apierrors.IsNotFound(err) {
+				return false
+			}
+			Expect(err).NotTo(HaveOccurred())
+			return true
+		}
+
+		BeforeEach(func() {
+			cs = newCatalogSource(pkgName + "-catalog")
+			cm := &corev1.ConfigMap{}
+			cm.SetName("unpack-cm")
+			cm.SetNamespace(ns)
+			ownedBy(cm, cs)
+			job := &batchv1.Job{}
+			job.SetName("unpack-job")
+			job.SetNamespace(ns)
+			ownedBy(job, cm)
+			unpackPod := &corev1.Pod{}
+			unpackPod.SetName("unpack-pod")
+			unpackPod.SetNamespace(ns)
+			ownedBy(unpackPod, job)
+			registryPod := &corev1.Pod{}
+			registryPod.SetName("registry-pod")
+			registryPod.SetNamespace(ns)
+			ownedBy(registryPod, cs)
+			unrelated := &corev1.ConfigMap{}
+			unrelated.SetName("unrelated-cm")
+			unrelated.SetNamespace(ns)
+			for _, obj := range []runtime.Object{cm, job, unpackPod, registryPod, unrelated, newCSV(csvName)} {
+				Expect(cfg.Client.Create(context.TODO(), obj)).To(Succeed())
+			}
+		})
+
+		It("should delete unpack and registry objects owned by the catalog source", func() {
+			sub := newSub("acme-sub", pkgName)
+			sub.Status.InstalledCSV = csvName
+			Expect(cfg.Client.Create(context.TODO(), sub)).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), cs)).To(Succeed())
+
+			Expect(u.Run(context.TODO())).To(Succeed())
+			Expect(exists("unpack-cm", &corev1.ConfigMap{})).To(BeFalse())
+			Expect(exists("unpack-job", &batchv1.Job{})).To(BeFalse())
+			Expect(exists("unpack-pod", &corev1.Pod{})).To(BeFalse())
+			Expect(exists("registry-pod", &corev1.Pod{})).To(BeFalse())
+			Expect(exists(cs.GetName(), &v1alpha1.CatalogSource{})).To(BeFalse())
+			Expect(exists("unrelated-cm", &corev1.ConfigMap{})).To(BeTrue())
+		})
+
+		It("should fall back to labeled catalog sources if the subscription was deleted", func() {
+			cs.SetLabels(configmap.MakeRegistryLabels(pkgName))
+			cs.SetAnnotations(map[string]string{InstalledCSVAnnotation: csvName})
+			Expect(cfg.Client.Create(context.TODO(), cs)).To(Succeed())
+
+			Expect(u.Run(context.TODO())).To(Succeed())
+			Expect(csvExists(csvName)).To(BeFalse())
+			Expect(csvExists(otherCSVName)).To(BeTrue())
+			Expect(exists("unpack-job", &batchv1.Job{})).To(BeFalse())
+			Expect(exists("unpack-pod", &corev1.Pod{})).To(BeFalse())
+			Expect(exists("registry-pod", &corev1.Pod{})).To(BeFalse())
+			Expect(exists(cs.GetName(), &v1alpha1.CatalogSource{})).To(BeFalse())
+			Expect(exists(otherPkgName+"-catalog", &v1alpha1.CatalogSource{})).To(BeTrue())
+			Expect(exists("unrelated-cm", &corev1.ConfigMap{})).To(BeTrue())
+		})
+
+		It("should return ErrPackageNotFound if an unlabeled catalog source remains", func() {
+			Expect(cfg.Client.Create(context.TODO(), cs)).To(Succeed())
+
+			err := u.Run(context.TODO())
+			Expect(errors.Is(err, ErrPackageNotFound)).To(BeTrue())
+			Expect(csvExists(csvName)).To(BeTrue())
+		})
+	})
+
+	Context("with the registry service account", func() {
+		newServiceAccount := func(labels map[string]string) *corev1.ServiceAccount {
+			sa := &corev1.ServiceAccount{}
+			sa.SetName(SDKRegistryServiceAccountName)
+			sa.SetNamespace(ns)
+			sa.SetLabels(labels)
+			return sa
+		}
+		saExists := func() bool {
+			key := types.NamespacedName{Namespace: ns, Name: SDKRegistryServiceAccountName}
+			err := cfg.Client.Get(context.TODO(), key, &corev1.ServiceAccount{})
+			if apierrors.IsNotFound(err) {
+				return false
+			}
+			Expect(err).NotTo(HaveOccurred())
+			return true
+		}
+
+		BeforeEach(func() {
+			sub := newSub("acme-sub", pkgName)
+			sub.Status.InstalledCSV = csvName
+			Expect(cfg.Client.Create(context.TODO(), sub)).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newCatalogSource(pkgName+"-catalog"))).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newCSV(csvName))).To(Succeed())
+		})
+
+		It("should delete the service account the SDK created", func() {
+			Expect(cfg.Client.Create(context.TODO(), newServiceAccount(configmap.SDKLabels))).To(Succeed())
+			Expect(u.Run(context.TODO())).To(Succeed())
+			Expect(saExists()).To(BeFalse())
+		})
+		It("should keep the service account while another registry pod runs as it", func() {
+			Expect(cfg.Client.Create(context.TODO(), newServiceAccount(configmap.SDKLabels))).To(Succeed())
+			pod := &corev1.Pod{}
+			pod.SetName("other-registry-pod")
+			pod.SetNamespace(ns)
+			pod.Spec.ServiceAccountName = SDKRegistryServiceAccountName
+			Expect(cfg.Client.Create(context.TODO(), pod)).To(Succeed())
+
+			Expect(u.Run(context.TODO())).To(Succeed())
+			Expect(saExists()).To(BeTrue())
+		})
+		It("should keep a service account of the same name the SDK did not create", func() {
+			Expect(cfg.Client.Create(context.TODO(), newServiceAccount(nil))).To(Succeed())
+			Expect(u.Run(context.TODO())).To(Succeed())
+			Expect(saExists()).To(BeTrue())
+		})
+	})
+
+	Context("with AllNamespaces", func() {
+		// installIn creates pkgName's Subscription, CatalogSource, and CSV in namespace.
+		installIn := func(namespace string) {
+			sub := newSub("acme-sub", pkgName)
+			sub.SetNamespace(namespace)
+			sub.Spec.CatalogSourceNamespace = namespace
+			sub.Status.InstalledCSV = csvName
+			Expect(cfg.Client.Create(context.TODO(), sub)).To(Succeed())
+			cs := newCatalogSource(pkgName + "-catalog")
+			cs.SetNamespace(namespace)
+			Expect(cfg.Client.Create(context.TODO(), cs)).To(Succeed())
+			csv := newCSV(csvName)
+			csv.SetNamespace(namespace)
+			Expect(cfg.Client.Create(context.TODO(), csv)).To(Succeed())
+		}
+		csvExistsIn := func(namespace string) bool {
+			err := cfg.Client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: csvName}, &v1alpha1.ClusterServiceVersion{})
+			if apierrors.IsNotFound(err) {
+				return false
+			}
+			Expect(err).NotTo(HaveOccurred())
+			return true
+		}
+
+		BeforeEach(func() {
+			u.AllNamespaces = true
+		})
+
+		It("should uninstall a package installed outside the configured namespace", func() {
+			installIn("otherns")
+			var logs []string
+			u.Logf = func(format string, args ...interface{}) { logs = append(logs, fmt.Sprintf(format, args...)) }
+			Expect(u.Run(context.TODO())).To(Succeed())
+			Expect(csvExistsIn("otherns")).To(BeFalse())
+			Expect(csvExists(otherCSVName)).To(BeTrue())
+			Expect(logs).To(ContainElement(`Uninstalling package "acme-thing" from namespace "otherns"`))
+		})
+		It("should require Yes to uninstall from more than one namespace", func() {
+			installIn("otherns1")
+			installIn("otherns2")
+			err := u.Run(context.TODO())
+			Expect(err).To(MatchError(ContainSubstring("installed in 2 namespaces: otherns1, otherns2")))
+			Expect(csvExistsIn("otherns1")).To(BeTrue())
+			Expect(csvExistsIn("otherns2")).To(BeTrue())
+
+			u.Yes = true
+			Expect(u.Run(context.TODO())).To(Succeed())
+			Expect(csvExistsIn("otherns1")).To(BeFalse())
+			Expect(csvExistsIn("otherns2")).To(BeFalse())
+		})
+		It("should continue past and aggregate failures in individual namespaces", func() {
+			installIn("otherns1")
+			installIn("otherns2")
+			cs := &v1alpha1.CatalogSource{}
+			cs.SetName(pkgName + "-catalog")
+			cs.SetNamespace("otherns1")
+			Expect(cfg.Client.Delete(context.TODO(), cs)).To(Succeed())
+			u.Yes = true
+			err := u.Run(context.TODO())
+			Expect(err).To(MatchError(ContainSubstring(`namespace "otherns1": get catalog source`)))
+			Expect(csvExistsIn("otherns1")).To(BeTrue())
+			Expect(csvExistsIn("otherns2")).To(BeFalse())
+		})
+		It("should return ErrPackageNotFound if no namespace has a subscription for the package", func() {
+			u.Package = "not-a-package"
+			Expect(errors.Is(u.Run(context.TODO()), ErrPackageNotFound)).To(BeTrue())
+		})
+	})
+
+	Context("with dependencies resolved from the catalog", func() {
+		const (
+			depPkgName = "acme-dep"
+			depCSVName = "acme-dep-operator.v0.0.1"
+			// Named as OLM names the Subscriptions it creates for dependencies.
+			depSubName = "acme-dep-alpha-acme-thing-catalog-testns"
+		)
+
+		subExists := func(name string) bool {
+			err := cfg.Client.Get(context.TODO(), types.NamespacedName{Namespace: ns, Name: name}, &v1alpha1.Subscription{})
+			if apierrors.IsNotFound(err) {
+				return false
+			}
+			Expect(err).NotTo(HaveOccurred())
+			return true
+		}
+
+		var sub *v1alpha1.Subscription
+
+		BeforeEach(func() {
+			sub = newSub("acme-sub", pkgName)
+			sub.Status.InstalledCSV = csvName
+			depSub := newSub(depSubName, depPkgName)
+			depSub.Spec.CatalogSource = pkgName + "-catalog"
+			depSub.Status.InstalledCSV = depCSVName
+			Expect(cfg.Client.Create(context.TODO(), depSub)).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newCatalogSource(pkgName+"-catalog"))).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newCSV(csvName))).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newCSV(depCSVName))).To(Succeed())
+		})
+
+		It("should delete the dependencies' subscriptions and CSVs", func() {
+			Expect(cfg.Client.Create(context.TODO(), sub)).To(Succeed())
+			Expect(u.Run(context.TODO())).To(Succeed())
+			Expect(subExists(depSubName)).To(BeFalse())
+			Expect(csvExists(csvName)).To(BeFalse())
+			Expect(csvExists(depCSVName)).To(BeFalse())
+			Expect(subExists("other-sub")).To(BeTrue())
+			Expect(csvExists(otherCSVName)).To(BeTrue())
+		})
+		It("should not delete subscriptions to a catalog source the SDK did not create", func() {
+			sub.SetAnnotations(map[string]string{ExistingCatalogSourceAnnotation: "true"})
+			Expect(cfg.Client.Create(context.TODO(), sub)).To(Succeed())
+			Expect(u.Run(context.TODO())).To(Succeed())
+			Expect(csvExists(csvName)).To(BeFalse())
+			Expect(subExists(depSubName)).To(BeTrue())
+			Expect(csvExists(depCSVName)).To(BeTrue())
+		})
+	})
+
+	Context("with AdditionalPackages", func() {
+		const (
+			depPkgName = "acme-dep"
+			depCSVName = "acme-dep-operator.v0.0.1"
+		)
+
+		BeforeEach(func() {
+			// Both packages are served by pkgName's catalog.
+			sub := newSub("acme-sub", pkgName)
+			sub.Status.InstalledCSV = csvName
+			Expect(cfg.Client.Create(context.TODO(), sub)).To(Succeed())
+			depSub := newSub("acme-dep-sub", depPkgName)
+			depSub.Spec.CatalogSource = pkgName + "-catalog"
+			depSub.Status.InstalledCSV = depCSVName
+			Expect(cfg.Client.Create(context.TODO(), depSub)).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newCatalogSource(pkgName+"-catalog"))).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newCSV(csvName))).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newCSV(depCSVName))).To(Succeed())
+			u.AdditionalPackages = []string{depPkgName}
+		})
+
+		It("should uninstall additional packages in reverse order before the package", func() {
+			var logs []string
+			u.Logf = func(format string, args ...interface{}) { logs = append(logs, fmt.Sprintf(format, args...)) }
+			Expect(u.Run(context.TODO())).To(Succeed())
+			Expect(csvExists(csvName)).To(BeFalse())
+			Expect(csvExists(depCSVName)).To(BeFalse())
+			Expect(csvExists(otherCSVName)).To(BeTrue())
+			var order []string
+			for _, l := range logs {
+				if strings.HasPrefix(l, "Uninstalling package") {
+					order = append(order, l)
+				}
+			}
+			Expect(order).To(Equal([]string{`Uninstalling package "acme-dep"`, `Uninstalling package "acme-thing"`}))
+		})
+		It("should fail for an additional package that is not installed", func() {
+			u.AdditionalPackages = append(u.AdditionalPackages, "not-a-package")
+			err := u.Run(context.TODO())
+			Expect(errors.Is(err, ErrPackageNotFound)).To(BeTrue())
+			Expect(csvExists(csvName)).To(BeTrue())
+		})
+		It("should not be combined with AllNamespaces", func() {
+			u.AllNamespaces, u.Yes = true, true
+			Expect(u.Validate()).To(MatchError(ContainSubstring("AdditionalPackages")))
+		})
+	})
+
+	Context("with DeleteNamespace", func() {
+		var namespace *corev1.Namespace
+
+		namespaceExists := func() bool {
+			err := cfg.Client.Get(context.TODO(), types.NamespacedName{Name: ns}, &corev1.Namespace{})
+			if apierrors.IsNotFound(err) {
+				return false
+			}
+			Expect(err).NotTo(HaveOccurred())
+			return true
+		}
+
+		BeforeEach(func() {
+			sub := newSub("acme-sub", pkgName)
+			sub.Status.InstalledCSV = csvName
+			Expect(cfg.Client.Create(context.TODO(), sub)).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newCatalogSource(pkgName+"-catalog"))).To(Succeed())
+			Expect(cfg.Client.Create(context.TODO(), newCSV(csvName))).To(Succeed())
+
+			namespace = &corev1.Namespace{}
+			namespace.SetName(ns)
+			u.DeleteNamespace = true
+		})
+
+		It("should delete a namespace labeled as created by operator-sdk", func() {
+			namespace.SetLabels(map[string]string{"owner": "operator-sdk"})
+			Expect(cfg.Client.Create(context.TODO(), namespace)).To(Succeed())
+			Expect(u.Run(context.TODO())).To(Succeed())
+			Expect(namespaceExists()).To(BeFalse())
+		})
+		It("should refuse to delete an unlabeled namespace before deleting anything", func() {
+			Expect(cfg.Client.Create(context.TODO(), namespace)).To(Succeed())
+			err := u.Run(context.TODO())
+			Expect(err).To(MatchError(ContainSubstring("use --force")))
+			Expect(namespaceExists()).To(BeTrue())
+			Expect(csvExists(csvName)).To(BeTrue())
+		})
+		It("should delete an unlabeled namespace with Force", func() {
+			Expect(cfg.Client.Create(context.TODO(), namespace)).To(Succeed())
+			u.Force = true
+			Expect(u.Run(context.TODO())).To(Succeed())
+			Expect(namespaceExists()).To(BeFalse())
+		})
+		It("should never delete protected namespaces", func() {
+			for _, name := range []string{"default", "kube-system"} {
+				cfg.Namespace = name
+				u.Force = true
+				_, err := u.getDeletableNamespace(context.TODO())
+				Expect(err).To(MatchError(fmt.Sprintf("refusing to delete namespace %q", name)))
+			}
+		})
+		It("should report what is blocking namespace finalization", func() {
+			namespace.Spec.Finalizers = []corev1.FinalizerName{corev1.FinalizerKubernetes}
+			namespace.Status.Phase = corev1.NamespaceTerminating
+			namespace.Status.Conditions = []corev1.NamespaceCondition{{
+				Type:    corev1.NamespaceContentRemaining,
+				Status:  corev1.ConditionTrue,
+				Message: "Some resources are remaining: pods. has 1 resource instances",
+			}}
+			Expect(cfg.Client.Create(context.TODO(), namespace)).To(Succeed())
+			Expect(u.getNamespaceBlockers(ns)).To(Equal(`namespace "testns" is blocked: ` +
+				`Some resources are remaining: pods. has 1 resource instances; namespace finalizer "kubernetes" remains`))
+		})
+	})
+})
@@ -0,0 +1,278 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replay records sanitized Kubernetes API interactions during real
+// cluster runs, and replays them to drive the same code deterministically
+// without a cluster. A replay fails when the code issues a request that was
+// not recorded, which signals a behavior change that needs re-recording.
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RecordEnv is the environment variable containing the directory recordings
+// are written to. If unset, nothing is recorded.
+const RecordEnv = "OPERATOR_SDK_RECORD"
+
+// Cassette contains the interactions recorded for one scenario.
+type Cassette struct {
+	// Values are arbitrary inputs of the recorded scenario, like its
+	// namespace, that a replay must use to issue the same requests.
+	Values map[string]string `json:"values,omitempty"`
+	// Interactions are in the order their responses were received.
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response. Requests are matched by
+// Method, Path, and BodyHash only.
+type Interaction struct {
+	Method string `json:"method"`
+	// Path is the request URL's path and sorted query.
+	Path string `json:"path"`
+	// BodyHash is the sha256 digest of the sanitized request body.
+	BodyHash string `json:"bodyHash,omitempty"`
+	// RequestBody is the sanitized request body, to help debug mismatches.
+	RequestBody string `json:"requestBody,omitempty"`
+
+	StatusCode  int    `json:"statusCode"`
+	ContentType string `json:"contentType,omitempty"`
+	// Body is the sanitized response body.
+	Body string `json:"body,omitempty"`
+}
+
+func (i Interaction) key() string {
+	return i.Method + " " + i.Path + " " + i.BodyHash
+}
+
+// LoadCassette reads a Cassette saved by a Recorder from path.
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("error decoding recording %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// FileName returns the recording file name of the test or scenario name.
+func FileName(name string) string {
+	return strings.NewReplacer("/", "_", " ", "_").Replace(name) + ".json"
+}
+
+// Recorder records the interactions of each transport it wraps.
+type Recorder struct {
+	mu       sync.Mutex
+	san      *sanitizer
+	cassette Cassette
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		san:      newSanitizer(),
+		cassette: Cassette{Values: map[string]string{}},
+	}
+}
+
+// Set records a scenario input value.
+func (r *Recorder) Set(key, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Values[key] = value
+}
+
+// Wrap returns a transport recording requests made with rt. Responses are
+// recorded sanitized and returned to the caller unchanged.
+func (r *Recorder) Wrap(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		reqBody, err := readRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		respBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+		in := newInteraction(req, r.san.body(reqBody))
+		in.StatusCode = resp.StatusCode
+		in.ContentType = resp.Header.Get("Content-Type")
+		in.Body = string(r.san.body(respBody))
+		r.mu.Lock()
+		r.cassette.Interactions = append(r.cassette.Interactions, in)
+		r.mu.Unlock()
+		return resp, nil
+	})
+}
+
+// Save writes all recorded interactions to path.
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// Replayer serves recorded responses to requests matching recorded
+// interactions. Matching interactions are served in recorded order, so
+// requests repeated while polling see the same sequence of responses.
+type Replayer struct {
+	mu        sync.Mutex
+	san       *sanitizer
+	queues    map[string][]Interaction
+	unmatched []string
+}
+
+func NewReplayer(c *Cassette) *Replayer {
+	p := &Replayer{san: newSanitizer(), queues: map[string][]Interaction{}}
+	for _, in := range c.Interactions {
+		p.queues[in.key()] = append(p.queues[in.key()], in)
+	}
+	return p
+}
+
+// Wrap returns p, ignoring rt so no requests reach a cluster.
+func (p *Replayer) Wrap(http.RoundTripper) http.RoundTripper {
+	return p
+}
+
+func (p *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	want := newInteraction(req, p.san.body(reqBody))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	queue := p.queues[want.key()]
+	if len(queue) == 0 {
+		msg := fmt.Sprintf("%s %s (body sha256 %q)", want.Method, want.Path, want.BodyHash)
+		if want.RequestBody != "" {
+			msg += ": " + want.RequestBody
+		}
+		p.unmatched = append(p.unmatched, msg)
+		return nil, fmt.Errorf("replay: unrecorded request %s; if this change is intended, re-record with %s set", msg, RecordEnv)
+	}
+	in := queue[0]
+	p.queues[want.key()] = queue[1:]
+
+	header := http.Header{}
+	if in.ContentType != "" {
+		header.Set("Content-Type", in.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+		StatusCode:    in.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(in.Body)),
+		ContentLength: int64(len(in.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Unmatched returns requests that matched no recorded interaction.
+func (p *Replayer) Unmatched() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.unmatched...)
+}
+
+// Unused returns the number of recorded interactions not yet served, by key.
+func (p *Replayer) Unused() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	unused := map[string]int{}
+	for key, queue := range p.queues {
+		if len(queue) != 0 {
+			unused[key] = len(queue)
+		}
+	}
+	return unused
+}
+
+func newInteraction(req *http.Request, body []byte) Interaction {
+	in := Interaction{
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		RequestBody: string(body),
+	}
+	if q := req.URL.Query(); len(q) != 0 {
+		in.Path += "?" + q.Encode()
+	}
+	if len(body) != 0 {
+		sum := sha256.Sum256(body)
+		in.BodyHash = hex.EncodeToString(sum[:])
+	}
+	return in
+}
+
+// readRequestBody returns req's body, replacing it so it can be read again.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const bearerToken = "s3cr3t-t0k3n"
+
+var _ = Describe("Replay", func() {
+	Describe("sanitizer", func() {
+		var s *sanitizer
+
+		BeforeEach(func() {
+			s = newSanitizer()
+		})
+
+		It("should map each UID and resourceVersion to a stable placeholder", func() {
+			b := s.body([]byte(`{"metadata":{"uid":"6a1c8dbb-1b4e-4d0e-9b0a-1f6e3a0c2d11","resourceVersion":"4711"},` +
+				`"ownerReferences":[{"uid":"6a1c8dbb-1b4e-4d0e-9b0a-1f6e3a0c2d11"},{"uid":"f1e2d3c4-b5a6-4978-8a9b-0c1d2e3f4a5b"}]}`))
+			Expect(string(b)).To(Equal(`{"metadata":{"resourceVersion":"replay-1","uid":"00000000-0000-0000-0000-000000000001"},` +
+				`"ownerReferences":[{"uid":"00000000-0000-0000-0000-000000000001"},{"uid":"00000000-0000-0000-0000-000000000002"}]}`))
+			Expect(string(s.body(b))).To(Equal(string(b)))
+		})
+		It("should replace timestamps and drop managed fields", func() {
+			b := s.body([]byte(`{"metadata":{"creationTimestamp":"2020-10-14T09:30:00Z","managedFields":[{"manager":"kubectl"}]}}`))
+			Expect(string(b)).To(Equal(`{"metadata":{"creationTimestamp":"1970-01-01T00:00:00Z"}}`))
+		})
+		It("should redact tokens and Secret data", func() {
+			b := s.body([]byte(`{"kind":"SecretList","items":[{"data":{"token":"` + bearerToken + `"},"stringData":{"password":"hunter2"}}],` +
+				`"status":{"token":"` + bearerToken + `"}}`))
+			Expect(string(b)).NotTo(ContainSubstring(bearerToken))
+			Expect(string(b)).NotTo(ContainSubstring("hunter2"))
+		})
+		It("should not change non-JSON bodies", func() {
+			Expect(string(s.body([]byte("ok")))).To(Equal("ok"))
+		})
+	})
+
+	Describe("Recorder and Replayer", func() {
+		var (
+			server   *httptest.Server
+			requests int
+			dir      string
+		)
+
+		// do makes a request with c, and returns the response status and body.
+		do := func(c *http.Client, method, url, body string) (int, string) {
+			req, err := http.NewRequest(method, url, strings.NewReader(body))
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer "+bearerToken)
+			resp, err := c.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			b, err := ioutil.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			return resp.StatusCode, string(b)
+		}
+
+		BeforeEach(func() {
+			requests = 0
+			// The server returns a new resourceVersion on each request, and a
+			// "Succeeded" phase from the third request on.
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				phase := "Pending"
+				if requests >= 3 {
+					phase = "Succeeded"
+				}
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"metadata":{"name":"foo","uid":"6a1c8dbb-1b4e-4d0e-9b0a-1f6e3a0c2d11","resourceVersion":"%d"},"status":{"phase":%q}}`,
+					1000+requests, phase)
+			}))
+			var err error
+			dir, err = ioutil.TempDir("", "replay-test-")
+			Expect(err).NotTo(HaveOccurred())
+		})
+		AfterEach(func() {
+			server.Close()
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("should replay recorded responses in order without a server", func() {
+			rec := NewRecorder()
+			rec.Set("namespace", "default")
+			c := &http.Client{Transport: rec.Wrap(http.DefaultTransport)}
+			_, created := do(c, http.MethodPost, server.URL+"/apis/foo/v1/foos", `{"metadata":{"name":"foo"}}`)
+			Expect(created).To(ContainSubstring(`"resourceVersion":"1001"`))
+			for i := 0; i < 2; i++ {
+				do(c, http.MethodGet, server.URL+"/apis/foo/v1/foos/foo?b=2&a=1", "")
+			}
+			path := filepath.Join(dir, FileName("Scenario/Basic"))
+			Expect(rec.Save(path)).To(Succeed())
+			b, err := ioutil.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(b)).NotTo(ContainSubstring(bearerToken))
+			Expect(string(b)).NotTo(ContainSubstring("6a1c8dbb"))
+
+			cassette, err := LoadCassette(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cassette.Values).To(HaveKeyWithValue("namespace", "default"))
+			server.Close()
+			p := NewReplayer(cassette)
+			c = &http.Client{Transport: p.Wrap(http.DefaultTransport)}
+			code, body := do(c, http.MethodPost, "http://replay.invalid/apis/foo/v1/foos", `{"metadata":{"name":"foo"}}`)
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(body).To(ContainSubstring(`"resourceVersion":"replay-1"`))
+			_, body = do(c, http.MethodGet, "http://replay.invalid/apis/foo/v1/foos/foo?a=1&b=2", "")
+			Expect(body).To(ContainSubstring(`"Pending"`))
+			_, body = do(c, http.MethodGet, "http://replay.invalid/apis/foo/v1/foos/foo?a=1&b=2", "")
+			Expect(body).To(ContainSubstring(`"Succeeded"`))
+			Expect(p.Unmatched()).To(BeEmpty())
+			Expect(p.Unused()).To(BeEmpty())
+		})
+		It("should fail requests that were not recorded", func() {
+			rec := NewRecorder()
+			c := &http.Client{Transport: rec.Wrap(http.DefaultTransport)}
+			do(c, http.MethodPost, server.URL+"/apis/foo/v1/foos", `{"metadata":{"name":"foo"}}`)
+
+			p := NewReplayer(&rec.cassette)
+			c = &http.Client{Transport: p}
+			req, err := http.NewRequest(http.MethodPost, "http://replay.invalid/apis/foo/v1/foos", strings.NewReader(`{"metadata":{"name":"bar"}}`))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = c.Do(req)
+			Expect(err).To(MatchError(ContainSubstring("unrecorded request POST /apis/foo/v1/foos")))
+			Expect(p.Unmatched()).To(HaveLen(1))
+			Expect(p.Unused()).To(HaveLen(1))
+		})
+	})
+})
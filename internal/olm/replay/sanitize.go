@@ -0,0 +1,165 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// uidPlaceholderPrefix prefixes placeholder UIDs, which remain valid UUIDs.
+	uidPlaceholderPrefix = "00000000-0000-0000-0000-"
+	// rvPlaceholderPrefix prefixes placeholder resourceVersions.
+	rvPlaceholderPrefix = "replay-"
+	// placeholderTime replaces all timestamps.
+	placeholderTime = "1970-01-01T00:00:00Z"
+	redactedValue   = "redacted"
+)
+
+var uidRegexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// sanitizer replaces cluster-specific values in JSON bodies with stable
+// placeholders, and redacts credentials. Each distinct UID and resourceVersion
+// is mapped to the same placeholder everywhere it appears, so references
+// between objects are preserved. Placeholders are left unchanged, so bodies
+// sent during a replay sanitize to the bodies that were recorded.
+type sanitizer struct {
+	mu   sync.Mutex
+	uids map[string]string
+	rvs  map[string]string
+}
+
+func newSanitizer() *sanitizer {
+	return &sanitizer{uids: map[string]string{}, rvs: map[string]string{}}
+}
+
+// body returns b sanitized and re-encoded with sorted keys. Non-JSON bodies
+// are returned unchanged.
+func (s *sanitizer) body(b []byte) []byte {
+	if len(b) == 0 {
+		return b
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return b
+	}
+	s.mu.Lock()
+	v = s.value("", v)
+	s.mu.Unlock()
+	out, err := json.Marshal(v)
+	if err != nil {
+		return b
+	}
+	return out
+}
+
+func (s *sanitizer) value(key string, v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		switch val["kind"] {
+		case "Secret":
+			redactSecret(val)
+		case "SecretList":
+			if items, ok := val["items"].([]interface{}); ok {
+				for _, item := range items {
+					if m, ok := item.(map[string]interface{}); ok {
+						redactSecret(m)
+					}
+				}
+			}
+		}
+		// Managed fields contain timestamps and client names, and are never
+		// read by the SDK.
+		delete(val, "managedFields")
+		// Visit keys in order so placeholders are numbered deterministically.
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			val[k] = s.value(k, val[k])
+		}
+		return val
+	case []interface{}:
+		for i := range val {
+			val[i] = s.value(key, val[i])
+		}
+		return val
+	case string:
+		return s.string(key, val)
+	}
+	return v
+}
+
+func (s *sanitizer) string(key, val string) string {
+	switch {
+	case val == "":
+		return val
+	case key == "token" || key == "access-token" || key == "id-token":
+		return redactedValue
+	case key == "resourceVersion":
+		if strings.HasPrefix(val, rvPlaceholderPrefix) {
+			return val
+		}
+		return placeholder(s.rvs, val, func(n int) string { return fmt.Sprintf("%s%d", rvPlaceholderPrefix, n) })
+	case uidRegexp.MatchString(val):
+		if strings.HasPrefix(val, uidPlaceholderPrefix) {
+			return val
+		}
+		return placeholder(s.uids, val, func(n int) string { return fmt.Sprintf("%s%012d", uidPlaceholderPrefix, n) })
+	case isTimestamp(val):
+		return placeholderTime
+	}
+	return val
+}
+
+func placeholder(seen map[string]string, val string, format func(int) string) string {
+	if p, ok := seen[val]; ok {
+		return p
+	}
+	p := format(len(seen) + 1)
+	seen[val] = p
+	return p
+}
+
+func isTimestamp(val string) bool {
+	if len(val) < len("2006-01-02T15:04:05Z") || val[4] != '-' || val[10] != 'T' {
+		return false
+	}
+	_, err := time.Parse(time.RFC3339, val)
+	return err == nil
+}
+
+// redactSecret replaces the values of a Secret's data and stringData.
+func redactSecret(secret map[string]interface{}) {
+	redacted := base64.StdEncoding.EncodeToString([]byte(redactedValue))
+	for field, value := range map[string]string{"data": redacted, "stringData": redactedValue} {
+		m, ok := secret[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k := range m {
+			m[k] = value
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Record returns a recorder for the scenario with name, and a function that
+// saves its recording to the directory in RecordEnv. If RecordEnv is unset,
+// both are nil. Recordings of failed scenarios are not saved.
+func Record(t testing.TB, name string) (*Recorder, func()) {
+	dir := os.Getenv(RecordEnv)
+	if dir == "" {
+		return nil, nil
+	}
+	r := NewRecorder()
+	return r, func() {
+		if t.Failed() {
+			t.Logf("Not saving recording of failed scenario %s", name)
+			return
+		}
+		path := filepath.Join(dir, FileName(name))
+		if err := r.Save(path); err != nil {
+			t.Errorf("Failed to save recording %s: %v", path, err)
+			return
+		}
+		t.Logf("Saved recording %s", path)
+	}
+}
+
+// Replay returns a replayer serving the recording at path, and a function
+// that fails t if any request did not match the recording. The test is
+// skipped if no recording exists.
+func Replay(t testing.TB, path string) (*Replayer, *Cassette, func()) {
+	c, err := LoadCassette(path)
+	if os.IsNotExist(err) {
+		t.Skipf("No recording at %s, record one by running the scenario against a cluster with %s set", path, RecordEnv)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewReplayer(c)
+	return p, c, func() {
+		for _, req := range p.Unmatched() {
+			t.Errorf("Request does not match recording %s: %s", path, req)
+		}
+		unused := p.Unused()
+		for _, key := range sortedKeys(unused) {
+			t.Logf("%d recorded responses to %s were not used", unused[key], key)
+		}
+	}
+}
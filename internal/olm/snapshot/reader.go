@@ -0,0 +1,173 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// NotCapturedError is returned by a snapshot Reader for kinds not captured in
+// the snapshot. Checks reading such kinds require a live cluster, and should
+// be reported as skipped.
+type NotCapturedError struct {
+	GVK schema.GroupVersionKind
+}
+
+func (e *NotCapturedError) Error() string {
+	return fmt.Sprintf("%s is not captured in the cluster snapshot", e.GVK.Kind)
+}
+
+// Reader serves reads from a Snapshot. Typed objects are decoded using scheme.
+type Reader struct {
+	snapshot *Snapshot
+	scheme   *runtime.Scheme
+}
+
+var _ client.Reader = &Reader{}
+
+// NewReader returns a Reader for s.
+func NewReader(s *Snapshot, scheme *runtime.Scheme) *Reader {
+	return &Reader{snapshot: s, scheme: scheme}
+}
+
+func (r *Reader) Get(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, r.scheme)
+	if err != nil {
+		return err
+	}
+	if !r.snapshot.IsCaptured(gvk) {
+		return &NotCapturedError{GVK: gvk}
+	}
+	for _, u := range r.snapshot.Objects {
+		if isKind(u, gvk) && u.GetNamespace() == key.Namespace && u.GetName() == key.Name {
+			return decode(u.DeepCopy(), obj)
+		}
+	}
+	return apierrors.NewNotFound(guessGroupResource(gvk), key.Name)
+}
+
+func (r *Reader) List(_ context.Context, list runtime.Object, opts ...client.ListOption) error {
+	listGVK, err := apiutil.GVKForObject(list, r.scheme)
+	if err != nil {
+		return err
+	}
+	gvk := listGVK.GroupVersion().WithKind(strings.TrimSuffix(listGVK.Kind, "List"))
+	if !r.snapshot.IsCaptured(gvk) {
+		return &NotCapturedError{GVK: gvk}
+	}
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	ul := &unstructured.UnstructuredList{}
+	ul.SetGroupVersionKind(listGVK)
+	for _, u := range r.snapshot.Objects {
+		if !isKind(u, gvk) {
+			continue
+		}
+		if listOpts.Namespace != "" && u.GetNamespace() != listOpts.Namespace {
+			continue
+		}
+		if listOpts.LabelSelector != nil && !listOpts.LabelSelector.Matches(labels.Set(u.GetLabels())) {
+			continue
+		}
+		ul.Items = append(ul.Items, *u.DeepCopy())
+	}
+	if target, ok := list.(*unstructured.UnstructuredList); ok {
+		*target = *ul
+		return nil
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(ul.UnstructuredContent(), list)
+}
+
+// isKind returns true if u has gvk's group and kind. Versions are not
+// compared, since snapshots capture the version preferred at export time.
+func isKind(u unstructured.Unstructured, gvk schema.GroupVersionKind) bool {
+	ugvk := u.GroupVersionKind()
+	return ugvk.Group == gvk.Group && ugvk.Kind == gvk.Kind
+}
+
+func decode(u *unstructured.Unstructured, obj runtime.Object) error {
+	if target, ok := obj.(*unstructured.Unstructured); ok {
+		*target = *u
+		return nil
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj)
+}
+
+func guessGroupResource(gvk schema.GroupVersionKind) schema.GroupResource {
+	plural, _ := meta.UnsafeGuessKindToResource(gvk)
+	return plural.GroupResource()
+}
+
+// errReadOnly is returned by writes to a client returned by Reader.Client.
+var errReadOnly = errors.New("cluster snapshots are read-only")
+
+// Client returns a client.Client that serves reads from r and fails all writes,
+// for checks that take a client.Client but only read.
+func (r *Reader) Client() client.Client {
+	return readOnlyClient{r}
+}
+
+var _ client.Client = readOnlyClient{}
+
+type readOnlyClient struct {
+	*Reader
+}
+
+func (readOnlyClient) Create(context.Context, runtime.Object, ...client.CreateOption) error {
+	return errReadOnly
+}
+
+func (readOnlyClient) Delete(context.Context, runtime.Object, ...client.DeleteOption) error {
+	return errReadOnly
+}
+
+func (readOnlyClient) Update(context.Context, runtime.Object, ...client.UpdateOption) error {
+	return errReadOnly
+}
+
+func (readOnlyClient) Patch(context.Context, runtime.Object, client.Patch, ...client.PatchOption) error {
+	return errReadOnly
+}
+
+func (readOnlyClient) DeleteAllOf(context.Context, runtime.Object, ...client.DeleteAllOfOption) error {
+	return errReadOnly
+}
+
+func (readOnlyClient) Status() client.StatusWriter {
+	return readOnlyStatusWriter{}
+}
+
+type readOnlyStatusWriter struct{}
+
+func (readOnlyStatusWriter) Update(context.Context, runtime.Object, ...client.UpdateOption) error {
+	return errReadOnly
+}
+
+func (readOnlyStatusWriter) Patch(context.Context, runtime.Object, client.Patch, ...client.PatchOption) error {
+	return errReadOnly
+}
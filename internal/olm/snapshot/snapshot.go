@@ -0,0 +1,218 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snapshot exports the cluster state OLM install checks read to a
+// file, and serves reads from that file so checks can run without access to
+// the cluster.
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// APIVersion and Kind identify the snapshot format. APIVersion changes
+	// whenever the format changes incompatibly.
+	APIVersion = "operator-sdk.operatorframework.io/v1alpha1"
+	Kind       = "ClusterSnapshot"
+
+	// DefaultMaxSize is the default maximum size in bytes of a snapshot file.
+	DefaultMaxSize int64 = 64 << 20
+)
+
+// Kinds are the kinds of objects captured in a snapshot. Secrets are never
+// captured.
+var Kinds = []schema.GroupVersionKind{
+	{Version: "v1", Kind: "Namespace"},
+	{Version: "v1", Kind: "ResourceQuota"},
+	{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"},
+	{Group: "apiregistration.k8s.io", Version: "v1", Kind: "APIService"},
+	{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"},
+	{Group: "operators.coreos.com", Version: "v1", Kind: "OperatorGroup"},
+	{Group: "operators.coreos.com", Version: "v1alpha1", Kind: "Subscription"},
+	{Group: "operators.coreos.com", Version: "v1alpha1", Kind: "ClusterServiceVersion"},
+}
+
+var (
+	_ discovery.ServerVersionInterface = &Snapshot{}
+	_ discovery.ServerGroupsInterface  = &Snapshot{}
+)
+
+// Snapshot is the state of a cluster at a point in time.
+type Snapshot struct {
+	APIVersion string      `json:"apiVersion"`
+	Kind       string      `json:"kind"`
+	Created    metav1.Time `json:"created"`
+	// KubernetesVersion and APIGroups are the cluster's discovery data.
+	KubernetesVersion version.Info      `json:"kubernetesVersion"`
+	APIGroups         []metav1.APIGroup `json:"apiGroups"`
+	// Captured contains the kinds whose objects were captured. A kind in Kinds
+	// is not captured if the cluster does not serve it.
+	Captured []metav1.GroupVersionKind   `json:"captured"`
+	Objects  []unstructured.Unstructured `json:"objects"`
+}
+
+// TooLargeError is returned when a snapshot is larger than its maximum size.
+type TooLargeError struct {
+	// Path is the snapshot's file, if any.
+	Path    string
+	MaxSize int64
+}
+
+func (e *TooLargeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("snapshot is larger than the maximum of %d bytes", e.MaxSize)
+	}
+	return fmt.Sprintf("snapshot %s is larger than the maximum of %d bytes", e.Path, e.MaxSize)
+}
+
+// Take captures Kinds from c and discovery data from dc, failing with a
+// *TooLargeError as soon as captured objects exceed maxSize bytes.
+// If maxSize is not positive, DefaultMaxSize is used.
+func Take(ctx context.Context, c client.Reader, dc discovery.DiscoveryInterface, maxSize int64) (*Snapshot, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	s := &Snapshot{APIVersion: APIVersion, Kind: Kind, Created: metav1.NewTime(time.Now().UTC())}
+
+	info, err := dc.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("error getting server version: %v", err)
+	}
+	s.KubernetesVersion = *info
+	groups, err := dc.ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("error discovering API groups: %v", err)
+	}
+	s.APIGroups = groups.Groups
+
+	// Objects are counted by their compact encoding, so a snapshot listing a
+	// huge cluster stops early instead of being held in memory in full.
+	var size int64
+	for _, gvk := range Kinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := c.List(ctx, list); err != nil {
+			if meta.IsNoMatchError(err) {
+				log.Debugf("Not capturing %s: kind is not served", gvk.Kind)
+				continue
+			}
+			return nil, fmt.Errorf("error listing %s: %v", gvk.Kind, err)
+		}
+		s.Captured = append(s.Captured, metav1.GroupVersionKind(gvk))
+		for _, item := range list.Items {
+			item.SetGroupVersionKind(gvk)
+			item.SetManagedFields(nil)
+			b, err := json.Marshal(item.Object)
+			if err != nil {
+				return nil, fmt.Errorf("error encoding %s %s: %v", gvk.Kind, item.GetName(), err)
+			}
+			if size += int64(len(b)); size > maxSize {
+				return nil, &TooLargeError{MaxSize: maxSize}
+			}
+			s.Objects = append(s.Objects, item)
+		}
+	}
+	return s, nil
+}
+
+// Write writes s to w as JSON, or returns a *TooLargeError without writing
+// if it is larger than maxSize bytes, so written snapshots can be loaded with
+// the same maxSize. If maxSize is not positive, DefaultMaxSize is used.
+func (s *Snapshot) Write(w io.Writer, maxSize int64) error {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if int64(len(b))+1 > maxSize {
+		return &TooLargeError{MaxSize: maxSize}
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// Load reads a snapshot from path, failing with a *TooLargeError if it is
+// larger than maxSize bytes. If maxSize is not positive, DefaultMaxSize is used.
+func Load(path string, maxSize int64) (*Snapshot, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// Read one byte past the limit to detect oversized files without
+	// trusting their reported size.
+	b, err := ioutil.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > maxSize {
+		return nil, &TooLargeError{Path: path, MaxSize: maxSize}
+	}
+
+	header := metav1.TypeMeta{}
+	if err := json.Unmarshal(b, &header); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot %s: %v", path, err)
+	}
+	if header.APIVersion != APIVersion || header.Kind != Kind {
+		return nil, fmt.Errorf("snapshot %s has unsupported format %s, %s; expected %s, %s",
+			path, header.APIVersion, header.Kind, APIVersion, Kind)
+	}
+	s := &Snapshot{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// IsCaptured returns true if objects of gvk's group and kind were captured.
+func (s *Snapshot) IsCaptured(gvk schema.GroupVersionKind) bool {
+	for _, c := range s.Captured {
+		if c.Group == gvk.Group && c.Kind == gvk.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerVersion returns the captured Kubernetes version.
+func (s *Snapshot) ServerVersion() (*version.Info, error) {
+	info := s.KubernetesVersion
+	return &info, nil
+}
+
+// ServerGroups returns the captured API groups.
+func (s *Snapshot) ServerGroups() (*metav1.APIGroupList, error) {
+	return &metav1.APIGroupList{Groups: s.APIGroups}, nil
+}
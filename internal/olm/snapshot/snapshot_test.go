@@ -0,0 +1,289 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
+)
+
+// clusterClient serves unstructured reads of objs, and returns "no kind
+// match" errors for kinds with groups not in groups.
+type clusterClient struct {
+	client.Client
+	groups map[string]bool
+	objs   []unstructured.Unstructured
+}
+
+func (c clusterClient) Get(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+	u := obj.(*unstructured.Unstructured)
+	gvk := u.GroupVersionKind()
+	if !c.groups[gvk.Group] {
+		return &meta.NoKindMatchError{GroupKind: gvk.GroupKind()}
+	}
+	for _, o := range c.objs {
+		if o.GroupVersionKind() == gvk && o.GetNamespace() == key.Namespace && o.GetName() == key.Name {
+			o.DeepCopyInto(u)
+			return nil
+		}
+	}
+	return apierrors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, key.Name)
+}
+
+func (c clusterClient) List(_ context.Context, list runtime.Object, _ ...client.ListOption) error {
+	ul := list.(*unstructured.UnstructuredList)
+	gvk := ul.GroupVersionKind()
+	gvk.Kind = gvk.Kind[:len(gvk.Kind)-len("List")]
+	if !c.groups[gvk.Group] {
+		return &meta.NoKindMatchError{GroupKind: gvk.GroupKind()}
+	}
+	for _, o := range c.objs {
+		if o.GroupVersionKind() == gvk {
+			ul.Items = append(ul.Items, *o.DeepCopy())
+		}
+	}
+	return nil
+}
+
+func newObject(apiVersion, kind, namespace, name string) unstructured.Unstructured {
+	u := unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	return u
+}
+
+func newPackageServerAPIService(available string) unstructured.Unstructured {
+	u := newObject("apiregistration.k8s.io/v1", "APIService", "", "v1.packages.operators.coreos.com")
+	_ = unstructured.SetNestedSlice(u.Object, []interface{}{
+		map[string]interface{}{"type": "Available", "status": available, "reason": "MissingEndpoints", "message": "no endpoints"},
+	}, "status", "conditions")
+	return u
+}
+
+func newDiscovery(groupVersions ...string) *fakediscovery.FakeDiscovery {
+	dc := &fakediscovery.FakeDiscovery{
+		Fake:               &clienttesting.Fake{},
+		FakedServerVersion: &version.Info{GitVersion: "v1.19.1"},
+	}
+	for _, gv := range groupVersions {
+		dc.Resources = append(dc.Resources, &metav1.APIResourceList{GroupVersion: gv})
+	}
+	return dc
+}
+
+var _ = Describe("Snapshot", func() {
+	var (
+		cl  clusterClient
+		dc  *fakediscovery.FakeDiscovery
+		tmp string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmp, err = ioutil.TempDir("", "snapshot-")
+		Expect(err).NotTo(HaveOccurred())
+
+		cm := newObject("v1", "ConfigMap", "testns", "config")
+		secret := newObject("v1", "Secret", "testns", "creds")
+		sub := newObject("operators.coreos.com/v1alpha1", "Subscription", "testns", "memcached-operator-sub")
+		sub.SetLabels(map[string]string{"app": "memcached"})
+		cl = clusterClient{
+			groups: map[string]bool{"": true, "apiregistration.k8s.io": true, "operators.coreos.com": true},
+			objs: []unstructured.Unstructured{
+				newObject("v1", "Namespace", "", "testns"),
+				cm, secret, sub,
+				newObject("operators.coreos.com/v1alpha1", "Subscription", "otherns", "other-sub"),
+				newPackageServerAPIService("True"),
+			},
+		}
+		dc = newDiscovery("v1", "apiregistration.k8s.io/v1", "operators.coreos.com/v1alpha1", "operators.coreos.com/v1")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	// roundTrip takes a snapshot of cl and dc, writes it to a file, and loads it.
+	roundTrip := func() *Snapshot {
+		s, err := Take(context.TODO(), cl, dc, 0)
+		Expect(err).NotTo(HaveOccurred())
+		path := filepath.Join(tmp, "snapshot.json")
+		buf := &bytes.Buffer{}
+		Expect(s.Write(buf, 0)).To(Succeed())
+		Expect(ioutil.WriteFile(path, buf.Bytes(), 0644)).To(Succeed())
+		loaded, err := Load(path, 0)
+		Expect(err).NotTo(HaveOccurred())
+		return loaded
+	}
+
+	Describe("Take", func() {
+		It("should capture served kinds and discovery data, and never capture Secrets", func() {
+			s := roundTrip()
+			Expect(s.KubernetesVersion.GitVersion).To(Equal("v1.19.1"))
+			Expect(s.APIGroups).To(HaveLen(3))
+			Expect(s.IsCaptured(corev1.SchemeGroupVersion.WithKind("Namespace"))).To(BeTrue())
+			Expect(s.IsCaptured(corev1.SchemeGroupVersion.WithKind("Secret"))).To(BeFalse())
+			Expect(s.IsCaptured(corev1.SchemeGroupVersion.WithKind("ConfigMap"))).To(BeFalse())
+			// The networking and apiextensions groups are not served by cl.
+			Expect(s.IsCaptured(Kinds[3])).To(BeTrue())
+			Expect(s.IsCaptured(Kinds[2])).To(BeFalse())
+			Expect(s.IsCaptured(Kinds[4])).To(BeFalse())
+			for _, obj := range s.Objects {
+				Expect(obj.GetKind()).NotTo(Equal("Secret"))
+				Expect(obj.GetKind()).NotTo(Equal("ConfigMap"))
+			}
+			Expect(s.Objects).To(HaveLen(4))
+		})
+		It("should fail once captured objects exceed the maximum size", func() {
+			_, err := Take(context.TODO(), cl, dc, 100)
+			tooLarge := &TooLargeError{}
+			Expect(errors.As(err, &tooLarge)).To(BeTrue())
+			Expect(tooLarge.MaxSize).To(Equal(int64(100)))
+		})
+	})
+
+	Describe("Write", func() {
+		It("should not write snapshots larger than the maximum size", func() {
+			s, err := Take(context.TODO(), cl, dc, 0)
+			Expect(err).NotTo(HaveOccurred())
+			buf := &bytes.Buffer{}
+			Expect(s.Write(buf, 0)).To(Succeed())
+			size := int64(buf.Len())
+
+			buf.Reset()
+			err = s.Write(buf, size-1)
+			Expect(err).To(BeAssignableToTypeOf(&TooLargeError{}))
+			Expect(buf.Len()).To(BeZero())
+			Expect(s.Write(buf, size)).To(Succeed())
+		})
+	})
+
+	Describe("Load", func() {
+		It("should reject snapshots larger than the maximum size", func() {
+			s, err := Take(context.TODO(), cl, dc, 0)
+			Expect(err).NotTo(HaveOccurred())
+			path := filepath.Join(tmp, "snapshot.json")
+			buf := &bytes.Buffer{}
+			Expect(s.Write(buf, 0)).To(Succeed())
+			Expect(ioutil.WriteFile(path, buf.Bytes(), 0644)).To(Succeed())
+
+			_, err = Load(path, int64(buf.Len()-1))
+			Expect(err).To(MatchError(&TooLargeError{Path: path, MaxSize: int64(buf.Len() - 1)}))
+			_, err = Load(path, int64(buf.Len()))
+			Expect(err).NotTo(HaveOccurred())
+		})
+		It("should reject snapshots of an unsupported version", func() {
+			path := filepath.Join(tmp, "snapshot.json")
+			Expect(ioutil.WriteFile(path, []byte(`{"apiVersion":"operator-sdk.operatorframework.io/v2","kind":"ClusterSnapshot"}`), 0644)).To(Succeed())
+			_, err := Load(path, 0)
+			Expect(err).To(MatchError(ContainSubstring("unsupported format")))
+		})
+	})
+
+	Describe("Reader", func() {
+		var r *Reader
+
+		BeforeEach(func() {
+			r = NewReader(roundTrip(), scheme.Scheme)
+		})
+
+		It("should get captured objects", func() {
+			ns := &corev1.Namespace{}
+			Expect(r.Get(context.TODO(), client.ObjectKey{Name: "testns"}, ns)).To(Succeed())
+			Expect(ns.GetName()).To(Equal("testns"))
+		})
+		It("should return not found errors for missing objects of captured kinds", func() {
+			ns := &corev1.Namespace{}
+			err := r.Get(context.TODO(), client.ObjectKey{Name: "missing"}, ns)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+		It("should return NotCapturedErrors for kinds not captured", func() {
+			secret := &corev1.Secret{}
+			err := r.Get(context.TODO(), client.ObjectKey{Namespace: "testns", Name: "creds"}, secret)
+			notCaptured := &NotCapturedError{}
+			Expect(errors.As(err, &notCaptured)).To(BeTrue())
+			Expect(notCaptured.GVK.Kind).To(Equal("Secret"))
+		})
+		It("should list objects filtered by namespace and labels", func() {
+			list := &unstructured.UnstructuredList{}
+			list.SetGroupVersionKind(Kinds[6].GroupVersion().WithKind("SubscriptionList"))
+			Expect(r.List(context.TODO(), list)).To(Succeed())
+			Expect(list.Items).To(HaveLen(2))
+			Expect(r.List(context.TODO(), list, client.InNamespace("testns"))).To(Succeed())
+			Expect(list.Items).To(HaveLen(1))
+			Expect(r.List(context.TODO(), list, client.MatchingLabels{"app": "other"})).To(Succeed())
+			Expect(list.Items).To(BeEmpty())
+		})
+		It("should fail writes", func() {
+			ns := &corev1.Namespace{}
+			ns.SetName("newns")
+			Expect(r.Client().Create(context.TODO(), ns)).To(MatchError(errReadOnly))
+		})
+	})
+
+	Describe("offline checks", func() {
+		// checkLive and checkOffline run the OLM installation check against
+		// cl and dc, and against a snapshot of them.
+		checkLive := func() error {
+			return olmclient.Client{KubeClient: cl}.CheckOLMInstalled(context.TODO(), dc)
+		}
+		checkOffline := func() error {
+			s := roundTrip()
+			c := olmclient.Client{KubeClient: NewReader(s, scheme.Scheme).Client()}
+			return c.CheckOLMInstalled(context.TODO(), s)
+		}
+		It("should match live findings if OLM is healthy", func() {
+			Expect(checkLive()).To(Succeed())
+			Expect(checkOffline()).To(Succeed())
+		})
+		It("should match live findings if OLM is unhealthy", func() {
+			cl.objs[len(cl.objs)-1] = newPackageServerAPIService("False")
+			live := checkLive()
+			Expect(errors.Is(live, olmclient.ErrOLMNotInstalled)).To(BeTrue())
+			Expect(checkOffline()).To(MatchError(live.Error()))
+		})
+		It("should match live findings if OLM is not installed", func() {
+			delete(cl.groups, "operators.coreos.com")
+			dc = newDiscovery("v1", "apiregistration.k8s.io/v1")
+			live := checkLive()
+			Expect(errors.Is(live, olmclient.ErrOLMNotInstalled)).To(BeTrue())
+			Expect(checkOffline()).To(MatchError(live.Error()))
+		})
+	})
+})
@@ -0,0 +1,73 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifests
+
+import (
+	"fmt"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"sigs.k8s.io/kubebuilder/pkg/model/config"
+	"sigs.k8s.io/kubebuilder/pkg/plugin"
+
+	"github.com/operator-framework/operator-sdk/internal/plugins"
+)
+
+// configKey is the key of Config in a project config file's plugins section.
+var configKey = plugin.Key("manifests"+plugins.DefaultNameQualifier, "v2")
+
+// Config configures manifests generation, and is saved in the project config file.
+type Config struct {
+	// PackageName is the name of the package bundles and package manifests are generated for,
+	// which prefixes ClusterServiceVersion names. Defaults to the project name.
+	PackageName string `json:"packageName,omitempty"`
+	// UI metadata of generated ClusterServiceVersions, which replaces that of CSV bases.
+	DisplayName string                `json:"displayName,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Provider    *v1alpha1.AppLink     `json:"provider,omitempty"`
+	Maintainers []v1alpha1.Maintainer `json:"maintainers,omitempty"`
+	Keywords    []string              `json:"keywords,omitempty"`
+	Maturity    string                `json:"maturity,omitempty"`
+	// MinKubeVersion is set as spec.minKubeVersion of generated ClusterServiceVersions.
+	MinKubeVersion string `json:"minKubeVersion,omitempty"`
+	// BundleLabels are custom LABEL's added to generated bundle.Dockerfiles.
+	BundleLabels map[string]string `json:"bundleLabels,omitempty"`
+	// BuildArgLabels map LABEL keys of generated bundle.Dockerfiles to the build args they are set to.
+	BuildArgLabels map[string]string `json:"buildArgLabels,omitempty"`
+}
+
+// GetConfig returns the Config in cfg, or an empty Config if cfg has none.
+func GetConfig(cfg *config.Config) (c Config, err error) {
+	if !cfg.IsV3() || len(cfg.Plugins) == 0 {
+		return c, nil
+	}
+	if _, hasKey := cfg.Plugins[configKey]; !hasKey {
+		return c, nil
+	}
+	if err := cfg.DecodePluginConfig(configKey, &c); err != nil {
+		return c, fmt.Errorf("error reading plugin config for %s: %v", configKey, err)
+	}
+	return c, nil
+}
+
+// SetConfig sets c in cfg. Only v3 projects can save plugin config.
+func SetConfig(cfg *config.Config, c Config) error {
+	if !cfg.IsV3() {
+		return fmt.Errorf("project version %s does not support plugin config", cfg.Version)
+	}
+	if err := cfg.EncodePluginConfig(configKey, c); err != nil {
+		return fmt.Errorf("error writing plugin config for %s: %v", configKey, err)
+	}
+	return nil
+}
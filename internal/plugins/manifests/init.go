@@ -64,6 +64,9 @@ func initUpdateMakefile(cfg *config.Config, filePath string) error {
 	return ioutil.WriteFile(filePath, makefileBytes, 0644)
 }
 
+// GenerateBundleFlags are the flags the Makefile's bundle recipe runs 'generate bundle' with.
+const GenerateBundleFlags = "-q --overwrite --version $(VERSION) $(BUNDLE_METADATA_OPTS)"
+
 // Makefile fragments to add to the base Makefile.
 const (
 	makefileBundleVarFragment = `# Current Operator version
@@ -86,7 +89,7 @@ BUNDLE_METADATA_OPTS ?= $(BUNDLE_CHANNELS) $(BUNDLE_DEFAULT_CHANNEL)
 bundle: manifests
 	operator-sdk generate kustomize manifests -q
 	cd config/manager && $(KUSTOMIZE) edit set image controller=$(IMG)
-	$(KUSTOMIZE) build config/manifests | operator-sdk generate bundle -q --overwrite --version $(VERSION) $(BUNDLE_METADATA_OPTS)
+	$(KUSTOMIZE) build config/manifests | operator-sdk generate bundle ` + GenerateBundleFlags + `
 	operator-sdk bundle validate ./bundle
 `
 
@@ -96,7 +99,7 @@ bundle: manifests
 bundle: kustomize
 	operator-sdk generate kustomize manifests -q
 	cd config/manager && $(KUSTOMIZE) edit set image controller=$(IMG)
-	$(KUSTOMIZE) build config/manifests | operator-sdk generate bundle -q --overwrite --version $(VERSION) $(BUNDLE_METADATA_OPTS)
+	$(KUSTOMIZE) build config/manifests | operator-sdk generate bundle ` + GenerateBundleFlags + `
 	operator-sdk bundle validate ./bundle
 `
 
@@ -0,0 +1,155 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	apierrors "github.com/operator-framework/api/pkg/validation/errors"
+	registrybundle "github.com/operator-framework/operator-registry/pkg/lib/bundle"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetBundleDataFromDir returns the bundle object and associated media type from dir, if any.
+func GetBundleDataFromDir(dir string) (*apimanifests.Bundle, string, error) {
+	// Gather bundle metadata.
+	metadata, _, err := FindBundleMetadata(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	manifestsDirName, hasLabel := metadata.GetManifestsDir()
+	if !hasLabel {
+		manifestsDirName = registrybundle.ManifestsDir
+	}
+	manifestsDir := filepath.Join(dir, manifestsDirName)
+	// Detect mediaType.
+	mediaType, err := registrybundle.GetMediaType(manifestsDir)
+	if err != nil {
+		return nil, "", err
+	}
+	// Read the bundle.
+	bundle, err := apimanifests.GetBundleFromDir(manifestsDir)
+	if err != nil {
+		return nil, "", err
+	}
+	return bundle, mediaType, nil
+}
+
+// ValidateBundleDir reads the bundle in bundleRoot and validates its format, metadata, and content,
+// including objects of APIs removed in Kubernetes version k8sVersion (see ValidateRemovedAPIs).
+// The bundle is returned so callers can run further validators on it.
+func ValidateBundleDir(logger *log.Entry, bundleRoot, k8sVersion string) (*apimanifests.Bundle, []apierrors.ManifestResult, error) {
+	if logger == nil {
+		logger = DiscardLogger()
+	}
+
+	bundle, mediaType, err := GetBundleDataFromDir(bundleRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata, annotationsPath, err := FindBundleMetadata(bundleRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	errs := apierrors.ManifestResult{Name: bundle.Name}
+
+	// The validator's image registry is only used to pull bundle images, so none is needed here.
+	val := registrybundle.NewImageValidator(nil, logger)
+	if err := val.ValidateBundleFormat(bundleRoot); err != nil {
+		verr := registrybundle.ValidationError{}
+		formatErrs := []error{err}
+		if errors.As(err, &verr) {
+			formatErrs = verr.Errors
+		}
+		for _, e := range formatErrs {
+			errs.Add(apierrors.ErrInvalidBundle(fmt.Sprintf("error validating format in %s: %v", bundleRoot, e), bundle.Name))
+		}
+	}
+	if err := validateChannelLabels(metadata); err != nil {
+		errs.Add(apierrors.ErrInvalidBundle(fmt.Sprintf("%s: %v", annotationsPath, err), bundle.Name))
+	}
+
+	removedAPIErrs, err := ValidateRemovedAPIs(bundle, k8sVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	errs.Errors = append(errs.Errors, removedAPIErrs.Errors...)
+	errs.Warnings = append(errs.Warnings, removedAPIErrs.Warnings...)
+
+	results := ValidateBundleContent(logger, bundle, mediaType)
+	return bundle, appendResult(results, errs), nil
+}
+
+// validateChannelLabels returns an error if metadata's default channel is not one of its channels.
+func validateChannelLabels(metadata Labels) error {
+	defaultChannel := metadata[registrybundle.ChannelDefaultLabel]
+	if defaultChannel == "" {
+		return nil
+	}
+	channels := metadata[registrybundle.ChannelsLabel]
+	for _, channel := range strings.Split(channels, ",") {
+		if strings.TrimSpace(channel) == defaultChannel {
+			return nil
+		}
+	}
+	return fmt.Errorf("default channel %q is not one of channels %q", defaultChannel, channels)
+}
+
+// bundleLabelPrefix prefixes all operator-registry bundle labels, ex. package and channel labels.
+const bundleLabelPrefix = "operators.operatorframework.io.bundle."
+
+// ValidateBundleImageLabels checks that image's labels are consistent with the annotations of its bundle,
+// unpacked to bundleRoot. Each bundle annotation must be set to the same value as an image label,
+// since index builders read bundle metadata from either.
+func ValidateBundleImageLabels(image string, imageLabels map[string]string, bundleRoot string) (apierrors.ManifestResult, error) {
+	result := apierrors.ManifestResult{Name: image}
+	metadata, annotationsPath, err := FindBundleMetadata(bundleRoot)
+	if err != nil {
+		return result, err
+	}
+	for _, err := range validateImageLabels(imageLabels, metadata) {
+		result.Add(apierrors.ErrInvalidBundle(fmt.Sprintf("image %s labels are inconsistent with %s: %v",
+			image, filepath.Base(annotationsPath), err), image))
+	}
+	return result, nil
+}
+
+// validateImageLabels returns an error for each bundle label in metadata that is missing from
+// or set to a different value in imageLabels.
+func validateImageLabels(imageLabels map[string]string, metadata Labels) (errs []error) {
+	var keys []string
+	for key := range metadata {
+		if strings.HasPrefix(key, bundleLabelPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, hasLabel := imageLabels[key]
+		switch {
+		case !hasLabel:
+			errs = append(errs, fmt.Errorf("label %q is not set, expected %q", key, metadata[key]))
+		case value != metadata[key]:
+			errs = append(errs, fmt.Errorf("label %q is %q, expected %q", key, value, metadata[key]))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,48 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// SkipRangeAnnotation is the CSV annotation containing a semantic version range of
+// operator versions that can upgrade directly to the CSV.
+const SkipRangeAnnotation = "olm.skipRange"
+
+// ParseCSVName returns the operator name and version of a CSV name of the form
+// <name>.v<version>, ex. memcached-operator.v0.0.1, which is the format the SDK
+// generates. An error is returned if name is not of that form.
+func ParseCSVName(name string) (string, semver.Version, error) {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+		return "", semver.Version{}, fmt.Errorf("CSV name %q is invalid: %s", name, strings.Join(errs, ", "))
+	}
+	// Prerelease versions may also contain ".v", so try each separator from the left.
+	for i := strings.Index(name, ".v"); i > 0; {
+		if v, err := semver.Parse(name[i+2:]); err == nil {
+			return name[:i], v, nil
+		}
+		next := strings.Index(name[i+2:], ".v")
+		if next < 0 {
+			break
+		}
+		i += next + 2
+	}
+	return "", semver.Version{}, fmt.Errorf("CSV name %q is not of the form <name>.v<semantic version>", name)
+}
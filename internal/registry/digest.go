@@ -0,0 +1,88 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DigestResolver resolves image references to the digests of the image manifests
+// they refer to.
+type DigestResolver interface {
+	// ResolveDigest returns the digest of image, ex. "sha256:abc...".
+	ResolveDigest(ctx context.Context, image string) (string, error)
+}
+
+// NewDigestResolver returns a DigestResolver that queries image registries,
+// authenticating with credentials in the config.json file in docker config directory
+// configDir, or the default docker config file if empty. If skipTLSVerify is
+// true, registries' TLS certificates are not verified, and if plainHTTP is
+// true, registries are queried over plain HTTP.
+func NewDigestResolver(configDir string, skipTLSVerify, plainHTTP bool) (DigestResolver, error) {
+	resolver, err := newResolver(configDir, skipTLSVerify, plainHTTP)
+	if err != nil {
+		return nil, fmt.Errorf("error creating image resolver: %v", err)
+	}
+	return digestResolverFunc(func(ctx context.Context, image string) (string, error) {
+		_, desc, err := resolver.Resolve(ctx, normalizeImageReference(image))
+		if err != nil {
+			return "", err
+		}
+		return desc.Digest.String(), nil
+	}), nil
+}
+
+// digestResolverFunc is a func implementing DigestResolver.
+type digestResolverFunc func(context.Context, string) (string, error)
+
+func (f digestResolverFunc) ResolveDigest(ctx context.Context, image string) (string, error) {
+	return f(ctx, image)
+}
+
+// normalizeImageReference returns image fully qualified with a registry host and
+// tag, as required by containerd, ex. "memcached" becomes "docker.io/library/memcached:latest".
+func normalizeImageReference(image string) string {
+	name, suffix := SplitImageTag(image)
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, suffix = name[:i], name[i:]
+	}
+	if suffix == "" {
+		suffix = ":latest"
+	}
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 1 || !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost" {
+		if len(parts) == 1 {
+			name = "library/" + name
+		}
+		name = "docker.io/" + name
+	}
+	return name + suffix
+}
+
+// SplitImageTag splits image into its name and tag, including the leading ":".
+// The tag is empty if image is untagged.
+func SplitImageTag(image string) (name, tag string) {
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") || strings.Contains(image[i:], "@") {
+		return image, ""
+	}
+	if j := strings.Index(image, "@"); j >= 0 && j < i {
+		// The colon belongs to the digest.
+		return image, ""
+	}
+	return image[:i], image[i:]
+}
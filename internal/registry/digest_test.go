@@ -0,0 +1,49 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("normalizeImageReference", func() {
+	It("qualifies Docker Hub images", func() {
+		Expect(normalizeImageReference("memcached")).To(Equal("docker.io/library/memcached:latest"))
+		Expect(normalizeImageReference("memcached:1.4.36")).To(Equal("docker.io/library/memcached:1.4.36"))
+		Expect(normalizeImageReference("example/memcached:v1")).To(Equal("docker.io/example/memcached:v1"))
+	})
+	It("keeps registry hosts and digests", func() {
+		Expect(normalizeImageReference("quay.io/example/operator:v0.0.1")).To(Equal("quay.io/example/operator:v0.0.1"))
+		Expect(normalizeImageReference("localhost:5000/operator")).To(Equal("localhost:5000/operator:latest"))
+		Expect(normalizeImageReference("localhost/operator:v1")).To(Equal("localhost/operator:v1"))
+		Expect(normalizeImageReference("quay.io/example/operator@sha256:abc")).To(Equal("quay.io/example/operator@sha256:abc"))
+	})
+})
+
+var _ = Describe("SplitImageTag", func() {
+	It("splits tagged images", func() {
+		name, tag := SplitImageTag("localhost:5000/example/operator:v0.0.1")
+		Expect(name).To(Equal("localhost:5000/example/operator"))
+		Expect(tag).To(Equal(":v0.0.1"))
+	})
+	It("returns no tag for untagged images", func() {
+		for _, image := range []string{"memcached", "localhost:5000/operator", "quay.io/example/operator@sha256:abc"} {
+			name, tag := SplitImageTag(image)
+			Expect(name).To(Equal(image))
+			Expect(tag).To(BeEmpty())
+		}
+	})
+})
@@ -27,8 +27,10 @@ import (
 )
 
 // ExtractBundleImage returns a bundle directory containing files extracted
-// from image. If local is true, the image will not be pulled.
-func ExtractBundleImage(ctx context.Context, logger *log.Entry, image string, local bool) (string, error) {
+// from image. If local is true, the image will not be pulled. opts configure
+// the registry image is pulled from.
+func ExtractBundleImage(ctx context.Context, logger *log.Entry, image string, local bool,
+	opts ...containerdregistry.RegistryOption) (string, error) {
 	if logger == nil {
 		logger = DiscardLogger()
 	}
@@ -51,7 +53,8 @@ func ExtractBundleImage(ctx context.Context, logger *log.Entry, image string, lo
 	logger = logger.WithFields(log.Fields{"dir": bundleDir})
 
 	// Use a containerd registry instead of shelling out to a container tool.
-	reg, err := containerdregistry.NewRegistry(containerdregistry.WithLog(logger))
+	reg, err := containerdregistry.NewRegistry(append([]containerdregistry.RegistryOption{
+		containerdregistry.WithLog(logger)}, opts...)...)
 	if err != nil {
 		return "", err
 	}
@@ -76,14 +79,17 @@ func ExtractBundleImage(ctx context.Context, logger *log.Entry, image string, lo
 	return bundleDir, nil
 }
 
-// GetImageLabels returns the set of labels on image.
-func GetImageLabels(ctx context.Context, logger *log.Entry, image string, local bool) (map[string]string, error) {
+// GetImageLabels returns the set of labels on image. opts configure the
+// registry image is pulled from.
+func GetImageLabels(ctx context.Context, logger *log.Entry, image string, local bool,
+	opts ...containerdregistry.RegistryOption) (map[string]string, error) {
 	if logger == nil {
 		logger = DiscardLogger()
 	}
 
 	// Create a containerd registry for socket-less image layer reading.
-	reg, err := containerdregistry.NewRegistry(containerdregistry.WithLog(logger))
+	reg, err := containerdregistry.NewRegistry(append([]containerdregistry.RegistryOption{
+		containerdregistry.WithLog(logger)}, opts...)...)
 	if err != nil {
 		return nil, fmt.Errorf("error creating new image registry: %v", err)
 	}
@@ -0,0 +1,119 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Media types of image manifest lists, which have a manifest per platform.
+const (
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// maxManifestSize limits the size of manifests and image configs read from registries.
+const maxManifestSize = 4 << 20
+
+// Platform is an operating system and architecture an image can run on.
+type Platform struct {
+	OS           string
+	Architecture string
+}
+
+func (p Platform) String() string {
+	return p.OS + "/" + p.Architecture
+}
+
+// PlatformResolver resolves the platforms of images.
+type PlatformResolver interface {
+	// ResolvePlatforms returns the platforms of each manifest in image's manifest list,
+	// or of its image config if image is not a manifest list.
+	ResolvePlatforms(ctx context.Context, image string) ([]Platform, error)
+}
+
+// NewPlatformResolver returns a PlatformResolver that queries image registries,
+// configured like NewDigestResolver.
+func NewPlatformResolver(configDir string, skipTLSVerify, plainHTTP bool) (PlatformResolver, error) {
+	resolver, err := newResolver(configDir, skipTLSVerify, plainHTTP)
+	if err != nil {
+		return nil, fmt.Errorf("error creating image resolver: %v", err)
+	}
+	return platformResolverFunc(func(ctx context.Context, image string) ([]Platform, error) {
+		ref := normalizeImageReference(image)
+		name, desc, err := resolver.Resolve(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		fetcher, err := resolver.Fetcher(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		fetchJSON := func(desc ocispec.Descriptor, v interface{}) error {
+			rc, err := fetcher.Fetch(ctx, desc)
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			b, err := ioutil.ReadAll(io.LimitReader(rc, maxManifestSize))
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(b, v); err != nil {
+				return fmt.Errorf("error decoding %s: %v", desc.MediaType, err)
+			}
+			return nil
+		}
+
+		switch desc.MediaType {
+		case mediaTypeDockerManifestList, ocispec.MediaTypeImageIndex:
+			index := ocispec.Index{}
+			if err := fetchJSON(desc, &index); err != nil {
+				return nil, err
+			}
+			var platforms []Platform
+			for _, m := range index.Manifests {
+				if m.Platform != nil {
+					platforms = append(platforms, Platform{OS: m.Platform.OS, Architecture: m.Platform.Architecture})
+				}
+			}
+			return platforms, nil
+		case mediaTypeDockerManifest, ocispec.MediaTypeImageManifest:
+			manifest := ocispec.Manifest{}
+			if err := fetchJSON(desc, &manifest); err != nil {
+				return nil, err
+			}
+			config := ocispec.Image{}
+			if err := fetchJSON(manifest.Config, &config); err != nil {
+				return nil, err
+			}
+			return []Platform{{OS: config.OS, Architecture: config.Architecture}}, nil
+		}
+		return nil, fmt.Errorf("unsupported manifest media type %q", desc.MediaType)
+	}), nil
+}
+
+// platformResolverFunc is a func implementing PlatformResolver.
+type platformResolverFunc func(context.Context, string) ([]Platform, error)
+
+func (f platformResolverFunc) ResolvePlatforms(ctx context.Context, image string) ([]Platform, error) {
+	return f(ctx, image)
+}
@@ -0,0 +1,175 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/blang/semver"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	apierrors "github.com/operator-framework/api/pkg/validation/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// removedAPI is a Kubernetes API no longer served as of a Kubernetes version.
+type removedAPI struct {
+	// removedIn is the first Kubernetes version that does not serve the API.
+	removedIn semver.Version
+	// replacement is the API to migrate to, if any, ex. "apiextensions.k8s.io/v1".
+	replacement string
+}
+
+// removedAPIs are the APIs removed from Kubernetes that bundles may contain objects of,
+// as listed in https://kubernetes.io/docs/reference/using-api/deprecation-guide/.
+var removedAPIs = map[schema.GroupVersionKind]removedAPI{}
+
+func init() {
+	add := func(removedIn, groupVersion, replacement string, kinds ...string) {
+		gv := schema.FromAPIVersionAndKind(groupVersion, "").GroupVersion()
+		for _, kind := range kinds {
+			removedAPIs[gv.WithKind(kind)] = removedAPI{
+				removedIn:   semver.MustParse(removedIn),
+				replacement: replacement,
+			}
+		}
+	}
+
+	add("1.16.0", "extensions/v1beta1", "apps/v1", "DaemonSet", "Deployment", "ReplicaSet")
+	add("1.16.0", "extensions/v1beta1", "networking.k8s.io/v1", "NetworkPolicy")
+	add("1.16.0", "apps/v1beta1", "apps/v1", "Deployment", "StatefulSet")
+	add("1.16.0", "apps/v1beta2", "apps/v1", "DaemonSet", "Deployment", "ReplicaSet", "StatefulSet")
+
+	add("1.22.0", "apiextensions.k8s.io/v1beta1", "apiextensions.k8s.io/v1", "CustomResourceDefinition")
+	add("1.22.0", "admissionregistration.k8s.io/v1beta1", "admissionregistration.k8s.io/v1",
+		"MutatingWebhookConfiguration", "ValidatingWebhookConfiguration")
+	add("1.22.0", "apiregistration.k8s.io/v1beta1", "apiregistration.k8s.io/v1", "APIService")
+	add("1.22.0", "certificates.k8s.io/v1beta1", "certificates.k8s.io/v1", "CertificateSigningRequest")
+	add("1.22.0", "coordination.k8s.io/v1beta1", "coordination.k8s.io/v1", "Lease")
+	add("1.22.0", "extensions/v1beta1", "networking.k8s.io/v1", "Ingress")
+	add("1.22.0", "networking.k8s.io/v1beta1", "networking.k8s.io/v1", "Ingress", "IngressClass")
+	add("1.22.0", "rbac.authorization.k8s.io/v1beta1", "rbac.authorization.k8s.io/v1",
+		"ClusterRole", "ClusterRoleBinding", "Role", "RoleBinding")
+	add("1.22.0", "scheduling.k8s.io/v1beta1", "scheduling.k8s.io/v1", "PriorityClass")
+	add("1.22.0", "storage.k8s.io/v1beta1", "storage.k8s.io/v1", "CSIDriver", "CSINode", "StorageClass", "VolumeAttachment")
+
+	add("1.25.0", "batch/v1beta1", "batch/v1", "CronJob")
+	add("1.25.0", "discovery.k8s.io/v1beta1", "discovery.k8s.io/v1", "EndpointSlice")
+	add("1.25.0", "events.k8s.io/v1beta1", "events.k8s.io/v1", "Event")
+	add("1.25.0", "autoscaling/v2beta1", "autoscaling/v2", "HorizontalPodAutoscaler")
+	add("1.25.0", "policy/v1beta1", "policy/v1", "PodDisruptionBudget")
+	add("1.25.0", "policy/v1beta1", "", "PodSecurityPolicy")
+	add("1.25.0", "node.k8s.io/v1beta1", "node.k8s.io/v1", "RuntimeClass")
+
+	add("1.26.0", "autoscaling/v2beta2", "autoscaling/v2", "HorizontalPodAutoscaler")
+	add("1.26.0", "flowcontrol.apiserver.k8s.io/v1beta1", "flowcontrol.apiserver.k8s.io/v1beta3",
+		"FlowSchema", "PriorityLevelConfiguration")
+	add("1.27.0", "storage.k8s.io/v1beta1", "storage.k8s.io/v1", "CSIStorageCapacity")
+}
+
+// ValidateRemovedAPIs returns a result with a finding for each of bundle's objects of an API that is not
+// served by the target Kubernetes version k8sVersion, or by spec.minKubeVersion if k8sVersion is empty.
+// A finding is an error if the API is not served by spec.minKubeVersion, since the bundle cannot install
+// on any cluster it supports, and a warning otherwise.
+func ValidateRemovedAPIs(bundle *apimanifests.Bundle, k8sVersion string) (apierrors.ManifestResult, error) {
+	result := apierrors.ManifestResult{Name: bundle.Name}
+
+	// An invalid spec.minKubeVersion is reported by ValidateBundleContent.
+	var minKubeVersion *semver.Version
+	if bundle.CSV != nil && bundle.CSV.Spec.MinKubeVersion != "" {
+		if v, err := ParseKubeVersion(bundle.CSV.Spec.MinKubeVersion); err == nil {
+			minKubeVersion = &v
+		}
+	}
+
+	target := minKubeVersion
+	if k8sVersion != "" {
+		v, err := ParseKubeVersion(k8sVersion)
+		if err != nil {
+			return result, err
+		}
+		target = &v
+	}
+	if target == nil {
+		return result, nil
+	}
+
+	for _, obj := range getRemovedAPIObjects(bundle, *target) {
+		api := removedAPIs[obj.gvk]
+		detail := fmt.Sprintf("%s %s %q is removed in Kubernetes %s",
+			obj.gvk.GroupVersion(), obj.gvk.Kind, obj.name, api.removedIn)
+		if api.replacement != "" {
+			detail += fmt.Sprintf("; migrate it to %s", api.replacement)
+		} else {
+			detail += "; it has no replacement"
+		}
+		if minKubeVersion != nil && minKubeVersion.GTE(api.removedIn) {
+			result.Add(newRemovedAPIError(apierrors.LevelError, obj.name,
+				fmt.Sprintf("%s (spec.minKubeVersion is %s)", detail, minKubeVersion)))
+		} else {
+			result.Add(newRemovedAPIError(apierrors.LevelWarn, obj.name,
+				fmt.Sprintf("%s (target Kubernetes version is %s)", detail, target)))
+		}
+	}
+	return result, nil
+}
+
+// ParseKubeVersion parses a Kubernetes version like "1.22", "v1.22", or "1.22.0".
+func ParseKubeVersion(version string) (semver.Version, error) {
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("invalid Kubernetes version %q: %v", version, err)
+	}
+	// Pre-release and build versions, ex. 1.22.0-rc.0, are treated as their release.
+	v.Pre, v.Build = nil, nil
+	return v, nil
+}
+
+func newRemovedAPIError(level apierrors.Level, name, detail string) apierrors.Error {
+	return apierrors.Error{Type: apierrors.ErrorInvalidBundle, Level: level, BadValue: name, Detail: detail}
+}
+
+type bundleObject struct {
+	gvk  schema.GroupVersionKind
+	name string
+}
+
+// getRemovedAPIObjects returns bundle's objects of APIs removed at or before target, sorted by GVK and name.
+func getRemovedAPIObjects(bundle *apimanifests.Bundle, target semver.Version) (objs []bundleObject) {
+	seen := map[bundleObject]bool{}
+	addObject := func(obj bundleObject) {
+		api, isRemoved := removedAPIs[obj.gvk]
+		if isRemoved && target.GTE(api.removedIn) && !seen[obj] {
+			seen[obj] = true
+			objs = append(objs, obj)
+		}
+	}
+	for _, u := range bundle.Objects {
+		addObject(bundleObject{gvk: u.GroupVersionKind(), name: u.GetName()})
+	}
+	// v1beta1 CRDs are also decoded separately, and may not be in bundle.Objects.
+	for _, crd := range bundle.V1beta1CRDs {
+		gvk := schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"}
+		addObject(bundleObject{gvk: gvk, name: crd.GetName()})
+	}
+
+	sort.Slice(objs, func(i, j int) bool {
+		if objs[i].gvk.String() != objs[j].gvk.String() {
+			return objs[i].gvk.String() < objs[j].gvk.String()
+		}
+		return objs[i].name < objs[j].name
+	})
+	return objs
+}
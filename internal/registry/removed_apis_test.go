@@ -0,0 +1,123 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	apierrors "github.com/operator-framework/api/pkg/validation/errors"
+)
+
+var _ = Describe("ValidateRemovedAPIs", func() {
+	const (
+		crdFinding         = `apiextensions.k8s.io/v1beta1 CustomResourceDefinition "memcacheds.cache.example.com" is removed in Kubernetes 1.22.0; migrate it to apiextensions.k8s.io/v1`
+		clusterRoleFinding = `rbac.authorization.k8s.io/v1beta1 ClusterRole "memcached-operator-metrics-reader" is removed in Kubernetes 1.22.0; migrate it to rbac.authorization.k8s.io/v1`
+		pdbFinding         = `policy/v1beta1 PodDisruptionBudget "memcached-operator-pdb" is removed in Kubernetes 1.25.0; migrate it to policy/v1`
+	)
+
+	var bundle *apimanifests.Bundle
+
+	BeforeEach(func() {
+		var err error
+		bundle, err = apimanifests.GetBundleFromDir(filepath.Join("testdata", "removed-apis-bundle", "manifests"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	details := func(errs []apierrors.Error) (ds []string) {
+		for _, e := range errs {
+			ds = append(ds, e.Detail)
+		}
+		return ds
+	}
+
+	It("reports nothing without a target version or minKubeVersion", func() {
+		result, err := ValidateRemovedAPIs(bundle, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Errors).To(BeEmpty())
+		Expect(result.Warnings).To(BeEmpty())
+	})
+	It("reports nothing for a target version that serves all APIs", func() {
+		result, err := ValidateRemovedAPIs(bundle, "1.21")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Errors).To(BeEmpty())
+		Expect(result.Warnings).To(BeEmpty())
+	})
+	It("warns about APIs removed at target version 1.22", func() {
+		result, err := ValidateRemovedAPIs(bundle, "v1.22.0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Errors).To(BeEmpty())
+		Expect(details(result.Warnings)).To(Equal([]string{
+			crdFinding + " (target Kubernetes version is 1.22.0)",
+			clusterRoleFinding + " (target Kubernetes version is 1.22.0)",
+		}))
+		Expect(result.Warnings[0].BadValue).To(Equal("memcacheds.cache.example.com"))
+	})
+	It("warns about APIs removed at or before target version 1.25", func() {
+		result, err := ValidateRemovedAPIs(bundle, "1.25")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Errors).To(BeEmpty())
+		Expect(details(result.Warnings)).To(Equal([]string{
+			crdFinding + " (target Kubernetes version is 1.25.0)",
+			pdbFinding + " (target Kubernetes version is 1.25.0)",
+			clusterRoleFinding + " (target Kubernetes version is 1.25.0)",
+		}))
+	})
+	It("infers the target version from minKubeVersion, and reports errors", func() {
+		bundle.CSV.Spec.MinKubeVersion = "1.22.0"
+		result, err := ValidateRemovedAPIs(bundle, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Warnings).To(BeEmpty())
+		Expect(details(result.Errors)).To(Equal([]string{
+			crdFinding + " (spec.minKubeVersion is 1.22.0)",
+			clusterRoleFinding + " (spec.minKubeVersion is 1.22.0)",
+		}))
+	})
+	It("infers the target version from a minKubeVersion without a patch version", func() {
+		bundle.CSV.Spec.MinKubeVersion = "1.22"
+		result, err := ValidateRemovedAPIs(bundle, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Warnings).To(BeEmpty())
+		Expect(details(result.Errors)).To(Equal([]string{
+			crdFinding + " (spec.minKubeVersion is 1.22.0)",
+			clusterRoleFinding + " (spec.minKubeVersion is 1.22.0)",
+		}))
+	})
+	It("reports errors for APIs removed by minKubeVersion and warnings for the target version", func() {
+		bundle.CSV.Spec.MinKubeVersion = "1.22.0"
+		result, err := ValidateRemovedAPIs(bundle, "1.25")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(details(result.Errors)).To(Equal([]string{
+			crdFinding + " (spec.minKubeVersion is 1.22.0)",
+			clusterRoleFinding + " (spec.minKubeVersion is 1.22.0)",
+		}))
+		Expect(details(result.Warnings)).To(Equal([]string{
+			pdbFinding + " (target Kubernetes version is 1.25.0)",
+		}))
+	})
+	It("reports nothing for a minKubeVersion that serves all APIs", func() {
+		bundle.CSV.Spec.MinKubeVersion = "1.16.0"
+		result, err := ValidateRemovedAPIs(bundle, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Errors).To(BeEmpty())
+		Expect(result.Warnings).To(BeEmpty())
+	})
+	It("returns an error for an invalid target version", func() {
+		_, err := ValidateRemovedAPIs(bundle, "latest")
+		Expect(err).To(MatchError(HavePrefix(`invalid Kubernetes version "latest"`)))
+	})
+})
@@ -0,0 +1,83 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
+)
+
+// newResolver returns a resolver that queries image registries, authenticating
+// with credentials in the config.json file in docker config directory
+// configDir, or the default docker config file if empty. If skipTLSVerify is
+// true, registries' TLS certificates are not verified, and if plainHTTP is
+// true, registries are queried over plain HTTP.
+func newResolver(configDir string, skipTLSVerify, plainHTTP bool) (remotes.Resolver, error) {
+	cfg, err := config.Load(configDir)
+	if err != nil {
+		return nil, fmt.Errorf("error loading docker config: %v", err)
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: skipTLSVerify}, //nolint:gosec
+	}
+	client := &http.Client{Transport: transport}
+
+	opts := []docker.RegistryOpt{
+		docker.WithClient(client),
+		docker.WithAuthorizer(docker.NewDockerAuthorizer(
+			docker.WithAuthClient(client),
+			docker.WithAuthCreds(credentials(cfg)),
+		)),
+	}
+	if plainHTTP {
+		opts = append(opts, docker.WithPlainHTTP(docker.MatchAllHosts))
+	}
+	return docker.NewResolver(docker.ResolverOptions{
+		Hosts: docker.ConfigureDefaultRegistries(opts...),
+	}), nil
+}
+
+// credentials returns a function that gets a registry host's credentials from cfg.
+func credentials(cfg *configfile.ConfigFile) func(string) (string, string, error) {
+	return func(host string) (string, string, error) {
+		// Docker Hub credentials are stored under its index server address.
+		if host == "registry-1.docker.io" {
+			host = "https://index.docker.io/v1/"
+		}
+		auth, err := cfg.GetAuthConfig(host)
+		if err != nil {
+			return "", "", err
+		}
+		if auth.IdentityToken != "" {
+			return "", auth.IdentityToken, nil
+		}
+		return auth.Username, auth.Password, nil
+	}
+}
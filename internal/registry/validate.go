@@ -19,11 +19,14 @@ import (
 	"io/ioutil"
 	"os"
 
+	"github.com/blang/semver"
 	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
 	apivalidation "github.com/operator-framework/api/pkg/validation"
 	apierrors "github.com/operator-framework/api/pkg/validation/errors"
 	registrybundle "github.com/operator-framework/operator-registry/pkg/lib/bundle"
 	log "github.com/sirupsen/logrus"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	k8svalidation "k8s.io/apimachinery/pkg/api/validation"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -80,6 +83,12 @@ func ValidateBundleContent(logger *log.Entry, bundle *apimanifests.Bundle, media
 	// All bundles must have a CSV currently.
 	if bundle.CSV != nil {
 		results = append(results, apivalidation.ClusterServiceVersionValidator.Validate(bundle.CSV)...)
+		if err := validateMinKubeVersion(bundle); err != nil {
+			errs.Add(apierrors.ErrInvalidBundle(err.Error(), bundle.CSV.GetName()))
+		}
+		for _, warning := range checkUpgradeGraph(bundle.CSV) {
+			errs.Add(apierrors.WarnInvalidCSV(warning, bundle.CSV.GetName()))
+		}
 	} else {
 		errs.Add(apierrors.ErrInvalidBundle("no ClusterServiceVersion in bundle", bundle.Name))
 	}
@@ -102,6 +111,70 @@ func ValidateBundleContent(logger *log.Entry, bundle *apimanifests.Bundle, media
 	return results
 }
 
+// v1CRDMinKubeVersion is the first Kubernetes version to serve apiextensions.k8s.io/v1 CRDs.
+var v1CRDMinKubeVersion = semver.MustParse("1.16.0")
+
+// validateMinKubeVersion returns an error if bundle's CSV spec.minKubeVersion
+// is not a semantic version, or is older than the first Kubernetes version to
+// serve the bundle's v1 CRDs. Objects of APIs not served by spec.minKubeVersion,
+// ex. v1beta1 CRDs, are reported by ValidateRemovedAPIs.
+func validateMinKubeVersion(bundle *apimanifests.Bundle) error {
+	minKubeVersion := bundle.CSV.Spec.MinKubeVersion
+	if minKubeVersion == "" {
+		return nil
+	}
+	v, err := semver.Parse(minKubeVersion)
+	if err != nil {
+		return fmt.Errorf("spec.minKubeVersion %q is not a semantic version: %v", minKubeVersion, err)
+	}
+	if len(bundle.V1CRDs) != 0 && v.LT(v1CRDMinKubeVersion) {
+		return fmt.Errorf("spec.minKubeVersion %s is less than %s, the first Kubernetes version to serve %s CRDs",
+			minKubeVersion, v1CRDMinKubeVersion, apiextv1.SchemeGroupVersion)
+	}
+	return nil
+}
+
+// checkUpgradeGraph returns warnings for csv's spec.replaces, spec.skips, and olm.skipRange annotation
+// that make csv unreachable in an upgrade graph from some of the versions they name.
+func checkUpgradeGraph(csv *v1alpha1.ClusterServiceVersion) (warnings []string) {
+	version := csv.Spec.Version.Version
+	if version.Equals(semver.Version{}) {
+		return nil
+	}
+
+	if replaces := csv.Spec.Replaces; replaces != "" {
+		if _, v, err := ParseCSVName(replaces); err == nil && v.GTE(version) {
+			warnings = append(warnings, fmt.Sprintf("spec.replaces %s is not older than version %s, "+
+				"so it cannot upgrade to this CSV", replaces, version))
+		}
+	}
+	for _, skip := range csv.Spec.Skips {
+		if _, v, err := ParseCSVName(skip); err == nil && v.GTE(version) {
+			warnings = append(warnings, fmt.Sprintf("spec.skips entry %s is not older than version %s, "+
+				"so it cannot upgrade to this CSV", skip, version))
+		}
+	}
+
+	skipRange, hasSkipRange := csv.GetAnnotations()[SkipRangeAnnotation]
+	if !hasSkipRange {
+		return warnings
+	}
+	inRange, err := semver.ParseRange(skipRange)
+	if err != nil {
+		return append(warnings, fmt.Sprintf("%s %q is not a valid semantic version range: %v",
+			SkipRangeAnnotation, skipRange, err))
+	}
+	if inRange(version) {
+		warnings = append(warnings, fmt.Sprintf("%s %q contains this CSV's version %s, "+
+			"but should only contain older versions", SkipRangeAnnotation, skipRange, version))
+	}
+	if csv.Spec.Replaces == "" {
+		warnings = append(warnings, fmt.Sprintf("%s is set but spec.replaces is not, "+
+			"so versions outside %q in the channel cannot upgrade to this CSV", SkipRangeAnnotation, skipRange))
+	}
+	return warnings
+}
+
 // validateObject validates an arbitrary metav1.Object's metadata.
 func validateObject(obj metav1.Object) error {
 	f := func(string, bool) []string { return nil }
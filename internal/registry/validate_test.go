@@ -0,0 +1,177 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"github.com/blang/semver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	registrybundle "github.com/operator-framework/operator-registry/pkg/lib/bundle"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+)
+
+var _ = Describe("validateMinKubeVersion", func() {
+	var bundle *apimanifests.Bundle
+
+	BeforeEach(func() {
+		bundle = &apimanifests.Bundle{CSV: &v1alpha1.ClusterServiceVersion{}}
+	})
+
+	It("succeeds if minKubeVersion is not set", func() {
+		bundle.V1CRDs = []*apiextv1.CustomResourceDefinition{{}}
+		Expect(validateMinKubeVersion(bundle)).To(Succeed())
+	})
+	It("returns an error if minKubeVersion is not a semantic version", func() {
+		bundle.CSV.Spec.MinKubeVersion = "v1.16.0"
+		Expect(validateMinKubeVersion(bundle)).To(MatchError(ContainSubstring("not a semantic version")))
+	})
+	It("succeeds for v1 CRDs with a minKubeVersion of at least 1.16.0", func() {
+		bundle.V1CRDs = []*apiextv1.CustomResourceDefinition{{}}
+		bundle.CSV.Spec.MinKubeVersion = "1.16.0"
+		Expect(validateMinKubeVersion(bundle)).To(Succeed())
+	})
+	It("returns an error for v1 CRDs with a minKubeVersion less than 1.16.0", func() {
+		bundle.V1CRDs = []*apiextv1.CustomResourceDefinition{{}}
+		bundle.CSV.Spec.MinKubeVersion = "1.15.0"
+		Expect(validateMinKubeVersion(bundle)).To(MatchError(
+			"spec.minKubeVersion 1.15.0 is less than 1.16.0, the first Kubernetes version to serve apiextensions.k8s.io/v1 CRDs"))
+	})
+	It("succeeds for v1beta1 CRDs with a minKubeVersion less than 1.22.0", func() {
+		bundle.V1beta1CRDs = []*apiextv1beta1.CustomResourceDefinition{{}}
+		bundle.CSV.Spec.MinKubeVersion = "1.11.0"
+		Expect(validateMinKubeVersion(bundle)).To(Succeed())
+	})
+	It("leaves v1beta1 CRDs with a minKubeVersion of at least 1.22.0 to ValidateRemovedAPIs", func() {
+		bundle.V1beta1CRDs = []*apiextv1beta1.CustomResourceDefinition{{}}
+		bundle.CSV.Spec.MinKubeVersion = "1.22.0"
+		Expect(validateMinKubeVersion(bundle)).To(Succeed())
+	})
+})
+
+var _ = Describe("checkUpgradeGraph", func() {
+	var csv *v1alpha1.ClusterServiceVersion
+
+	BeforeEach(func() {
+		csv = &v1alpha1.ClusterServiceVersion{}
+		csv.SetName("memcached-operator.v0.3.0")
+		csv.Spec.Version.Version = semver.MustParse("0.3.0")
+	})
+
+	It("returns no warnings for a reachable CSV", func() {
+		csv.Spec.Replaces = "memcached-operator.v0.2.0"
+		csv.Spec.Skips = []string{"memcached-operator.v0.2.1"}
+		csv.SetAnnotations(map[string]string{SkipRangeAnnotation: ">=0.1.0 <0.3.0"})
+		Expect(checkUpgradeGraph(csv)).To(BeEmpty())
+	})
+	It("returns no warnings without replaces, skips, or a skip range", func() {
+		Expect(checkUpgradeGraph(csv)).To(BeEmpty())
+	})
+	It("warns if replaces or skips are not older than the CSV", func() {
+		csv.Spec.Replaces = "memcached-operator.v0.3.0"
+		csv.Spec.Skips = []string{"memcached-operator.v0.2.1", "memcached-operator.v0.4.0"}
+		Expect(checkUpgradeGraph(csv)).To(ConsistOf(
+			ContainSubstring("spec.replaces memcached-operator.v0.3.0 is not older than version 0.3.0"),
+			ContainSubstring("spec.skips entry memcached-operator.v0.4.0 is not older than version 0.3.0"),
+		))
+	})
+	It("warns if the skip range contains the CSV's version", func() {
+		csv.Spec.Replaces = "memcached-operator.v0.2.0"
+		csv.SetAnnotations(map[string]string{SkipRangeAnnotation: ">=0.1.0 <=0.3.0"})
+		Expect(checkUpgradeGraph(csv)).To(ConsistOf(ContainSubstring("contains this CSV's version 0.3.0")))
+	})
+	It("warns if a skip range is set without replaces", func() {
+		csv.SetAnnotations(map[string]string{SkipRangeAnnotation: ">=0.1.0 <0.3.0"})
+		Expect(checkUpgradeGraph(csv)).To(ConsistOf(ContainSubstring("spec.replaces is not")))
+	})
+	It("warns if the skip range is invalid", func() {
+		csv.Spec.Replaces = "memcached-operator.v0.2.0"
+		csv.SetAnnotations(map[string]string{SkipRangeAnnotation: "0.1.x"})
+		Expect(checkUpgradeGraph(csv)).To(ConsistOf(ContainSubstring("is not a valid semantic version range")))
+	})
+})
+
+var _ = Describe("ParseCSVName", func() {
+	It("parses an operator name and version", func() {
+		name, v, err := ParseCSVName("memcached-operator.v0.1.0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("memcached-operator"))
+		Expect(v).To(Equal(semver.MustParse("0.1.0")))
+	})
+	It("parses a name containing .v and a prerelease version", func() {
+		name, v, err := ParseCSVName("foo.vbar.v1.0.0-alpha.v2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("foo.vbar"))
+		Expect(v).To(Equal(semver.MustParse("1.0.0-alpha.v2")))
+	})
+	It("returns an error for names without a version", func() {
+		_, _, err := ParseCSVName("memcached-operator")
+		Expect(err).To(MatchError(ContainSubstring("not of the form")))
+		_, _, err = ParseCSVName("memcached-operator.0.1.0")
+		Expect(err).To(HaveOccurred())
+	})
+	It("returns an error for invalid names", func() {
+		_, _, err := ParseCSVName("Memcached-Operator.v0.1.0")
+		Expect(err).To(MatchError(ContainSubstring("is invalid")))
+	})
+})
+
+var _ = Describe("validateChannelLabels", func() {
+	It("succeeds if the default channel is one of the channels", func() {
+		Expect(validateChannelLabels(Labels{
+			registrybundle.ChannelsLabel:       "alpha,stable",
+			registrybundle.ChannelDefaultLabel: "stable",
+		})).To(Succeed())
+	})
+	It("succeeds if no default channel is set", func() {
+		Expect(validateChannelLabels(Labels{registrybundle.ChannelsLabel: "alpha"})).To(Succeed())
+	})
+	It("returns an error if the default channel is not one of the channels", func() {
+		Expect(validateChannelLabels(Labels{
+			registrybundle.ChannelsLabel:       "alpha,beta",
+			registrybundle.ChannelDefaultLabel: "stable",
+		})).To(MatchError(`default channel "stable" is not one of channels "alpha,beta"`))
+	})
+})
+
+var _ = Describe("validateImageLabels", func() {
+	var metadata Labels
+
+	BeforeEach(func() {
+		metadata = Labels{
+			registrybundle.PackageLabel:                      "memcached-operator",
+			registrybundle.ChannelsLabel:                     "alpha",
+			"operators.operatorframework.io.metrics.builder": "operator-sdk-v1.0.0",
+		}
+	})
+
+	It("succeeds if all bundle labels match annotations", func() {
+		Expect(validateImageLabels(map[string]string{
+			registrybundle.PackageLabel:        "memcached-operator",
+			registrybundle.ChannelsLabel:       "alpha",
+			"org.opencontainers.image.version": "0.0.1",
+		}, metadata)).To(BeEmpty())
+	})
+	It("returns an error for each missing or different bundle label", func() {
+		errs := validateImageLabels(map[string]string{
+			registrybundle.ChannelsLabel: "stable",
+		}, metadata)
+		Expect(errs).To(HaveLen(2))
+		Expect(errs[0]).To(MatchError(`label "operators.operatorframework.io.bundle.channels.v1" is "stable", expected "alpha"`))
+		Expect(errs[1]).To(MatchError(`label "operators.operatorframework.io.bundle.package.v1" is not set, expected "memcached-operator"`))
+	})
+})
@@ -15,98 +15,14 @@
 package k8sutil
 
 import (
-	"bytes"
-	"fmt"
-	"io/ioutil"
-	"path/filepath"
-
 	"github.com/operator-framework/operator-registry/pkg/registry"
-	log "github.com/sirupsen/logrus"
 	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/version"
-	"sigs.k8s.io/yaml"
 )
 
-// GetCustomResourceDefinitions returns all CRD manifests of both v1 and v1beta1
-// versions in the directory crdsDir. If a duplicate object with different API
-// versions is found, and error is returned.
-func GetCustomResourceDefinitions(crdsDir string) (
-	v1crds []apiextv1.CustomResourceDefinition,
-	v1beta1crds []apiextv1beta1.CustomResourceDefinition,
-	err error) {
-
-	infos, err := ioutil.ReadDir(crdsDir)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	// The set of all custom resource GVKs in found CRDs.
-	crGVKSet := map[schema.GroupVersionKind]struct{}{}
-	for _, info := range infos {
-		path := filepath.Join(crdsDir, info.Name())
-
-		if info.IsDir() {
-			log.Debugf("Skipping dir: %s", path)
-			continue
-		}
-
-		b, err := ioutil.ReadFile(path)
-		if err != nil {
-			return nil, nil, fmt.Errorf("error reading manifest %s: %w", path, err)
-		}
-
-		scanner := NewYAMLScanner(bytes.NewBuffer(b))
-		for scanner.Scan() {
-			manifest := scanner.Bytes()
-			typeMeta, err := GetTypeMetaFromBytes(manifest)
-			if err != nil {
-				log.Debugf("Skipping manifest in %s: %v", path, err)
-				continue
-			}
-			if typeMeta.Kind != "CustomResourceDefinition" {
-				continue
-			}
-
-			// Unmarshal based on CRD version.
-			var crGVKs []schema.GroupVersionKind
-			switch gvk := typeMeta.GroupVersionKind(); gvk.Version {
-			case apiextv1.SchemeGroupVersion.Version:
-				crd := apiextv1.CustomResourceDefinition{}
-				if err = yaml.Unmarshal(manifest, &crd); err != nil {
-					return nil, nil, err
-				}
-				v1crds = append(v1crds, crd)
-				crGVKs = append(crGVKs, GVKsForV1CustomResourceDefinitions(crd)...)
-			case apiextv1beta1.SchemeGroupVersion.Version:
-				crd := apiextv1beta1.CustomResourceDefinition{}
-				if err := yaml.Unmarshal(manifest, &crd); err != nil {
-					return nil, nil, err
-				}
-				v1beta1crds = append(v1beta1crds, crd)
-				crGVKs = append(crGVKs, GVKsForV1beta1CustomResourceDefinitions(crd)...)
-			default:
-				return nil, nil, fmt.Errorf("unrecognized CustomResourceDefinition version %q", gvk.Version)
-			}
-
-			// Check if any GVK in crd is a duplicate.
-			for _, gvk := range crGVKs {
-				if _, hasGVK := crGVKSet[gvk]; hasGVK {
-					return nil, nil, fmt.Errorf("duplicate custom resource GVK %s in %s", gvk, path)
-				}
-				crGVKSet[gvk] = struct{}{}
-			}
-
-		}
-		if err = scanner.Err(); err != nil {
-			return nil, nil, fmt.Errorf("error scanning %s: %w", path, err)
-		}
-	}
-	return v1crds, v1beta1crds, nil
-}
-
 // DefinitionsForV1CustomResourceDefinitions returns definition keys for all
 // custom resource versions in each crd in crds.
 func DefinitionsForV1CustomResourceDefinitions(crds ...apiextv1.CustomResourceDefinition) (keys []registry.DefinitionKey) {
@@ -18,7 +18,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -240,3 +242,27 @@ func TestSupportsOwnerReference(t *testing.T) {
 		})
 	}
 }
+
+func TestSetRestrictedSecurityContext(t *testing.T) {
+	meta := metav1.ObjectMeta{Annotations: map[string]string{"foo": "bar"}}
+	spec := corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init"}},
+		Containers:     []corev1.Container{{Name: "a"}, {Name: "b"}},
+	}
+	SetRestrictedSecurityContext(&meta, &spec)
+
+	assert.Equal(t, map[string]string{
+		"foo":                          "bar",
+		corev1.SeccompPodAnnotationKey: corev1.SeccompProfileRuntimeDefault,
+	}, meta.Annotations)
+	if assert.NotNil(t, spec.SecurityContext) {
+		assert.True(t, *spec.SecurityContext.RunAsNonRoot)
+		assert.Equal(t, RestrictedRunAsUser, *spec.SecurityContext.RunAsUser)
+	}
+	for _, c := range append(spec.InitContainers, spec.Containers...) {
+		if assert.NotNil(t, c.SecurityContext, c.Name) {
+			assert.False(t, *c.SecurityContext.AllowPrivilegeEscalation, c.Name)
+			assert.Equal(t, []corev1.Capability{"ALL"}, c.SecurityContext.Capabilities.Drop, c.Name)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RestrictedRunAsUser is the user containers run as with a restricted security
+// context, since registry images run as root by default.
+const RestrictedRunAsUser int64 = 1001
+
+// SetRestrictedSecurityContext sets security contexts satisfying the restricted
+// Pod Security Standard on the pod with meta and spec: containers run as a
+// non-root user, without privilege escalation or capabilities, and with the
+// runtime default seccomp profile. The vendored k8s.io/api predates the
+// seccompProfile field, so the profile is set by annotation, which the API
+// server copies to the field when the pod is created.
+func SetRestrictedSecurityContext(meta *metav1.ObjectMeta, spec *corev1.PodSpec) {
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[corev1.SeccompPodAnnotationKey] = corev1.SeccompProfileRuntimeDefault
+
+	runAsNonRoot, runAsUser := true, RestrictedRunAsUser
+	spec.SecurityContext = &corev1.PodSecurityContext{
+		RunAsNonRoot: &runAsNonRoot,
+		RunAsUser:    &runAsUser,
+	}
+	setContainers := func(containers []corev1.Container) {
+		for i := range containers {
+			allowPrivilegeEscalation := false
+			containers[i].SecurityContext = &corev1.SecurityContext{
+				AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+				Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+			}
+		}
+	}
+	setContainers(spec.InitContainers)
+	setContainers(spec.Containers)
+}
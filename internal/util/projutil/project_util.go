@@ -15,9 +15,11 @@
 package projutil
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"regexp"
 	"strings"
 
@@ -92,6 +94,15 @@ func ReadConfig() (*config.Config, error) {
 	return c, nil
 }
 
+// WriteConfig writes cfg to the default path (project root).
+func WriteConfig(cfg *config.Config) error {
+	b, err := cfg.Marshal()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(configFile, b, FileMode)
+}
+
 // PluginKeyToOperatorType converts a plugin key string to an operator project type.
 // TODO(estroz): this can probably be made more robust by checking known plugin keys directly.
 func PluginKeyToOperatorType(pluginKey string) OperatorType {
@@ -155,3 +166,16 @@ func appendContent(fileContents, target, newContent string) (string, error) {
 	index := labelIndex + separationIndex + 1
 	return fileContents[:index] + newContent + fileContents[index:], nil
 }
+
+// RunCmd runs cmd, logging its combined output at debug level, and including
+// it in the returned error on failure.
+func RunCmd(cmd *exec.Cmd) error {
+	log.Debugf("Running %v", cmd.Args)
+	out := &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%v: %s", err, bytes.TrimSpace(out.Bytes()))
+	}
+	log.Debugf("%s", out.Bytes())
+	return nil
+}
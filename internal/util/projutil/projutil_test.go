@@ -16,6 +16,7 @@ package projutil
 
 import (
 	"errors"
+	"os/exec"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
@@ -90,6 +91,15 @@ var _ = Describe("Testing projutil helpers", func() {
 		})
 
 	})
+	Describe("Testing RunCmd", func() {
+		It("Should include the command's output in its error", func() {
+			err := RunCmd(exec.Command("sh", "-c", "echo no space left on device >&2; exit 1"))
+			Expect(err).To(MatchError(ContainSubstring("no space left on device")))
+		})
+		It("Should succeed when the command succeeds", func() {
+			Expect(RunCmd(exec.Command("sh", "-c", "echo done"))).To(Succeed())
+		})
+	})
 })
 
 func TestMetadata(t *testing.T) {
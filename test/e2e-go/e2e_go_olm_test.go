@@ -16,6 +16,8 @@ package e2e_go_test
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -57,6 +59,69 @@ var _ = Describe("Integrating Go Projects with OLM", func() {
 			err = tc.Make("bundle-build", "BUNDLE_IMG="+bundleImage)
 			Expect(err).NotTo(HaveOccurred())
 
+			By("regenerating bundle metadata with build arg labels")
+			generateCmd := exec.Command(tc.BinaryName, "generate", "bundle", "--metadata",
+				"--build-arg-labels", "org.opencontainers.image.version=VERSION,org.opencontainers.image.revision=VCS_REF")
+			_, err = tc.Run(generateCmd)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("building the operator bundle image with build args using buildkit")
+			const vcsRef = "0123456789abcdef0123456789abcdef01234567"
+			buildCmd := exec.Command("env", "DOCKER_BUILDKIT=1", "docker", "build",
+				"-f", "bundle.Dockerfile",
+				"--build-arg", "VERSION="+operatorVersion,
+				"--build-arg", "VCS_REF="+vcsRef,
+				"-t", bundleImage, ".")
+			_, err = tc.Run(buildCmd)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("checking the bundle image's build arg labels")
+			inspectCmd := exec.Command("docker", "inspect", "--format", "{{json .Config.Labels}}", bundleImage)
+			labelsOutput, err := tc.Run(inspectCmd)
+			Expect(err).NotTo(HaveOccurred())
+			imageLabels := map[string]string{}
+			Expect(json.Unmarshal(labelsOutput, &imageLabels)).To(Succeed())
+			Expect(imageLabels).To(HaveKeyWithValue("org.opencontainers.image.version", operatorVersion))
+			Expect(imageLabels).To(HaveKeyWithValue("org.opencontainers.image.revision", vcsRef))
+			Expect(imageLabels).To(HaveKeyWithValue("operators.operatorframework.io.bundle.package.v1", projectName))
+
+			By("validating the bundle image pulled from a local registry without a container tool")
+			const registryName = "e2e-bundle-validate-registry"
+			registryCmd := exec.Command("docker", "run", "-d", "--rm", "-p", "5000:5000", "--name", registryName, "registry:2")
+			_, err = tc.Run(registryCmd)
+			Expect(err).NotTo(HaveOccurred())
+			defer func() {
+				_, err := tc.Run(exec.Command("docker", "rm", "-f", registryName))
+				Expect(err).NotTo(HaveOccurred())
+			}()
+			localBundleImage := "localhost:5000/" + projectName + "-bundle:v" + operatorVersion
+			_, err = tc.Run(exec.Command("docker", "tag", bundleImage, localBundleImage))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = tc.Run(exec.Command("docker", "push", localBundleImage))
+			Expect(err).NotTo(HaveOccurred())
+			validateCmd := exec.Command(tc.BinaryName, "bundle", "validate", localBundleImage,
+				"--image-builder", "none", "--use-http")
+			_, err = tc.Run(validateCmd)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("validating the architectures of a bundle's multi-arch images")
+			multiArchDir, err := ioutil.TempDir("", "e2e-bundle-multiarch-")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(multiArchDir)
+			_, err = tc.Run(exec.Command("cp", "-r", filepath.Join(tc.Dir, "bundle"), multiArchDir))
+			Expect(err).NotTo(HaveOccurred())
+			multiArchBundleDir := filepath.Join(multiArchDir, "bundle")
+			csvPath := filepath.Join(multiArchBundleDir, "manifests", projectName+".clusterserviceversion.yaml")
+			testutils.ReplaceRegexInFile(csvPath, `image: .+`, "image: docker.io/library/busybox:1.32")
+			testutils.ReplaceInFile(csvPath, "metadata:\n  annotations:", "metadata:\n  labels:\n"+
+				"    operatorframework.io/arch.amd64: supported\n"+
+				"    operatorframework.io/arch.arm64: supported\n"+
+				"  annotations:")
+			validateCmd = exec.Command(tc.BinaryName, "bundle", "validate", multiArchBundleDir,
+				"--select-optional", "name=multiarch", "--verify-images")
+			_, err = tc.Run(validateCmd)
+			Expect(err).NotTo(HaveOccurred())
+
 			if isRunningOnKind() {
 				By("loading the bundle image into Kind cluster")
 				err = tc.LoadImageToKindClusterWithName(bundleImage)
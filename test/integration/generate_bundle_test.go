@@ -0,0 +1,151 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	genbundle "github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/generate/bundle"
+)
+
+// TestGenerateBundleImageDigests generates a bundle with --use-image-digests for
+// manifests whose Deployment runs an image pushed to the registry in TEST_HTTP_REGISTRY,
+// or TEST_REGISTRY if unset, and checks that the image is pinned to its pushed digest.
+func TestGenerateBundleImageDigests(t *testing.T) {
+	testRegistry, digestArgs := os.Getenv(httpRegistryEnvVar), []string{"--use-image-digests", "--use-http"}
+	if testRegistry == "" {
+		testRegistry, digestArgs = os.Getenv(registryEnvVar), []string{"--use-image-digests"}
+	}
+	if testRegistry == "" {
+		t.Skipf("%s or %s must be set to push images", httpRegistryEnvVar, registryEnvVar)
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skipf("docker must be installed to build images: %v", err)
+	}
+
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	// Any pushed image will do, so use a bundle image.
+	allNamespacesModes := []operatorsv1alpha1.InstallMode{
+		{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+	}
+	imageName := fmt.Sprintf("%s/%s-digests", testRegistry, defaultOperatorName)
+	image := buildBundleImages(t, tmp, imageName, allNamespacesModes, defaultOperatorVersion)[defaultOperatorVersion]
+	out, err := exec.Command("docker", "inspect", "--format", `{{join .RepoDigests "\n"}}`, image).CombinedOutput()
+	if err != nil {
+		t.Fatalf("docker inspect %s: %v\n%s", image, err, out)
+	}
+	var pinnedImage string
+	for _, repoDigest := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if strings.HasPrefix(repoDigest, imageName+"@") {
+			pinnedImage = repoDigest
+		}
+	}
+	if pinnedImage == "" {
+		t.Fatalf("no digest of %s found in %q", imageName, out)
+	}
+
+	projectDir := filepath.Join(tmp, "digests-operator")
+	writeDigestsManifests(t, projectDir, image)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(projectDir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		assert.NoError(t, os.Chdir(wd))
+	}()
+
+	cmd := genbundle.NewCmd()
+	cmd.SetArgs(append([]string{"--manifests", "--quiet", "--version", defaultOperatorVersion,
+		"--deploy-dir", "deploy", "--crds-dir", filepath.Join("deploy", "crds")}, digestArgs...))
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join("bundle", "manifests", "digests-operator.clusterserviceversion.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	csv := operatorsv1alpha1.ClusterServiceVersion{}
+	if err := yaml.Unmarshal(b, &csv); err != nil {
+		t.Fatal(err)
+	}
+	depSpecs := csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs
+	if assert.Len(t, depSpecs, 1) && assert.Len(t, depSpecs[0].Spec.Template.Spec.Containers, 1) {
+		c := depSpecs[0].Spec.Template.Spec.Containers[0]
+		assert.Equal(t, pinnedImage, c.Image)
+		assert.Equal(t, []corev1.EnvVar{{Name: "RELATED_IMAGE_BUNDLE", Value: pinnedImage}}, c.Env)
+	}
+	for _, ri := range csv.Spec.RelatedImages {
+		assert.Equal(t, pinnedImage, ri.Image, "related image %s", ri.Name)
+	}
+}
+
+// writeDigestsManifests writes a Deployment running image and a CRD to dir's
+// deploy directory, laid out for bundle generation without a PROJECT file.
+func writeDigestsManifests(t *testing.T, dir, image string) {
+	replicas := int32(1)
+	labels := map[string]string{"name": "digests-operator"}
+	dep := appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "digests-operator"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "manager",
+						Image: image,
+						Env:   []corev1.EnvVar{{Name: "RELATED_IMAGE_BUNDLE", Value: image}},
+					}},
+				},
+			},
+		},
+	}
+	if err := writeManifest(filepath.Join(dir, "deploy", "operator.yaml"), dep); err != nil {
+		t.Fatal(err)
+	}
+	crd := newV1CRD(DefinitionKey{
+		Kind:  "Memcached",
+		Name:  "memcacheds.cache.example.com",
+		Group: "cache.example.com",
+		Versions: []apiextv1.CustomResourceDefinitionVersion{
+			{Name: "v1alpha1", Storage: true, Served: true},
+		},
+	})
+	if err := writeManifest(filepath.Join(dir, "deploy", "crds", "crd.yaml"), crd); err != nil {
+		t.Fatal(err)
+	}
+}
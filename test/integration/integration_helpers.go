@@ -26,6 +26,7 @@ import (
 	apimanifests "github.com/operator-framework/api/pkg/manifests"
 	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/operator-framework/operator-registry/pkg/lib/bundle"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
@@ -33,6 +34,24 @@ import (
 
 const (
 	imageEnvVar = "OSDK_INTEGRATION_IMAGE"
+	// registryEnvVar is a registry, ex. "localhost:5000", that registry images
+	// built by tests are pushed to and the cluster can pull from.
+	registryEnvVar = "TEST_REGISTRY"
+	// privateRegistryEnvVar is a registry requiring credentials to pull from,
+	// which the local docker client must be logged in to.
+	privateRegistryEnvVar = "TEST_PRIVATE_REGISTRY"
+	// privateRegistryConfigEnvVar is the path to a docker config.json containing
+	// credentials the cluster pulls from privateRegistryEnvVar with.
+	privateRegistryConfigEnvVar = "TEST_PRIVATE_REGISTRY_DOCKER_CONFIG"
+	// caRegistryEnvVar is a registry serving TLS with a certificate signed by a
+	// self-signed CA, ex. one run locally, that registry images are pushed to.
+	caRegistryEnvVar = "TEST_CA_REGISTRY"
+	// caRegistryCertEnvVar is the path to the PEM-encoded CA certificate that signed
+	// caRegistryEnvVar's certificate.
+	caRegistryCertEnvVar = "TEST_CA_REGISTRY_CERT"
+	// httpRegistryEnvVar is a registry serving plain HTTP, ex. a local registry
+	// connected to a kind cluster whose nodes pull from it over HTTP.
+	httpRegistryEnvVar = "TEST_HTTP_REGISTRY"
 )
 
 var (
@@ -44,7 +63,10 @@ type DefinitionKey struct {
 	Kind     string
 	Name     string
 	Group    string
-	Versions []apiextv1beta1.CustomResourceDefinitionVersion
+	Versions []apiextv1.CustomResourceDefinitionVersion
+	// V1beta1 writes this definition as an apiextensions.k8s.io/v1beta1 CRD
+	// instead of an apiextensions.k8s.io/v1 CRD.
+	V1beta1 bool
 }
 
 type CSVTemplateConfig struct {
@@ -204,23 +226,11 @@ func writeOperatorManifests(dir string, csvConfig CSVTemplateConfig) error {
 		manifestDir = filepath.Join(dir, csvConfig.Version)
 	}
 	for _, key := range csvConfig.CRDKeys {
-		crd := apiextv1beta1.CustomResourceDefinition{
-			TypeMeta: metav1.TypeMeta{
-				APIVersion: apiextv1beta1.SchemeGroupVersion.String(),
-				Kind:       "CustomResourceDefinition",
-			},
-			ObjectMeta: metav1.ObjectMeta{Name: key.Name},
-			Spec: apiextv1beta1.CustomResourceDefinitionSpec{
-				Names: apiextv1beta1.CustomResourceDefinitionNames{
-					Kind:     key.Kind,
-					ListKind: key.Kind + "List",
-					Singular: strings.ToLower(key.Kind),
-					Plural:   strings.ToLower(key.Kind) + "s",
-				},
-				Group:    key.Group,
-				Scope:    "Namespaced",
-				Versions: key.Versions,
-			},
+		var crd interface{}
+		if key.V1beta1 {
+			crd = newV1beta1CRD(key)
+		} else {
+			crd = newV1CRD(key)
 		}
 		crdPath := filepath.Join(manifestDir, fmt.Sprintf("%s_%ss.yaml", key.Name, strings.ToLower(key.Kind)))
 		if err := writeManifest(crdPath, crd); err != nil {
@@ -234,6 +244,63 @@ func writeOperatorManifests(dir string, csvConfig CSVTemplateConfig) error {
 	return nil
 }
 
+func newV1CRD(key DefinitionKey) apiextv1.CustomResourceDefinition {
+	preserve := true
+	versions := make([]apiextv1.CustomResourceDefinitionVersion, len(key.Versions))
+	for i, v := range key.Versions {
+		// v1 CRDs must have a schema for each version.
+		if v.Schema == nil {
+			v.Schema = &apiextv1.CustomResourceValidation{
+				OpenAPIV3Schema: &apiextv1.JSONSchemaProps{Type: "object", XPreserveUnknownFields: &preserve},
+			}
+		}
+		versions[i] = v
+	}
+	return apiextv1.CustomResourceDefinition{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: apiextv1.SchemeGroupVersion.String(),
+			Kind:       "CustomResourceDefinition",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name},
+		Spec: apiextv1.CustomResourceDefinitionSpec{
+			Names: apiextv1.CustomResourceDefinitionNames{
+				Kind:     key.Kind,
+				ListKind: key.Kind + "List",
+				Singular: strings.ToLower(key.Kind),
+				Plural:   strings.ToLower(key.Kind) + "s",
+			},
+			Group:    key.Group,
+			Scope:    apiextv1.NamespaceScoped,
+			Versions: versions,
+		},
+	}
+}
+
+func newV1beta1CRD(key DefinitionKey) apiextv1beta1.CustomResourceDefinition {
+	versions := make([]apiextv1beta1.CustomResourceDefinitionVersion, len(key.Versions))
+	for i, v := range key.Versions {
+		versions[i] = apiextv1beta1.CustomResourceDefinitionVersion{Name: v.Name, Served: v.Served, Storage: v.Storage}
+	}
+	return apiextv1beta1.CustomResourceDefinition{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: apiextv1beta1.SchemeGroupVersion.String(),
+			Kind:       "CustomResourceDefinition",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name},
+		Spec: apiextv1beta1.CustomResourceDefinitionSpec{
+			Names: apiextv1beta1.CustomResourceDefinitionNames{
+				Kind:     key.Kind,
+				ListKind: key.Kind + "List",
+				Singular: strings.ToLower(key.Kind),
+				Plural:   strings.ToLower(key.Kind) + "s",
+			},
+			Group:    key.Group,
+			Scope:    "Namespaced",
+			Versions: versions,
+		},
+	}
+}
+
 func writePackageManifest(dir, pkgName string, channels []apimanifests.PackageChannel) error {
 	pkg := apimanifests.PackageManifest{
 		PackageName:        pkgName,
@@ -16,6 +16,7 @@ package e2e
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -27,12 +28,21 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
-	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	olmclient "github.com/operator-framework/operator-sdk/internal/olm/client"
 	"github.com/operator-framework/operator-sdk/internal/olm/operator"
 	"github.com/operator-framework/operator-sdk/internal/olm/operator/packagemanifests"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/parity"
 	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
 )
 
@@ -41,6 +51,11 @@ const (
 
 	defaultOperatorName    = "memcached-operator"
 	defaultOperatorVersion = "0.0.2"
+
+	// mismatchedPackageName is a package name that is not a prefix of its CSVs'
+	// names, which are derived from mismatchedOperatorName.
+	mismatchedPackageName  = "acme-thing"
+	mismatchedOperatorName = "acme-thing-operator"
 )
 
 var (
@@ -54,9 +69,21 @@ func TestOLMIntegration(t *testing.T) {
 		testImageTag = image
 	}
 
-	t.Run("PackageManifestsBasic", PackageManifestsBasic)
-	t.Run("PackageManifestsOwnNamespace", PackageManifestsOwnNamespace)
-	t.Run("PackageManifestsMultiplePackages", PackageManifestsMultiplePackages)
+	t.Run("PackageManifestsBasic", recorded("PackageManifestsBasic", PackageManifestsBasic))
+	t.Run("PackageManifestsUpgrade", recorded("PackageManifestsUpgrade", PackageManifestsUpgrade))
+	t.Run("PackageManifestsOwnNamespace", recorded("PackageManifestsOwnNamespace", PackageManifestsOwnNamespace))
+	t.Run("PackageManifestsMultiplePackages", recorded("PackageManifestsMultiplePackages", PackageManifestsMultiplePackages))
+	t.Run("PackageManifestsCanary", recorded("PackageManifestsCanary", PackageManifestsCanary))
+	t.Run("PackageManifestsCatalogStageTimeout", recorded("PackageManifestsCatalogStageTimeout", PackageManifestsCatalogStageTimeout))
+	t.Run("PackageManifestsNonexistentImage", recorded("PackageManifestsNonexistentImage", PackageManifestsNonexistentImage))
+	t.Run("PackageManifestsMismatchedNames", recorded("PackageManifestsMismatchedNames", PackageManifestsMismatchedNames))
+	t.Run("PackageManifestsOrphanCleanup", recorded("PackageManifestsOrphanCleanup", PackageManifestsOrphanCleanup))
+	t.Run("PackageManifestsFBC", recorded("PackageManifestsFBC", PackageManifestsFBC))
+	t.Run("PackageManifestsImpersonationForbidden", recorded("PackageManifestsImpersonationForbidden", PackageManifestsImpersonationForbidden))
+	t.Run("PackageManifestsEnvOverrides", recorded("PackageManifestsEnvOverrides", PackageManifestsEnvOverrides))
+	t.Run("PackageManifestsRegistryImage", PackageManifestsRegistryImage)
+	t.Run("PackageManifestsCorruptCSV", PackageManifestsCorruptCSV)
+	t.Run("PackageManifestsRestrictedSecurityContext", PackageManifestsRestrictedSecurityContext)
 }
 
 func PackageManifestsOwnNamespace(t *testing.T) {
@@ -71,7 +98,7 @@ func PackageManifestsOwnNamespace(t *testing.T) {
 				Kind:  "Memcached",
 				Name:  "memcacheds.cache.example.com",
 				Group: "cache.example.com",
-				Versions: []apiextv1beta1.CustomResourceDefinitionVersion{
+				Versions: []apiextv1.CustomResourceDefinitionVersion{
 					{Name: "v1alpha1", Storage: true, Served: true},
 				},
 			},
@@ -101,8 +128,7 @@ func PackageManifestsOwnNamespace(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	cfg := &operator.Configuration{KubeconfigPath: kubeconfigPath}
-	assert.NoError(t, cfg.Load())
+	cfg := newConfig(t)
 	i := packagemanifests.NewInstall(cfg)
 	i.PackageManifestsDirectory = manifestsDir
 	i.Version = defaultOperatorVersion
@@ -134,7 +160,7 @@ func PackageManifestsBasic(t *testing.T) {
 				Kind:  "Memcached",
 				Name:  "memcacheds.cache.example.com",
 				Group: "cache.example.com",
-				Versions: []apiextv1beta1.CustomResourceDefinitionVersion{
+				Versions: []apiextv1.CustomResourceDefinitionVersion{
 					{Name: "v1alpha1", Storage: true, Served: true},
 				},
 			},
@@ -163,8 +189,7 @@ func PackageManifestsBasic(t *testing.T) {
 		os.RemoveAll(tmp)
 		t.Fatal(err)
 	}
-	cfg := &operator.Configuration{KubeconfigPath: kubeconfigPath}
-	assert.NoError(t, cfg.Load())
+	cfg := newConfig(t)
 	i := packagemanifests.NewInstall(cfg)
 	i.PackageManifestsDirectory = manifestsDir
 	i.Version = defaultOperatorVersion
@@ -183,6 +208,114 @@ func PackageManifestsBasic(t *testing.T) {
 	assert.Error(t, doUninstall(t, kubeconfigPath))
 }
 
+// PackageManifestsUpgrade installs the first version of a package, then adds the
+// version replacing it to the package's catalog and upgrades the operator to it.
+func PackageManifestsUpgrade(t *testing.T) {
+
+	operatorVersion1 := "0.0.1"
+	operatorVersion2 := defaultOperatorVersion
+	csvName1 := fmt.Sprintf("%s.v%s", defaultOperatorName, operatorVersion1)
+	csvName2 := fmt.Sprintf("%s.v%s", defaultOperatorName, operatorVersion2)
+	csvConfig := CSVTemplateConfig{
+		OperatorName: defaultOperatorName,
+		Version:      operatorVersion1,
+		TestImageTag: testImageTag,
+		CRDKeys: []DefinitionKey{
+			{
+				Kind:  "Memcached",
+				Name:  "memcacheds.cache.example.com",
+				Group: "cache.example.com",
+				Versions: []apiextv1.CustomResourceDefinitionVersion{
+					{Name: "v1alpha1", Storage: true, Served: true},
+				},
+			},
+		},
+		InstallModes: []operatorsv1alpha1.InstallMode{
+			{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+		},
+	}
+
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	manifestsDir := filepath.Join(tmp, defaultOperatorName)
+	if err := writeOperatorManifests(manifestsDir, csvConfig); err != nil {
+		t.Fatal(err)
+	}
+	channels := []apimanifests.PackageChannel{{Name: "alpha", CurrentCSVName: csvName1}}
+	if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newConfig(t)
+	i := packagemanifests.NewInstall(cfg)
+	i.PackageManifestsDirectory = manifestsDir
+	i.Version = operatorVersion1
+
+	// Cleanup.
+	defer func() {
+		assert.NoError(t, doUninstall(t, kubeconfigPath))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	prevCSV, err := i.Run(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, csvName1, prevCSV.GetName())
+
+	// Add the next version to the package, which the catalog serves once updated.
+	csvConfig.Version = operatorVersion2
+	csvConfig.ReplacesCSVName = csvName1
+	if err := writeOperatorManifests(manifestsDir, csvConfig); err != nil {
+		t.Fatal(err)
+	}
+	channels[0].CurrentCSVName = csvName2
+	if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+		t.Fatal(err)
+	}
+	pkg, bundles, err := apimanifests.GetManifestsDir(manifestsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := registry.NewConfigMapCatalogCreator(cfg)
+	c.Package, c.Bundles = pkg, bundles
+	if c.Format, err = registry.ResolveCatalogFormat(ctx, cfg, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	u := registry.NewOperatorInstaller(cfg)
+	u.PackageName = defaultOperatorName
+	u.StartingCSV = csvName2
+	u.Channel = "alpha"
+	u.CatalogUpdater = configMapCatalogUpdater{c}
+	csv, err := u.UpgradeOperator(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, csvName2, csv.GetName())
+	assert.Equal(t, csvName1, csv.Spec.Replaces)
+	assert.Equal(t, operatorsv1alpha1.CSVPhaseSucceeded, csv.Status.Phase)
+
+	// Upgrading to the installed version is refused.
+	_, err = u.UpgradeOperator(ctx)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is already installed")
+	}
+}
+
+// configMapCatalogUpdater updates a package's ConfigMap catalog to serve the
+// package and bundles of its ConfigMapCatalogCreator.
+type configMapCatalogUpdater struct {
+	*registry.ConfigMapCatalogCreator
+}
+
+func (u configMapCatalogUpdater) UpdateCatalog(ctx context.Context, cs *operatorsv1alpha1.CatalogSource) error {
+	_, err := u.CreateCatalog(ctx, cs.GetName())
+	return err
+}
+
 func PackageManifestsMultiplePackages(t *testing.T) {
 
 	operatorVersion1 := defaultOperatorVersion
@@ -198,7 +331,7 @@ func PackageManifestsMultiplePackages(t *testing.T) {
 					Kind:  "Memcached",
 					Name:  "memcacheds.cache.example.com",
 					Group: "cache.example.com",
-					Versions: []apiextv1beta1.CustomResourceDefinitionVersion{
+					Versions: []apiextv1.CustomResourceDefinitionVersion{
 						{Name: "v1alpha1", Storage: true, Served: true},
 					},
 				},
@@ -220,7 +353,7 @@ func PackageManifestsMultiplePackages(t *testing.T) {
 					Kind:  "Memcached",
 					Name:  "memcacheds.cache.example.com",
 					Group: "cache.example.com",
-					Versions: []apiextv1beta1.CustomResourceDefinitionVersion{
+					Versions: []apiextv1.CustomResourceDefinitionVersion{
 						{Name: "v1alpha1", Storage: false, Served: true},
 						{Name: "v1alpha2", Storage: true, Served: true},
 					},
@@ -255,8 +388,9 @@ func PackageManifestsMultiplePackages(t *testing.T) {
 		os.RemoveAll(tmp)
 		t.Fatal(err)
 	}
-	cfg := &operator.Configuration{KubeconfigPath: kubeconfigPath}
-	assert.NoError(t, cfg.Load())
+	// Catalog content generated by operator-sdk must match what operator-registry builds.
+	assert.NoError(t, parity.Check(context.TODO(), manifestsDir, parity.DefaultAllowlist))
+	cfg := newConfig(t)
 	i := packagemanifests.NewInstall(cfg)
 	i.PackageManifestsDirectory = manifestsDir
 	i.Version = operatorVersion2
@@ -265,15 +399,777 @@ func PackageManifestsMultiplePackages(t *testing.T) {
 	assert.NoError(t, doInstall(i))
 	// Remove operator after deploy
 	assert.NoError(t, doUninstall(t, kubeconfigPath))
+
+	// Deploy each version from each channel explicitly.
+	for _, c := range []struct {
+		channel, version string
+	}{
+		{"stable", operatorVersion2},
+		{"alpha", operatorVersion1},
+	} {
+		i := packagemanifests.NewInstall(newConfig(t))
+		i.PackageManifestsDirectory = manifestsDir
+		i.Version = c.version
+		i.Channel = c.channel
+		assert.NoError(t, doInstall(i), "install from channel %s", c.channel)
+		assert.NoError(t, doUninstall(t, kubeconfigPath), "uninstall from channel %s", c.channel)
+	}
+
+	// A version not in the channel's replaces chain must not be installed.
+	i = packagemanifests.NewInstall(newConfig(t))
+	i.PackageManifestsDirectory = manifestsDir
+	i.Version = operatorVersion2
+	i.Channel = "alpha"
+	assert.Error(t, doInstall(i))
 }
 
-func doUninstall(t *testing.T, kubeconfigPath string) error {
-	cfg := &operator.Configuration{KubeconfigPath: kubeconfigPath}
+func PackageManifestsCanary(t *testing.T) {
+
+	csvConfig := CSVTemplateConfig{
+		OperatorName:    defaultOperatorName,
+		Version:         defaultOperatorVersion,
+		TestImageTag:    testImageTag,
+		ReplacesCSVName: "",
+		CRDKeys: []DefinitionKey{
+			{
+				Kind:  "Memcached",
+				Name:  "memcacheds.cache.example.com",
+				Group: "cache.example.com",
+				Versions: []apiextv1.CustomResourceDefinitionVersion{
+					{Name: "v1alpha1", Storage: true, Served: true},
+				},
+			},
+		},
+		InstallModes: []operatorsv1alpha1.InstallMode{
+			{Type: operatorsv1alpha1.InstallModeTypeOwnNamespace, Supported: false},
+			{Type: operatorsv1alpha1.InstallModeTypeSingleNamespace, Supported: false},
+			{Type: operatorsv1alpha1.InstallModeTypeMultiNamespace, Supported: false},
+			{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+		},
+	}
+	channels := []apimanifests.PackageChannel{
+		{Name: "alpha", CurrentCSVName: fmt.Sprintf("%s.v%s", defaultOperatorName, defaultOperatorVersion)},
+	}
+
+	runCanary := func(t *testing.T, config CSVTemplateConfig, timeout time.Duration) operator.CanaryResult {
+		tmp, cleanup := mkTempDirWithCleanup(t, "")
+		defer cleanup()
+
+		manifestsDir := filepath.Join(tmp, defaultOperatorName)
+		if err := writeOperatorManifests(manifestsDir, config); err != nil {
+			t.Fatal(err)
+		}
+		if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+			t.Fatal(err)
+		}
+		cfg := newConfig(t)
+		i := packagemanifests.NewInstall(cfg)
+		i.PackageManifestsDirectory = manifestsDir
+		i.Version = defaultOperatorVersion
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		res := operator.RunCanary(ctx, cfg, &i, operator.VerifyOptions{})
+
+		vctx, vcancel := context.WithTimeout(context.Background(), defaultTimeout)
+		defer vcancel()
+		assert.NoError(t, operator.VerifyNoResiduals(vctx, cfg, defaultOperatorName))
+		return res
+	}
+
+	t.Run("Passing", func(t *testing.T) {
+		res := runCanary(t, csvConfig, defaultTimeout)
+		assert.True(t, res.Passed())
+		assert.NoError(t, res.VerifyErr)
+		assert.NoError(t, res.CleanupErr)
+	})
+
+	t.Run("Failing", func(t *testing.T) {
+		brokenConfig := csvConfig
+		brokenConfig.TestImageTag = "quay.io/operator-framework/does-not-exist:broken"
+		res := runCanary(t, brokenConfig, time.Minute)
+		assert.False(t, res.Passed())
+		assert.Error(t, res.VerifyErr)
+		assert.NotEmpty(t, res.Diagnostics)
+		assert.NoError(t, res.CleanupErr)
+	})
+}
+
+func PackageManifestsCatalogStageTimeout(t *testing.T) {
+
+	csvConfig := CSVTemplateConfig{
+		OperatorName:    defaultOperatorName,
+		Version:         defaultOperatorVersion,
+		TestImageTag:    testImageTag,
+		ReplacesCSVName: "",
+		CRDKeys: []DefinitionKey{
+			{
+				Kind:  "Memcached",
+				Name:  "memcacheds.cache.example.com",
+				Group: "cache.example.com",
+				Versions: []apiextv1.CustomResourceDefinitionVersion{
+					{Name: "v1alpha1", Storage: true, Served: true},
+				},
+			},
+		},
+		InstallModes: []operatorsv1alpha1.InstallMode{
+			{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+		},
+	}
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	channels := []apimanifests.PackageChannel{
+		{Name: "alpha", CurrentCSVName: fmt.Sprintf("%s.v%s", defaultOperatorName, defaultOperatorVersion)},
+	}
+	manifestsDir := filepath.Join(tmp, defaultOperatorName)
+	if err := writeOperatorManifests(manifestsDir, csvConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+		t.Fatal(err)
+	}
+	cfg := newConfig(t)
+	i := packagemanifests.NewInstall(cfg)
+	i.PackageManifestsDirectory = manifestsDir
+	i.Version = defaultOperatorVersion
+	// An image that cannot be pulled keeps the registry from ever serving.
+	i.RegistryImage = "quay.io/operator-framework/does-not-exist:broken"
+	i.CatalogReadyTimeout = 30 * time.Second
+
+	defer func() {
+		if err := doUninstall(t, kubeconfigPath); err != nil {
+			t.Log(err)
+		}
+	}()
+
+	err := doInstall(i)
+	stageErr := &registry.StageTimeoutError{}
+	if assert.True(t, errors.As(err, &stageErr), "expected stage timeout, got: %v", err) {
+		assert.Equal(t, registry.StageCatalog, stageErr.Stage)
+		assert.Contains(t, err.Error(), "catalog stage timed out")
+		assert.Contains(t, err.Error(), "catalog source")
+	}
+}
+
+func PackageManifestsNonexistentImage(t *testing.T) {
+
+	csvConfig := CSVTemplateConfig{
+		OperatorName: defaultOperatorName,
+		Version:      defaultOperatorVersion,
+		// An operator image that cannot be pulled keeps the CSV from succeeding.
+		TestImageTag:    "quay.io/operator-framework/does-not-exist:broken",
+		ReplacesCSVName: "",
+		CRDKeys: []DefinitionKey{
+			{
+				Kind:  "Memcached",
+				Name:  "memcacheds.cache.example.com",
+				Group: "cache.example.com",
+				Versions: []apiextv1.CustomResourceDefinitionVersion{
+					{Name: "v1alpha1", Storage: true, Served: true},
+				},
+			},
+		},
+		InstallModes: []operatorsv1alpha1.InstallMode{
+			{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+		},
+	}
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	channels := []apimanifests.PackageChannel{
+		{Name: "alpha", CurrentCSVName: fmt.Sprintf("%s.v%s", defaultOperatorName, defaultOperatorVersion)},
+	}
+	manifestsDir := filepath.Join(tmp, defaultOperatorName)
+	if err := writeOperatorManifests(manifestsDir, csvConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+		t.Fatal(err)
+	}
+	cfg := newConfig(t)
+	i := packagemanifests.NewInstall(cfg)
+	i.PackageManifestsDirectory = manifestsDir
+	i.Version = defaultOperatorVersion
+
+	defer func() {
+		if err := doUninstall(t, kubeconfigPath); err != nil {
+			t.Log(err)
+		}
+	}()
+
+	err := doInstall(i)
+	csvErr := &olmclient.CSVFailedError{}
+	if assert.True(t, errors.As(err, &csvErr), "expected CSV error, got: %v", err) {
+		assert.Contains(t, err.Error(), "Failed to pull image")
+	}
+}
+
+func PackageManifestsMismatchedNames(t *testing.T) {
+
+	crdKeys := []DefinitionKey{
+		{
+			Kind:  "Memcached",
+			Name:  "memcacheds.cache.example.com",
+			Group: "cache.example.com",
+			Versions: []apiextv1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1", Storage: true, Served: true},
+			},
+		},
+	}
+	installModes := []operatorsv1alpha1.InstallMode{
+		{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+	}
+	operatorVersion1 := defaultOperatorVersion
+	operatorVersion2 := "0.0.3"
+	csvName1 := fmt.Sprintf("%s.v%s", mismatchedOperatorName, operatorVersion1)
+	csvName2 := fmt.Sprintf("%s.v%s", mismatchedOperatorName, operatorVersion2)
+	csvConfigs := []CSVTemplateConfig{
+		{
+			OperatorName: mismatchedOperatorName,
+			Version:      operatorVersion1,
+			TestImageTag: testImageTag,
+			CRDKeys:      crdKeys,
+			InstallModes: installModes,
+		},
+		{
+			OperatorName:    mismatchedOperatorName,
+			Version:         operatorVersion2,
+			TestImageTag:    testImageTag,
+			ReplacesCSVName: csvName1,
+			CRDKeys:         crdKeys,
+			InstallModes:    installModes,
+		},
+	}
+
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	channels := []apimanifests.PackageChannel{
+		{Name: "stable", CurrentCSVName: csvName2},
+		{Name: "alpha", CurrentCSVName: csvName1},
+	}
+	manifestsDir := filepath.Join(tmp, mismatchedPackageName)
+	for _, config := range csvConfigs {
+		if err := writeOperatorManifests(manifestsDir, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writePackageManifest(manifestsDir, mismatchedPackageName, channels); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []string{operatorVersion1, operatorVersion2} {
+		t.Run(version, func(t *testing.T) {
+			cfg := newConfig(t)
+			i := packagemanifests.NewInstall(cfg)
+			i.PackageManifestsDirectory = manifestsDir
+			i.Version = version
+
+			// Deploy operator
+			assert.NoError(t, doInstall(i))
+			// Remove operator after deploy
+			assert.NoError(t, doUninstallPackage(t, kubeconfigPath, mismatchedPackageName))
+			// Remove operator after removal
+			assert.Error(t, doUninstallPackage(t, kubeconfigPath, mismatchedPackageName))
+
+			ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+			defer cancel()
+			assert.NoError(t, operator.VerifyNoResiduals(ctx, cfg, mismatchedPackageName))
+		})
+	}
+
+	t.Run("Upgrade", func(t *testing.T) {
+		upgradeDir := filepath.Join(tmp, "upgrade", mismatchedPackageName)
+		if err := writeOperatorManifests(upgradeDir, csvConfigs[0]); err != nil {
+			t.Fatal(err)
+		}
+		upgradeChannels := []apimanifests.PackageChannel{{Name: "alpha", CurrentCSVName: csvName1}}
+		if err := writePackageManifest(upgradeDir, mismatchedPackageName, upgradeChannels); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := newConfig(t)
+		i := packagemanifests.NewInstall(cfg)
+		i.PackageManifestsDirectory = upgradeDir
+		i.Version = operatorVersion1
+
+		ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+		defer cancel()
+		prevCSV, err := i.Run(ctx)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, csvName1, prevCSV.GetName())
+
+		// Serve the next version, whose CSV name is also not prefixed by the package name.
+		if err := writeOperatorManifests(upgradeDir, csvConfigs[1]); err != nil {
+			t.Fatal(err)
+		}
+		upgradeChannels[0].CurrentCSVName = csvName2
+		if err := writePackageManifest(upgradeDir, mismatchedPackageName, upgradeChannels); err != nil {
+			t.Fatal(err)
+		}
+		pkg, bundles, err := apimanifests.GetManifestsDir(upgradeDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := registry.NewConfigMapCatalogCreator(cfg)
+		c.Package, c.Bundles = pkg, bundles
+		if c.Format, err = registry.ResolveCatalogFormat(ctx, cfg, ""); err != nil {
+			t.Fatal(err)
+		}
+
+		u := registry.NewOperatorInstaller(cfg)
+		u.PackageName = mismatchedPackageName
+		u.StartingCSV = csvName2
+		u.Channel = "alpha"
+		u.CatalogUpdater = configMapCatalogUpdater{c}
+		csv, err := u.UpgradeOperator(ctx)
+		if assert.NoError(t, err) {
+			assert.Equal(t, csvName2, csv.GetName())
+			assert.Equal(t, csvName1, csv.Spec.Replaces)
+			assert.Equal(t, operatorsv1alpha1.CSVPhaseSucceeded, csv.Status.Phase)
+		}
+
+		// The upgraded CSV is found and removed by package name.
+		assert.NoError(t, doUninstallPackage(t, kubeconfigPath, mismatchedPackageName))
+		assert.NoError(t, operator.VerifyNoResiduals(ctx, cfg, mismatchedPackageName))
+	})
+}
+
+func PackageManifestsOrphanCleanup(t *testing.T) {
+
+	csvConfig := CSVTemplateConfig{
+		OperatorName: defaultOperatorName,
+		Version:      defaultOperatorVersion,
+		TestImageTag: testImageTag,
+		CRDKeys: []DefinitionKey{
+			{
+				Kind:  "Memcached",
+				Name:  "memcacheds.cache.example.com",
+				Group: "cache.example.com",
+				Versions: []apiextv1.CustomResourceDefinitionVersion{
+					{Name: "v1alpha1", Storage: true, Served: true},
+				},
+			},
+		},
+		InstallModes: []operatorsv1alpha1.InstallMode{
+			{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+		},
+	}
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	channels := []apimanifests.PackageChannel{
+		{Name: "alpha", CurrentCSVName: fmt.Sprintf("%s.v%s", defaultOperatorName, defaultOperatorVersion)},
+	}
+	manifestsDir := filepath.Join(tmp, defaultOperatorName)
+	if err := writeOperatorManifests(manifestsDir, csvConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+		t.Fatal(err)
+	}
+	cfg := newConfig(t)
+
+	// Simulate a ConfigMap left behind by a previous failed install of an old version.
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	orphan := &corev1.ConfigMap{}
+	orphan.SetName(defaultOperatorName + "-registry-manifests-0-0-1")
+	orphan.SetNamespace(cfg.Namespace)
+	orphan.SetLabels(configmap.MakeRegistryLabels(defaultOperatorName))
+	orphan.BinaryData = map[string][]byte{"stale.yaml": []byte("{}")}
+	assert.NoError(t, cfg.Client.Create(ctx, orphan))
+
+	i := packagemanifests.NewInstall(cfg)
+	i.PackageManifestsDirectory = manifestsDir
+	i.Version = defaultOperatorVersion
+
+	// Deploy operator, which should delete the orphan.
+	assert.NoError(t, doInstall(i))
+	orphanKey := types.NamespacedName{Namespace: orphan.GetNamespace(), Name: orphan.GetName()}
+	assert.True(t, apierrors.IsNotFound(cfg.Client.Get(ctx, orphanKey, &corev1.ConfigMap{})))
+
+	// Remove operator, which should sweep all remaining registry objects.
+	assert.NoError(t, doUninstall(t, kubeconfigPath))
+}
+
+func PackageManifestsFBC(t *testing.T) {
+
+	csvConfig := CSVTemplateConfig{
+		OperatorName: defaultOperatorName,
+		Version:      defaultOperatorVersion,
+		TestImageTag: testImageTag,
+		CRDKeys: []DefinitionKey{
+			{
+				Kind:  "Memcached",
+				Name:  "memcacheds.cache.example.com",
+				Group: "cache.example.com",
+				Versions: []apiextv1.CustomResourceDefinitionVersion{
+					{Name: "v1alpha1", Storage: true, Served: true},
+				},
+			},
+		},
+		InstallModes: []operatorsv1alpha1.InstallMode{
+			{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+		},
+	}
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	channels := []apimanifests.PackageChannel{
+		{Name: "alpha", CurrentCSVName: fmt.Sprintf("%s.v%s", defaultOperatorName, defaultOperatorVersion)},
+	}
+	manifestsDir := filepath.Join(tmp, defaultOperatorName)
+	if err := writeOperatorManifests(manifestsDir, csvConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+		t.Fatal(err)
+	}
+	cfg := newConfig(t)
+	i := packagemanifests.NewInstall(cfg)
+	i.PackageManifestsDirectory = manifestsDir
+	i.Version = defaultOperatorVersion
+	i.CatalogFormat = registry.CatalogFormatFBC
+
+	// Deploy operator from a file-based catalog, which is stored in a single ConfigMap.
+	assert.NoError(t, doInstall(i))
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	cms := corev1.ConfigMapList{}
+	assert.NoError(t, cfg.Client.List(ctx, &cms, client.InNamespace(cfg.Namespace),
+		client.MatchingLabels(configmap.MakeRegistryLabels(defaultOperatorName))))
+	if assert.Len(t, cms.Items, 1) {
+		assert.Equal(t, defaultOperatorName+"-registry-manifests-fbc", cms.Items[0].GetName())
+	}
+
+	// Remove operator after deploy
+	assert.NoError(t, doUninstall(t, kubeconfigPath))
+}
+
+func PackageManifestsRegistryImage(t *testing.T) {
+	testRegistry := os.Getenv(registryEnvVar)
+	if testRegistry == "" {
+		t.Skipf("%s must be set to push registry images", registryEnvVar)
+	}
+
+	csvConfig := CSVTemplateConfig{
+		OperatorName: defaultOperatorName,
+		Version:      defaultOperatorVersion,
+		TestImageTag: testImageTag,
+		CRDKeys: []DefinitionKey{
+			{
+				Kind:  "Memcached",
+				Name:  "memcacheds.cache.example.com",
+				Group: "cache.example.com",
+				Versions: []apiextv1.CustomResourceDefinitionVersion{
+					{Name: "v1alpha1", Storage: true, Served: true},
+				},
+			},
+		},
+		InstallModes: []operatorsv1alpha1.InstallMode{
+			{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+		},
+	}
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	channels := []apimanifests.PackageChannel{
+		{Name: "alpha", CurrentCSVName: fmt.Sprintf("%s.v%s", defaultOperatorName, defaultOperatorVersion)},
+	}
+	manifestsDir := filepath.Join(tmp, defaultOperatorName)
+	if err := writeOperatorManifests(manifestsDir, csvConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+		t.Fatal(err)
+	}
+	cfg := newConfig(t)
+	i := packagemanifests.NewInstall(cfg)
+	i.PackageManifestsDirectory = manifestsDir
+	i.Version = defaultOperatorVersion
+	i.UseRegistryImage = true
+	i.ImageCatalogCreator.Image = fmt.Sprintf("%s/%s-registry:v%s", testRegistry, defaultOperatorName, defaultOperatorVersion)
+
+	// Deploy operator from a pushed registry image, without registry ConfigMaps.
+	assert.NoError(t, doInstall(i))
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	cs := operatorsv1alpha1.CatalogSource{}
+	csKey := types.NamespacedName{Namespace: cfg.Namespace, Name: defaultOperatorName + "-catalog"}
+	if assert.NoError(t, cfg.Client.Get(ctx, csKey, &cs)) {
+		assert.Equal(t, i.ImageCatalogCreator.Image, cs.Spec.Image)
+	}
+	cms := corev1.ConfigMapList{}
+	assert.NoError(t, cfg.Client.List(ctx, &cms, client.InNamespace(cfg.Namespace),
+		client.MatchingLabels(configmap.MakeRegistryLabels(defaultOperatorName))))
+	assert.Empty(t, cms.Items)
+
+	// Remove operator after deploy
+	assert.NoError(t, doUninstall(t, kubeconfigPath))
+}
+
+func PackageManifestsEnvOverrides(t *testing.T) {
+
+	csvConfig := CSVTemplateConfig{
+		OperatorName: defaultOperatorName,
+		Version:      defaultOperatorVersion,
+		TestImageTag: testImageTag,
+		CRDKeys: []DefinitionKey{
+			{
+				Kind:  "Memcached",
+				Name:  "memcacheds.cache.example.com",
+				Group: "cache.example.com",
+				Versions: []apiextv1.CustomResourceDefinitionVersion{
+					{Name: "v1alpha1", Storage: true, Served: true},
+				},
+			},
+		},
+		InstallModes: []operatorsv1alpha1.InstallMode{
+			{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+		},
+	}
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	channels := []apimanifests.PackageChannel{
+		{Name: "alpha", CurrentCSVName: fmt.Sprintf("%s.v%s", defaultOperatorName, defaultOperatorVersion)},
+	}
+	manifestsDir := filepath.Join(tmp, defaultOperatorName)
+	if err := writeOperatorManifests(manifestsDir, csvConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+		t.Fatal(err)
+	}
+	overrides := []corev1.EnvVar{
+		{Name: "WATCH_NAMESPACE", Value: "default"},
+		{Name: "FEATURE_GATES", Value: "Foo=true"},
+	}
+	cfg := newConfig(t)
+	i := packagemanifests.NewInstall(cfg)
+	i.PackageManifestsDirectory = manifestsDir
+	i.Version = defaultOperatorVersion
+	i.EnvOverrides = overrides
+
+	defer func() {
+		if err := doUninstall(t, kubeconfigPath); err != nil {
+			t.Log(err)
+		}
+	}()
+
+	if !assert.NoError(t, doInstall(i)) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	pods := corev1.PodList{}
+	assert.NoError(t, cfg.Client.List(ctx, &pods, client.InNamespace(cfg.Namespace),
+		client.MatchingLabels{"control-plane": "controller-manager"}))
+	if assert.NotEmpty(t, pods.Items) {
+		for _, pod := range pods.Items {
+			// Pods of previous scenarios may still be terminating.
+			if pod.GetDeletionTimestamp() != nil {
+				continue
+			}
+			for _, c := range pod.Spec.Containers {
+				for _, env := range overrides {
+					assert.Contains(t, c.Env, env, "pod %s container %s", pod.GetName(), c.Name)
+				}
+			}
+		}
+	}
+}
+
+// PackageManifestsCorruptCSV serves a CSV the registry cannot parse, which
+// the SDK's own loader would reject, so it is corrupted after being loaded.
+func PackageManifestsCorruptCSV(t *testing.T) {
+
+	csvConfig := CSVTemplateConfig{
+		OperatorName: defaultOperatorName,
+		Version:      defaultOperatorVersion,
+		TestImageTag: testImageTag,
+		CRDKeys: []DefinitionKey{
+			{
+				Kind:  "Memcached",
+				Name:  "memcacheds.cache.example.com",
+				Group: "cache.example.com",
+				Versions: []apiextv1.CustomResourceDefinitionVersion{
+					{Name: "v1alpha1", Storage: true, Served: true},
+				},
+			},
+		},
+		InstallModes: []operatorsv1alpha1.InstallMode{
+			{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+		},
+	}
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	channels := []apimanifests.PackageChannel{
+		{Name: "alpha", CurrentCSVName: fmt.Sprintf("%s.v%s", defaultOperatorName, defaultOperatorVersion)},
+	}
+	manifestsDir := filepath.Join(tmp, defaultOperatorName)
+	if err := writeOperatorManifests(manifestsDir, csvConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+		t.Fatal(err)
+	}
+	pkg, bundles, err := apimanifests.GetManifestsDir(manifestsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range bundles[0].Objects {
+		if obj.GetKind() == operatorsv1alpha1.ClusterServiceVersionKind {
+			if err := unstructured.SetNestedField(obj.Object, "memcacheds.cache.example.com", "spec", "customresourcedefinitions"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	cfg := newConfig(t)
+	olmClient, err := olmclient.NewClientForConfig(cfg.RESTConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	// Registry objects are owned by the CatalogSource, so are garbage collected with it.
+	catsrc := &operatorsv1alpha1.CatalogSource{}
+	catsrc.SetName(defaultOperatorName + "-catalog")
+	catsrc.SetNamespace(cfg.Namespace)
+	catsrc.Spec.SourceType = operatorsv1alpha1.SourceTypeGrpc
+	if err := cfg.Client.Create(ctx, catsrc); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cfg.Client.Delete(context.Background(), catsrc); err != nil {
+			t.Log(err)
+		}
+	}()
+
+	rr := configmap.RegistryResources{Client: olmClient, Pkg: pkg, Bundles: bundles}
+	err = rr.CreatePackageManifestsRegistry(ctx, catsrc, cfg.Namespace)
+	podErr := &olmclient.PodFailedError{}
+	if assert.True(t, errors.As(err, &podErr), "expected registry pod error, got: %v", err) {
+		assert.NotEmpty(t, podErr.Logs)
+		assert.Contains(t, err.Error(), "cannot unmarshal string")
+	}
+}
+
+func PackageManifestsRestrictedSecurityContext(t *testing.T) {
+
+	csvConfig := CSVTemplateConfig{
+		OperatorName: defaultOperatorName,
+		Version:      defaultOperatorVersion,
+		TestImageTag: testImageTag,
+		InstallModes: []operatorsv1alpha1.InstallMode{
+			{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+		},
+	}
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	channels := []apimanifests.PackageChannel{
+		{Name: "alpha", CurrentCSVName: fmt.Sprintf("%s.v%s", defaultOperatorName, defaultOperatorVersion)},
+	}
+	manifestsDir := filepath.Join(tmp, defaultOperatorName)
+	if err := writeOperatorManifests(manifestsDir, csvConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+		t.Fatal(err)
+	}
+	pkg, bundles, err := apimanifests.GetManifestsDir(manifestsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newConfig(t)
+	olmClient, err := olmclient.NewClientForConfig(cfg.RESTConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	// Pods that do not satisfy the restricted Pod Security Standard are rejected in this namespace.
+	ns := &corev1.Namespace{}
+	ns.SetName("operator-sdk-restricted")
+	ns.SetLabels(map[string]string{"pod-security.kubernetes.io/enforce": "restricted"})
+	if err := cfg.Client.Create(ctx, ns); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cfg.Client.Delete(context.Background(), ns); err != nil {
+			t.Log(err)
+		}
+	}()
+	catsrc := &operatorsv1alpha1.CatalogSource{}
+	catsrc.SetName(defaultOperatorName + "-catalog")
+	catsrc.SetNamespace(ns.GetName())
+	catsrc.Spec.SourceType = operatorsv1alpha1.SourceTypeGrpc
+	if err := cfg.Client.Create(ctx, catsrc); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := configmap.RegistryResources{Client: olmClient, Pkg: pkg, Bundles: bundles, RestrictedSecurityContext: true}
+	assert.NoError(t, rr.CreatePackageManifestsRegistry(ctx, catsrc, ns.GetName()))
+}
+
+func PackageManifestsImpersonationForbidden(t *testing.T) {
+
+	csvConfig := CSVTemplateConfig{
+		OperatorName: defaultOperatorName,
+		Version:      defaultOperatorVersion,
+		TestImageTag: testImageTag,
+		InstallModes: []operatorsv1alpha1.InstallMode{
+			{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+		},
+	}
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	channels := []apimanifests.PackageChannel{
+		{Name: "alpha", CurrentCSVName: fmt.Sprintf("%s.v%s", defaultOperatorName, defaultOperatorVersion)},
+	}
+	manifestsDir := filepath.Join(tmp, defaultOperatorName)
+	if err := writeOperatorManifests(manifestsDir, csvConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+		t.Fatal(err)
+	}
+	// A service account with no RBAC bound to it.
+	const user = "system:serviceaccount:default:operator-sdk-no-rbac"
+	cfg := &operator.Configuration{KubeconfigPath: kubeconfigPath, ImpersonateUser: user, WrapTransport: wrapTransport}
 	assert.NoError(t, cfg.Load())
+	assert.Equal(t, user, cfg.RESTConfig.Impersonate.UserName)
+	i := packagemanifests.NewInstall(cfg)
+	i.PackageManifestsDirectory = manifestsDir
+	i.Version = defaultOperatorVersion
+	i.CatalogFormat = registry.CatalogFormatConfigMap
+
+	err := doInstall(i)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "forbidden")
+		assert.Contains(t, err.Error(), fmt.Sprintf("impersonating user %q", user))
+	}
+}
+
+func doUninstall(t *testing.T, kubeconfigPath string) error {
+	return doUninstallPackage(t, kubeconfigPath, defaultOperatorName)
+}
+
+func doUninstallPackage(t *testing.T, kubeconfigPath, packageName string) error {
+	cfg := newConfig(t)
 	uninstall := operator.NewUninstall(cfg)
 	uninstall.DeleteAll = true
 	uninstall.DeleteOperatorGroupNames = []string{operator.SDKOperatorGroupName}
-	uninstall.Package = defaultOperatorName
+	uninstall.Package = packageName
 	uninstall.Logf = logrus.Infof
 
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
@@ -281,7 +1177,7 @@ func doUninstall(t *testing.T, kubeconfigPath string) error {
 	if err := uninstall.Run(ctx); err != nil {
 		return err
 	}
-	return waitForPackageManifestConfigMapDeletion(ctx, cfg, defaultOperatorName)
+	return waitForRegistryObjectsDeletion(ctx, cfg, packageName)
 }
 
 type installer interface {
@@ -296,16 +1192,26 @@ func doInstall(i installer) error {
 	return err
 }
 
-func waitForPackageManifestConfigMapDeletion(ctx context.Context, cfg *operator.Configuration, packageName string) error {
-	cfgmaps := corev1.ConfigMapList{}
+// waitForRegistryObjectsDeletion waits for all operator-sdk registry objects
+// for packageName, of every kind in objLists, to be deleted. If no lists are
+// given, ConfigMaps and Pods are checked.
+func waitForRegistryObjectsDeletion(ctx context.Context, cfg *operator.Configuration, packageName string, objLists ...runtime.Object) error {
+	if len(objLists) == 0 {
+		objLists = []runtime.Object{&corev1.ConfigMapList{}, &corev1.PodList{}}
+	}
 	opts := []client.ListOption{
 		client.InNamespace(cfg.Namespace),
-		client.MatchingLabels{"owner": "operator-sdk", "package-name": packageName},
+		client.MatchingLabels(configmap.MakeRegistryLabels(packageName)),
 	}
 	return wait.PollImmediateUntil(250*time.Millisecond, func() (bool, error) {
-		if err := cfg.Client.List(ctx, &cfgmaps, opts...); err != nil {
-			return false, err
+		for _, list := range objLists {
+			if err := cfg.Client.List(ctx, list, opts...); err != nil {
+				return false, err
+			}
+			if meta.LenList(list) != 0 {
+				return false, nil
+			}
 		}
-		return len(cfgmaps.Items) == 0, nil
+		return true, nil
 	}, ctx.Done())
 }
@@ -0,0 +1,123 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-registry/pkg/image/containerdregistry"
+	registrybundle "github.com/operator-framework/operator-registry/pkg/lib/bundle"
+	"github.com/stretchr/testify/assert"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/pkgmantobundle"
+	internalregistry "github.com/operator-framework/operator-sdk/internal/registry"
+)
+
+// TestPkgManToBundle converts memcached-operator package manifests into
+// bundles and checks that each bundle passes bundle validation.
+func TestPkgManToBundle(t *testing.T) {
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	manifestsDir := filepath.Join(tmp, defaultOperatorName)
+	versions := []string{"0.0.1", defaultOperatorVersion}
+	for i, version := range versions {
+		csvConfig := CSVTemplateConfig{
+			OperatorName: defaultOperatorName,
+			Version:      version,
+			TestImageTag: testImageTag,
+			CRDKeys: []DefinitionKey{
+				{
+					Kind:  "Memcached",
+					Name:  "memcacheds.cache.example.com",
+					Group: "cache.example.com",
+					Versions: []apiextv1.CustomResourceDefinitionVersion{
+						{Name: "v1alpha1", Storage: true, Served: true},
+					},
+				},
+			},
+			InstallModes: []operatorsv1alpha1.InstallMode{
+				{Type: operatorsv1alpha1.InstallModeTypeOwnNamespace, Supported: true},
+				{Type: operatorsv1alpha1.InstallModeTypeSingleNamespace, Supported: true},
+				{Type: operatorsv1alpha1.InstallModeTypeMultiNamespace, Supported: false},
+				{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+			},
+		}
+		if i > 0 {
+			csvConfig.ReplacesCSVName = fmt.Sprintf("%s.v%s", defaultOperatorName, versions[i-1])
+		}
+		if err := writeOperatorManifests(manifestsDir, csvConfig); err != nil {
+			t.Fatal(err)
+		}
+	}
+	channels := []apimanifests.PackageChannel{
+		{Name: "alpha", CurrentCSVName: fmt.Sprintf("%s.v%s", defaultOperatorName, defaultOperatorVersion)},
+		{Name: "stable", CurrentCSVName: fmt.Sprintf("%s.v0.0.1", defaultOperatorName)},
+	}
+	if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir := filepath.Join(tmp, "bundles")
+	cmd := pkgmantobundle.NewCmd()
+	cmd.SetArgs([]string{manifestsDir, "--output-dir", outputDir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := internalregistry.DiscardLogger()
+	reg, err := containerdregistry.NewRegistry(
+		containerdregistry.WithLog(logger),
+		containerdregistry.WithCacheDir(filepath.Join(tmp, "cache")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := reg.Destroy(); err != nil {
+			t.Logf("Failed to destroy image registry: %v", err)
+		}
+	}()
+	val := registrybundle.NewImageValidator(reg, logger)
+
+	wantChannels := map[string]string{"0.0.1": "alpha,stable", defaultOperatorVersion: "alpha"}
+	for _, version := range versions {
+		bundleDir := filepath.Join(outputDir, "bundle-"+version)
+		assert.NoError(t, val.ValidateBundleFormat(bundleDir), "bundle %s format", version)
+
+		metadata, _, err := internalregistry.FindBundleMetadata(bundleDir)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, defaultOperatorName, metadata[registrybundle.PackageLabel])
+		assert.Equal(t, wantChannels[version], metadata[registrybundle.ChannelsLabel])
+		assert.Equal(t, "alpha", metadata[registrybundle.ChannelDefaultLabel])
+
+		bundle, err := apimanifests.GetBundleFromDir(filepath.Join(bundleDir, registrybundle.ManifestsDir))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, fmt.Sprintf("%s.v%s", defaultOperatorName, version), bundle.CSV.GetName())
+		assert.Len(t, bundle.V1CRDs, 1)
+		for _, result := range internalregistry.ValidateBundleContent(logger, bundle, registrybundle.RegistryV1Type) {
+			assert.False(t, result.HasError(), "bundle %s content: %v", version, result.Errors)
+		}
+	}
+}
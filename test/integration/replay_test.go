@@ -0,0 +1,141 @@
+// Copyright 2018 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	"github.com/operator-framework/operator-sdk/internal/olm/replay"
+)
+
+// Keys of scenario inputs stored in recordings.
+const (
+	replayNamespaceKey = "namespace"
+	replayImageKey     = "image"
+)
+
+// replayScenarios are replayed by TestOLMReplay from recordings in
+// testdata/recordings. To re-record them, run TestOLMIntegration against a
+// cluster with OPERATOR_SDK_RECORD set to the absolute path of that directory.
+var replayScenarios = map[string]func(*testing.T){
+	"PackageManifestsBasic":   PackageManifestsBasic,
+	"PackageManifestsUpgrade": PackageManifestsUpgrade,
+}
+
+var (
+	// wrapTransport wraps the transport of all clients created by the current scenario.
+	wrapTransport func(http.RoundTripper) http.RoundTripper
+	// recorder records the current scenario, if recording.
+	recorder *replay.Recorder
+)
+
+// newConfig returns a loaded Configuration whose requests are recorded or
+// replayed with the current scenario.
+func newConfig(t *testing.T) *operator.Configuration {
+	cfg := &operator.Configuration{KubeconfigPath: kubeconfigPath, WrapTransport: wrapTransport}
+	assert.NoError(t, cfg.Load())
+	if recorder != nil {
+		recorder.Set(replayNamespaceKey, cfg.Namespace)
+	}
+	return cfg
+}
+
+// recorded returns scenario wrapped to record its API interactions to the
+// directory in OPERATOR_SDK_RECORD, if set.
+func recorded(name string, scenario func(*testing.T)) func(*testing.T) {
+	return func(t *testing.T) {
+		rec, save := replay.Record(t, name)
+		if rec == nil {
+			scenario(t)
+			return
+		}
+		rec.Set(replayImageKey, testImageTag)
+		recorder, wrapTransport = rec, rec.Wrap
+		defer func() {
+			recorder, wrapTransport = nil, nil
+			save()
+		}()
+		scenario(t)
+	}
+}
+
+// TestOLMReplay runs scenarios against the API interactions recorded for them
+// by TestOLMIntegration, so changes to install and uninstall behavior are
+// caught without a cluster. Scenarios without a recording are skipped.
+func TestOLMReplay(t *testing.T) {
+	for name, scenario := range replayScenarios {
+		name, scenario := name, scenario
+		t.Run(name, func(t *testing.T) {
+			p, cassette, finish := replay.Replay(t, filepath.Join("testdata", "recordings", replay.FileName(name)))
+			defer finish()
+
+			kubeconfig, err := writeReplayKubeconfig(cassette.Values[replayNamespaceKey])
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(kubeconfig)
+
+			origKubeconfigPath, origImageTag := kubeconfigPath, testImageTag
+			kubeconfigPath, wrapTransport = kubeconfig, p.Wrap
+			if image := cassette.Values[replayImageKey]; image != "" {
+				testImageTag = image
+			}
+			defer func() {
+				kubeconfigPath, testImageTag, wrapTransport = origKubeconfigPath, origImageTag, nil
+			}()
+			scenario(t)
+		})
+	}
+}
+
+// writeReplayKubeconfig writes a kubeconfig for a cluster that is never
+// contacted, with namespace as the default namespace.
+func writeReplayKubeconfig(namespace string) (string, error) {
+	if namespace == "" {
+		namespace = "default"
+	}
+	f, err := ioutil.TempFile("", "replay-kubeconfig-")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, `apiVersion: v1
+kind: Config
+clusters:
+- name: replay
+  cluster:
+    server: http://replay.invalid
+contexts:
+- name: replay
+  context:
+    cluster: replay
+    namespace: %s
+    user: replay
+current-context: replay
+users:
+- name: replay
+  user:
+    token: redacted
+`, namespace)
+	return f.Name(), err
+}
@@ -0,0 +1,777 @@
+// Copyright 2020 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	apimanifests "github.com/operator-framework/api/pkg/manifests"
+	operatorsv1 "github.com/operator-framework/api/pkg/operators/v1"
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operator-framework/operator-sdk/internal/cmd/operator-sdk/pkgmantobundle"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/bundle"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/configmap"
+	"github.com/operator-framework/operator-sdk/internal/olm/operator/registry/index"
+	"github.com/operator-framework/operator-sdk/internal/util/projutil"
+)
+
+const defaultRunBundleIndexImage = "quay.io/operator-framework/upstream-opm-builder:latest"
+
+// TestRunBundle installs memcached-operator bundle images into the default
+// minimal index and into an existing index containing the operator's package.
+// Bundle and index images are pushed to the registry in TEST_REGISTRY, and
+// must be pullable by the cluster.
+func TestRunBundle(t *testing.T) {
+	testRegistry := os.Getenv(registryEnvVar)
+	if testRegistry == "" {
+		t.Skipf("%s must be set to push bundle images", registryEnvVar)
+	}
+	for _, tool := range []string{"docker", "opm"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s must be installed to build images: %v", tool, err)
+		}
+	}
+
+	// Registry pods pull bundles themselves, so must not need a docker daemon on nodes.
+	requireContainerdNodes(t, newConfig(t))
+
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	allNamespacesModes := []operatorsv1alpha1.InstallMode{
+		{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+	}
+	bundleImages := buildBundleImages(t, tmp, fmt.Sprintf("%s/%s-bundle", testRegistry, defaultOperatorName),
+		allNamespacesModes, "0.0.1", defaultOperatorVersion)
+	indexImage := fmt.Sprintf("%s/%s-index:v0.0.1", testRegistry, defaultOperatorName)
+	if err := projutil.RunCmd(exec.Command("opm", "index", "add", "--container-tool", "docker",
+		"--bundles", bundleImages["0.0.1"], "--tag", indexImage)); err != nil {
+		t.Fatal(err)
+	}
+	if err := projutil.RunCmd(exec.Command("docker", "push", indexImage)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same bundle is served from both catalog formats.
+	for _, mode := range []string{index.SQLiteCatalogMode, index.FBCCatalogMode} {
+		mode := mode
+		t.Run("DefaultIndex/"+mode, func(t *testing.T) {
+			runBundle(t, defaultRunBundleIndexImage, mode, bundleImages[defaultOperatorVersion], defaultOperatorVersion)
+		})
+	}
+	t.Run("ExistingIndex", func(t *testing.T) {
+		// The upgrade to the channel head is added on top of the index's package.
+		runBundle(t, indexImage, "", bundleImages[defaultOperatorVersion], defaultOperatorVersion)
+		// The bundle is already the index's channel head, and is replaced.
+		runBundle(t, indexImage, "", bundleImages["0.0.1"], "0.0.1")
+	})
+
+	// The operator installed from 0.0.1 is upgraded to the bundle replacing it.
+	for _, mode := range []string{index.SQLiteCatalogMode, index.FBCCatalogMode} {
+		mode := mode
+		t.Run("Upgrade/"+mode, func(t *testing.T) {
+			upgradeBundle(t, mode, bundleImages["0.0.1"], bundleImages[defaultOperatorVersion], defaultOperatorVersion)
+		})
+	}
+
+	t.Run("ServiceAccount", func(t *testing.T) {
+		bundleServiceAccount(t, bundleImages[defaultOperatorVersion])
+	})
+	t.Run("SubscriptionDeleted", func(t *testing.T) {
+		cleanupWithoutSubscription(t, bundleImages[defaultOperatorVersion])
+	})
+	t.Run("RegistryScheduling", func(t *testing.T) {
+		registryScheduling(t, bundleImages[defaultOperatorVersion])
+	})
+	t.Run("CreateNamespace", func(t *testing.T) {
+		bundleCreateNamespace(t, bundleImages[defaultOperatorVersion])
+	})
+
+	ownNamespaceModes := []operatorsv1alpha1.InstallMode{
+		{Type: operatorsv1alpha1.InstallModeTypeOwnNamespace, Supported: true},
+		{Type: operatorsv1alpha1.InstallModeTypeSingleNamespace, Supported: false},
+		{Type: operatorsv1alpha1.InstallModeTypeMultiNamespace, Supported: false},
+		{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: false},
+	}
+	ownNamespaceImages := buildBundleImages(t, filepath.Join(tmp, "own-namespace"),
+		fmt.Sprintf("%s/%s-own-namespace-bundle", testRegistry, defaultOperatorName),
+		ownNamespaceModes, defaultOperatorVersion)
+	t.Run("OwnNamespace", func(t *testing.T) {
+		bundleOwnNamespace(t, ownNamespaceImages[defaultOperatorVersion])
+	})
+}
+
+// TestRunBundlePrivateRegistry installs a memcached-operator bundle image
+// pushed to a registry requiring credentials, pulled with a pull secret.
+func TestRunBundlePrivateRegistry(t *testing.T) {
+	privateRegistry := os.Getenv(privateRegistryEnvVar)
+	dockerConfigPath := os.Getenv(privateRegistryConfigEnvVar)
+	if privateRegistry == "" || dockerConfigPath == "" {
+		t.Skipf("%s and %s must be set to push private bundle images", privateRegistryEnvVar, privateRegistryConfigEnvVar)
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skipf("docker must be installed to build images: %v", err)
+	}
+	dockerConfig, err := ioutil.ReadFile(dockerConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	allNamespacesModes := []operatorsv1alpha1.InstallMode{
+		{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+	}
+	bundleImages := buildBundleImages(t, tmp, fmt.Sprintf("%s/%s-bundle", privateRegistry, defaultOperatorName),
+		allNamespacesModes, defaultOperatorVersion)
+	bundleImage := bundleImages[defaultOperatorVersion]
+
+	cfg := newConfig(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	t.Run("MissingSecret", func(t *testing.T) {
+		i := bundle.NewInstall(cfg)
+		i.BundleImage = bundleImage
+		i.IndexImage = defaultRunBundleIndexImage
+		i.PullSecretName = "operator-sdk-missing-creds"
+		_, err := i.Run(ctx)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), `pull secret "operator-sdk-missing-creds" not found`)
+		}
+	})
+
+	secret := &corev1.Secret{Type: corev1.SecretTypeDockerConfigJson}
+	secret.SetName("operator-sdk-registry-creds")
+	secret.SetNamespace(cfg.Namespace)
+	secret.Data = map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig}
+	if err := cfg.Client.Create(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cfg.Client.Delete(context.Background(), secret); err != nil {
+			t.Log(err)
+		}
+	}()
+
+	t.Run("PullSecret", func(t *testing.T) {
+		i := bundle.NewInstall(cfg)
+		i.BundleImage = bundleImage
+		i.IndexImage = defaultRunBundleIndexImage
+		i.PullSecretName = secret.GetName()
+		if err := i.InstallMode.Set(string(operatorsv1alpha1.InstallModeTypeAllNamespaces)); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			assert.NoError(t, doUninstall(t, kubeconfigPath))
+		}()
+		if _, err := i.Run(ctx); !assert.NoError(t, err) {
+			return
+		}
+		cs := operatorsv1alpha1.CatalogSource{}
+		csKey := types.NamespacedName{Namespace: cfg.Namespace, Name: defaultOperatorName + "-catalog"}
+		if assert.NoError(t, cfg.Client.Get(ctx, csKey, &cs)) {
+			assert.Equal(t, []string{secret.GetName()}, cs.Spec.Secrets)
+		}
+	})
+}
+
+// TestRunBundleCustomCA installs a memcached-operator bundle image pushed to a
+// registry whose certificate is signed by a self-signed CA, which the registry
+// pod trusts via a CA secret. The cluster's nodes must also trust the CA, since
+// they pull the index image and OLM's bundle unpack job images.
+func TestRunBundleCustomCA(t *testing.T) {
+	caRegistry := os.Getenv(caRegistryEnvVar)
+	caCertPath := os.Getenv(caRegistryCertEnvVar)
+	if caRegistry == "" || caCertPath == "" {
+		t.Skipf("%s and %s must be set to push bundle images to a registry using a custom CA",
+			caRegistryEnvVar, caRegistryCertEnvVar)
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skipf("docker must be installed to build images: %v", err)
+	}
+	caCert, err := ioutil.ReadFile(caCertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	allNamespacesModes := []operatorsv1alpha1.InstallMode{
+		{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+	}
+	bundleImages := buildBundleImages(t, tmp, fmt.Sprintf("%s/%s-bundle", caRegistry, defaultOperatorName),
+		allNamespacesModes, defaultOperatorVersion)
+	bundleImage := bundleImages[defaultOperatorVersion]
+
+	cfg := newConfig(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	// createCASecret creates a CA secret containing cert, returning a func deleting it.
+	createCASecret := func(t *testing.T, name string, cert []byte) func() {
+		secret := &corev1.Secret{}
+		secret.SetName(name)
+		secret.SetNamespace(cfg.Namespace)
+		secret.Data = map[string][]byte{"cert.pem": cert}
+		if err := cfg.Client.Create(ctx, secret); err != nil {
+			t.Fatal(err)
+		}
+		return func() {
+			if err := cfg.Client.Delete(context.Background(), secret); err != nil {
+				t.Log(err)
+			}
+		}
+	}
+
+	t.Run("InvalidCA", func(t *testing.T) {
+		defer createCASecret(t, "operator-sdk-invalid-ca", []byte("not a certificate"))()
+		i := bundle.NewInstall(cfg)
+		i.BundleImage = bundleImage
+		i.IndexImage = defaultRunBundleIndexImage
+		i.CASecretName = "operator-sdk-invalid-ca"
+		_, err := i.Run(ctx)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "contains no PEM-encoded certificates")
+		}
+		// The secret is checked before any resources are created.
+		cs := operatorsv1alpha1.CatalogSource{}
+		csKey := types.NamespacedName{Namespace: cfg.Namespace, Name: defaultOperatorName + "-catalog"}
+		assert.True(t, apierrors.IsNotFound(cfg.Client.Get(ctx, csKey, &cs)), "CatalogSource should not exist")
+	})
+
+	t.Run("CASecret", func(t *testing.T) {
+		defer createCASecret(t, "operator-sdk-registry-ca", caCert)()
+		i := bundle.NewInstall(cfg)
+		i.BundleImage = bundleImage
+		i.IndexImage = defaultRunBundleIndexImage
+		i.CASecretName = "operator-sdk-registry-ca"
+		if err := i.InstallMode.Set(string(operatorsv1alpha1.InstallModeTypeAllNamespaces)); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			assert.NoError(t, doUninstall(t, kubeconfigPath))
+		}()
+		csv, err := i.Run(ctx)
+		if assert.NoError(t, err) {
+			assert.Equal(t, fmt.Sprintf("%s.v%s", defaultOperatorName, defaultOperatorVersion), csv.GetName())
+		}
+	})
+}
+
+// TestRunBundlePlainHTTP installs and upgrades memcached-operator bundle images
+// pushed to a registry serving plain HTTP, which the SDK and registry pod pull
+// from with UseHTTP. The cluster's nodes must be configured to pull from the
+// registry over HTTP, as kind's local registry setup does.
+func TestRunBundlePlainHTTP(t *testing.T) {
+	httpRegistry := os.Getenv(httpRegistryEnvVar)
+	if httpRegistry == "" {
+		t.Skipf("%s must be set to push bundle images to a plain HTTP registry", httpRegistryEnvVar)
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skipf("docker must be installed to build images: %v", err)
+	}
+
+	tmp, cleanup := mkTempDirWithCleanup(t, "")
+	defer cleanup()
+
+	allNamespacesModes := []operatorsv1alpha1.InstallMode{
+		{Type: operatorsv1alpha1.InstallModeTypeAllNamespaces, Supported: true},
+	}
+	bundleImages := buildBundleImages(t, tmp, fmt.Sprintf("%s/%s-bundle", httpRegistry, defaultOperatorName),
+		allNamespacesModes, "0.0.1", defaultOperatorVersion)
+
+	cfg := newConfig(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	i := bundle.NewInstall(cfg)
+	i.BundleImage = bundleImages["0.0.1"]
+	i.IndexImage = defaultRunBundleIndexImage
+	i.UseHTTP = true
+	if err := i.InstallMode.Set(string(operatorsv1alpha1.InstallModeTypeAllNamespaces)); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		assert.NoError(t, doUninstall(t, kubeconfigPath))
+	}()
+	if _, err := i.Run(ctx); !assert.NoError(t, err) {
+		return
+	}
+
+	// The upgrade pulls over plain HTTP as the catalog was created with.
+	u := bundle.NewUpgrade(cfg)
+	u.BundleImage = bundleImages[defaultOperatorVersion]
+	csv, err := u.Run(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, fmt.Sprintf("%s.v%s", defaultOperatorName, defaultOperatorVersion), csv.GetName())
+
+	pods := corev1.PodList{}
+	if assert.NoError(t, cfg.Client.List(ctx, &pods, client.InNamespace(cfg.Namespace))) {
+		var cmds []string
+		for _, pod := range pods.Items {
+			for _, c := range pod.Spec.Containers {
+				cmds = append(cmds, strings.Join(c.Command, " "))
+			}
+		}
+		assert.Contains(t, strings.Join(cmds, "\n"), "--use-http")
+	}
+}
+
+// bundleOwnNamespace installs bundleImage, whose CSV only supports the
+// OwnNamespace install mode, with unsupported and supported install modes.
+func bundleOwnNamespace(t *testing.T, bundleImage string) {
+	cfg := newConfig(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	// Unsupported install modes are rejected before any resources are created.
+	i := bundle.NewInstall(cfg)
+	i.BundleImage = bundleImage
+	i.IndexImage = defaultRunBundleIndexImage
+	if err := i.InstallMode.Set(string(operatorsv1alpha1.InstallModeTypeAllNamespaces)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := i.Run(ctx)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `install mode type "AllNamespaces" not supported`)
+	}
+	cs := operatorsv1alpha1.CatalogSource{}
+	csKey := types.NamespacedName{Namespace: cfg.Namespace, Name: defaultOperatorName + "-catalog"}
+	assert.True(t, apierrors.IsNotFound(cfg.Client.Get(ctx, csKey, &cs)), "CatalogSource should not exist")
+
+	i = bundle.NewInstall(cfg)
+	i.BundleImage = bundleImage
+	i.IndexImage = defaultRunBundleIndexImage
+	if err := i.InstallMode.Set(string(operatorsv1alpha1.InstallModeTypeOwnNamespace)); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		assert.NoError(t, doUninstall(t, kubeconfigPath))
+	}()
+	if _, err := i.Run(ctx); !assert.NoError(t, err) {
+		return
+	}
+	og := operatorsv1.OperatorGroup{}
+	ogKey := types.NamespacedName{Namespace: cfg.Namespace, Name: operator.SDKOperatorGroupName}
+	if assert.NoError(t, cfg.Client.Get(ctx, ogKey, &og)) {
+		assert.Equal(t, []string{cfg.Namespace}, og.Spec.TargetNamespaces)
+	}
+}
+
+// runBundle installs bundleImage in indexImage, served in catalogMode, checks
+// that the bundle's CSV is installed from a registry pod running indexImage,
+// then uninstalls it.
+func runBundle(t *testing.T, indexImage, catalogMode, bundleImage, version string) {
+	cfg := newConfig(t)
+	i := bundle.NewInstall(cfg)
+	i.BundleImage = bundleImage
+	i.IndexImage = indexImage
+	i.CatalogMode = catalogMode
+	if err := i.InstallMode.Set(string(operatorsv1alpha1.InstallModeTypeAllNamespaces)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	csv, err := i.Run(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, fmt.Sprintf("%s.v%s", defaultOperatorName, version), csv.GetName())
+
+		cs := operatorsv1alpha1.CatalogSource{}
+		csKey := types.NamespacedName{Namespace: cfg.Namespace, Name: defaultOperatorName + "-catalog"}
+		if assert.NoError(t, cfg.Client.Get(ctx, csKey, &cs)) {
+			assert.Equal(t, indexImage, cs.GetAnnotations()["operators.operatorframework.io/index-image"])
+		}
+		pods := corev1.PodList{}
+		if assert.NoError(t, cfg.Client.List(ctx, &pods, client.InNamespace(cfg.Namespace))) {
+			var images []string
+			for _, pod := range pods.Items {
+				for _, c := range pod.Spec.Containers {
+					images = append(images, c.Image)
+				}
+			}
+			assert.Contains(t, images, indexImage)
+		}
+		assertRegistryServiceAccount(ctx, t, cfg, operator.SDKRegistryServiceAccountName)
+	}
+
+	assert.NoError(t, doUninstall(t, kubeconfigPath))
+}
+
+// bundleServiceAccount installs bundleImage with a registry pod running as an
+// existing ServiceAccount, which is neither created nor deleted by the SDK.
+func bundleServiceAccount(t *testing.T, bundleImage string) {
+	cfg := newConfig(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	i := bundle.NewInstall(cfg)
+	i.BundleImage = bundleImage
+	i.IndexImage = defaultRunBundleIndexImage
+	i.ServiceAccountName = "memcached-registry"
+	if err := i.InstallMode.Set(string(operatorsv1alpha1.InstallModeTypeAllNamespaces)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := i.Run(ctx)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `service account "memcached-registry" not found`)
+	}
+
+	sa := &corev1.ServiceAccount{}
+	sa.SetName(i.ServiceAccountName)
+	sa.SetNamespace(cfg.Namespace)
+	if err := cfg.Client.Create(ctx, sa); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cfg.Client.Delete(context.Background(), sa); err != nil {
+			t.Log(err)
+		}
+	}()
+
+	if _, err := i.Run(ctx); assert.NoError(t, err) {
+		assertRegistryServiceAccount(ctx, t, cfg, sa.GetName())
+		sdkSA := types.NamespacedName{Namespace: cfg.Namespace, Name: operator.SDKRegistryServiceAccountName}
+		assert.True(t, apierrors.IsNotFound(cfg.Client.Get(ctx, sdkSA, &corev1.ServiceAccount{})),
+			"SDK registry ServiceAccount should not exist")
+	}
+	if !assert.NoError(t, doUninstall(t, kubeconfigPath)) {
+		return
+	}
+	assert.NoError(t, cfg.Client.Get(ctx, types.NamespacedName{Namespace: cfg.Namespace, Name: sa.GetName()}, sa))
+}
+
+// bundleCreateNamespace installs bundleImage in a namespace that does not exist
+// with CreateNamespace, then deletes the namespace it created on uninstall.
+func bundleCreateNamespace(t *testing.T, bundleImage string) {
+	cfg := newConfig(t)
+	cfg.Namespace = "run-bundle-create-ns"
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	nsKey := types.NamespacedName{Name: cfg.Namespace}
+	if !assert.True(t, apierrors.IsNotFound(cfg.Client.Get(ctx, nsKey, &corev1.Namespace{})), "namespace should not exist") {
+		return
+	}
+
+	i := bundle.NewInstall(cfg)
+	i.BundleImage = bundleImage
+	i.IndexImage = defaultRunBundleIndexImage
+	i.CreateNamespace = true
+	if err := i.InstallMode.Set(string(operatorsv1alpha1.InstallModeTypeAllNamespaces)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := i.Run(ctx)
+	assert.NoError(t, err)
+
+	ns := &corev1.Namespace{}
+	if assert.NoError(t, cfg.Client.Get(ctx, nsKey, ns)) {
+		assert.Equal(t, "operator-sdk", ns.GetLabels()["owner"])
+	}
+
+	u := operator.NewUninstall(cfg)
+	u.Package = defaultOperatorName
+	u.DeleteAll = true
+	u.DeleteNamespace = true
+	u.Logf = t.Logf
+	if assert.NoError(t, u.Run(ctx)) {
+		assert.True(t, apierrors.IsNotFound(cfg.Client.Get(ctx, nsKey, ns)), "namespace should be deleted")
+	}
+}
+
+// registryScheduling taints a node, and checks that a catalog for bundleImage
+// created with a matching node selector and toleration is served from it. Only the
+// catalog is created, since OLM's pods do not tolerate the taint.
+func registryScheduling(t *testing.T, bundleImage string) {
+	cfg := newConfig(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	const taintKey = "operator-sdk.e2e/registry"
+	nodes := corev1.NodeList{}
+	if err := cfg.Client.List(ctx, &nodes); err != nil || len(nodes.Items) == 0 {
+		t.Fatalf("list nodes: %v", err)
+	}
+	node := &nodes.Items[0]
+	orig := node.DeepCopy()
+	labels := node.GetLabels()
+	labels[taintKey] = "true"
+	node.SetLabels(labels)
+	node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{Key: taintKey, Value: "true", Effect: corev1.TaintEffectNoSchedule})
+	if err := cfg.Client.Patch(ctx, node, client.MergeFrom(orig)); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		restored := node.DeepCopy()
+		restored.SetLabels(orig.GetLabels())
+		restored.Spec.Taints = orig.Spec.Taints
+		if err := cfg.Client.Patch(context.Background(), restored, client.MergeFrom(node)); err != nil {
+			t.Log(err)
+		}
+	}()
+
+	c := registry.NewIndexImageCatalogCreator(cfg)
+	c.IndexImage = defaultRunBundleIndexImage
+	c.BundleImage = bundleImage
+	c.InjectBundles = []string{bundleImage}
+	c.InjectBundleMode = index.SemverBundleAddMode
+	c.PackageName = defaultOperatorName
+	c.RegistryNodeSelector = map[string]string{taintKey: "true"}
+	c.RegistryTolerations = []corev1.Toleration{{
+		Key: taintKey, Operator: corev1.TolerationOpEqual, Value: "true", Effect: corev1.TaintEffectNoSchedule,
+	}}
+	cs, err := c.CreateCatalog(ctx, defaultOperatorName+"-catalog")
+	if err == nil {
+		pods := corev1.PodList{}
+		if assert.NoError(t, cfg.Client.List(ctx, &pods, client.InNamespace(cfg.Namespace),
+			client.MatchingLabels(configmap.MakeRegistryLabels(defaultOperatorName)))) && assert.Len(t, pods.Items, 1) {
+			assert.Equal(t, node.GetName(), pods.Items[0].Spec.NodeName)
+			assert.Equal(t, corev1.PodRunning, pods.Items[0].Status.Phase)
+		}
+	}
+	assert.NoError(t, err)
+
+	// Without a Subscription, cleanup finds the catalog by label.
+	if cs != nil {
+		assert.NoError(t, doUninstall(t, kubeconfigPath))
+	}
+}
+
+// assertRegistryServiceAccount asserts that the operator's registry pods run as
+// the ServiceAccount named name, and none as the namespace's default.
+func assertRegistryServiceAccount(ctx context.Context, t *testing.T, cfg *operator.Configuration, name string) {
+	pods := corev1.PodList{}
+	if !assert.NoError(t, cfg.Client.List(ctx, &pods, client.InNamespace(cfg.Namespace),
+		client.MatchingLabels(configmap.MakeRegistryLabels(defaultOperatorName)))) {
+		return
+	}
+	assert.NotEmpty(t, pods.Items, "no registry pods found")
+	for _, pod := range pods.Items {
+		assert.Equal(t, name, pod.Spec.ServiceAccountName, "registry pod %q service account", pod.GetName())
+		for _, v := range pod.Spec.Volumes {
+			assert.Nil(t, v.HostPath, "registry pod %q mounts host path volume %q", pod.GetName(), v.Name)
+		}
+	}
+}
+
+// requireContainerdNodes fails t unless every node runs containerd, so no
+// docker daemon is available to registry pods.
+func requireContainerdNodes(t *testing.T, cfg *operator.Configuration) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	nodes := corev1.NodeList{}
+	if err := cfg.Client.List(ctx, &nodes); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range nodes.Items {
+		if rt := node.Status.NodeInfo.ContainerRuntimeVersion; !strings.HasPrefix(rt, "containerd://") {
+			t.Fatalf("node %q runs %q, run bundle tests must run on containerd-only nodes, ex. kind's", node.GetName(), rt)
+		}
+	}
+}
+
+// cleanupWithoutSubscription installs bundleImage, deletes its Subscription by
+// hand, then checks that uninstalling still leaves no objects in the namespace.
+func cleanupWithoutSubscription(t *testing.T, bundleImage string) {
+	cfg := newConfig(t)
+	i := bundle.NewInstall(cfg)
+	i.BundleImage = bundleImage
+	i.IndexImage = defaultRunBundleIndexImage
+	if err := i.InstallMode.Set(string(operatorsv1alpha1.InstallModeTypeAllNamespaces)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	if _, err := i.Run(ctx); !assert.NoError(t, err) {
+		assert.NoError(t, doUninstall(t, kubeconfigPath))
+		return
+	}
+
+	subs := operatorsv1alpha1.SubscriptionList{}
+	if err := cfg.Client.List(ctx, &subs, client.InNamespace(cfg.Namespace)); err != nil {
+		t.Fatal(err)
+	}
+	for j := range subs.Items {
+		if err := cfg.Client.Delete(ctx, &subs.Items[j]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !assert.NoError(t, doUninstall(t, kubeconfigPath)) {
+		return
+	}
+
+	// Pods of the operator's Deployment are garbage-collected after its CSV.
+	remaining := func() (names []string, err error) {
+		lists := map[string]runtime.Object{
+			"subscription":  &operatorsv1alpha1.SubscriptionList{},
+			"csv":           &operatorsv1alpha1.ClusterServiceVersionList{},
+			"catalogsource": &operatorsv1alpha1.CatalogSourceList{},
+			"job":           &batchv1.JobList{},
+			"pod":           &corev1.PodList{},
+			"configmap":     &corev1.ConfigMapList{},
+		}
+		for kind, list := range lists {
+			if err := cfg.Client.List(ctx, list, client.InNamespace(cfg.Namespace)); err != nil {
+				return nil, err
+			}
+			objs, err := meta.ExtractList(list)
+			if err != nil {
+				return nil, err
+			}
+			for _, obj := range objs {
+				accessor, err := meta.Accessor(obj)
+				if err != nil {
+					return nil, err
+				}
+				// Published into every namespace by the cluster.
+				if kind == "configmap" && accessor.GetName() == "kube-root-ca.crt" {
+					continue
+				}
+				names = append(names, kind+"/"+accessor.GetName())
+			}
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+	var names []string
+	err := wait.PollImmediateUntil(time.Second, func() (done bool, err error) {
+		names, err = remaining()
+		return len(names) == 0, err
+	}, ctx.Done())
+	assert.NoError(t, err, "objects remaining in namespace %q: %v", cfg.Namespace, names)
+}
+
+// upgradeBundle installs fromBundleImage in the default index served in catalogMode,
+// upgrades it to toBundleImage, and checks that the CSV for version replaced the
+// installed CSV, then uninstalls it.
+func upgradeBundle(t *testing.T, catalogMode, fromBundleImage, toBundleImage, version string) {
+	cfg := newConfig(t)
+	i := bundle.NewInstall(cfg)
+	i.BundleImage = fromBundleImage
+	i.IndexImage = defaultRunBundleIndexImage
+	i.CatalogMode = catalogMode
+	if err := i.InstallMode.Set(string(operatorsv1alpha1.InstallModeTypeAllNamespaces)); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		assert.NoError(t, doUninstall(t, kubeconfigPath))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	prevCSV, err := i.Run(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	u := bundle.NewUpgrade(cfg)
+	u.BundleImage = toBundleImage
+	csv, err := u.Run(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, fmt.Sprintf("%s.v%s", defaultOperatorName, version), csv.GetName())
+	assert.Equal(t, prevCSV.GetName(), csv.Spec.Replaces)
+
+	// Upgrading to an installed bundle is refused.
+	u = bundle.NewUpgrade(cfg)
+	u.BundleImage = toBundleImage
+	_, err = u.Run(ctx)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is already installed")
+	}
+}
+
+// buildBundleImages writes memcached-operator package manifests supporting
+// installModes for versions, each replacing the previous, converts them to
+// bundles, then builds and pushes each bundle's image to imageTagBase.
+// Images are returned keyed by version.
+func buildBundleImages(t *testing.T, tmp, imageTagBase string, installModes []operatorsv1alpha1.InstallMode,
+	versions ...string) map[string]string {
+	manifestsDir := filepath.Join(tmp, defaultOperatorName)
+	for i, version := range versions {
+		csvConfig := CSVTemplateConfig{
+			OperatorName: defaultOperatorName,
+			Version:      version,
+			TestImageTag: testImageTag,
+			CRDKeys: []DefinitionKey{
+				{
+					Kind:  "Memcached",
+					Name:  "memcacheds.cache.example.com",
+					Group: "cache.example.com",
+					Versions: []apiextv1.CustomResourceDefinitionVersion{
+						{Name: "v1alpha1", Storage: true, Served: true},
+					},
+				},
+			},
+			InstallModes: installModes,
+		}
+		if i > 0 {
+			csvConfig.ReplacesCSVName = fmt.Sprintf("%s.v%s", defaultOperatorName, versions[i-1])
+		}
+		if err := writeOperatorManifests(manifestsDir, csvConfig); err != nil {
+			t.Fatal(err)
+		}
+	}
+	channels := []apimanifests.PackageChannel{
+		{Name: "alpha", CurrentCSVName: fmt.Sprintf("%s.v%s", defaultOperatorName, versions[len(versions)-1])},
+	}
+	if err := writePackageManifest(manifestsDir, defaultOperatorName, channels); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := pkgmantobundle.NewCmd()
+	cmd.SetArgs([]string{manifestsDir, "--output-dir", filepath.Join(tmp, "bundles"), "--image-tag-base", imageTagBase})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	images := make(map[string]string, len(versions))
+	for _, version := range versions {
+		images[version] = fmt.Sprintf("%s:v%s", imageTagBase, version)
+		if err := projutil.RunCmd(exec.Command("docker", "push", images[version])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return images
+}